@@ -0,0 +1,224 @@
+// Package audit records who changed what through the API: uploads, deletes,
+// bucket switches, configuration changes, exports, job changes and so on.
+// Entries are kept in a SQLite database and can be queried by caller,
+// action and time, for governance of the bronze layer.
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"bronze-backend/config"
+
+	_ "modernc.org/sqlite"
+)
+
+// retentionInterval is how often entries past the retention period are
+// pruned.
+const retentionInterval = time.Hour
+
+const schema = `
+CREATE TABLE IF NOT EXISTS audit_entries (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	entry_time INTEGER NOT NULL,
+	subject    TEXT NOT NULL DEFAULT '',
+	action     TEXT NOT NULL,
+	path       TEXT NOT NULL,
+	status     INTEGER NOT NULL,
+	data       TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_entries_time ON audit_entries (entry_time);
+CREATE INDEX IF NOT EXISTS audit_entries_subject ON audit_entries (subject, entry_time);
+CREATE INDEX IF NOT EXISTS audit_entries_action ON audit_entries (action, entry_time);
+`
+
+// Entry is one audited request.
+type Entry struct {
+	ID         int64             `json:"id"`
+	Time       time.Time         `json:"time"`
+	Subject    string            `json:"subject,omitempty"` // Empty when auth is disabled
+	Role       string            `json:"role,omitempty"`
+	Action     string            `json:"action"` // Method and route, e.g. "DELETE /api/files/{filename}"
+	Path       string            `json:"path"`
+	Params     map[string]string `json:"params,omitempty"` // Route variables, e.g. the job id
+	Query      string            `json:"query,omitempty"`
+	Body       json.RawMessage   `json:"body,omitempty"` // JSON request body, secrets redacted
+	Form       map[string]string `json:"form,omitempty"` // Multipart fields; file fields hold the file name
+	Status     int               `json:"status"`
+	Duration   time.Duration     `json:"duration"`
+	RemoteAddr string            `json:"remote_addr"`
+}
+
+// Log is the audit log. A nil Log records nothing.
+type Log struct {
+	db        *sql.DB
+	retention time.Duration
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// Open opens (or creates) the audit log, or returns nil when auditing is
+// disabled.
+func Open(cfg config.AuditConfig) (*Log, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.DBPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create audit database directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+cfg.DBPath+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit database: %w", err)
+	}
+	// One writer at a time, as for the watcher's event database
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create audit schema: %w", err)
+	}
+
+	l := &Log{
+		db:        db,
+		retention: cfg.Retention,
+		stop:      make(chan struct{}),
+	}
+	if l.retention > 0 {
+		l.wg.Add(1)
+		go l.retentionLoop()
+	}
+	return l, nil
+}
+
+// Close stops pruning and closes the database.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	close(l.stop)
+	l.wg.Wait()
+	return l.db.Close()
+}
+
+// Record stores entry, setting its ID.
+func (l *Log) Record(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+
+	result, err := l.db.Exec(`INSERT INTO audit_entries
+		(entry_time, subject, action, path, status, data) VALUES (?, ?, ?, ?, ?, ?)`,
+		entry.Time.UnixNano(), entry.Subject, entry.Action, entry.Path, entry.Status, string(data))
+	if err != nil {
+		return err
+	}
+	entry.ID, err = result.LastInsertId()
+	return err
+}
+
+// Query returns the entries matching filter, newest first, and the number
+// of matches before paging.
+func (l *Log) Query(filter Filter) ([]*Entry, int, error) {
+	var where []string
+	var args []any
+	if filter.Subject != "" {
+		where = append(where, "subject = ?")
+		args = append(args, filter.Subject)
+	}
+	if filter.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.PathPrefix != "" {
+		where = append(where, "substr(path, 1, ?) = ?")
+		args = append(args, len(filter.PathPrefix), filter.PathPrefix)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "entry_time >= ?")
+		args = append(args, filter.Since.UnixNano())
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "entry_time < ?")
+		args = append(args, filter.Until.UnixNano())
+	}
+
+	clause := ""
+	if len(where) > 0 {
+		clause = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := l.db.QueryRow(`SELECT COUNT(*) FROM audit_entries`+clause, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1 // No limit
+	}
+	rows, err := l.db.Query(`SELECT id, data FROM audit_entries`+clause+
+		` ORDER BY entry_time DESC, id DESC LIMIT ? OFFSET ?`,
+		append(args, limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []*Entry{}
+	for rows.Next() {
+		var id int64
+		var data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, 0, err
+		}
+		entry := &Entry{}
+		if err := json.Unmarshal([]byte(data), entry); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode audit entry %d: %w", id, err)
+		}
+		entry.ID = id
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
+
+// Prune deletes entries older than before.
+func (l *Log) Prune(before time.Time) (int, error) {
+	result, err := l.db.Exec(`DELETE FROM audit_entries WHERE entry_time < ?`, before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
+
+func (l *Log) retentionLoop() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := l.Prune(time.Now().Add(-l.retention))
+		if err != nil {
+			log.Printf("Warning: Failed to prune audit log: %v", err)
+		} else if deleted > 0 {
+			log.Printf("Pruned %d audit entries older than %v", deleted, l.retention)
+		}
+
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"bronze-backend/httputil"
+)
+
+const (
+	defaultQueryLimit = 100
+	maxQueryLimit     = 1000
+)
+
+// Filter selects and pages audit entries.
+type Filter struct {
+	Subject    string
+	Action     string // Exact action, e.g. "POST /api/buckets/set"
+	PathPrefix string
+	Since      time.Time
+	Until      time.Time
+	Limit      int // 0 returns every match
+	Offset     int
+}
+
+// ParseFilter reads a Filter from GET /api/audit query parameters.
+func ParseFilter(query url.Values) (Filter, error) {
+	filter := Filter{
+		Subject:    query.Get("subject"),
+		Action:     query.Get("action"),
+		PathPrefix: query.Get("path"),
+		Limit:      defaultQueryLimit,
+	}
+
+	var err error
+	if filter.Since, err = parseTime(query.Get("since")); err != nil {
+		return filter, fmt.Errorf("invalid since: %w", err)
+	}
+	if filter.Until, err = parseTime(query.Get("until")); err != nil {
+		return filter, fmt.Errorf("invalid until: %w", err)
+	}
+
+	if value := query.Get("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil || filter.Limit < 1 || filter.Limit > maxQueryLimit {
+			return filter, fmt.Errorf("invalid limit %q, use 1 to %d", value, maxQueryLimit)
+		}
+	}
+	if value := query.Get("offset"); value != "" {
+		if filter.Offset, err = strconv.Atoi(value); err != nil || filter.Offset < 0 {
+			return filter, fmt.Errorf("invalid offset %q", value)
+		}
+	}
+
+	return filter, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// Handler serves the audit log.
+type Handler struct {
+	log *Log
+}
+
+func NewHandler(log *Log) *Handler {
+	return &Handler{log: log}
+}
+
+// ListEntriesResponse is the body of GET /api/audit.
+type ListEntriesResponse struct {
+	Success bool     `json:"success"`
+	Entries []*Entry `json:"entries"`
+	Count   int      `json:"count"`
+	Total   int      `json:"total"`
+	Limit   int      `json:"limit"`
+	Offset  int      `json:"offset"`
+}
+
+// ListEntries returns audit entries, newest first, filtered by subject,
+// action, path prefix and time.
+func (h *Handler) ListEntries(w http.ResponseWriter, r *http.Request) {
+	if h.log == nil {
+		httputil.Error(w, "Audit log is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	filter, err := ParseFilter(r.URL.Query())
+	if err != nil {
+		httputil.WriteError(w, "Invalid query parameters", http.StatusBadRequest, err)
+		return
+	}
+
+	entries, total, err := h.log.Query(filter)
+	if err != nil {
+		httputil.WriteError(w, "Failed to query audit log", http.StatusInternalServerError, err)
+		return
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, ListEntriesResponse{
+		Success: true,
+		Entries: entries,
+		Count:   len(entries),
+		Total:   total,
+		Limit:   filter.Limit,
+		Offset:  filter.Offset,
+	})
+}
@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"bronze-backend/auth"
+	"bronze-backend/config"
+
+	"github.com/gorilla/mux"
+)
+
+func openTestLog(t *testing.T) *Log {
+	t.Helper()
+	l, err := Open(config.AuditConfig{Enabled: true, DBPath: filepath.Join(t.TempDir(), "audit.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestQueryFilters(t *testing.T) {
+	l := openTestLog(t)
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, e := range []Entry{
+		{Subject: "alice", Action: "POST /api/files/upload", Path: "/api/files/upload"},
+		{Subject: "bob", Action: "DELETE /api/files/{filename}", Path: "/api/files/a.csv"},
+		{Subject: "alice", Action: "POST /api/buckets/set", Path: "/api/buckets/set"},
+	} {
+		e.Time = base.Add(time.Duration(i) * time.Minute)
+		e.Status = http.StatusOK
+		if err := l.Record(&e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string // Paths, newest first
+	}{
+		{"all", Filter{}, []string{"/api/buckets/set", "/api/files/a.csv", "/api/files/upload"}},
+		{"subject", Filter{Subject: "alice"}, []string{"/api/buckets/set", "/api/files/upload"}},
+		{"action", Filter{Action: "DELETE /api/files/{filename}"}, []string{"/api/files/a.csv"}},
+		{"path prefix", Filter{PathPrefix: "/api/files/"}, []string{"/api/files/a.csv", "/api/files/upload"}},
+		{"time range", Filter{Since: base.Add(time.Minute), Until: base.Add(2 * time.Minute)}, []string{"/api/files/a.csv"}},
+		{"paged", Filter{Limit: 1, Offset: 1}, []string{"/api/files/a.csv"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, _, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Path)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, total, _ := l.Query(Filter{Limit: 1}); total != 3 {
+		t.Errorf("total = %d, want 3", total)
+	}
+
+	if deleted, err := l.Prune(base.Add(time.Minute)); err != nil || deleted != 1 {
+		t.Errorf("Prune = %d, %v; want 1 entry deleted", deleted, err)
+	}
+}
+
+func TestMiddlewareRecordsRequest(t *testing.T) {
+	l := openTestLog(t)
+
+	var handlerBody string
+	router := mux.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := &auth.Principal{Subject: "alice", Role: auth.RoleAdmin}
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}, l.Middleware)
+	router.HandleFunc("/api/jobs/{id:[a-z0-9-]+}", func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		handlerBody = string(data)
+		w.WriteHeader(http.StatusAccepted)
+	}).Methods("PUT")
+
+	body := `{"priority":"high","password":"hunter2","nested":{"secret_key":"x"}}`
+	req := httptest.NewRequest(http.MethodPut, "/api/jobs/job-1?force=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if handlerBody != body {
+		t.Errorf("handler read %q, want the original body", handlerBody)
+	}
+
+	entries, _, err := l.Query(Filter{})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Query = %v, %v; want one entry", entries, err)
+	}
+	e := entries[0]
+	if e.Subject != "alice" || e.Role != "admin" {
+		t.Errorf("caller = %q/%q, want alice/admin", e.Subject, e.Role)
+	}
+	if e.Action != "PUT /api/jobs/{id}" || e.Params["id"] != "job-1" || e.Query != "force=true" {
+		t.Errorf("request = %q %v %q", e.Action, e.Params, e.Query)
+	}
+	if e.Status != http.StatusAccepted {
+		t.Errorf("status = %d, want 202", e.Status)
+	}
+
+	var recorded map[string]any
+	if err := json.Unmarshal(e.Body, &recorded); err != nil {
+		t.Fatal(err)
+	}
+	if recorded["priority"] != "high" || recorded["password"] != redactedValue ||
+		recorded["nested"].(map[string]any)["secret_key"] != redactedValue {
+		t.Errorf("body = %s, want secrets redacted", e.Body)
+	}
+}
+
+func TestNilLogPassesThrough(t *testing.T) {
+	var l *Log
+	called := false
+	l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+	if !called {
+		t.Error("handler not called")
+	}
+
+	rec := httptest.NewRecorder()
+	NewHandler(nil).ListEntries(rec, httptest.NewRequest(http.MethodGet, "/api/audit", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want 503", rec.Code)
+	}
+}
+
+func TestParseFilterRejectsBadValues(t *testing.T) {
+	for _, query := range []string{"limit=0", "limit=5000", "offset=-1", "since=yesterday"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/audit?"+query, nil)
+		if _, err := ParseFilter(req.URL.Query()); err == nil {
+			t.Errorf("%s accepted", query)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"bronze-backend/auth"
+	"bronze-backend/httputil"
+
+	"github.com/gorilla/mux"
+)
+
+// maxBodyBytes is the largest JSON request body stored with an entry.
+// Larger bodies are left out rather than truncated into invalid JSON.
+const maxBodyBytes = 8 << 10
+
+// redactedValue replaces secrets in stored request bodies.
+const redactedValue = "********"
+
+// sensitiveKeys are substrings of JSON field names whose values are never
+// stored, such as archive passwords and the MinIO secret key.
+var sensitiveKeys = []string{"password", "secret", "token"}
+
+// routeVariablePattern matches the pattern part of a route variable, so
+// "{filename:.+}" is recorded as "{filename}".
+var routeVariablePattern = regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+// Middleware records every request it wraps once the handler has returned.
+// It belongs on routes that change something; the caller is taken from the
+// request context, so it must run after authentication.
+func (l *Log) Middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		body := captureBody(r)
+		recorder := httputil.NewResponseRecorder(w)
+
+		next.ServeHTTP(recorder, r)
+
+		entry := &Entry{
+			Time:       start,
+			Action:     r.Method + " " + routeTemplate(r),
+			Path:       r.URL.Path,
+			Params:     mux.Vars(r),
+			Query:      r.URL.RawQuery,
+			Body:       body,
+			Form:       formFields(r),
+			Status:     recorder.Status(),
+			Duration:   time.Since(start),
+			RemoteAddr: r.RemoteAddr,
+		}
+		if principal, ok := auth.FromContext(r.Context()); ok {
+			entry.Subject = principal.Subject
+			entry.Role = principal.Role.String()
+		}
+
+		if err := l.Record(entry); err != nil {
+			log.Printf("Warning: Failed to record audit entry for %s %s: %v", r.Method, r.URL.Path, err)
+		}
+	})
+}
+
+// routeTemplate returns the matched route's path template, or the request
+// path when there is none.
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return routeVariablePattern.ReplaceAllString(template, "{$1}")
+		}
+	}
+	return r.URL.Path
+}
+
+// captureBody returns a small JSON request body with its secrets redacted,
+// leaving the body intact for the handler. Other bodies, such as uploads,
+// are not captured.
+func captureBody(r *http.Request) json.RawMessage {
+	if r.Body == nil {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+	if err != nil || len(data) > maxBodyBytes {
+		return nil
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+	redacted, err := json.Marshal(redact(value))
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+// formFields returns the fields of a multipart form the handler parsed, such
+// as an upload's object name, with file fields giving the uploaded file's
+// name.
+func formFields(r *http.Request) map[string]string {
+	if r.MultipartForm == nil {
+		return nil
+	}
+
+	fields := make(map[string]string)
+	for key, values := range r.MultipartForm.Value {
+		if len(values) == 0 {
+			continue
+		}
+		if isSensitive(key) {
+			fields[key] = redactedValue
+		} else {
+			fields[key] = values[0]
+		}
+	}
+	for key, files := range r.MultipartForm.File {
+		if len(files) > 0 {
+			fields[key] = files[0].Filename
+		}
+	}
+	return fields
+}
+
+// redact replaces the values of sensitive fields, at any depth.
+func redact(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if isSensitive(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redact(field)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redact(item)
+		}
+	}
+	return value
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+// Package auth validates OIDC bearer tokens and enforces role-based access
+// to the API. Each caller gets one role; a higher role can do everything a
+// lower one can:
+//
+//   - viewer browses and downloads files, data and job status
+//   - editor also uploads, runs jobs and exports
+//   - admin also changes configuration and buckets, and deletes
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"bronze-backend/config"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// Role is a caller's access level. Roles are ordered, so a role allows
+// everything the roles below it allow.
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleEditor
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{
+	RoleNone:   "none",
+	RoleViewer: "viewer",
+	RoleEditor: "editor",
+	RoleAdmin:  "admin",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// MarshalText writes the role by name in JSON.
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// ParseRole returns the role named name, case-insensitively.
+func ParseRole(name string) (Role, bool) {
+	for role, roleName := range roleNames {
+		if strings.EqualFold(name, roleName) {
+			return role, true
+		}
+	}
+	return RoleNone, false
+}
+
+// ErrInvalidToken is returned for a token that is malformed, expired, signed
+// by an unknown key or issued for another audience.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Principal is the caller of a request, as established by its token.
+type Principal struct {
+	Subject string `json:"subject"`
+	Email   string `json:"email,omitempty"`
+	Role    Role   `json:"role"`
+	Tenant  string `json:"tenant,omitempty"` // From the tenant claim; empty if it has none
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns ctx carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the caller of the request ctx belongs to.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// Subject returns the caller's subject, or "" when the request was not
+// authenticated, such as when auth is disabled.
+func Subject(ctx context.Context) string {
+	if p, ok := FromContext(ctx); ok {
+		return p.Subject
+	}
+	return ""
+}
+
+// Authenticator verifies bearer tokens against the issuer's signing keys and
+// maps their claims to a Principal. A nil Authenticator means auth is
+// disabled and lets every request through.
+type Authenticator struct {
+	verifier    *oidc.IDTokenVerifier
+	rolesClaim  []string
+	tenantClaim []string
+	defaultRole Role
+}
+
+// New returns an Authenticator for cfg, or nil when auth is disabled. The
+// signing keys are found through OIDC discovery on the issuer unless
+// cfg.JWKSURL is set; ctx bounds discovery and later key refreshes.
+func New(ctx context.Context, cfg config.AuthConfig) (*Authenticator, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.IssuerURL == "" {
+		return nil, errors.New("OIDC_ISSUER_URL is required when auth is enabled")
+	}
+
+	verifierConfig := &oidc.Config{
+		ClientID:          cfg.Audience,
+		SkipClientIDCheck: cfg.Audience == "",
+	}
+
+	var verifier *oidc.IDTokenVerifier
+	if cfg.JWKSURL != "" {
+		verifier = oidc.NewVerifier(cfg.IssuerURL, oidc.NewRemoteKeySet(ctx, cfg.JWKSURL), verifierConfig)
+	} else {
+		provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+		}
+		verifier = provider.Verifier(verifierConfig)
+	}
+
+	a, err := newAuthenticator(verifier, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Auth enabled: issuer %s, roles claim %s, default role %s",
+		cfg.IssuerURL, cfg.RolesClaim, a.defaultRole)
+	return a, nil
+}
+
+func newAuthenticator(verifier *oidc.IDTokenVerifier, cfg config.AuthConfig) (*Authenticator, error) {
+	defaultRole := RoleNone
+	if cfg.DefaultRole != "" {
+		role, ok := ParseRole(cfg.DefaultRole)
+		if !ok {
+			return nil, fmt.Errorf("unknown default role %q", cfg.DefaultRole)
+		}
+		defaultRole = role
+	}
+
+	rolesClaim := cfg.RolesClaim
+	if rolesClaim == "" {
+		rolesClaim = "roles"
+	}
+
+	tenantClaim := cfg.TenantClaim
+	if tenantClaim == "" {
+		tenantClaim = "tenant"
+	}
+
+	return &Authenticator{
+		verifier:    verifier,
+		rolesClaim:  strings.Split(rolesClaim, "."),
+		tenantClaim: strings.Split(tenantClaim, "."),
+		defaultRole: defaultRole,
+	}, nil
+}
+
+// Authenticate verifies the raw bearer token and returns its caller.
+func (a *Authenticator) Authenticate(ctx context.Context, rawToken string) (*Principal, error) {
+	token, err := a.verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var claims map[string]any
+	if err := token.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	email, _ := claims["email"].(string)
+	tenant, _ := claim(claims, a.tenantClaim).(string)
+	return &Principal{
+		Subject: token.Subject,
+		Email:   email,
+		Role:    a.role(claims),
+		Tenant:  tenant,
+	}, nil
+}
+
+// claim returns the claim at path, or nil if there is none.
+func claim(claims map[string]any, path []string) any {
+	var value any = claims
+	for _, key := range path {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// role returns the highest known role listed in the roles claim, or the
+// default role when it lists none. The claim may be a list of names or a
+// space-separated string.
+func (a *Authenticator) role(claims map[string]any) Role {
+	var names []string
+	switch v := claim(claims, a.rolesClaim).(type) {
+	case string:
+		names = strings.Fields(v)
+	case []any:
+		for _, item := range v {
+			if name, ok := item.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	highest := RoleNone
+	for _, name := range names {
+		if role, ok := ParseRole(name); ok && role > highest {
+			highest = role
+		}
+	}
+	if highest == RoleNone {
+		return a.defaultRole
+	}
+	return highest
+}
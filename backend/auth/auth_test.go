@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bronze-backend/config"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const testIssuer = "https://issuer.example.com"
+
+type testIssuerKeys struct {
+	signer jose.Signer
+	key    *ecdsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuerKeys {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testIssuerKeys{signer: signer, key: key}
+}
+
+func (k *testIssuerKeys) authenticator(t *testing.T, cfg config.AuthConfig) *Authenticator {
+	t.Helper()
+	keySet := &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{&k.key.PublicKey}}
+	verifier := oidc.NewVerifier(testIssuer, keySet, &oidc.Config{
+		ClientID:             "bronze",
+		SupportedSigningAlgs: []string{oidc.ES256},
+	})
+	a, err := newAuthenticator(verifier, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func (k *testIssuerKeys) token(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	standard := map[string]any{
+		"iss": testIssuer,
+		"aud": "bronze",
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	for key, value := range claims {
+		standard[key] = value
+	}
+	raw, err := jwt.Signed(k.signer).Claims(standard).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestAuthenticateRoles(t *testing.T) {
+	issuer := newTestIssuer(t)
+	a := issuer.authenticator(t, config.AuthConfig{RolesClaim: "roles", DefaultRole: "viewer"})
+	nested := issuer.authenticator(t, config.AuthConfig{RolesClaim: "realm_access.roles"})
+
+	tests := []struct {
+		name   string
+		auth   *Authenticator
+		claims map[string]any
+		want   Role
+	}{
+		{"highest listed role", a, map[string]any{"roles": []any{"viewer", "admin", "other"}}, RoleAdmin},
+		{"space-separated", a, map[string]any{"roles": "editor viewer"}, RoleEditor},
+		{"no known role gets default", a, map[string]any{"roles": []any{"other"}}, RoleViewer},
+		{"missing claim gets default", a, nil, RoleViewer},
+		{"nested claim", nested, map[string]any{"realm_access": map[string]any{"roles": []any{"Editor"}}}, RoleEditor},
+		{"no default role", nested, map[string]any{"roles": []any{"admin"}}, RoleNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := tt.auth.Authenticate(t.Context(), issuer.token(t, tt.claims))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal.Subject != "user-1" {
+				t.Errorf("subject = %q, want user-1", principal.Subject)
+			}
+			if principal.Role != tt.want {
+				t.Errorf("role = %s, want %s", principal.Role, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateTenant(t *testing.T) {
+	issuer := newTestIssuer(t)
+	a := issuer.authenticator(t, config.AuthConfig{DefaultRole: "viewer"})
+	nested := issuer.authenticator(t, config.AuthConfig{DefaultRole: "viewer", TenantClaim: "org.team"})
+
+	for _, tt := range []struct {
+		auth   *Authenticator
+		claims map[string]any
+		want   string
+	}{
+		{a, map[string]any{"tenant": "team-a"}, "team-a"},
+		{a, nil, ""},
+		{nested, map[string]any{"org": map[string]any{"team": "team-b"}}, "team-b"},
+		{nested, map[string]any{"tenant": "team-a"}, ""},
+	} {
+		principal, err := tt.auth.Authenticate(t.Context(), issuer.token(t, tt.claims))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if principal.Tenant != tt.want {
+			t.Errorf("claims %v: tenant = %q, want %q", tt.claims, principal.Tenant, tt.want)
+		}
+	}
+}
+
+func TestAuthenticateRejectsBadTokens(t *testing.T) {
+	issuer := newTestIssuer(t)
+	a := issuer.authenticator(t, config.AuthConfig{DefaultRole: "viewer"})
+
+	tests := map[string]string{
+		"expired":        issuer.token(t, map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}),
+		"wrong audience": issuer.token(t, map[string]any{"aud": "other"}),
+		"wrong issuer":   issuer.token(t, map[string]any{"iss": "https://evil.example.com"}),
+		"unknown key":    newTestIssuer(t).token(t, nil),
+		"malformed":      "not-a-token",
+	}
+	for name, token := range tests {
+		if _, err := a.Authenticate(t.Context(), token); err == nil {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+}
+
+func TestNewAuthenticatorRejectsUnknownDefaultRole(t *testing.T) {
+	if _, err := newAuthenticator(nil, config.AuthConfig{DefaultRole: "superuser"}); err == nil {
+		t.Error("unknown default role accepted")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	issuer := newTestIssuer(t)
+	a := issuer.authenticator(t, config.AuthConfig{})
+
+	var subject string
+	handler := a.Require(RoleEditor)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject = Subject(r.Context())
+	}))
+
+	serve := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/jobs", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve(""); code != http.StatusUnauthorized {
+		t.Errorf("no token: status %d, want 401", code)
+	}
+	if code := serve("not-a-token"); code != http.StatusUnauthorized {
+		t.Errorf("bad token: status %d, want 401", code)
+	}
+	if code := serve(issuer.token(t, map[string]any{"roles": []any{"viewer"}})); code != http.StatusForbidden {
+		t.Errorf("viewer: status %d, want 403", code)
+	}
+	if code := serve(issuer.token(t, map[string]any{"roles": []any{"admin"}})); code != http.StatusOK {
+		t.Errorf("admin: status %d, want 200", code)
+	}
+	if subject != "user-1" {
+		t.Errorf("subject in context = %q, want user-1", subject)
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	issuer := newTestIssuer(t)
+	a := issuer.authenticator(t, config.AuthConfig{})
+
+	var identified string
+	handler := a.Identify(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identified = Subject(r.Context())
+		a.Require(RoleViewer)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(w, r)
+	}))
+
+	serve := func(token string) int {
+		identified = ""
+		req := httptest.NewRequest(http.MethodGet, "/api/files", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve(issuer.token(t, map[string]any{"roles": []any{"viewer"}})); code != http.StatusOK || identified != "user-1" {
+		t.Errorf("valid token: status %d, identified %q; want 200, user-1", code, identified)
+	}
+	// Passed on unidentified, for Require to refuse
+	if code := serve("not-a-token"); code != http.StatusUnauthorized || identified != "" {
+		t.Errorf("bad token: status %d, identified %q; want 401, nobody", code, identified)
+	}
+}
+
+func TestRequireDisabled(t *testing.T) {
+	var a *Authenticator
+	called := false
+	handler := a.Require(RoleAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/api/files/x", nil))
+	if !called {
+		t.Error("request refused with auth disabled")
+	}
+}
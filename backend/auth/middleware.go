@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"bronze-backend/httputil"
+)
+
+// Identify stores the caller of each request with a valid bearer token in
+// the request context, so middleware running before Require, such as rate
+// limiting, can tell callers apart by who they are. Requests without a
+// valid token pass on unauthenticated; Require refuses them.
+func (a *Authenticator) Identify(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok && rawToken != "" {
+			if principal, err := a.Authenticate(r.Context(), rawToken); err == nil {
+				r = r.WithContext(WithPrincipal(r.Context(), principal))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Require authenticates each request by its bearer token and refuses it
+// unless the caller has at least role. The caller is stored in the request
+// context for handlers to read with FromContext; one Identify already
+// stored is not verified again. With a nil Authenticator every request
+// passes unauthenticated.
+func (a *Authenticator) Require(role Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if a == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, identified := FromContext(r.Context())
+			if !identified {
+				rawToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+				if !ok || rawToken == "" {
+					w.Header().Set("WWW-Authenticate", `Bearer`)
+					httputil.Error(w, "Bearer token required", http.StatusUnauthorized)
+					return
+				}
+
+				var err error
+				principal, err = a.Authenticate(r.Context(), rawToken)
+				if err != nil {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					httputil.WriteError(w, "Invalid bearer token", http.StatusUnauthorized, err)
+					return
+				}
+				r = r.WithContext(WithPrincipal(r.Context(), principal))
+			}
+
+			if principal.Role < role {
+				httputil.Error(w, fmt.Sprintf("Role %s required", role), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
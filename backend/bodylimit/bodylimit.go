@@ -0,0 +1,126 @@
+// Package bodylimit caps the size of request bodies, so a client cannot tie
+// up memory or disk with an oversized upload or JSON document. Uploads and
+// other bodies have separate limits, and single routes can override them.
+package bodylimit
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"sync"
+
+	"bronze-backend/config"
+	"bronze-backend/httputil"
+
+	"github.com/gorilla/mux"
+)
+
+// routeVariablePattern matches a route variable with its pattern, such as
+// {filename:.+}, so endpoint limits can name it {filename}.
+var routeVariablePattern = regexp.MustCompile(`\{([^}:]+):[^}]*\}`)
+
+// limits are the parsed limits; 0 is no limit.
+type limits struct {
+	upload    int64
+	json      int64
+	endpoints map[string]int64
+}
+
+// Limiter enforces the body size limits.
+type Limiter struct {
+	mu     sync.RWMutex
+	limits limits
+}
+
+// New returns a Limiter for cfg.
+func New(cfg config.BodyLimitConfig) (*Limiter, error) {
+	l := &Limiter{}
+	if err := l.SetConfig(cfg); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// SetConfig changes the limits. Requests already being read keep the limit
+// they started with.
+func (l *Limiter) SetConfig(cfg config.BodyLimitConfig) error {
+	var parsed limits
+	var err error
+	if parsed.upload, err = config.ParseByteSize(cfg.MaxUploadSize); err != nil {
+		return fmt.Errorf("invalid upload size limit: %w", err)
+	}
+	if parsed.json, err = config.ParseByteSize(cfg.MaxJSONBodySize); err != nil {
+		return fmt.Errorf("invalid JSON body size limit: %w", err)
+	}
+	if parsed.endpoints, err = cfg.EndpointLimits(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits = parsed
+	log.Printf("Body size limits: uploads %s, other bodies %s, %d endpoint overrides",
+		describe(parsed.upload), describe(parsed.json), len(parsed.endpoints))
+	return nil
+}
+
+func describe(limit int64) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d bytes", limit)
+}
+
+// limit returns the body size limit of the request: its route's override, or
+// the upload or JSON limit depending on its content type.
+func (l *Limiter) limit(r *http.Request) int64 {
+	key := ""
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			key = r.Method + " " + routeVariablePattern.ReplaceAllString(template, "{$1}")
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return l.Limit(key, mediaType == "multipart/form-data")
+}
+
+// Limit returns the body size limit of route, written "METHOD /path" as in
+// BODY_LIMIT_ENDPOINTS, for an upload or another body; 0 is no limit. A nil
+// Limiter has no limits.
+func (l *Limiter) Limit(route string, upload bool) int64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if limit, ok := l.limits.endpoints[route]; ok {
+		return limit
+	}
+	if upload {
+		return l.limits.upload
+	}
+	return l.limits.json
+}
+
+// Middleware rejects requests whose declared length is over their limit with
+// a 413, and stops reading bodies without one at the limit; the handler's
+// read then fails and httputil.WriteError answers 413.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := l.limit(r)
+		if limit > 0 && r.Body != nil && r.Body != http.NoBody {
+			if r.ContentLength > limit {
+				httputil.Error(w, httputil.TooLargeMessage(limit), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
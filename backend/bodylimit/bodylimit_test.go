@@ -0,0 +1,127 @@
+package bodylimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bronze-backend/config"
+	"bronze-backend/httputil"
+
+	"github.com/gorilla/mux"
+)
+
+func newTestRouter(t *testing.T, cfg config.BodyLimitConfig) http.Handler {
+	l, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	router.Use(l.Middleware)
+	read := func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			httputil.WriteError(w, "Failed to read body", http.StatusBadRequest, err)
+		}
+	}
+	router.HandleFunc("/api/files/upload", read).Methods("POST")
+	router.HandleFunc("/api/data/{filename:.+}", read).Methods("POST")
+	router.HandleFunc("/api/config", read).Methods("PUT")
+	return router
+}
+
+func send(h http.Handler, method, path, contentType string, size int, chunked bool) *httptest.ResponseRecorder {
+	var body io.Reader = bytes.NewReader(make([]byte, size))
+	if chunked {
+		// Hides the length, as a chunked request does
+		body = io.MultiReader(body)
+	}
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLimits(t *testing.T) {
+	h := newTestRouter(t, config.BodyLimitConfig{
+		MaxUploadSize:   "100B",
+		MaxJSONBodySize: "10B",
+		Endpoints:       "POST /api/data/{filename}=50B",
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		size        int
+		want        int
+	}{
+		{"json under limit", "PUT", "/api/config", "application/json", 10, http.StatusOK},
+		{"json over limit", "PUT", "/api/config", "application/json", 11, http.StatusRequestEntityTooLarge},
+		{"upload under limit", "POST", "/api/files/upload", "multipart/form-data; boundary=x", 100, http.StatusOK},
+		{"upload over limit", "POST", "/api/files/upload", "multipart/form-data; boundary=x", 101, http.StatusRequestEntityTooLarge},
+		{"endpoint override", "POST", "/api/data/a/b.csv", "application/json", 50, http.StatusOK},
+		{"over endpoint override", "POST", "/api/data/a/b.csv", "application/json", 51, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		for _, chunked := range []bool{false, true} {
+			rec := send(h, tt.method, tt.path, tt.contentType, tt.size, chunked)
+			if rec.Code != tt.want {
+				t.Errorf("%s (chunked %v): status %d, want %d", tt.name, chunked, rec.Code, tt.want)
+			}
+		}
+	}
+
+	rec := send(h, "PUT", "/api/config", "application/json", 11, true)
+	var response httputil.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(response.Message, "limit is 10 bytes") {
+		t.Errorf("message = %q, want the limit", response.Message)
+	}
+}
+
+func TestZeroIsUnlimited(t *testing.T) {
+	h := newTestRouter(t, config.BodyLimitConfig{MaxUploadSize: "0", MaxJSONBodySize: "0"})
+	if rec := send(h, "PUT", "/api/config", "application/json", 1<<20, true); rec.Code != http.StatusOK {
+		t.Errorf("status %d, want 200", rec.Code)
+	}
+}
+
+func TestSetConfig(t *testing.T) {
+	l, err := New(config.BodyLimitConfig{MaxJSONBodySize: "10B"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetConfig(config.BodyLimitConfig{Endpoints: "POST /api/files"}); err == nil {
+		t.Error("endpoint without a size: want error")
+	}
+
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if err := l.SetConfig(config.BodyLimitConfig{MaxJSONBodySize: "20B"}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := send(h, "PUT", "/api/config", "application/json", 20, false); rec.Code != http.StatusOK {
+		t.Errorf("after raising the limit: status %d, want 200", rec.Code)
+	}
+	if limit := l.Limit("PUT /api/config", false); limit != 20 {
+		t.Errorf("Limit() = %d, want 20", limit)
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if rec := send(h, "PUT", "/api/config", "application/json", 1<<20, false); rec.Code != http.StatusOK {
+		t.Errorf("status %d, want 200", rec.Code)
+	}
+	if l.Limit("POST /api/files/upload", true) != 0 {
+		t.Error("a nil Limiter has a limit")
+	}
+}
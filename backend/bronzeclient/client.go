@@ -0,0 +1,253 @@
+// Package bronzeclient is a Go client for the Bronze REST API. Its request
+// and response types are the ones the handlers use, so a change to a
+// response breaks the build of a client rather than its decoding at run
+// time.
+//
+//	client, err := bronzeclient.New(bronzeclient.Config{BaseURL: "http://localhost:8060", Token: token})
+//	job, err := client.CreateJob(ctx, bronzeclient.CreateJobRequest{Type: "extract", ObjectName: "uploads/a.zip"})
+//	job, err = client.WaitForJob(ctx, job.ID, time.Second)
+package bronzeclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"bronze-backend/httputil"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultRetryWait  = 500 * time.Millisecond
+	maxRetryWait      = 30 * time.Second
+)
+
+// Config configures a Client.
+type Config struct {
+	BaseURL string // e.g. http://localhost:8060
+	Token   string // Bearer token; empty when the server has auth disabled
+
+	// HTTPClient sends the requests; http.DefaultClient if nil. Streams
+	// stay open as long as the server sends, so it should not set a
+	// Timeout: bound calls with their context instead.
+	HTTPClient *http.Client
+
+	// MaxRetries is how many times a failed request is retried; 0 means
+	// the default of 3 and a negative value disables retries.
+	MaxRetries int
+	// RetryWait is the wait before the first retry, doubled for each
+	// retry after it unless the server sends Retry-After; 500ms if 0.
+	RetryWait time.Duration
+}
+
+// Client calls the Bronze API. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	token      string
+	httpClient *http.Client
+	maxRetries int
+	retryWait  time.Duration
+}
+
+func New(cfg Config) (*Client, error) {
+	baseURL, err := url.Parse(strings.TrimSuffix(cfg.BaseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: the scheme must be http or https", cfg.BaseURL)
+	}
+
+	c := &Client{
+		baseURL:    baseURL,
+		token:      cfg.Token,
+		httpClient: cfg.HTTPClient,
+		maxRetries: cfg.MaxRetries,
+		retryWait:  cfg.RetryWait,
+	}
+	if c.httpClient == nil {
+		c.httpClient = http.DefaultClient
+	}
+	if c.maxRetries == 0 {
+		c.maxRetries = defaultMaxRetries
+	} else if c.maxRetries < 0 {
+		c.maxRetries = 0
+	}
+	if c.retryWait <= 0 {
+		c.retryWait = defaultRetryWait
+	}
+	return c, nil
+}
+
+// APIError is a response with an error status.
+type APIError struct {
+	StatusCode int
+	Code       httputil.Code // The server's error code, if it sent one
+	Message    string        // The server's message, or the status text
+
+	body []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("bronze: %d %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 response.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// request describes an API call.
+type request struct {
+	method string
+	path   string // Below the base URL, e.g. /api/jobs
+	query  url.Values
+	body   any // Encoded as JSON
+
+	// raw is sent as is instead of body, with contentType. It can only be
+	// read once, so the request is not retried.
+	raw         io.Reader
+	contentType string
+}
+
+// send sends req, retrying while the server is rate limiting or briefly
+// unavailable, and returns the response of a 2xx status. Other statuses
+// are returned as an *APIError.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	u := c.baseURL.JoinPath(req.path)
+	u.RawQuery = req.query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		contentType := ""
+		switch {
+		case req.raw != nil:
+			reader, contentType = req.raw, req.contentType
+		case body != nil:
+			reader, contentType = bytes.NewReader(body), "application/json"
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, req.method, u.String(), reader)
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			httpReq.Header.Set("Content-Type", contentType)
+		}
+		if c.token != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+c.token)
+		}
+
+		resp, err := c.httpClient.Do(httpReq)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+
+		var wait time.Duration
+		retry := req.raw == nil && attempt < c.maxRetries && ctx.Err() == nil
+		if err != nil {
+			// A request that never got a response may still have been
+			// processed, so only repeat those that are safe to repeat
+			retry = retry && idempotent(req.method)
+		} else {
+			retry = retry && retryable(req.method, resp.StatusCode)
+			wait = retryAfter(resp)
+			apiErr := readError(resp)
+			resp.Body.Close()
+			err = apiErr
+		}
+		if !retry {
+			return nil, err
+		}
+
+		if wait == 0 {
+			wait = min(c.retryWait<<attempt, maxRetryWait)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// do sends req and decodes the JSON response into out, unless out is nil.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether a response status is worth retrying. 429 and
+// 503 are sent before a request is handled, so any request may be repeated.
+func retryable(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent(method)
+	}
+	return false
+}
+
+// retryAfter returns the wait the server asked for, or 0.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return min(time.Duration(seconds)*time.Second, maxRetryWait)
+}
+
+// readError reads the message of an error response.
+func readError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	apiErr.body = data
+	var body httputil.ErrorResponse
+	if json.Unmarshal(data, &body) == nil {
+		apiErr.Code = body.Code
+		if body.Message != "" {
+			apiErr.Message = body.Message
+		} else if body.Error != "" {
+			apiErr.Message = body.Error
+		}
+	}
+	return apiErr
+}
@@ -0,0 +1,218 @@
+package bronzeclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bronze-backend/httputil"
+	"bronze-backend/jobs"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := New(Config{BaseURL: server.URL, Token: "secret", RetryWait: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestNewRejectsInvalidURL(t *testing.T) {
+	for _, baseURL := range []string{"", "localhost:8060", "ftp://host"} {
+		if _, err := New(Config{BaseURL: baseURL}); err == nil {
+			t.Errorf("New(%q) succeeded, want an error", baseURL)
+		}
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var attempts atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		var req CreateJobRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ObjectName != "a.zip" {
+			t.Errorf("attempt %d sent object %q, want a.zip", attempts.Load(), req.ObjectName)
+		}
+
+		if attempts.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			httputil.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		httputil.WriteJSON(w, http.StatusCreated, jobs.JobResponse{Success: true, Job: &Job{ID: "1", ObjectName: req.ObjectName}})
+	})
+
+	job, err := client.CreateJob(context.Background(), CreateJobRequest{Type: "extract", ObjectName: "a.zip"})
+	if err != nil || job.ID != "1" {
+		t.Fatalf("CreateJob() = %+v, %v", job, err)
+	}
+	if attempts.Load() != 3 {
+		t.Errorf("sent %d attempts, want 3", attempts.Load())
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var attempts atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		switch r.URL.Path {
+		case "/api/jobs/missing":
+			httputil.WriteCode(w, httputil.CodeJobNotFound, "Job not found", nil)
+		case "/api/jobs":
+			// A POST that failed on the server may have had effects
+			httputil.Error(w, "Failed to create job", http.StatusBadGateway)
+		default:
+			httputil.Error(w, "Queue unavailable", http.StatusServiceUnavailable)
+		}
+	})
+
+	_, err := client.GetJob(context.Background(), "missing")
+	if !IsNotFound(err) || !strings.Contains(err.Error(), "Job not found") {
+		t.Errorf("GetJob() error = %v, want a 404 with the message", err)
+	}
+	if apiErr := (*APIError)(nil); !errors.As(err, &apiErr) || apiErr.Code != httputil.CodeJobNotFound {
+		t.Errorf("GetJob() error = %#v, want code %s", err, httputil.CodeJobNotFound)
+	}
+
+	attempts.Store(0)
+	if _, err := client.CreateJob(context.Background(), CreateJobRequest{}); err == nil || attempts.Load() != 1 {
+		t.Errorf("POST with 502: %d attempts, error %v; want 1 attempt and an error", attempts.Load(), err)
+	}
+
+	attempts.Store(0)
+	var apiErr *APIError
+	if _, err := client.JobStats(context.Background()); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("JobStats() error = %v, want a 503", err)
+	}
+	if attempts.Load() != defaultMaxRetries+1 {
+		t.Errorf("503: sent %d attempts, want %d", attempts.Load(), defaultMaxRetries+1)
+	}
+}
+
+func TestExportFailure(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		httputil.WriteJSON(w, http.StatusConflict, ExportResponse{
+			Code:      httputil.CodeSchemaMismatch,
+			Message:   "Schema mismatch detected in strict mode",
+			TableName: "sales",
+		})
+	})
+
+	resp, err := client.Export(context.Background(), ExportRequest{TableName: "sales"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != httputil.CodeSchemaMismatch {
+		t.Errorf("Export() error = %v, want code %s", err, httputil.CodeSchemaMismatch)
+	}
+	if resp == nil || resp.TableName != "sales" {
+		t.Errorf("Export() response = %+v, want the failed export", resp)
+	}
+}
+
+func TestListJobsQuery(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.RawQuery; got != "limit=10&offset=20&prefix=uploads%2F&status=failed" {
+			t.Errorf("query = %s", got)
+		}
+		httputil.WriteJSON(w, http.StatusOK, jobs.JobsListResponse{Jobs: []*Job{{ID: "1"}}, Total: 21})
+	})
+
+	list, err := client.ListJobs(context.Background(), JobListOptions{Status: JobStatusFailed, Prefix: "uploads/", Limit: 10, Offset: 20})
+	if err != nil || len(list.Jobs) != 1 || list.Total != 21 {
+		t.Errorf("ListJobs() = %+v, %v", list, err)
+	}
+}
+
+func TestWaitForJob(t *testing.T) {
+	var polls atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		status := JobStatusProcessing
+		if polls.Add(1) == 3 {
+			status = JobStatusCompleted
+		}
+		httputil.WriteJSON(w, http.StatusOK, jobs.JobResponse{Job: &Job{ID: "1", Status: status}})
+	})
+
+	job, err := client.WaitForJob(context.Background(), "1", time.Millisecond)
+	if err != nil || job.Status != JobStatusCompleted || polls.Load() != 3 {
+		t.Errorf("WaitForJob() = %+v, %v after %d polls", job, err, polls.Load())
+	}
+}
+
+func TestUpload(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(file)
+		if r.FormValue("object_name") != "uploads/a.csv" || header.Filename != "a.csv" || string(content) != "a,b\n" {
+			t.Errorf("uploaded %s as %s (%s)", content, r.FormValue("object_name"), header.Filename)
+		}
+		httputil.WriteJSON(w, http.StatusCreated, UploadResult{Success: true, ObjectName: "uploads/a.csv", Size: int64(len(content))})
+	})
+
+	result, err := client.Upload(context.Background(), "uploads/a.csv", strings.NewReader("a,b\n"))
+	if err != nil || result.Size != 4 {
+		t.Errorf("Upload() = %+v, %v", result, err)
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("rule") != "csv" {
+			t.Errorf("rule = %q, want csv", r.URL.Query().Get("rule"))
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": connected\n\n")
+		for _, key := range []string{"a.csv", "b.csv", "c.csv"} {
+			data, _ := json.Marshal(FileEvent{ID: key, Key: key})
+			fmt.Fprintf(w, "id: %s\nevent: file_event\ndata: %s\n\n", key, data)
+		}
+	})
+
+	var keys []string
+	err := client.StreamEvents(context.Background(), "csv", func(event *FileEvent) error {
+		keys = append(keys, event.Key)
+		if len(keys) == 2 {
+			return ErrStopStream
+		}
+		return nil
+	})
+	if err != nil || strings.Join(keys, ",") != "a.csv,b.csv" {
+		t.Errorf("StreamEvents() read %v, %v; want a.csv and b.csv", keys, err)
+	}
+}
+
+func TestBrowse(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "event: connected\ndata: {}\n\n")
+		fmt.Fprint(w, "event: item\ndata: {\"name\":\"a.csv\"}\n\n")
+		fmt.Fprint(w, "event: keepalive\ndata: {}\n\n")
+		fmt.Fprint(w, "event: complete\ndata: {}\n\n")
+		fmt.Fprint(w, "event: item\ndata: {\"name\":\"late\"}\n\n")
+	})
+
+	var types []string
+	err := client.Browse(context.Background(), BrowseRequest{Folders: []FolderRequest{{Path: "data/"}}}, func(event Event) error {
+		types = append(types, event.Type)
+		return nil
+	})
+	if err != nil || strings.Join(types, ",") != "item,complete" {
+		t.Errorf("Browse() read %v, %v; want item and complete", types, err)
+	}
+}
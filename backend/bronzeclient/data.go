@@ -0,0 +1,42 @@
+package bronzeclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// BrowseData reads rows from a CSV or Excel file.
+func (c *Client) BrowseData(ctx context.Context, req DataBrowseRequest) (*DataBrowseResponse, error) {
+	var resp DataBrowseResponse
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/data/browse", body: req}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Export exports req.Files to a Nessie table. A failed export is returned
+// with an *APIError, along with the response describing which rows failed.
+func (c *Client) Export(ctx context.Context, req ExportRequest) (*ExportResponse, error) {
+	var resp ExportResponse
+	err := c.do(ctx, request{method: http.MethodPost, path: "/api/data/export-multiple", body: req}, &resp)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && isExportResponse(apiErr.body) {
+		json.Unmarshal(apiErr.body, &resp)
+		return &resp, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// isExportResponse reports whether an error body is an ExportResponse
+// rather than the plain error envelope, which has no table name.
+func isExportResponse(body []byte) bool {
+	var probe struct {
+		TableName *string `json:"table_name"`
+	}
+	return json.Unmarshal(body, &probe) == nil && probe.TableName != nil
+}
@@ -0,0 +1,148 @@
+package bronzeclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"bronze-backend/files"
+)
+
+// ListFiles lists the files below prefix, at most limit of them if limit
+// is positive.
+func (c *Client) ListFiles(ctx context.Context, prefix string, limit int) ([]FileInfo, error) {
+	query := url.Values{"prefix": {prefix}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var resp files.FileListResponse
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/files", query: query}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Files, nil
+}
+
+func (c *Client) GetFileInfo(ctx context.Context, objectName string) (*FileDetail, error) {
+	var resp files.FileInfoResponse
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/files/" + objectName + "/info"}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.File, nil
+}
+
+// Upload stores r as objectName. The body is streamed, not buffered, so an
+// upload is never retried.
+func (c *Client) Upload(ctx context.Context, objectName string, r io.Reader) (*UploadResult, error) {
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		err := form.WriteField("object_name", objectName)
+		if err == nil {
+			var part io.Writer
+			if part, err = form.CreateFormFile("file", path.Base(objectName)); err == nil {
+				_, err = io.Copy(part, r)
+			}
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	var resp UploadResult
+	req := request{method: http.MethodPost, path: "/api/files/upload", raw: pr, contentType: form.FormDataContentType()}
+	err := c.do(ctx, req, &resp)
+	pr.Close() // Stops the writer if the request failed before reading it all
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Download returns the content of objectName. The caller must close it.
+func (c *Client) Download(ctx context.Context, objectName string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: "/api/files/" + objectName})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// PresignedURL returns a URL to download objectName from MinIO directly
+// for expiry, or the server's default expiry if 0.
+func (c *Client) PresignedURL(ctx context.Context, objectName string, expiry time.Duration) (string, error) {
+	query := url.Values{}
+	if expiry > 0 {
+		query.Set("expiry", expiry.String())
+	}
+	var resp struct {
+		URL string `json:"url"`
+	}
+	req := request{method: http.MethodGet, path: "/api/files/" + objectName + "/presigned", query: query}
+	if err := c.do(ctx, req, &resp); err != nil {
+		return "", err
+	}
+	return resp.URL, nil
+}
+
+func (c *Client) DeleteFile(ctx context.Context, objectName string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/files/" + objectName}, nil)
+}
+
+// DeleteFilesByPrefix deletes every file below prefix and returns their
+// names.
+func (c *Client) DeleteFilesByPrefix(ctx context.Context, prefix string) ([]string, error) {
+	if prefix == "" {
+		return nil, fmt.Errorf("refusing to delete with an empty prefix")
+	}
+	var resp DeleteResult
+	req := request{method: http.MethodDelete, path: "/api/files", query: url.Values{"prefix": {prefix}}}
+	if err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Deleted, nil
+}
+
+func (c *Client) CopyFile(ctx context.Context, source, dest string) (*CopyResult, error) {
+	var resp CopyResult
+	req := request{
+		method: http.MethodPost,
+		path:   "/api/files/copy",
+		body:   files.CopyFileRequest{SourceObjectName: source, DestObjectName: dest},
+	}
+	if err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Browse lists folders, calling fn with each event of the stream as it
+// arrives: folder_start, item, folder_complete and error for each folder,
+// then complete. It returns when the stream ends or fn returns an error;
+// return ErrStopStream from fn to stop early without one.
+func (c *Client) Browse(ctx context.Context, req BrowseRequest, fn func(Event) error) error {
+	resp, err := c.send(ctx, request{method: http.MethodPost, path: "/api/files/browse", body: req})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return readEvents(resp.Body, func(event Event) error {
+		if event.Type == "connected" || event.Type == "keepalive" {
+			return nil
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+		if event.Type == "complete" {
+			return ErrStopStream
+		}
+		return nil
+	})
+}
@@ -0,0 +1,158 @@
+package bronzeclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"bronze-backend/jobs"
+)
+
+// JobListOptions filters and pages ListJobs, as the GET /api/jobs query
+// parameters do. Zero fields are not sent.
+type JobListOptions struct {
+	Type          string
+	Status        JobStatus
+	Prefix        string // Object name prefix
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Sort          string // "created_at" or "duration"
+	Order         string // "asc" or "desc"
+	Limit         int
+	Offset        int
+}
+
+func (o JobListOptions) values() url.Values {
+	query := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	set("type", o.Type)
+	set("status", string(o.Status))
+	set("prefix", o.Prefix)
+	if !o.CreatedAfter.IsZero() {
+		set("created_after", o.CreatedAfter.Format(time.RFC3339))
+	}
+	if !o.CreatedBefore.IsZero() {
+		set("created_before", o.CreatedBefore.Format(time.RFC3339))
+	}
+	set("sort", o.Sort)
+	set("order", o.Order)
+	if o.Limit > 0 {
+		set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		set("offset", strconv.Itoa(o.Offset))
+	}
+	return query
+}
+
+// JobList is a page of jobs.
+type JobList struct {
+	Jobs  []*Job
+	Total int // Matching jobs across all pages
+}
+
+// CreateJob queues a job. When an identical job is already queued and
+// req.Force is false, that job is returned instead.
+func (c *Client) CreateJob(ctx context.Context, req CreateJobRequest) (*Job, error) {
+	var resp jobs.JobResponse
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/jobs", body: req}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Job, nil
+}
+
+func (c *Client) GetJob(ctx context.Context, id string) (*Job, error) {
+	var resp jobs.JobResponse
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/jobs/" + url.PathEscape(id)}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Job, nil
+}
+
+func (c *Client) ListJobs(ctx context.Context, opts JobListOptions) (*JobList, error) {
+	var resp jobs.JobsListResponse
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/jobs", query: opts.values()}, &resp); err != nil {
+		return nil, err
+	}
+	return &JobList{Jobs: resp.Jobs, Total: resp.Total}, nil
+}
+
+// CancelJob cancels a pending or running job.
+func (c *Client) CancelJob(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/jobs/" + url.PathEscape(id)}, nil)
+}
+
+// SetJobPriority changes a pending job's priority: "low", "medium" or
+// "high".
+func (c *Client) SetJobPriority(ctx context.Context, id, priority string) (*Job, error) {
+	var resp jobs.JobResponse
+	req := request{
+		method: http.MethodPut,
+		path:   "/api/jobs/" + url.PathEscape(id) + "/priority",
+		body:   jobs.UpdatePriorityRequest{Priority: priority},
+	}
+	if err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Job, nil
+}
+
+// BoostChain raises the pending jobs of a chain, and those it enqueues
+// later, to at least priority, returning the IDs of the jobs raised.
+func (c *Client) BoostChain(ctx context.Context, chainID, priority string) ([]string, error) {
+	var resp jobs.ChainPriorityResponse
+	req := request{
+		method: http.MethodPut,
+		path:   "/api/jobs/chains/" + url.PathEscape(chainID) + "/priority",
+		body:   jobs.UpdatePriorityRequest{Priority: priority},
+	}
+	if err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Boosted, nil
+}
+
+func (c *Client) JobStats(ctx context.Context) (*JobStats, error) {
+	var resp JobStats
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/jobs/stats"}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) JobMetrics(ctx context.Context) (*JobMetrics, error) {
+	var resp JobMetrics
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/jobs/metrics"}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// WaitForJob polls the job every interval until it has completed, failed
+// or been cancelled, and returns it. Use ctx to give up waiting.
+func (c *Client) WaitForJob(ctx context.Context, id string, interval time.Duration) (*Job, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job, err := c.GetJob(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		switch job.Status {
+		case JobStatusCompleted, JobStatusFailed, JobStatusCancelled:
+			return job, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
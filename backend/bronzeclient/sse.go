@@ -0,0 +1,75 @@
+package bronzeclient
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// maxEventSize bounds one server-sent event, as a browse of a large folder
+// sends each folder's listing in one event.
+const maxEventSize = 16 << 20
+
+// ErrStopStream may be returned by a stream callback to stop reading
+// without the stream call returning an error.
+var ErrStopStream = errors.New("stop stream")
+
+// Event is a server-sent event.
+type Event struct {
+	ID   string
+	Type string // "message" when the server names none
+	Data json.RawMessage
+}
+
+// Decode decodes the event's JSON data into v.
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// readEvents calls fn with each event read from r until r ends or fn
+// returns an error.
+func readEvents(r io.Reader, fn func(Event) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxEventSize)
+
+	var event Event
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if data.Len() > 0 {
+				event.Data = json.RawMessage(strings.TrimSuffix(data.String(), "\n"))
+				if event.Type == "" {
+					event.Type = "message"
+				}
+				if err := fn(event); err != nil {
+					if errors.Is(err, ErrStopStream) {
+						return nil
+					}
+					return err
+				}
+			}
+			event = Event{}
+			data.Reset()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // Keepalive comment
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Type = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			event.ID = value
+		}
+	}
+	return scanner.Err()
+}
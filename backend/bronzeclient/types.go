@@ -0,0 +1,48 @@
+package bronzeclient
+
+import (
+	"bronze-backend/data_browser"
+	"bronze-backend/files"
+	"bronze-backend/jobs"
+	"bronze-backend/monitoring"
+	"bronze-backend/storage"
+)
+
+// The API's types, aliased so callers need not import the server packages.
+type (
+	Job              = jobs.Job
+	JobStatus        = jobs.JobStatus
+	JobTrigger       = jobs.JobTrigger
+	CreateJobRequest = jobs.CreateJobRequest
+	JobStats         = jobs.JobStatsResponse
+	JobMetrics       = jobs.JobMetricsResponse
+
+	FileInfo       = storage.FileInfoResponse
+	FileDetail     = storage.FileInfoDetail
+	UploadResult   = files.UploadResponse
+	CopyResult     = files.CopyFileResponse
+	DeleteResult   = files.DeleteResponse
+	BrowseRequest  = files.MultiFolderRequest
+	FolderRequest  = files.FolderRequest
+	FolderFileInfo = files.FileInfo
+	DirectoryInfo  = files.DirectoryInfo
+
+	FileEvent        = monitoring.FileEvent
+	WatcherStatus    = monitoring.WatcherStatus
+	WatchRule        = monitoring.WatchRule
+	BackfillProgress = monitoring.BackfillProgress
+
+	DataBrowseRequest  = data_browser.BrowseRequest
+	DataBrowseResponse = data_browser.BrowseResponse
+	ExportRequest      = data_browser.ExportRequest
+	ExportFile         = data_browser.FileExportInfo
+	ExportResponse     = data_browser.ExportResponse
+)
+
+const (
+	JobStatusPending    = jobs.JobStatusPending
+	JobStatusProcessing = jobs.JobStatusProcessing
+	JobStatusCompleted  = jobs.JobStatusCompleted
+	JobStatusFailed     = jobs.JobStatusFailed
+	JobStatusCancelled  = jobs.JobStatusCancelled
+)
@@ -0,0 +1,109 @@
+package bronzeclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// EventHistory returns the stored watcher events, newest first, at most
+// limit of them if limit is positive.
+func (c *Client) EventHistory(ctx context.Context, limit int) ([]*FileEvent, error) {
+	return c.events(ctx, "/api/watcher/events/history", limit)
+}
+
+// UnprocessedEvents returns the watcher events not yet marked processed.
+func (c *Client) UnprocessedEvents(ctx context.Context, limit int) ([]*FileEvent, error) {
+	return c.events(ctx, "/api/watcher/events/unprocessed", limit)
+}
+
+func (c *Client) events(ctx context.Context, path string, limit int) ([]*FileEvent, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var resp struct {
+		Events []*FileEvent `json:"events"`
+	}
+	if err := c.do(ctx, request{method: http.MethodGet, path: path, query: query}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Events, nil
+}
+
+func (c *Client) MarkEventProcessed(ctx context.Context, eventID string) error {
+	req := request{
+		method: http.MethodPost,
+		path:   "/api/watcher/events/mark-processed",
+		body:   map[string]string{"event_id": eventID},
+	}
+	return c.do(ctx, req, nil)
+}
+
+// StreamEvents calls fn with each watcher event as it happens, only those
+// of the watch rule if rule is not empty. It returns when the server closes
+// the stream or fn returns an error; return ErrStopStream from fn to stop
+// without one.
+func (c *Client) StreamEvents(ctx context.Context, rule string, fn func(*FileEvent) error) error {
+	query := url.Values{}
+	if rule != "" {
+		query.Set("rule", rule)
+	}
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: "/api/watcher/events/stream", query: query})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return readEvents(resp.Body, func(event Event) error {
+		var fileEvent FileEvent
+		if err := event.Decode(&fileEvent); err != nil {
+			return fmt.Errorf("failed to decode watcher event: %w", err)
+		}
+		return fn(&fileEvent)
+	})
+}
+
+func (c *Client) WatcherStatus(ctx context.Context) (*WatcherStatus, error) {
+	var resp WatcherStatus
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/watcher/status"}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) WatchRules(ctx context.Context) ([]WatchRule, error) {
+	var resp struct {
+		Rules []WatchRule `json:"rules"`
+	}
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/watcher/rules"}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Rules, nil
+}
+
+// StartBackfill starts emitting created events for the objects already
+// under the watch rule.
+func (c *Client) StartBackfill(ctx context.Context, rule string) (*BackfillProgress, error) {
+	var resp struct {
+		Backfill *BackfillProgress `json:"backfill"`
+	}
+	req := request{method: http.MethodPost, path: "/api/watcher/rules/" + url.PathEscape(rule) + "/backfill"}
+	if err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Backfill, nil
+}
+
+func (c *Client) Backfill(ctx context.Context, rule string) (*BackfillProgress, error) {
+	var resp struct {
+		Backfill *BackfillProgress `json:"backfill"`
+	}
+	req := request{method: http.MethodGet, path: "/api/watcher/rules/" + url.PathEscape(rule) + "/backfill"}
+	if err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Backfill, nil
+}
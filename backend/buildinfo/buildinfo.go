@@ -0,0 +1,61 @@
+// Package buildinfo identifies the running build. The version is set when
+// linking:
+//
+//	go build -ldflags "-X bronze-backend/buildinfo.Version=1.4.0" -o bronze-backend .
+//
+// The commit comes from the version control information the Go toolchain
+// stamps into binaries built in a git checkout.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Version is the release the binary was built as.
+var Version = "dev"
+
+// Info describes a build.
+type Info struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit,omitempty"`
+	CommitTime string `json:"commit_time,omitempty"` // RFC 3339
+	Modified   bool   `json:"modified,omitempty"`    // Built with uncommitted changes
+	GoVersion  string `json:"go_version"`
+}
+
+// Get returns the running build's Info.
+var Get = sync.OnceValue(func() Info {
+	info := Info{Version: Version, GoVersion: runtime.Version()}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Commit = setting.Value
+		case "vcs.time":
+			info.CommitTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+})
+
+// String returns the version with the short commit, e.g. "1.4.0 (3f2c1ab)".
+func (i Info) String() string {
+	if i.Commit == "" {
+		return i.Version
+	}
+	commit := i.Commit
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	if i.Modified {
+		commit += ", modified"
+	}
+	return i.Version + " (" + commit + ")"
+}
@@ -0,0 +1,25 @@
+package buildinfo
+
+import "testing"
+
+func TestString(t *testing.T) {
+	for _, tt := range []struct {
+		info Info
+		want string
+	}{
+		{Info{Version: "dev"}, "dev"},
+		{Info{Version: "1.4.0", Commit: "3f2c1ab9d0e4"}, "1.4.0 (3f2c1ab)"},
+		{Info{Version: "1.4.0", Commit: "3f2c1ab9d0e4", Modified: true}, "1.4.0 (3f2c1ab, modified)"},
+	} {
+		if got := tt.info.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	info := Get()
+	if info.Version != Version || info.GoVersion == "" {
+		t.Errorf("Get() = %+v", info)
+	}
+}
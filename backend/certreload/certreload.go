@@ -0,0 +1,144 @@
+// Package certreload serves a TLS certificate from PEM files and reloads it
+// when the files change, so a rotated certificate (from cert-manager or
+// certbot, say) is picked up without restarting the server.
+package certreload
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader holds the current certificate for tls.Config.GetCertificate.
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New loads the certificate and key, failing if they cannot be used. With a
+// positive interval the files are checked that often and reloaded when
+// either has changed.
+func New(certFile, keyFile string, interval time.Duration) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		stop:     make(chan struct{}),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		r.wg.Add(1)
+		go r.watch(interval)
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate. It has the signature of
+// tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// TLSConfig returns a server TLS configuration serving the current
+// certificate.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
+
+// Reload reads the certificate and key again. On error the previous
+// certificate stays in use.
+func (r *Reloader) Reload() error {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.certMod = certMod
+	r.keyMod = keyMod
+	r.mu.Unlock()
+
+	if cert.Leaf != nil {
+		log.Printf("Loaded TLS certificate for %v, valid until %s",
+			cert.Leaf.DNSNames, cert.Leaf.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Stop stops watching the files.
+func (r *Reloader) Stop() {
+	close(r.stop)
+	r.wg.Wait()
+}
+
+func (r *Reloader) watch(interval time.Duration) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+
+		if !r.changed() {
+			continue
+		}
+		// A half-written rotation fails here and is retried next tick
+		if err := r.Reload(); err != nil {
+			log.Printf("Warning: Failed to reload TLS certificate, keeping the current one: %v", err)
+		}
+	}
+}
+
+// changed reports whether either file was modified since the last load.
+func (r *Reloader) changed() bool {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		log.Printf("Warning: Failed to check TLS certificate: %v", err)
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod)
+}
+
+func (r *Reloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to read TLS certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to read TLS key: %w", err)
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
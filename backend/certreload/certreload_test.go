@@ -0,0 +1,103 @@
+package certreload
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate for host and its key, and
+// moves their modification time to modTime.
+func writeCert(t *testing.T, certFile, keyFile, host string, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePEM(t, certFile, "CERTIFICATE", der, modTime)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER, modTime)
+}
+
+func writePEM(t *testing.T, path, blockType string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func currentHost(t *testing.T, r *Reloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.Leaf.DNSNames[0]
+}
+
+func TestReloadOnChange(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Hour)
+	writeCert(t, certFile, keyFile, "old.example.com", start)
+
+	r, err := New(certFile, keyFile, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	if host := currentHost(t, r); host != "old.example.com" {
+		t.Fatalf("serving %s, want old.example.com", host)
+	}
+
+	writeCert(t, certFile, keyFile, "new.example.com", start.Add(time.Minute))
+	deadline := time.Now().Add(2 * time.Second)
+	for currentHost(t, r) != "new.example.com" {
+		if time.Now().After(deadline) {
+			t.Fatal("rotated certificate was not loaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A broken certificate leaves the current one in use
+	writePEM(t, certFile, "CERTIFICATE", []byte("garbage"), start.Add(2*time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if host := currentHost(t, r); host != "new.example.com" {
+		t.Errorf("serving %s after a failed reload, want new.example.com", host)
+	}
+}
+
+func TestNewRejectsMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := New(filepath.Join(dir, "missing.crt"), filepath.Join(dir, "missing.key"), 0); err == nil {
+		t.Error("missing certificate accepted")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"bronze-backend/buildinfo"
+	"bronze-backend/config"
+
+	"github.com/joho/godotenv"
+)
+
+// command is a subcommand of the bronze binary.
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+// commands are the subcommands. Without one, bronze serves the API.
+var commands = map[string]command{
+	"serve":   {summary: "Serve the API, running the worker pool and file watcher", run: serve},
+	"worker":  {summary: "Process jobs from the shared queue without serving the API", run: worker},
+	"export":  {summary: "Export files to a Nessie table, e.g. export --preset nightly", run: export},
+	"ingest":  {summary: "Upload local files or directories, e.g. ingest --prefix incoming/ ./data", run: ingest},
+	"version": {summary: "Print the version and commit", run: version},
+}
+
+// runCommand runs the subcommand named by args[0], defaulting to serve.
+func runCommand(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return serve(args)
+	}
+
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		return nil
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		usage()
+		return fmt.Errorf("unknown command %q", name)
+	}
+	return cmd.run(args[1:])
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "Usage: bronze <command> [flags]\n\nCommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun bronze <command> -h for the command's flags.\n")
+}
+
+func version(args []string) error {
+	fmt.Println(buildinfo.Get())
+	return nil
+}
+
+// configFlag is a flag that overrides a configuration key.
+type configFlag struct {
+	name  string
+	key   string
+	usage string
+}
+
+// configFlags are the shorthands every command accepts; -set overrides any
+// other key.
+var configFlags = []configFlag{
+	{name: "host", key: "SERVER_HOST", usage: "address to listen on"},
+	{name: "port", key: "SERVER_PORT", usage: "port to listen on"},
+	{name: "bucket", key: "MINIO_BUCKET", usage: "MinIO bucket"},
+	{name: "minio-endpoint", key: "MINIO_ENDPOINT", usage: "MinIO endpoint, host:port"},
+	{name: "workers", key: "MAX_WORKERS", usage: "number of workers"},
+	{name: "queue", key: "QUEUE_BACKEND", usage: "job queue backend, memory or redis"},
+	{name: "nessie-endpoint", key: "NESSIE_ENDPOINT", usage: "Nessie API endpoint"},
+}
+
+// configOptions loads the configuration from the .env file and environment,
+// with flags taking precedence.
+type configOptions struct {
+	envFile   string
+	overrides map[string]string
+}
+
+// newFlagSet returns a flag set for the named command with the configuration
+// flags registered.
+func newFlagSet(name, args string) (*flag.FlagSet, *configOptions) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	opts := &configOptions{overrides: make(map[string]string)}
+
+	fs.StringVar(&opts.envFile, "env-file", ".env", "`file` of environment variables to load")
+	fs.Func("set", "override a configuration key, as `KEY=VALUE`; repeatable", func(value string) error {
+		key, val, ok := strings.Cut(value, "=")
+		if !ok {
+			return fmt.Errorf("want KEY=VALUE")
+		}
+		opts.overrides[strings.TrimSpace(key)] = val
+		return nil
+	})
+	for _, f := range configFlags {
+		key := f.key
+		fs.Func(f.name, fmt.Sprintf("%s (%s)", f.usage, key), func(value string) error {
+			opts.overrides[key] = value
+			return nil
+		})
+	}
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: bronze %s [flags]%s\n\nFlags:\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs, opts
+}
+
+// load reads the .env file, applies the flag overrides and loads the
+// configuration. Overrides are checked against the configuration schema.
+func (o *configOptions) load() (*config.Config, error) {
+	if err := godotenv.Load(o.envFile); err != nil {
+		log.Println("No .env file found, using environment variables or defaults")
+	} else {
+		log.Printf("Loaded .env file from: %s", o.envFile)
+	}
+
+	if err := config.ValidateUpdates(o.overrides); err != nil {
+		return nil, err
+	}
+	for key, value := range o.overrides {
+		if err := os.Setenv(key, value); err != nil {
+			return nil, fmt.Errorf("failed to set %s: %w", key, err)
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIngestFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"data/a.csv", "data/sub/b.json", "single.xlsx"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := ingestFiles([]string{filepath.Join(dir, "data"), filepath.Join(dir, "single.xlsx")}, "incoming")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var objects []string
+	for _, file := range files {
+		objects = append(objects, file.object)
+	}
+	want := []string{"incoming/a.csv", "incoming/sub/b.json", "incoming/single.xlsx"}
+	if !reflect.DeepEqual(objects, want) {
+		t.Errorf("objects = %v, want %v", objects, want)
+	}
+
+	if _, err := ingestFiles([]string{filepath.Join(dir, "missing")}, ""); err == nil {
+		t.Error("missing path: want error")
+	}
+}
+
+func TestConfigFlagsOverrideEnvironment(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	t.Setenv("SERVER_PORT", "8060")
+	t.Setenv("MAX_WORKERS", "3")
+
+	fs, opts := newFlagSet("test", "")
+	if err := fs.Parse([]string{"-env-file", filepath.Join(t.TempDir(), ".env"), "-port", "9090", "-set", "MAX_WORKERS=7"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := opts.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != 9090 || cfg.Processing.MaxWorkers != 7 {
+		t.Errorf("port %d, workers %d, want 9090 and 7", cfg.Server.Port, cfg.Processing.MaxWorkers)
+	}
+
+	fs, opts = newFlagSet("test", "")
+	if err := fs.Parse([]string{"-env-file", filepath.Join(t.TempDir(), ".env"), "-workers", "many"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := opts.load(); err == nil {
+		t.Error("invalid -workers: want error")
+	}
+}
@@ -2,21 +2,48 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Server     ServerConfig     `json:"server"`
-	MinIO      MinIOConfig      `json:"minio"`
-	Processing ProcessingConfig `json:"processing"`
-	Nessie     NessieConfig     `json:"nessie"`
+	Server      ServerConfig      `json:"server"`
+	MinIO       MinIOConfig       `json:"minio"`
+	Processing  ProcessingConfig  `json:"processing"`
+	Nessie      NessieConfig      `json:"nessie"`
+	Watcher     WatcherConfig     `json:"watcher"`
+	Tracing     TracingConfig     `json:"tracing"`
+	Debug       DebugConfig       `json:"debug"`
+	Auth        AuthConfig        `json:"auth"`
+	Audit       AuditConfig       `json:"audit"`
+	RateLimit   RateLimitConfig   `json:"rate_limit"`
+	BodyLimit   BodyLimitConfig   `json:"body_limit"`
+	Tenancy     TenancyConfig     `json:"tenancy"`
+	Usage       UsageConfig       `json:"usage"`
+	Idempotency IdempotencyConfig `json:"idempotency"`
+	Quarantine  QuarantineConfig  `json:"quarantine"`
+	ObjectCache ObjectCacheConfig `json:"object_cache"`
 }
 
 type ServerConfig struct {
 	Host string `json:"host"`
 	Port int    `json:"port"`
+	// Mode is "all", "api" or "worker"; see RunModeAll
+	Mode string `json:"mode"`
+	// TLSCert and TLSKey are PEM files; with both set the server speaks
+	// HTTPS and reloads them every TLSReloadInterval if they change (0
+	// disables reloading)
+	TLSCert           string        `json:"tls_cert"`
+	TLSKey            string        `json:"tls_key"`
+	TLSReloadInterval time.Duration `json:"tls_reload_interval"`
+	// AllowedOrigins, comma-separated, are the web origins besides the
+	// server's own that may open WebSocket connections; see
+	// AllowedOriginList
+	AllowedOrigins string `json:"allowed_origins"`
 }
 
 type MinIOConfig struct {
@@ -25,23 +52,490 @@ type MinIOConfig struct {
 	SecretKey string `json:"secret_key"`
 	Bucket    string `json:"bucket"`
 	Region    string `json:"region"`
+	// AllowedBuckets, comma-separated, are the buckets besides the current
+	// one that non-admin clients may select per request; see
+	// AllowedBucketNames
+	AllowedBuckets string `json:"allowed_buckets"`
+	// Objects of at least DownloadThreshold, a byte size, are downloaded
+	// as DownloadPartSize ranges, DownloadConcurrency at a time; an empty
+	// threshold downloads every object in one request
+	DownloadThreshold   string `json:"download_threshold"`
+	DownloadPartSize    string `json:"download_part_size"`
+	DownloadConcurrency int    `json:"download_concurrency"`
+	// Client sets the HTTP transport, retries and tracing of the MinIO SDK
+	Client MinIOClientConfig `json:"client"`
 }
 
+// MinIOClientConfig tunes the MinIO SDK's HTTP client. A zero timeout
+// waits forever.
+type MinIOClientConfig struct {
+	// MaxIdleConns is how many connections are kept open for reuse, at
+	// most MaxIdleConnsPerHost to each host
+	MaxIdleConns        int           `json:"max_idle_conns"`
+	MaxIdleConnsPerHost int           `json:"max_idle_conns_per_host"`
+	DialTimeout         time.Duration `json:"dial_timeout"`
+	TLSHandshakeTimeout time.Duration `json:"tls_handshake_timeout"`
+	// ResponseHeaderTimeout is the longest wait for a response once a
+	// request is sent
+	ResponseHeaderTimeout time.Duration `json:"response_header_timeout"`
+	IdleConnTimeout       time.Duration `json:"idle_conn_timeout"`
+	// MaxRetries is how many times the SDK retries a failed request
+	MaxRetries int `json:"max_retries"`
+	// Trace logs the SDK's requests and responses; see MinIOTraceOff
+	Trace string `json:"trace"`
+}
+
+// What the MinIO SDK traces: nothing, failed requests, or every request.
+// Signatures are redacted.
+const (
+	MinIOTraceOff    = "off"
+	MinIOTraceErrors = "errors"
+	MinIOTraceAll    = "all"
+)
+
 type ProcessingConfig struct {
 	MaxWorkers    int                 `json:"max_workers"`
 	QueueSize     int                 `json:"queue_size"`
 	Decompression DecompressionConfig `json:"decompression"`
 	WatchInterval time.Duration       `json:"watch_interval"`
 	TempDir       string              `json:"temp_dir"`
+	StateFile     string              `json:"state_file"`
+	Autoscale     AutoscaleConfig     `json:"autoscale"`
+	Queue         QueueConfig         `json:"queue"`
+	Webhook       WebhookConfig       `json:"webhook"`
+	// ConversionRates are the unit and currency rates amount normalization
+	// converts with, as comma-separated "FROM/TO=rate" entries, rate being
+	// how many TO make one FROM (e.g. "EUR/USD=1.08,km/m=1000")
+	ConversionRates string `json:"conversion_rates"`
+	// JobHistory is how many finished jobs the queue keeps for
+	// lookup, the oldest being dropped first
+	JobHistory int `json:"job_history"`
+	// JobTempDiskLimit and JobMemoryLimit budget each job's writes to
+	// TempDir and the bytes it reads into memory to parse, as byte sizes;
+	// empty is unlimited
+	JobTempDiskLimit string `json:"job_temp_disk_limit"`
+	JobMemoryLimit   string `json:"job_memory_limit"`
+	// TempMinFree is the disk space, as a byte size, downloads and
+	// extractions leave free on TempDir's filesystem; empty only checks
+	// that they fit
+	TempMinFree string `json:"temp_min_free"`
+	// TempCleanInterval is how often job directories left in TempDir by
+	// crashed runs are removed, 0 for only at startup; TempOrphanAge is
+	// how long since one was last modified before it counts as orphaned
+	TempCleanInterval time.Duration `json:"temp_clean_interval"`
+	TempOrphanAge     time.Duration `json:"temp_orphan_age"`
+}
+
+// Run modes split the API from job processing, so each can be scaled on its
+// own. RunModeAll serves the API and processes jobs in one process;
+// RunModeAPI serves the API without a worker pool; RunModeWorker processes
+// jobs and serves only the health probes. The API and worker instances share
+// jobs through the Redis queue.
+const (
+	RunModeAll    = "all"
+	RunModeAPI    = "api"
+	RunModeWorker = "worker"
+)
+
+const (
+	QueueBackendMemory = "memory"
+	QueueBackendRedis  = "redis"
+)
+
+type QueueConfig struct {
+	Backend           string        `json:"backend"`
+	RedisURL          string        `json:"redis_url"`
+	Prefix            string        `json:"prefix"`
+	Consumer          string        `json:"consumer"`
+	VisibilityTimeout time.Duration `json:"visibility_timeout"`
+}
+
+// WebhookConfig sets a webhook notified of every finished job, in addition
+// to any per-job callback URL. Secret signs the payloads of both.
+type WebhookConfig struct {
+	URL        string        `json:"url"`
+	Secret     string        `json:"-"`
+	Timeout    time.Duration `json:"timeout"`
+	MaxRetries int           `json:"max_retries"`
+	// AllowedHosts, comma-separated host names, IP addresses and CIDR
+	// ranges, are the internal hosts per-job and watch rule webhooks may
+	// call; other loopback, private and link-local addresses are refused
+	AllowedHosts string `json:"allowed_hosts"`
+}
+
+type AutoscaleConfig struct {
+	Enabled    bool          `json:"enabled"`
+	MinWorkers int           `json:"min_workers"`
+	MaxWorkers int           `json:"max_workers"`
+	Interval   time.Duration `json:"interval"`
+	TargetWait time.Duration `json:"target_wait"`
 }
 
 type DecompressionConfig struct {
-	Enabled            bool   `json:"enabled"`
-	MaxExtractSize     string `json:"max_extract_size"`
-	MaxFilesPerArchive int    `json:"max_files_per_archive"`
-	NestedArchiveDepth int    `json:"nested_archive_depth"`
-	PasswordProtected  bool   `json:"password_protected"`
-	ExtractToSubfolder bool   `json:"extract_to_subfolder"`
+	Enabled             bool    `json:"enabled"`
+	MaxExtractSize      string  `json:"max_extract_size"`
+	MaxFilesPerArchive  int     `json:"max_files_per_archive"`
+	MaxCompressionRatio float64 `json:"max_compression_ratio"`
+	NestedArchiveDepth  int     `json:"nested_archive_depth"`
+	PasswordProtected   bool    `json:"password_protected"`
+	ExtractToSubfolder  bool    `json:"extract_to_subfolder"`
+	ExtractPrefix       string  `json:"extract_prefix"`
+	StreamThreshold     string  `json:"stream_threshold"`
+	// AutoExtract extracts archives landing under a prefix without being
+	// asked, as comma-separated "prefix[=destination][:cleanup]" entries
+	AutoExtract string `json:"auto_extract"`
+}
+
+// Cleanup policies of an AutoExtractRule.
+const (
+	CleanupKeep   = "keep"   // Leave the archive in place
+	CleanupDelete = "delete" // Delete the archive once it is extracted
+)
+
+// AutoExtractRule is one entry of DecompressionConfig.AutoExtract.
+type AutoExtractRule struct {
+	Prefix      string `json:"prefix"`
+	Destination string `json:"destination,omitempty"` // Empty extracts to the usual location
+	Cleanup     string `json:"cleanup"`
+}
+
+// ParseAutoExtract parses AutoExtract.
+func (c DecompressionConfig) ParseAutoExtract() ([]AutoExtractRule, error) {
+	var rules []AutoExtractRule
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(c.AutoExtract, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		location, cleanup, _ := strings.Cut(entry, ":")
+		prefix, destination, _ := strings.Cut(location, "=")
+		rule := AutoExtractRule{
+			Prefix:      strings.TrimLeft(strings.TrimSpace(prefix), "/"),
+			Destination: strings.Trim(strings.TrimSpace(destination), "/"),
+			Cleanup:     strings.ToLower(strings.TrimSpace(cleanup)),
+		}
+		if rule.Prefix == "" {
+			return nil, fmt.Errorf("invalid rule %q, want \"prefix[=destination][:cleanup]\"", entry)
+		}
+		if seen[rule.Prefix] {
+			return nil, fmt.Errorf("prefix %s is listed twice", rule.Prefix)
+		}
+		seen[rule.Prefix] = true
+		if strings.Contains(rule.Destination, "..") {
+			return nil, fmt.Errorf("invalid rule %q: destination must not contain \"..\"", entry)
+		}
+		switch rule.Cleanup {
+		case "":
+			rule.Cleanup = CleanupKeep
+		case CleanupKeep, CleanupDelete:
+		default:
+			return nil, fmt.Errorf("invalid rule %q: unknown cleanup %q, use keep or delete", entry, rule.Cleanup)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+const (
+	WatcherModePoll   = "poll"
+	WatcherModeNotify = "notify"
+
+	WatcherStorageMemory = "memory"
+	WatcherStorageSQLite = "sqlite"
+)
+
+// WatcherConfig controls the file watcher. In poll mode the bucket is listed
+// every WATCH_INTERVAL; notify mode subscribes to MinIO bucket notifications.
+type WatcherConfig struct {
+	Enabled bool   `json:"enabled"`
+	Mode    string `json:"mode"`
+	Bucket  string `json:"bucket"`
+	Prefix  string `json:"prefix"`
+	// RulesFile is a JSON array of watch rules; when set, it replaces
+	// Bucket, Prefix and Mode
+	RulesFile string `json:"rules_file"`
+	// Storage is "memory" or "sqlite"; DBPath defaults to TEMP_DIR/watcher.db
+	Storage   string        `json:"storage"`
+	DBPath    string        `json:"db_path"`
+	Retention time.Duration `json:"retention"` // Processed events older than this are deleted, 0 keeps them
+	// AutoJobsFile stores the auto-job rules; defaults to TEMP_DIR/auto_jobs.json
+	AutoJobsFile string `json:"auto_jobs_file"`
+	// Debounce holds an object's events until it has been quiet this long, so
+	// a multipart upload or copy produces one event; 0 disables
+	Debounce time.Duration `json:"debounce"`
+}
+
+// TracingConfig exports OpenTelemetry traces over OTLP/HTTP. Tracing is off
+// when Endpoint is empty.
+type TracingConfig struct {
+	Endpoint    string  `json:"endpoint"` // e.g. http://localhost:4318
+	ServiceName string  `json:"service_name"`
+	SampleRatio float64 `json:"sample_ratio"` // Share of new traces recorded, 0 to 1
+}
+
+// Enabled reports whether traces are exported.
+func (t TracingConfig) Enabled() bool {
+	return t.Endpoint != ""
+}
+
+// DebugConfig exposes pprof and runtime statistics under /api/debug.
+// Requests must send Token as a bearer token; Load refuses Enabled without
+// one, as the endpoints are outside OIDC auth.
+type DebugConfig struct {
+	Enabled bool   `json:"enabled"`
+	Token   string `json:"-"`
+}
+
+// AuthConfig validates OIDC bearer tokens on API requests. Roles are read
+// from RolesClaim, which may be a dotted path such as realm_access.roles;
+// tokens carrying no known role get DefaultRole, or are refused if it is
+// empty. TenantClaim, also a dotted path, names the caller's tenant.
+type AuthConfig struct {
+	Enabled     bool   `json:"enabled"`
+	IssuerURL   string `json:"issuer_url"`
+	Audience    string `json:"audience"` // Expected aud claim; empty skips the check
+	JWKSURL     string `json:"jwks_url"` // Overrides the key set found by discovery
+	RolesClaim  string `json:"roles_claim"`
+	DefaultRole string `json:"default_role"`
+	TenantClaim string `json:"tenant_claim"`
+}
+
+// AuditConfig records mutating API requests in a SQLite database.
+type AuditConfig struct {
+	Enabled   bool          `json:"enabled"`
+	DBPath    string        `json:"db_path"`   // Defaults to TEMP_DIR/audit.db
+	Retention time.Duration `json:"retention"` // Entries older than this are pruned, 0 keeps them
+}
+
+// RateLimitConfig gives each client (bearer token, or IP without one) a
+// token bucket for API requests, and a second, smaller one for expensive
+// operations such as browsing and exports.
+type RateLimitConfig struct {
+	Enabled        bool    `json:"enabled"`
+	RPS            float64 `json:"rps"` // Sustained requests per second
+	Burst          int     `json:"burst"`
+	ExpensiveRPS   float64 `json:"expensive_rps"`
+	ExpensiveBurst int     `json:"expensive_burst"`
+	TrustProxy     bool    `json:"trust_proxy"` // Take the client IP from X-Forwarded-For
+}
+
+// BodyLimitConfig caps request body sizes. Sizes are byte sizes such as
+// 10MB, see ParseByteSize; 0 means no limit.
+type BodyLimitConfig struct {
+	MaxUploadSize   string `json:"max_upload_size"`    // multipart/form-data bodies
+	MaxJSONBodySize string `json:"max_json_body_size"` // Every other body
+	// UploadMemory is how much of an upload is held in memory; the rest
+	// spills to temporary files
+	UploadMemory string `json:"upload_memory"`
+	// Endpoints overrides the limit of single routes, as comma-separated
+	// "METHOD /route/{var}=size" entries
+	Endpoints string `json:"endpoints"`
+}
+
+// TenancyConfig splits one deployment between tenants, each confined to a
+// bucket and prefix and a Nessie namespace. A request's tenant comes from
+// the token's tenant claim, or from Header.
+type TenancyConfig struct {
+	Enabled bool `json:"enabled"`
+	// Tenants lists the tenants as comma-separated
+	// "name=bucket[/prefix][:namespace]" entries; the namespace defaults to
+	// the tenant's name
+	Tenants string `json:"tenants"`
+	Header  string `json:"header"`
+}
+
+// Tenant is one entry of TenancyConfig.Tenants.
+type Tenant struct {
+	Name      string
+	Bucket    string
+	Prefix    string // Empty, or ending in "/"
+	Namespace string
+}
+
+// ParseTenants parses Tenants.
+func (c TenancyConfig) ParseTenants() ([]Tenant, error) {
+	var tenants []Tenant
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(c.Tenants, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, location, ok := strings.Cut(entry, "=")
+		location, namespace, _ := strings.Cut(location, ":")
+		bucket, prefix, _ := strings.Cut(strings.TrimSpace(location), "/")
+		name, bucket = strings.TrimSpace(name), strings.TrimSpace(bucket)
+		if !ok || name == "" || bucket == "" {
+			return nil, fmt.Errorf("invalid tenant %q, want \"name=bucket[/prefix][:namespace]\"", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("tenant %s is listed twice", name)
+		}
+		seen[name] = true
+
+		if prefix = strings.Trim(prefix, "/"); prefix != "" {
+			prefix += "/"
+		}
+		if namespace = strings.TrimSpace(namespace); namespace == "" {
+			namespace = name
+		}
+		tenants = append(tenants, Tenant{Name: name, Bucket: bucket, Prefix: prefix, Namespace: namespace})
+	}
+	return tenants, nil
+}
+
+// ParseConversionRates parses ConversionRates into rates keyed by their
+// upper-cased "FROM/TO" pair.
+func (c ProcessingConfig) ParseConversionRates() (map[string]float64, error) {
+	rates := make(map[string]float64)
+	for _, entry := range strings.Split(c.ConversionRates, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pair, value, ok := strings.Cut(entry, "=")
+		from, to, isPair := strings.Cut(pair, "/")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || !isPair || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid rate %q, want \"FROM/TO=rate\"", entry)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || !(rate > 0) || math.IsInf(rate, 0) {
+			return nil, fmt.Errorf("invalid rate %q, want a positive number", entry)
+		}
+		rates[strings.ToUpper(from+"/"+to)] = rate
+	}
+	return rates, nil
+}
+
+// UsageConfig accounts the bytes each caller uploads, the rows they export
+// and the time their jobs run, per calendar month, in a SQLite database.
+type UsageConfig struct {
+	Enabled bool   `json:"enabled"`
+	DBPath  string `json:"db_path"` // Defaults to TEMP_DIR/usage.db
+	// Quotas caps each caller's monthly usage, as comma-separated
+	// "metric=soft/hard" entries for the metrics upload (a size), rows (a
+	// count) and job_time (a duration). Either limit may be left empty; a
+	// lone value is the hard limit
+	Quotas string `json:"quotas"`
+}
+
+// Quota limits one usage metric; 0 is no limit. Going over Soft is only
+// warned about, while Hard refuses further use.
+type Quota struct {
+	Soft int64 `json:"soft,omitempty"`
+	Hard int64 `json:"hard,omitempty"`
+}
+
+// Quotas are the parsed UsageConfig.Quotas. JobTime is in milliseconds.
+type Quotas struct {
+	Upload  Quota `json:"upload"`
+	Rows    Quota `json:"rows"`
+	JobTime Quota `json:"job_time"`
+}
+
+// ParseQuotas parses Quotas.
+func (c UsageConfig) ParseQuotas() (Quotas, error) {
+	var quotas Quotas
+	for _, entry := range strings.Split(c.Quotas, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		metric, limits, ok := strings.Cut(entry, "=")
+		if !ok {
+			return quotas, fmt.Errorf("invalid quota %q, want \"metric=soft/hard\"", entry)
+		}
+		var quota *Quota
+		var parse func(string) (int64, error)
+		switch strings.TrimSpace(metric) {
+		case "upload":
+			quota, parse = &quotas.Upload, ParseByteSize
+		case "rows":
+			quota, parse = &quotas.Rows, func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) }
+		case "job_time":
+			quota, parse = &quotas.JobTime, func(s string) (int64, error) {
+				d, err := time.ParseDuration(s)
+				return d.Milliseconds(), err
+			}
+		default:
+			return quotas, fmt.Errorf("unknown quota metric %q, use upload, rows or job_time", metric)
+		}
+
+		limit := func(value string) (int64, error) {
+			if value = strings.TrimSpace(value); value == "" {
+				return 0, nil
+			}
+			n, err := parse(value)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid quota %q: bad limit %q", entry, value)
+			}
+			return n, nil
+		}
+		soft, hard, hasSoft := strings.Cut(limits, "/")
+		if !hasSoft {
+			soft, hard = "", limits
+		}
+		var err error
+		if quota.Soft, err = limit(soft); err != nil {
+			return quotas, err
+		}
+		if quota.Hard, err = limit(hard); err != nil {
+			return quotas, err
+		}
+		if quota.Hard > 0 && quota.Soft > quota.Hard {
+			return quotas, fmt.Errorf("invalid quota %q: soft limit over hard limit", entry)
+		}
+	}
+	return quotas, nil
+}
+
+// IdempotencyConfig keeps the responses of uploads, job creation and
+// exports sent with an Idempotency-Key header, and replays them when the
+// request is retried with the same key.
+type IdempotencyConfig struct {
+	Enabled bool          `json:"enabled"`
+	TTL     time.Duration `json:"ttl"` // How long a response is replayed
+}
+
+// QuarantineConfig sets aside source files that keep failing extraction or
+// export, with a report of why, under Prefix.
+type QuarantineConfig struct {
+	Enabled   bool   `json:"enabled"`
+	Prefix    string `json:"prefix"`
+	Threshold int    `json:"threshold"` // Failures in a row before a file is quarantined
+	Move      bool   `json:"move"`      // Delete the file after copying it
+}
+
+// ObjectCacheConfig keeps local copies of the objects read whole for
+// browsing, exports and jobs, keyed by ETag. An empty Size disables it.
+type ObjectCacheConfig struct {
+	Dir     string `json:"dir"`      // Defaults to TEMP_DIR/object-cache
+	Size    string `json:"size"`     // Byte size the copies may take up
+	MinSize string `json:"min_size"` // Smaller objects are read from MinIO each time
+}
+
+// EndpointLimits parses Endpoints into limits keyed by "METHOD /route".
+func (c BodyLimitConfig) EndpointLimits() (map[string]int64, error) {
+	limits := make(map[string]int64)
+	for _, entry := range strings.Split(c.Endpoints, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, size, ok := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasPath || method == "" || !strings.HasPrefix(strings.TrimSpace(path), "/") {
+			return nil, fmt.Errorf("invalid endpoint limit %q, want \"METHOD /path=size\"", entry)
+		}
+		limit, err := ParseByteSize(size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint limit %q: %w", entry, err)
+		}
+		limits[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = limit
+	}
+	return limits, nil
 }
 
 type NessieConfig struct {
@@ -50,48 +544,316 @@ type NessieConfig struct {
 	AuthToken string `json:"auth_token"`
 	DefaultDB string `json:"default_database"`
 	BatchSize int    `json:"batch_size"`
+	// RetryInterval is the first wait before reconnecting when Nessie is
+	// unreachable at startup; it doubles up to 5 minutes
+	RetryInterval time.Duration `json:"retry_interval"`
+	// PresetsFile holds named export requests for `bronze export --preset`;
+	// defaults to TEMP_DIR/export_presets.json
+	PresetsFile string `json:"presets_file"`
+	// SilverNamespace holds the tables promote jobs write; a tenant's are
+	// in this child of its namespace
+	SilverNamespace string `json:"silver_namespace"`
+	// SchemaDrift is what exports do when a load's columns differ from the
+	// table's last load, unless the request says otherwise
+	SchemaDrift string `json:"schema_drift"`
+	// Client sets the timeout, retries and circuit breaker of requests
+	Client NessieClientConfig `json:"client"`
 }
 
+// NessieClientConfig tunes the HTTP client talking to Nessie.
+type NessieClientConfig struct {
+	Timeout time.Duration `json:"timeout"` // Per attempt
+	// MaxRetries is how many times a read or delete failing with a network
+	// error or a 429 or 5xx status is retried, backing off from RetryBackoff
+	MaxRetries   int           `json:"max_retries"`
+	RetryBackoff time.Duration `json:"retry_backoff"`
+	// MaxIdleConns is how many connections to Nessie are kept open for reuse
+	MaxIdleConns int `json:"max_idle_conns"`
+	// BreakerThreshold consecutive failed requests open the circuit
+	// breaker, failing requests at once for BreakerCooldown before one is
+	// let through to probe Nessie; 0 disables the breaker
+	BreakerThreshold int           `json:"breaker_threshold"`
+	BreakerCooldown  time.Duration `json:"breaker_cooldown"`
+	// CatalogCacheTTL is how long pages of namespaces and tables are
+	// cached; 0 disables the cache
+	CatalogCacheTTL time.Duration `json:"catalog_cache_ttl"`
+}
+
+// What an export does on schema drift
+const (
+	SchemaDriftWarn  = "warn"
+	SchemaDriftBlock = "block"
+	SchemaDriftOff   = "off"
+)
+
 func Load() (*Config, error) {
 	config := &Config{
 		Server: ServerConfig{
-			Host: getEnv("SERVER_HOST", "localhost"),
-			Port: getEnvInt("SERVER_PORT", 8060),
+			Host:              getEnv("SERVER_HOST", "localhost"),
+			Port:              getEnvInt("SERVER_PORT", 8060),
+			Mode:              getEnv("RUN_MODE", RunModeAll),
+			TLSCert:           getEnv("SERVER_TLS_CERT", ""),
+			TLSKey:            getEnv("SERVER_TLS_KEY", ""),
+			TLSReloadInterval: getEnvDuration("SERVER_TLS_RELOAD_INTERVAL", time.Minute),
+			AllowedOrigins:    getEnv("SERVER_ALLOWED_ORIGINS", ""),
 		},
 		MinIO: MinIOConfig{
-			Endpoint:  getEnv("MINIO_ENDPOINT", "localhost:9000"),
-			AccessKey: getEnv("MINIO_ACCESS_KEY", "minioadmin"),
-			SecretKey: getEnv("MINIO_SECRET_KEY", "minioadmin"),
-			Bucket:    getEnv("MINIO_BUCKET", "files"),
-			Region:    getEnv("MINIO_REGION", "us-east-1"),
+			Endpoint:            getEnv("MINIO_ENDPOINT", "localhost:9000"),
+			AccessKey:           getEnv("MINIO_ACCESS_KEY", "minioadmin"),
+			SecretKey:           getEnv("MINIO_SECRET_KEY", "minioadmin"),
+			Bucket:              getEnv("MINIO_BUCKET", "files"),
+			Region:              getEnv("MINIO_REGION", "us-east-1"),
+			AllowedBuckets:      getEnv("MINIO_ALLOWED_BUCKETS", ""),
+			DownloadThreshold:   getEnv("MINIO_DOWNLOAD_THRESHOLD", "256MB"),
+			DownloadPartSize:    getEnv("MINIO_DOWNLOAD_PART_SIZE", "16MB"),
+			DownloadConcurrency: getEnvInt("MINIO_DOWNLOAD_CONCURRENCY", 4),
+			Client: MinIOClientConfig{
+				MaxIdleConns:          getEnvInt("MINIO_MAX_IDLE_CONNS", 256),
+				MaxIdleConnsPerHost:   getEnvInt("MINIO_MAX_IDLE_CONNS_PER_HOST", 16),
+				DialTimeout:           getEnvDuration("MINIO_DIAL_TIMEOUT", 30*time.Second),
+				TLSHandshakeTimeout:   getEnvDuration("MINIO_TLS_HANDSHAKE_TIMEOUT", 10*time.Second),
+				ResponseHeaderTimeout: getEnvDuration("MINIO_RESPONSE_HEADER_TIMEOUT", time.Minute),
+				IdleConnTimeout:       getEnvDuration("MINIO_IDLE_CONN_TIMEOUT", time.Minute),
+				MaxRetries:            getEnvInt("MINIO_MAX_RETRIES", 3),
+				Trace:                 getEnv("MINIO_TRACE", MinIOTraceOff),
+			},
 		},
 		Processing: ProcessingConfig{
-			MaxWorkers:    getEnvInt("MAX_WORKERS", 3),
-			QueueSize:     getEnvInt("QUEUE_SIZE", 100),
-			WatchInterval: getEnvDuration("WATCH_INTERVAL", 5*time.Second),
-			TempDir:       getEnv("TEMP_DIR", "/tmp/bronze"),
+			MaxWorkers:        getEnvInt("MAX_WORKERS", 3),
+			QueueSize:         getEnvInt("QUEUE_SIZE", 100),
+			JobHistory:        getEnvInt("JOB_HISTORY", 1000),
+			JobTempDiskLimit:  getEnv("JOB_TEMP_DISK_LIMIT", ""),
+			JobMemoryLimit:    getEnv("JOB_MEMORY_LIMIT", ""),
+			WatchInterval:     getEnvDuration("WATCH_INTERVAL", 5*time.Second),
+			TempDir:           getEnv("TEMP_DIR", "/tmp/bronze"),
+			TempMinFree:       getEnv("TEMP_MIN_FREE", ""),
+			TempCleanInterval: getEnvDuration("TEMP_CLEAN_INTERVAL", 15*time.Minute),
+			TempOrphanAge:     getEnvDuration("TEMP_ORPHAN_AGE", time.Hour),
+			StateFile:         getEnv("JOB_STATE_FILE", ""),
+			ConversionRates:   getEnv("CONVERSION_RATES", ""),
+			Autoscale: AutoscaleConfig{
+				Enabled:    getEnvBool("AUTOSCALE_ENABLED", false),
+				MinWorkers: getEnvInt("AUTOSCALE_MIN_WORKERS", 1),
+				MaxWorkers: getEnvInt("AUTOSCALE_MAX_WORKERS", 10),
+				Interval:   getEnvDuration("AUTOSCALE_INTERVAL", 15*time.Second),
+				TargetWait: getEnvDuration("AUTOSCALE_TARGET_WAIT", 30*time.Second),
+			},
+			Queue: QueueConfig{
+				Backend:           getEnv("QUEUE_BACKEND", QueueBackendMemory),
+				RedisURL:          getEnv("REDIS_URL", "redis://localhost:6379/0"),
+				Prefix:            getEnv("QUEUE_PREFIX", "bronze"),
+				Consumer:          getEnv("QUEUE_CONSUMER", ""),
+				VisibilityTimeout: getEnvDuration("QUEUE_VISIBILITY_TIMEOUT", 5*time.Minute),
+			},
+			Webhook: WebhookConfig{
+				URL:          getEnv("WEBHOOK_URL", ""),
+				Secret:       getEnv("WEBHOOK_SECRET", ""),
+				Timeout:      getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+				MaxRetries:   getEnvInt("WEBHOOK_MAX_RETRIES", 3),
+				AllowedHosts: getEnv("WEBHOOK_ALLOWED_HOSTS", ""),
+			},
 			Decompression: DecompressionConfig{
-				Enabled:            getEnvBool("DECOMPRESSION_ENABLED", true),
-				MaxExtractSize:     getEnv("MAX_EXTRACT_SIZE", ""),
-				MaxFilesPerArchive: getEnvInt("MAX_FILES_PER_ARCHIVE", 0),
-				NestedArchiveDepth: getEnvInt("NESTED_ARCHIVE_DEPTH", 0),
-				PasswordProtected:  getEnvBool("PASSWORD_PROTECTED", true),
-				ExtractToSubfolder: getEnvBool("EXTRACT_TO_SUBFOLDER", true),
+				Enabled:             getEnvBool("DECOMPRESSION_ENABLED", true),
+				MaxExtractSize:      getEnv("MAX_EXTRACT_SIZE", "10GB"),
+				MaxFilesPerArchive:  getEnvInt("MAX_FILES_PER_ARCHIVE", 10000),
+				MaxCompressionRatio: getEnvFloat("MAX_COMPRESSION_RATIO", 100),
+				NestedArchiveDepth:  getEnvInt("NESTED_ARCHIVE_DEPTH", 0),
+				PasswordProtected:   getEnvBool("PASSWORD_PROTECTED", true),
+				ExtractToSubfolder:  getEnvBool("EXTRACT_TO_SUBFOLDER", true),
+				ExtractPrefix:       getEnv("EXTRACT_PREFIX", ""),
+				StreamThreshold:     getEnv("STREAM_EXTRACT_THRESHOLD", ""),
+				AutoExtract:         getEnv("AUTO_EXTRACT", ""),
 			},
 		},
 		Nessie: NessieConfig{
-			Endpoint:  getEnv("NESSIE_ENDPOINT", "http://localhost:19120/api/v1"),
-			Namespace: getEnv("NESSIE_NAMESPACE", "warehouse"),
-			AuthToken: getEnv("NESSIE_AUTH_TOKEN", ""),
-			DefaultDB: getEnv("NESSIE_DEFAULT_DB", "bronze_warehouse"),
-			BatchSize: getEnvInt("NESSIE_BATCH_SIZE", 1000),
+			Endpoint:        getEnv("NESSIE_ENDPOINT", "http://localhost:19120/api/v1"),
+			Namespace:       getEnv("NESSIE_NAMESPACE", "warehouse"),
+			AuthToken:       getEnv("NESSIE_AUTH_TOKEN", ""),
+			DefaultDB:       getEnv("NESSIE_DEFAULT_DB", "bronze_warehouse"),
+			BatchSize:       getEnvInt("NESSIE_BATCH_SIZE", 1000),
+			RetryInterval:   getEnvDuration("NESSIE_RETRY_INTERVAL", 10*time.Second),
+			PresetsFile:     getEnv("EXPORT_PRESETS_FILE", ""),
+			SilverNamespace: getEnv("NESSIE_SILVER_NAMESPACE", "silver"),
+			SchemaDrift:     getEnv("EXPORT_SCHEMA_DRIFT", SchemaDriftWarn),
+			Client: NessieClientConfig{
+				Timeout:          getEnvDuration("NESSIE_TIMEOUT", 30*time.Second),
+				MaxRetries:       getEnvInt("NESSIE_MAX_RETRIES", 3),
+				RetryBackoff:     getEnvDuration("NESSIE_RETRY_BACKOFF", 500*time.Millisecond),
+				MaxIdleConns:     getEnvInt("NESSIE_MAX_IDLE_CONNS", 16),
+				BreakerThreshold: getEnvInt("NESSIE_BREAKER_THRESHOLD", 5),
+				BreakerCooldown:  getEnvDuration("NESSIE_BREAKER_COOLDOWN", 30*time.Second),
+				CatalogCacheTTL:  getEnvDuration("NESSIE_CATALOG_CACHE_TTL", time.Minute),
+			},
+		},
+		Watcher: WatcherConfig{
+			Enabled:      getEnvBool("WATCHER_ENABLED", true),
+			Mode:         getEnv("WATCHER_MODE", WatcherModePoll),
+			Bucket:       getEnv("WATCHER_BUCKET", ""),
+			Prefix:       getEnv("WATCHER_PREFIX", ""),
+			RulesFile:    getEnv("WATCHER_RULES_FILE", ""),
+			Storage:      getEnv("WATCHER_STORAGE", WatcherStorageMemory),
+			DBPath:       getEnv("WATCHER_DB_PATH", ""),
+			Retention:    getEnvDuration("WATCHER_RETENTION", 7*24*time.Hour),
+			AutoJobsFile: getEnv("WATCHER_AUTO_JOBS_FILE", ""),
+			Debounce:     getEnvDuration("WATCHER_DEBOUNCE", 2*time.Second),
+		},
+		Tracing: TracingConfig{
+			Endpoint:    getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+			ServiceName: getEnv("OTEL_SERVICE_NAME", "bronze-backend"),
+			SampleRatio: getEnvFloat("TRACING_SAMPLE_RATIO", 1.0),
+		},
+		Debug: DebugConfig{
+			Enabled: getEnvBool("DEBUG_ENDPOINTS_ENABLED", false),
+			Token:   getEnv("DEBUG_TOKEN", ""),
+		},
+		Auth: AuthConfig{
+			Enabled:     getEnvBool("AUTH_ENABLED", false),
+			IssuerURL:   getEnv("OIDC_ISSUER_URL", ""),
+			Audience:    getEnv("OIDC_AUDIENCE", ""),
+			JWKSURL:     getEnv("OIDC_JWKS_URL", ""),
+			RolesClaim:  getEnv("OIDC_ROLES_CLAIM", "roles"),
+			DefaultRole: getEnv("AUTH_DEFAULT_ROLE", "viewer"),
+			TenantClaim: getEnv("OIDC_TENANT_CLAIM", "tenant"),
+		},
+		Audit: AuditConfig{
+			Enabled:   getEnvBool("AUDIT_ENABLED", true),
+			DBPath:    getEnv("AUDIT_DB_PATH", ""),
+			Retention: getEnvDuration("AUDIT_RETENTION", 0),
+		},
+		BodyLimit: BodyLimitConfig{
+			MaxUploadSize:   getEnv("MAX_UPLOAD_SIZE", "5GB"),
+			MaxJSONBodySize: getEnv("MAX_JSON_BODY_SIZE", "10MB"),
+			UploadMemory:    getEnv("UPLOAD_MEMORY_SIZE", "32MB"),
+			Endpoints:       getEnv("BODY_LIMIT_ENDPOINTS", ""),
+		},
+		Tenancy: TenancyConfig{
+			Enabled: getEnvBool("TENANCY_ENABLED", false),
+			Tenants: getEnv("TENANTS", ""),
+			Header:  getEnv("TENANT_HEADER", "X-Tenant"),
+		},
+		Usage: UsageConfig{
+			Enabled: getEnvBool("USAGE_ENABLED", false),
+			DBPath:  getEnv("USAGE_DB_PATH", ""),
+			Quotas:  getEnv("USAGE_QUOTAS", ""),
+		},
+		Idempotency: IdempotencyConfig{
+			Enabled: getEnvBool("IDEMPOTENCY_ENABLED", true),
+			TTL:     getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		},
+		Quarantine: QuarantineConfig{
+			Enabled:   getEnvBool("QUARANTINE_ENABLED", false),
+			Prefix:    getEnv("QUARANTINE_PREFIX", "quarantine/"),
+			Threshold: getEnvInt("QUARANTINE_THRESHOLD", 3),
+			Move:      getEnvBool("QUARANTINE_MOVE", false),
+		},
+		ObjectCache: ObjectCacheConfig{
+			Dir:     getEnv("OBJECT_CACHE_DIR", ""),
+			Size:    getEnv("OBJECT_CACHE_SIZE", ""),
+			MinSize: getEnv("OBJECT_CACHE_MIN_SIZE", "1MB"),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:        getEnvBool("RATE_LIMIT_ENABLED", false),
+			RPS:            getEnvFloat("RATE_LIMIT_RPS", 20),
+			Burst:          getEnvInt("RATE_LIMIT_BURST", 40),
+			ExpensiveRPS:   getEnvFloat("RATE_LIMIT_EXPENSIVE_RPS", 0.5),
+			ExpensiveBurst: getEnvInt("RATE_LIMIT_EXPENSIVE_BURST", 5),
+			TrustProxy:     getEnvBool("RATE_LIMIT_TRUST_PROXY", false),
 		},
 	}
 
+	if config.Debug.Enabled && config.Debug.Token == "" {
+		return nil, fmt.Errorf("DEBUG_ENDPOINTS_ENABLED needs DEBUG_TOKEN")
+	}
+
+	if (config.Server.TLSCert == "") != (config.Server.TLSKey == "") {
+		return nil, fmt.Errorf("SERVER_TLS_CERT and SERVER_TLS_KEY must be set together")
+	}
+
+	for key, size := range map[string]string{
+		"MAX_UPLOAD_SIZE":          config.BodyLimit.MaxUploadSize,
+		"MAX_JSON_BODY_SIZE":       config.BodyLimit.MaxJSONBodySize,
+		"UPLOAD_MEMORY_SIZE":       config.BodyLimit.UploadMemory,
+		"JOB_TEMP_DISK_LIMIT":      config.Processing.JobTempDiskLimit,
+		"JOB_MEMORY_LIMIT":         config.Processing.JobMemoryLimit,
+		"TEMP_MIN_FREE":            config.Processing.TempMinFree,
+		"OBJECT_CACHE_SIZE":        config.ObjectCache.Size,
+		"MINIO_DOWNLOAD_THRESHOLD": config.MinIO.DownloadThreshold,
+		"MINIO_DOWNLOAD_PART_SIZE": config.MinIO.DownloadPartSize,
+		"OBJECT_CACHE_MIN_SIZE":    config.ObjectCache.MinSize,
+	} {
+		if _, err := ParseByteSize(size); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	if _, err := config.BodyLimit.EndpointLimits(); err != nil {
+		return nil, fmt.Errorf("BODY_LIMIT_ENDPOINTS: %w", err)
+	}
+	if _, err := config.Tenancy.ParseTenants(); err != nil {
+		return nil, fmt.Errorf("TENANTS: %w", err)
+	}
+	if _, err := config.Usage.ParseQuotas(); err != nil {
+		return nil, fmt.Errorf("USAGE_QUOTAS: %w", err)
+	}
+	if _, err := config.Processing.ParseConversionRates(); err != nil {
+		return nil, fmt.Errorf("CONVERSION_RATES: %w", err)
+	}
+	if _, err := config.Processing.Decompression.ParseAutoExtract(); err != nil {
+		return nil, fmt.Errorf("AUTO_EXTRACT: %w", err)
+	}
+
+	switch config.MinIO.Client.Trace {
+	case MinIOTraceOff, MinIOTraceErrors, MinIOTraceAll:
+	default:
+		return nil, fmt.Errorf("unknown MINIO_TRACE %q", config.MinIO.Client.Trace)
+	}
+
+	switch config.Server.Mode {
+	case RunModeAll, RunModeWorker:
+	case RunModeAPI:
+		// Nothing would process the jobs of an unshared queue
+		if !config.Processing.Queue.IsDistributed() {
+			return nil, fmt.Errorf("RUN_MODE=api needs QUEUE_BACKEND=redis")
+		}
+	default:
+		return nil, fmt.Errorf("unknown RUN_MODE %q", config.Server.Mode)
+	}
+
 	if err := os.MkdirAll(config.Processing.TempDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create temp directory: %w", err)
 	}
 
+	if config.Watcher.Bucket == "" {
+		config.Watcher.Bucket = config.MinIO.Bucket
+	}
+
+	if config.Watcher.DBPath == "" {
+		config.Watcher.DBPath = filepath.Join(config.Processing.TempDir, "watcher.db")
+	}
+
+	if config.Watcher.AutoJobsFile == "" {
+		config.Watcher.AutoJobsFile = filepath.Join(config.Processing.TempDir, "auto_jobs.json")
+	}
+
+	if config.Nessie.PresetsFile == "" {
+		config.Nessie.PresetsFile = filepath.Join(config.Processing.TempDir, "export_presets.json")
+	}
+
+	if config.Audit.DBPath == "" {
+		config.Audit.DBPath = filepath.Join(config.Processing.TempDir, "audit.db")
+	}
+
+	if config.Usage.DBPath == "" {
+		config.Usage.DBPath = filepath.Join(config.Processing.TempDir, "usage.db")
+	}
+
+	if config.ObjectCache.Dir == "" {
+		config.ObjectCache.Dir = filepath.Join(config.Processing.TempDir, "object-cache")
+	}
+
+	if config.Processing.StateFile == "" {
+		config.Processing.StateFile = filepath.Join(config.Processing.TempDir, "job_state.json")
+	}
+
 	return config, nil
 }
 
@@ -99,6 +861,39 @@ func (c *Config) GetServerAddr() string {
 	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port)
 }
 
+// TLSEnabled reports whether the server serves HTTPS itself.
+func (c *ServerConfig) TLSEnabled() bool {
+	return c.TLSCert != "" && c.TLSKey != ""
+}
+
+// IsDistributed reports whether the queue is shared with other instances,
+// in which case pending jobs already outlive a restart.
+func (c *QueueConfig) IsDistributed() bool {
+	return c.Backend == QueueBackendRedis
+}
+
+// AllowedOriginList parses AllowedOrigins.
+func (c *ServerConfig) AllowedOriginList() []string {
+	var origins []string
+	for _, origin := range strings.Split(c.AllowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// AllowedBucketNames parses AllowedBuckets.
+func (c *MinIOConfig) AllowedBucketNames() []string {
+	var names []string
+	for _, name := range strings.Split(c.AllowedBuckets, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 func (c *MinIOConfig) UseSSL() bool {
 	return len(c.Endpoint) > 8 && c.Endpoint[:8] == "https://"
 }
@@ -128,6 +923,15 @@ func getEnvBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoadRunMode(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+
+	tests := []struct {
+		mode, queue string
+		wantErr     bool
+	}{
+		{"", "", false},
+		{RunModeWorker, QueueBackendMemory, false},
+		{RunModeAPI, QueueBackendRedis, false},
+		{RunModeAPI, QueueBackendMemory, true}, // Nothing would process its jobs
+		{"both", "", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("RUN_MODE", tt.mode)
+		t.Setenv("QUEUE_BACKEND", tt.queue)
+		if _, err := Load(); (err != nil) != tt.wantErr {
+			t.Errorf("RUN_MODE=%q QUEUE_BACKEND=%q: err = %v, want error %v", tt.mode, tt.queue, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadDebugNeedsToken(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	t.Setenv("DEBUG_ENDPOINTS_ENABLED", "true")
+
+	if _, err := Load(); err == nil {
+		t.Error("debug endpoints without DEBUG_TOKEN: want error")
+	}
+	t.Setenv("DEBUG_TOKEN", "secret")
+	if _, err := Load(); err != nil {
+		t.Errorf("with DEBUG_TOKEN: %v", err)
+	}
+}
+
+func TestLoadMinIOTrace(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+
+	for trace, wantErr := range map[string]bool{"": false, MinIOTraceErrors: false, MinIOTraceAll: false, "verbose": true} {
+		t.Setenv("MINIO_TRACE", trace)
+		if _, err := Load(); (err != nil) != wantErr {
+			t.Errorf("MINIO_TRACE=%q: err = %v, want error %v", trace, err, wantErr)
+		}
+	}
+}
+
+func TestLoadExtractionLimits(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := cfg.Processing.Decompression
+	if size, _ := ParseByteSize(limits.MaxExtractSize); size == 0 || limits.MaxFilesPerArchive == 0 || limits.MaxCompressionRatio == 0 {
+		t.Errorf("default extraction limits %q, %d files, %g:1; want all bounded", limits.MaxExtractSize, limits.MaxFilesPerArchive, limits.MaxCompressionRatio)
+	}
+
+	// 0 opts out of each
+	t.Setenv("MAX_EXTRACT_SIZE", "0")
+	t.Setenv("MAX_FILES_PER_ARCHIVE", "0")
+	t.Setenv("MAX_COMPRESSION_RATIO", "0")
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	limits = cfg.Processing.Decompression
+	if size, _ := ParseByteSize(limits.MaxExtractSize); size != 0 || limits.MaxFilesPerArchive != 0 || limits.MaxCompressionRatio != 0 {
+		t.Errorf("extraction limits set to 0: %q, %d files, %g:1", limits.MaxExtractSize, limits.MaxFilesPerArchive, limits.MaxCompressionRatio)
+	}
+}
+
+func TestParseTenants(t *testing.T) {
+	tenants, err := TenancyConfig{Tenants: "a=bronze-a, b=shared/teams/b/:team_b"}.ParseTenants()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Tenant{
+		{Name: "a", Bucket: "bronze-a", Namespace: "a"},
+		{Name: "b", Bucket: "shared", Prefix: "teams/b/", Namespace: "team_b"},
+	}
+	if len(tenants) != len(want) || tenants[0] != want[0] || tenants[1] != want[1] {
+		t.Errorf("ParseTenants() = %+v, want %+v", tenants, want)
+	}
+
+	for _, spec := range []string{"a", "=bucket", "a=", "a=x,a=y"} {
+		if _, err := (TenancyConfig{Tenants: spec}).ParseTenants(); err == nil {
+			t.Errorf("ParseTenants(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestParseQuotas(t *testing.T) {
+	quotas, err := UsageConfig{Quotas: "upload=1GB/2GB, rows=/1000, job_time=90m"}.ParseQuotas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Quotas{
+		Upload:  Quota{Soft: 1 << 30, Hard: 2 << 30},
+		Rows:    Quota{Hard: 1000},
+		JobTime: Quota{Hard: 90 * 60 * 1000},
+	}
+	if quotas != want {
+		t.Errorf("ParseQuotas() = %+v, want %+v", quotas, want)
+	}
+
+	for _, spec := range []string{"upload", "cpu=1h", "rows=ten", "rows=-1", "upload=2GB/1GB"} {
+		if _, err := (UsageConfig{Quotas: spec}).ParseQuotas(); err == nil {
+			t.Errorf("ParseQuotas(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestParseAutoExtract(t *testing.T) {
+	rules, err := DecompressionConfig{AutoExtract: "incoming/zips/=extracted/:delete, /uploads/"}.ParseAutoExtract()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []AutoExtractRule{
+		{Prefix: "incoming/zips/", Destination: "extracted", Cleanup: CleanupDelete},
+		{Prefix: "uploads/", Cleanup: CleanupKeep},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("ParseAutoExtract() = %+v, want %+v", rules, want)
+	}
+
+	for _, spec := range []string{"=out", "a/:move", "a/=../out", "a/,a/=b"} {
+		if _, err := (DecompressionConfig{AutoExtract: spec}).ParseAutoExtract(); err == nil {
+			t.Errorf("ParseAutoExtract(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestParseConversionRates(t *testing.T) {
+	rates, err := ProcessingConfig{ConversionRates: "EUR/USD=1.08, km/m = 1000"}.ParseConversionRates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 2 || rates["EUR/USD"] != 1.08 || rates["KM/M"] != 1000 {
+		t.Errorf("ParseConversionRates() = %v", rates)
+	}
+
+	for _, spec := range []string{"EUR=1", "EUR/=1", "EUR/USD", "EUR/USD=0", "EUR/USD=-2", "EUR/USD=x"} {
+		if _, err := (ProcessingConfig{ConversionRates: spec}).ParseConversionRates(); err == nil {
+			t.Errorf("ParseConversionRates(%q) succeeded, want an error", spec)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+// Manager holds the running configuration and reloads it when the .env file
+// changes. Settings with a registered handler are applied at once; the rest
+// take effect on the next restart.
+type Manager struct {
+	envFile string
+
+	mu      sync.Mutex
+	current *Config
+	env     map[string]string // .env values as of the last load
+	live    []liveSetting
+}
+
+type liveSetting struct {
+	keys  []string
+	apply func(*Config)
+}
+
+// ReloadResult lists the .env keys a reload found changed.
+type ReloadResult struct {
+	Applied         []string `json:"applied"`          // In effect now
+	RestartRequired []string `json:"restart_required"` // In effect after a restart
+}
+
+// NewManager returns a Manager for cfg, loaded from the environment and
+// envFile.
+func NewManager(cfg *Config, envFile string) *Manager {
+	env, err := readEnvFile(envFile)
+	if err != nil {
+		log.Printf("Warning: Failed to read %s: %v", envFile, err)
+	}
+	return &Manager{
+		envFile: envFile,
+		current: cfg,
+		env:     env,
+	}
+}
+
+// Current returns the configuration as of the last successful load.
+func (m *Manager) Current() *Config {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current
+}
+
+// OnChange registers apply to be called with the new configuration when a
+// reload changes any of keys, which are then reported as applied.
+func (m *Manager) OnChange(apply func(*Config), keys ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.live = append(m.live, liveSetting{keys: keys, apply: apply})
+}
+
+// Reload reads the .env file again and applies the keys that changed since
+// the last load. Changed keys override the process environment, as if the
+// server had been started with the new file. An invalid configuration is
+// rejected and the current one stays in use.
+func (m *Manager) Reload() (*ReloadResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reload()
+}
+
+// Values returns the value in effect for every setting, from the
+// environment or the default, with secrets redacted.
+func (m *Manager) Values() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	values := make(map[string]string, len(settings))
+	for _, s := range settings {
+		values[s.Key] = getEnv(s.Key, s.Default)
+	}
+	return Redact(values)
+}
+
+// Update validates updates, writes them to the .env file and reloads it.
+// Secrets sent back as RedactedSecret keep their value. If the result does
+// not load, the file is restored and the error returned.
+func (m *Manager) Update(updates map[string]string) (*ReloadResult, error) {
+	if err := ValidateUpdates(updates); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	original, err := os.ReadFile(m.envFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", m.envFile, err)
+	}
+	existed := err == nil
+
+	changes := make(map[string]string, len(updates))
+	for key, value := range updates {
+		if setting, _ := LookupSetting(key); setting.Secret && value == RedactedSecret {
+			continue
+		}
+		changes[key] = strings.TrimSpace(value)
+	}
+	if err := os.WriteFile(m.envFile, updateEnvLines(original, changes), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", m.envFile, err)
+	}
+
+	result, err := m.reload()
+	if err != nil {
+		if existed {
+			os.WriteFile(m.envFile, original, 0644)
+		} else {
+			os.Remove(m.envFile)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSetting, err)
+	}
+	return result, nil
+}
+
+func (m *Manager) reload() (*ReloadResult, error) {
+	env, err := readEnvFile(m.envFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", m.envFile, err)
+	}
+
+	result := &ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+	changed := changedKeys(m.env, env)
+	if len(changed) == 0 {
+		return result, nil
+	}
+
+	setEnv(changed, env)
+	cfg, err := Load()
+	if err != nil {
+		setEnv(changed, m.env)
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	applied := make(map[string]bool)
+	for _, setting := range m.live {
+		if !slices.ContainsFunc(setting.keys, func(key string) bool { return slices.Contains(changed, key) }) {
+			continue
+		}
+		setting.apply(cfg)
+		for _, key := range setting.keys {
+			applied[key] = true
+		}
+	}
+	for _, key := range changed {
+		if applied[key] {
+			result.Applied = append(result.Applied, key)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, key)
+		}
+	}
+
+	m.current = cfg
+	m.env = env
+	log.Printf("Configuration reloaded: applied %v, restart required for %v", result.Applied, result.RestartRequired)
+	return result, nil
+}
+
+// updateEnvLines sets the keys in changes in the .env file content, replacing
+// their lines in place and appending new keys in order. Comments and other
+// lines are kept.
+func updateEnvLines(content []byte, changes map[string]string) []byte {
+	var lines []string
+	if len(content) > 0 {
+		lines = strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	}
+
+	written := make(map[string]bool)
+	for i, line := range lines {
+		key, _, ok := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "export "), "=")
+		key = strings.TrimSpace(key)
+		if value, changed := changes[key]; ok && changed {
+			lines[i] = key + "=" + quoteEnvValue(value)
+			written[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		if !written[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, key+"="+quoteEnvValue(changes[key]))
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// quoteEnvValue quotes values that would otherwise be cut short or changed
+// when the .env file is read. Single quotes keep the value as it is; double
+// quotes would expand $VARIABLES.
+func quoteEnvValue(value string) string {
+	if !strings.ContainsAny(value, " \t#'\"\\$") {
+		return value
+	}
+	if !strings.Contains(value, "'") {
+		return "'" + value + "'"
+	}
+	return strconv.Quote(value)
+}
+
+// readEnvFile returns the values in the .env file, or none if it does not
+// exist.
+func readEnvFile(path string) (map[string]string, error) {
+	env, err := godotenv.Read(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	return env, err
+}
+
+// changedKeys returns the sorted keys added, removed or changed between two
+// sets of .env values.
+func changedKeys(previous, current map[string]string) []string {
+	var changed []string
+	for key, value := range current {
+		if old, ok := previous[key]; !ok || old != value {
+			changed = append(changed, key)
+		}
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// setEnv sets each of keys in the process environment to its value in env,
+// unsetting those env does not have.
+func setEnv(keys []string, env map[string]string) {
+	for _, key := range keys {
+		if value, ok := env[key]; ok {
+			os.Setenv(key, value)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
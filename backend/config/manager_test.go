@@ -0,0 +1,100 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeEnvFile(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestManagerReload(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	// Registered for cleanup so the reload's changes are undone
+	t.Setenv("TEMP_DIR", dir)
+	t.Setenv("MAX_WORKERS", "3")
+	t.Setenv("MINIO_BUCKET", "files")
+	t.Setenv("SERVER_TLS_CERT", "")
+	writeEnvFile(t, envFile, "MAX_WORKERS=3", "MINIO_BUCKET=files")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(cfg, envFile)
+
+	workers := 0
+	m.OnChange(func(c *Config) { workers = c.Processing.MaxWorkers }, "MAX_WORKERS")
+
+	if result, err := m.Reload(); err != nil || len(result.Applied)+len(result.RestartRequired) != 0 {
+		t.Fatalf("unchanged file: Reload = %+v, %v", result, err)
+	}
+
+	writeEnvFile(t, envFile, "MAX_WORKERS=8", "MINIO_BUCKET=archive")
+	result, err := m.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(result.Applied, ",") != "MAX_WORKERS" || strings.Join(result.RestartRequired, ",") != "MINIO_BUCKET" {
+		t.Errorf("Reload = %+v, want MAX_WORKERS applied and MINIO_BUCKET pending a restart", result)
+	}
+	if workers != 8 || m.Current().Processing.MaxWorkers != 8 || m.Current().MinIO.Bucket != "archive" {
+		t.Errorf("workers = %d, current = %+v", workers, m.Current().Processing)
+	}
+
+	// An invalid file leaves the configuration and environment alone
+	writeEnvFile(t, envFile, "MAX_WORKERS=2", "MINIO_BUCKET=archive", "SERVER_TLS_CERT=cert.pem")
+	if _, err := m.Reload(); err == nil {
+		t.Fatal("certificate without a key accepted")
+	}
+	if workers != 8 || os.Getenv("MAX_WORKERS") != "8" || os.Getenv("SERVER_TLS_CERT") != "" {
+		t.Errorf("failed reload applied changes: workers = %d, MAX_WORKERS=%q", workers, os.Getenv("MAX_WORKERS"))
+	}
+}
+
+func TestManagerUpdate(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	t.Setenv("TEMP_DIR", dir)
+	t.Setenv("MINIO_SECRET_KEY", "")
+	t.Setenv("EXTRACT_PREFIX", "")
+	t.Setenv("SERVER_TLS_CERT", "")
+	writeEnvFile(t, envFile, "# MinIO", "MINIO_SECRET_KEY=hunter2")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(cfg, envFile)
+
+	// The redacted secret sent back by the UI keeps the saved one
+	if _, err := m.Update(map[string]string{"MINIO_SECRET_KEY": RedactedSecret, "EXTRACT_PREFIX": "my archives"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(envFile)
+	if want := "# MinIO\nMINIO_SECRET_KEY=hunter2\nEXTRACT_PREFIX='my archives'\n"; string(data) != want {
+		t.Errorf(".env = %q, want %q", data, want)
+	}
+	if got := m.Current().Processing.Decompression.ExtractPrefix; got != "my archives" {
+		t.Errorf("ExtractPrefix = %q", got)
+	}
+	if got := m.Values()["MINIO_SECRET_KEY"]; got != RedactedSecret {
+		t.Errorf("Values shows secret as %q", got)
+	}
+
+	// A file that would not load is put back
+	if _, err := m.Update(map[string]string{"SERVER_TLS_CERT": "cert.pem"}); !errors.Is(err, ErrInvalidSetting) {
+		t.Fatalf("certificate without key: got %v, want ErrInvalidSetting", err)
+	}
+	if restored, _ := os.ReadFile(envFile); string(restored) != string(data) {
+		t.Errorf(".env not restored: %q", restored)
+	}
+}
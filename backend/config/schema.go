@@ -0,0 +1,379 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSetting is returned for configuration updates with unknown keys
+// or values that do not parse.
+var ErrInvalidSetting = errors.New("invalid setting")
+
+// RedactedSecret replaces secret values in API responses. Sending it back in
+// an update keeps the saved value.
+const RedactedSecret = "********"
+
+// SettingType says how a setting's value is parsed.
+type SettingType string
+
+const (
+	TypeString   SettingType = "string"
+	TypeInt      SettingType = "int"
+	TypeFloat    SettingType = "float"
+	TypeBool     SettingType = "bool"
+	TypeDuration SettingType = "duration" // e.g. 30s, 5m
+	TypeSize     SettingType = "size"     // e.g. 500MB, see ParseByteSize
+	TypePort     SettingType = "port"
+)
+
+// Setting describes one environment variable read by Load. Numbers and
+// durations may not be negative; Positive also rules out zero. An empty
+// value always means the default.
+type Setting struct {
+	Key      string      `json:"key"`
+	Type     SettingType `json:"type"`
+	Default  string      `json:"default"`
+	Secret   bool        `json:"secret,omitempty"`
+	Options  []string    `json:"options,omitempty"` // Allowed values, when limited
+	Positive bool        `json:"positive,omitempty"`
+	Max      float64     `json:"max,omitempty"` // Upper bound for numbers, 0 for none
+}
+
+var settings = []Setting{
+	{Key: "SERVER_HOST", Type: TypeString, Default: "localhost"},
+	{Key: "SERVER_PORT", Type: TypePort, Default: "8060"},
+	{Key: "RUN_MODE", Type: TypeString, Default: RunModeAll, Options: []string{RunModeAll, RunModeAPI, RunModeWorker}},
+	{Key: "SERVER_TLS_CERT", Type: TypeString},
+	{Key: "SERVER_TLS_KEY", Type: TypeString},
+	{Key: "SERVER_TLS_RELOAD_INTERVAL", Type: TypeDuration, Default: "1m"},
+	{Key: "SERVER_ALLOWED_ORIGINS", Type: TypeString},
+
+	{Key: "MINIO_ENDPOINT", Type: TypeString, Default: "localhost:9000"},
+	{Key: "MINIO_ACCESS_KEY", Type: TypeString, Default: "minioadmin"},
+	{Key: "MINIO_SECRET_KEY", Type: TypeString, Default: "minioadmin", Secret: true},
+	{Key: "MINIO_BUCKET", Type: TypeString, Default: "files"},
+	{Key: "MINIO_REGION", Type: TypeString, Default: "us-east-1"},
+	{Key: "MINIO_ALLOWED_BUCKETS", Type: TypeString},
+	{Key: "MINIO_DOWNLOAD_THRESHOLD", Type: TypeSize, Default: "256MB"},
+	{Key: "MINIO_DOWNLOAD_PART_SIZE", Type: TypeSize, Default: "16MB"},
+	{Key: "MINIO_DOWNLOAD_CONCURRENCY", Type: TypeInt, Default: "4", Positive: true, Max: 64},
+	{Key: "MINIO_MAX_IDLE_CONNS", Type: TypeInt, Default: "256", Positive: true},
+	{Key: "MINIO_MAX_IDLE_CONNS_PER_HOST", Type: TypeInt, Default: "16", Positive: true},
+	{Key: "MINIO_DIAL_TIMEOUT", Type: TypeDuration, Default: "30s"},
+	{Key: "MINIO_TLS_HANDSHAKE_TIMEOUT", Type: TypeDuration, Default: "10s"},
+	{Key: "MINIO_RESPONSE_HEADER_TIMEOUT", Type: TypeDuration, Default: "1m"},
+	{Key: "MINIO_IDLE_CONN_TIMEOUT", Type: TypeDuration, Default: "1m"},
+	{Key: "MINIO_MAX_RETRIES", Type: TypeInt, Default: "3", Max: 20},
+	{Key: "MINIO_TRACE", Type: TypeString, Default: MinIOTraceOff, Options: []string{MinIOTraceOff, MinIOTraceErrors, MinIOTraceAll}},
+
+	{Key: "MAX_WORKERS", Type: TypeInt, Default: "3", Positive: true},
+	{Key: "QUEUE_SIZE", Type: TypeInt, Default: "100", Positive: true},
+	{Key: "JOB_HISTORY", Type: TypeInt, Default: "1000", Positive: true},
+	{Key: "JOB_TEMP_DISK_LIMIT", Type: TypeSize},
+	{Key: "JOB_MEMORY_LIMIT", Type: TypeSize},
+	{Key: "WATCH_INTERVAL", Type: TypeDuration, Default: "5s", Positive: true},
+	{Key: "TEMP_DIR", Type: TypeString, Default: "/tmp/bronze"},
+	{Key: "TEMP_MIN_FREE", Type: TypeSize},
+	{Key: "TEMP_CLEAN_INTERVAL", Type: TypeDuration, Default: "15m"},
+	{Key: "TEMP_ORPHAN_AGE", Type: TypeDuration, Default: "1h", Positive: true},
+	{Key: "JOB_STATE_FILE", Type: TypeString},
+	{Key: "CONVERSION_RATES", Type: TypeString},
+
+	{Key: "AUTOSCALE_ENABLED", Type: TypeBool, Default: "false"},
+	{Key: "AUTOSCALE_MIN_WORKERS", Type: TypeInt, Default: "1", Positive: true},
+	{Key: "AUTOSCALE_MAX_WORKERS", Type: TypeInt, Default: "10", Positive: true},
+	{Key: "AUTOSCALE_INTERVAL", Type: TypeDuration, Default: "15s", Positive: true},
+	{Key: "AUTOSCALE_TARGET_WAIT", Type: TypeDuration, Default: "30s", Positive: true},
+
+	{Key: "QUEUE_BACKEND", Type: TypeString, Default: QueueBackendMemory, Options: []string{QueueBackendMemory, QueueBackendRedis}},
+	{Key: "REDIS_URL", Type: TypeString, Default: "redis://localhost:6379/0", Secret: true},
+	{Key: "QUEUE_PREFIX", Type: TypeString, Default: "bronze"},
+	{Key: "QUEUE_CONSUMER", Type: TypeString},
+	{Key: "QUEUE_VISIBILITY_TIMEOUT", Type: TypeDuration, Default: "5m", Positive: true},
+
+	{Key: "WEBHOOK_URL", Type: TypeString},
+	{Key: "WEBHOOK_SECRET", Type: TypeString, Secret: true},
+	{Key: "WEBHOOK_TIMEOUT", Type: TypeDuration, Default: "10s", Positive: true},
+	{Key: "WEBHOOK_MAX_RETRIES", Type: TypeInt, Default: "3"},
+	{Key: "WEBHOOK_ALLOWED_HOSTS", Type: TypeString},
+
+	{Key: "DECOMPRESSION_ENABLED", Type: TypeBool, Default: "true"},
+	{Key: "MAX_EXTRACT_SIZE", Type: TypeSize, Default: "10GB"},
+	{Key: "MAX_FILES_PER_ARCHIVE", Type: TypeInt, Default: "10000"},
+	{Key: "MAX_COMPRESSION_RATIO", Type: TypeFloat, Default: "100"},
+	{Key: "NESTED_ARCHIVE_DEPTH", Type: TypeInt, Default: "0"},
+	{Key: "PASSWORD_PROTECTED", Type: TypeBool, Default: "true"},
+	{Key: "EXTRACT_TO_SUBFOLDER", Type: TypeBool, Default: "true"},
+	{Key: "EXTRACT_PREFIX", Type: TypeString},
+	{Key: "STREAM_EXTRACT_THRESHOLD", Type: TypeSize},
+	{Key: "AUTO_EXTRACT", Type: TypeString},
+
+	{Key: "NESSIE_ENDPOINT", Type: TypeString, Default: "http://localhost:19120/api/v1"},
+	{Key: "NESSIE_NAMESPACE", Type: TypeString, Default: "warehouse"},
+	{Key: "NESSIE_AUTH_TOKEN", Type: TypeString, Secret: true},
+	{Key: "NESSIE_DEFAULT_DB", Type: TypeString, Default: "bronze_warehouse"},
+	{Key: "NESSIE_BATCH_SIZE", Type: TypeInt, Default: "1000", Positive: true},
+	{Key: "NESSIE_RETRY_INTERVAL", Type: TypeDuration, Default: "10s", Positive: true},
+	{Key: "EXPORT_PRESETS_FILE", Type: TypeString},
+	{Key: "NESSIE_SILVER_NAMESPACE", Type: TypeString, Default: "silver"},
+	{Key: "EXPORT_SCHEMA_DRIFT", Type: TypeString, Default: SchemaDriftWarn, Options: []string{SchemaDriftWarn, SchemaDriftBlock, SchemaDriftOff}},
+	{Key: "NESSIE_TIMEOUT", Type: TypeDuration, Default: "30s", Positive: true},
+	{Key: "NESSIE_MAX_RETRIES", Type: TypeInt, Default: "3"},
+	{Key: "NESSIE_RETRY_BACKOFF", Type: TypeDuration, Default: "500ms", Positive: true},
+	{Key: "NESSIE_MAX_IDLE_CONNS", Type: TypeInt, Default: "16", Positive: true},
+	{Key: "NESSIE_BREAKER_THRESHOLD", Type: TypeInt, Default: "5"},
+	{Key: "NESSIE_BREAKER_COOLDOWN", Type: TypeDuration, Default: "30s", Positive: true},
+	{Key: "NESSIE_CATALOG_CACHE_TTL", Type: TypeDuration, Default: "1m"},
+
+	{Key: "WATCHER_ENABLED", Type: TypeBool, Default: "true"},
+	{Key: "WATCHER_MODE", Type: TypeString, Default: WatcherModePoll, Options: []string{WatcherModePoll, WatcherModeNotify}},
+	{Key: "WATCHER_BUCKET", Type: TypeString},
+	{Key: "WATCHER_PREFIX", Type: TypeString},
+	{Key: "WATCHER_RULES_FILE", Type: TypeString},
+	{Key: "WATCHER_STORAGE", Type: TypeString, Default: WatcherStorageMemory, Options: []string{WatcherStorageMemory, WatcherStorageSQLite}},
+	{Key: "WATCHER_DB_PATH", Type: TypeString},
+	{Key: "WATCHER_RETENTION", Type: TypeDuration, Default: "168h"},
+	{Key: "WATCHER_AUTO_JOBS_FILE", Type: TypeString},
+	{Key: "WATCHER_DEBOUNCE", Type: TypeDuration, Default: "2s"},
+
+	{Key: "OTEL_EXPORTER_OTLP_ENDPOINT", Type: TypeString},
+	{Key: "OTEL_SERVICE_NAME", Type: TypeString, Default: "bronze-backend"},
+	{Key: "TRACING_SAMPLE_RATIO", Type: TypeFloat, Default: "1", Max: 1},
+
+	{Key: "DEBUG_ENDPOINTS_ENABLED", Type: TypeBool, Default: "false"},
+	{Key: "DEBUG_TOKEN", Type: TypeString, Secret: true},
+
+	{Key: "AUTH_ENABLED", Type: TypeBool, Default: "false"},
+	{Key: "OIDC_ISSUER_URL", Type: TypeString},
+	{Key: "OIDC_AUDIENCE", Type: TypeString},
+	{Key: "OIDC_JWKS_URL", Type: TypeString},
+	{Key: "OIDC_ROLES_CLAIM", Type: TypeString, Default: "roles"},
+	{Key: "AUTH_DEFAULT_ROLE", Type: TypeString, Default: "viewer", Options: []string{"viewer", "editor", "admin"}},
+	{Key: "OIDC_TENANT_CLAIM", Type: TypeString, Default: "tenant"},
+
+	{Key: "AUDIT_ENABLED", Type: TypeBool, Default: "true"},
+	{Key: "AUDIT_DB_PATH", Type: TypeString},
+	{Key: "AUDIT_RETENTION", Type: TypeDuration, Default: "0s"},
+
+	{Key: "RATE_LIMIT_ENABLED", Type: TypeBool, Default: "false"},
+	{Key: "RATE_LIMIT_RPS", Type: TypeFloat, Default: "20", Positive: true},
+	{Key: "RATE_LIMIT_BURST", Type: TypeInt, Default: "40", Positive: true},
+	{Key: "RATE_LIMIT_EXPENSIVE_RPS", Type: TypeFloat, Default: "0.5", Positive: true},
+	{Key: "RATE_LIMIT_EXPENSIVE_BURST", Type: TypeInt, Default: "5", Positive: true},
+	{Key: "RATE_LIMIT_TRUST_PROXY", Type: TypeBool, Default: "false"},
+
+	{Key: "MAX_UPLOAD_SIZE", Type: TypeSize, Default: "5GB"},
+	{Key: "MAX_JSON_BODY_SIZE", Type: TypeSize, Default: "10MB"},
+	{Key: "UPLOAD_MEMORY_SIZE", Type: TypeSize, Default: "32MB"},
+	{Key: "BODY_LIMIT_ENDPOINTS", Type: TypeString},
+
+	{Key: "TENANCY_ENABLED", Type: TypeBool, Default: "false"},
+	{Key: "TENANTS", Type: TypeString},
+	{Key: "TENANT_HEADER", Type: TypeString, Default: "X-Tenant"},
+
+	{Key: "USAGE_ENABLED", Type: TypeBool, Default: "false"},
+	{Key: "USAGE_DB_PATH", Type: TypeString},
+	{Key: "USAGE_QUOTAS", Type: TypeString},
+
+	{Key: "IDEMPOTENCY_ENABLED", Type: TypeBool, Default: "true"},
+	{Key: "IDEMPOTENCY_TTL", Type: TypeDuration, Default: "24h", Positive: true},
+
+	{Key: "QUARANTINE_ENABLED", Type: TypeBool, Default: "false"},
+	{Key: "QUARANTINE_PREFIX", Type: TypeString, Default: "quarantine/"},
+	{Key: "QUARANTINE_THRESHOLD", Type: TypeInt, Default: "3", Positive: true},
+	{Key: "QUARANTINE_MOVE", Type: TypeBool, Default: "false"},
+
+	{Key: "OBJECT_CACHE_DIR", Type: TypeString},
+	{Key: "OBJECT_CACHE_SIZE", Type: TypeSize},
+	{Key: "OBJECT_CACHE_MIN_SIZE", Type: TypeSize, Default: "1MB"},
+}
+
+// Settings returns every setting Load reads, in documentation order.
+func Settings() []Setting {
+	return slices.Clone(settings)
+}
+
+// LookupSetting returns the setting for an environment variable.
+func LookupSetting(key string) (Setting, bool) {
+	for _, s := range settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Validate checks that value parses as the setting's type and is in range.
+func (s Setting) Validate(value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	if len(s.Options) > 0 && !slices.Contains(s.Options, value) {
+		return fmt.Errorf("must be one of %s", strings.Join(s.Options, ", "))
+	}
+
+	var number float64
+	switch s.Type {
+	case TypeInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be a whole number")
+		}
+		number = float64(n)
+	case TypeFloat:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		number = n
+	case TypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		return nil
+	case TypeDuration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("must be a duration such as 30s or 5m")
+		}
+		number = d.Seconds()
+	case TypeSize:
+		if _, err := ParseByteSize(value); err != nil {
+			return fmt.Errorf("must be a size such as 500MB or 1GB")
+		}
+		return nil
+	case TypePort:
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("must be a port between 1 and 65535")
+		}
+		return nil
+	default:
+		return nil
+	}
+
+	if number < 0 {
+		return fmt.Errorf("must not be negative")
+	}
+	if s.Positive && number == 0 {
+		return fmt.Errorf("must be greater than zero")
+	}
+	if s.Max > 0 && number > s.Max {
+		return fmt.Errorf("must be at most %g", s.Max)
+	}
+	return nil
+}
+
+// ValidateUpdates checks every key and value of a configuration update,
+// reporting all problems at once.
+func ValidateUpdates(updates map[string]string) error {
+	var problems []string
+	for key, value := range updates {
+		setting, ok := LookupSetting(key)
+		if !ok {
+			problem := "unknown setting " + key
+			if suggestion := closestKey(key); suggestion != "" {
+				problem += fmt.Sprintf(" (did you mean %s?)", suggestion)
+			}
+			problems = append(problems, problem)
+			continue
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			problems = append(problems, key+" must be a single line")
+			continue
+		}
+		if setting.Secret && value == RedactedSecret {
+			continue
+		}
+		if err := setting.Validate(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s %v", key, err))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("%w: %s", ErrInvalidSetting, strings.Join(problems, "; "))
+}
+
+// Redact returns values with the secret ones replaced by RedactedSecret.
+func Redact(values map[string]string) map[string]string {
+	redacted := make(map[string]string, len(values))
+	for key, value := range values {
+		if setting, ok := LookupSetting(key); ok && setting.Secret && value != "" {
+			value = RedactedSecret
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+// closestKey returns the known key nearest to an unknown one, if any is
+// close enough to be a typo.
+func closestKey(key string) string {
+	key = strings.ToUpper(strings.TrimSpace(key))
+	best, bestDistance := "", 4
+	for _, s := range settings {
+		if d := editDistance(key, s.Key); d < bestDistance {
+			best, bestDistance = s.Key, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+var byteSizeUnits = map[string]int64{
+	"":   1,
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+// ParseByteSize parses sizes like "500MB" or "1GB" (binary units). An empty
+// value, "0" or "unlimited" means no limit and returns 0.
+func ParseByteSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" || value == "0" || value == "UNLIMITED" {
+		return 0, nil
+	}
+
+	number := strings.TrimRight(value, "KMGTB ")
+	multiplier, ok := byteSizeUnits[strings.TrimSpace(value[len(number):])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
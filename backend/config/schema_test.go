@@ -0,0 +1,115 @@
+package config
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestSettingsCoverLoad keeps the schema in step with the variables Load
+// reads.
+func TestSettingsCoverLoad(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "config.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		if fn, ok := call.Fun.(*ast.Ident); ok && strings.HasPrefix(fn.Name, "getEnv") {
+			if lit, ok := call.Args[0].(*ast.BasicLit); ok {
+				key, _ := strconv.Unquote(lit.Value)
+				read[key] = true
+			}
+		}
+		return true
+	})
+
+	for key := range read {
+		if _, ok := LookupSetting(key); !ok {
+			t.Errorf("%s is read by Load but missing from the schema", key)
+		}
+	}
+	for _, s := range Settings() {
+		if !read[s.Key] {
+			t.Errorf("%s is in the schema but not read by Load", s.Key)
+		}
+		if err := s.Validate(s.Default); err != nil {
+			t.Errorf("%s default %q is invalid: %v", s.Key, s.Default, err)
+		}
+	}
+}
+
+func TestValidateUpdates(t *testing.T) {
+	valid := map[string]string{
+		"SERVER_PORT":          "9090",
+		"WATCH_INTERVAL":       "30s",
+		"MAX_EXTRACT_SIZE":     "1.5GB",
+		"QUEUE_BACKEND":        "redis",
+		"TRACING_SAMPLE_RATIO": "0.25",
+		"MINIO_SECRET_KEY":     RedactedSecret,
+		"EXTRACT_PREFIX":       "",
+	}
+	if err := ValidateUpdates(valid); err != nil {
+		t.Errorf("valid update rejected: %v", err)
+	}
+
+	tests := map[string]string{
+		"SERVER_PORT":          "70000",
+		"MAX_WORKERS":          "0",
+		"WATCH_INTERVAL":       "5 seconds",
+		"MAX_EXTRACT_SIZE":     "lots",
+		"PASSWORD_PROTECTED":   "maybe",
+		"WATCHER_MODE":         "push",
+		"TRACING_SAMPLE_RATIO": "2",
+		"NESTED_ARCHIVE_DEPTH": "-1",
+		"MINIO_BUCKET":         "files\nAUTH_ENABLED=false",
+	}
+	for key, value := range tests {
+		err := ValidateUpdates(map[string]string{key: value})
+		if !errors.Is(err, ErrInvalidSetting) || !strings.Contains(err.Error(), key) {
+			t.Errorf("%s=%q: got %v, want an invalid setting error naming the key", key, value, err)
+		}
+	}
+
+	err := ValidateUpdates(map[string]string{"MAX_WORKER": "4"})
+	if err == nil || !strings.Contains(err.Error(), "did you mean MAX_WORKERS?") {
+		t.Errorf("unknown key: got %v, want a suggestion", err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	got := Redact(map[string]string{"MINIO_SECRET_KEY": "hunter2", "DEBUG_TOKEN": "", "MINIO_BUCKET": "files"})
+	if got["MINIO_SECRET_KEY"] != RedactedSecret || got["DEBUG_TOKEN"] != "" || got["MINIO_BUCKET"] != "files" {
+		t.Errorf("Redact = %v", got)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]int64{
+		"":          0,
+		"0":         0,
+		"unlimited": 0,
+		"512":       512,
+		"10KB":      10 << 10,
+		"1.5 MB":    3 << 19,
+		"2gb":       2 << 30,
+	}
+	for input, want := range tests {
+		got, err := ParseByteSize(input)
+		if err != nil || got != want {
+			t.Errorf("ParseByteSize(%q) = %d, %v; want %d", input, got, err, want)
+		}
+	}
+	if _, err := ParseByteSize("ten megs"); err == nil {
+		t.Error("Expected an error for an invalid size")
+	}
+}
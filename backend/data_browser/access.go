@@ -0,0 +1,114 @@
+package data_browser
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"bronze-backend/httputil"
+)
+
+// accessEngine is the database engine an Access file was written by.
+type accessEngine int
+
+const (
+	engineJet accessEngine = iota // .mdb, Access 97 to 2003
+	engineACE                     // .accdb, Access 2007 and later
+)
+
+func (e accessEngine) String() string {
+	if e == engineACE {
+		return "ACCDB"
+	}
+	return "MDB"
+}
+
+// Every Access file starts with its engine's signature at offset 4.
+var (
+	jetSignature = []byte("Standard Jet DB")
+	aceSignature = []byte("Standard ACE DB")
+)
+
+// detectAccessEngine tells Jet and ACE files apart by their signature
+// rather than their extension, which may be wrong.
+func detectAccessEngine(data []byte) (accessEngine, error) {
+	if len(data) >= 4+len(aceSignature) {
+		switch signature := data[4 : 4+len(aceSignature)]; {
+		case bytes.Equal(signature, jetSignature):
+			return engineJet, nil
+		case bytes.Equal(signature, aceSignature):
+			return engineACE, nil
+		}
+	}
+	return 0, httputil.NewError(httputil.CodeInvalidDatabase, "file is not an Access database", nil)
+}
+
+// accessConnection is a database/sql driver and the DSN it opens a file with.
+type accessConnection struct {
+	driver string
+	dsn    string
+}
+
+// accessConnections returns the ways to open the file at path, in the order
+// they are tried. The Jet 4.0 provider only reads MDB files; ACCDB files
+// need the Access Database Engine (ACE) driver, which also reads MDB.
+func accessConnections(engine accessEngine, path string) []accessConnection {
+	ace := fmt.Sprintf("Driver={Microsoft Access Driver (*.mdb, *.accdb)};Dbq=%s;", path)
+	connections := []accessConnection{{"access", ace}, {"odbc", ace}}
+	if engine == engineJet {
+		jet := fmt.Sprintf("Provider=Microsoft.Jet.OLEDB.4.0;Data Source=%s;", path)
+		connections = append([]accessConnection{{"mssql", jet}}, connections...)
+	}
+	return connections
+}
+
+// openAccessDB writes an Access file to a temporary file and opens it with
+// the first driver that can read its format. The returned function closes
+// the database and removes the file.
+func openAccessDB(data []byte, ext string) (*sql.DB, func(), error) {
+	engine, err := detectAccessEngine(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tempFile, err := os.CreateTemp("", "tempdb_*"+ext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer tempFile.Close()
+	if _, err := tempFile.Write(data); err != nil {
+		os.Remove(tempFile.Name())
+		return nil, nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	tempFile.Close()
+
+	var errs []error
+	for _, c := range accessConnections(engine, tempFile.Name()) {
+		if !slices.Contains(sql.Drivers(), c.driver) {
+			continue
+		}
+		db, err := sql.Open(c.driver, c.dsn)
+		if err == nil {
+			err = db.Ping()
+		}
+		if err != nil {
+			if db != nil {
+				db.Close()
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", c.driver, err))
+			continue
+		}
+		return db, func() {
+			db.Close()
+			os.Remove(tempFile.Name())
+		}, nil
+	}
+
+	os.Remove(tempFile.Name())
+	return nil, nil, httputil.NewError(httputil.CodeUnsupportedDB,
+		fmt.Sprintf("%s files cannot be opened on this server: the Microsoft Access Database Engine ODBC driver is required", engine),
+		errors.Join(errs...))
+}
@@ -0,0 +1,68 @@
+package data_browser
+
+import (
+	"errors"
+	"testing"
+
+	"bronze-backend/httputil"
+)
+
+// accessHeader returns the start of an Access file with signature.
+func accessHeader(signature string) []byte {
+	return append([]byte{0x00, 0x01, 0x00, 0x00}, signature+"\x00"...)
+}
+
+func TestDetectAccessEngine(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data []byte
+		want accessEngine
+		code httputil.Code
+	}{
+		{"jet", accessHeader("Standard Jet DB"), engineJet, ""},
+		{"ace", accessHeader("Standard ACE DB"), engineACE, ""},
+		{"csv", []byte("id,name\n1,a\n"), 0, httputil.CodeInvalidDatabase},
+		{"empty", nil, 0, httputil.CodeInvalidDatabase},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectAccessEngine(tt.data)
+			var apiErr *httputil.APIError
+			if tt.code != "" {
+				if !errors.As(err, &apiErr) || apiErr.Code != tt.code {
+					t.Errorf("error = %v, want code %s", err, tt.code)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("detectAccessEngine() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessConnections(t *testing.T) {
+	drivers := func(engine accessEngine) []string {
+		var names []string
+		for _, c := range accessConnections(engine, "/tmp/db") {
+			names = append(names, c.driver)
+		}
+		return names
+	}
+	if got := drivers(engineJet); len(got) != 3 || got[0] != "mssql" {
+		t.Errorf("MDB drivers = %v, want the Jet provider first", got)
+	}
+	for _, c := range accessConnections(engineACE, "/tmp/db") {
+		if c.driver == "mssql" {
+			t.Error("ACCDB files are opened with the Jet provider, which cannot read them")
+		}
+	}
+}
+
+func TestOpenAccessDBWithoutDriver(t *testing.T) {
+	// No Access driver is built into the tests
+	_, _, err := openAccessDB(accessHeader("Standard ACE DB"), ".accdb")
+	var apiErr *httputil.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != httputil.CodeUnsupportedDB {
+		t.Errorf("error = %v, want code %s", err, httputil.CodeUnsupportedDB)
+	}
+}
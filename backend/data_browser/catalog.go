@@ -0,0 +1,79 @@
+package data_browser
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bronze-backend/httputil"
+	"bronze-backend/storage"
+)
+
+// pageOptions reads the limit, page_token and refresh parameters of a
+// catalog listing.
+func pageOptions(r *http.Request) (storage.NessiePageOptions, error) {
+	query := r.URL.Query()
+	options := storage.NessiePageOptions{
+		PageToken: query.Get("page_token"),
+		Fresh:     query.Get("refresh") == "true",
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return options, errors.New("limit must be a positive integer")
+		}
+		options.Limit = n
+	}
+	return options, nil
+}
+
+// ListNamespaces answers a page of the Nessie namespaces.
+func (h *ExportHandler) ListNamespaces(w http.ResponseWriter, r *http.Request) {
+	if !h.requireNessie(w) {
+		return
+	}
+	options, err := pageOptions(r)
+	if err != nil {
+		httputil.WriteError(w, err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	page, err := h.nessieClient.Load().ListNamespaces(r.Context(), options)
+	if err != nil {
+		httputil.WriteCode(w, httputil.CodeNessieError, "Failed to list namespaces", err)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]any{
+		"success":         true,
+		"namespaces":      page.Namespaces,
+		"next_page_token": page.NextPageToken,
+	})
+}
+
+// ListTables answers a page of the tables of a database.
+func (h *ExportHandler) ListTables(w http.ResponseWriter, r *http.Request) {
+	if !h.requireNessie(w) {
+		return
+	}
+	options, err := pageOptions(r)
+	if err != nil {
+		httputil.WriteError(w, err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+	database := r.URL.Query().Get("database")
+	if database == "" {
+		database = h.config.Nessie.DefaultDB
+	}
+
+	page, err := h.nessieClient.Load().ListTables(r.Context(), database, options)
+	if err != nil {
+		httputil.WriteCode(w, httputil.CodeNessieError, "Failed to list tables", err)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]any{
+		"success":         true,
+		"database":        database,
+		"tables":          page.Tables,
+		"next_page_token": page.NextPageToken,
+	})
+}
@@ -0,0 +1,64 @@
+package data_browser
+
+import (
+	"slices"
+	"strings"
+)
+
+// CleanOptions tidy the values read from a file. Rows hold NULL as an empty
+// string, which the Parquet writer and the export write as NULL.
+type CleanOptions struct {
+	// NullTokens are values read as NULL, such as "NA", "NULL" or "-".
+	// They match case-insensitively and ignoring surrounding whitespace.
+	NullTokens []string `json:"null_tokens,omitempty"`
+	// TrimWhitespace removes leading and trailing whitespace
+	TrimWhitespace bool `json:"trim_whitespace,omitempty"`
+	// CollapseWhitespace replaces every run of whitespace, line breaks
+	// included, with one space, and trims
+	CollapseWhitespace bool `json:"collapse_whitespace,omitempty"`
+	// Normalize reads columns as amounts, after the options above
+	Normalize []Normalization `json:"normalize,omitempty"`
+}
+
+func (o CleanOptions) enabled() bool {
+	return len(o.NullTokens) > 0 || o.TrimWhitespace || o.CollapseWhitespace
+}
+
+// clean returns value as the options read it.
+func (o CleanOptions) clean(value string) string {
+	switch {
+	case o.CollapseWhitespace:
+		value = strings.Join(strings.Fields(value), " ")
+	case o.TrimWhitespace:
+		value = strings.TrimSpace(value)
+	}
+	if o.isNull(value) {
+		return ""
+	}
+	return value
+}
+
+func (o CleanOptions) isNull(value string) bool {
+	value = strings.TrimSpace(value)
+	return slices.ContainsFunc(o.NullTokens, func(token string) bool {
+		return strings.EqualFold(strings.TrimSpace(token), value)
+	})
+}
+
+// apply cleans the column names and rows of response in place, converting
+// amounts with rates. Column names are never read as NULL.
+func (o CleanOptions) apply(response *BrowseResponse, rates map[string]float64) error {
+	if o.enabled() {
+		names := o
+		names.NullTokens = nil
+		for i, column := range response.Columns {
+			response.Columns[i] = names.clean(column)
+		}
+		for _, row := range response.Rows {
+			for i, value := range row {
+				row[i] = o.clean(value)
+			}
+		}
+	}
+	return applyNormalizations(response, o.Normalize, rates)
+}
@@ -0,0 +1,49 @@
+package data_browser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCleanOptions(t *testing.T) {
+	csv := "  id ,name,  note\n1,NA,\" two  words\t\"\n2, null ,-\n3,n/a2,\"line\nbreak\"\n"
+	read := func(options CleanOptions) BrowseResponse {
+		t.Helper()
+		response, err := (&DataBrowserHandler{}).readData([]byte(csv), BrowseRequest{
+			FileName:     "data.csv",
+			MaxRows:      100,
+			HasHeaders:   true,
+			CleanOptions: options,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	response := read(CleanOptions{NullTokens: []string{"NA", "NULL", "-"}, CollapseWhitespace: true})
+	if want := []string{"id", "name", "note"}; !slices.Equal(response.Columns, want) {
+		t.Errorf("columns = %q, want %q", response.Columns, want)
+	}
+	want := [][]string{
+		{"1", "", "two words"},
+		{"2", "", ""},
+		{"3", "n/a2", "line break"},
+	}
+	if !slices.EqualFunc(response.Rows, want, slices.Equal) {
+		t.Errorf("rows = %q, want %q", response.Rows, want)
+	}
+
+	response = read(CleanOptions{TrimWhitespace: true})
+	if got := response.Rows[0][2]; got != "two  words" {
+		t.Errorf("trimmed value = %q, want inner whitespace kept", got)
+	}
+	if got := response.Rows[0][1]; got != "NA" {
+		t.Errorf("value = %q, want NA kept without null tokens", got)
+	}
+
+	response = read(CleanOptions{})
+	if got := response.Rows[0][2]; got != " two  words\t" {
+		t.Errorf("value = %q, want it unchanged by default", got)
+	}
+}
@@ -0,0 +1,243 @@
+package data_browser
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bronze-backend/httputil"
+	"bronze-backend/tenant"
+)
+
+// ColumnLineagePrefix is where the column lineage of tables is stored in
+// MinIO, at lineage/tables/{database}/{table}.json below the tenant's
+// prefix.
+const ColumnLineagePrefix = "lineage/tables/"
+
+// How an exported column was made from a source column
+const (
+	TransformCopy      = "copy"
+	TransformRename    = "rename"    // By rename_columns, or suffixed as a repeated name
+	TransformNormalize = "normalize" // By normalize_column_names
+	TransformDerived   = "derived"   // Used by the expression of a derived column
+)
+
+// ColumnSource is a column of a file that went into a table column.
+type ColumnSource struct {
+	File      string `json:"file"`
+	Sheet     string `json:"sheet,omitempty"`
+	Column    string `json:"column"`
+	Transform string `json:"transform"`
+}
+
+// ColumnLineage is where a table column came from, over every export to
+// the table since it was created.
+type ColumnLineage struct {
+	Column     string         `json:"column"`
+	Sources    []ColumnSource `json:"sources"`
+	Expression string         `json:"expression,omitempty"` // Of a derived column
+	ExportID   string         `json:"export_id"`            // The last export writing the column
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// TableLineage is the column lineage of a table.
+type TableLineage struct {
+	Database string          `json:"database"`
+	Table    string          `json:"table"`
+	Columns  []ColumnLineage `json:"columns"`
+}
+
+// traceColumns returns the source columns of the files read behind each
+// column they export as under rules, by lowercased name. It runs before
+// applyColumnRules renames the columns.
+func traceColumns(results []ProcessingResult, rules ColumnRules) map[string][]ColumnSource {
+	renamed := make(map[string]bool, len(rules.RenameColumns))
+	for source := range rules.RenameColumns {
+		renamed[strings.ToLower(source)] = true
+	}
+
+	traced := make(map[string][]ColumnSource)
+	for _, result := range results {
+		if !result.Success {
+			continue
+		}
+		columnMap := NewColumnMapperWithRules(result.Columns, rules).GetColumnMap()
+		for _, source := range result.Columns {
+			target, ok := columnMap[source]
+			if !ok {
+				continue // Excluded
+			}
+			transform := TransformCopy
+			switch {
+			case renamed[strings.ToLower(source)]:
+				transform = TransformRename
+			case rules.NormalizeColumnNames && target != source:
+				transform = TransformNormalize
+			case target != source:
+				transform = TransformRename
+			}
+			key := strings.ToLower(target)
+			traced[key] = appendSource(traced[key], ColumnSource{File: result.FileName, Sheet: result.SheetName, Column: source, Transform: transform})
+		}
+	}
+	return traced
+}
+
+// retraceColumn moves the sources traced to a column of result over to the
+// name it was renamed to after tracing.
+func retraceColumn(traced map[string][]ColumnSource, result ProcessingResult, from, to string) {
+	fromKey, toKey := strings.ToLower(from), strings.ToLower(to)
+	if fromKey == toKey {
+		return
+	}
+	var kept []ColumnSource
+	for _, source := range traced[fromKey] {
+		if source.File == result.FileName && source.Sheet == result.SheetName {
+			traced[toKey] = appendSource(traced[toKey], source)
+		} else {
+			kept = append(kept, source)
+		}
+	}
+	if len(kept) == 0 {
+		delete(traced, fromKey)
+	} else {
+		traced[fromKey] = kept
+	}
+}
+
+// appendSource adds source to sources unless it is there already.
+func appendSource(sources []ColumnSource, source ColumnSource) []ColumnSource {
+	if slices.Contains(sources, source) {
+		return sources
+	}
+	return append(sources, source)
+}
+
+// derivedInputs returns the columns expression uses.
+func derivedInputs(expression string) []string {
+	var inputs []string
+	record := func(name string) (any, error) {
+		inputs = append(inputs, name)
+		return nil, nil
+	}
+	evaluateDerived(expression, record, derivedFunctions("", "", time.Time{}))
+	return inputs
+}
+
+// buildColumnLineage returns the lineage of the table columns an export
+// wrote, from the sources traceColumns found. A derived column's sources
+// are those of the columns its expression uses.
+func buildColumnLineage(columns []string, traced map[string][]ColumnSource, derived []DerivedColumn, exportID string, now time.Time) []ColumnLineage {
+	sources := make(map[string][]ColumnSource, len(traced)+len(derived))
+	for key, list := range traced {
+		sources[key] = list
+	}
+	expressions := make(map[string]string, len(derived))
+	for _, column := range derived {
+		key := strings.ToLower(column.Name)
+		var list []ColumnSource
+		for _, input := range derivedInputs(column.Expression) {
+			for _, source := range sources[strings.ToLower(input)] {
+				source.Transform = TransformDerived
+				list = appendSource(list, source)
+			}
+		}
+		sources[key] = list
+		expressions[key] = column.Expression
+	}
+
+	lineage := make([]ColumnLineage, 0, len(columns))
+	for _, column := range columns {
+		key := strings.ToLower(column)
+		expression, isDerived := expressions[key]
+		list, ok := sources[key]
+		if !ok && !isDerived {
+			continue // Not written by the export
+		}
+		if list == nil {
+			list = []ColumnSource{} // A derived column using no columns
+		}
+		lineage = append(lineage, ColumnLineage{
+			Column:     column,
+			Sources:    list,
+			Expression: expression,
+			ExportID:   exportID,
+			UpdatedAt:  now,
+		})
+	}
+	return lineage
+}
+
+// mergeColumnLineage adds the lineage of an append export to that of the
+// table's earlier exports.
+func mergeColumnLineage(existing, update []ColumnLineage) []ColumnLineage {
+	merged := slices.Clone(existing)
+	for _, column := range update {
+		i := slices.IndexFunc(merged, func(c ColumnLineage) bool { return strings.EqualFold(c.Column, column.Column) })
+		if i < 0 {
+			merged = append(merged, column)
+			continue
+		}
+		for _, source := range column.Sources {
+			merged[i].Sources = appendSource(merged[i].Sources, source)
+		}
+		if column.Expression != "" {
+			merged[i].Expression = column.Expression
+		}
+		merged[i].ExportID = column.ExportID
+		merged[i].UpdatedAt = column.UpdatedAt
+	}
+	return merged
+}
+
+// columnLineageObject returns the object of the column lineage of a table.
+func columnLineageObject(ctx context.Context, database, table string) string {
+	return tenant.Prefix(ctx) + ColumnLineagePrefix + database + "/" + table + ".json"
+}
+
+// saveColumnLineage stores the lineage of the columns an export wrote,
+// replacing that of a table it created.
+func (h *ExportHandler) saveColumnLineage(ctx context.Context, database, table string, created bool, columns []ColumnLineage) error {
+	key := columnLineageObject(ctx, database, table)
+	lineage := TableLineage{Database: database, Table: table}
+	if !created {
+		// A table without lineage was created before it was recorded
+		h.browser.readJSON(ctx, key, &lineage)
+	}
+	lineage.Columns = mergeColumnLineage(lineage.Columns, columns)
+	return h.browser.storeJSON(ctx, key, lineage)
+}
+
+// GetColumnLineage answers which source columns, and transforms, made each
+// column of a table. The database defaults to NESSIE_DEFAULT_DB.
+func (h *ExportHandler) GetColumnLineage(w http.ResponseWriter, r *http.Request) {
+	table := mux.Vars(r)["table"]
+	database := r.URL.Query().Get("database")
+	if database == "" {
+		database = h.config.Nessie.DefaultDB
+	}
+	if strings.ContainsAny(database+table, "/\\") {
+		httputil.WriteError(w, "Database and table must not contain path separators", http.StatusBadRequest, nil)
+		return
+	}
+
+	var lineage TableLineage
+	if err := h.browser.readJSON(r.Context(), columnLineageObject(r.Context(), database, table), &lineage); err != nil {
+		httputil.WriteCode(w, httputil.CodeNotFound, "No column lineage recorded for the table", err)
+		return
+	}
+	if column := r.URL.Query().Get("column"); column != "" {
+		lineage.Columns = slices.DeleteFunc(lineage.Columns, func(c ColumnLineage) bool { return !strings.EqualFold(c.Column, column) })
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, map[string]any{
+		"success":  true,
+		"database": lineage.Database,
+		"table":    lineage.Table,
+		"columns":  lineage.Columns,
+	})
+}
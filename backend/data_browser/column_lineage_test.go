@@ -0,0 +1,67 @@
+package data_browser
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBuildColumnLineage(t *testing.T) {
+	results := []ProcessingResult{
+		{FileName: "a.csv", Success: true, Columns: []string{"ID", "Unit Price", "qty", "note"}},
+		{FileName: "b.xlsx", SheetName: "May", Success: true, Columns: []string{"id", "Price"}},
+		{FileName: "c.csv", Columns: []string{"skipped"}},
+	}
+	rules := ColumnRules{
+		ExcludeColumns:       []string{"note"},
+		RenameColumns:        map[string]string{"Price": "unit_price"},
+		NormalizeColumnNames: true,
+	}
+	traced := traceColumns(results, rules)
+	derived := []DerivedColumn{{Name: "total", Expression: "unit_price * qty"}, {Name: "loaded_at", Expression: "NOW()"}}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	lineage := buildColumnLineage([]string{"id", "unit_price", "qty", "total", "loaded_at"}, traced, derived, "e1", now)
+	byColumn := make(map[string]ColumnLineage)
+	for _, column := range lineage {
+		byColumn[column.Column] = column
+	}
+	if len(lineage) != 5 {
+		t.Fatalf("lineage = %+v", lineage)
+	}
+
+	want := map[string][]ColumnSource{
+		"id": {
+			{File: "a.csv", Column: "ID", Transform: TransformNormalize},
+			{File: "b.xlsx", Sheet: "May", Column: "id", Transform: TransformCopy},
+		},
+		"unit_price": {
+			{File: "a.csv", Column: "Unit Price", Transform: TransformNormalize},
+			{File: "b.xlsx", Sheet: "May", Column: "Price", Transform: TransformRename},
+		},
+		"total": {
+			{File: "a.csv", Column: "Unit Price", Transform: TransformDerived},
+			{File: "b.xlsx", Sheet: "May", Column: "Price", Transform: TransformDerived},
+			{File: "a.csv", Column: "qty", Transform: TransformDerived},
+		},
+		"loaded_at": {},
+	}
+	for column, sources := range want {
+		if got := byColumn[column].Sources; !slices.Equal(got, sources) {
+			t.Errorf("%s sources = %+v, want %+v", column, got, sources)
+		}
+	}
+	if byColumn["total"].Expression != "unit_price * qty" || byColumn["id"].ExportID != "e1" {
+		t.Errorf("lineage = %+v", lineage)
+	}
+
+	// An append adds its sources to those of earlier exports
+	later := buildColumnLineage([]string{"id"}, traceColumns([]ProcessingResult{{FileName: "d.csv", Success: true, Columns: []string{"id"}}}, ColumnRules{}), nil, "e2", now)
+	merged := mergeColumnLineage(lineage, later)
+	if len(merged) != 5 || len(merged[0].Sources) != 3 || merged[0].ExportID != "e2" || merged[1].ExportID != "e1" {
+		t.Errorf("merged = %+v", merged)
+	}
+	if len(lineage[0].Sources) != 2 {
+		t.Error("merging changed the earlier lineage")
+	}
+}
@@ -3,9 +3,15 @@ package data_browser
 import (
 	"fmt"
 	"regexp"
-	"strconv"
+	"slices"
 	"strings"
 	"time"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
 )
 
 type ColumnMismatch struct {
@@ -43,17 +49,99 @@ type ColumnMapper struct {
 	targetColumns      []string
 	columnMap          map[string]string // source -> target
 	mismatches         []ColumnMismatch
-	caseSensitive      bool
+	options            MatchOptions
 	autoTypeConversion bool
 	transformRules     []ColumnTransform
+	// targetSources holds the source index of each target column, for
+	// mappers made by NewColumnMapperWithRules
+	targetSources []int
+}
+
+// DefaultMatchThreshold is the similarity a fuzzy column match needs when
+// MatchOptions sets no threshold.
+const DefaultMatchThreshold = 0.8
+
+// MatchOptions control how a ColumnMapper matches source columns to target
+// columns. Names are compared with surrounding and repeated spaces removed,
+// in Unicode NFC form; the zero value also ignores case.
+type MatchOptions struct {
+	CaseSensitive bool `json:"case_sensitive,omitempty"`
+	// NormalizeUnicode compares names in NFKC form instead, so
+	// compatibility characters such as full-width letters or "²" match
+	// their plain forms
+	NormalizeUnicode bool `json:"normalize_unicode,omitempty"`
+	// FoldAccents compares letters without their accents: "Montant Dû"
+	// matches "montant du"
+	FoldAccents bool `json:"fold_accents,omitempty"`
+	// Strategy names the SimilarityStrategy scoring columns against
+	// targets of other names; it defaults to StrategyLevenshtein
+	Strategy string `json:"strategy,omitempty"`
+	// Threshold is the score, from 0 to 1, a column needs to match a target
+	// of another name. It defaults to DefaultMatchThreshold
+	Threshold float64 `json:"threshold,omitempty"`
+}
+
+// Validate reports options that cannot be used.
+func (o MatchOptions) Validate() error {
+	if o.Threshold < 0 || o.Threshold > 1 {
+		return fmt.Errorf("column_matching threshold must be between 0 and 1, got %g", o.Threshold)
+	}
+	if _, ok := o.strategy(); !ok {
+		return fmt.Errorf("unknown column_matching strategy %q, use one of %s", o.Strategy, strings.Join(SimilarityStrategies(), ", "))
+	}
+	return nil
+}
+
+func (o MatchOptions) strategy() (SimilarityStrategy, bool) {
+	if o.Strategy == "" {
+		return similarityStrategy(StrategyLevenshtein)
+	}
+	return similarityStrategy(o.Strategy)
+}
+
+func (o MatchOptions) threshold() float64 {
+	if o.Threshold == 0 {
+		return DefaultMatchThreshold
+	}
+	return o.Threshold
+}
+
+var accentFolder = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// key returns the form of name columns are compared in.
+func (o MatchOptions) key(name string) string {
+	name = strings.Join(strings.Fields(name), " ")
+	if o.NormalizeUnicode {
+		name = norm.NFKC.String(name)
+	} else {
+		name = norm.NFC.String(name)
+	}
+	if o.FoldAccents {
+		if folded, _, err := transform.String(accentFolder, name); err == nil {
+			name = folded
+		}
+	}
+	if !o.CaseSensitive {
+		name = cases.Fold().String(name)
+	}
+	return name
 }
 
 func NewColumnMapper(sourceColumns, targetColumns []string, caseSensitive bool) *ColumnMapper {
+	return NewColumnMapperWithOptions(sourceColumns, targetColumns, MatchOptions{CaseSensitive: caseSensitive})
+}
+
+// NewColumnMapperWithOptions maps sourceColumns to targetColumns, matching
+// names as options say. Each target takes one source: equal names first,
+// then, in source order, the remaining target the strategy scores highest
+// at or above the threshold, the first one on a tie. Options are assumed
+// valid; an unknown strategy matches equal names only.
+func NewColumnMapperWithOptions(sourceColumns, targetColumns []string, options MatchOptions) *ColumnMapper {
 	mapper := &ColumnMapper{
 		sourceColumns:  sourceColumns,
 		targetColumns:  targetColumns,
 		columnMap:      make(map[string]string),
-		caseSensitive:  caseSensitive,
+		options:        options,
 		transformRules: make([]ColumnTransform, 0),
 		mismatches:     make([]ColumnMismatch, 0),
 	}
@@ -64,43 +152,46 @@ func NewColumnMapper(sourceColumns, targetColumns []string, caseSensitive bool)
 
 func (cm *ColumnMapper) generateMapping() {
 	targetColMap := cm.createColumnMap(cm.targetColumns)
+	claimed := make(map[string]bool, len(cm.targetColumns))
 
+	// Equal names first, so a fuzzy match cannot take their target
+	var unmatched []string
 	for _, sourceCol := range cm.sourceColumns {
-		sourceColKey := cm.normalizeColumnName(sourceCol)
-		targetCol, exists := targetColMap[sourceColKey]
-
-		if exists {
-			// Found exact or case-insensitive match
+		targetCol, exists := targetColMap[cm.normalizeColumnName(sourceCol)]
+		if exists && !claimed[targetCol] {
 			cm.columnMap[sourceCol] = targetCol
+			claimed[targetCol] = true
 		} else {
-			// Try fuzzy matching
-			if match := cm.findFuzzyMatch(sourceCol, cm.targetColumns); match != "" {
-				cm.columnMap[sourceCol] = match
-				cm.mismatches = append(cm.mismatches, ColumnMismatch{
-					ColumnName:   sourceCol,
-					MismatchType: "case_diff",
-					SourceType:   "VARCHAR",
-					TargetType:   "VARCHAR",
-					Severity:     "info",
-				})
-			} else {
-				// Extra column in source
-				cm.mismatches = append(cm.mismatches, ColumnMismatch{
-					ColumnName:   sourceCol,
-					MismatchType: "extra",
-					SourceType:   "VARCHAR",
-					TargetType:   "",
-					Severity:     "warning",
-				})
-			}
+			unmatched = append(unmatched, sourceCol)
+		}
+	}
+
+	for _, sourceCol := range unmatched {
+		if match := cm.findFuzzyMatch(sourceCol, cm.targetColumns, claimed); match != "" {
+			cm.columnMap[sourceCol] = match
+			claimed[match] = true
+			cm.mismatches = append(cm.mismatches, ColumnMismatch{
+				ColumnName:   sourceCol,
+				MismatchType: "case_diff",
+				SourceType:   "VARCHAR",
+				TargetType:   "VARCHAR",
+				Severity:     "info",
+			})
+		} else {
+			// Extra column in source
+			cm.mismatches = append(cm.mismatches, ColumnMismatch{
+				ColumnName:   sourceCol,
+				MismatchType: "extra",
+				SourceType:   "VARCHAR",
+				TargetType:   "",
+				Severity:     "warning",
+			})
 		}
 	}
 
 	// Check for missing target columns
-	sourceColMap := cm.createColumnMap(cm.sourceColumns)
 	for _, targetCol := range cm.targetColumns {
-		targetColKey := cm.normalizeColumnName(targetCol)
-		if _, exists := sourceColMap[targetColKey]; !exists {
+		if !claimed[targetCol] {
 			cm.mismatches = append(cm.mismatches, ColumnMismatch{
 				ColumnName:   targetCol,
 				MismatchType: "missing",
@@ -115,43 +206,36 @@ func (cm *ColumnMapper) generateMapping() {
 func (cm *ColumnMapper) createColumnMap(columns []string) map[string]string {
 	colMap := make(map[string]string)
 	for _, col := range columns {
-		colMap[cm.normalizeColumnName(col)] = col
+		key := cm.normalizeColumnName(col)
+		if _, exists := colMap[key]; !exists {
+			colMap[key] = col
+		}
 	}
 	return colMap
 }
 
 func (cm *ColumnMapper) normalizeColumnName(colName string) string {
-	if cm.caseSensitive {
-		return colName
-	}
-	return strings.ToLower(colName)
+	return cm.options.key(colName)
 }
 
-func (cm *ColumnMapper) findFuzzyMatch(sourceCol string, targetColumns []string) string {
-	sourceLower := strings.ToLower(sourceCol)
-
-	// Remove common prefixes/suffixes for matching
-	cleanSource := cm.cleanColumnName(sourceLower)
-
-	for _, targetCol := range targetColumns {
-		targetLower := strings.ToLower(targetCol)
-		cleanTarget := cm.cleanColumnName(targetLower)
-
-		if cleanSource == cleanTarget {
-			return targetCol
-		}
+// findFuzzyMatch returns the target, not yet claimed, the strategy scores
+// highest against sourceCol at or above the threshold, or "".
+func (cm *ColumnMapper) findFuzzyMatch(sourceCol string, targetColumns []string, claimed map[string]bool) string {
+	strategy, ok := cm.options.strategy()
+	if !ok {
+		return ""
 	}
+	source := cm.normalizeColumnName(sourceCol)
 
-	// Try Levenshtein distance for close matches
 	bestMatch := ""
-	bestDistance := len(sourceCol) // Max possible distance
-
+	bestScore := cm.options.threshold()
 	for _, targetCol := range targetColumns {
-		targetLower := strings.ToLower(targetCol)
-		distance := cm.levenshteinDistance(cleanSource, cm.cleanColumnName(targetLower))
-
-		if distance < bestDistance && distance <= 2 { // Allow up to 2 character differences
-			bestDistance = distance
+		if claimed[targetCol] {
+			continue
+		}
+		score := strategy.Score(source, cm.normalizeColumnName(targetCol))
+		if score > bestScore || (bestMatch == "" && score == bestScore) {
+			bestScore = score
 			bestMatch = targetCol
 		}
 	}
@@ -159,7 +243,23 @@ func (cm *ColumnMapper) findFuzzyMatch(sourceCol string, targetColumns []string)
 	return bestMatch
 }
 
-func (cm *ColumnMapper) cleanColumnName(colName string) string {
+var trailingDigits = regexp.MustCompile(`\d+$`)
+
+// cleanColumnName drops what tells apart names that likely mean the same
+// column: separators, common prefixes and suffixes, and trailing numbers.
+func cleanColumnName(colName string) string {
+	// Separate words by "_" whatever their separators: "Montant (€)" is
+	// "Montant_"
+	var b strings.Builder
+	for _, r := range colName {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else if !strings.HasSuffix(b.String(), "_") {
+			b.WriteByte('_')
+		}
+	}
+	colName = b.String()
+
 	// Remove common prefixes
 	prefixes := []string{"col_", "column_", "field_", "f_"}
 	for _, prefix := range prefixes {
@@ -179,13 +279,15 @@ func (cm *ColumnMapper) cleanColumnName(colName string) string {
 	}
 
 	// Remove numbers at end
-	re := regexp.MustCompile(`\d+$`)
-	colName = re.ReplaceAllString(colName, "")
+	colName = trailingDigits.ReplaceAllString(colName, "")
 
 	return strings.Trim(colName, "_")
 }
 
-func (cm *ColumnMapper) levenshteinDistance(s1, s2 string) int {
+// levenshteinDistance is the edit distance of a and b, a replacement
+// costing as much as a deletion and an insertion.
+func levenshteinDistance(a, b string) int {
+	s1, s2 := []rune(a), []rune(b)
 	if len(s1) < len(s2) {
 		s1, s2 = s2, s1
 	}
@@ -195,7 +297,10 @@ func (cm *ColumnMapper) levenshteinDistance(s1, s2 string) int {
 	}
 
 	prevRow := make([]int, len(s2)+1)
-	for i := 0; i <= len(s1); i++ {
+	for j := range prevRow {
+		prevRow[j] = j
+	}
+	for i := 1; i <= len(s1); i++ {
 		currentRow := make([]int, len(s2)+1)
 		currentRow[0] = i
 
@@ -212,7 +317,7 @@ func (cm *ColumnMapper) levenshteinDistance(s1, s2 string) int {
 			}
 
 			minCost := prevRow[j] + insertCost
-			if currentRow[j-1] < minCost {
+			if currentRow[j-1]+deleteCost < minCost {
 				minCost = currentRow[j-1] + deleteCost
 			}
 			if prevRow[j-1]+cost < minCost {
@@ -292,16 +397,9 @@ func (cm *ColumnMapper) convertValue(value interface{}, targetColumn string) (in
 
 	// Number conversion attempts
 	if cm.isNumericColumn(targetColumn) {
-		// Remove currency symbols, commas, etc.
-		cleanNumber := strings.Map(func(r rune) rune {
-			if strings.ContainsRune("0123456789.-", r) {
-				return r
-			}
-			return -1
-		}, strValue)
-
-		if cleaned, err := strconv.ParseFloat(cleanNumber, 64); err == nil {
-			return cleaned, nil
+		// Currency symbols, units and thousand separators are dropped
+		if amount, _, err := parseAmount(strValue, '.'); err == nil {
+			return amount, nil
 		}
 	}
 
@@ -367,3 +465,149 @@ func (cm *ColumnMapper) GetColumnMap() map[string]string {
 func (cm *ColumnMapper) AddTransformRule(transform ColumnTransform) {
 	cm.transformRules = append(cm.transformRules, transform)
 }
+
+// ColumnRules drop and rename the columns of exported files before the
+// table is created. Source columns are matched case-insensitively.
+type ColumnRules struct {
+	// ExcludeColumns are source columns left out of the export
+	ExcludeColumns []string `json:"exclude_columns,omitempty"`
+	// RenameColumns maps source columns to the names they export under,
+	// which are used as given
+	RenameColumns map[string]string `json:"rename_columns,omitempty"`
+	// NormalizeColumnNames exports the other columns under warehouse
+	// friendly names; see warehouseIdentifier
+	NormalizeColumnNames bool `json:"normalize_column_names,omitempty"`
+}
+
+// NewColumnMapperWithRules maps sourceColumns to the columns they export
+// as under rules, in source order. Excluded columns are left unmapped, and
+// names that repeat, ignoring case, get a "_2", "_3"... suffix.
+func NewColumnMapperWithRules(sourceColumns []string, rules ColumnRules) *ColumnMapper {
+	mapper := &ColumnMapper{
+		sourceColumns:  sourceColumns,
+		columnMap:      make(map[string]string),
+		transformRules: make([]ColumnTransform, 0),
+		mismatches:     make([]ColumnMismatch, 0),
+	}
+
+	renames := make(map[string]string, len(rules.RenameColumns))
+	for source, target := range rules.RenameColumns {
+		renames[strings.ToLower(source)] = target
+	}
+
+	seen := make(map[string]bool, len(sourceColumns))
+	for i, source := range sourceColumns {
+		if slices.ContainsFunc(rules.ExcludeColumns, func(excluded string) bool {
+			return strings.EqualFold(excluded, source)
+		}) {
+			continue
+		}
+
+		target, renamed := renames[strings.ToLower(source)]
+		if !renamed {
+			target = source
+			if rules.NormalizeColumnNames {
+				target = warehouseIdentifier(source)
+			}
+		}
+		if target == "" {
+			target = fmt.Sprintf("column_%d", i+1)
+		}
+		name := target
+		for n := 2; seen[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s_%d", target, n)
+		}
+		seen[strings.ToLower(name)] = true
+
+		mapper.columnMap[source] = name
+		mapper.targetColumns = append(mapper.targetColumns, name)
+		mapper.targetSources = append(mapper.targetSources, i)
+	}
+	return mapper
+}
+
+// TargetColumns returns the columns the source maps to, in source order.
+func (cm *ColumnMapper) TargetColumns() []string {
+	return cm.targetColumns
+}
+
+// ProjectRow returns the values of row for the mapper's target columns. It
+// is only valid for mappers made by NewColumnMapperWithRules.
+func (cm *ColumnMapper) ProjectRow(row []string) []string {
+	projected := make([]string, len(cm.targetSources))
+	for i, source := range cm.targetSources {
+		if source < len(row) {
+			projected[i] = row[source]
+		}
+	}
+	return projected
+}
+
+// reservedWords are SQL keywords that cannot name a column without quoting.
+var reservedWords = map[string]bool{
+	"all": true, "and": true, "any": true, "as": true, "asc": true, "between": true,
+	"by": true, "case": true, "cast": true, "check": true, "column": true,
+	"constraint": true, "create": true, "cross": true, "current": true,
+	"date": true, "default": true, "delete": true, "desc": true, "distinct": true,
+	"drop": true, "else": true, "end": true, "except": true, "exists": true,
+	"false": true, "fetch": true, "for": true, "foreign": true, "from": true,
+	"full": true, "grant": true, "group": true, "having": true, "in": true,
+	"inner": true, "insert": true, "intersect": true, "interval": true,
+	"into": true, "is": true, "join": true, "left": true, "like": true,
+	"limit": true, "not": true, "null": true, "of": true, "offset": true,
+	"on": true, "or": true, "order": true, "outer": true, "primary": true,
+	"references": true, "right": true, "row": true, "rows": true,
+	"select": true, "set": true, "table": true, "then": true, "time": true,
+	"timestamp": true, "to": true, "true": true, "union": true, "unique": true,
+	"update": true, "user": true, "using": true, "values": true, "when": true,
+	"where": true, "window": true, "with": true,
+}
+
+// warehouseIdentifier turns a column name into a snake_case identifier
+// that needs no quoting: "Order Date" and "OrderDate" become "order_date".
+// Characters other than ASCII letters and digits separate words, names
+// starting with a digit get a "col_" prefix and reserved words a "_"
+// suffix. It returns "" for names without letters or digits.
+func warehouseIdentifier(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	separate := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+			b.WriteByte('_')
+		}
+	}
+	for i, r := range runes {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			// Start a word at "oD" in "orderDate" and "rD" in "XMLData"
+			if i > 0 && (isLowerOrDigit(runes[i-1]) ||
+				isUpper(runes[i-1]) && i+1 < len(runes) && runes[i+1] >= 'a' && runes[i+1] <= 'z') {
+				separate()
+			}
+			b.WriteRune(r + 'a' - 'A')
+		case isLowerOrDigit(r):
+			b.WriteRune(r)
+		default:
+			separate()
+		}
+	}
+
+	identifier := strings.Trim(b.String(), "_")
+	switch {
+	case identifier == "":
+		return ""
+	case identifier[0] >= '0' && identifier[0] <= '9':
+		identifier = "col_" + identifier
+	case reservedWords[identifier]:
+		identifier += "_"
+	}
+	return identifier
+}
+
+func isUpper(r rune) bool {
+	return r >= 'A' && r <= 'Z'
+}
+
+func isLowerOrDigit(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= '0' && r <= '9'
+}
@@ -0,0 +1,113 @@
+package data_browser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestWarehouseIdentifier(t *testing.T) {
+	for name, want := range map[string]string{
+		"Order Date":     "order_date",
+		"OrderDate":      "order_date",
+		"customerID":     "customer_id",
+		"XMLData":        "xml_data",
+		"  Amount (USD)": "amount_usd",
+		"e-mail__addr":   "e_mail_addr",
+		"2023 Sales":     "col_2023_sales",
+		"Order":          "order_",
+		"Café":           "caf",
+		"%":              "",
+	} {
+		if got := warehouseIdentifier(name); got != want {
+			t.Errorf("warehouseIdentifier(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestApplyColumnRules(t *testing.T) {
+	results := []ProcessingResult{
+		{
+			Success: true,
+			Columns: []string{"ID", "Customer Name", "Internal Notes", "Name", "customer_name", ""},
+			Rows:    [][]string{{"1", "Ann", "x", "a", "b", "c"}, {"2"}},
+		},
+		{Success: false, Columns: []string{"Internal Notes"}},
+	}
+	applyColumnRules(results, ColumnRules{
+		ExcludeColumns:       []string{"internal notes"},
+		RenameColumns:        map[string]string{"name": "Full Name"},
+		NormalizeColumnNames: true,
+	})
+
+	want := []string{"id", "customer_name", "Full Name", "customer_name_2", "column_6"}
+	if !slices.Equal(results[0].Columns, want) {
+		t.Errorf("columns = %q, want %q", results[0].Columns, want)
+	}
+	rows := [][]string{{"1", "Ann", "a", "b", "c"}, {"2", "", "", "", ""}}
+	if !slices.EqualFunc(results[0].Rows, rows, slices.Equal) {
+		t.Errorf("rows = %q, want %q", results[0].Rows, rows)
+	}
+	if !slices.Equal(results[1].Columns, []string{"Internal Notes"}) {
+		t.Errorf("failed file columns = %q, want them untouched", results[1].Columns)
+	}
+
+	// Without rules, only repeated names change
+	mapper := NewColumnMapperWithRules([]string{"Name", "NAME"}, ColumnRules{})
+	if got := mapper.TargetColumns(); !slices.Equal(got, []string{"Name", "NAME_2"}) {
+		t.Errorf("columns without rules = %q", got)
+	}
+}
+
+func TestColumnMapperMatchOptions(t *testing.T) {
+	targets := []string{"montant_eur", "Montant Dû", "Ｑｔｙ", "customer_name", "Date"}
+	tests := []struct {
+		name    string
+		source  string
+		options MatchOptions
+		want    string
+	}{
+		{"ignoring case", "DATE", MatchOptions{}, "Date"},
+		{"case sensitive", "DATE", MatchOptions{CaseSensitive: true, Threshold: 1}, ""},
+		{"decomposed accents", "montant dû", MatchOptions{}, "Montant Dû"},
+		{"accents folded", "Montant Du", MatchOptions{FoldAccents: true, Threshold: 1}, "Montant Dû"},
+		{"accents kept", "Montant Du", MatchOptions{Threshold: 1}, ""},
+		{"compatibility forms", "qty", MatchOptions{NormalizeUnicode: true, Threshold: 1}, "Ｑｔｙ"},
+		{"separators", "Customer Name", MatchOptions{}, "customer_name"},
+		{"similar", "customer_nme", MatchOptions{}, "customer_name"},
+		{"below threshold", "customer_nme", MatchOptions{Threshold: 0.99}, ""},
+	}
+	for _, test := range tests {
+		got := NewColumnMapperWithOptions([]string{test.source}, targets, test.options).GetColumnMap()[test.source]
+		if got != test.want {
+			t.Errorf("%s: %q mapped to %q, want %q", test.name, test.source, got, test.want)
+		}
+	}
+
+	// An exact match keeps its target from a fuzzy match earlier in the source
+	mapper := NewColumnMapperWithOptions([]string{"Montant", "montant_eur"}, []string{"montant_eur"}, MatchOptions{})
+	if got := mapper.GetColumnMap(); len(got) != 1 || got["montant_eur"] != "montant_eur" {
+		t.Errorf("column map = %v", got)
+	}
+
+	if err := (MatchOptions{Threshold: 1.5}).Validate(); err == nil {
+		t.Error("threshold 1.5 is valid")
+	}
+}
+
+func TestApplyColumnMatching(t *testing.T) {
+	results := []ProcessingResult{
+		{FileName: "a.csv", Success: true, Columns: []string{"Montant (€)", "ID"}},
+		{FileName: "b.csv", Success: true, Columns: []string{"montant"}},
+	}
+	traced := traceColumns(results, ColumnRules{})
+
+	if !applyColumnMatching(results, []string{"id", "montant"}, MatchOptions{}, traced) {
+		t.Fatal("no column renamed")
+	}
+	if !slices.Equal(results[0].Columns, []string{"montant", "id"}) {
+		t.Errorf("columns = %q", results[0].Columns)
+	}
+	if _, ok := traced["montant (€)"]; ok || len(traced["montant"]) != 2 {
+		t.Errorf("traced = %+v", traced)
+	}
+}
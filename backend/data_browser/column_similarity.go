@@ -0,0 +1,266 @@
+package data_browser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"bronze-backend/httputil"
+)
+
+// Column similarity strategies, for MatchOptions.Strategy
+const (
+	StrategyExact       = "exact"       // Equal names only
+	StrategyNormalized  = "normalized"  // Equal once cleaned; see cleanColumnName
+	StrategyLevenshtein = "levenshtein" // Edit distance of the cleaned names
+	StrategyJaccard     = "jaccard"     // Words the cleaned names share
+)
+
+// SimilarityStrategy scores how alike a source and a target column name
+// are, from 0 to 1. It gets the names in the form MatchOptions compares
+// them in.
+type SimilarityStrategy interface {
+	Score(source, target string) float64
+}
+
+// SimilarityFunc adapts a function to a SimilarityStrategy.
+type SimilarityFunc func(source, target string) float64
+
+func (f SimilarityFunc) Score(source, target string) float64 {
+	return f(source, target)
+}
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = map[string]SimilarityStrategy{
+		StrategyExact:       SimilarityFunc(exactSimilarity),
+		StrategyNormalized:  SimilarityFunc(normalizedSimilarity),
+		StrategyLevenshtein: SimilarityFunc(levenshteinSimilarity),
+		StrategyJaccard:     SimilarityFunc(jaccardSimilarity),
+	}
+)
+
+// RegisterSimilarityStrategy makes strategy available to MatchOptions as
+// name, replacing any strategy of that name.
+func RegisterSimilarityStrategy(name string, strategy SimilarityStrategy) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	strategies[name] = strategy
+}
+
+// SimilarityStrategies returns the names of the strategies, sorted.
+func SimilarityStrategies() []string {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func similarityStrategy(name string) (SimilarityStrategy, bool) {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	strategy, ok := strategies[name]
+	return strategy, ok
+}
+
+func exactSimilarity(source, target string) float64 {
+	if source == target {
+		return 1
+	}
+	return 0
+}
+
+func normalizedSimilarity(source, target string) float64 {
+	return exactSimilarity(cleanColumnName(source), cleanColumnName(target))
+}
+
+// levenshteinSimilarity scores the cleaned names by their edit distance
+// relative to their length.
+func levenshteinSimilarity(source, target string) float64 {
+	source, target = cleanColumnName(source), cleanColumnName(target)
+	length := utf8.RuneCountInString(source) + utf8.RuneCountInString(target)
+	if length == 0 {
+		return 1
+	}
+	// A replacement costs 2, so the distance is at most the total length
+	return 1 - float64(levenshteinDistance(source, target))/float64(length)
+}
+
+// jaccardSimilarity scores the cleaned names by the words they share out
+// of the words of either: "order_date" and "date_of_order" score 2/3.
+func jaccardSimilarity(source, target string) float64 {
+	words := func(name string) map[string]bool {
+		set := make(map[string]bool)
+		for _, word := range strings.Split(cleanColumnName(name), "_") {
+			if word != "" {
+				set[word] = true
+			}
+		}
+		return set
+	}
+	a, b := words(source), words(target)
+	union := len(a)
+	shared := 0
+	for word := range b {
+		if a[word] {
+			shared++
+		} else {
+			union++
+		}
+	}
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+// ColumnCandidate is a target a source column may map to.
+type ColumnCandidate struct {
+	Target string  `json:"target"`
+	Score  float64 `json:"score"`
+}
+
+// ColumnSuggestion is what a source column maps to and the targets it
+// could map to instead, best first.
+type ColumnSuggestion struct {
+	Source     string            `json:"source"`
+	Match      string            `json:"match,omitempty"` // What an export maps it to, if anything
+	Candidates []ColumnCandidate `json:"candidates"`
+}
+
+// Suggestions returns, for each source column, its mapping and up to limit
+// targets scoring above 0, best first. A target of an equal name scores 1.
+func (cm *ColumnMapper) Suggestions(limit int) []ColumnSuggestion {
+	strategy, ok := cm.options.strategy()
+	if !ok {
+		strategy = SimilarityFunc(exactSimilarity)
+	}
+
+	suggestions := make([]ColumnSuggestion, 0, len(cm.sourceColumns))
+	for _, sourceCol := range cm.sourceColumns {
+		source := cm.normalizeColumnName(sourceCol)
+		candidates := make([]ColumnCandidate, 0, len(cm.targetColumns))
+		for _, targetCol := range cm.targetColumns {
+			target := cm.normalizeColumnName(targetCol)
+			score := 1.0
+			if source != target {
+				score = strategy.Score(source, target)
+			}
+			if score > 0 {
+				candidates = append(candidates, ColumnCandidate{Target: targetCol, Score: score})
+			}
+		}
+		slices.SortStableFunc(candidates, func(a, b ColumnCandidate) int {
+			switch {
+			case a.Score > b.Score:
+				return -1
+			case a.Score < b.Score:
+				return 1
+			}
+			return 0
+		})
+		if limit > 0 && len(candidates) > limit {
+			candidates = candidates[:limit]
+		}
+
+		suggestions = append(suggestions, ColumnSuggestion{
+			Source:     sourceCol,
+			Match:      cm.columnMap[sourceCol],
+			Candidates: candidates,
+		})
+	}
+	return suggestions
+}
+
+// ColumnSuggestionRequest asks how source columns match the columns given,
+// or those of an existing table.
+type ColumnSuggestionRequest struct {
+	SourceColumns  []string     `json:"source_columns"`
+	TargetColumns  []string     `json:"target_columns,omitempty"`
+	Table          string       `json:"table,omitempty"`    // Instead of target_columns
+	Database       string       `json:"database,omitempty"` // Of the table, default NESSIE_DEFAULT_DB
+	ColumnMatching MatchOptions `json:"column_matching"`
+	Limit          int          `json:"limit,omitempty"` // Candidates per column, default 5
+}
+
+// SuggestColumnMappings answers the mapping an export with the request's
+// column_matching would make, and the scored candidates of each source
+// column, for a user to confirm or correct it as rename_columns.
+func (h *ExportHandler) SuggestColumnMappings(w http.ResponseWriter, r *http.Request) {
+	var request ColumnSuggestionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		httputil.WriteError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+	if len(request.SourceColumns) == 0 {
+		httputil.WriteError(w, "source_columns is required", http.StatusBadRequest, nil)
+		return
+	}
+	if err := request.ColumnMatching.Validate(); err != nil {
+		httputil.WriteError(w, err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+	if request.Limit <= 0 {
+		request.Limit = 5
+	}
+
+	targets := request.TargetColumns
+	if request.Table != "" {
+		if len(targets) > 0 {
+			httputil.WriteError(w, "Give target_columns or table, not both", http.StatusBadRequest, nil)
+			return
+		}
+		if !h.requireNessie(w) {
+			return
+		}
+		database := request.Database
+		if database == "" {
+			database = h.config.Nessie.DefaultDB
+		}
+		nessieClient := h.nessieClient.Load()
+		exists, err := nessieClient.TableExists(r.Context(), database, request.Table)
+		if err != nil {
+			httputil.WriteCode(w, httputil.CodeNessieError, "Failed to check table existence", err)
+			return
+		}
+		if !exists {
+			httputil.WriteCode(w, httputil.CodeNotFound, fmt.Sprintf("Table %s.%s not found", database, request.Table), nil)
+			return
+		}
+		table, err := nessieClient.GetTableSchema(r.Context(), database, request.Table)
+		if err != nil {
+			httputil.WriteCode(w, httputil.CodeNessieError, "Failed to get table schema", err)
+			return
+		}
+		for _, column := range table.Columns {
+			targets = append(targets, column.Name)
+		}
+	}
+	if len(targets) == 0 {
+		httputil.WriteError(w, "target_columns or table is required", http.StatusBadRequest, nil)
+		return
+	}
+
+	options := request.ColumnMatching
+	if options.Strategy == "" {
+		options.Strategy = StrategyLevenshtein
+	}
+	options.Threshold = options.threshold()
+	mapper := NewColumnMapperWithOptions(request.SourceColumns, targets, options)
+
+	httputil.WriteJSON(w, http.StatusOK, map[string]any{
+		"success":     true,
+		"strategy":    options.Strategy,
+		"threshold":   options.Threshold,
+		"suggestions": mapper.Suggestions(request.Limit),
+	})
+}
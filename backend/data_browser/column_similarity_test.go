@@ -0,0 +1,67 @@
+package data_browser
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestSimilarityStrategies(t *testing.T) {
+	tests := []struct {
+		strategy       string
+		source, target string
+		want           float64
+	}{
+		{StrategyExact, "order_date", "order date", 0},
+		{StrategyNormalized, "order_date", "order date", 1},
+		{StrategyNormalized, "col_amount", "amount_2", 1},
+		{StrategyNormalized, "amount", "amounts", 0},
+		{StrategyLevenshtein, "amount", "amounts", 1 - 1.0/13},
+		{StrategyJaccard, "order_date", "date of order", 2.0 / 3},
+		{StrategyJaccard, "%", "%", 0},
+	}
+	for _, test := range tests {
+		strategy, ok := similarityStrategy(test.strategy)
+		if !ok {
+			t.Fatalf("strategy %s not registered", test.strategy)
+		}
+		if got := strategy.Score(test.source, test.target); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s(%q, %q) = %v, want %v", test.strategy, test.source, test.target, got, test.want)
+		}
+	}
+
+	if err := (MatchOptions{Strategy: "soundex"}).Validate(); err == nil {
+		t.Error("unknown strategy is valid")
+	}
+	RegisterSimilarityStrategy("prefix", SimilarityFunc(func(source, target string) float64 {
+		if source[0] == target[0] {
+			return 1
+		}
+		return 0
+	}))
+	mapper := NewColumnMapperWithOptions([]string{"qty"}, []string{"amount", "quantity"}, MatchOptions{Strategy: "prefix"})
+	if got := mapper.GetColumnMap()["qty"]; got != "quantity" {
+		t.Errorf("registered strategy mapped qty to %q", got)
+	}
+}
+
+func TestColumnMapperSuggestions(t *testing.T) {
+	targets := []string{"order_id", "order_date", "ship_date", "customer"}
+	mapper := NewColumnMapperWithOptions([]string{"Order Date", "date shipped", "Customer", "notes"}, targets, MatchOptions{Strategy: StrategyJaccard, Threshold: 0.5})
+
+	suggestions := mapper.Suggestions(2)
+	if len(suggestions) != 4 {
+		t.Fatalf("suggestions = %+v", suggestions)
+	}
+	want := []ColumnSuggestion{
+		{Source: "Order Date", Match: "order_date", Candidates: []ColumnCandidate{{"order_date", 1}, {"order_id", 1.0 / 3}}},
+		{Source: "date shipped", Candidates: []ColumnCandidate{{"order_date", 1.0 / 3}, {"ship_date", 1.0 / 3}}},
+		{Source: "Customer", Match: "customer", Candidates: []ColumnCandidate{{"customer", 1}}},
+		{Source: "notes", Candidates: []ColumnCandidate{}},
+	}
+	for i, suggestion := range suggestions {
+		if suggestion.Source != want[i].Source || suggestion.Match != want[i].Match || !slices.Equal(suggestion.Candidates, want[i].Candidates) {
+			t.Errorf("suggestion %d = %+v, want %+v", i, suggestion, want[i])
+		}
+	}
+}
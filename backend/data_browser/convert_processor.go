@@ -0,0 +1,362 @@
+package data_browser
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"bronze-backend/jobs"
+	"bronze-backend/storage"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// fullReadRows lifts the browse row cap when a job needs the whole file. It
+// stays well below MaxInt so offset arithmetic in the readers cannot overflow.
+const fullReadRows = math.MaxInt32
+
+// ConvertProcessor runs "convert" jobs: it reads a CSV, Excel, JSON or MDB
+// object with the data browser readers and writes it back next to the source
+// as Parquet or CSV.
+//
+// Job metadata:
+//   - format:       parquet (default) or csv
+//   - output:       optional object name for the result
+//   - sheet_name:   sheet (Excel) or table (MDB) to convert
+//   - has_headers:  whether the first row holds column names (default true)
+//   - treat_as_csv: read the source as CSV regardless of extension
+//   - formula_mode, normalize_dates, null_tokens, trim_whitespace and
+//     collapse_whitespace: as in BrowseRequest
+type ConvertProcessor struct {
+	browser     *DataBrowserHandler
+	minioClient *storage.MinIOClient
+}
+
+func NewConvertProcessor(minioClient *storage.MinIOClient) *ConvertProcessor {
+	return &ConvertProcessor{
+		browser:     NewDataBrowserHandler(minioClient),
+		minioClient: minioClient,
+	}
+}
+
+type ConvertResult struct {
+	Source      string            `json:"source"`
+	Output      string            `json:"output"`
+	Format      string            `json:"format"`
+	Columns     []string          `json:"columns"`
+	ColumnTypes map[string]string `json:"column_types,omitempty"`
+	RowCount    int               `json:"row_count"`
+	OutputSize  int64             `json:"output_size"`
+}
+
+func (cp *ConvertProcessor) ProcessJob(ctx context.Context, job *jobs.Job) jobs.JobResult {
+	startTime := time.Now()
+
+	fail := func(format string, args ...any) jobs.JobResult {
+		return jobs.JobResult{
+			Success:        false,
+			ProcessingTime: time.Since(startTime),
+			Message:        fmt.Sprintf(format, args...),
+		}
+	}
+
+	if cp.minioClient == nil {
+		return fail("MinIO client not available")
+	}
+
+	format, _ := job.Metadata["format"].(string)
+	format = strings.ToLower(format)
+	if format == "" {
+		format = "parquet"
+	}
+	if format != "parquet" && format != "csv" {
+		return fail("unsupported output format %q, use parquet or csv", format)
+	}
+
+	request := BrowseRequest{
+		FileName:   job.ObjectName,
+		MaxRows:    fullReadRows,
+		HasHeaders: true,
+	}
+	if sheet, ok := job.Metadata["sheet_name"].(string); ok {
+		request.SheetName = sheet
+	}
+	if hasHeaders, ok := job.Metadata["has_headers"].(bool); ok {
+		request.HasHeaders = hasHeaders
+	}
+	if treatAsCSV, ok := job.Metadata["treat_as_csv"].(bool); ok {
+		request.TreatAsCSV = treatAsCSV
+	}
+	if formulaMode, ok := job.Metadata["formula_mode"].(string); ok {
+		request.FormulaMode = formulaMode
+	}
+	if normalizeDates, ok := job.Metadata["normalize_dates"].(bool); ok {
+		request.NormalizeDates = normalizeDates
+	}
+	if tokens, ok := job.Metadata["null_tokens"].([]interface{}); ok {
+		for _, token := range tokens {
+			if token, ok := token.(string); ok {
+				request.NullTokens = append(request.NullTokens, token)
+			}
+		}
+	}
+	if trim, ok := job.Metadata["trim_whitespace"].(bool); ok {
+		request.TrimWhitespace = trim
+	}
+	if collapse, ok := job.Metadata["collapse_whitespace"].(bool); ok {
+		request.CollapseWhitespace = collapse
+	}
+
+	log.Printf("Converting %s to %s for job %s", job.ObjectName, format, job.ID)
+
+	reader, _, err := cp.minioClient.DownloadFileCached(ctx, job.ObjectName, "")
+	if err != nil {
+		return fail("Failed to download file: %v", err)
+	}
+	data, err := jobs.ReadAll(ctx, reader)
+	reader.Close()
+	if err != nil {
+		return fail("Failed to read file: %v", err)
+	}
+
+	job.UpdateProgress(30)
+
+	parsed, err := cp.browser.readData(data, request)
+	if err != nil {
+		return fail("Failed to read source data: %v", err)
+	}
+
+	columns := normalizeColumnNames(parsed.Columns, request.HasHeaders || parsed.DataType == "json")
+
+	job.UpdateProgress(60)
+
+	result := ConvertResult{
+		Source:   job.ObjectName,
+		Format:   format,
+		Columns:  columns,
+		RowCount: len(parsed.Rows),
+	}
+
+	var buf bytes.Buffer
+	contentType := "text/csv"
+	if format == "parquet" {
+		contentType = "application/vnd.apache.parquet"
+		result.ColumnTypes, err = writeParquet(&buf, columns, parsed.Rows)
+	} else {
+		err = writeCSV(&buf, columns, parsed.Rows)
+	}
+	if err != nil {
+		return fail("Failed to write %s: %v", format, err)
+	}
+
+	job.UpdateProgress(85)
+
+	output, _ := job.Metadata["output"].(string)
+	if output == "" {
+		output = convertOutputName(job.ObjectName, request.SheetName, format)
+	}
+	result.Output = output
+	result.OutputSize = int64(buf.Len())
+
+	if _, err := cp.minioClient.UploadFile(ctx, output, bytes.NewReader(buf.Bytes()), int64(buf.Len()), contentType); err != nil {
+		return fail("Failed to upload converted file: %v", err)
+	}
+	job.AddArtifact("output", output)
+
+	return jobs.JobResult{
+		Success:        true,
+		ProcessingTime: time.Since(startTime),
+		Message:        fmt.Sprintf("Converted %s to %s (%d rows)", job.ObjectName, output, result.RowCount),
+		Result:         result,
+	}
+}
+
+// convertOutputName places the result next to the source, swapping the
+// extension, and .gz of a gzipped source, and keeping the sheet name when
+// one was chosen.
+func convertOutputName(source, sheet, format string) string {
+	dir, base := path.Split(source)
+	if strings.HasSuffix(strings.ToLower(base), gzipSuffix) {
+		base = base[:len(base)-len(gzipSuffix)]
+	}
+	base = strings.TrimSuffix(base, path.Ext(base))
+	if sheet != "" {
+		base += "_" + strings.ReplaceAll(sheet, "/", "_")
+	}
+	return dir + base + "." + format
+}
+
+// normalizeColumnNames gives every column a unique, non-empty name. Without
+// headers the first row is data, so columns are named by position.
+func normalizeColumnNames(columns []string, hasHeaders bool) []string {
+	names := make([]string, len(columns))
+	seen := make(map[string]int, len(columns))
+	for i, column := range columns {
+		name := strings.TrimSpace(column)
+		if !hasHeaders || name == "" {
+			name = fmt.Sprintf("column_%d", i+1)
+		}
+		if count := seen[name]; count > 0 {
+			seen[name] = count + 1
+			name = fmt.Sprintf("%s_%d", name, count+1)
+		}
+		seen[name]++
+		names[i] = name
+	}
+	return names
+}
+
+func writeCSV(w io.Writer, columns []string, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// inferColumnType picks the narrowest Parquet type every non-empty value of
+// the column parses as.
+func inferColumnType(rows [][]string, column int) string {
+	isInt, isFloat, isBool, hasValue := true, true, true, false
+	for _, row := range rows {
+		if column >= len(row) || row[column] == "" {
+			continue
+		}
+		hasValue = true
+		value := row[column]
+		if isInt {
+			if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+				isInt = false
+			}
+		}
+		if isFloat {
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				isFloat = false
+			}
+		}
+		if isBool {
+			if _, err := strconv.ParseBool(value); err != nil {
+				isBool = false
+			}
+		}
+		if !isInt && !isFloat && !isBool {
+			break
+		}
+	}
+
+	switch {
+	case !hasValue:
+		return "string"
+	case isInt:
+		return "int64"
+	case isFloat:
+		return "double"
+	case isBool:
+		return "boolean"
+	default:
+		return "string"
+	}
+}
+
+func writeParquet(w io.Writer, columns []string, rows [][]string) (map[string]string, error) {
+	types := make(map[string]string, len(columns))
+	group := make(parquet.Group, len(columns))
+	for i, column := range columns {
+		columnType := inferColumnType(rows, i)
+		types[column] = columnType
+
+		var node parquet.Node
+		switch columnType {
+		case "int64":
+			node = parquet.Int(64)
+		case "double":
+			node = parquet.Leaf(parquet.DoubleType)
+		case "boolean":
+			node = parquet.Leaf(parquet.BooleanType)
+		default:
+			node = parquet.String()
+		}
+		group[column] = parquet.Optional(node)
+	}
+
+	schema := parquet.NewSchema("bronze", group)
+
+	// Group fields are ordered by name, so map each source column to its
+	// position in the schema
+	leafIndex := make(map[string]int, len(columns))
+	for i, field := range schema.Fields() {
+		leafIndex[field.Name()] = i
+	}
+
+	writer := parquet.NewWriter(w, schema)
+
+	batch := make([]parquet.Row, 0, 1000)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := writer.WriteRows(batch); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for _, source := range rows {
+		row := make(parquet.Row, len(columns))
+		for i, column := range columns {
+			index := leafIndex[column]
+			value := ""
+			if i < len(source) {
+				value = source[i]
+			}
+			if value == "" {
+				row[index] = parquet.NullValue().Level(0, 0, index)
+				continue
+			}
+
+			var v parquet.Value
+			switch types[column] {
+			case "int64":
+				n, _ := strconv.ParseInt(value, 10, 64)
+				v = parquet.Int64Value(n)
+			case "double":
+				f, _ := strconv.ParseFloat(value, 64)
+				v = parquet.DoubleValue(f)
+			case "boolean":
+				b, _ := strconv.ParseBool(value)
+				v = parquet.BooleanValue(b)
+			default:
+				v = parquet.ByteArrayValue([]byte(value))
+			}
+			row[index] = v.Level(0, 1, index)
+		}
+
+		batch = append(batch, row)
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return types, nil
+}
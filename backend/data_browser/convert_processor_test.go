@@ -0,0 +1,102 @@
+package data_browser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestWriteParquetInfersColumnTypes(t *testing.T) {
+	columns := []string{"name", "age", "score", "active"}
+	rows := [][]string{
+		{"Alice", "30", "9.5", "true"},
+		{"Bob", "", "7", "false"},
+		{"Carol", "41", "8.25", ""},
+	}
+
+	var buf bytes.Buffer
+	types, err := writeParquet(&buf, columns, rows)
+	if err != nil {
+		t.Fatalf("writeParquet failed: %v", err)
+	}
+
+	expected := map[string]string{"name": "string", "age": "int64", "score": "double", "active": "boolean"}
+	for column, want := range expected {
+		if types[column] != want {
+			t.Errorf("Expected %s to be %s, got %s", column, want, types[column])
+		}
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to open written parquet: %v", err)
+	}
+	if file.NumRows() != int64(len(rows)) {
+		t.Fatalf("Expected %d rows, got %d", len(rows), file.NumRows())
+	}
+
+	reader := parquet.NewReader(file)
+	defer reader.Close()
+
+	readRows := make([]parquet.Row, len(rows))
+	n, _ := reader.ReadRows(readRows)
+	if n != len(rows) {
+		t.Fatalf("Expected to read %d rows, got %d", len(rows), n)
+	}
+
+	ageIndex := -1
+	for i, field := range file.Schema().Fields() {
+		if field.Name() == "age" {
+			ageIndex = i
+		}
+	}
+	if !readRows[1][ageIndex].IsNull() {
+		t.Errorf("Expected empty age to be written as null")
+	}
+	if readRows[2][ageIndex].Int64() != 41 {
+		t.Errorf("Expected age 41, got %v", readRows[2][ageIndex])
+	}
+}
+
+func TestNormalizeColumnNames(t *testing.T) {
+	got := normalizeColumnNames([]string{"id", "", "id", " name "}, true)
+	want := []string{"id", "column_2", "id_2", "name"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Column %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+
+	got = normalizeColumnNames([]string{"1", "2"}, false)
+	if got[0] != "column_1" || got[1] != "column_2" {
+		t.Errorf("Expected positional names without headers, got %v", got)
+	}
+}
+
+func TestConvertOutputName(t *testing.T) {
+	if got := convertOutputName("uploads/sales.xlsx", "Q1", "parquet"); got != "uploads/sales_Q1.parquet" {
+		t.Errorf("Unexpected output name %q", got)
+	}
+	if got := convertOutputName("data.csv", "", "parquet"); got != "data.parquet" {
+		t.Errorf("Unexpected output name %q", got)
+	}
+	if got := convertOutputName("dumps/data.CSV.GZ", "", "parquet"); got != "dumps/data.parquet" {
+		t.Errorf("Unexpected output name %q", got)
+	}
+}
+
+func TestParseJSONRecords(t *testing.T) {
+	columns, rows, err := parseJSONRecords([]byte(`{"id": 1, "name": "a"}
+{"id": 2, "tags": ["x"], "name": null}`))
+	if err != nil {
+		t.Fatalf("parseJSONRecords failed: %v", err)
+	}
+
+	if len(columns) != 3 || columns[0] != "id" || columns[1] != "name" || columns[2] != "tags" {
+		t.Fatalf("Unexpected columns %v", columns)
+	}
+	if len(rows) != 2 || rows[0][2] != "" || rows[1][2] != `["x"]` || rows[1][1] != "" {
+		t.Errorf("Unexpected rows %v", rows)
+	}
+}
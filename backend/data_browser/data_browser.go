@@ -7,22 +7,36 @@ import (
 	"database/sql"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"bronze-backend/httputil"
+	"bronze-backend/jobs"
 	"bronze-backend/storage"
 	_ "github.com/microsoft/go-mssqldb" // Import for MDB support
 	"github.com/tealeg/xlsx/v3"
+	"golang.org/x/text/transform"
 )
 
+// MaxBrowseRows caps the rows one browse request returns.
+const MaxBrowseRows = 10000
+
 type DataBrowserHandler struct {
 	minioClient *storage.MinIOClient
+	rates       map[string]float64 // Of amount normalization, by "FROM/TO"
+}
+
+// MDBSupported reports whether an Access driver is built in. Reading MDB
+// and ACCDB files needs an "access" or "odbc" database/sql driver; the SQL
+// Server driver cannot open them on its own.
+func MDBSupported() bool {
+	return slices.Contains(sql.Drivers(), "access") || slices.Contains(sql.Drivers(), "odbc")
 }
 
 func NewDataBrowserHandler(minioClient *storage.MinIOClient) *DataBrowserHandler {
@@ -31,6 +45,12 @@ func NewDataBrowserHandler(minioClient *storage.MinIOClient) *DataBrowserHandler
 	}
 }
 
+// SetConversionRates sets the unit and currency rates amount normalization
+// converts with, as parsed from CONVERSION_RATES.
+func (h *DataBrowserHandler) SetConversionRates(rates map[string]float64) {
+	h.rates = rates
+}
+
 type BrowseRequest struct {
 	FileName          string `json:"file_name"`
 	SheetName         string `json:"sheet_name,omitempty"`
@@ -41,6 +61,30 @@ type BrowseRequest struct {
 	AutoDetectHeaders bool   `json:"auto_detect_headers,omitempty"`
 	StreamMode        bool   `json:"stream_mode,omitempty"`
 	ChunkSize         int    `json:"chunk_size,omitempty"`
+	// Headers name the columns, overriding a header row. Without a header
+	// row the first row is data, and columns left unnamed are col_1, col_2...
+	Headers []string `json:"headers,omitempty"`
+	// FormulaMode is what Excel formula cells read as: "cached" (default),
+	// "formula" or "evaluate"; see FormulaMode
+	FormulaMode string `json:"formula_mode,omitempty"`
+	// NormalizeDates renders the Excel date serials of date columns as ISO
+	// 8601, e.g. "2023-01-01" rather than "44927"; see detectDateColumns
+	NormalizeDates bool `json:"normalize_dates,omitempty"`
+	// DateColumns names columns to read as dates, for dates stored without
+	// a date format. Naming any implies NormalizeDates
+	DateColumns []string `json:"date_columns,omitempty"`
+	// VersionID reads an earlier version of the file, in a bucket with
+	// versioning on; see ListFileVersions
+	VersionID string `json:"version_id,omitempty"`
+	// CompareToLatest diffs the version read against the latest one, in
+	// the response's version_diff
+	CompareToLatest bool `json:"compare_to_latest,omitempty"`
+	// UnionFiles browses these files, instead of file_name, as one file of
+	// their merged columns, as a multi-file export loads them; see
+	// browseUnion. SchemaResolution merges their columns as in exports
+	UnionFiles       []string `json:"union_files,omitempty"`
+	SchemaResolution string   `json:"schema_resolution,omitempty"`
+	CleanOptions
 }
 
 type BrowseResponse struct {
@@ -56,6 +100,17 @@ type BrowseResponse struct {
 	Offset     int        `json:"offset"`
 	HasHeaders bool       `json:"has_headers"`
 	Sheets     []string   `json:"sheets,omitempty"`
+	// Metadata describes an MDB file's queries, relationships and indexes
+	Metadata *MDBMetadata `json:"metadata,omitempty"`
+	// VersionID is the version read, when one was asked for
+	VersionID   string       `json:"version_id,omitempty"`
+	VersionDiff *VersionDiff `json:"version_diff,omitempty"`
+	// SourceFiles and Conflicts describe the files of a union and how
+	// their columns were merged
+	SourceFiles []FileInfo       `json:"source_files,omitempty"`
+	Conflicts   []ColumnConflict `json:"conflicts,omitempty"`
+	// GeoColumns are the columns detected to hold geographic data
+	GeoColumns []GeoColumn `json:"geo_columns,omitempty"`
 }
 
 type FileInfoListResponse struct {
@@ -77,19 +132,19 @@ type DataFileInfo struct {
 
 func (h *DataBrowserHandler) BrowseData(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var request BrowseRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		h.writeError(w, "Failed to decode request", http.StatusBadRequest, err)
+		httputil.WriteError(w, "Failed to decode request", http.StatusBadRequest, err)
 		return
 	}
 
 	response, err := h.BrowseDataRequest(r.Context(), request)
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusInternalServerError, err)
+		httputil.WriteError(w, err.Error(), http.StatusInternalServerError, err)
 		return
 	}
 
@@ -97,16 +152,16 @@ func (h *DataBrowserHandler) BrowseData(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *DataBrowserHandler) BrowseDataRequest(ctx context.Context, request BrowseRequest) (BrowseResponse, error) {
-	if request.FileName == "" {
-		return BrowseResponse{}, fmt.Errorf("file name is required")
+	if request.FileName == "" && len(request.UnionFiles) == 0 {
+		return BrowseResponse{}, httputil.NewError(httputil.CodeBadRequest, "file name is required", nil)
 	}
 
 	// Set defaults
 	if request.MaxRows <= 0 {
 		request.MaxRows = 100
 	}
-	if request.MaxRows > 10000 {
-		request.MaxRows = 10000 // Cap at 10k rows
+	if request.MaxRows > MaxBrowseRows {
+		request.MaxRows = MaxBrowseRows
 	}
 	if request.ChunkSize <= 0 {
 		request.ChunkSize = 1000 // Default chunk size for streaming
@@ -116,25 +171,81 @@ func (h *DataBrowserHandler) BrowseDataRequest(ctx context.Context, request Brow
 	ctx, cancel := context.WithTimeout(ctx, 300*time.Second) // Longer timeout for streaming
 	defer cancel()
 
-	reader, err := h.minioClient.DownloadFile(ctx, request.FileName)
+	// Handle streaming mode (not supported in request mode)
+	if request.StreamMode {
+		return BrowseResponse{}, httputil.NewError(httputil.CodeBadRequest, "streaming mode not supported in request mode", nil)
+	}
+	if request.CompareToLatest && request.VersionID == "" {
+		return BrowseResponse{}, httputil.NewError(httputil.CodeBadRequest, "compare_to_latest requires version_id", nil)
+	}
+	if len(request.UnionFiles) > 0 {
+		return h.browseUnion(ctx, request)
+	}
+
+	data, err := h.downloadVersion(ctx, request.FileName, request.VersionID)
 	if err != nil {
-		return BrowseResponse{}, fmt.Errorf("failed to download file: %w", err)
+		return BrowseResponse{}, err
 	}
-	defer reader.Close()
 
-	// Handle streaming mode (not supported in request mode)
-	if request.StreamMode {
-		return BrowseResponse{}, fmt.Errorf("streaming mode not supported in request mode")
+	response, err := h.readData(data, request)
+	if err != nil {
+		return response, err
+	}
+	response.VersionID = request.VersionID
+	response.GeoColumns = detectGeoColumns(response.Columns, response.Rows)
+	if request.CompareToLatest {
+		if response.VersionDiff, err = h.diffAgainstLatest(ctx, data, request); err != nil {
+			return response, err
+		}
+	}
+	return response, nil
+}
+
+// downloadVersion reads a version of a file into memory, or its latest
+// version when versionID is empty.
+func (h *DataBrowserHandler) downloadVersion(ctx context.Context, fileName, versionID string) ([]byte, error) {
+	reader, _, err := h.minioClient.DownloadFileCached(ctx, fileName, versionID)
+	if err != nil {
+		return nil, downloadError(err, versionID, "failed to download file")
 	}
+	defer reader.Close()
 
-	// Read file into memory for non-streaming mode
-	data, err := io.ReadAll(reader)
+	// Read uncached, MinIO only reports a missing object on the first read
+	data, err := jobs.ReadAll(ctx, reader)
 	if err != nil {
-		return BrowseResponse{}, fmt.Errorf("failed to read file data: %w", err)
+		return nil, downloadError(err, versionID, "failed to read file data")
+	}
+	return data, nil
+}
+
+// downloadError reports a missing file or version as not found.
+func downloadError(err error, versionID, message string) error {
+	if !storage.IsNotFound(err) {
+		return fmt.Errorf("%s: %w", message, err)
+	}
+	if versionID != "" {
+		return httputil.NewError(httputil.CodeFileNotFound, "file version not found", err)
+	}
+	return httputil.NewError(httputil.CodeFileNotFound, "file not found", err)
+}
+
+// errUnreadable wraps the errors of files whose contents cannot be parsed.
+var errUnreadable = errors.New("processing failed")
+
+// readData parses file contents with the reader matching the file type. Row
+// limits are taken from the request as-is, so callers that need every row
+// can bypass the browse cap. Gzipped files read as the file they compress.
+func (h *DataBrowserHandler) readData(data []byte, request BrowseRequest) (BrowseResponse, error) {
+	if err := validateHeaders(request.Headers); err != nil {
+		return BrowseResponse{}, httputil.NewError(httputil.CodeBadRequest, err.Error(), nil)
 	}
 
 	// Determine file type and process
-	ext := strings.ToLower(filepath.Ext(request.FileName))
+	ext := dataExt(request.FileName)
+	data, err := decodeData(request.FileName, data, request.TreatAsCSV || isTextExt(ext))
+	if err != nil {
+		return BrowseResponse{}, fmt.Errorf("%w: %w", errUnreadable, err)
+	}
 	var response BrowseResponse
 
 	// If treat_as_csv is true, process as CSV regardless of extension
@@ -146,15 +257,20 @@ func (h *DataBrowserHandler) BrowseDataRequest(ctx context.Context, request Brow
 			response, err = h.processExcelFile(data, request)
 		case ".csv":
 			response, err = h.processCSVFile(data, request)
-		case ".mdb":
+		case ".mdb", ".accdb":
 			response, err = h.processMDBFile(data, request)
+		case ".json", ".jsonl", ".ndjson":
+			response, err = h.processJSONFile(data, request)
 		default:
-			return BrowseResponse{}, fmt.Errorf("unsupported file type: %s", ext)
+			return BrowseResponse{}, httputil.NewError(httputil.CodeUnsupportedFile, "unsupported file type: "+ext, nil)
 		}
 	}
 
 	if err != nil {
-		return BrowseResponse{}, fmt.Errorf("processing failed: %w", err)
+		return BrowseResponse{}, fmt.Errorf("%w: %w", errUnreadable, err)
+	}
+	if err := request.CleanOptions.apply(&response, h.rates); err != nil {
+		return BrowseResponse{}, httputil.NewError(httputil.CodeBadRequest, err.Error(), nil)
 	}
 
 	return response, nil
@@ -162,7 +278,7 @@ func (h *DataBrowserHandler) BrowseDataRequest(ctx context.Context, request Brow
 
 func (h *DataBrowserHandler) ListDataFiles(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -172,22 +288,25 @@ func (h *DataBrowserHandler) ListDataFiles(w http.ResponseWriter, r *http.Reques
 	// List all files
 	files, err := h.minioClient.ListFiles(ctx, "", 0)
 	if err != nil {
-		h.writeError(w, "Failed to list files", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to list files", http.StatusInternalServerError, err)
 		return
 	}
 
 	var dataFiles []DataFileInfo
 	supportedExtensions := map[string]bool{
-		".xlsx":  true,
-		".xls":   true,
-		".xlsm":  true,
-		".csv":   true,
-		".mdb":   true,
-		".accdb": true, // Add ACCDB support
+		".xlsx":   true,
+		".xls":    true,
+		".xlsm":   true,
+		".csv":    true,
+		".mdb":    true,
+		".accdb":  true, // Add ACCDB support
+		".json":   true,
+		".jsonl":  true,
+		".ndjson": true,
 	}
 
 	for _, file := range files {
-		ext := strings.ToLower(filepath.Ext(file.Key))
+		ext := dataExt(file.Key)
 
 		dataFile := DataFileInfo{
 			Name:         file.Key,
@@ -212,6 +331,11 @@ func (h *DataBrowserHandler) ListDataFiles(w http.ResponseWriter, r *http.Reques
 					dataFile.DataType = "treatable_as_csv"
 				}
 			}
+		} else if dataFile.DataType == "json" {
+			if columns, rowCount, err := h.getJSONInfo(ctx, file.Key); err == nil {
+				dataFile.Columns = columns
+				dataFile.RowCount = rowCount
+			}
 		} else if ext == ".mdb" || ext == ".accdb" {
 			// For MDB files, get table and column info
 			if tables, columns, rowCount, err := h.getMDBInfo(ctx, file.Key); err == nil {
@@ -247,6 +371,11 @@ func (h *DataBrowserHandler) processExcelFile(data []byte, request BrowseRequest
 		Offset:     request.Offset,
 	}
 
+	formulaMode, err := parseFormulaMode(request.FormulaMode)
+	if err != nil {
+		return response, err
+	}
+
 	// Open Excel file
 	wb, err := xlsx.OpenBinary(data)
 	if err != nil {
@@ -276,6 +405,7 @@ func (h *DataBrowserHandler) processExcelFile(data []byte, request BrowseRequest
 	}
 
 	response.SheetName = targetSheet
+	cells := newCellReader(wb, sheet, formulaMode)
 
 	// Get all rows to calculate total and extract data
 	var allRows []*xlsx.Row
@@ -310,11 +440,10 @@ func (h *DataBrowserHandler) processExcelFile(data []byte, request BrowseRequest
 	firstRow := allRows[0]
 	var cols []string
 	firstRow.ForEachCell(func(cell *xlsx.Cell) error {
-		cellValue, _ := cell.FormattedValue()
-		cols = append(cols, cellValue)
+		cols = append(cols, cells.read(cell))
 		return nil
 	})
-	response.Columns = cols
+	response.Columns = fileColumns(cols, request.HasHeaders, request.Headers, sheet.MaxCol)
 
 	// Process data rows
 	dataStart := 0
@@ -322,6 +451,10 @@ func (h *DataBrowserHandler) processExcelFile(data []byte, request BrowseRequest
 		dataStart = 1
 	}
 
+	if request.NormalizeDates || len(request.DateColumns) > 0 {
+		cells.dates = detectDateColumns(allRows[dataStart:], response.Columns, request.DateColumns)
+	}
+
 	var rows [][]string
 	for i := startRow + dataStart; i < endRow; i++ {
 		if i >= len(allRows) {
@@ -331,8 +464,7 @@ func (h *DataBrowserHandler) processExcelFile(data []byte, request BrowseRequest
 		row := allRows[i]
 		var rowData []string
 		row.ForEachCell(func(cell *xlsx.Cell) error {
-			cellValue, _ := cell.FormattedValue()
-			rowData = append(rowData, cellValue)
+			rowData = append(rowData, cells.read(cell))
 			return nil
 		})
 
@@ -414,8 +546,8 @@ func (h *DataBrowserHandler) processCSVFile(data []byte, request BrowseRequest)
 		}
 	}
 
-	// Get columns from first row
-	response.Columns = allRecords[0]
+	// Get columns from first row, or name them for a file without one
+	response.Columns = fileColumns(allRecords[0], hasHeaders, request.Headers, recordWidth(allRecords))
 
 	// Determine data start
 	dataStart := 0
@@ -463,49 +595,11 @@ func (h *DataBrowserHandler) processMDBFile(data []byte, request BrowseRequest)
 		Offset:     request.Offset,
 	}
 
-	// Create temporary file for MDB database
-	tempFile, err := os.CreateTemp("", "tempdb_*.mdb")
+	db, closeDB, err := openAccessDB(data, dataExt(request.FileName))
 	if err != nil {
-		return response, fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer os.Remove(tempFile.Name())
-	defer tempFile.Close()
-
-	// Write MDB data to temp file
-	if _, err := tempFile.Write(data); err != nil {
-		return response, fmt.Errorf("failed to write temp file: %w", err)
-	}
-	tempFile.Close()
-
-	// Open MDB database using connection string
-	connStr := fmt.Sprintf("Provider=Microsoft.Jet.OLEDB.4.0;Data Source=%s;", tempFile.Name())
-
-	// Alternative connection string for newer Access versions
-	connStrAlt := fmt.Sprintf("Driver={Microsoft Access Driver (*.mdb, *.accdb)};Dbq=%s;", tempFile.Name())
-
-	var db *sql.DB
-	var errOpen error
-
-	// Try different connection strings
-	db, errOpen = sql.Open("mssql", connStr)
-	if errOpen != nil {
-		// Try with alternative driver
-		db, errOpen = sql.Open("access", connStrAlt)
-		if errOpen != nil {
-			// Try ODBC approach
-			db, errOpen = sql.Open("odbc", connStrAlt)
-			if errOpen != nil {
-				// If all fail, provide helpful error
-				return response, fmt.Errorf("failed to connect to MDB database: %w. Please ensure ODBC/Jet drivers are installed", errOpen)
-			}
-		}
-	}
-	defer db.Close()
-
-	// Test connection
-	if err := db.Ping(); err != nil {
-		return response, fmt.Errorf("failed to connect to MDB database: %w", err)
+		return response, err
 	}
+	defer closeDB()
 
 	// Get list of tables
 	tables, err := h.getMDBTables(db)
@@ -517,6 +611,10 @@ func (h *DataBrowserHandler) processMDBFile(data []byte, request BrowseRequest)
 		return response, fmt.Errorf("no tables found in MDB database")
 	}
 
+	// Saved queries are browsed like tables, after them
+	metadata := h.getMDBMetadata(db)
+	sheets := append(tables, metadata.Queries...)
+
 	// Use first table if not specified
 	tableName := request.SheetName // Reuse SheetName field as table selector
 	if tableName == "" {
@@ -524,15 +622,8 @@ func (h *DataBrowserHandler) processMDBFile(data []byte, request BrowseRequest)
 	}
 
 	// Check if table exists
-	tableExists := false
-	for _, t := range tables {
-		if t == tableName {
-			tableExists = true
-			break
-		}
-	}
-	if !tableExists {
-		return response, fmt.Errorf("table '%s' not found in MDB database. Available tables: %v", tableName, tables)
+	if !slices.Contains(sheets, tableName) {
+		return response, fmt.Errorf("table '%s' not found in MDB database. Available tables and queries: %v", tableName, sheets)
 	}
 
 	// Get column information and data
@@ -545,7 +636,9 @@ func (h *DataBrowserHandler) processMDBFile(data []byte, request BrowseRequest)
 	response.Rows = rows
 	response.RowCount = len(rows)
 	response.TotalRows = totalRows
-	response.Sheets = tables
+	response.SheetName = tableName
+	response.Sheets = sheets
+	response.Metadata = metadata
 
 	return response, nil
 }
@@ -723,7 +816,7 @@ func (h *DataBrowserHandler) convertInterfaceToString(value interface{}) string
 }
 
 func (h *DataBrowserHandler) getExcelInfo(ctx context.Context, fileName string) ([]string, []string, int64, error) {
-	reader, err := h.minioClient.DownloadFile(ctx, fileName)
+	reader, _, err := h.minioClient.DownloadFileCached(ctx, fileName, "")
 	if err != nil {
 		return nil, nil, 0, err
 	}
@@ -733,6 +826,10 @@ func (h *DataBrowserHandler) getExcelInfo(ctx context.Context, fileName string)
 	if err != nil {
 		return nil, nil, 0, err
 	}
+	data, err = decodeData(fileName, data, false)
+	if err != nil {
+		return nil, nil, 0, err
+	}
 
 	wb, err := xlsx.OpenBinary(data)
 	if err != nil {
@@ -780,6 +877,8 @@ func (h *DataBrowserHandler) getDataType(ext string) string {
 		return "csv"
 	case ".mdb", ".accdb":
 		return "mdb"
+	case ".json", ".jsonl", ".ndjson":
+		return "json"
 	default:
 		return "unknown"
 	}
@@ -787,7 +886,7 @@ func (h *DataBrowserHandler) getDataType(ext string) string {
 
 // getCSVInfo gets basic info about CSV files without processing all data
 func (h *DataBrowserHandler) getCSVInfo(ctx context.Context, fileName string) ([]string, int64, error) {
-	reader, err := h.minioClient.DownloadFile(ctx, fileName)
+	reader, _, err := h.minioClient.DownloadFileCached(ctx, fileName, "")
 	if err != nil {
 		return nil, 0, err
 	}
@@ -797,6 +896,10 @@ func (h *DataBrowserHandler) getCSVInfo(ctx context.Context, fileName string) ([
 	if err != nil {
 		return nil, 0, err
 	}
+	data, err = decodeData(fileName, data, true)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	if len(data) == 0 {
 		return []string{}, 0, nil
@@ -824,7 +927,7 @@ func (h *DataBrowserHandler) getCSVInfo(ctx context.Context, fileName string) ([
 
 // getMDBInfo gets basic info about MDB files without processing all data
 func (h *DataBrowserHandler) getMDBInfo(ctx context.Context, fileName string) ([]string, []string, int64, error) {
-	reader, err := h.minioClient.DownloadFile(ctx, fileName)
+	reader, _, err := h.minioClient.DownloadFileCached(ctx, fileName, "")
 	if err != nil {
 		return nil, nil, 0, err
 	}
@@ -834,46 +937,20 @@ func (h *DataBrowserHandler) getMDBInfo(ctx context.Context, fileName string) ([
 	if err != nil {
 		return nil, nil, 0, err
 	}
-
-	if len(data) == 0 {
-		return []string{}, []string{}, 0, nil
-	}
-
-	// Create temporary file for MDB database
-	tempFile, err := os.CreateTemp("", "tempdb_info_*.mdb")
+	data, err = decodeData(fileName, data, false)
 	if err != nil {
-		return nil, nil, 0, fmt.Errorf("failed to create temp file: %w", err)
+		return nil, nil, 0, err
 	}
-	defer os.Remove(tempFile.Name())
-	defer tempFile.Close()
 
-	// Write MDB data to temp file
-	if _, err := tempFile.Write(data); err != nil {
-		return nil, nil, 0, fmt.Errorf("failed to write temp file: %w", err)
+	if len(data) == 0 {
+		return []string{}, []string{}, 0, nil
 	}
-	tempFile.Close()
 
-	// Open MDB database
-	connStr := fmt.Sprintf("Provider=Microsoft.Jet.OLEDB.4.0;Data Source=%s;", tempFile.Name())
-	connStrAlt := fmt.Sprintf("Driver={Microsoft Access Driver (*.mdb, *.accdb)};Dbq=%s;", tempFile.Name())
-
-	var db *sql.DB
-	db, err = sql.Open("mssql", connStr)
+	db, closeDB, err := openAccessDB(data, dataExt(fileName))
 	if err != nil {
-		db, err = sql.Open("access", connStrAlt)
-		if err != nil {
-			db, err = sql.Open("odbc", connStrAlt)
-			if err != nil {
-				return nil, nil, 0, fmt.Errorf("failed to connect to MDB database: %w", err)
-			}
-		}
-	}
-	defer db.Close()
-
-	// Test connection
-	if err := db.Ping(); err != nil {
-		return nil, nil, 0, fmt.Errorf("failed to connect to MDB database: %w", err)
+		return nil, nil, 0, err
 	}
+	defer closeDB()
 
 	// Get list of tables
 	tables, err := h.getMDBTables(db)
@@ -891,7 +968,12 @@ func (h *DataBrowserHandler) getMDBInfo(ctx context.Context, fileName string) ([
 		return tables, []string{}, 0, err
 	}
 
-	return tables, columns, totalRows, nil
+	queries, err := h.getMDBQueries(db)
+	if err != nil {
+		log.Printf("Failed to read MDB queries: %v", err)
+	}
+
+	return append(tables, queries...), columns, totalRows, nil
 }
 
 func (h *DataBrowserHandler) writeJSON(w http.ResponseWriter, statusCode int, data any) {
@@ -900,19 +982,6 @@ func (h *DataBrowserHandler) writeJSON(w http.ResponseWriter, statusCode int, da
 	json.NewEncoder(w).Encode(data)
 }
 
-func (h *DataBrowserHandler) writeError(w http.ResponseWriter, message string, statusCode int, err error) {
-	response := map[string]any{
-		"success": false,
-		"message": message,
-	}
-	if err != nil {
-		response["error"] = err.Error()
-		log.Printf("Data Browser Error: %v", err)
-	}
-
-	h.writeJSON(w, statusCode, response)
-}
-
 // detectDelimiter tries to detect the most likely delimiter in CSV data
 func (h *DataBrowserHandler) detectDelimiter(data []byte) rune {
 	dataStr := string(data)
@@ -1060,10 +1129,10 @@ func (h *DataBrowserHandler) streamCSVData(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Create CSV reader with auto-detected delimiter
-	bufReader := bufio.NewReader(reader)
+	bufReader := bufio.NewReader(transform.NewReader(reader, bomDecoder()))
 	peekBytes, err := bufReader.Peek(1024) // Read first KB for delimiter detection
 	if err != nil && err != io.EOF {
-		h.writeError(w, "Failed to peek file for delimiter detection", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to peek file for delimiter detection", http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1138,8 +1207,7 @@ func (h *DataBrowserHandler) streamCSVData(w http.ResponseWriter, r *http.Reques
 				}
 			} else if request.HasHeaders && !hasSentHeaders {
 				// Use current record as headers
-				columns = make([]string, len(record))
-				copy(columns, record)
+				columns = fileColumns(slices.Clone(record), true, request.Headers, len(record))
 
 				// Send header information
 				headerChunk := map[string]interface{}{
@@ -1154,9 +1222,17 @@ func (h *DataBrowserHandler) streamCSVData(w http.ResponseWriter, r *http.Reques
 				hasSentHeaders = true
 				continue // Skip this row as it's headers
 			} else if len(columns) == 0 {
-				// Use current record as columns
-				columns = make([]string, len(record))
-				copy(columns, record)
+				// Name the columns of a file without a header row
+				columns = fileColumns(nil, false, request.Headers, len(record))
+				headerChunk := map[string]interface{}{
+					"success":     true,
+					"columns":     columns,
+					"has_headers": false,
+				}
+				encoder.Encode(headerChunk)
+				if flusher, ok := w.(http.Flusher); ok {
+					flusher.Flush()
+				}
 			}
 
 			hasSentHeaders = true
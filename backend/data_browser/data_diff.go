@@ -0,0 +1,274 @@
+package data_browser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"bronze-backend/httputil"
+)
+
+// DiffSource is a file, or a version of one, to diff.
+type DiffSource struct {
+	FileName   string `json:"file_name"`
+	VersionID  string `json:"version_id,omitempty"`
+	SheetName  string `json:"sheet_name,omitempty"`
+	TreatAsCSV bool   `json:"treat_as_csv,omitempty"`
+}
+
+// DiffRequest compares the rows of two files, or two versions of one, by
+// their key columns.
+type DiffRequest struct {
+	Base       DiffSource `json:"base"`
+	Compare    DiffSource `json:"compare"`
+	KeyColumns []string   `json:"key_columns"`
+	// Columns limits the columns compared, default every column both have
+	Columns []string `json:"columns,omitempty"`
+	// MaxRows caps the rows listed of each kind, default 100
+	MaxRows int `json:"max_rows,omitempty"`
+	CleanOptions
+}
+
+// CellChange is a value of a changed row.
+type CellChange struct {
+	Column string `json:"column"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// ChangedRow is a row both sides have under a key, with other values.
+type ChangedRow struct {
+	Key     []string     `json:"key"`
+	Changes []CellChange `json:"changes"`
+}
+
+// DiffSummary counts the rows of a diff.
+type DiffSummary struct {
+	BaseRows    int `json:"base_rows"`
+	CompareRows int `json:"compare_rows"`
+	Added       int `json:"added"`
+	Removed     int `json:"removed"`
+	Changed     int `json:"changed"`
+	Unchanged   int `json:"unchanged"`
+	// DuplicateKeys counts the rows of either side whose key an earlier row
+	// has; they are left out of the diff
+	DuplicateKeys int `json:"duplicate_keys"`
+}
+
+// DataDiff is how the compare side differs from the base side. Added rows
+// are in CompareColumns, removed rows in BaseColumns.
+type DataDiff struct {
+	KeyColumns     []string     `json:"key_columns"`
+	Columns        []string     `json:"columns"` // Compared
+	BaseColumns    []string     `json:"base_columns"`
+	CompareColumns []string     `json:"compare_columns"`
+	ColumnsAdded   []string     `json:"columns_added,omitempty"`
+	ColumnsRemoved []string     `json:"columns_removed,omitempty"`
+	Summary        DiffSummary  `json:"summary"`
+	Added          [][]string   `json:"added"`
+	Removed        [][]string   `json:"removed"`
+	Changed        []ChangedRow `json:"changed"`
+	// Truncated is set when there were more rows to list than max_rows
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// DiffData compares two files, or two versions of one, by key columns, for
+// reviewing what an export would change.
+func (h *DataBrowserHandler) DiffData(w http.ResponseWriter, r *http.Request) {
+	var request DiffRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		httputil.WriteError(w, "Failed to decode request", http.StatusBadRequest, err)
+		return
+	}
+	if request.Base.FileName == "" || request.Compare.FileName == "" {
+		httputil.WriteError(w, "base.file_name and compare.file_name are required", http.StatusBadRequest, nil)
+		return
+	}
+	if len(request.KeyColumns) == 0 {
+		httputil.WriteError(w, "key_columns is required", http.StatusBadRequest, nil)
+		return
+	}
+	if request.MaxRows <= 0 {
+		request.MaxRows = 100
+	}
+	if request.MaxRows > MaxBrowseRows {
+		request.MaxRows = MaxBrowseRows
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 300*time.Second)
+	defer cancel()
+
+	base, err := h.readDiffSource(ctx, request.Base, request.CleanOptions)
+	if err != nil {
+		httputil.WriteError(w, "Failed to read base: "+err.Error(), http.StatusInternalServerError, err)
+		return
+	}
+	compare, err := h.readDiffSource(ctx, request.Compare, request.CleanOptions)
+	if err != nil {
+		httputil.WriteError(w, "Failed to read compare: "+err.Error(), http.StatusInternalServerError, err)
+		return
+	}
+
+	diff, err := diffByKey(base, compare, request.KeyColumns, request.Columns, request.MaxRows)
+	if err != nil {
+		httputil.WriteCode(w, httputil.CodeBadRequest, err.Error(), nil)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"diff":    diff,
+	})
+}
+
+// readDiffSource reads every row of a side of a diff.
+func (h *DataBrowserHandler) readDiffSource(ctx context.Context, source DiffSource, clean CleanOptions) (BrowseResponse, error) {
+	data, err := h.downloadVersion(ctx, source.FileName, source.VersionID)
+	if err != nil {
+		return BrowseResponse{}, err
+	}
+	return h.readData(data, BrowseRequest{
+		FileName:     source.FileName,
+		SheetName:    source.SheetName,
+		TreatAsCSV:   source.TreatAsCSV,
+		HasHeaders:   true,
+		MaxRows:      fullReadRows,
+		CleanOptions: clean,
+	})
+}
+
+// diffByKey pairs the rows of base and compare by their key columns and
+// compares the values of columns, or of every non-key column both have.
+// Up to limit rows of each kind are listed.
+func diffByKey(base, compare BrowseResponse, keyColumns, columns []string, limit int) (*DataDiff, error) {
+	basePositions := columnPositions(base.Columns)
+	comparePositions := columnPositions(compare.Columns)
+	for _, key := range keyColumns {
+		_, inBase := basePositions[key]
+		_, inCompare := comparePositions[key]
+		if !inBase || !inCompare {
+			return nil, fmt.Errorf("key column %q is not in both sides", key)
+		}
+	}
+
+	diff := &DataDiff{
+		KeyColumns:     keyColumns,
+		BaseColumns:    base.Columns,
+		CompareColumns: compare.Columns,
+		Added:          [][]string{},
+		Removed:        [][]string{},
+		Changed:        []ChangedRow{},
+	}
+	isKey := make(map[string]bool, len(keyColumns))
+	for _, key := range keyColumns {
+		isKey[key] = true
+	}
+	for _, column := range compare.Columns {
+		if _, ok := basePositions[column]; !ok {
+			diff.ColumnsAdded = append(diff.ColumnsAdded, column)
+		}
+	}
+	for _, column := range base.Columns {
+		_, shared := comparePositions[column]
+		if !shared {
+			diff.ColumnsRemoved = append(diff.ColumnsRemoved, column)
+		} else if len(columns) == 0 && !isKey[column] {
+			diff.Columns = append(diff.Columns, column)
+		}
+	}
+	for _, column := range columns {
+		_, inBase := basePositions[column]
+		_, inCompare := comparePositions[column]
+		if !inBase || !inCompare {
+			return nil, fmt.Errorf("column %q is not in both sides", column)
+		}
+		diff.Columns = append(diff.Columns, column)
+	}
+	if diff.Columns == nil {
+		diff.Columns = []string{}
+	}
+
+	value := func(positions map[string]int, row []string, column string) string {
+		if i := positions[column]; i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	keyOf := func(positions map[string]int, row []string) []string {
+		key := make([]string, len(keyColumns))
+		for i, column := range keyColumns {
+			key[i] = value(positions, row, column)
+		}
+		return key
+	}
+	join := func(key []string) string { return strings.Join(key, "\x1f") }
+
+	// Base rows by key, dropped as compare rows claim them
+	baseRows := make(map[string][]string, len(base.Rows))
+	var baseOrder []string
+	for _, row := range base.Rows {
+		k := join(keyOf(basePositions, row))
+		if _, ok := baseRows[k]; ok {
+			diff.Summary.DuplicateKeys++
+			continue
+		}
+		baseRows[k] = row
+		baseOrder = append(baseOrder, k)
+	}
+
+	seen := make(map[string]bool, len(compare.Rows))
+	for _, row := range compare.Rows {
+		key := keyOf(comparePositions, row)
+		k := join(key)
+		if seen[k] {
+			diff.Summary.DuplicateKeys++
+			continue
+		}
+		seen[k] = true
+
+		baseRow, ok := baseRows[k]
+		if !ok {
+			diff.Summary.Added++
+			if len(diff.Added) < limit {
+				diff.Added = append(diff.Added, row)
+			}
+			continue
+		}
+		delete(baseRows, k)
+
+		var changes []CellChange
+		for _, column := range diff.Columns {
+			from, to := value(basePositions, baseRow, column), value(comparePositions, row, column)
+			if from != to {
+				changes = append(changes, CellChange{Column: column, From: from, To: to})
+			}
+		}
+		if len(changes) == 0 {
+			diff.Summary.Unchanged++
+			continue
+		}
+		diff.Summary.Changed++
+		if len(diff.Changed) < limit {
+			diff.Changed = append(diff.Changed, ChangedRow{Key: key, Changes: changes})
+		}
+	}
+	for _, k := range baseOrder {
+		row, ok := baseRows[k]
+		if !ok {
+			continue
+		}
+		diff.Summary.Removed++
+		if len(diff.Removed) < limit {
+			diff.Removed = append(diff.Removed, row)
+		}
+	}
+
+	diff.Summary.BaseRows = len(base.Rows)
+	diff.Summary.CompareRows = len(compare.Rows)
+	diff.Truncated = diff.Summary.Added > len(diff.Added) ||
+		diff.Summary.Removed > len(diff.Removed) ||
+		diff.Summary.Changed > len(diff.Changed)
+	return diff, nil
+}
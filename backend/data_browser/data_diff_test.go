@@ -0,0 +1,47 @@
+package data_browser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffByKey(t *testing.T) {
+	base := BrowseResponse{
+		Columns: []string{"id", "region", "amount", "note"},
+		Rows:    [][]string{{"1", "eu", "10", "a"}, {"2", "us", "20", "b"}, {"3", "eu", "30", "c"}, {"3", "eu", "31", "dup"}},
+	}
+	compare := BrowseResponse{
+		Columns: []string{"region", "id", "amount", "channel"},
+		Rows:    [][]string{{"eu", "1", "10", "web"}, {"us", "2", "25", "shop"}, {"apac", "4", "40", "web"}},
+	}
+
+	diff, err := diffByKey(base, compare, []string{"id"}, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(diff.Columns, []string{"region", "amount"}) ||
+		!reflect.DeepEqual(diff.ColumnsAdded, []string{"channel"}) ||
+		!reflect.DeepEqual(diff.ColumnsRemoved, []string{"note"}) {
+		t.Errorf("columns = %v, added %v, removed %v", diff.Columns, diff.ColumnsAdded, diff.ColumnsRemoved)
+	}
+	want := DiffSummary{BaseRows: 4, CompareRows: 3, Added: 1, Removed: 1, Changed: 1, Unchanged: 1, DuplicateKeys: 1}
+	if diff.Summary != want {
+		t.Errorf("summary = %+v, want %+v", diff.Summary, want)
+	}
+	if !reflect.DeepEqual(diff.Added, [][]string{{"apac", "4", "40", "web"}}) ||
+		!reflect.DeepEqual(diff.Removed, [][]string{{"3", "eu", "30", "c"}}) {
+		t.Errorf("added %v, removed %v", diff.Added, diff.Removed)
+	}
+	wantChanged := []ChangedRow{{Key: []string{"2"}, Changes: []CellChange{{Column: "amount", From: "20", To: "25"}}}}
+	if !reflect.DeepEqual(diff.Changed, wantChanged) {
+		t.Errorf("changed = %+v", diff.Changed)
+	}
+
+	// Comparing only region, the changed amount goes unnoticed
+	if diff, err := diffByKey(base, compare, []string{"id"}, []string{"region"}, 10); err != nil || diff.Summary.Changed != 0 {
+		t.Errorf("region-only diff = %+v, %v", diff, err)
+	}
+	if _, err := diffByKey(base, compare, []string{"note"}, nil, 10); err == nil {
+		t.Error("a key column one side lacks was accepted")
+	}
+}
@@ -0,0 +1,79 @@
+package data_browser
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tealeg/xlsx/v3"
+)
+
+// maxExcelSerial is the serial of 9999-12-31, the last date Excel shows.
+const maxExcelSerial = 2958465
+
+// dateSampleRows is how many data rows are looked at to decide whether a
+// column holds dates.
+const dateSampleRows = 1000
+
+// isNumericCell reports whether cell holds a number, which is how Excel
+// stores dates and times.
+func isNumericCell(cell *xlsx.Cell) bool {
+	t := cell.Type()
+	return t == xlsx.CellTypeNumeric || t == xlsx.CellTypeDate
+}
+
+// detectDateColumns returns the indexes of the columns of rows whose
+// numbers are dates: the columns named in names, matched case-insensitively
+// against header, and those where most numbers have a date or time format.
+// Columns whose numbers are shown as plain numbers, like a General "44927",
+// are only dates when named.
+func detectDateColumns(rows []*xlsx.Row, header, names []string) map[int]bool {
+	dates := make(map[int]bool)
+	for i, column := range header {
+		if slices.ContainsFunc(names, func(name string) bool {
+			return strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(column))
+		}) {
+			dates[i] = true
+		}
+	}
+
+	numbers := make(map[int]int)
+	formatted := make(map[int]int)
+	for _, row := range rows[:min(len(rows), dateSampleRows)] {
+		row.ForEachCell(func(cell *xlsx.Cell) error {
+			if !isNumericCell(cell) || cell.Value == "" {
+				return nil
+			}
+			col, _ := cell.GetCoordinates()
+			numbers[col]++
+			if cell.IsTime() {
+				formatted[col]++
+			}
+			return nil
+		})
+	}
+	for col, n := range numbers {
+		if formatted[col]*2 > n {
+			dates[col] = true
+		}
+	}
+	return dates
+}
+
+// excelDate renders an Excel date serial as ISO 8601: a date, a date and
+// time, or a time of day for serials below 1. It reports false for numbers
+// outside the range of Excel dates.
+func excelDate(serial float64, date1904 bool) (string, bool) {
+	if serial < 0 || serial > maxExcelSerial || math.IsNaN(serial) {
+		return "", false
+	}
+	t := xlsx.TimeFromExcelTime(serial, date1904).Round(time.Millisecond)
+	switch {
+	case serial < 1:
+		return t.Format("15:04:05.999"), true
+	case serial == math.Trunc(serial):
+		return t.Format("2006-01-02"), true
+	}
+	return t.Format("2006-01-02T15:04:05.999"), true
+}
@@ -0,0 +1,91 @@
+package data_browser
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/tealeg/xlsx/v3"
+)
+
+// datesWorkbook returns an .xlsx file with a header row and two data rows:
+// Invoiced holds formatted dates, Due unformatted serials, Amount numbers
+// and Time formatted times of day.
+func datesWorkbook(t *testing.T) []byte {
+	t.Helper()
+	wb := xlsx.NewFile()
+	sheet, err := wb.AddSheet("Data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := sheet.AddRow()
+	for _, name := range []string{"Invoiced", "Due", "Amount", "Time"} {
+		header.AddCell().SetString(name)
+	}
+	for _, serial := range []float64{44927, 44927.75} {
+		row := sheet.AddRow()
+		row.AddCell().SetDateTimeWithFormat(serial, "dd/mm/yyyy")
+		row.AddCell().SetFloat(serial + 30)
+		row.AddCell().SetFloat(serial)
+		row.AddCell().SetDateTimeWithFormat((serial-44927)/3+0.5, "h:mm")
+	}
+
+	var buf bytes.Buffer
+	if err := wb.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNormalizeDates(t *testing.T) {
+	browse := func(request BrowseRequest) [][]string {
+		t.Helper()
+		request.FileName, request.MaxRows, request.HasHeaders = "book.xlsx", 100, true
+		response, err := (&DataBrowserHandler{}).processExcelFile(datesWorkbook(t), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response.Rows
+	}
+
+	rows := browse(BrowseRequest{NormalizeDates: true})
+	want := [][]string{
+		{"2023-01-01", "44957", "44927", "12:00:00"},
+		{"2023-01-01T18:00:00", "44957.75", "44927.75", "18:00:00"},
+	}
+	if !slices.EqualFunc(rows, want, slices.Equal) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+
+	rows = browse(BrowseRequest{DateColumns: []string{"due"}})
+	if rows[0][1] != "2023-01-31" || rows[0][2] != "44927" {
+		t.Errorf("row with Due named = %q", rows[0])
+	}
+
+	rows = browse(BrowseRequest{})
+	if rows[0][0] == "2023-01-01" {
+		t.Errorf("dates were normalized without being asked to: %q", rows[0])
+	}
+}
+
+func TestExcelDate(t *testing.T) {
+	for _, tt := range []struct {
+		serial   float64
+		date1904 bool
+		want     string
+		ok       bool
+	}{
+		{44927, false, "2023-01-01", true},
+		{44927.5, false, "2023-01-01T12:00:00", true},
+		{44927 + 1.0/3, false, "2023-01-01T08:00:00", true},
+		{43465, true, "2023-01-01", true}, // 1904 date system
+		{0.25, false, "06:00:00", true},
+		{-1, false, "", false},
+		{3e6, false, "", false},
+	} {
+		got, ok := excelDate(tt.serial, tt.date1904)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("excelDate(%v, %v) = %q, %v, want %q, %v", tt.serial, tt.date1904, got, ok, tt.want, tt.ok)
+		}
+	}
+}
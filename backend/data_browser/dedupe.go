@@ -0,0 +1,108 @@
+package data_browser
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DedupeWholeRow is the dedupe_keys value comparing rows on every column.
+const DedupeWholeRow = "*"
+
+// rowKey identifies a row by the hash of its key values.
+type rowKey [sha256.Size]byte
+
+// applyDedupe drops the rows of results whose keys were seen before, in the
+// same file or an earlier one, and records how many each file lost. keys
+// name the columns compared, after ColumnRules apply; DedupeWholeRow
+// compares every column by name, so rows of files with different columns
+// match when the columns they do not share are empty. A file lacking a key
+// column fails.
+func applyDedupe(results []ProcessingResult, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	wholeRow := slices.Contains(keys, DedupeWholeRow)
+	seen := make(map[rowKey]bool)
+
+	for i := range results {
+		result := &results[i]
+		if !result.Success {
+			continue
+		}
+
+		var columns []int
+		if wholeRow {
+			// Compare columns in name order, which files may not share
+			columns = make([]int, len(result.Columns))
+			for c := range columns {
+				columns[c] = c
+			}
+			slices.SortFunc(columns, func(a, b int) int {
+				return cmp.Compare(strings.ToLower(result.Columns[a]), strings.ToLower(result.Columns[b]))
+			})
+		} else {
+			var missing []string
+			for _, key := range keys {
+				c := slices.IndexFunc(result.Columns, func(column string) bool { return strings.EqualFold(column, key) })
+				if c < 0 {
+					missing = append(missing, key)
+				}
+				columns = append(columns, c)
+			}
+			if len(missing) > 0 {
+				result.Success = false
+				result.Errors = append(result.Errors, ExportRowError{
+					FileName:     result.FileName,
+					SheetName:    result.SheetName,
+					ErrorCode:    "DEDUPE_KEY_MISSING",
+					ErrorMsg:     fmt.Sprintf("file has no dedupe key column %s", strings.Join(missing, ", ")),
+					SuggestedFix: "Check dedupe_keys against the file's columns after renames",
+				})
+				continue
+			}
+		}
+
+		kept := result.Rows[:0]
+		for _, row := range result.Rows {
+			key := hashRowKey(row, result.Columns, columns, wholeRow)
+			if seen[key] {
+				result.Duplicates++
+				continue
+			}
+			seen[key] = true
+			kept = append(kept, row)
+		}
+		result.Rows = kept
+		result.RowCount = len(kept)
+	}
+}
+
+// hashRowKey hashes the values of row in columns. Whole rows hash their
+// non-empty values with their column names.
+func hashRowKey(row, names []string, columns []int, wholeRow bool) rowKey {
+	h := sha256.New()
+	write := func(s string) {
+		h.Write(binary.AppendUvarint(nil, uint64(len(s))))
+		h.Write([]byte(s))
+	}
+	for _, c := range columns {
+		value := ""
+		if c < len(row) {
+			value = row[c]
+		}
+		if wholeRow {
+			if value == "" {
+				continue
+			}
+			write(strings.ToLower(names[c]))
+		}
+		write(value)
+	}
+	var key rowKey
+	h.Sum(key[:0])
+	return key
+}
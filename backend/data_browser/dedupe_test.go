@@ -0,0 +1,58 @@
+package data_browser
+
+import (
+	"slices"
+	"testing"
+)
+
+func dedupeResults() []ProcessingResult {
+	return []ProcessingResult{
+		{
+			FileName: "a.csv",
+			Success:  true,
+			Columns:  []string{"id", "name"},
+			Rows:     [][]string{{"1", "ann"}, {"2", "bob"}, {"1", "ann"}, {"1", "anne"}},
+		},
+		{
+			FileName: "b.csv",
+			Success:  true,
+			Columns:  []string{"Name", "ID", "note"},
+			Rows:     [][]string{{"bob", "2", ""}, {"cy", "3", ""}, {"ann", "1", "vip"}},
+		},
+	}
+}
+
+func TestApplyDedupeByKeys(t *testing.T) {
+	results := dedupeResults()
+	applyDedupe(results, []string{"ID"})
+
+	if want := [][]string{{"1", "ann"}, {"2", "bob"}}; !slices.EqualFunc(results[0].Rows, want, slices.Equal) {
+		t.Errorf("a.csv rows = %q, want %q", results[0].Rows, want)
+	}
+	if want := [][]string{{"cy", "3", ""}}; !slices.EqualFunc(results[1].Rows, want, slices.Equal) {
+		t.Errorf("b.csv rows = %q, want %q", results[1].Rows, want)
+	}
+	if results[0].Duplicates != 2 || results[1].Duplicates != 2 || results[1].RowCount != 1 {
+		t.Errorf("duplicates = %d, %d", results[0].Duplicates, results[1].Duplicates)
+	}
+
+	results = dedupeResults()
+	applyDedupe(results, []string{"note"})
+	if results[0].Success || results[0].Errors[0].ErrorCode != "DEDUPE_KEY_MISSING" || !results[1].Success {
+		t.Errorf("file without the key: %+v", results[0])
+	}
+}
+
+func TestApplyDedupeWholeRow(t *testing.T) {
+	results := dedupeResults()
+	applyDedupe(results, []string{DedupeWholeRow})
+
+	// Rows match across column orders, and an empty note matches a file
+	// without the column
+	if results[0].Duplicates != 1 || results[1].Duplicates != 1 {
+		t.Errorf("duplicates = %d, %d, want 1, 1", results[0].Duplicates, results[1].Duplicates)
+	}
+	if want := [][]string{{"cy", "3", ""}, {"ann", "1", "vip"}}; !slices.EqualFunc(results[1].Rows, want, slices.Equal) {
+		t.Errorf("b.csv rows = %q, want %q", results[1].Rows, want)
+	}
+}
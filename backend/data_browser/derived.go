@@ -0,0 +1,139 @@
+package data_browser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"bronze-backend/httputil"
+)
+
+// DerivedColumn is a column added to every exported row, computed by an
+// expression in Excel formula syntax. The expression refers to the row's
+// columns by name, as amount or [Unit Price] for names that are not
+// identifiers, whose values read as numbers where they are. It may use the
+// functions of formula_mode evaluate and:
+//   - NOW(): when the export started, as RFC 3339 in UTC
+//   - TODAY(): the date the export started, as 2006-01-02
+//   - SOURCE_FILE(): the object the row was read from
+//   - SOURCE_SHEET(): the sheet or table the row was read from, if chosen
+//
+// Excel errors such as #DIV/0! export as NULL.
+type DerivedColumn struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+// errUnknownColumn is returned for an expression naming a column the file
+// lacks.
+type errUnknownColumn string
+
+func (e errUnknownColumn) Error() string {
+	return fmt.Sprintf("unknown column %q", string(e))
+}
+
+// validateDerivedColumns checks that derived columns are named uniquely and
+// that their expressions parse, before any file is read.
+func validateDerivedColumns(columns []DerivedColumn) error {
+	seen := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		name := strings.ToLower(column.Name)
+		if name == "" {
+			return httputil.NewError(httputil.CodeBadRequest, "derived column without a name", nil)
+		}
+		if seen[name] {
+			return httputil.NewError(httputil.CodeBadRequest, fmt.Sprintf("derived column %q is defined twice", column.Name), nil)
+		}
+		seen[name] = true
+
+		anyName := func(string) (any, error) { return nil, nil }
+		if _, err := evaluateDerived(column.Expression, anyName, derivedFunctions("", "", time.Time{})); err != nil {
+			return httputil.NewError(httputil.CodeBadRequest, fmt.Sprintf("derived column %q: invalid expression %q", column.Name, column.Expression), err)
+		}
+	}
+	return nil
+}
+
+// derivedFunctions are the functions of derived columns describing where
+// a row came from.
+func derivedFunctions(source, sheet string, now time.Time) map[string]func(args []any) (any, error) {
+	constant := func(value any) func(args []any) (any, error) {
+		return func(args []any) (any, error) {
+			if len(args) != 0 {
+				return nil, errUnsupportedFormula
+			}
+			return value, nil
+		}
+	}
+	now = now.UTC()
+	return map[string]func(args []any) (any, error){
+		"NOW":          constant(now.Format(time.RFC3339)),
+		"TODAY":        constant(now.Format("2006-01-02")),
+		"SOURCE_FILE":  constant(source),
+		"SOURCE_SHEET": constant(sheet),
+	}
+}
+
+// evaluateDerived computes expression, resolving column names with names.
+func evaluateDerived(expression string, names func(name string) (any, error), functions map[string]func(args []any) (any, error)) (string, error) {
+	p := &formulaParser{src: strings.TrimPrefix(strings.TrimSpace(expression), "="), names: names, functions: functions}
+	value, err := p.parse()
+	if err != nil {
+		return "", err
+	}
+	value = scalar(value)
+	if _, ok := value.(formulaError); ok {
+		return "", nil
+	}
+	return formulaText(value), nil
+}
+
+// addDerivedColumns appends the derived columns to the columns and rows of
+// result. A derived column may use those defined before it.
+func addDerivedColumns(result *ProcessingResult, columns []DerivedColumn, now time.Time) error {
+	index := make(map[string]int, len(result.Columns)+len(columns))
+	for i, column := range result.Columns {
+		index[strings.ToLower(column)] = i
+	}
+	width := len(result.Columns)
+	for i, column := range columns {
+		if _, ok := index[strings.ToLower(column.Name)]; ok {
+			return fmt.Errorf("derived column %q is also a column of the file", column.Name)
+		}
+		index[strings.ToLower(column.Name)] = width + i
+	}
+
+	functions := derivedFunctions(result.FileName, result.SheetName, now)
+	for r, row := range result.Rows {
+		values := make([]string, width, width+len(columns))
+		copy(values, row)
+		names := func(name string) (any, error) {
+			i, ok := index[strings.ToLower(name)]
+			if !ok || i >= len(values) {
+				return nil, errUnknownColumn(name)
+			}
+			// Files hold text, which reads as a number where it is one
+			if values[i] == "" {
+				return nil, nil
+			}
+			if n, err := strconv.ParseFloat(values[i], 64); err == nil {
+				return n, nil
+			}
+			return values[i], nil
+		}
+		for _, column := range columns {
+			value, err := evaluateDerived(column.Expression, names, functions)
+			if err != nil {
+				return fmt.Errorf("derived column %q: %w", column.Name, err)
+			}
+			values = append(values, value)
+		}
+		result.Rows[r] = values
+	}
+
+	for _, column := range columns {
+		result.Columns = append(result.Columns, column.Name)
+	}
+	return nil
+}
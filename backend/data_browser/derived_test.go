@@ -0,0 +1,65 @@
+package data_browser
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"bronze-backend/httputil"
+)
+
+func TestApplyDerivedColumns(t *testing.T) {
+	now := time.Date(2024, 3, 5, 14, 30, 0, 0, time.FixedZone("WIB", 7*3600))
+	results := []ProcessingResult{
+		{
+			FileName: "sales/march.csv",
+			Success:  true,
+			Columns:  []string{"amount", "Exchange Rate", "region"},
+			Rows:     [][]string{{"10", "1.5", "north"}, {"4", "0", ""}, {"x"}},
+		},
+		{FileName: "sales/other.csv", Success: true, Columns: []string{"total"}, Rows: [][]string{{"1"}}},
+	}
+	applyDerivedColumns(results, []DerivedColumn{
+		{Name: "amount_usd", Expression: "amount * [exchange rate]"},
+		{Name: "per_rate", Expression: "=amount / [Exchange Rate]"},
+		{Name: "load_date", Expression: "now()"},
+		{Name: "source_file", Expression: "SOURCE_FILE()"},
+		{Name: "label", Expression: `IF(region="", "unknown", UPPER(region)) & "-" & ROUND(amount_usd, 0)`},
+	}, now)
+
+	if want := []string{"amount", "Exchange Rate", "region", "amount_usd", "per_rate", "load_date", "source_file", "label"}; !slices.Equal(results[0].Columns, want) {
+		t.Errorf("columns = %q, want %q", results[0].Columns, want)
+	}
+	want := [][]string{
+		{"10", "1.5", "north", "15", "6.66666666666667", "2024-03-05T07:30:00Z", "sales/march.csv", "NORTH-15"},
+		{"4", "0", "", "0", "", "2024-03-05T07:30:00Z", "sales/march.csv", "unknown-0"},
+		{"x", "", "", "", "", "2024-03-05T07:30:00Z", "sales/march.csv", "unknown-0"}, // #VALUE! exports as NULL
+	}
+	if !slices.EqualFunc(results[0].Rows, want, slices.Equal) {
+		t.Errorf("rows = %q, want %q", results[0].Rows, want)
+	}
+
+	// The second file has no amount column
+	if results[1].Success || len(results[1].Errors) != 1 || results[1].Errors[0].ErrorCode != "DERIVED_COLUMN_ERROR" {
+		t.Errorf("file without the columns used: %+v", results[1])
+	}
+}
+
+func TestValidateDerivedColumns(t *testing.T) {
+	for name, columns := range map[string][]DerivedColumn{
+		"no name":          {{Expression: "1"}},
+		"defined twice":    {{Name: "a", Expression: "1"}, {Name: "A", Expression: "2"}},
+		"syntax":           {{Name: "a", Expression: "amount *"}},
+		"unknown function": {{Name: "a", Expression: "VLOOKUP(amount)"}},
+		"cell reference":   {{Name: "a", Expression: "Sheet1!A1"}},
+	} {
+		var apiErr *httputil.APIError
+		if err := validateDerivedColumns(columns); !errors.As(err, &apiErr) || apiErr.Code != httputil.CodeBadRequest {
+			t.Errorf("%s: error %v, want a bad request", name, err)
+		}
+	}
+	if err := validateDerivedColumns([]DerivedColumn{{Name: "a", Expression: "[Unit Price] * 2 & TODAY()"}}); err != nil {
+		t.Errorf("valid expression: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package data_browser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// gzipSuffix marks a gzipped data file, read as the file it compresses:
+// sales.csv.gz reads as sales.csv.
+const gzipSuffix = ".gz"
+
+// dataExt returns the lower-case extension of a data file, that of the
+// name under .gz for a gzipped one.
+func dataExt(fileName string) string {
+	name := strings.ToLower(fileName)
+	return filepath.Ext(strings.TrimSuffix(name, gzipSuffix))
+}
+
+// isTextExt reports whether files of ext are read as text.
+func isTextExt(ext string) bool {
+	switch ext {
+	case ".csv", ".json", ".jsonl", ".ndjson":
+		return true
+	}
+	return false
+}
+
+// decodeData returns the contents of a data file as its reader expects
+// them: decompressed when gzipped and, for text, with its byte order mark
+// removed, UTF-16 being converted to UTF-8.
+func decodeData(fileName string, data []byte, text bool) ([]byte, error) {
+	if strings.HasSuffix(strings.ToLower(fileName), gzipSuffix) {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", fileName, err)
+		}
+		data, err = io.ReadAll(gz)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", fileName, err)
+		}
+	}
+	if !text {
+		return data, nil
+	}
+	decoded, _, err := transform.Bytes(bomDecoder(), data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", fileName, err)
+	}
+	return decoded, nil
+}
+
+// bomDecoder removes a UTF-8 byte order mark and converts text starting
+// with a UTF-16 one to UTF-8. Text without one is left as it is.
+func bomDecoder() transform.Transformer {
+	return unicode.BOMOverride(transform.Nop)
+}
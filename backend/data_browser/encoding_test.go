@@ -0,0 +1,71 @@
+package data_browser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"slices"
+	"testing"
+	"unicode/utf16"
+)
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func utf16LE(s string) []byte {
+	data := []byte{0xFF, 0xFE}
+	for _, unit := range utf16.Encode([]rune(s)) {
+		data = append(data, byte(unit), byte(unit>>8))
+	}
+	return data
+}
+
+func TestDataExt(t *testing.T) {
+	for name, want := range map[string]string{
+		"sales.csv":       ".csv",
+		"sales.CSV.GZ":    ".csv",
+		"events.json.gz":  ".json",
+		"archive.gz":      "",
+		"report.xlsx":     ".xlsx",
+		"dir.gz/data.csv": ".csv",
+	} {
+		if got := dataExt(name); got != want {
+			t.Errorf("dataExt(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestReadDataDecodesBOMAndGzip(t *testing.T) {
+	const csv = "id,name\n1,Zoë\n"
+	tests := map[string][]byte{
+		"plain.csv":      []byte(csv),
+		"utf8bom.csv":    append([]byte("\xEF\xBB\xBF"), csv...),
+		"utf16.csv":      utf16LE(csv),
+		"sales.csv.gz":   gzipped(t, append([]byte("\xEF\xBB\xBF"), csv...)),
+		"utf16.csv.gz":   gzipped(t, utf16LE(csv)),
+		"events.json.gz": gzipped(t, []byte("\xEF\xBB\xBF"+`[{"id": 1, "name": "Zoë"}]`)),
+	}
+	for name, data := range tests {
+		response, err := (&DataBrowserHandler{}).readData(data, BrowseRequest{FileName: name, MaxRows: 10, HasHeaders: true})
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !slices.Equal(response.Columns, []string{"id", "name"}) || len(response.Rows) != 1 || response.Rows[0][1] != "Zoë" {
+			t.Errorf("%s: columns %q, rows %q", name, response.Columns, response.Rows)
+		}
+	}
+
+	if _, err := (&DataBrowserHandler{}).readData([]byte(csv), BrowseRequest{FileName: "bad.csv.gz", MaxRows: 10}); err == nil {
+		t.Error("a .gz file that is not gzipped was read")
+	}
+}
@@ -0,0 +1,135 @@
+package data_browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// tableWriter stages the rows of an export and commits or discards them;
+// *storage.NessieClient is one.
+type tableWriter interface {
+	StageRows(ctx context.Context, database, tableName, stageID string, rows []map[string]interface{}) error
+	CommitStaged(ctx context.Context, database, tableName, stageID string) (string, error)
+	DiscardStaged(ctx context.Context, database, tableName, stageID string) error
+	DropTable(ctx context.Context, database, tableName string) error
+}
+
+// stagedExport writes the rows of an export to its table in batches staged
+// under one ID, which become visible together when committed, so the table
+// never holds part of an export.
+type stagedExport struct {
+	writer       tableWriter
+	database     string
+	table        string
+	stageID      string
+	batchSize    int
+	createdTable bool // The export created the table, so rolling back drops it
+	staged       bool // Some batch was staged
+}
+
+// exportOutcome is how a staged export ended.
+type exportOutcome struct {
+	rows       int    // Rows committed
+	failed     int    // Errors of the files that failed
+	commitID   string // The Nessie commit of the rows, if any
+	rolledBack bool
+	stoppedBy  string // The failed file the export was rolled back for
+}
+
+// write stages the rows of the files read and commits them as one Nessie
+// commit. Failed files are left out, unless transactional or stopOnError
+// is set: then nothing is committed if any file failed, and what was
+// staged is rolled back. stopOnError also stops at the first failed file
+// rather than counting the errors of the rest. An error staging or
+// committing rolls the export back too, and is returned.
+func (e *stagedExport) write(ctx context.Context, results []ProcessingResult, transactional, stopOnError bool) (exportOutcome, error) {
+	var outcome exportOutcome
+	for _, result := range results {
+		if !result.Success {
+			outcome.failed += len(result.Errors)
+			if outcome.stoppedBy == "" && (transactional || stopOnError) {
+				outcome.stoppedBy = result.FileName
+			}
+			if stopOnError {
+				break
+			}
+			continue
+		}
+		if outcome.stoppedBy != "" {
+			continue // Rolled back anyway
+		}
+		if err := e.stage(ctx, result); err != nil {
+			outcome.rolledBack = true
+			return outcome, e.rollback(ctx, err)
+		}
+		outcome.rows += len(result.Rows)
+	}
+
+	if outcome.stoppedBy != "" {
+		log.Printf("Rolling back export to %s.%s: %s failed", e.database, e.table, outcome.stoppedBy)
+		outcome.rows = 0
+		outcome.rolledBack = true
+		return outcome, e.rollback(ctx, nil)
+	}
+	if !e.staged {
+		return outcome, nil
+	}
+
+	commitID, err := e.writer.CommitStaged(ctx, e.database, e.table, e.stageID)
+	if err != nil {
+		outcome.rows = 0
+		outcome.rolledBack = true
+		return outcome, e.rollback(ctx, fmt.Errorf("failed to commit rows: %w", err))
+	}
+	outcome.commitID = commitID
+	return outcome, nil
+}
+
+// stage stages the rows of result in batches. Empty values are NULL.
+func (e *stagedExport) stage(ctx context.Context, result ProcessingResult) error {
+	for start := 0; start < len(result.Rows); start += e.batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := min(start+e.batchSize, len(result.Rows))
+		batch := make([]map[string]interface{}, 0, end-start)
+		for _, row := range result.Rows[start:end] {
+			values := make(map[string]interface{}, len(result.Columns))
+			for i, column := range result.Columns {
+				if i < len(row) && row[i] != "" {
+					values[column] = row[i]
+				} else {
+					values[column] = nil
+				}
+			}
+			batch = append(batch, values)
+		}
+		// The stage exists once a batch was sent, even if sending failed
+		e.staged = true
+		if err := e.writer.StageRows(ctx, e.database, e.table, e.stageID, batch); err != nil {
+			return fmt.Errorf("failed to stage rows of %s: %w", result.FileName, err)
+		}
+	}
+	return nil
+}
+
+// rollback discards what was staged and drops the table if the export
+// created it, returning cause with any error doing so. It runs even when
+// ctx was cancelled, which may be why the export is rolled back.
+func (e *stagedExport) rollback(ctx context.Context, cause error) error {
+	ctx = context.WithoutCancel(ctx)
+	errs := []error{cause}
+	if e.staged {
+		if err := e.writer.DiscardStaged(ctx, e.database, e.table, e.stageID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to discard staged rows: %w", err))
+		}
+	}
+	if e.createdTable {
+		if err := e.writer.DropTable(ctx, e.database, e.table); err != nil {
+			errs = append(errs, fmt.Errorf("failed to drop table created by the export: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
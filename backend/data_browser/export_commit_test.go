@@ -0,0 +1,112 @@
+package data_browser
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// recordingWriter records what an export did to its table.
+type recordingWriter struct {
+	calls     []string
+	batches   [][]map[string]interface{}
+	stageErr  error
+	commitErr error
+}
+
+func (w *recordingWriter) StageRows(_ context.Context, _, _, _ string, rows []map[string]interface{}) error {
+	w.calls = append(w.calls, "stage")
+	w.batches = append(w.batches, rows)
+	return w.stageErr
+}
+
+func (w *recordingWriter) CommitStaged(context.Context, string, string, string) (string, error) {
+	w.calls = append(w.calls, "commit")
+	return "abc123", w.commitErr
+}
+
+func (w *recordingWriter) DiscardStaged(context.Context, string, string, string) error {
+	w.calls = append(w.calls, "discard")
+	return nil
+}
+
+func (w *recordingWriter) DropTable(context.Context, string, string) error {
+	w.calls = append(w.calls, "drop")
+	return nil
+}
+
+func TestStagedExportWrite(t *testing.T) {
+	ok := ProcessingResult{FileName: "a.csv", Success: true, Columns: []string{"id", "name"}, Rows: [][]string{{"1", "Ann"}, {"2", ""}, {"3"}}}
+	failed := ProcessingResult{FileName: "b.csv", Errors: []ExportRowError{{ErrorCode: "FILE_PROCESSING_ERROR"}}}
+	later := ProcessingResult{FileName: "c.csv", Success: true, Columns: []string{"id"}, Rows: [][]string{{"4"}}}
+	results := []ProcessingResult{ok, failed, later}
+
+	for _, tt := range []struct {
+		name                 string
+		transactional, stop  bool
+		createdTable         bool
+		wantCalls            []string
+		wantRows, wantFailed int
+	}{
+		// Failed files are left out of one commit
+		{name: "default", wantCalls: []string{"stage", "stage", "stage", "commit"}, wantRows: 4, wantFailed: 1},
+		{name: "transactional", transactional: true, wantCalls: []string{"stage", "stage", "discard"}, wantFailed: 1},
+		{name: "stop on error", stop: true, createdTable: true, wantCalls: []string{"stage", "stage", "discard", "drop"}, wantFailed: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &recordingWriter{}
+			export := &stagedExport{writer: w, database: "bronze", table: "t", stageID: "s1", batchSize: 2, createdTable: tt.createdTable}
+			outcome, err := export.write(context.Background(), results, tt.transactional, tt.stop)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(w.calls, tt.wantCalls) {
+				t.Errorf("calls = %q, want %q", w.calls, tt.wantCalls)
+			}
+			if outcome.rows != tt.wantRows || outcome.failed != tt.wantFailed {
+				t.Errorf("outcome = %+v, want %d rows and %d failed", outcome, tt.wantRows, tt.wantFailed)
+			}
+			rolledBack := tt.transactional || tt.stop
+			if outcome.rolledBack != rolledBack || (outcome.stoppedBy == "b.csv") != rolledBack || (outcome.commitID != "") == rolledBack {
+				t.Errorf("outcome = %+v, rolled back %v", outcome, rolledBack)
+			}
+		})
+	}
+
+	// Rows are staged in batches, empty values as NULL
+	w := &recordingWriter{}
+	export := &stagedExport{writer: w, batchSize: 2}
+	if _, err := export.write(context.Background(), []ProcessingResult{ok}, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.batches) != 2 || len(w.batches[0]) != 2 || w.batches[0][1]["name"] != nil || w.batches[1][0]["id"] != "3" || w.batches[1][0]["name"] != nil {
+		t.Errorf("batches = %v", w.batches)
+	}
+}
+
+func TestStagedExportRollsBackOnError(t *testing.T) {
+	results := []ProcessingResult{{FileName: "a.csv", Success: true, Columns: []string{"id"}, Rows: [][]string{{"1"}}}}
+
+	w := &recordingWriter{commitErr: errors.New("conflict")}
+	export := &stagedExport{writer: w, batchSize: 10, createdTable: true}
+	outcome, err := export.write(context.Background(), results, false, false)
+	if err == nil || !outcome.rolledBack || outcome.rows != 0 {
+		t.Errorf("failed commit: %+v, %v", outcome, err)
+	}
+	if want := []string{"stage", "commit", "discard", "drop"}; !slices.Equal(w.calls, want) {
+		t.Errorf("calls = %q, want %q", w.calls, want)
+	}
+
+	// A cancelled export stages nothing more and still rolls back
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = &recordingWriter{}
+	export = &stagedExport{writer: w, batchSize: 10, createdTable: true}
+	if _, err := export.write(ctx, results, false, false); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled export: %v", err)
+	}
+	if want := []string{"drop"}; !slices.Equal(w.calls, want) {
+		t.Errorf("calls = %q, want %q", w.calls, want)
+	}
+}
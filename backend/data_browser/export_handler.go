@@ -3,14 +3,26 @@ package data_browser
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"bronze-backend/auth"
 	"bronze-backend/config"
+	"bronze-backend/httputil"
+	"bronze-backend/jobs"
+	"bronze-backend/metering"
+	"bronze-backend/quarantine"
+	"bronze-backend/realtime"
 	"bronze-backend/storage"
+	"bronze-backend/tenant"
+
+	"github.com/google/uuid"
 )
 
 type ExportRequest struct {
@@ -21,20 +33,54 @@ type ExportRequest struct {
 	MaxErrors          int              `json:"max_errors,omitempty"`
 	StopOnError        bool             `json:"stop_on_error,omitempty"`
 	CollectErrors      bool             `json:"collect_errors,omitempty"`
-	SchemaResolution   string           `json:"schema_resolution,omitempty"` // "merge", "first_file", "manual"
+	SchemaResolution   string           `json:"schema_resolution,omitempty"` // "merge", "first_file", "manual", "preserve_order"
 	MaxConcurrent      int              `json:"max_concurrent_files,omitempty"`
 	BatchSize          int              `json:"batch_size,omitempty"`
 	AutoTypeConversion bool             `json:"auto_type_conversion,omitempty"`
+	// FormulaMode is what Excel formula cells export as, for files that
+	// set none; see BrowseRequest.FormulaMode
+	FormulaMode string `json:"formula_mode,omitempty"`
+	// NormalizeDates exports the Excel dates of every file as ISO 8601; see
+	// BrowseRequest.NormalizeDates
+	NormalizeDates bool `json:"normalize_dates,omitempty"`
+	// CleanOptions apply to every file
+	CleanOptions
+	// ColumnRules apply to every file, before the table is created
+	ColumnRules
+	// ColumnMatching, when set, renames the columns of every file to the
+	// columns they match of the table an append writes to; see MatchOptions
+	ColumnMatching *MatchOptions `json:"column_matching,omitempty"`
+	// DedupeKeys are the columns rows are compared on to drop duplicates
+	// across all files, or DedupeWholeRow; see applyDedupe
+	DedupeKeys []string `json:"dedupe_keys,omitempty"`
+	// DerivedColumns are added to every row, after ColumnRules apply
+	DerivedColumns []DerivedColumn `json:"derived_columns,omitempty"`
+	// ID names the export in its realtime events; one is generated if empty
+	ID string `json:"id,omitempty"`
+	// Transactional commits nothing unless every file succeeds, as
+	// StopOnError does without stopping at the first failed file
+	Transactional bool `json:"transactional,omitempty"`
+	// OnSchemaDrift is what an export whose columns drifted from the
+	// table's registered schema does: "warn", "block" or "off". It
+	// defaults to EXPORT_SCHEMA_DRIFT
+	OnSchemaDrift string `json:"on_schema_drift,omitempty"`
 }
 
 type FileExportInfo struct {
-	FileName   string `json:"file_name"`
-	SheetName  string `json:"sheet_name,omitempty"`
-	TreatAsCSV bool   `json:"treat_as_csv,omitempty"`
+	FileName    string   `json:"file_name"`
+	SheetName   string   `json:"sheet_name,omitempty"`
+	TreatAsCSV  bool     `json:"treat_as_csv,omitempty"`
+	FormulaMode string   `json:"formula_mode,omitempty"`
+	DateColumns []string `json:"date_columns,omitempty"`
+	// NoHeaders reads the first row as data rather than column names
+	NoHeaders bool `json:"no_headers,omitempty"`
+	// Headers name the columns; see BrowseRequest.Headers
+	Headers []string `json:"headers,omitempty"`
 }
 
 type ExportResponse struct {
 	Success          bool                           `json:"success"`
+	Code             httputil.Code                  `json:"code,omitempty"` // Why the export failed
 	Message          string                         `json:"message"`
 	TableName        string                         `json:"table_name"`
 	FilesProcessed   int                            `json:"files_processed"`
@@ -45,6 +91,21 @@ type ExportResponse struct {
 	RowErrors        []ExportRowError               `json:"row_errors,omitempty"`
 	ErrorSummary     map[string]int                 `json:"error_summary,omitempty"`
 	Database         string                         `json:"database,omitempty"`
+	Subject          string                         `json:"subject,omitempty"` // Caller who ran the export
+	ExportID         string                         `json:"export_id,omitempty"`
+	// DuplicatesSkipped counts the duplicate rows dropped per source file
+	DuplicatesSkipped map[string]int `json:"duplicates_skipped,omitempty"`
+	// CommitID is the Nessie commit holding the exported rows
+	CommitID string `json:"commit_id,omitempty"`
+	// RolledBack is set when rows were staged or a table created, and
+	// undone because the export failed
+	RolledBack bool `json:"rolled_back,omitempty"`
+	// QualityJobID is the quality job queued to check the table, when it
+	// has a quality suite
+	QualityJobID string `json:"quality_job_id,omitempty"`
+	// SchemaDrift is the alert raised when the columns read drifted from
+	// the table's registered schema
+	SchemaDrift *DriftAlert `json:"schema_drift,omitempty"`
 }
 
 type ExportRowError struct {
@@ -66,33 +127,109 @@ type ProcessingResult struct {
 	RowCount  int
 	Errors    []ExportRowError
 	Success   bool
+	// Duplicates counts the rows dropped by dedupe_keys
+	Duplicates int
 }
 
 func NewExportHandler(minioClient *storage.MinIOClient, nessieClient *storage.NessieClient, cfg *config.Config, browser *DataBrowserHandler) *ExportHandler {
-	return &ExportHandler{
-		minioClient:  minioClient,
-		nessieClient: nessieClient,
-		config:       cfg,
-		browser:      browser,
+	h := &ExportHandler{
+		minioClient: minioClient,
+		config:      cfg,
+		browser:     browser,
 	}
+	h.nessieClient.Store(nessieClient)
+	return h
 }
 
 type ExportHandler struct {
 	minioClient  *storage.MinIOClient
-	nessieClient *storage.NessieClient
+	nessieClient atomic.Pointer[storage.NessieClient] // Nil until Nessie is reachable
 	config       *config.Config
 	browser      *DataBrowserHandler
+	events       *realtime.Hub
+	usage        *metering.Tracker
+	quarantine   *quarantine.Store // Nil unless quarantine is enabled
+	jobQueue     jobs.Queue        // Nil unless quality jobs are queued
+	notifier     *jobs.WebhookNotifier
+
+	historyMu sync.Mutex
+	history   []ExportRecord // Oldest first
+}
+
+// SetNessieClient enables exports once Nessie has become reachable.
+func (h *ExportHandler) SetNessieClient(client *storage.NessieClient) {
+	h.nessieClient.Store(client)
+}
+
+// NessieClient returns the Nessie client, or nil while Nessie is unreachable.
+func (h *ExportHandler) NessieClient() *storage.NessieClient {
+	return h.nessieClient.Load()
+}
+
+// SetEventHub publishes the progress of every export to the exports topic
+// of hub: export.started, export.progress for each stage, then
+// export.completed or export.failed with the response.
+func (h *ExportHandler) SetEventHub(hub *realtime.Hub) {
+	h.events = hub
+}
+
+// SetUsage accounts the rows of every export to its caller and refuses
+// exports from callers over their rows quota.
+func (h *ExportHandler) SetUsage(tracker *metering.Tracker) {
+	h.usage = tracker
+}
+
+// SetQuarantine sets aside files that keep failing to be read by exports.
+func (h *ExportHandler) SetQuarantine(q *quarantine.Store) {
+	h.quarantine = q
+}
+
+// SetJobQueue queues a quality job after each export to a table with a
+// quality suite.
+func (h *ExportHandler) SetJobQueue(queue jobs.Queue) {
+	h.jobQueue = queue
+}
+
+// SetNotifier sends schema drift alerts to the global webhook, if set.
+func (h *ExportHandler) SetNotifier(notifier *jobs.WebhookNotifier) {
+	h.notifier = notifier
+}
+
+// exportProgress is the data of an export's realtime events.
+type exportProgress struct {
+	ExportID  string          `json:"export_id"`
+	TableName string          `json:"table_name"`
+	Stage     string          `json:"stage,omitempty"`
+	Files     int             `json:"files,omitempty"`
+	Response  *ExportResponse `json:"response,omitempty"`
+}
+
+// requireNessie answers 503 and returns false while Nessie is unreachable.
+func (h *ExportHandler) requireNessie(w http.ResponseWriter) bool {
+	if h.nessieClient.Load() == nil {
+		httputil.WriteCode(w, httputil.CodeNessieUnavailable, "Nessie is not available", nil)
+		return false
+	}
+	return true
 }
 
 func (h *ExportHandler) CreateExportJob(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.requireNessie(w) {
+		return
+	}
+
+	if !h.usage.Allow(w, r, metering.MetricRows) {
 		return
 	}
 
 	var request ExportRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		h.writeError(w, "Failed to decode request", http.StatusBadRequest, err)
+		httputil.WriteError(w, "Failed to decode request", http.StatusBadRequest, err)
 		return
 	}
 
@@ -118,27 +255,47 @@ func (h *ExportHandler) CreateExportJob(w http.ResponseWriter, r *http.Request)
 		"processing_time":  response.ProcessingTime.String(),
 		"table_name":      response.TableName,
 		"database":        response.Database,
+		"subject":         response.Subject,
+		"export_id":       response.ExportID,
+	}
+	if response.CommitID != "" {
+		exportResponse["commit_id"] = response.CommitID
+	}
+	if response.QualityJobID != "" {
+		exportResponse["quality_job_id"] = response.QualityJobID
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(exportResponse)
+	status := http.StatusOK
+	if !response.Success {
+		exportResponse["code"] = response.Code
+		exportResponse["rolled_back"] = response.RolledBack
+		status = response.Code.Status()
+	}
+	httputil.WriteJSON(w, status, exportResponse)
 }
 
 func (h *ExportHandler) ExportMultipleFiles(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.requireNessie(w) {
+		return
+	}
+
+	if !h.usage.Allow(w, r, metering.MetricRows) {
 		return
 	}
 
 	var request ExportRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		h.writeError(w, "Failed to decode request", http.StatusBadRequest, err)
+		httputil.WriteError(w, "Failed to decode request", http.StatusBadRequest, err)
 		return
 	}
 
 	if len(request.Files) == 0 {
-		h.writeError(w, "No files provided for export", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "No files provided for export", http.StatusBadRequest, nil)
 		return
 	}
 
@@ -147,8 +304,44 @@ func (h *ExportHandler) ExportMultipleFiles(w http.ResponseWriter, r *http.Reque
 }
 
 func (h *ExportHandler) processExport(ctx context.Context, request ExportRequest) ExportResponse {
+	id := request.ID
+	if id == "" {
+		id = uuid.NewString()
+		request.ID = id
+	}
+	startedAt := time.Now()
+	h.events.Publish(realtime.TopicExports, "export.started", exportProgress{ExportID: id, TableName: request.TableName, Files: len(request.Files)})
+
+	stage := func(name string) {
+		h.events.Publish(realtime.TopicExports, "export.progress", exportProgress{ExportID: id, TableName: request.TableName, Stage: name})
+	}
+	response := h.runExport(ctx, request, stage)
+	response.ExportID = id
+	h.recordExport(ctx, request, response, startedAt)
+	h.usage.Add(auth.Subject(ctx), metering.MetricRows, response.RowsExported)
+
+	eventType := "export.completed"
+	if !response.Success {
+		eventType = "export.failed"
+	}
+	h.events.Publish(realtime.TopicExports, eventType, exportProgress{ExportID: id, TableName: request.TableName, Response: &response})
+	return response
+}
+
+// runExport exports the request's files, calling stage as it enters each
+// step.
+func (h *ExportHandler) runExport(ctx context.Context, request ExportRequest, stage func(name string)) ExportResponse {
 	startTime := time.Now()
 
+	nessieClient := h.nessieClient.Load()
+	if nessieClient == nil {
+		return ExportResponse{
+			Success: false,
+			Code:    httputil.CodeNessieUnavailable,
+			Message: "Nessie is not available",
+		}
+	}
+
 	// Set defaults
 	if request.MaxErrors == 0 {
 		request.MaxErrors = 1000
@@ -162,31 +355,94 @@ func (h *ExportHandler) processExport(ctx context.Context, request ExportRequest
 	if request.SchemaResolution == "" {
 		request.SchemaResolution = "merge"
 	}
+	if err := applyFormulaMode(&request); err != nil {
+		return ExportResponse{
+			Success: false,
+			Code:    httputil.CodeBadRequest,
+			Message: err.Error(),
+		}
+	}
+	if err := validateDerivedColumns(request.DerivedColumns); err != nil {
+		return ExportResponse{
+			Success: false,
+			Code:    httputil.CodeBadRequest,
+			Message: err.Error(),
+		}
+	}
+	for _, file := range request.Files {
+		if err := validateHeaders(file.Headers); err != nil {
+			return ExportResponse{
+				Success: false,
+				Code:    httputil.CodeBadRequest,
+				Message: fmt.Sprintf("%s: %v", file.FileName, err),
+			}
+		}
+	}
+	for _, normalization := range request.Normalize {
+		if _, err := normalization.validate(nil); err != nil {
+			return ExportResponse{
+				Success: false,
+				Code:    httputil.CodeBadRequest,
+				Message: err.Error(),
+			}
+		}
+	}
+	if request.ColumnMatching != nil {
+		if err := request.ColumnMatching.Validate(); err != nil {
+			return ExportResponse{
+				Success: false,
+				Code:    httputil.CodeBadRequest,
+				Message: err.Error(),
+			}
+		}
+	}
+	driftPolicy, err := h.schemaDriftPolicy(request)
+	if err != nil {
+		return ExportResponse{
+			Success: false,
+			Code:    httputil.CodeBadRequest,
+			Message: err.Error(),
+		}
+	}
 
 	database := request.Database
 	if database == "" {
 		database = h.config.Nessie.DefaultDB
 	}
 
-	log.Printf("Starting export to table '%s' with %d files, operation: %s", request.TableName, len(request.Files), request.Operation)
+	subject := auth.Subject(ctx)
+	if subject != "" {
+		log.Printf("Starting export to table '%s' with %d files, operation: %s, by %s", request.TableName, len(request.Files), request.Operation, subject)
+	} else {
+		log.Printf("Starting export to table '%s' with %d files, operation: %s", request.TableName, len(request.Files), request.Operation)
+	}
 
 	// Process files (simplified for now)
-	results := h.processFilesSimplified(request.Files)
+	stage("reading_files")
+	results := h.processFilesSimplified(ctx, request)
+	traced := traceColumns(results, request.ColumnRules)
+	applyColumnRules(results, request.ColumnRules)
+	applyDedupe(results, request.DedupeKeys)
+	applyDerivedColumns(results, request.DerivedColumns, startTime)
 
 	// Merge schemas from all processed files
+	stage("merging_schemas")
 	mergedSchema, err := h.mergeSchemas(results, request.SchemaResolution)
 	if err != nil {
 		return ExportResponse{
 			Success: false,
+			Code:    httputil.CodeExportFailed,
 			Message: fmt.Sprintf("Failed to merge schemas: %v", err),
 		}
 	}
 
 	// Check if table exists and validate schema
-	tableExists, err := h.nessieClient.TableExists(ctx, database, request.TableName)
+	stage("checking_table")
+	tableExists, err := nessieClient.TableExists(ctx, database, request.TableName)
 	if err != nil {
 		return ExportResponse{
 			Success: false,
+			Code:    httputil.CodeNessieError,
 			Message: fmt.Sprintf("Failed to check table existence: %v", err),
 		}
 	}
@@ -195,39 +451,88 @@ func (h *ExportHandler) processExport(ctx context.Context, request ExportRequest
 	var columnMismatches []storage.NessieColumnMismatch
 	if tableExists && request.Operation == "append" {
 		// Get existing table schema for comparison
-		targetTable, err := h.nessieClient.GetTableSchema(ctx, database, request.TableName)
+		targetTable, err := nessieClient.GetTableSchema(ctx, database, request.TableName)
 		if err != nil {
 			return ExportResponse{
 				Success: false,
+				Code:    httputil.CodeNessieError,
 				Message: fmt.Sprintf("Failed to get table schema: %v", err),
 			}
 		}
-		columnMismatches = h.nessieClient.ValidateSchema(mergedSchema.Columns, targetTable)
+		if request.ColumnMatching != nil {
+			tableColumns := make([]string, len(targetTable.Columns))
+			for i, column := range targetTable.Columns {
+				tableColumns[i] = column.Name
+			}
+			if applyColumnMatching(results, tableColumns, *request.ColumnMatching, traced) {
+				if mergedSchema, err = h.mergeSchemas(results, request.SchemaResolution); err != nil {
+					return ExportResponse{
+						Success: false,
+						Code:    httputil.CodeExportFailed,
+						Message: fmt.Sprintf("Failed to merge schemas: %v", err),
+					}
+				}
+			}
+		}
+		columnMismatches = nessieClient.ValidateSchema(mergedSchema.Columns, targetTable)
 	}
 
 	if len(columnMismatches) > 0 && request.SchemaResolution == "strict" {
 		return ExportResponse{
 			Success:          false,
+			Code:             httputil.CodeSchemaMismatch,
 			Message:          "Schema mismatch detected in strict mode",
 			ColumnMismatches: columnMismatches,
 		}
 	}
 
-	// Create table if needed
-	if request.Operation == "create" || !tableExists {
+	// Compare the columns read with those of the table's last load
+	var loadSchema []SchemaColumn
+	var driftAlert *DriftAlert
+	if driftPolicy != config.SchemaDriftOff {
+		loadSchema = inferColumnKinds(results, mergedSchema.Columns)
+		if tableExists && request.Operation == "append" {
+			driftAlert = h.checkSchemaDrift(ctx, request, database, loadSchema, driftPolicy == config.SchemaDriftBlock)
+		}
+		if driftAlert != nil && driftAlert.Blocked {
+			return ExportResponse{
+				Success:     false,
+				Code:        httputil.CodeSchemaDrift,
+				Message:     fmt.Sprintf("Columns drifted from the registered schema of %s.%s: %s", database, request.TableName, driftAlert.Drift),
+				TableName:   request.TableName,
+				Database:    database,
+				SchemaDrift: driftAlert,
+			}
+		}
+	}
+
+	// Geographic columns are typed from their values
+	for column, columnType := range geoColumnTypes(results, mergedSchema.Columns) {
+		mergedSchema.ColumnTypes[column] = columnType
+	}
+
+	// Create table if needed. Rolling back drops it only if it did not
+	// exist before, so a failed "create" over a table keeps its data
+	createsTable := request.Operation == "create" || !tableExists
+	if createsTable {
+		stage("creating_table")
 		nessieTable := &storage.NessieTable{
 			Name:     request.TableName,
 			Database: database,
-			Columns:  h.createNessieColumns(mergedSchema.Columns, mergedSchema.ColumnTypes),
+			Columns:  h.createNessieColumns(mergedSchema.Columns, mergedSchema.ColumnTypes, request.SchemaResolution != ResolutionOrdered),
 			Properties: map[string]interface{}{
 				"description": fmt.Sprintf("Table created from %d files", len(request.Files)),
 				"created_at":  time.Now(),
 			},
 		}
+		if subject != "" {
+			nessieTable.Properties["created_by"] = subject
+		}
 
-		if err := h.nessieClient.CreateTable(ctx, nessieTable); err != nil {
+		if err := nessieClient.CreateTable(ctx, nessieTable); err != nil {
 			return ExportResponse{
 				Success: false,
+				Code:    httputil.CodeNessieError,
 				Message: fmt.Sprintf("Failed to create table: %v", err),
 			}
 		}
@@ -235,15 +540,34 @@ func (h *ExportHandler) processExport(ctx context.Context, request ExportRequest
 		log.Printf("Created Nessie table: %s.%s", database, request.TableName)
 	}
 
-	// Export data (simplified)
-	totalRows, totalErrors := h.exportDataSimplified(results, request.TableName, database, request)
+	// Stage the rows and commit them at once, or roll back
+	stage("exporting_rows")
+	export := &stagedExport{
+		writer:       nessieClient,
+		database:     database,
+		table:        request.TableName,
+		stageID:      uuid.NewString(),
+		batchSize:    request.BatchSize,
+		createdTable: !tableExists,
+	}
+	outcome, err := export.write(ctx, results, request.Transactional, request.StopOnError)
+	if err != nil {
+		return ExportResponse{
+			Success:    false,
+			Code:       httputil.CodeNessieError,
+			Message:    fmt.Sprintf("Failed to write rows, export rolled back: %v", err),
+			TableName:  request.TableName,
+			Database:   database,
+			RolledBack: true,
+		}
+	}
 
 	processingTime := time.Since(startTime)
-	totalRowsInt64 := int64(totalRows)
-	totalErrorsInt64 := int64(totalErrors)
+	totalRowsInt64 := int64(outcome.rows)
+	totalErrorsInt64 := int64(outcome.failed)
 
-	return ExportResponse{
-		Success:          totalRowsInt64 > 0 || totalErrorsInt64 == 0,
+	response := ExportResponse{
+		Success:          !outcome.rolledBack && (totalRowsInt64 > 0 || totalErrorsInt64 == 0),
 		Message:          fmt.Sprintf("Export completed. %d rows exported, %d rows failed", totalRowsInt64, totalErrorsInt64),
 		TableName:        request.TableName,
 		FilesProcessed:   len(results),
@@ -252,23 +576,104 @@ func (h *ExportHandler) processExport(ctx context.Context, request ExportRequest
 		ProcessingTime:   processingTime,
 		ColumnMismatches: columnMismatches,
 		Database:         database,
+		Subject:          subject,
+		CommitID:         outcome.commitID,
+		RolledBack:       outcome.rolledBack,
+		SchemaDrift:      driftAlert,
 	}
+	if outcome.rolledBack {
+		response.Message = fmt.Sprintf("Export rolled back, no rows were committed: %s failed", outcome.stoppedBy)
+	}
+	if len(request.DedupeKeys) > 0 {
+		response.DuplicatesSkipped = make(map[string]int)
+		for _, result := range results {
+			if result.Success {
+				response.DuplicatesSkipped[result.FileName] += result.Duplicates
+			}
+		}
+	}
+	if !response.Success {
+		response.Code = httputil.CodeExportFailed
+	} else if response.CommitID != "" {
+		lineage := buildColumnLineage(mergedSchema.Columns, traced, request.DerivedColumns, request.ID, startTime)
+		if err := h.saveColumnLineage(ctx, database, request.TableName, createsTable, lineage); err != nil {
+			log.Printf("Warning: Failed to save column lineage of %s.%s: %v", database, request.TableName, err)
+		}
+		if loadSchema != nil {
+			h.registerSchema(ctx, request, database, loadSchema, createsTable)
+		}
+		response.QualityJobID = h.queueQualityCheck(ctx, database, request.TableName)
+	}
+	return response
 }
 
-func (h *ExportHandler) processFilesSimplified(files []FileExportInfo) []ProcessingResult {
+// queueQualityCheck queues a quality job for a table with a quality suite
+// and returns its ID, or "" if the table has none.
+func (h *ExportHandler) queueQualityCheck(ctx context.Context, database, table string) string {
+	if h.jobQueue == nil {
+		return ""
+	}
+	if _, err := h.browser.LoadQualitySuite(ctx, database, table); err != nil {
+		return ""
+	}
+
+	job := jobs.NewJob("quality", database+"."+table, h.minioClient.Bucket(ctx), qualitySuiteObject(ctx, database, table), jobs.PriorityMedium)
+	job.Metadata["database"] = database
+	job.Metadata["table"] = table
+	job.SetTraceContext(ctx)
+	job.Subject = auth.Subject(ctx)
+	job.Tenant = tenant.Name(ctx)
+	if err := h.jobQueue.Enqueue(job); err != nil {
+		log.Printf("Warning: Failed to queue quality job for %s.%s: %v", database, table, err)
+		return ""
+	}
+	log.Printf("Queued quality job %s for %s.%s", job.ID, database, table)
+	return job.ID
+}
+
+// applyFormulaMode checks the request's formula modes and gives files
+// without one the request's. The files are copied rather than changed in
+// place, as the caller keeps the request for the export history.
+func applyFormulaMode(request *ExportRequest) error {
+	if _, err := parseFormulaMode(request.FormulaMode); err != nil {
+		return err
+	}
+	files := make([]FileExportInfo, len(request.Files))
+	for i, file := range request.Files {
+		if file.FormulaMode == "" {
+			file.FormulaMode = request.FormulaMode
+		} else if _, err := parseFormulaMode(file.FormulaMode); err != nil {
+			return fmt.Errorf("%s: %w", file.FileName, err)
+		}
+		files[i] = file
+	}
+	request.Files = files
+	return nil
+}
+
+func (h *ExportHandler) processFilesSimplified(ctx context.Context, export ExportRequest) []ProcessingResult {
 	var results []ProcessingResult
 
-	for _, file := range files {
+	for _, file := range export.Files {
 		request := BrowseRequest{
-			FileName:   file.FileName,
-			SheetName:  file.SheetName,
-			TreatAsCSV: file.TreatAsCSV,
-			MaxRows:    1000, // Limit for testing
-			HasHeaders: true,
+			FileName:       file.FileName,
+			SheetName:      file.SheetName,
+			TreatAsCSV:     file.TreatAsCSV,
+			MaxRows:        1000, // Limit for testing
+			HasHeaders:     !file.NoHeaders,
+			Headers:        file.Headers,
+			FormulaMode:    file.FormulaMode,
+			NormalizeDates: export.NormalizeDates,
+			DateColumns:    file.DateColumns,
+			CleanOptions:   export.CleanOptions,
 		}
 
-		response, err := h.browser.BrowseDataRequest(context.Background(), request)
+		response, err := h.browser.BrowseDataRequest(ctx, request)
 		if err != nil {
+			suggestedFix := "Check file format and accessibility"
+			if report := h.quarantineFile(ctx, file.FileName, err); report != nil {
+				suggestedFix = fmt.Sprintf("File was quarantined as %s; fix it and upload it again", report.QuarantinedAs)
+			}
 			results = append(results, ProcessingResult{
 				FileName:  file.FileName,
 				SheetName: file.SheetName,
@@ -279,12 +684,13 @@ func (h *ExportHandler) processFilesSimplified(files []FileExportInfo) []Process
 						SheetName:    file.SheetName,
 						ErrorCode:    "FILE_PROCESSING_ERROR",
 						ErrorMsg:     err.Error(),
-						SuggestedFix: "Check file format and accessibility",
+						SuggestedFix: suggestedFix,
 					},
 				},
 			})
 			continue
 		}
+		h.quarantine.Succeed(ctx, file.FileName)
 
 		results = append(results, ProcessingResult{
 			FileName:  file.FileName,
@@ -300,6 +706,87 @@ func (h *ExportHandler) processFilesSimplified(files []FileExportInfo) []Process
 	return results
 }
 
+// quarantineFile counts a failure to read file towards its quarantine,
+// returning the report if it was quarantined. Only files that could not be
+// parsed count; a missing or refused file is not the file's fault.
+func (h *ExportHandler) quarantineFile(ctx context.Context, file string, err error) *quarantine.Report {
+	if !errors.Is(err, errUnreadable) {
+		return nil
+	}
+	report, qErr := h.quarantine.Fail(ctx, file, quarantine.Failure{
+		Source: quarantine.SourceExport,
+		Stage:  "read",
+		Err:    err,
+	})
+	if qErr != nil {
+		log.Printf("Warning: Failed to quarantine %s: %v", file, qErr)
+	}
+	return report
+}
+
+// applyColumnRules drops and renames the columns of the files read, so the
+// merged schema and the table have the exported names.
+func applyColumnRules(results []ProcessingResult, rules ColumnRules) {
+	for i := range results {
+		if !results[i].Success {
+			continue
+		}
+		mapper := NewColumnMapperWithRules(results[i].Columns, rules)
+		results[i].Columns = mapper.TargetColumns()
+		for j, row := range results[i].Rows {
+			results[i].Rows[j] = mapper.ProjectRow(row)
+		}
+	}
+}
+
+// applyColumnMatching renames the columns of the files read to the table
+// columns they match under options, moving the sources traced to them
+// along. It returns whether any column was renamed.
+func applyColumnMatching(results []ProcessingResult, tableColumns []string, options MatchOptions, traced map[string][]ColumnSource) bool {
+	renamed := false
+	for i := range results {
+		result := &results[i]
+		if !result.Success {
+			continue
+		}
+		columnMap := NewColumnMapperWithOptions(result.Columns, tableColumns, options).GetColumnMap()
+		for j, column := range result.Columns {
+			target, ok := columnMap[column]
+			if !ok || target == column {
+				continue
+			}
+			result.Columns[j] = target
+			retraceColumn(traced, *result, column, target)
+			renamed = true
+		}
+	}
+	return renamed
+}
+
+// applyDerivedColumns adds the derived columns to the files read. A file
+// they cannot be computed for, say for lacking a column they use, fails.
+func applyDerivedColumns(results []ProcessingResult, columns []DerivedColumn, now time.Time) {
+	if len(columns) == 0 {
+		return
+	}
+	for i := range results {
+		result := &results[i]
+		if !result.Success {
+			continue
+		}
+		if err := addDerivedColumns(result, columns, now); err != nil {
+			result.Success = false
+			result.Errors = append(result.Errors, ExportRowError{
+				FileName:     result.FileName,
+				SheetName:    result.SheetName,
+				ErrorCode:    "DERIVED_COLUMN_ERROR",
+				ErrorMsg:     err.Error(),
+				SuggestedFix: "Check the columns the derived column expressions use",
+			})
+		}
+	}
+}
+
 func (h *ExportHandler) mergeSchemas(results []ProcessingResult, resolution string) (*MergedSchema, error) {
 	if len(results) == 0 {
 		return nil, fmt.Errorf("no processing results to merge")
@@ -328,26 +815,13 @@ func (h *ExportHandler) mergeSchemas(results []ProcessingResult, resolution stri
 	return merger.MergeSchemas(files)
 }
 
-func (h *ExportHandler) exportDataSimplified(results []ProcessingResult, tableName, database string, request ExportRequest) (int, int) {
-	totalRows := 0
-	totalErrors := 0
-
-	for _, result := range results {
-		if !result.Success {
-			totalErrors += len(result.Errors)
-			continue
-		}
-
-		// Simple row counting for now
-		totalRows += len(result.Rows)
-	}
-
-	return totalRows, totalErrors
-}
-
-func (h *ExportHandler) createNessieColumns(columns []string, columnTypes map[string]string) []storage.NessieColumn {
+// createNessieColumns returns the table columns of a merged schema, sorted
+// by name unless the schema's order is to be kept.
+func (h *ExportHandler) createNessieColumns(columns []string, columnTypes map[string]string, sorted bool) []storage.NessieColumn {
 	var nessieColumns []storage.NessieColumn
-	sort.Strings(columns) // Sort for consistent column order
+	if sorted {
+		sort.Strings(columns) // Sort for consistent column order
+	}
 
 	for _, col := range columns {
 		colType := "VARCHAR(255)" // Default type
@@ -366,49 +840,39 @@ func (h *ExportHandler) createNessieColumns(columns []string, columnTypes map[st
 	return nessieColumns
 }
 
+// writeJSONResponse answers with response, under the status of its code
+// when the export failed.
 func (h *ExportHandler) writeJSONResponse(w http.ResponseWriter, response ExportResponse) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-
-	if response.Success {
-		w.WriteHeader(http.StatusOK)
-	} else {
-		w.WriteHeader(http.StatusBadRequest)
+	status := http.StatusOK
+	if !response.Success {
+		status = response.Code.Status()
 	}
-
-	json.NewEncoder(w).Encode(response)
+	httputil.WriteJSON(w, status, response)
 }
 
-func (h *ExportHandler) writeError(w http.ResponseWriter, message string, statusCode int, err error) {
-	response := map[string]interface{}{
-		"success": false,
-		"message": message,
-	}
 
-	if err != nil {
-		response["error"] = err.Error()
-		log.Printf("Export Error: %v", err)
+func (h *ExportHandler) ExportSingleFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(response)
-}
+	if !h.requireNessie(w) {
+		return
+	}
 
-func (h *ExportHandler) ExportSingleFile(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !h.usage.Allow(w, r, metering.MetricRows) {
 		return
 	}
 
 	var request ExportRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		h.writeError(w, "Failed to decode request", http.StatusBadRequest, err)
+		httputil.WriteError(w, "Failed to decode request", http.StatusBadRequest, err)
 		return
 	}
 
 	if len(request.Files) != 1 {
-		h.writeError(w, "This endpoint only supports single file exports", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "This endpoint only supports single file exports", http.StatusBadRequest, nil)
 		return
 	}
 
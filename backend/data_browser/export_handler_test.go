@@ -0,0 +1,153 @@
+package data_browser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bronze-backend/config"
+	"bronze-backend/storage"
+)
+
+func TestExportsUnavailableWithoutNessie(t *testing.T) {
+	cfg := &config.Config{}
+	h := NewExportHandler(nil, nil, cfg, nil)
+
+	endpoints := map[string]http.HandlerFunc{
+		"export-single":   h.ExportSingleFile,
+		"export-multiple": h.ExportMultipleFiles,
+		"export-job":      h.CreateExportJob,
+	}
+	for name, handler := range endpoints {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/api/data/"+name, strings.NewReader("{}")))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: status %d, want 503", name, rec.Code)
+		}
+	}
+
+	nessie := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer nessie.Close()
+	cfg.Nessie = config.NessieConfig{Endpoint: nessie.URL, Namespace: "warehouse"}
+	client, err := storage.NewNessieClient(&cfg.Nessie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetNessieClient(client)
+
+	// With Nessie back, requests get past the availability check
+	rec := httptest.NewRecorder()
+	h.ExportSingleFile(rec, httptest.NewRequest(http.MethodPost, "/api/data/export-single", strings.NewReader(`{"files":[]}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("after reconnect: status %d, want 400", rec.Code)
+	}
+}
+
+func TestLoadExportPreset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export_presets.json")
+	presets := `{"nightly": {"table_name": "sales", "operation": "append", "files": [{"file_name": "sales/today.csv"}]}}`
+	if err := os.WriteFile(path, []byte(presets), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	preset, err := LoadExportPreset(path, "nightly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preset.TableName != "sales" || len(preset.Files) != 1 || preset.Files[0].FileName != "sales/today.csv" {
+		t.Errorf("preset = %+v", preset)
+	}
+
+	if _, err := LoadExportPreset(path, "weekly"); err == nil {
+		t.Error("unknown preset: want error")
+	}
+	if _, err := NewExportHandler(nil, nil, &config.Config{}, nil).Export(t.Context(), preset); err == nil {
+		t.Error("export without Nessie: want error")
+	}
+}
+
+func TestRecentExports(t *testing.T) {
+	h := NewExportHandler(nil, nil, &config.Config{Nessie: config.NessieConfig{DefaultDB: "lake"}}, nil)
+	for i := 0; i < maxExportHistory+2; i++ {
+		request := ExportRequest{TableName: "sales", Files: []FileExportInfo{{FileName: "sales/" + strconv.Itoa(i) + ".csv"}}}
+		h.recordExport(t.Context(), request, ExportResponse{ExportID: strconv.Itoa(i), Success: true}, time.Now())
+	}
+
+	records := h.RecentExports()
+	if len(records) != maxExportHistory {
+		t.Fatalf("%d records, want %d", len(records), maxExportHistory)
+	}
+	newest := records[0]
+	if newest.ID != strconv.Itoa(maxExportHistory+1) || newest.Database != "lake" || newest.Files[0] != "sales/501.csv" {
+		t.Errorf("newest record = %+v", newest)
+	}
+	if records[len(records)-1].ID != "2" {
+		t.Errorf("oldest record %s, want 2", records[len(records)-1].ID)
+	}
+}
+
+// Rolling an export back drops its table only if the export created it: a
+// "create" over an existing table must not destroy the table's data.
+func TestExportRollbackKeepsExistingTable(t *testing.T) {
+	const csv = "id,name\n1,Ann\n"
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", `"abc"`)
+		if r.URL.Path != "/lake/sales.csv" {
+			return
+		}
+		http.ServeContent(w, r, "sales.csv", time.Time{}, strings.NewReader(csv))
+	}))
+	defer s3.Close()
+	minioClient, err := storage.NewMinIOClient(&config.MinIOConfig{Endpoint: s3.URL, Bucket: "lake", AccessKey: "key", SecretKey: "secret", Region: "us-east-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, exists := range []bool{true, false} {
+		var mu sync.Mutex
+		var dropped bool
+		nessie := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			table := strings.HasSuffix(r.URL.Path, "/tables/sales")
+			switch {
+			case r.Method == http.MethodGet && table && !exists:
+				w.WriteHeader(http.StatusNotFound)
+			case r.Method == http.MethodDelete && table:
+				mu.Lock()
+				dropped = true
+				mu.Unlock()
+			case strings.HasSuffix(r.URL.Path, "/commit"):
+				w.WriteHeader(http.StatusConflict)
+			}
+		}))
+		cfg := &config.Config{Nessie: config.NessieConfig{Endpoint: nessie.URL, Namespace: "warehouse", DefaultDB: "lake"}}
+		client, err := storage.NewNessieClient(&cfg.Nessie)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := NewExportHandler(minioClient, client, cfg, NewDataBrowserHandler(minioClient))
+
+		// The commit fails, so the export rolls back
+		response := h.runExport(t.Context(), ExportRequest{
+			TableName: "sales",
+			Operation: "create",
+			Files:     []FileExportInfo{{FileName: "sales.csv"}},
+		}, func(string) {})
+		nessie.Close()
+
+		if !response.RolledBack {
+			t.Errorf("table exists %v: response %+v, want rolled back", exists, response)
+		}
+		mu.Lock()
+		if dropped == exists {
+			t.Errorf("table exists %v: dropped %v", exists, dropped)
+		}
+		mu.Unlock()
+	}
+}
@@ -0,0 +1,80 @@
+package data_browser
+
+import (
+	"context"
+	"time"
+
+	"bronze-backend/tenant"
+)
+
+// maxExportHistory bounds how many exports RecentExports remembers.
+const maxExportHistory = 500
+
+// ExportRecord is a finished export: which files went into which table.
+type ExportRecord struct {
+	ID           string        `json:"export_id"`
+	TableName    string        `json:"table_name"`
+	Database     string        `json:"database"`
+	Operation    string        `json:"operation"`
+	Files        []string      `json:"files"` // Object names of the exported files
+	Success      bool          `json:"success"`
+	Message      string        `json:"message"`
+	RowsExported int64         `json:"rows_exported"`
+	RowsFailed   int64         `json:"rows_failed"`
+	Subject      string        `json:"subject,omitempty"`
+	Tenant       string        `json:"tenant,omitempty"`
+	StartedAt    time.Time     `json:"started_at"`
+	Duration     time.Duration `json:"duration"`
+}
+
+// recordExport adds a finished export to the history, dropping the oldest
+// once it is full.
+func (h *ExportHandler) recordExport(ctx context.Context, request ExportRequest, response ExportResponse, startedAt time.Time) {
+	database := response.Database
+	if database == "" {
+		database = request.Database
+	}
+	if database == "" && h.config != nil {
+		database = h.config.Nessie.DefaultDB
+	}
+	files := make([]string, len(request.Files))
+	for i, file := range request.Files {
+		files[i] = file.FileName
+	}
+
+	record := ExportRecord{
+		ID:           response.ExportID,
+		TableName:    request.TableName,
+		Database:     database,
+		Operation:    request.Operation,
+		Files:        files,
+		Success:      response.Success,
+		Message:      response.Message,
+		RowsExported: response.RowsExported,
+		RowsFailed:   response.RowsFailed,
+		Subject:      response.Subject,
+		Tenant:       tenant.Name(ctx),
+		StartedAt:    startedAt,
+		Duration:     time.Since(startedAt),
+	}
+
+	h.historyMu.Lock()
+	defer h.historyMu.Unlock()
+	h.history = append(h.history, record)
+	if len(h.history) > maxExportHistory {
+		h.history = h.history[len(h.history)-maxExportHistory:]
+	}
+}
+
+// RecentExports returns the exports this process ran, newest first. Only
+// the last few hundred are kept, and none survive a restart.
+func (h *ExportHandler) RecentExports() []ExportRecord {
+	h.historyMu.Lock()
+	defer h.historyMu.Unlock()
+
+	records := make([]ExportRecord, len(h.history))
+	for i, record := range h.history {
+		records[len(h.history)-1-i] = record
+	}
+	return records
+}
@@ -0,0 +1,42 @@
+package data_browser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// LoadExportPreset returns the export request saved under name in the
+// presets file, a JSON object mapping preset names to export requests.
+func LoadExportPreset(path, name string) (ExportRequest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ExportRequest{}, fmt.Errorf("failed to read export presets: %w", err)
+	}
+
+	var presets map[string]ExportRequest
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return ExportRequest{}, fmt.Errorf("failed to parse export presets: %w", err)
+	}
+
+	preset, ok := presets[name]
+	if !ok {
+		return ExportRequest{}, fmt.Errorf("export preset %q not found in %s", name, path)
+	}
+	return preset, nil
+}
+
+// Export runs an export outside an HTTP request, e.g. from the command line.
+func (h *ExportHandler) Export(ctx context.Context, request ExportRequest) (ExportResponse, error) {
+	if len(request.Files) == 0 {
+		return ExportResponse{}, fmt.Errorf("no files to export")
+	}
+	if request.TableName == "" {
+		return ExportResponse{}, fmt.Errorf("table name is required")
+	}
+	if h.nessieClient.Load() == nil {
+		return ExportResponse{}, fmt.Errorf("Nessie is not available")
+	}
+	return h.processExport(ctx, request), nil
+}
@@ -0,0 +1,107 @@
+package data_browser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"bronze-backend/config"
+	"bronze-backend/jobs"
+	"bronze-backend/storage"
+)
+
+// ExportProcessor runs "export" jobs: it exports the job's object to Nessie
+// with the settings of an export preset, as `bronze export --preset` does.
+// The file options of the preset's first file, such as its sheet and
+// headers, apply to the object.
+//
+// Job metadata:
+//   - preset: name of the preset in EXPORT_PRESETS_FILE (required)
+type ExportProcessor struct {
+	minioClient *storage.MinIOClient
+	config      *config.Config
+	nessie      lazyNessie
+
+	once    sync.Once
+	handler *ExportHandler
+}
+
+func NewExportProcessor(cfg *config.Config, minioClient *storage.MinIOClient) *ExportProcessor {
+	return &ExportProcessor{
+		minioClient: minioClient,
+		config:      cfg,
+		nessie:      lazyNessie{config: &cfg.Nessie},
+	}
+}
+
+// exportHandler returns the handler exports run through, created with the
+// first job.
+func (ep *ExportProcessor) exportHandler() *ExportHandler {
+	ep.once.Do(func() {
+		browser := NewDataBrowserHandler(ep.minioClient)
+		// Validated when the config was loaded
+		rates, _ := ep.config.Processing.ParseConversionRates()
+		browser.SetConversionRates(rates)
+		ep.handler = NewExportHandler(ep.minioClient, nil, ep.config, browser)
+	})
+	return ep.handler
+}
+
+// presetRequest returns the export request of preset for the object.
+func presetRequest(preset ExportRequest, objectName string) ExportRequest {
+	file := FileExportInfo{}
+	if len(preset.Files) > 0 {
+		file = preset.Files[0]
+	}
+	file.FileName = objectName
+	preset.Files = []FileExportInfo{file}
+	return preset
+}
+
+func (ep *ExportProcessor) ProcessJob(ctx context.Context, job *jobs.Job) jobs.JobResult {
+	startTime := time.Now()
+
+	fail := func(format string, args ...any) jobs.JobResult {
+		return jobs.JobResult{
+			Success:        false,
+			ProcessingTime: time.Since(startTime),
+			Message:        fmt.Sprintf(format, args...),
+		}
+	}
+
+	if ep.minioClient == nil {
+		return fail("MinIO client not available")
+	}
+
+	presetName, _ := job.Metadata["preset"].(string)
+	if presetName == "" {
+		return fail("export preset is required (metadata.preset)")
+	}
+	preset, err := LoadExportPreset(ep.config.Nessie.PresetsFile, presetName)
+	if err != nil {
+		return fail("Failed to load export preset: %v", err)
+	}
+
+	nessieClient, err := ep.nessie.get()
+	if err != nil {
+		return fail("Nessie is not available: %v", err)
+	}
+	handler := ep.exportHandler()
+	handler.SetNessieClient(nessieClient)
+
+	log.Printf("Exporting %s with preset %s for job %s", job.ObjectName, presetName, job.ID)
+	job.UpdateProgress(10)
+
+	response, err := handler.Export(ctx, presetRequest(preset, job.ObjectName))
+	if err != nil {
+		return fail("Failed to export %s: %v", job.ObjectName, err)
+	}
+	return jobs.JobResult{
+		Success:        response.Success,
+		ProcessingTime: time.Since(startTime),
+		Message:        response.Message,
+		Result:         response,
+	}
+}
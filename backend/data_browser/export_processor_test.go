@@ -0,0 +1,26 @@
+package data_browser
+
+import "testing"
+
+func TestPresetRequest(t *testing.T) {
+	preset := ExportRequest{
+		TableName: "sales_raw",
+		Operation: "append",
+		Files:     []FileExportInfo{{FileName: "sample.xlsx", SheetName: "Orders", Headers: []string{"id", "total"}}, {FileName: "other.csv"}},
+	}
+
+	request := presetRequest(preset, "incoming/sales/2026-10.xlsx")
+	if len(request.Files) != 1 || request.Files[0].FileName != "incoming/sales/2026-10.xlsx" || request.Files[0].SheetName != "Orders" || len(request.Files[0].Headers) != 2 {
+		t.Errorf("files = %+v", request.Files)
+	}
+	if request.TableName != "sales_raw" || request.Operation != "append" {
+		t.Errorf("request = %+v", request)
+	}
+	if preset.Files[0].FileName != "sample.xlsx" {
+		t.Error("the preset was changed")
+	}
+
+	if request := presetRequest(ExportRequest{TableName: "t"}, "a.csv"); len(request.Files) != 1 || request.Files[0].FileName != "a.csv" {
+		t.Errorf("files without preset files = %+v", request.Files)
+	}
+}
@@ -0,0 +1,1005 @@
+package data_browser
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"bronze-backend/httputil"
+	"github.com/tealeg/xlsx/v3"
+)
+
+// FormulaMode chooses what an Excel cell holding a formula reads as.
+type FormulaMode string
+
+const (
+	// FormulaCached reads the value Excel stored when the workbook was last
+	// calculated, formatted like the cell. It is the default.
+	FormulaCached FormulaMode = "cached"
+	// FormulaText reads the formula itself, e.g. "=SUM(A1:A3)".
+	FormulaText FormulaMode = "formula"
+	// FormulaEvaluate computes the formula from the workbook's cells, for
+	// workbooks saved without calculated values. A formula the evaluator
+	// cannot compute reads as its cached value.
+	FormulaEvaluate FormulaMode = "evaluate"
+)
+
+// parseFormulaMode parses a request's formula_mode, empty meaning cached.
+func parseFormulaMode(value string) (FormulaMode, error) {
+	switch mode := FormulaMode(strings.ToLower(value)); mode {
+	case "":
+		return FormulaCached, nil
+	case FormulaCached, FormulaText, FormulaEvaluate:
+		return mode, nil
+	}
+	return "", httputil.NewError(httputil.CodeBadRequest, fmt.Sprintf("invalid formula_mode %q: use cached, formula or evaluate", value), nil)
+}
+
+// cellReader reads the cells of one sheet as text.
+type cellReader struct {
+	mode  FormulaMode
+	sheet *xlsx.Sheet
+	eval  *formulaEvaluator
+	// dates are the indexes of the columns whose numbers read as ISO 8601
+	// dates; see detectDateColumns
+	dates    map[int]bool
+	date1904 bool
+}
+
+func newCellReader(wb *xlsx.File, sheet *xlsx.Sheet, mode FormulaMode) *cellReader {
+	return &cellReader{mode: mode, sheet: sheet, eval: newFormulaEvaluator(wb), date1904: wb.Date1904}
+}
+
+func (r *cellReader) read(cell *xlsx.Cell) string {
+	if formula := cell.Formula(); formula != "" {
+		switch r.mode {
+		case FormulaText:
+			return "=" + formula
+		case FormulaEvaluate:
+			if value, err := r.eval.evaluate(r.sheet, cell); err == nil {
+				value = scalar(value)
+				if n, ok := value.(float64); ok {
+					if date, ok := r.date(cell, n); ok {
+						return date
+					}
+				}
+				return formulaText(value)
+			}
+		}
+	}
+	if isNumericCell(cell) {
+		if n, err := cell.Float(); err == nil {
+			if date, ok := r.date(cell, n); ok {
+				return date
+			}
+		}
+	}
+	value, _ := cell.FormattedValue()
+	return value
+}
+
+// date renders n as a date if cell is in a date column.
+func (r *cellReader) date(cell *xlsx.Cell, n float64) (string, bool) {
+	if col, _ := cell.GetCoordinates(); !r.dates[col] {
+		return "", false
+	}
+	return excelDate(n, r.date1904)
+}
+
+// errUnsupportedFormula stops the evaluation of a formula using something
+// the evaluator lacks, such as a named range or an unknown function.
+var errUnsupportedFormula = errors.New("unsupported formula")
+
+// formulaError is an Excel error value. Like in Excel, it is a result, not
+// a failure: it propagates through the formulas using it.
+type formulaError string
+
+const (
+	errDiv0  formulaError = "#DIV/0!"
+	errValue formulaError = "#VALUE!"
+	errRef   formulaError = "#REF!"
+	errNum   formulaError = "#NUM!"
+)
+
+// cellRange holds the values of the cells a reference covers, row by row.
+// Functions see every value; operators take a range of one cell as that
+// cell's value.
+type cellRange []any
+
+// maxFormulaDepth bounds the chain of formulas referring to formulas.
+const maxFormulaDepth = 256
+
+// maxRangeCells bounds the cells one range reference may cover.
+const maxRangeCells = 1 << 20
+
+// formulaEvaluator computes formulas from the cells of a workbook. Values
+// are float64, string, bool, formulaError or nil for an empty cell.
+type formulaEvaluator struct {
+	wb      *xlsx.File
+	results map[*xlsx.Cell]any
+	active  map[*xlsx.Cell]bool // Being evaluated, to catch circular references
+}
+
+func newFormulaEvaluator(wb *xlsx.File) *formulaEvaluator {
+	return &formulaEvaluator{
+		wb:      wb,
+		results: make(map[*xlsx.Cell]any),
+		active:  make(map[*xlsx.Cell]bool),
+	}
+}
+
+// evaluate returns the value of the formula of cell, which is in sheet.
+func (e *formulaEvaluator) evaluate(sheet *xlsx.Sheet, cell *xlsx.Cell) (any, error) {
+	if value, ok := e.results[cell]; ok {
+		return value, nil
+	}
+	if e.active[cell] || len(e.active) >= maxFormulaDepth {
+		return nil, errUnsupportedFormula
+	}
+	e.active[cell] = true
+	defer delete(e.active, cell)
+
+	p := &formulaParser{e: e, sheet: sheet, src: cell.Formula()}
+	value, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	e.results[cell] = value
+	return value, nil
+}
+
+// cellValue returns the value of the cell at row and col of sheet,
+// evaluating its formula if it has one.
+func (e *formulaEvaluator) cellValue(sheet *xlsx.Sheet, row, col int) (any, error) {
+	// Sheet.Cell adds rows it is asked for beyond the last one
+	if row >= sheet.MaxRow || col >= sheet.MaxCol {
+		return nil, nil
+	}
+	cell, err := sheet.Cell(row, col)
+	if err != nil {
+		return nil, nil
+	}
+	if cell.Formula() != "" {
+		return e.evaluate(sheet, cell)
+	}
+
+	switch cell.Type() {
+	case xlsx.CellTypeNumeric, xlsx.CellTypeDate:
+		if n, err := strconv.ParseFloat(cell.Value, 64); err == nil {
+			return n, nil
+		}
+	case xlsx.CellTypeBool:
+		return cell.Value == "1", nil
+	case xlsx.CellTypeError:
+		return formulaError(cell.Value), nil
+	}
+	if cell.Value == "" {
+		return nil, nil
+	}
+	return cell.Value, nil
+}
+
+var cellRefPattern = regexp.MustCompile(`^\$?([A-Za-z]{1,3})\$?([0-9]+)$`)
+
+// parseCellRef parses a reference like "B3" or "$B$3" into zero-based
+// coordinates.
+func parseCellRef(ref string) (row, col int, ok bool) {
+	m := cellRefPattern.FindStringSubmatch(ref)
+	if m == nil {
+		return 0, 0, false
+	}
+	row, err := strconv.Atoi(m[2])
+	if err != nil || row < 1 {
+		return 0, 0, false
+	}
+	return row - 1, xlsx.ColLettersToIndex(strings.ToUpper(m[1])), true
+}
+
+// formulaParser evaluates a formula as it parses it, by recursive descent
+// over Excel's operator precedence: comparison, then &, then + and -, then
+// * and /, then ^, then negation, then %.
+type formulaParser struct {
+	e     *formulaEvaluator
+	sheet *xlsx.Sheet
+	src   string
+	pos   int
+	// names, when set, resolves identifiers and [bracketed names] instead
+	// of cell references, for expressions over something other than a
+	// workbook; see DerivedColumn
+	names func(name string) (any, error)
+	// functions are known besides formulaFunctions
+	functions map[string]func(args []any) (any, error)
+}
+
+func (p *formulaParser) parse() (any, error) {
+	value, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, errUnsupportedFormula
+	}
+	return value, nil
+}
+
+func (p *formulaParser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// accept consumes op if it comes next.
+func (p *formulaParser) accept(op string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], op) {
+		p.pos += len(op)
+		return true
+	}
+	return false
+}
+
+func (p *formulaParser) comparison() (any, error) {
+	left, err := p.concatenation()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		for _, candidate := range []string{"<>", "<=", ">=", "=", "<", ">"} {
+			if p.accept(candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		right, err := p.concatenation()
+		if err != nil {
+			return nil, err
+		}
+		l, r := scalar(left), scalar(right)
+		if e, ok := firstError(l, r); ok {
+			left = e
+			continue
+		}
+		c := compareValues(l, r)
+		switch op {
+		case "=":
+			left = c == 0
+		case "<>":
+			left = c != 0
+		case "<":
+			left = c < 0
+		case ">":
+			left = c > 0
+		case "<=":
+			left = c <= 0
+		case ">=":
+			left = c >= 0
+		}
+	}
+}
+
+func (p *formulaParser) concatenation() (any, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&") {
+		right, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		l, r := scalar(left), scalar(right)
+		if e, ok := firstError(l, r); ok {
+			left = e
+		} else {
+			left = formulaText(l) + formulaText(r)
+		}
+	}
+	return left, nil
+}
+
+func (p *formulaParser) additive() (any, error) {
+	left, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op func(a, b float64) any
+		switch {
+		case p.accept("+"):
+			op = func(a, b float64) any { return a + b }
+		case p.accept("-"):
+			op = func(a, b float64) any { return a - b }
+		default:
+			return left, nil
+		}
+		right, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmetic(left, right, op)
+	}
+}
+
+func (p *formulaParser) multiplicative() (any, error) {
+	left, err := p.power()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op func(a, b float64) any
+		switch {
+		case p.accept("*"):
+			op = func(a, b float64) any { return a * b }
+		case p.accept("/"):
+			op = func(a, b float64) any {
+				if b == 0 {
+					return errDiv0
+				}
+				return a / b
+			}
+		default:
+			return left, nil
+		}
+		right, err := p.power()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmetic(left, right, op)
+	}
+}
+
+func (p *formulaParser) power() (any, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("^") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmetic(left, right, func(a, b float64) any {
+			result := math.Pow(a, b)
+			if math.IsNaN(result) || math.IsInf(result, 0) {
+				return errNum
+			}
+			return result
+		})
+	}
+	return left, nil
+}
+
+func (p *formulaParser) unary() (any, error) {
+	switch {
+	case p.accept("-"):
+		value, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return arithmetic(0.0, value, func(a, b float64) any { return a - b }), nil
+	case p.accept("+"):
+		return p.unary()
+	}
+
+	value, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("%") {
+		value = arithmetic(value, 100.0, func(a, b float64) any { return a / b })
+	}
+	return value, nil
+}
+
+func (p *formulaParser) primary() (any, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, errUnsupportedFormula
+	}
+
+	switch c := p.src[p.pos]; {
+	case c == '(':
+		p.pos++
+		value, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, errUnsupportedFormula
+		}
+		return value, nil
+	case c == '"':
+		return p.stringLiteral()
+	case c == '[' && p.names != nil:
+		end := strings.IndexByte(p.src[p.pos:], ']')
+		if end < 0 {
+			return nil, errUnsupportedFormula
+		}
+		name := p.src[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+		return p.names(name)
+	case c == '\'' && p.e != nil:
+		// A quoted sheet name, as in 'Q1 Sales'!B2
+		end := strings.Index(p.src[p.pos+1:], "'!")
+		if end < 0 {
+			return nil, errUnsupportedFormula
+		}
+		name := strings.ReplaceAll(p.src[p.pos+1:p.pos+1+end], "''", "'")
+		p.pos += end + 3
+		return p.sheetReference(name)
+	case c >= '0' && c <= '9' || c == '.':
+		return p.number()
+	}
+
+	name := p.identifier()
+	if name == "" {
+		return nil, errUnsupportedFormula
+	}
+	switch {
+	case p.accept("("):
+		return p.call(strings.ToUpper(strings.TrimPrefix(name, "_xlfn.")))
+	case strings.HasPrefix(p.src[p.pos:], "!") && p.e != nil:
+		p.pos++
+		return p.sheetReference(name)
+	case strings.EqualFold(name, "TRUE"):
+		return true, nil
+	case strings.EqualFold(name, "FALSE"):
+		return false, nil
+	case p.names != nil:
+		return p.names(name)
+	}
+	return p.reference(p.sheet, name)
+}
+
+func (p *formulaParser) identifier() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c != '_' && c != '.' && c != '$' && !(c >= 'A' && c <= 'Z') && !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *formulaParser) number() (any, error) {
+	start := p.pos
+	for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+		p.pos++
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'E' || p.src[p.pos] == 'e') {
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+	}
+	n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+	if err != nil {
+		return nil, errUnsupportedFormula
+	}
+	return n, nil
+}
+
+func (p *formulaParser) stringLiteral() (any, error) {
+	var b strings.Builder
+	for p.pos++; p.pos < len(p.src); p.pos++ {
+		if p.src[p.pos] != '"' {
+			b.WriteByte(p.src[p.pos])
+			continue
+		}
+		if p.pos+1 < len(p.src) && p.src[p.pos+1] == '"' {
+			b.WriteByte('"')
+			p.pos++
+			continue
+		}
+		p.pos++
+		return b.String(), nil
+	}
+	return nil, errUnsupportedFormula
+}
+
+func (p *formulaParser) sheetReference(name string) (any, error) {
+	ref := p.identifier()
+	sheet, ok := p.e.wb.Sheet[name]
+	if !ok {
+		if p.accept(":") {
+			p.identifier()
+		}
+		return errRef, nil
+	}
+	return p.reference(sheet, ref)
+}
+
+// reference returns the cells of sheet that ref, and the range end that
+// may follow it, cover.
+func (p *formulaParser) reference(sheet *xlsx.Sheet, ref string) (any, error) {
+	row1, col1, ok := parseCellRef(ref)
+	if !ok {
+		return nil, errUnsupportedFormula
+	}
+	row2, col2 := row1, col1
+	if p.accept(":") {
+		if row2, col2, ok = parseCellRef(p.identifier()); !ok {
+			return nil, errUnsupportedFormula
+		}
+	}
+	row1, row2 = min(row1, row2), max(row1, row2)
+	col1, col2 = min(col1, col2), max(col1, col2)
+	if (row2-row1+1)*(col2-col1+1) > maxRangeCells {
+		return nil, errUnsupportedFormula
+	}
+
+	var values cellRange
+	for row := row1; row <= row2; row++ {
+		for col := col1; col <= col2; col++ {
+			value, err := p.e.cellValue(sheet, row, col)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// call parses the arguments of function name and applies it.
+func (p *formulaParser) call(name string) (any, error) {
+	var args []any
+	if !p.accept(")") {
+		for {
+			p.skipSpace()
+			if p.pos < len(p.src) && (p.src[p.pos] == ',' || p.src[p.pos] == ')') {
+				args = append(args, nil) // An omitted argument
+			} else {
+				arg, err := p.comparison()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+			}
+			if p.accept(")") {
+				break
+			}
+			if !p.accept(",") {
+				return nil, errUnsupportedFormula
+			}
+		}
+	}
+
+	fn, ok := p.functions[name]
+	if !ok {
+		fn, ok = formulaFunctions[name]
+	}
+	if !ok {
+		return nil, errUnsupportedFormula
+	}
+	return fn(args)
+}
+
+// formulaFunctions are the functions the evaluator knows.
+var formulaFunctions = map[string]func(args []any) (any, error){
+	"SUM": aggregate(func(values []float64) any {
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum
+	}),
+	"PRODUCT": aggregate(func(values []float64) any {
+		product := 1.0
+		for _, v := range values {
+			product *= v
+		}
+		return product
+	}),
+	"AVERAGE": aggregate(func(values []float64) any {
+		if len(values) == 0 {
+			return errDiv0
+		}
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}),
+	"MIN": aggregate(func(values []float64) any {
+		if len(values) == 0 {
+			return 0.0
+		}
+		return slices.Min(values)
+	}),
+	"MAX": aggregate(func(values []float64) any {
+		if len(values) == 0 {
+			return 0.0
+		}
+		return slices.Max(values)
+	}),
+	"COUNT": func(args []any) (any, error) {
+		count := 0
+		for _, arg := range args {
+			for _, v := range flatten(arg) {
+				if _, ok := v.(float64); ok {
+					count++
+				}
+			}
+		}
+		return float64(count), nil
+	},
+	"COUNTA": func(args []any) (any, error) {
+		count := 0
+		for _, arg := range args {
+			for _, v := range flatten(arg) {
+				if v != nil {
+					count++
+				}
+			}
+		}
+		return float64(count), nil
+	},
+	"ABS":       numeric1(math.Abs),
+	"INT":       numeric1(math.Floor),
+	"ROUND":     rounding(math.Round),
+	"ROUNDUP":   rounding(func(x float64) float64 { return math.Copysign(math.Ceil(math.Abs(x)), x) }),
+	"ROUNDDOWN": rounding(math.Trunc),
+	"MOD": func(args []any) (any, error) {
+		if len(args) != 2 {
+			return nil, errUnsupportedFormula
+		}
+		return arithmetic(args[0], args[1], func(a, b float64) any {
+			if b == 0 {
+				return errDiv0
+			}
+			return a - b*math.Floor(a/b)
+		}), nil
+	},
+	"IF": func(args []any) (any, error) {
+		if len(args) < 2 || len(args) > 3 {
+			return nil, errUnsupportedFormula
+		}
+		condition, err := toBool(scalar(args[0]))
+		if err != "" {
+			return err, nil
+		}
+		if condition {
+			return args[1], nil
+		}
+		if len(args) == 3 {
+			return args[2], nil
+		}
+		return false, nil
+	},
+	"IFERROR": func(args []any) (any, error) {
+		if len(args) != 2 {
+			return nil, errUnsupportedFormula
+		}
+		if _, ok := scalar(args[0]).(formulaError); ok {
+			return args[1], nil
+		}
+		return args[0], nil
+	},
+	"AND": logical(func(values []bool) bool {
+		for _, v := range values {
+			if !v {
+				return false
+			}
+		}
+		return true
+	}),
+	"OR": logical(func(values []bool) bool {
+		for _, v := range values {
+			if v {
+				return true
+			}
+		}
+		return false
+	}),
+	"NOT": func(args []any) (any, error) {
+		if len(args) != 1 {
+			return nil, errUnsupportedFormula
+		}
+		value, err := toBool(scalar(args[0]))
+		if err != "" {
+			return err, nil
+		}
+		return !value, nil
+	},
+	"CONCATENATE": concat,
+	"CONCAT":      concat,
+	"LEN":         text1(func(s string) any { return float64(len([]rune(s))) }),
+	"UPPER":       text1(func(s string) any { return strings.ToUpper(s) }),
+	"LOWER":       text1(func(s string) any { return strings.ToLower(s) }),
+	"TRIM":        text1(func(s string) any { return strings.Join(strings.Fields(s), " ") }),
+	"LEFT":        substring(func(s []rune, n int) string { return string(s[:min(n, len(s))]) }),
+	"RIGHT":       substring(func(s []rune, n int) string { return string(s[max(len(s)-n, 0):]) }),
+}
+
+// aggregate makes a function of the numbers among its arguments. Like in
+// Excel, text and booleans in ranges are skipped, while arguments given
+// directly are converted.
+func aggregate(fn func(values []float64) any) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		var values []float64
+		for _, arg := range args {
+			if r, ok := arg.(cellRange); ok {
+				for _, v := range r {
+					switch v := v.(type) {
+					case float64:
+						values = append(values, v)
+					case formulaError:
+						return v, nil
+					}
+				}
+				continue
+			}
+			n, err := toNumber(arg)
+			if err != "" {
+				return err, nil
+			}
+			values = append(values, n)
+		}
+		return fn(values), nil
+	}
+}
+
+func numeric1(fn func(float64) float64) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		if len(args) != 1 {
+			return nil, errUnsupportedFormula
+		}
+		n, err := toNumber(scalar(args[0]))
+		if err != "" {
+			return err, nil
+		}
+		return fn(n), nil
+	}
+}
+
+func rounding(fn func(float64) float64) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		if len(args) != 2 {
+			return nil, errUnsupportedFormula
+		}
+		return arithmetic(args[0], args[1], func(x, digits float64) any {
+			scale := math.Pow(10, math.Trunc(digits))
+			return fn(x*scale) / scale
+		}), nil
+	}
+}
+
+func logical(fn func(values []bool) bool) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		var values []bool
+		for _, arg := range args {
+			for _, v := range flatten(arg) {
+				if v == nil {
+					continue
+				}
+				b, err := toBool(v)
+				if err != "" {
+					return err, nil
+				}
+				values = append(values, b)
+			}
+		}
+		if len(values) == 0 {
+			return errValue, nil
+		}
+		return fn(values), nil
+	}
+}
+
+func concat(args []any) (any, error) {
+	var b strings.Builder
+	for _, arg := range args {
+		for _, v := range flatten(arg) {
+			if e, ok := v.(formulaError); ok {
+				return e, nil
+			}
+			b.WriteString(formulaText(v))
+		}
+	}
+	return b.String(), nil
+}
+
+func text1(fn func(string) any) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		if len(args) != 1 {
+			return nil, errUnsupportedFormula
+		}
+		value := scalar(args[0])
+		if e, ok := value.(formulaError); ok {
+			return e, nil
+		}
+		return fn(formulaText(value)), nil
+	}
+}
+
+func substring(fn func(s []rune, n int) string) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		if len(args) < 1 || len(args) > 2 {
+			return nil, errUnsupportedFormula
+		}
+		value := scalar(args[0])
+		if e, ok := value.(formulaError); ok {
+			return e, nil
+		}
+		n := 1.0
+		if len(args) == 2 {
+			var err formulaError
+			if n, err = toNumber(scalar(args[1])); err != "" {
+				return err, nil
+			}
+		}
+		if n < 0 {
+			return errValue, nil
+		}
+		return fn([]rune(formulaText(value)), int(n)), nil
+	}
+}
+
+// scalar returns the value of a range of one cell; a larger range, which
+// Excel would intersect with the formula's row or column, is #VALUE!.
+func scalar(value any) any {
+	if r, ok := value.(cellRange); ok {
+		if len(r) != 1 {
+			return errValue
+		}
+		return r[0]
+	}
+	return value
+}
+
+func flatten(value any) []any {
+	if r, ok := value.(cellRange); ok {
+		return r
+	}
+	return []any{value}
+}
+
+func firstError(values ...any) (formulaError, bool) {
+	for _, v := range values {
+		if e, ok := v.(formulaError); ok {
+			return e, true
+		}
+	}
+	return "", false
+}
+
+// arithmetic applies op to two values converted to numbers.
+func arithmetic(left, right any, op func(a, b float64) any) any {
+	a, err := toNumber(scalar(left))
+	if err != "" {
+		return err
+	}
+	b, err := toNumber(scalar(right))
+	if err != "" {
+		return err
+	}
+	return op(a, b)
+}
+
+// toNumber converts a value the way Excel operators do.
+func toNumber(value any) (float64, formulaError) {
+	switch v := value.(type) {
+	case nil:
+		return 0, ""
+	case float64:
+		return v, ""
+	case bool:
+		if v {
+			return 1, ""
+		}
+		return 0, ""
+	case string:
+		if n, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return n, ""
+		}
+		return 0, errValue
+	case formulaError:
+		return 0, v
+	}
+	return 0, errValue
+}
+
+func toBool(value any) (bool, formulaError) {
+	switch v := value.(type) {
+	case nil:
+		return false, ""
+	case bool:
+		return v, ""
+	case float64:
+		return v != 0, ""
+	case string:
+		if strings.EqualFold(v, "TRUE") {
+			return true, ""
+		}
+		if strings.EqualFold(v, "FALSE") {
+			return false, ""
+		}
+		return false, errValue
+	case formulaError:
+		return false, v
+	}
+	return false, errValue
+}
+
+// compareValues orders values like Excel: numbers before text before
+// booleans, text without regard to case. An empty cell compares as the
+// empty value of the other side's type.
+func compareValues(a, b any) int {
+	if a == nil {
+		a = emptyLike(b)
+	}
+	if b == nil {
+		b = emptyLike(a)
+	}
+	rank := func(v any) int {
+		switch v.(type) {
+		case float64:
+			return 0
+		case string:
+			return 1
+		}
+		return 2
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return cmp.Compare(ra, rb)
+	}
+	switch a := a.(type) {
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case string:
+		return cmp.Compare(strings.ToLower(a), strings.ToLower(b.(string)))
+	case bool:
+		x, _ := toNumber(a)
+		y, _ := toNumber(b)
+		return cmp.Compare(x, y)
+	}
+	return 0
+}
+
+func emptyLike(value any) any {
+	switch value.(type) {
+	case string:
+		return ""
+	case bool:
+		return false
+	}
+	return 0.0
+}
+
+// formulaText formats a value as Excel shows it in a General cell.
+func formulaText(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		// Excel keeps 15 significant digits
+		rounded, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 15, 64), 64)
+		return strconv.FormatFloat(rounded, 'f', -1, 64)
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case string:
+		return v
+	case formulaError:
+		return string(v)
+	}
+	return fmt.Sprint(value)
+}
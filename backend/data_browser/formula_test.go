@@ -0,0 +1,153 @@
+package data_browser
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"bronze-backend/httputil"
+	"github.com/tealeg/xlsx/v3"
+)
+
+// formulaWorkbook returns an .xlsx file whose Data sheet has numbers in
+// A1:A3, text in B1 and C1 and the formulas given, one per row of column
+// D, and whose Q1 Rates sheet has 0.5 in A1.
+func formulaWorkbook(t *testing.T, formulas ...string) []byte {
+	t.Helper()
+	wb := xlsx.NewFile()
+	data, err := wb.AddSheet("Data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	numbers := []float64{10, 20, 12.5}
+	for i := 0; i < max(len(numbers), len(formulas)); i++ {
+		row := data.AddRow()
+		a, b, c, d := row.AddCell(), row.AddCell(), row.AddCell(), row.AddCell()
+		if i < len(numbers) {
+			a.SetFloat(numbers[i])
+		}
+		if i == 0 {
+			b.SetString("north")
+			c.SetString("East ")
+		}
+		if i < len(formulas) {
+			d.SetFormula(formulas[i])
+		}
+	}
+
+	rates, err := wb.AddSheet("Q1 Rates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rates.AddRow().AddCell().SetFloat(0.5)
+
+	var buf bytes.Buffer
+	if err := wb.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// readFormulas browses column D of the Data sheet in mode.
+func readFormulas(t *testing.T, mode string, formulas ...string) []string {
+	t.Helper()
+	h := &DataBrowserHandler{}
+	response, err := h.processExcelFile(formulaWorkbook(t, formulas...), BrowseRequest{
+		FileName:    "book.xlsx",
+		SheetName:   "Data",
+		MaxRows:     100,
+		FormulaMode: mode,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []string
+	for _, row := range response.Rows[:len(formulas)] {
+		values = append(values, row[3])
+	}
+	return values
+}
+
+func TestFormulaEvaluate(t *testing.T) {
+	for _, tt := range []struct {
+		formula string
+		want    string
+	}{
+		{"A1+A2*2", "50"},
+		{"(A1+A2)*2", "60"},
+		{"-A1^2", "100"},
+		{"A3/A1", "1.25"},
+		{"A1/0", "#DIV/0!"},
+		{"A1/0+1", "#DIV/0!"},
+		{"IFERROR(A1/0,0)", "0"},
+		{"50%", "0.5"},
+		{"0.1+0.2", "0.3"},
+		{"SUM(A1:A3)", "42.5"},
+		{"SUM(A1:C3)", "42.5"},
+		{"AVERAGE(A1:A2)", "15"},
+		{"MIN(A1:A3)&\"-\"&MAX(A1:A3)", "10-20"},
+		{"COUNT(A1:C3)", "3"},
+		{"COUNTA(A1:C3)", "5"},
+		{"ROUND(A3/3,2)", "4.17"},
+		{"ROUNDUP(A3,0)", "13"},
+		{"MOD(A1,3)", "1"},
+		{"IF(A1>A2,\"up\",\"down\")", "down"},
+		{"IF(AND(A1>5,NOT(A2<5)),1,2)", "1"},
+		{"B1=\"NORTH\"", "TRUE"},
+		{"A1<B1", "TRUE"},
+		{"UPPER(B1)&\"/\"&TRIM(C1)", "NORTH/East"},
+		{"_xlfn.CONCAT(B1:C1)", "northEast "},
+		{"CONCATENATE(LEFT(B1,2),RIGHT(B1))", "noh"},
+		{"LEN(C1)", "5"},
+		{"$A$1*'Q1 Rates'!A1", "5"},
+		{"A1+Missing!A1", "#REF!"},
+		{"B1+1", "#VALUE!"},
+		{"D1+1", "51"}, // D1 holds the first formula, A1+A2*2
+	} {
+		got := readFormulas(t, "evaluate", "A1+A2*2", tt.formula)[1]
+		if got != tt.want {
+			t.Errorf("=%s evaluates to %q, want %q", tt.formula, got, tt.want)
+		}
+	}
+}
+
+func TestFormulaModes(t *testing.T) {
+	formulas := []string{"SUM(A1:A3)", "VLOOKUP(A1,A1:A3,1)", "D3+1", "D4"}
+	if got := readFormulas(t, "formula", formulas...); got[0] != "=SUM(A1:A3)" || got[1] != "=VLOOKUP(A1,A1:A3,1)" {
+		t.Errorf("formula mode = %q, want the formulas", got)
+	}
+	// Unsupported and circular formulas fall back to their cached value,
+	// which this workbook has none of
+	if got := readFormulas(t, "evaluate", formulas...); got[0] != "42.5" || got[1] != "" || got[2] != "" {
+		t.Errorf("evaluate mode = %q", got)
+	}
+	if got := readFormulas(t, "", formulas...); got[0] != "" {
+		t.Errorf("cached mode = %q, want the cached value", got)
+	}
+
+	h := &DataBrowserHandler{}
+	_, err := h.processExcelFile(formulaWorkbook(t), BrowseRequest{FileName: "book.xlsx", FormulaMode: "guess"})
+	var apiErr *httputil.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != httputil.CodeBadRequest {
+		t.Errorf("unknown mode: error %v, want a bad request", err)
+	}
+}
+
+func TestApplyFormulaMode(t *testing.T) {
+	files := []FileExportInfo{{FileName: "a.xlsx"}, {FileName: "b.xlsx", FormulaMode: "formula"}}
+	request := ExportRequest{Files: files, FormulaMode: "evaluate"}
+	if err := applyFormulaMode(&request); err != nil {
+		t.Fatal(err)
+	}
+	if request.Files[0].FormulaMode != "evaluate" || request.Files[1].FormulaMode != "formula" {
+		t.Errorf("files = %+v", request.Files)
+	}
+	if files[0].FormulaMode != "" {
+		t.Error("the caller's files were changed")
+	}
+
+	request = ExportRequest{Files: []FileExportInfo{{FileName: "a.xlsx", FormulaMode: "raw"}}}
+	if err := applyFormulaMode(&request); err == nil {
+		t.Error("an unknown file formula mode was accepted")
+	}
+}
@@ -0,0 +1,150 @@
+package data_browser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"bronze-backend/httputil"
+)
+
+// MaxFrequencyValues caps the values listed per column.
+const MaxFrequencyValues = 1000
+
+// FrequencyRequest asks for the most frequent values of columns of a file.
+type FrequencyRequest struct {
+	FileName   string `json:"file_name"`
+	SheetName  string `json:"sheet_name,omitempty"`
+	TreatAsCSV bool   `json:"treat_as_csv,omitempty"`
+	// Columns defaults to every column of the file
+	Columns []string `json:"columns,omitempty"`
+	// Top is the values listed per column, default 10
+	Top int `json:"top,omitempty"`
+	CleanOptions
+}
+
+// ValueCount is a value and the rows holding it.
+type ValueCount struct {
+	Value   string  `json:"value"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"` // Of the column's non-empty values
+}
+
+// ColumnFrequencies are the most frequent values of a column, most frequent
+// first, equally frequent ones by value.
+type ColumnFrequencies struct {
+	Column   string       `json:"column"`
+	Distinct int          `json:"distinct"` // Non-empty values
+	Empty    int          `json:"empty"`    // Rows without a value, NULL included
+	Values   []ValueCount `json:"values"`
+	// Other counts the rows of the non-empty values not listed
+	Other int `json:"other"`
+}
+
+// GetValueFrequencies answers the most frequent values of columns of a
+// file, to spot bad categorical values before an export.
+func (h *DataBrowserHandler) GetValueFrequencies(w http.ResponseWriter, r *http.Request) {
+	var request FrequencyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		httputil.WriteError(w, "Failed to decode request", http.StatusBadRequest, err)
+		return
+	}
+	if request.FileName == "" {
+		httputil.WriteError(w, "file_name is required", http.StatusBadRequest, nil)
+		return
+	}
+	if request.Top <= 0 {
+		request.Top = 10
+	}
+	if request.Top > MaxFrequencyValues {
+		request.Top = MaxFrequencyValues
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 300*time.Second)
+	defer cancel()
+
+	data, err := h.downloadVersion(ctx, request.FileName, "")
+	if err != nil {
+		httputil.WriteError(w, err.Error(), http.StatusInternalServerError, err)
+		return
+	}
+	parsed, err := h.readData(data, BrowseRequest{
+		FileName:     request.FileName,
+		SheetName:    request.SheetName,
+		TreatAsCSV:   request.TreatAsCSV,
+		HasHeaders:   true,
+		MaxRows:      fullReadRows,
+		CleanOptions: request.CleanOptions,
+	})
+	if err != nil {
+		httputil.WriteError(w, err.Error(), http.StatusInternalServerError, err)
+		return
+	}
+
+	frequencies, err := valueFrequencies(parsed.Columns, parsed.Rows, request.Columns, request.Top)
+	if err != nil {
+		httputil.WriteCode(w, httputil.CodeBadRequest, err.Error(), nil)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success":     true,
+		"file_name":   request.FileName,
+		"sheet_name":  parsed.SheetName,
+		"total_rows":  len(parsed.Rows),
+		"frequencies": frequencies,
+	})
+}
+
+// valueFrequencies counts the values of the named columns, or of every
+// column, listing the top most frequent of each.
+func valueFrequencies(columns []string, rows [][]string, selected []string, top int) ([]ColumnFrequencies, error) {
+	positions := columnPositions(columns)
+	if len(selected) == 0 {
+		selected = columns
+	}
+	for _, column := range selected {
+		if _, ok := positions[column]; !ok {
+			return nil, fmt.Errorf("column %q is not in the file", column)
+		}
+	}
+
+	frequencies := make([]ColumnFrequencies, 0, len(selected))
+	for _, column := range selected {
+		position := positions[column]
+		counts := make(map[string]int)
+		result := ColumnFrequencies{Column: column, Values: []ValueCount{}}
+		for _, row := range rows {
+			if position >= len(row) || row[position] == "" {
+				result.Empty++
+				continue
+			}
+			counts[row[position]]++
+		}
+
+		values := make([]ValueCount, 0, len(counts))
+		filled := 0
+		for value, count := range counts {
+			values = append(values, ValueCount{Value: value, Count: count})
+			filled += count
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+			return values[i].Value < values[j].Value
+		})
+
+		result.Distinct = len(values)
+		for _, value := range values[:min(top, len(values))] {
+			value.Percent = float64(value.Count) * 100 / float64(filled)
+			result.Values = append(result.Values, value)
+			result.Other -= value.Count
+		}
+		result.Other += filled
+		frequencies = append(frequencies, result)
+	}
+	return frequencies, nil
+}
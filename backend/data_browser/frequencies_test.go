@@ -0,0 +1,34 @@
+package data_browser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValueFrequencies(t *testing.T) {
+	columns := []string{"id", "status"}
+	rows := [][]string{{"1", "paid"}, {"2", "open"}, {"3", "paid"}, {"4", ""}, {"5", "PAID"}, {"6", "open"}, {"7"}}
+
+	frequencies, err := valueFrequencies(columns, rows, []string{"status"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ColumnFrequencies{{
+		Column:   "status",
+		Distinct: 3,
+		Empty:    2,
+		Values:   []ValueCount{{Value: "open", Count: 2, Percent: 40}, {Value: "paid", Count: 2, Percent: 40}},
+		Other:    1,
+	}}
+	if !reflect.DeepEqual(frequencies, want) {
+		t.Errorf("valueFrequencies = %+v, want %+v", frequencies, want)
+	}
+
+	all, err := valueFrequencies(columns, rows, nil, 10)
+	if err != nil || len(all) != 2 || all[0].Distinct != 7 || all[0].Other != 0 {
+		t.Errorf("every column = %+v, %v", all, err)
+	}
+	if _, err := valueFrequencies(columns, rows, []string{"region"}, 10); err == nil {
+		t.Error("an unknown column was accepted")
+	}
+}
@@ -0,0 +1,463 @@
+package data_browser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"bronze-backend/httputil"
+)
+
+// Kinds of geographic columns
+const (
+	GeoKindLatLon  = "lat_lon" // A pair of latitude and longitude columns
+	GeoKindWKT     = "wkt"
+	GeoKindGeoJSON = "geojson"
+)
+
+// GeometryMixed is the geometry type of a column holding several types.
+const GeometryMixed = "Geometry"
+
+// Columns are detected from their first geoSampleRows rows, of which at
+// least geoMatchShare of the non-empty values must be geographic.
+const (
+	geoSampleRows = 200
+	geoMatchShare = 0.9
+)
+
+// Limits of a geographic preview
+const (
+	DefaultGeoFeatures = 1000
+	MaxGeoFeatures     = 10000
+)
+
+// GeoColumn is a column, or pair of columns, holding geographic data.
+type GeoColumn struct {
+	Kind string `json:"kind"`
+	// Column holds the geometries of a wkt or geojson column
+	Column string `json:"column,omitempty"`
+	// LatColumn and LonColumn are the columns of a lat_lon pair
+	LatColumn string `json:"lat_column,omitempty"`
+	LonColumn string `json:"lon_column,omitempty"`
+	// GeometryType is the GeoJSON type of the values, Geometry when mixed
+	GeometryType string `json:"geometry_type"`
+}
+
+var (
+	latitudeNames  = map[string]bool{"lat": true, "latitude": true}
+	longitudeNames = map[string]bool{"lon": true, "lng": true, "long": true, "longitude": true}
+)
+
+// detectGeoColumns finds the latitude/longitude pairs and the WKT or
+// GeoJSON columns of a file from its first rows. A pair is named alike but
+// for the latitude and longitude words, as pickup_lat and pickup_lng are.
+func detectGeoColumns(columns []string, rows [][]string) []GeoColumn {
+	sample := rows[:min(len(rows), geoSampleRows)]
+	var detected []GeoColumn
+	paired := make(map[int]bool)
+
+	latitudes := make(map[string]int)
+	for i, column := range columns {
+		if key, ok := coordinateKey(column, latitudeNames); ok && inRange(sample, i, 90) {
+			if _, seen := latitudes[key]; !seen {
+				latitudes[key] = i
+			}
+		}
+	}
+	for i, column := range columns {
+		key, ok := coordinateKey(column, longitudeNames)
+		if !ok {
+			continue
+		}
+		lat, found := latitudes[key]
+		if !found || paired[lat] || !inRange(sample, i, 180) {
+			continue
+		}
+		paired[lat], paired[i] = true, true
+		detected = append(detected, GeoColumn{
+			Kind:         GeoKindLatLon,
+			LatColumn:    columns[lat],
+			LonColumn:    column,
+			GeometryType: GeometryPoint,
+		})
+	}
+
+	for i, column := range columns {
+		if paired[i] {
+			continue
+		}
+		if geo, ok := detectGeometryColumn(sample, i); ok {
+			geo.Column = column
+			detected = append(detected, geo)
+		}
+	}
+	return detected
+}
+
+// coordinateKey returns name without the word of names it holds, or false
+// when it holds none: Pickup_Latitude keys as pickup_.
+func coordinateKey(name string, names map[string]bool) (string, bool) {
+	words := nameWords(name)
+	for i, word := range words {
+		if names[word] {
+			words[i] = ""
+			return strings.Join(words, "_"), true
+		}
+	}
+	return "", false
+}
+
+// nameWords splits a column name into lower-case words at non-alphanumeric
+// characters and camel-case humps.
+func nameWords(name string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				words, word = append(words, string(word)), nil
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && len(word) > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			words, word = append(words, string(word)), nil
+		}
+		word = append(word, unicode.ToLower(r))
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// inRange reports whether the non-empty values of column i of rows are
+// numbers within ±limit, as latitudes and longitudes are.
+func inRange(rows [][]string, i int, limit float64) bool {
+	filled, valid := 0, 0
+	for _, row := range rows {
+		if i >= len(row) || strings.TrimSpace(row[i]) == "" {
+			continue
+		}
+		filled++
+		if _, ok := coordinate(row[i], limit); ok {
+			valid++
+		}
+	}
+	return filled > 0 && float64(valid) >= geoMatchShare*float64(filled)
+}
+
+// coordinate parses a latitude or longitude within ±limit.
+func coordinate(value string, limit float64) (float64, bool) {
+	c, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(c) || math.Abs(c) > limit {
+		return 0, false
+	}
+	return c, true
+}
+
+// detectGeometryColumn reports whether column i of rows holds WKT or
+// GeoJSON geometries, and of which type.
+func detectGeometryColumn(rows [][]string, i int) (GeoColumn, bool) {
+	filled := 0
+	kinds := make(map[string]int)
+	types := make(map[string]bool)
+	for _, row := range rows {
+		if i >= len(row) || strings.TrimSpace(row[i]) == "" {
+			continue
+		}
+		filled++
+		value := strings.TrimSpace(row[i])
+		// Only text starting like a geometry is worth parsing
+		if !strings.HasPrefix(value, "{") && !unicode.IsLetter(rune(value[0])) {
+			continue
+		}
+		g, kind, err := parseGeometry(value)
+		if err != nil {
+			continue
+		}
+		kinds[kind]++
+		types[g.Type] = true
+	}
+
+	geo := GeoColumn{GeometryType: GeometryMixed}
+	for kind, count := range kinds {
+		if filled > 0 && float64(count) >= geoMatchShare*float64(filled) {
+			geo.Kind = kind
+		}
+	}
+	if geo.Kind == "" {
+		return GeoColumn{}, false
+	}
+	if len(types) == 1 {
+		for geometryType := range types {
+			geo.GeometryType = geometryType
+		}
+	}
+	return geo, true
+}
+
+// geoColumnTypes returns the table types of the geographic columns of an
+// export: GEOMETRY for WKT and GeoJSON columns and DOUBLE for latitudes and
+// longitudes. A column gets one only when every file holding it agrees.
+func geoColumnTypes(results []ProcessingResult, columns []string) map[string]string {
+	// The type of each column of each file, by lower-case name
+	fileTypes := make([]map[string]string, len(results))
+	for i, result := range results {
+		if !result.Success {
+			continue
+		}
+		fileTypes[i] = make(map[string]string)
+		for _, geo := range detectGeoColumns(result.Columns, result.Rows) {
+			if geo.Kind == GeoKindLatLon {
+				fileTypes[i][strings.ToLower(geo.LatColumn)] = "DOUBLE"
+				fileTypes[i][strings.ToLower(geo.LonColumn)] = "DOUBLE"
+			} else {
+				fileTypes[i][strings.ToLower(geo.Column)] = "GEOMETRY"
+			}
+		}
+	}
+
+	types := make(map[string]string)
+	for _, column := range columns {
+		columnType := ""
+		for i, result := range results {
+			if !result.Success || columnIndex(result.Columns, column) < 0 {
+				continue
+			}
+			fileType := fileTypes[i][strings.ToLower(column)]
+			if fileType == "" || (columnType != "" && columnType != fileType) {
+				columnType = ""
+				break
+			}
+			columnType = fileType
+		}
+		if columnType != "" {
+			types[column] = columnType
+		}
+	}
+	return types
+}
+
+// GeoPreviewRequest asks for the rows of a file as GeoJSON features.
+type GeoPreviewRequest struct {
+	FileName   string `json:"file_name"`
+	SheetName  string `json:"sheet_name,omitempty"`
+	TreatAsCSV bool   `json:"treat_as_csv,omitempty"`
+	// Column, or LatColumn and LonColumn, hold the geometries; default the
+	// first geographic column detected
+	Column    string `json:"column,omitempty"`
+	LatColumn string `json:"lat_column,omitempty"`
+	LonColumn string `json:"lon_column,omitempty"`
+	// Properties are the columns copied to the features, default the others
+	Properties []string `json:"properties,omitempty"`
+	// MaxFeatures defaults to 1000
+	MaxFeatures int `json:"max_features,omitempty"`
+	// Tolerance is how far, in coordinate units, simplified lines may stray;
+	// 0 picks one from the extent of the features, below 0 keeps them exact
+	Tolerance float64 `json:"tolerance,omitempty"`
+	CleanOptions
+}
+
+// GeoFeature is a GeoJSON feature.
+type GeoFeature struct {
+	Type       string            `json:"type"`
+	Geometry   *Geometry         `json:"geometry"`
+	Properties map[string]string `json:"properties"`
+}
+
+// FeatureCollection is a GeoJSON feature collection.
+type FeatureCollection struct {
+	Type     string       `json:"type"`
+	Features []GeoFeature `json:"features"`
+	// BBox is [min x, min y, max x, max y] of the features
+	BBox []float64 `json:"bbox,omitempty"`
+}
+
+// GeoPreview is the geographic data of a file, ready for a map.
+type GeoPreview struct {
+	GeoColumn  GeoColumn         `json:"geo_column"`
+	Collection FeatureCollection `json:"collection"`
+	Tolerance  float64           `json:"tolerance"` // Used to simplify
+	// InvalidRows counts the rows without a readable geometry
+	InvalidRows int  `json:"invalid_rows"`
+	Truncated   bool `json:"truncated,omitempty"`
+}
+
+// PreviewGeoData answers the geographic column of a file as simplified
+// GeoJSON features, for mapping the data before an export.
+func (h *DataBrowserHandler) PreviewGeoData(w http.ResponseWriter, r *http.Request) {
+	var request GeoPreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		httputil.WriteError(w, "Failed to decode request", http.StatusBadRequest, err)
+		return
+	}
+	if request.FileName == "" {
+		httputil.WriteError(w, "file_name is required", http.StatusBadRequest, nil)
+		return
+	}
+	if (request.LatColumn == "") != (request.LonColumn == "") {
+		httputil.WriteError(w, "lat_column and lon_column go together", http.StatusBadRequest, nil)
+		return
+	}
+	if request.MaxFeatures <= 0 {
+		request.MaxFeatures = DefaultGeoFeatures
+	}
+	if request.MaxFeatures > MaxGeoFeatures {
+		request.MaxFeatures = MaxGeoFeatures
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 300*time.Second)
+	defer cancel()
+
+	data, err := h.downloadVersion(ctx, request.FileName, "")
+	if err != nil {
+		httputil.WriteError(w, err.Error(), http.StatusInternalServerError, err)
+		return
+	}
+	parsed, err := h.readData(data, BrowseRequest{
+		FileName:     request.FileName,
+		SheetName:    request.SheetName,
+		TreatAsCSV:   request.TreatAsCSV,
+		HasHeaders:   true,
+		MaxRows:      fullReadRows,
+		CleanOptions: request.CleanOptions,
+	})
+	if err != nil {
+		httputil.WriteError(w, err.Error(), http.StatusInternalServerError, err)
+		return
+	}
+
+	detected := detectGeoColumns(parsed.Columns, parsed.Rows)
+	var geo GeoColumn
+	switch {
+	case request.LatColumn != "":
+		geo = GeoColumn{Kind: GeoKindLatLon, LatColumn: request.LatColumn, LonColumn: request.LonColumn, GeometryType: GeometryPoint}
+	case request.Column != "":
+		geo = GeoColumn{Column: request.Column}
+	case len(detected) > 0:
+		geo = detected[0]
+	default:
+		httputil.WriteCode(w, httputil.CodeBadRequest, "No geographic column was detected; name one with column or lat_column and lon_column", nil)
+		return
+	}
+
+	preview, err := geoPreview(parsed.Columns, parsed.Rows, geo, request.Properties, request.MaxFeatures, request.Tolerance)
+	if err != nil {
+		httputil.WriteCode(w, httputil.CodeBadRequest, err.Error(), nil)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success":     true,
+		"file_name":   request.FileName,
+		"sheet_name":  parsed.SheetName,
+		"total_rows":  len(parsed.Rows),
+		"geo_columns": detected,
+		"preview":     preview,
+	})
+}
+
+// geoPreview turns up to limit rows into features of geo's geometry with
+// properties, or every other column, as properties. A column named without
+// a kind is read as WKT or GeoJSON value by value.
+func geoPreview(columns []string, rows [][]string, geo GeoColumn, properties []string, limit int, tolerance float64) (*GeoPreview, error) {
+	positions := columnPositions(columns)
+	geoColumns := []string{geo.Column}
+	if geo.Kind == GeoKindLatLon {
+		geoColumns = []string{geo.LatColumn, geo.LonColumn}
+	}
+	for _, column := range geoColumns {
+		if _, ok := positions[column]; !ok {
+			return nil, fmt.Errorf("column %q is not in the file", column)
+		}
+	}
+	if len(properties) == 0 {
+		for _, column := range columns {
+			if column != geoColumns[0] && column != geoColumns[len(geoColumns)-1] {
+				properties = append(properties, column)
+			}
+		}
+	}
+	for _, column := range properties {
+		if _, ok := positions[column]; !ok {
+			return nil, fmt.Errorf("column %q is not in the file", column)
+		}
+	}
+
+	value := func(row []string, column string) string {
+		if i := positions[column]; i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	preview := &GeoPreview{
+		GeoColumn:  geo,
+		Collection: FeatureCollection{Type: "FeatureCollection", Features: []GeoFeature{}},
+	}
+	types := make(map[string]bool)
+	for _, row := range rows {
+		var g *Geometry
+		if geo.Kind == GeoKindLatLon {
+			lat, latOK := coordinate(value(row, geo.LatColumn), 90)
+			lon, lonOK := coordinate(value(row, geo.LonColumn), 180)
+			if latOK && lonOK {
+				g = &Geometry{Type: GeometryPoint, Coordinates: []float64{lon, lat}}
+			}
+		} else if parsed, kind, err := parseGeometry(value(row, geo.Column)); err == nil && (geo.Kind == "" || geo.Kind == kind) {
+			g = parsed
+			if preview.GeoColumn.Kind == "" {
+				preview.GeoColumn.Kind = kind
+			}
+		}
+		if g == nil {
+			preview.InvalidRows++
+			continue
+		}
+		if len(preview.Collection.Features) == limit {
+			preview.Truncated = true
+			continue
+		}
+		types[g.Type] = true
+		feature := GeoFeature{Type: "Feature", Geometry: g, Properties: make(map[string]string, len(properties))}
+		for _, column := range properties {
+			feature.Properties[column] = value(row, column)
+		}
+		preview.Collection.Features = append(preview.Collection.Features, feature)
+	}
+	if preview.GeoColumn.GeometryType == "" {
+		preview.GeoColumn.GeometryType = GeometryMixed
+		if len(types) == 1 {
+			for geometryType := range types {
+				preview.GeoColumn.GeometryType = geometryType
+			}
+		}
+	}
+
+	features := preview.Collection.Features
+	if len(features) == 0 {
+		return preview, nil
+	}
+	bbox := []float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, feature := range features {
+		feature.Geometry.extend(bbox)
+	}
+	preview.Collection.BBox = bbox
+
+	// A thousandth of the extent keeps shapes recognizable on a map
+	if tolerance == 0 {
+		tolerance = math.Hypot(bbox[2]-bbox[0], bbox[3]-bbox[1]) / 1000
+	}
+	if tolerance > 0 {
+		preview.Tolerance = tolerance
+		for i := range features {
+			features[i].Geometry = features[i].Geometry.simplify(tolerance)
+		}
+	}
+	return preview, nil
+}
@@ -0,0 +1,127 @@
+package data_browser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWKT(t *testing.T) {
+	cases := []struct {
+		wkt  string
+		want *Geometry
+	}{
+		{"POINT (30 10)", &Geometry{Type: GeometryPoint, Coordinates: []float64{30, 10}}},
+		{"SRID=4326;point z (1 2 3)", &Geometry{Type: GeometryPoint, Coordinates: []float64{1, 2}}},
+		{"LINESTRING (30 10, 10 30, 40 40)", &Geometry{Type: GeometryLineString, Coordinates: [][]float64{{30, 10}, {10, 30}, {40, 40}}}},
+		{"MULTIPOINT ((10 40), (40 30))", &Geometry{Type: GeometryMultiPoint, Coordinates: [][]float64{{10, 40}, {40, 30}}}},
+		{"MULTIPOINT (10 40, 40 30)", &Geometry{Type: GeometryMultiPoint, Coordinates: [][]float64{{10, 40}, {40, 30}}}},
+		{"POLYGON ((0 0, 4 0, 4 4, 0 0))", &Geometry{Type: GeometryPolygon, Coordinates: [][][]float64{{{0, 0}, {4, 0}, {4, 4}, {0, 0}}}}},
+		{"MULTIPOLYGON (((0 0, 1 0, 1 1, 0 0)), ((5 5, 6 5, 6 6, 5 5)))", &Geometry{Type: GeometryMultiPolygon, Coordinates: [][][][]float64{
+			{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}},
+			{{{5, 5}, {6, 5}, {6, 6}, {5, 5}}},
+		}}},
+	}
+	for _, c := range cases {
+		got, err := parseWKT(c.wkt)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseWKT(%q) = %+v, %v; want %+v", c.wkt, got, err, c.want)
+		}
+	}
+
+	for _, bad := range []string{"POINT EMPTY", "POINT (1)", "CIRCLE (1 2)", "POINT (1 2) extra", "LINESTRING (1 2, x 3)", "related"} {
+		if _, err := parseWKT(bad); err == nil {
+			t.Errorf("parseWKT(%q) was accepted", bad)
+		}
+	}
+}
+
+func TestParseGeoJSONGeometry(t *testing.T) {
+	g, kind, err := parseGeometry(`{"type":"Feature","geometry":{"type":"LineString","coordinates":[[1,2,3],[4,5]]},"properties":{}}`)
+	want := &Geometry{Type: GeometryLineString, Coordinates: [][]float64{{1, 2}, {4, 5}}}
+	if err != nil || kind != GeoKindGeoJSON || !reflect.DeepEqual(g, want) {
+		t.Errorf("parseGeometry = %+v, %q, %v", g, kind, err)
+	}
+	for _, bad := range []string{`{"type":"Point","coordinates":[1]}`, `{"type":"Circle"}`, `{"type":"Feature","geometry":null}`} {
+		if _, err := parseGeoJSONGeometry(bad); err == nil {
+			t.Errorf("parseGeoJSONGeometry(%s) was accepted", bad)
+		}
+	}
+}
+
+func TestDetectGeoColumns(t *testing.T) {
+	columns := []string{"id", "pickupLat", "pickup_lng", "related", "area", "Latitude"}
+	rows := [][]string{
+		{"1", "51.5", "-0.12", "x", "POLYGON ((0 0, 1 0, 1 1, 0 0))", "95"},
+		{"2", "48.85", "2.35", "y", "POLYGON ((2 2, 3 2, 3 3, 2 2))", "12"},
+		{"3", "", "", "z", "", ""},
+	}
+	want := []GeoColumn{
+		{Kind: GeoKindLatLon, LatColumn: "pickupLat", LonColumn: "pickup_lng", GeometryType: GeometryPoint},
+		{Kind: GeoKindWKT, Column: "area", GeometryType: GeometryPolygon},
+	}
+	if got := detectGeoColumns(columns, rows); !reflect.DeepEqual(got, want) {
+		t.Errorf("detectGeoColumns = %+v, want %+v", got, want)
+	}
+
+	results := []ProcessingResult{
+		{Success: true, Columns: columns, Rows: rows},
+		{Success: true, Columns: []string{"area"}, Rows: [][]string{{"not a shape"}}},
+	}
+	types := geoColumnTypes(results, []string{"id", "pickupLat", "pickup_lng", "area"})
+	if !reflect.DeepEqual(types, map[string]string{"pickupLat": "DOUBLE", "pickup_lng": "DOUBLE"}) {
+		t.Errorf("geoColumnTypes = %v", types)
+	}
+}
+
+func TestGeoPreview(t *testing.T) {
+	columns := []string{"name", "route"}
+	rows := [][]string{
+		{"a", "LINESTRING (0 0, 1 0.001, 2 0, 3 0)"},
+		{"b", "nowhere"},
+		{"c", `{"type":"Point","coordinates":[3,4]}`},
+	}
+
+	preview, err := geoPreview(columns, rows, GeoColumn{Column: "route"}, nil, 10, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.InvalidRows != 1 || preview.GeoColumn.Kind != GeoKindWKT || preview.GeoColumn.GeometryType != GeometryMixed {
+		t.Errorf("preview = %+v", preview)
+	}
+	features := preview.Collection.Features
+	if len(features) != 2 || features[0].Properties["name"] != "a" || len(features[0].Properties) != 1 {
+		t.Fatalf("features = %+v", features)
+	}
+	if line := features[0].Geometry.Coordinates; !reflect.DeepEqual(line, [][]float64{{0, 0}, {3, 0}}) {
+		t.Errorf("simplified line = %v", line)
+	}
+	if !reflect.DeepEqual(preview.Collection.BBox, []float64{0, 0, 3, 4}) {
+		t.Errorf("bbox = %v", preview.Collection.BBox)
+	}
+
+	exact, err := geoPreview(columns, rows, GeoColumn{Column: "route"}, []string{}, 1, -1)
+	if err != nil || !exact.Truncated || len(exact.Collection.Features) != 1 || exact.Tolerance != 0 {
+		t.Errorf("exact preview = %+v, %v", exact, err)
+	}
+	if line := exact.Collection.Features[0].Geometry.Coordinates.([][]float64); len(line) != 4 {
+		t.Errorf("unsimplified line = %v", line)
+	}
+
+	points, err := geoPreview([]string{"lat", "lon"}, [][]string{{"10", "20"}, {"100", "20"}},
+		GeoColumn{Kind: GeoKindLatLon, LatColumn: "lat", LonColumn: "lon", GeometryType: GeometryPoint}, nil, 10, 0)
+	if err != nil || points.InvalidRows != 1 || !reflect.DeepEqual(points.Collection.Features[0].Geometry.Coordinates, []float64{20, 10}) {
+		t.Errorf("point preview = %+v, %v", points, err)
+	}
+	if _, err := geoPreview(columns, rows, GeoColumn{Column: "shape"}, nil, 10, 0); err == nil {
+		t.Error("an unknown column was accepted")
+	}
+}
+
+func TestSimplifyKeepsRings(t *testing.T) {
+	ring := [][]float64{{0, 0}, {1, 0.0001}, {2, 0}, {2, 0.0001}, {0, 0}}
+	g := &Geometry{Type: GeometryPolygon, Coordinates: [][][]float64{ring}}
+	simplified := g.simplify(1).Coordinates.([][][]float64)
+	if len(simplified[0]) < 4 {
+		t.Errorf("ring simplified to %v", simplified[0])
+	}
+}
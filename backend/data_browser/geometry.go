@@ -0,0 +1,424 @@
+package data_browser
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Geometry types, as GeoJSON names them
+const (
+	GeometryPoint           = "Point"
+	GeometryLineString      = "LineString"
+	GeometryPolygon         = "Polygon"
+	GeometryMultiPoint      = "MultiPoint"
+	GeometryMultiLineString = "MultiLineString"
+	GeometryMultiPolygon    = "MultiPolygon"
+)
+
+// Geometry is a GeoJSON geometry. Coordinates are a position ([x, y]) for
+// a Point, positions for a LineString or MultiPoint, lists of them for a
+// Polygon or MultiLineString, and lists of polygons for a MultiPolygon.
+// Only x and y are kept.
+type Geometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// parseGeoJSONGeometry parses a GeoJSON geometry, or the geometry of a
+// GeoJSON feature.
+func parseGeoJSONGeometry(value string) (*Geometry, error) {
+	var raw struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+		Geometry    json.RawMessage `json:"geometry"`
+	}
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, err
+	}
+	if raw.Type == "Feature" {
+		if len(raw.Geometry) == 0 || string(raw.Geometry) == "null" {
+			return nil, fmt.Errorf("feature without a geometry")
+		}
+		return parseGeoJSONGeometry(string(raw.Geometry))
+	}
+
+	var coordinates any
+	var err error
+	switch raw.Type {
+	case GeometryPoint:
+		var position []float64
+		if err = json.Unmarshal(raw.Coordinates, &position); err == nil {
+			coordinates, err = checkPosition(position)
+		}
+	case GeometryLineString, GeometryMultiPoint:
+		var positions [][]float64
+		if err = json.Unmarshal(raw.Coordinates, &positions); err == nil {
+			coordinates, err = checkPositions(positions)
+		}
+	case GeometryPolygon, GeometryMultiLineString:
+		var lines [][][]float64
+		if err = json.Unmarshal(raw.Coordinates, &lines); err == nil {
+			coordinates, err = checkLines(lines)
+		}
+	case GeometryMultiPolygon:
+		var polygons [][][][]float64
+		if err = json.Unmarshal(raw.Coordinates, &polygons); err == nil {
+			for i := range polygons {
+				if polygons[i], err = checkLines(polygons[i]); err != nil {
+					break
+				}
+			}
+			coordinates = polygons
+		}
+	default:
+		return nil, fmt.Errorf("unsupported geometry type %q", raw.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Geometry{Type: raw.Type, Coordinates: coordinates}, nil
+}
+
+func checkPosition(position []float64) ([]float64, error) {
+	if len(position) < 2 {
+		return nil, fmt.Errorf("a position needs x and y")
+	}
+	for _, c := range position[:2] {
+		if math.IsNaN(c) || math.IsInf(c, 0) {
+			return nil, fmt.Errorf("invalid coordinate %v", c)
+		}
+	}
+	return position[:2], nil
+}
+
+func checkPositions(positions [][]float64) ([][]float64, error) {
+	var err error
+	for i := range positions {
+		if positions[i], err = checkPosition(positions[i]); err != nil {
+			return nil, err
+		}
+	}
+	return positions, nil
+}
+
+func checkLines(lines [][][]float64) ([][][]float64, error) {
+	var err error
+	for i := range lines {
+		if lines[i], err = checkPositions(lines[i]); err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
+
+// wktTypes maps WKT geometry tags to GeoJSON types.
+var wktTypes = map[string]string{
+	"POINT":           GeometryPoint,
+	"LINESTRING":      GeometryLineString,
+	"POLYGON":         GeometryPolygon,
+	"MULTIPOINT":      GeometryMultiPoint,
+	"MULTILINESTRING": GeometryMultiLineString,
+	"MULTIPOLYGON":    GeometryMultiPolygon,
+}
+
+// parseWKT parses well-known text, optionally EWKT with an SRID=n; prefix.
+// Z, M and ZM geometries keep their x and y. EMPTY geometries are refused.
+func parseWKT(value string) (*Geometry, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(strings.ToUpper(value), "SRID=") {
+		semicolon := strings.IndexByte(value, ';')
+		if semicolon < 0 {
+			return nil, fmt.Errorf("SRID without a geometry")
+		}
+		value = value[semicolon+1:]
+	}
+
+	p := &wktParser{s: value}
+	tag := strings.ToUpper(p.word())
+	geometryType, ok := wktTypes[tag]
+	if !ok {
+		return nil, fmt.Errorf("unsupported WKT geometry %q", tag)
+	}
+	switch dimension := strings.ToUpper(p.word()); dimension {
+	case "", "Z", "M", "ZM":
+	case "EMPTY":
+		return nil, fmt.Errorf("empty geometry")
+	default:
+		return nil, fmt.Errorf("unexpected %q", dimension)
+	}
+
+	var coordinates any
+	var err error
+	switch geometryType {
+	case GeometryPoint:
+		if err = p.expect('('); err == nil {
+			if coordinates, err = p.position(); err == nil {
+				err = p.expect(')')
+			}
+		}
+	case GeometryLineString:
+		coordinates, err = p.positions(false)
+	case GeometryMultiPoint:
+		coordinates, err = p.positions(true)
+	case GeometryPolygon, GeometryMultiLineString:
+		coordinates, err = p.lines()
+	case GeometryMultiPolygon:
+		var polygons [][][][]float64
+		if err = p.expect('('); err == nil {
+			for {
+				var polygon [][][]float64
+				if polygon, err = p.lines(); err != nil {
+					break
+				}
+				polygons = append(polygons, polygon)
+				if !p.next() {
+					err = p.expect(')')
+					break
+				}
+			}
+		}
+		coordinates = polygons
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q after the geometry", p.s[p.pos:])
+	}
+	return &Geometry{Type: geometryType, Coordinates: coordinates}, nil
+}
+
+// wktParser reads well-known text from pos on.
+type wktParser struct {
+	s   string
+	pos int
+}
+
+func (p *wktParser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// word reads a run of letters, or returns "".
+func (p *wktParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && (p.s[p.pos] >= 'A' && p.s[p.pos] <= 'Z' || p.s[p.pos] >= 'a' && p.s[p.pos] <= 'z') {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *wktParser) expect(c byte) error {
+	p.skipSpace()
+	if p.pos >= len(p.s) || p.s[p.pos] != c {
+		return fmt.Errorf("expected %q at offset %d", c, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+// next consumes a comma, reporting whether there was one.
+func (p *wktParser) next() bool {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == ',' {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// position reads the coordinates of a position, keeping x and y.
+func (p *wktParser) position() ([]float64, error) {
+	var position []float64
+	for {
+		p.skipSpace()
+		start := p.pos
+		for p.pos < len(p.s) && strings.IndexByte(" \t\r\n,()", p.s[p.pos]) < 0 {
+			p.pos++
+		}
+		if start == p.pos {
+			break
+		}
+		c, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid coordinate %q", p.s[start:p.pos])
+		}
+		position = append(position, c)
+	}
+	if len(position) < 2 || len(position) > 4 {
+		return nil, fmt.Errorf("a position needs 2 to 4 coordinates at offset %d", p.pos)
+	}
+	return checkPosition(position)
+}
+
+// positions reads a parenthesized list of positions; wrapped allows each
+// to be parenthesized, as MULTIPOINT ((1 2), (3 4)) has them.
+func (p *wktParser) positions(wrapped bool) ([][]float64, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	var positions [][]float64
+	for {
+		p.skipSpace()
+		inner := wrapped && p.pos < len(p.s) && p.s[p.pos] == '('
+		if inner {
+			p.pos++
+		}
+		position, err := p.position()
+		if err != nil {
+			return nil, err
+		}
+		if inner {
+			if err := p.expect(')'); err != nil {
+				return nil, err
+			}
+		}
+		positions = append(positions, position)
+		if !p.next() {
+			return positions, p.expect(')')
+		}
+	}
+}
+
+// lines reads a parenthesized list of position lists.
+func (p *wktParser) lines() ([][][]float64, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	var lines [][][]float64
+	for {
+		line, err := p.positions(false)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+		if !p.next() {
+			return lines, p.expect(')')
+		}
+	}
+}
+
+// parseGeometry parses value as GeoJSON when it is a JSON object, as WKT
+// otherwise, returning the kind it was read as.
+func parseGeometry(value string) (*Geometry, string, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "{") {
+		g, err := parseGeoJSONGeometry(value)
+		return g, GeoKindGeoJSON, err
+	}
+	g, err := parseWKT(value)
+	return g, GeoKindWKT, err
+}
+
+// simplify returns g with its lines simplified by Douglas-Peucker within
+// tolerance. Lines keep their ends, and rings at least 4 positions.
+func (g *Geometry) simplify(tolerance float64) *Geometry {
+	if tolerance <= 0 {
+		return g
+	}
+	simplified := &Geometry{Type: g.Type, Coordinates: g.Coordinates}
+	switch coordinates := g.Coordinates.(type) {
+	case [][]float64:
+		if g.Type == GeometryLineString {
+			simplified.Coordinates = simplifyLine(coordinates, tolerance, 2)
+		}
+	case [][][]float64:
+		simplified.Coordinates = simplifyLines(coordinates, tolerance, g.Type == GeometryPolygon)
+	case [][][][]float64:
+		polygons := make([][][][]float64, len(coordinates))
+		for i, polygon := range coordinates {
+			polygons[i] = simplifyLines(polygon, tolerance, true)
+		}
+		simplified.Coordinates = polygons
+	}
+	return simplified
+}
+
+func simplifyLines(lines [][][]float64, tolerance float64, rings bool) [][][]float64 {
+	least := 2
+	if rings {
+		least = 4
+	}
+	simplified := make([][][]float64, len(lines))
+	for i, line := range lines {
+		simplified[i] = simplifyLine(line, tolerance, least)
+	}
+	return simplified
+}
+
+// simplifyLine drops the positions of line closer than tolerance to the
+// line simplified without them, unless fewer than least would be left.
+func simplifyLine(line [][]float64, tolerance float64, least int) [][]float64 {
+	if len(line) <= least {
+		return line
+	}
+	keep := make([]bool, len(line))
+	keep[0], keep[len(line)-1] = true, true
+	var visit func(first, last int)
+	visit = func(first, last int) {
+		farthest, distance := -1, tolerance
+		for i := first + 1; i < last; i++ {
+			if d := segmentDistance(line[i], line[first], line[last]); d > distance {
+				farthest, distance = i, d
+			}
+		}
+		if farthest >= 0 {
+			keep[farthest] = true
+			visit(first, farthest)
+			visit(farthest, last)
+		}
+	}
+	visit(0, len(line)-1)
+
+	simplified := make([][]float64, 0, len(line))
+	for i, position := range line {
+		if keep[i] {
+			simplified = append(simplified, position)
+		}
+	}
+	if len(simplified) < least {
+		return line
+	}
+	return simplified
+}
+
+// segmentDistance is the distance from p to the segment from a to b.
+func segmentDistance(p, a, b []float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	if dx == 0 && dy == 0 {
+		return math.Hypot(p[0]-a[0], p[1]-a[1])
+	}
+	t := ((p[0]-a[0])*dx + (p[1]-a[1])*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(p[0]-(a[0]+t*dx), p[1]-(a[1]+t*dy))
+}
+
+// extend grows bbox, [minX, minY, maxX, maxY], to hold g.
+func (g *Geometry) extend(bbox []float64) {
+	var visit func(coordinates any)
+	visit = func(coordinates any) {
+		switch c := coordinates.(type) {
+		case []float64:
+			bbox[0], bbox[1] = math.Min(bbox[0], c[0]), math.Min(bbox[1], c[1])
+			bbox[2], bbox[3] = math.Max(bbox[2], c[0]), math.Max(bbox[3], c[1])
+		case [][]float64:
+			for _, position := range c {
+				visit(position)
+			}
+		case [][][]float64:
+			for _, line := range c {
+				visit(line)
+			}
+		case [][][][]float64:
+			for _, polygon := range c {
+				visit(polygon)
+			}
+		}
+	}
+	visit(g.Coordinates)
+}
@@ -0,0 +1,61 @@
+package data_browser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// syntheticColumn names column i, from 0, of a file without a header row:
+// col_1, col_2, ...
+func syntheticColumn(i int) string {
+	return fmt.Sprintf("col_%d", i+1)
+}
+
+// validateHeaders checks the column names supplied for a file.
+func validateHeaders(headers []string) error {
+	seen := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		name := strings.ToLower(strings.TrimSpace(header))
+		if name == "" {
+			continue
+		}
+		if seen[name] {
+			return fmt.Errorf("header %q is given twice", header)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// fileColumns returns the columns of a file whose first row is first and
+// whose rows are up to width values wide. A header row names the columns
+// unless headers are supplied; the columns neither names are synthetic.
+func fileColumns(first []string, hasHeaders bool, headers []string, width int) []string {
+	if hasHeaders && len(headers) == 0 {
+		return first
+	}
+	if hasHeaders {
+		width = len(first)
+	}
+	columns := make([]string, max(width, len(headers)))
+	for i := range columns {
+		switch {
+		case i < len(headers) && strings.TrimSpace(headers[i]) != "":
+			columns[i] = strings.TrimSpace(headers[i])
+		case hasHeaders && i < len(first) && first[i] != "":
+			columns[i] = first[i]
+		default:
+			columns[i] = syntheticColumn(i)
+		}
+	}
+	return columns
+}
+
+// recordWidth returns the values of the widest of records.
+func recordWidth(records [][]string) int {
+	width := 0
+	for _, record := range records {
+		width = max(width, len(record))
+	}
+	return width
+}
@@ -0,0 +1,74 @@
+package data_browser
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/tealeg/xlsx/v3"
+)
+
+func TestHeaderlessCSV(t *testing.T) {
+	csv := "1,Alice,30\n2,Bob,41\n"
+	read := func(request BrowseRequest) BrowseResponse {
+		t.Helper()
+		request.FileName, request.MaxRows = "people.csv", 100
+		response, err := (&DataBrowserHandler{}).readData([]byte(csv), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	response := read(BrowseRequest{})
+	if want := []string{"col_1", "col_2", "col_3"}; !slices.Equal(response.Columns, want) {
+		t.Errorf("columns = %q, want %q", response.Columns, want)
+	}
+	if len(response.Rows) != 2 || response.Rows[0][1] != "Alice" || response.Rows[1][2] != "41" {
+		t.Errorf("rows = %q", response.Rows)
+	}
+
+	response = read(BrowseRequest{Headers: []string{"id", "", "age", "city"}})
+	if want := []string{"id", "col_2", "age", "city"}; !slices.Equal(response.Columns, want) {
+		t.Errorf("columns = %q, want %q", response.Columns, want)
+	}
+
+	// Headers override a header row, which is still skipped
+	response = read(BrowseRequest{HasHeaders: true, Headers: []string{"key"}})
+	if want := []string{"key", "Alice", "30"}; !slices.Equal(response.Columns, want) || len(response.Rows) != 1 {
+		t.Errorf("columns = %q, rows = %q", response.Columns, response.Rows)
+	}
+
+	if _, err := (&DataBrowserHandler{}).readData([]byte(csv), BrowseRequest{FileName: "people.csv", Headers: []string{"id", "ID"}}); err == nil {
+		t.Error("repeated headers were accepted")
+	}
+}
+
+func TestHeaderlessExcel(t *testing.T) {
+	wb := xlsx.NewFile()
+	sheet, err := wb.AddSheet("Data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, values := range [][]string{{"1", "Alice"}, {"2", "Bob", "note"}} {
+		row := sheet.AddRow()
+		for _, value := range values {
+			row.AddCell().SetString(value)
+		}
+	}
+	var buf bytes.Buffer
+	if err := wb.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := (&DataBrowserHandler{}).readData(buf.Bytes(), BrowseRequest{FileName: "people.xlsx", MaxRows: 100, Headers: []string{"id", "name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"id", "name", "col_3"}; !slices.Equal(response.Columns, want) {
+		t.Errorf("columns = %q, want %q", response.Columns, want)
+	}
+	if len(response.Rows) != 2 || response.Rows[0][0] != "1" || response.Rows[1][2] != "note" {
+		t.Errorf("rows = %q", response.Rows)
+	}
+}
@@ -0,0 +1,212 @@
+package data_browser
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// parseJSONRecords flattens a JSON array (of objects or arrays) or newline
+// delimited JSON into columns and string rows. Object keys become columns in
+// the order they are first seen; nested values are kept as compact JSON.
+func parseJSONRecords(data []byte) ([]string, [][]string, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return []string{}, [][]string{}, nil
+	}
+
+	var records []json.RawMessage
+	if trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &records); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse JSON array: %w", err)
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+		line := 0
+		for scanner.Scan() {
+			line++
+			text := bytes.TrimSpace(scanner.Bytes())
+			if len(text) == 0 {
+				continue
+			}
+			if !json.Valid(text) {
+				return nil, nil, fmt.Errorf("invalid JSON on line %d", line)
+			}
+			records = append(records, json.RawMessage(append([]byte(nil), text...)))
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, nil, fmt.Errorf("failed to read JSON lines: %w", err)
+		}
+	}
+
+	var columns []string
+	columnIndex := make(map[string]int)
+	rows := make([][]string, 0, len(records))
+
+	for i, raw := range records {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+
+		var value any
+		if err := decoder.Decode(&value); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse record %d: %w", i+1, err)
+		}
+
+		switch record := value.(type) {
+		case map[string]any:
+			// Decoding into a map loses key order, so walk the raw object
+			// to register new columns in document order
+			for _, key := range jsonObjectKeys(raw) {
+				if _, ok := columnIndex[key]; !ok {
+					columnIndex[key] = len(columns)
+					columns = append(columns, key)
+				}
+			}
+			row := make([]string, len(columns))
+			for key, v := range record {
+				row[columnIndex[key]] = jsonValueString(v)
+			}
+			rows = append(rows, row)
+		case []any:
+			for len(columns) < len(record) {
+				name := fmt.Sprintf("column_%d", len(columns)+1)
+				columnIndex[name] = len(columns)
+				columns = append(columns, name)
+			}
+			row := make([]string, len(columns))
+			for j, v := range record {
+				row[j] = jsonValueString(v)
+			}
+			rows = append(rows, row)
+		default:
+			if _, ok := columnIndex["value"]; !ok {
+				columnIndex["value"] = len(columns)
+				columns = append(columns, "value")
+			}
+			row := make([]string, len(columns))
+			row[columnIndex["value"]] = jsonValueString(record)
+			rows = append(rows, row)
+		}
+	}
+
+	// Rows read before later columns appeared are shorter; pad them
+	for i := range rows {
+		for len(rows[i]) < len(columns) {
+			rows[i] = append(rows[i], "")
+		}
+	}
+
+	return columns, rows, nil
+}
+
+func jsonObjectKeys(raw json.RawMessage) []string {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := decoder.Token(); err != nil {
+		return nil
+	}
+
+	var keys []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return keys
+		}
+		key, ok := token.(string)
+		if !ok {
+			return keys
+		}
+		keys = append(keys, key)
+
+		var skip json.RawMessage
+		if err := decoder.Decode(&skip); err != nil {
+			return keys
+		}
+	}
+	return keys
+}
+
+func jsonValueString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(encoded)
+	}
+}
+
+func (h *DataBrowserHandler) processJSONFile(data []byte, request BrowseRequest) (BrowseResponse, error) {
+	response := BrowseResponse{
+		Success:    true,
+		Message:    "JSON file processed successfully",
+		DataType:   "json",
+		FileName:   request.FileName,
+		HasHeaders: true,
+		Offset:     request.Offset,
+	}
+
+	columns, records, err := parseJSONRecords(data)
+	if err != nil {
+		return response, err
+	}
+
+	response.Columns = columns
+	response.TotalRows = int64(len(records))
+
+	if request.Offset >= len(records) {
+		response.Rows = [][]string{}
+		response.RowCount = 0
+		return response, nil
+	}
+
+	endRow := request.Offset + request.MaxRows
+	if endRow > len(records) {
+		endRow = len(records)
+	}
+
+	response.Rows = records[request.Offset:endRow]
+	response.RowCount = len(response.Rows)
+
+	return response, nil
+}
+
+// getJSONInfo gets column and row info for JSON files
+func (h *DataBrowserHandler) getJSONInfo(ctx context.Context, fileName string) ([]string, int64, error) {
+	reader, _, err := h.minioClient.DownloadFileCached(ctx, fileName, "")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err = decodeData(fileName, data, true)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	columns, rows, err := parseJSONRecords(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return columns, int64(len(rows)), nil
+}
@@ -0,0 +1,230 @@
+package data_browser
+
+import (
+	"database/sql"
+	"log"
+)
+
+// MDBMetadata describes the structure of an Access database beyond its
+// tables, so its relational design can be understood before exporting.
+type MDBMetadata struct {
+	// Queries are the saved select, crosstab and union queries. They are
+	// listed after the tables in Sheets and can be browsed like tables;
+	// action queries are left out, as running them would change the file.
+	Queries       []string       `json:"queries"`
+	Relationships []Relationship `json:"relationships"`
+	Indexes       []Index        `json:"indexes"`
+}
+
+// Relationship links columns of Table to the columns of ReferencedTable
+// they refer to, in the same order.
+type Relationship struct {
+	Name              string   `json:"name"`
+	Table             string   `json:"table"`
+	Columns           []string `json:"columns"`
+	ReferencedTable   string   `json:"referenced_table"`
+	ReferencedColumns []string `json:"referenced_columns"`
+	OneToOne          bool     `json:"one_to_one"`
+	EnforceIntegrity  bool     `json:"enforce_integrity"`
+	CascadeUpdates    bool     `json:"cascade_updates"`
+	CascadeDeletes    bool     `json:"cascade_deletes"`
+}
+
+// Index is an index of a table, over its columns in order.
+type Index struct {
+	Table   string   `json:"table"`
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Primary bool     `json:"primary"`
+	Unique  bool     `json:"unique"`
+}
+
+// Flags of MSysRelationships.grbit.
+const (
+	relationshipOneToOne      = 0x1
+	relationshipNoIntegrity   = 0x2
+	relationshipCascadeUpdate = 0x100
+	relationshipCascadeDelete = 0x1000
+)
+
+// getMDBMetadata reads the saved queries, relationships and indexes of db.
+// Each is read on its own: a driver that hides the system tables or schema
+// views leaves that part empty rather than failing the browse.
+func (h *DataBrowserHandler) getMDBMetadata(db *sql.DB) *MDBMetadata {
+	metadata := &MDBMetadata{}
+	var err error
+	if metadata.Queries, err = h.getMDBQueries(db); err != nil {
+		log.Printf("Failed to read MDB queries: %v", err)
+	}
+	if metadata.Relationships, err = h.getMDBRelationships(db); err != nil {
+		log.Printf("Failed to read MDB relationships: %v", err)
+	}
+	if metadata.Indexes, err = h.getMDBIndexes(db); err != nil {
+		log.Printf("Failed to read MDB indexes: %v", err)
+	}
+	return metadata
+}
+
+// getMDBQueries retrieves the names of the saved queries that return rows
+func (h *DataBrowserHandler) getMDBQueries(db *sql.DB) ([]string, error) {
+	query := `
+		SELECT TABLE_NAME
+		FROM INFORMATION_SCHEMA.VIEWS
+		ORDER BY TABLE_NAME
+	`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		// Type 5 is a query; flags 0, 16 and 128 are select, crosstab and
+		// union queries. Names starting with ~ are Access's own.
+		queryAlt := `
+			SELECT Name
+			FROM MSysObjects
+			WHERE Type=5 AND Flags IN (0, 16, 128) AND Left(Name, 1) <> '~'
+			ORDER BY Name
+		`
+		rows, err = db.Query(queryAlt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer rows.Close()
+
+	var queries []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			continue
+		}
+		queries = append(queries, name)
+	}
+
+	return queries, rows.Err()
+}
+
+// relationshipColumn is one column pair of a relationship, as stored in
+// MSysRelationships.
+type relationshipColumn struct {
+	name             string
+	table            string
+	column           string
+	referencedTable  string
+	referencedColumn string
+	flags            int64
+}
+
+// getMDBRelationships retrieves the relationships between tables
+func (h *DataBrowserHandler) getMDBRelationships(db *sql.DB) ([]Relationship, error) {
+	query := `
+		SELECT szRelationship, szObject, szColumn, szReferencedObject, szReferencedColumn, grbit
+		FROM MSysRelationships
+		ORDER BY szRelationship, icolumn
+	`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []relationshipColumn
+	for rows.Next() {
+		var c relationshipColumn
+		if err := rows.Scan(&c.name, &c.table, &c.column, &c.referencedTable, &c.referencedColumn, &c.flags); err != nil {
+			continue
+		}
+		columns = append(columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return groupRelationships(columns), nil
+}
+
+// groupRelationships joins the column pairs of each relationship, given in
+// order, into one Relationship.
+func groupRelationships(columns []relationshipColumn) []Relationship {
+	var relationships []Relationship
+	for _, c := range columns {
+		if n := len(relationships); n > 0 && relationships[n-1].Name == c.name {
+			last := &relationships[n-1]
+			last.Columns = append(last.Columns, c.column)
+			last.ReferencedColumns = append(last.ReferencedColumns, c.referencedColumn)
+			continue
+		}
+		relationships = append(relationships, Relationship{
+			Name:              c.name,
+			Table:             c.table,
+			Columns:           []string{c.column},
+			ReferencedTable:   c.referencedTable,
+			ReferencedColumns: []string{c.referencedColumn},
+			OneToOne:          c.flags&relationshipOneToOne != 0,
+			EnforceIntegrity:  c.flags&relationshipNoIntegrity == 0,
+			CascadeUpdates:    c.flags&relationshipCascadeUpdate != 0,
+			CascadeDeletes:    c.flags&relationshipCascadeDelete != 0,
+		})
+	}
+	return relationships
+}
+
+// indexColumn is one column of an index, as listed in the INDEXES schema
+// view.
+type indexColumn struct {
+	table   string
+	name    string
+	column  string
+	primary bool
+	unique  bool
+}
+
+// getMDBIndexes retrieves the indexes of every table. Access keeps them out
+// of its system tables, so they are only available from drivers offering
+// the INDEXES schema view.
+func (h *DataBrowserHandler) getMDBIndexes(db *sql.DB) ([]Index, error) {
+	query := `
+		SELECT TABLE_NAME, INDEX_NAME, COLUMN_NAME, PRIMARY_KEY, [UNIQUE]
+		FROM INFORMATION_SCHEMA.INDEXES
+		ORDER BY TABLE_NAME, INDEX_NAME, ORDINAL_POSITION
+	`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []indexColumn
+	for rows.Next() {
+		var c indexColumn
+		if err := rows.Scan(&c.table, &c.name, &c.column, &c.primary, &c.unique); err != nil {
+			continue
+		}
+		columns = append(columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return groupIndexes(columns), nil
+}
+
+// groupIndexes joins the columns of each index, given in order, into one
+// Index.
+func groupIndexes(columns []indexColumn) []Index {
+	var indexes []Index
+	for _, c := range columns {
+		if n := len(indexes); n > 0 && indexes[n-1].Table == c.table && indexes[n-1].Name == c.name {
+			indexes[n-1].Columns = append(indexes[n-1].Columns, c.column)
+			continue
+		}
+		indexes = append(indexes, Index{
+			Table:   c.table,
+			Name:    c.name,
+			Columns: []string{c.column},
+			Primary: c.primary,
+			Unique:  c.unique || c.primary,
+		})
+	}
+	return indexes
+}
@@ -0,0 +1,38 @@
+package data_browser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupRelationships(t *testing.T) {
+	got := groupRelationships([]relationshipColumn{
+		{name: "CustomersOrders", table: "Orders", column: "CustomerID", referencedTable: "Customers", referencedColumn: "ID", flags: relationshipCascadeDelete},
+		{name: "OrderLinesOrders", table: "OrderLines", column: "OrderID", referencedTable: "Orders", referencedColumn: "ID", flags: relationshipNoIntegrity},
+		{name: "OrderLinesOrders", table: "OrderLines", column: "Region", referencedTable: "Orders", referencedColumn: "Region", flags: relationshipNoIntegrity},
+	})
+	want := []Relationship{
+		{Name: "CustomersOrders", Table: "Orders", Columns: []string{"CustomerID"}, ReferencedTable: "Customers", ReferencedColumns: []string{"ID"}, EnforceIntegrity: true, CascadeDeletes: true},
+		{Name: "OrderLinesOrders", Table: "OrderLines", Columns: []string{"OrderID", "Region"}, ReferencedTable: "Orders", ReferencedColumns: []string{"ID", "Region"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groupRelationships() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGroupIndexes(t *testing.T) {
+	got := groupIndexes([]indexColumn{
+		{table: "Customers", name: "PrimaryKey", column: "ID", primary: true},
+		{table: "Orders", name: "ByCustomer", column: "CustomerID"},
+		{table: "Orders", name: "ByCustomer", column: "OrderDate"},
+		{table: "Orders", name: "PrimaryKey", column: "ID", primary: true},
+	})
+	want := []Index{
+		{Table: "Customers", Name: "PrimaryKey", Columns: []string{"ID"}, Primary: true, Unique: true},
+		{Table: "Orders", Name: "ByCustomer", Columns: []string{"CustomerID", "OrderDate"}},
+		{Table: "Orders", Name: "PrimaryKey", Columns: []string{"ID"}, Primary: true, Unique: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groupIndexes() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,279 @@
+package data_browser
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// What a value that does not normalize becomes
+const (
+	NormalizeErrorKeep = "keep" // It stays as it was read
+	NormalizeErrorNull = "null"
+)
+
+// Normalization reads the values of columns as amounts, dropping currency
+// symbols, unit names and thousand separators, and optionally converting
+// them to one unit or currency.
+type Normalization struct {
+	Columns []string `json:"columns"`
+	// DecimalSeparator is "." (default) or ","; the other one, spaces,
+	// apostrophes and underscores separate thousands
+	DecimalSeparator string `json:"decimal_separator,omitempty"`
+	// Unit is the unit or currency of the values naming none
+	Unit string `json:"unit,omitempty"`
+	// TargetUnit converts the values to this unit or currency
+	TargetUnit string `json:"target_unit,omitempty"`
+	// Rates add to and override CONVERSION_RATES, e.g. {"EUR/USD": 1.08}
+	Rates   map[string]float64 `json:"rates,omitempty"`
+	OnError string             `json:"on_error,omitempty"` // NormalizeErrorKeep (default) or NormalizeErrorNull
+}
+
+// currencySymbols maps the currency symbols values are written with to
+// their ISO 4217 codes.
+var currencySymbols = map[string]string{
+	"$":   "USD",
+	"US$": "USD",
+	"€":   "EUR",
+	"£":   "GBP",
+	"¥":   "JPY",
+	"₹":   "INR",
+	"₩":   "KRW",
+	"₽":   "RUB",
+	"₺":   "TRY",
+	"₫":   "VND",
+	"฿":   "THB",
+	"₱":   "PHP",
+	"R$":  "BRL",
+	"Rp":  "IDR",
+	"RM":  "MYR",
+	"S$":  "SGD",
+	"A$":  "AUD",
+	"C$":  "CAD",
+	"HK$": "HKD",
+}
+
+// validate checks n and returns its rates over defaults, keyed by their
+// upper-cased "FROM/TO" pair.
+func (n Normalization) validate(defaults map[string]float64) (map[string]float64, error) {
+	if len(n.Columns) == 0 {
+		return nil, fmt.Errorf("normalization without columns")
+	}
+	switch n.DecimalSeparator {
+	case "", ".", ",":
+	default:
+		return nil, fmt.Errorf("invalid decimal_separator %q, use . or ,", n.DecimalSeparator)
+	}
+	switch n.OnError {
+	case "", NormalizeErrorKeep, NormalizeErrorNull:
+	default:
+		return nil, fmt.Errorf("invalid on_error %q, use keep or null", n.OnError)
+	}
+	if len(n.Rates) == 0 {
+		return defaults, nil
+	}
+	rates := make(map[string]float64, len(defaults)+len(n.Rates))
+	for pair, rate := range defaults {
+		rates[pair] = rate
+	}
+	for pair, rate := range n.Rates {
+		from, to, ok := strings.Cut(pair, "/")
+		if !ok || strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" {
+			return nil, fmt.Errorf("invalid rate %q, want FROM/TO", pair)
+		}
+		if !(rate > 0) || math.IsInf(rate, 0) {
+			return nil, fmt.Errorf("rate %q must be a positive number", pair)
+		}
+		rates[strings.ToUpper(strings.TrimSpace(from)+"/"+strings.TrimSpace(to))] = rate
+	}
+	return rates, nil
+}
+
+// normalize returns value as a plain number, in TargetUnit when set.
+func (n Normalization) normalize(value string, rates map[string]float64) (string, error) {
+	decimal := '.'
+	if n.DecimalSeparator == "," {
+		decimal = ','
+	}
+	amount, unit, err := parseAmount(value, decimal)
+	if err != nil {
+		return "", err
+	}
+	if n.TargetUnit != "" {
+		if unit == "" {
+			unit = n.Unit
+		}
+		if unit == "" {
+			return "", fmt.Errorf("%q names no unit to convert from", value)
+		}
+		rate, ok := conversionRate(rates, unit, n.TargetUnit)
+		if !ok {
+			return "", fmt.Errorf("no rate converts %s to %s", unit, n.TargetUnit)
+		}
+		amount *= rate
+	}
+	return formatAmount(amount), nil
+}
+
+// applyNormalizations normalizes the columns of response in place, over
+// the default rates. Columns a file lacks are left out.
+func applyNormalizations(response *BrowseResponse, normalizations []Normalization, defaults map[string]float64) error {
+	for _, n := range normalizations {
+		rates, err := n.validate(defaults)
+		if err != nil {
+			return err
+		}
+		for _, column := range n.Columns {
+			i := columnIndex(response.Columns, column)
+			if i < 0 {
+				continue
+			}
+			for _, row := range response.Rows {
+				if i >= len(row) || strings.TrimSpace(row[i]) == "" {
+					continue
+				}
+				normalized, err := n.normalize(row[i], rates)
+				switch {
+				case err == nil:
+					row[i] = normalized
+				case n.OnError == NormalizeErrorNull:
+					row[i] = ""
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// thousandSeparators separate thousands whatever the decimal separator.
+const thousandSeparators = " '_’\u00a0\u202f"
+
+// parseAmount reads an amount such as "$1,234.50", "-12 kg", "Rp 1.500.000"
+// (with decimal ',') or "(1,000.00)", an accounting negative, returning its
+// number and the unit or currency it names, if any. Currency symbols are
+// returned as ISO codes.
+func parseAmount(value string, decimal rune) (float64, string, error) {
+	s := strings.TrimSpace(value)
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative, s = true, strings.TrimSpace(s[1:len(s)-1])
+	}
+
+	first := strings.IndexFunc(s, unicode.IsDigit)
+	last := strings.LastIndexFunc(s, unicode.IsDigit)
+	if first < 0 {
+		return 0, "", fmt.Errorf("%q holds no number", value)
+	}
+	prefix, number, suffix := s[:first], s[first:last+1], s[last+1:]
+	if strings.HasSuffix(prefix, string(decimal)) {
+		prefix, number = strings.TrimSuffix(prefix, string(decimal)), string(decimal)+number
+	}
+
+	// Signs go on either side of a unit: -$12, $-12, 12-
+	prefix, minus := cutSign(strings.TrimSpace(prefix))
+	negative = negative != minus
+	suffix, minus = cutSign(strings.TrimSpace(suffix))
+	negative = negative != minus
+	if prefix != "" && suffix != "" {
+		return 0, "", fmt.Errorf("%q names two units", value)
+	}
+	unit := prefix + suffix
+	if code, ok := currencySymbols[unit]; ok {
+		unit = code
+	}
+
+	amount, err := parseNumber(number, decimal)
+	if err != nil {
+		return 0, "", fmt.Errorf("%q is not an amount", value)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, unit, nil
+}
+
+// cutSign removes a sign from either end of s, reporting whether it was a
+// minus.
+func cutSign(s string) (string, bool) {
+	for _, sign := range []string{"-", "−", "+"} {
+		rest, ok := strings.CutSuffix(s, sign)
+		if !ok {
+			rest, ok = strings.CutPrefix(s, sign)
+		}
+		if ok {
+			return strings.TrimSpace(rest), sign != "+"
+		}
+	}
+	return s, false
+}
+
+// parseNumber reads digits with thousand separators and at most one
+// decimal separator, or a number in exponent notation.
+func parseNumber(number string, decimal rune) (float64, error) {
+	if strings.ContainsAny(number, "eE") {
+		return strconv.ParseFloat(number, 64)
+	}
+	var b strings.Builder
+	for _, r := range number {
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == decimal:
+			b.WriteByte('.')
+		case (r == '.' || r == ',') || strings.ContainsRune(thousandSeparators, r):
+			// A thousand separator
+		default:
+			return 0, fmt.Errorf("unexpected %q", r)
+		}
+	}
+	return strconv.ParseFloat(b.String(), 64)
+}
+
+// formatAmount writes amount without float noise from a conversion.
+func formatAmount(amount float64) string {
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(amount, 'g', 15, 64), 64)
+	if err != nil {
+		rounded = amount
+	}
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
+
+// conversionRate returns how many to make one from, from a rate between
+// them either way or through one unit both have a rate with.
+func conversionRate(rates map[string]float64, from, to string) (float64, bool) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return 1, true
+	}
+	direct := func(from, to string) (float64, bool) {
+		if rate, ok := rates[from+"/"+to]; ok {
+			return rate, true
+		}
+		if rate, ok := rates[to+"/"+from]; ok {
+			return 1 / rate, true
+		}
+		return 0, false
+	}
+	if rate, ok := direct(from, to); ok {
+		return rate, true
+	}
+
+	// Sorted, so rates that disagree convert the same way every time
+	units := make([]string, 0, 2*len(rates))
+	for pair := range rates {
+		a, b, _ := strings.Cut(pair, "/")
+		units = append(units, a, b)
+	}
+	sort.Strings(units)
+	for _, via := range units {
+		if first, ok := direct(from, via); ok {
+			if second, ok := direct(via, to); ok {
+				return first * second, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,117 @@
+package data_browser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	cases := []struct {
+		value   string
+		decimal rune
+		amount  float64
+		unit    string
+	}{
+		{"$1,234.50", '.', 1234.5, "USD"},
+		{"-$12", '.', -12, "USD"},
+		{"$-12", '.', -12, "USD"},
+		{"(1,000.00)", '.', -1000, ""},
+		{"12.5-", '.', -12.5, ""},
+		{"Rp 1.500.000", ',', 1500000, "IDR"},
+		{"1.234,5 €", ',', 1234.5, "EUR"},
+		{"12 kg", '.', 12, "kg"},
+		{"1 000 000", '.', 1000000, ""},
+		{"CHF 1'250.75", '.', 1250.75, "CHF"},
+		{".5", '.', 0.5, ""},
+		{"1.5e3", '.', 1500, ""},
+	}
+	for _, c := range cases {
+		amount, unit, err := parseAmount(c.value, c.decimal)
+		if err != nil || amount != c.amount || unit != c.unit {
+			t.Errorf("parseAmount(%q) = %v, %q, %v; want %v, %q", c.value, amount, unit, err, c.amount, c.unit)
+		}
+	}
+
+	for _, bad := range []string{"N/A", "", "USD 12 EUR", "2024-01-05", "1.2.3"} {
+		if _, _, err := parseAmount(bad, '.'); err == nil {
+			t.Errorf("parseAmount(%q) was accepted", bad)
+		}
+	}
+}
+
+func TestConversionRate(t *testing.T) {
+	rates := map[string]float64{"EUR/USD": 1.25, "USD/IDR": 16000, "KM/M": 1000}
+	cases := []struct {
+		from, to string
+		rate     float64
+	}{
+		{"eur", "usd", 1.25},
+		{"USD", "EUR", 0.8},
+		{"EUR", "IDR", 20000},
+		{"m", "km", 0.001},
+		{"kg", "KG", 1},
+	}
+	for _, c := range cases {
+		if rate, ok := conversionRate(rates, c.from, c.to); !ok || rate != c.rate {
+			t.Errorf("conversionRate(%s, %s) = %v, %v; want %v", c.from, c.to, rate, ok, c.rate)
+		}
+	}
+	if _, ok := conversionRate(rates, "EUR", "KM"); ok {
+		t.Error("EUR converted to KM")
+	}
+}
+
+func TestApplyNormalizations(t *testing.T) {
+	response := &BrowseResponse{
+		Columns: []string{"item", "price", "weight"},
+		Rows: [][]string{
+			{"a", "$10", "1,5 kg"},
+			{"b", "€ 2.00", "500 g"},
+			{"c", "12", "n/a"},
+			{"d", "", "2"},
+		},
+	}
+	normalizations := []Normalization{
+		{Columns: []string{"Price"}, Unit: "USD", TargetUnit: "USD", Rates: map[string]float64{"eur/usd": 1.08}},
+		{Columns: []string{"weight", "missing"}, DecimalSeparator: ",", TargetUnit: "kg", Rates: map[string]float64{"kg/g": 1000}, OnError: NormalizeErrorNull},
+	}
+	if err := applyNormalizations(response, normalizations, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"a", "10", "1.5"},
+		{"b", "2.16", "0.5"},
+		{"c", "12", ""},
+		{"d", "", ""},
+	}
+	if !reflect.DeepEqual(response.Rows, want) {
+		t.Errorf("rows = %v, want %v", response.Rows, want)
+	}
+
+	kept := &BrowseResponse{Columns: []string{"price"}, Rows: [][]string{{"about 10 or so"}, {"£3"}}}
+	if err := applyNormalizations(kept, []Normalization{{Columns: []string{"price"}}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(kept.Rows, [][]string{{"about 10 or so"}, {"3"}}) {
+		t.Errorf("rows = %v", kept.Rows)
+	}
+
+	for _, bad := range []Normalization{
+		{},
+		{Columns: []string{"price"}, DecimalSeparator: ";"},
+		{Columns: []string{"price"}, OnError: "drop"},
+		{Columns: []string{"price"}, Rates: map[string]float64{"EUR": 1}},
+		{Columns: []string{"price"}, Rates: map[string]float64{"EUR/USD": 0}},
+	} {
+		if err := applyNormalizations(response, []Normalization{bad}, nil); err == nil {
+			t.Errorf("normalization %+v was accepted", bad)
+		}
+	}
+}
+
+func TestColumnMapperConvertsAmounts(t *testing.T) {
+	mapper := &ColumnMapper{}
+	if value, err := mapper.convertValue("$1,234.50", "total_amount"); err != nil || value != 1234.5 {
+		t.Errorf("convertValue = %v, %v", value, err)
+	}
+}
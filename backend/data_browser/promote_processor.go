@@ -0,0 +1,365 @@
+package data_browser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"bronze-backend/config"
+	"bronze-backend/jobs"
+	"bronze-backend/storage"
+	"bronze-backend/tenant"
+)
+
+// ArtifactLineage is the artifact of a promote job recording where the
+// rows it wrote came from.
+const ArtifactLineage = "lineage.json"
+
+// PromoteProcessor runs "promote" jobs: it reads a bronze table, or a file,
+// applies a stored transform set and writes the rows to a silver table in
+// NESSIE_SILVER_NAMESPACE, with the lineage of the rows. The rows are
+// written in one commit, and nothing is written unless every row
+// transforms and the set's validation suite passes.
+//
+// Job metadata:
+//   - transform_set:   name of the set under transforms/sets/ (required)
+//   - source_table:    bronze table to read; without it the job's object is
+//     read, as in convert jobs
+//   - source_database: database of source_table (default NESSIE_DEFAULT_DB)
+//   - table:           silver table to write (default source_table)
+//   - database:        database of the silver table (default NESSIE_DEFAULT_DB)
+//   - operation:       create, or append (default), which creates a missing
+//     table
+//   - sheet_name, has_headers and treat_as_csv: for files, as in convert jobs
+type PromoteProcessor struct {
+	browser     *DataBrowserHandler
+	minioClient *storage.MinIOClient
+	config      *config.Config
+	nessie      lazyNessie
+}
+
+func NewPromoteProcessor(cfg *config.Config, minioClient *storage.MinIOClient) *PromoteProcessor {
+	return &PromoteProcessor{
+		browser:     NewDataBrowserHandler(minioClient),
+		minioClient: minioClient,
+		config:      cfg,
+		nessie:      lazyNessie{config: &cfg.Nessie},
+	}
+}
+
+// LineageRef is a table as of a commit, or an object.
+type LineageRef struct {
+	Namespace string `json:"namespace,omitempty"`
+	Database  string `json:"database,omitempty"`
+	Table     string `json:"table,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+	Bucket    string `json:"bucket,omitempty"`
+	Object    string `json:"object,omitempty"`
+}
+
+func (r LineageRef) String() string {
+	if r.Table == "" {
+		return r.Bucket + "/" + r.Object
+	}
+	return r.Namespace + "." + r.Database + "." + r.Table
+}
+
+// PromotionLineage records how a promote job made the rows it wrote.
+type PromotionLineage struct {
+	Source       LineageRef     `json:"source"`
+	Target       LineageRef     `json:"target"`
+	TransformSet string         `json:"transform_set"`
+	SetUpdatedAt time.Time      `json:"transform_set_updated_at,omitempty"` // The version of the set applied
+	Stats        TransformStats `json:"stats"`
+	JobID        string         `json:"job_id"`
+	Subject      string         `json:"subject,omitempty"`
+	PromotedAt   time.Time      `json:"promoted_at"`
+}
+
+// lazyNessie is the Nessie client of a job processor, connected by its
+// first job, so workers that started while Nessie was down use it once it
+// is back.
+type lazyNessie struct {
+	config *config.NessieConfig
+	client atomic.Pointer[storage.NessieClient]
+}
+
+func (l *lazyNessie) get() (*storage.NessieClient, error) {
+	if client := l.client.Load(); client != nil {
+		return client, nil
+	}
+	client, err := storage.NewNessieClient(l.config)
+	if err != nil {
+		return nil, err
+	}
+	l.client.CompareAndSwap(nil, client)
+	return l.client.Load(), nil
+}
+
+// silverNamespace returns the namespace promoted tables are written to: a
+// child of the tenant's namespace for a tenant's jobs.
+func silverNamespace(ctx context.Context, silver string) string {
+	if t, ok := tenant.FromContext(ctx); ok {
+		return t.Namespace + "." + silver
+	}
+	return silver
+}
+
+func (pp *PromoteProcessor) ProcessJob(ctx context.Context, job *jobs.Job) jobs.JobResult {
+	startTime := time.Now()
+
+	fail := func(format string, args ...any) jobs.JobResult {
+		return jobs.JobResult{
+			Success:        false,
+			ProcessingTime: time.Since(startTime),
+			Message:        fmt.Sprintf(format, args...),
+		}
+	}
+
+	if pp.minioClient == nil {
+		return fail("MinIO client not available")
+	}
+
+	setName, _ := job.Metadata["transform_set"].(string)
+	if setName == "" {
+		return fail("transform set is required (metadata.transform_set)")
+	}
+	set, err := pp.browser.LoadTransformSet(ctx, setName)
+	if err != nil {
+		return fail("Failed to load transform set: %v", err)
+	}
+	if err := set.Validate(); err != nil {
+		return fail("Invalid transform set: %v", err)
+	}
+
+	nessieClient, err := pp.nessie.get()
+	if err != nil {
+		return fail("Nessie is not available: %v", err)
+	}
+
+	sourceTable, _ := job.Metadata["source_table"].(string)
+	sourceDatabase, _ := job.Metadata["source_database"].(string)
+	if sourceDatabase == "" {
+		sourceDatabase = pp.config.Nessie.DefaultDB
+	}
+	table, _ := job.Metadata["table"].(string)
+	if table == "" {
+		table = sourceTable
+	}
+	if table == "" {
+		return fail("silver table is required (metadata.table)")
+	}
+	database, _ := job.Metadata["database"].(string)
+	if database == "" {
+		database = pp.config.Nessie.DefaultDB
+	}
+	operation, _ := job.Metadata["operation"].(string)
+	if operation == "" {
+		operation = "append"
+	}
+	if operation != "create" && operation != "append" {
+		return fail("unsupported operation %q, use create or append", operation)
+	}
+
+	var result ProcessingResult
+	var source LineageRef
+	if sourceTable != "" {
+		log.Printf("Promoting table %s.%s with transform set %s for job %s", sourceDatabase, sourceTable, set.Name, job.ID)
+		result, source, err = readNessieTable(ctx, nessieClient, sourceDatabase, sourceTable)
+	} else {
+		log.Printf("Promoting %s with transform set %s for job %s", job.ObjectName, set.Name, job.ID)
+		result, source, err = pp.readFile(ctx, job)
+	}
+	if err != nil {
+		return fail("Failed to read source data: %v", err)
+	}
+
+	job.UpdateProgress(40)
+
+	stats, columnTypes, err := set.apply(&result)
+	if err != nil {
+		return fail("Failed to transform %s: %v", source, err)
+	}
+
+	if set.ValidationSuite != "" {
+		suite, err := pp.browser.LoadValidationSuite(ctx, set.ValidationSuite)
+		if err != nil {
+			return fail("Failed to load validation suite: %v", err)
+		}
+		if err := suite.Validate(); err != nil {
+			return fail("Invalid validation suite: %v", err)
+		}
+		if err := loadReferences(ctx, nessieClient, suite.Rules, pp.config.Nessie.DefaultDB); err != nil {
+			return fail("Failed to read referenced table: %v", err)
+		}
+		report := RunValidationSuite(suite, result.Columns, result.Rows)
+		report.FileName = source.String()
+		if err := jobs.SaveArtifact(ctx, pp.minioClient, job, "validation_report.json", report); err != nil {
+			log.Printf("Warning: Failed to upload validation report for job %s: %v", job.ID, err)
+		}
+		if !report.Passed {
+			return fail("Validation failed, nothing promoted: %d of %d rules failed (%d warnings)", report.Errors, len(report.Rules), report.Warnings)
+		}
+	}
+
+	job.UpdateProgress(60)
+
+	target := LineageRef{Namespace: silverNamespace(ctx, pp.config.Nessie.SilverNamespace), Database: database, Table: table}
+	silver := nessieClient.WithNamespace(target.Namespace)
+	exists, err := silver.TableExists(ctx, database, table)
+	if err != nil {
+		return fail("Failed to check silver table: %v", err)
+	}
+
+	if operation == "create" || !exists {
+		nessieTable := &storage.NessieTable{
+			Name:     table,
+			Database: database,
+			Columns:  make([]storage.NessieColumn, len(result.Columns)),
+			Properties: map[string]interface{}{
+				"description":   fmt.Sprintf("Promoted from %s", source),
+				"promoted_from": source.String(),
+				"transform_set": set.Name,
+				"created_at":    time.Now(),
+			},
+		}
+		for i, column := range result.Columns {
+			nessieTable.Columns[i] = storage.NessieColumn{Name: column, Type: columnTypes[i], Nullable: true}
+		}
+		if job.Subject != "" {
+			nessieTable.Properties["created_by"] = job.Subject
+		}
+		if err := silver.CreateTable(ctx, nessieTable); err != nil {
+			return fail("Failed to create silver table: %v", err)
+		}
+	} else {
+		existing, err := silver.GetTableSchema(ctx, database, table)
+		if err != nil {
+			return fail("Failed to get silver table schema: %v", err)
+		}
+		for _, mismatch := range silver.ValidateSchema(result.Columns, existing) {
+			if mismatch.MismatchType == "extra" {
+				return fail("Column %s is not in silver table %s", mismatch.ColumnName, target)
+			}
+		}
+	}
+
+	job.UpdateProgress(70)
+
+	export := &stagedExport{
+		writer:       silver,
+		database:     database,
+		table:        table,
+		stageID:      job.ID,
+		batchSize:    pp.config.Nessie.BatchSize,
+		createdTable: !exists, // Not one that existed before a "create"
+	}
+	outcome, err := export.write(ctx, []ProcessingResult{result}, true, true)
+	if err != nil {
+		return fail("Failed to write silver table, promotion rolled back: %v", err)
+	}
+	target.Commit = outcome.commitID
+
+	lineage := PromotionLineage{
+		Source:       source,
+		Target:       target,
+		TransformSet: set.Name,
+		SetUpdatedAt: set.UpdatedAt,
+		Stats:        stats,
+		JobID:        job.ID,
+		Subject:      job.Subject,
+		PromotedAt:   time.Now(),
+	}
+	if err := jobs.SaveArtifact(ctx, pp.minioClient, job, ArtifactLineage, lineage); err != nil {
+		log.Printf("Warning: Failed to upload lineage for job %s: %v", job.ID, err)
+	}
+
+	return jobs.JobResult{
+		Success:        true,
+		ProcessingTime: time.Since(startTime),
+		Message:        fmt.Sprintf("Promoted %d of %d rows from %s to %s", stats.RowsWritten, stats.RowsRead, source, target),
+		Result:         lineage,
+	}
+}
+
+// readNessieTable reads a table in its column order, values as text.
+func readNessieTable(ctx context.Context, nessieClient *storage.NessieClient, database, table string) (ProcessingResult, LineageRef, error) {
+	source := LineageRef{Namespace: nessieClient.Namespace(ctx), Database: database, Table: table}
+
+	schema, err := nessieClient.GetTableSchema(ctx, database, table)
+	if err != nil {
+		return ProcessingResult{}, source, err
+	}
+	if schema == nil {
+		return ProcessingResult{}, source, fmt.Errorf("table %s not found", source)
+	}
+	data, err := nessieClient.ReadTable(ctx, database, table)
+	if err != nil {
+		return ProcessingResult{}, source, err
+	}
+	source.Commit = data.Hash
+
+	result := ProcessingResult{
+		FileName: source.String(),
+		Columns:  make([]string, len(schema.Columns)),
+		Rows:     make([][]string, len(data.Rows)),
+		RowCount: len(data.Rows),
+		Success:  true,
+	}
+	for i, column := range schema.Columns {
+		result.Columns[i] = column.Name
+	}
+	for r, values := range data.Rows {
+		row := make([]string, len(result.Columns))
+		for i, column := range result.Columns {
+			row[i] = jsonValueString(values[column])
+		}
+		result.Rows[r] = row
+	}
+	return result, source, nil
+}
+
+// readFile reads the job's object with the data browser readers.
+func (pp *PromoteProcessor) readFile(ctx context.Context, job *jobs.Job) (ProcessingResult, LineageRef, error) {
+	source := LineageRef{Bucket: pp.minioClient.Bucket(ctx), Object: job.ObjectName}
+
+	request := BrowseRequest{
+		FileName:   job.ObjectName,
+		MaxRows:    fullReadRows,
+		HasHeaders: true,
+	}
+	if sheet, ok := job.Metadata["sheet_name"].(string); ok {
+		request.SheetName = sheet
+	}
+	if hasHeaders, ok := job.Metadata["has_headers"].(bool); ok {
+		request.HasHeaders = hasHeaders
+	}
+	if treatAsCSV, ok := job.Metadata["treat_as_csv"].(bool); ok {
+		request.TreatAsCSV = treatAsCSV
+	}
+
+	reader, _, err := pp.minioClient.DownloadFileCached(ctx, job.ObjectName, "")
+	if err != nil {
+		return ProcessingResult{}, source, err
+	}
+	data, err := jobs.ReadAll(ctx, reader)
+	reader.Close()
+	if err != nil {
+		return ProcessingResult{}, source, err
+	}
+
+	parsed, err := pp.browser.readData(data, request)
+	if err != nil {
+		return ProcessingResult{}, source, err
+	}
+	return ProcessingResult{
+		FileName:  job.ObjectName,
+		SheetName: parsed.SheetName,
+		Columns:   parsed.Columns,
+		Rows:      parsed.Rows,
+		RowCount:  len(parsed.Rows),
+		Success:   true,
+	}, source, nil
+}
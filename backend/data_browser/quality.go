@@ -0,0 +1,345 @@
+package data_browser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/minio/minio-go/v7"
+
+	"bronze-backend/httputil"
+	"bronze-backend/tenant"
+)
+
+// Quality suites are stored in MinIO, one per table at
+// quality/suites/{database}/{table}.json below the tenant's prefix, and the
+// result of the last quality job of each table at
+// quality/results/{database}/{table}.json.
+const (
+	QualitySuitePrefix  = "quality/suites/"
+	QualityResultPrefix = "quality/results/"
+)
+
+// What a failing quality suite does
+const (
+	QualityPolicyWarn       = "warn"        // Nothing beyond failing its job
+	QualityPolicyBlockMerge = "block_merge" // Branch merges are refused until it passes
+)
+
+// QualitySuite is the validation rules of an exported table, checked by
+// quality jobs against the whole table: row counts, null ratios,
+// uniqueness and references to other tables.
+type QualitySuite struct {
+	Database    string           `json:"database"`
+	Table       string           `json:"table"`
+	Description string           `json:"description,omitempty"`
+	Rules       []ValidationRule `json:"rules"`
+	Policy      string           `json:"policy,omitempty"` // QualityPolicyWarn (default) or QualityPolicyBlockMerge
+	UpdatedAt   time.Time        `json:"updated_at,omitempty"`
+}
+
+// validationSuite returns the suite's rules as a validation suite named
+// after its table.
+func (s *QualitySuite) validationSuite() ValidationSuite {
+	return ValidationSuite{
+		Name:        s.Database + "." + s.Table,
+		Description: s.Description,
+		Rules:       s.Rules,
+		UpdatedAt:   s.UpdatedAt,
+	}
+}
+
+// Validate checks the suite before it is stored or run.
+func (s *QualitySuite) Validate() error {
+	if s.Database == "" || s.Table == "" {
+		return fmt.Errorf("database and table are required")
+	}
+	if strings.ContainsAny(s.Database+s.Table, "/\\") {
+		return fmt.Errorf("database and table must not contain path separators")
+	}
+	switch s.Policy {
+	case "", QualityPolicyWarn, QualityPolicyBlockMerge:
+	default:
+		return fmt.Errorf("invalid policy %q, use warn or block_merge", s.Policy)
+	}
+	suite := s.validationSuite()
+	return suite.Validate()
+}
+
+// QualityResult is the outcome of a table's last quality job.
+type QualityResult struct {
+	Database string           `json:"database"`
+	Table    string           `json:"table"`
+	Commit   string           `json:"commit,omitempty"` // The commit of the table checked
+	Policy   string           `json:"policy"`
+	JobID    string           `json:"job_id"`
+	Report   ValidationReport `json:"report"`
+}
+
+// BlocksMerge reports whether the result keeps branches from being merged.
+func (r *QualityResult) BlocksMerge() bool {
+	return r.Policy == QualityPolicyBlockMerge && !r.Report.Passed
+}
+
+// qualitySuiteObject returns the object of the suite of a table. Each tenant
+// keeps its suites below its own prefix.
+func qualitySuiteObject(ctx context.Context, database, table string) string {
+	return tenant.Prefix(ctx) + QualitySuitePrefix + database + "/" + table + ".json"
+}
+
+// qualityResultObject returns the object of the last result of a table.
+func qualityResultObject(ctx context.Context, database, table string) string {
+	return tenant.Prefix(ctx) + QualityResultPrefix + database + "/" + table + ".json"
+}
+
+// readJSON decodes the object key into v.
+func (h *DataBrowserHandler) readJSON(ctx context.Context, key string, v any) error {
+	reader, err := h.minioClient.DownloadFile(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// storeJSON stores v as the object key.
+func (h *DataBrowserHandler) storeJSON(ctx context.Context, key string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = h.minioClient.UploadFile(ctx, key, bytes.NewReader(data), int64(len(data)), "application/json")
+	return err
+}
+
+// LoadQualitySuite reads the stored suite of a table from MinIO.
+func (h *DataBrowserHandler) LoadQualitySuite(ctx context.Context, database, table string) (QualitySuite, error) {
+	var suite QualitySuite
+	if err := h.readJSON(ctx, qualitySuiteObject(ctx, database, table), &suite); err != nil {
+		return suite, fmt.Errorf("failed to read quality suite of %s.%s: %w", database, table, err)
+	}
+	suite.Database, suite.Table = database, table
+	return suite, nil
+}
+
+// SaveQualityResult stores result as the last of its table.
+func (h *DataBrowserHandler) SaveQualityResult(ctx context.Context, result QualityResult) error {
+	return h.storeJSON(ctx, qualityResultObject(ctx, result.Database, result.Table), result)
+}
+
+// ListQualityResults returns the last result of every table checked.
+func (h *DataBrowserHandler) ListQualityResults(ctx context.Context) ([]QualityResult, error) {
+	prefix := tenant.Prefix(ctx) + QualityResultPrefix
+	bucket, err := h.minioClient.Scope(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]QualityResult, 0)
+	client := h.minioClient.GetClient()
+	for object := range client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		if !strings.HasSuffix(object.Key, ".json") {
+			continue
+		}
+		var result QualityResult
+		if err := h.readJSON(ctx, object.Key, &result); err != nil {
+			return nil, fmt.Errorf("failed to read quality result %s: %w", object.Key, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (h *DataBrowserHandler) ListQualitySuites(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	prefix := tenant.Prefix(ctx) + QualitySuitePrefix
+	bucket, err := h.minioClient.Scope(ctx, prefix)
+	if err != nil {
+		httputil.WriteError(w, "Failed to list quality suites", http.StatusInternalServerError, err)
+		return
+	}
+
+	suites := make([]string, 0)
+	client := h.minioClient.GetClient()
+	for object := range client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			httputil.WriteError(w, "Failed to list quality suites", http.StatusInternalServerError, object.Err)
+			return
+		}
+		if strings.HasSuffix(object.Key, ".json") {
+			table := strings.TrimSuffix(strings.TrimPrefix(object.Key, prefix), ".json")
+			suites = append(suites, strings.Replace(table, "/", ".", 1))
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Quality suites listed successfully",
+		"suites":  suites,
+		"count":   len(suites),
+	})
+}
+
+func (h *DataBrowserHandler) GetQualitySuite(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	suite, err := h.LoadQualitySuite(r.Context(), vars["database"], vars["table"])
+	if err != nil {
+		httputil.WriteCode(w, httputil.CodeQualityNotFound, "Quality suite not found", err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Quality suite retrieved successfully",
+		"suite":   suite,
+	})
+}
+
+func (h *DataBrowserHandler) SaveQualitySuite(w http.ResponseWriter, r *http.Request) {
+	var suite QualitySuite
+	if err := json.NewDecoder(r.Body).Decode(&suite); err != nil {
+		httputil.WriteError(w, "Failed to decode request", http.StatusBadRequest, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	suite.Database, suite.Table = vars["database"], vars["table"]
+	if err := suite.Validate(); err != nil {
+		httputil.WriteError(w, "Invalid quality suite", http.StatusBadRequest, err)
+		return
+	}
+	suite.UpdatedAt = time.Now()
+
+	if err := h.storeJSON(r.Context(), qualitySuiteObject(r.Context(), suite.Database, suite.Table), suite); err != nil {
+		httputil.WriteError(w, "Failed to save quality suite", http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Quality suite saved successfully",
+		"suite":   suite,
+	})
+}
+
+// DeleteQualitySuite deletes the suite of a table and its last result, so
+// a blocking result no longer holds up merges.
+func (h *DataBrowserHandler) DeleteQualitySuite(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := h.minioClient.DeleteFile(r.Context(), qualitySuiteObject(r.Context(), vars["database"], vars["table"])); err != nil {
+		httputil.WriteError(w, "Failed to delete quality suite", http.StatusInternalServerError, err)
+		return
+	}
+	if err := h.minioClient.DeleteFile(r.Context(), qualityResultObject(r.Context(), vars["database"], vars["table"])); err != nil {
+		httputil.WriteError(w, "Failed to delete quality result", http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Quality suite deleted successfully",
+	})
+}
+
+func (h *DataBrowserHandler) GetQualityResult(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	var result QualityResult
+	if err := h.readJSON(r.Context(), qualityResultObject(r.Context(), vars["database"], vars["table"]), &result); err != nil {
+		httputil.WriteCode(w, httputil.CodeNotFound, "The table has not been checked", err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Quality result retrieved successfully",
+		"result":  result,
+	})
+}
+
+// MergeRequest is the body of a branch merge.
+type MergeRequest struct {
+	Into string `json:"into,omitempty"` // The branch merged into, default main
+}
+
+type MergeResponse struct {
+	Success bool          `json:"success"`
+	Code    httputil.Code `json:"code,omitempty"`
+	Message string        `json:"message"`
+	From    string        `json:"from"`
+	Into    string        `json:"into"`
+	Hash    string        `json:"hash,omitempty"` // Of into after the merge
+	// Blocking are the failing results of quality suites with the
+	// block_merge policy that refused the merge
+	Blocking []QualityResult `json:"blocking,omitempty"`
+}
+
+// MergeBranch merges a Nessie branch, unless the last quality check of a
+// table whose suite blocks merges failed.
+func (h *ExportHandler) MergeBranch(w http.ResponseWriter, r *http.Request) {
+	if !h.requireNessie(w) {
+		return
+	}
+
+	var request MergeRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			httputil.WriteError(w, "Failed to decode request", http.StatusBadRequest, err)
+			return
+		}
+	}
+	response := MergeResponse{From: mux.Vars(r)["branch"], Into: request.Into}
+	if response.Into == "" {
+		response.Into = "main"
+	}
+	if response.From == response.Into {
+		httputil.WriteError(w, "Cannot merge a branch into itself", http.StatusBadRequest, nil)
+		return
+	}
+
+	results, err := h.browser.ListQualityResults(r.Context())
+	if err != nil {
+		httputil.WriteError(w, "Failed to read quality results", http.StatusInternalServerError, err)
+		return
+	}
+	for _, result := range results {
+		if result.BlocksMerge() {
+			response.Blocking = append(response.Blocking, result)
+		}
+	}
+	if len(response.Blocking) > 0 {
+		tables := make([]string, len(response.Blocking))
+		for i, result := range response.Blocking {
+			tables[i] = result.Database + "." + result.Table
+		}
+		response.Code = httputil.CodeQualityFailed
+		response.Message = fmt.Sprintf("Merge blocked by failing quality checks of %s", strings.Join(tables, ", "))
+		httputil.WriteJSON(w, response.Code.Status(), response)
+		return
+	}
+
+	hash, err := h.nessieClient.Load().MergeBranch(r.Context(), response.From, response.Into)
+	if err != nil {
+		httputil.WriteCode(w, httputil.CodeNessieError, "Failed to merge branch", err)
+		return
+	}
+	response.Success = true
+	response.Hash = hash
+	response.Message = fmt.Sprintf("Merged %s into %s", response.From, response.Into)
+	httputil.WriteJSON(w, http.StatusOK, response)
+}
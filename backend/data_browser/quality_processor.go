@@ -0,0 +1,165 @@
+package data_browser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"bronze-backend/config"
+	"bronze-backend/jobs"
+	"bronze-backend/storage"
+)
+
+// ArtifactQualityReport is the artifact of a quality job holding its
+// validation report.
+const ArtifactQualityReport = "quality_report.json"
+
+// QualityProcessor runs "quality" jobs: it checks a table against its
+// quality suite, keeps the result as the table's last and fails the job if
+// the suite fails. Exports queue one for tables that have a suite.
+//
+// Job metadata:
+//   - table:    table to check (required)
+//   - database: database of the table (default NESSIE_DEFAULT_DB)
+type QualityProcessor struct {
+	browser     *DataBrowserHandler
+	minioClient *storage.MinIOClient
+	config      *config.Config
+	nessie      lazyNessie
+}
+
+func NewQualityProcessor(cfg *config.Config, minioClient *storage.MinIOClient) *QualityProcessor {
+	return &QualityProcessor{
+		browser:     NewDataBrowserHandler(minioClient),
+		minioClient: minioClient,
+		config:      cfg,
+		nessie:      lazyNessie{config: &cfg.Nessie},
+	}
+}
+
+func (qp *QualityProcessor) ProcessJob(ctx context.Context, job *jobs.Job) jobs.JobResult {
+	startTime := time.Now()
+
+	fail := func(format string, args ...any) jobs.JobResult {
+		return jobs.JobResult{
+			Success:        false,
+			ProcessingTime: time.Since(startTime),
+			Message:        fmt.Sprintf(format, args...),
+		}
+	}
+
+	if qp.minioClient == nil {
+		return fail("MinIO client not available")
+	}
+
+	table, _ := job.Metadata["table"].(string)
+	if table == "" {
+		return fail("table is required (metadata.table)")
+	}
+	database, _ := job.Metadata["database"].(string)
+	if database == "" {
+		database = qp.config.Nessie.DefaultDB
+	}
+
+	suite, err := qp.browser.LoadQualitySuite(ctx, database, table)
+	if err != nil {
+		return fail("Failed to load quality suite: %v", err)
+	}
+	if err := suite.Validate(); err != nil {
+		return fail("Invalid quality suite: %v", err)
+	}
+
+	nessieClient, err := qp.nessie.get()
+	if err != nil {
+		return fail("Nessie is not available: %v", err)
+	}
+
+	log.Printf("Checking quality of %s.%s for job %s", database, table, job.ID)
+
+	data, source, err := readNessieTable(ctx, nessieClient, database, table)
+	if err != nil {
+		return fail("Failed to read table: %v", err)
+	}
+
+	job.UpdateProgress(40)
+
+	if err := loadReferences(ctx, nessieClient, suite.Rules, qp.config.Nessie.DefaultDB); err != nil {
+		return fail("Failed to read referenced table: %v", err)
+	}
+
+	job.UpdateProgress(70)
+
+	result := QualityResult{
+		Database: database,
+		Table:    table,
+		Commit:   source.Commit,
+		Policy:   suite.Policy,
+		JobID:    job.ID,
+		Report:   RunValidationSuite(suite.validationSuite(), data.Columns, data.Rows),
+	}
+	if result.Policy == "" {
+		result.Policy = QualityPolicyWarn
+	}
+	result.Report.FileName = source.String()
+
+	if err := jobs.SaveArtifact(ctx, qp.minioClient, job, ArtifactQualityReport, result); err != nil {
+		log.Printf("Warning: Failed to upload quality report for job %s: %v", job.ID, err)
+	}
+	// Merges are gated on this, so a result that cannot be kept fails the job
+	if err := qp.browser.SaveQualityResult(ctx, result); err != nil {
+		return fail("Failed to save quality result: %v", err)
+	}
+
+	report := result.Report
+	if !report.Passed {
+		return fail("Quality checks failed: %d of %d rules failed (%d warnings)", report.Errors, len(report.Rules), report.Warnings)
+	}
+
+	return jobs.JobResult{
+		Success:        true,
+		ProcessingTime: time.Since(startTime),
+		Message:        fmt.Sprintf("Quality checks passed: %d rules, %d warnings", len(report.Rules), report.Warnings),
+		Result:         result,
+	}
+}
+
+// loadReferences reads the columns the references rules of rules check
+// against, each table once. Validate jobs read no tables, so their
+// references rules fail.
+func loadReferences(ctx context.Context, nessieClient *storage.NessieClient, rules []ValidationRule, defaultDB string) error {
+	tables := make(map[string]ProcessingResult)
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Type != RuleReferences {
+			continue
+		}
+		database := rule.RefDatabase
+		if database == "" {
+			database = defaultDB
+		}
+
+		key := database + "." + rule.RefTable
+		data, ok := tables[key]
+		if !ok {
+			var err error
+			if data, _, err = readNessieTable(ctx, nessieClient, database, rule.RefTable); err != nil {
+				return err
+			}
+			tables[key] = data
+		}
+
+		column := columnIndex(data.Columns, rule.RefColumn)
+		if column < 0 {
+			return fmt.Errorf("column %q not found in %s", rule.RefColumn, key)
+		}
+		rule.refValues = make(map[string]bool, len(data.Rows))
+		for _, row := range data.Rows {
+			if value := strings.TrimSpace(row[column]); value != "" {
+				rule.refValues[value] = true
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package data_browser
+
+import "testing"
+
+func TestQualitySuiteValidate(t *testing.T) {
+	maxRatio := 0.1
+	suite := QualitySuite{
+		Database: "bronze",
+		Table:    "orders",
+		Policy:   QualityPolicyBlockMerge,
+		Rules: []ValidationRule{
+			{Type: RuleMaxNullRatio, Column: "customer_id", Max: &maxRatio},
+			{Type: RuleReferences, Column: "customer_id", RefTable: "customers", RefColumn: "id"},
+		},
+	}
+	if err := suite.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if name := suite.validationSuite().Name; name != "bronze.orders" {
+		t.Errorf("suite name = %q", name)
+	}
+
+	for _, invalid := range []QualitySuite{
+		{Table: "orders"},
+		{Database: "bronze", Table: "a/b"},
+		{Database: "bronze", Table: "orders", Policy: "block"},
+		{Database: "bronze", Table: "orders", Rules: []ValidationRule{{Type: RuleUnique}}},
+	} {
+		if invalid.Validate() == nil {
+			t.Errorf("Validate(%+v) = nil", invalid)
+		}
+	}
+}
+
+func TestQualityResultBlocksMerge(t *testing.T) {
+	for _, tt := range []struct {
+		policy string
+		passed bool
+		want   bool
+	}{
+		{QualityPolicyBlockMerge, false, true},
+		{QualityPolicyBlockMerge, true, false},
+		{QualityPolicyWarn, false, false},
+	} {
+		result := QualityResult{Policy: tt.policy, Report: ValidationReport{Passed: tt.passed}}
+		if got := result.BlocksMerge(); got != tt.want {
+			t.Errorf("BlocksMerge(%s, passed %v) = %v", tt.policy, tt.passed, got)
+		}
+	}
+}
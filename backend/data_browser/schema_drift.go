@@ -0,0 +1,354 @@
+package data_browser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/minio/minio-go/v7"
+
+	"bronze-backend/auth"
+	"bronze-backend/config"
+	"bronze-backend/httputil"
+	"bronze-backend/realtime"
+	"bronze-backend/tenant"
+)
+
+// The schema registry keeps the schema of the last load of each table in
+// MinIO, at schemas/tables/{database}/{table}.json below the tenant's
+// prefix, and an alert for every load that drifted from it at
+// schemas/drift/{database}/{table}/{time}.json.
+const (
+	SchemaRegistryPrefix = "schemas/tables/"
+	SchemaDriftPrefix    = "schemas/drift/"
+)
+
+// SchemaDriftEvent is the realtime event and webhook of a drift alert.
+const SchemaDriftEvent = "export.schema_drift"
+
+// SchemaColumn is a column of a load and the kind of its values: integer,
+// number, boolean, date or string, or empty if it held no values.
+type SchemaColumn struct {
+	Name string `json:"name"`
+	Kind string `json:"kind,omitempty"`
+}
+
+// RegisteredSchema is the schema of a table's last load.
+type RegisteredSchema struct {
+	Database  string         `json:"database"`
+	Table     string         `json:"table"`
+	Columns   []SchemaColumn `json:"columns"`
+	Version   int            `json:"version"` // Counts the loads that changed it
+	Prefix    string         `json:"prefix"`  // Of the files loaded
+	ExportID  string         `json:"export_id"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+type RetypedColumn struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// SchemaDrift is how a load's columns differ from the registered ones.
+type SchemaDrift struct {
+	Added   []SchemaColumn  `json:"added,omitempty"`
+	Removed []SchemaColumn  `json:"removed,omitempty"`
+	Retyped []RetypedColumn `json:"retyped,omitempty"`
+}
+
+func (d SchemaDrift) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Retyped) == 0
+}
+
+func (d SchemaDrift) String() string {
+	var parts []string
+	for _, column := range d.Added {
+		parts = append(parts, "+"+column.Name)
+	}
+	for _, column := range d.Removed {
+		parts = append(parts, "-"+column.Name)
+	}
+	for _, column := range d.Retyped {
+		parts = append(parts, fmt.Sprintf("%s %s->%s", column.Name, column.From, column.To))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DriftAlert records a load that drifted from the registered schema.
+type DriftAlert struct {
+	Database       string      `json:"database"`
+	Table          string      `json:"table"`
+	Prefix         string      `json:"prefix"`
+	Files          []string    `json:"files"`
+	Drift          SchemaDrift `json:"drift"`
+	Blocked        bool        `json:"blocked"` // The export was refused
+	ExportID       string      `json:"export_id"`
+	Subject        string      `json:"subject,omitempty"`
+	SchemaVersion  int         `json:"schema_version"` // Of the registered schema compared with
+	DetectedAt     time.Time   `json:"detected_at"`
+	RegisteredFrom string      `json:"registered_from,omitempty"` // The export that registered it
+}
+
+// inferColumnKinds returns the columns of a load with the kind of their
+// values across the files read. A column whose files disagree is string.
+func inferColumnKinds(results []ProcessingResult, columns []string) []SchemaColumn {
+	schema := make([]SchemaColumn, len(columns))
+	for i, column := range columns {
+		schema[i].Name = column
+		for _, result := range results {
+			if !result.Success {
+				continue
+			}
+			index := columnIndex(result.Columns, column)
+			if index < 0 {
+				continue
+			}
+			for _, row := range result.Rows {
+				if index >= len(row) || strings.TrimSpace(row[index]) == "" {
+					continue
+				}
+				schema[i].Kind = widenKind(schema[i].Kind, valueKind(strings.TrimSpace(row[index])))
+			}
+		}
+	}
+	return schema
+}
+
+// valueKind returns the kind of a value.
+func valueKind(value string) string {
+	for _, kind := range []string{"integer", "number", "boolean", "date"} {
+		if matchesDataType(value, kind) {
+			return kind
+		}
+	}
+	return "string"
+}
+
+// widenKind returns the kind holding values of kinds a and b.
+func widenKind(a, b string) string {
+	switch {
+	case a == "" || a == b:
+		return b
+	case (a == "integer" && b == "number") || (a == "number" && b == "integer"):
+		return "number"
+	}
+	return "string"
+}
+
+// compareSchemas returns how load differs from registered. Names are
+// compared ignoring case; a column that held no values in either is not
+// re-typed.
+func compareSchemas(registered, load []SchemaColumn) SchemaDrift {
+	var drift SchemaDrift
+	find := func(columns []SchemaColumn, name string) int {
+		return slices.IndexFunc(columns, func(c SchemaColumn) bool { return strings.EqualFold(c.Name, name) })
+	}
+	for _, column := range load {
+		i := find(registered, column.Name)
+		switch {
+		case i < 0:
+			drift.Added = append(drift.Added, column)
+		case registered[i].Kind != "" && column.Kind != "" && registered[i].Kind != column.Kind:
+			drift.Retyped = append(drift.Retyped, RetypedColumn{Name: column.Name, From: registered[i].Kind, To: column.Kind})
+		}
+	}
+	for _, column := range registered {
+		if find(load, column.Name) < 0 {
+			drift.Removed = append(drift.Removed, column)
+		}
+	}
+	return drift
+}
+
+// filesPrefix returns the folder the files of a request share.
+func filesPrefix(files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	prefix := path.Dir(files[0])
+	for _, file := range files[1:] {
+		for prefix != "." && prefix != "/" && !strings.HasPrefix(file, prefix+"/") {
+			prefix = path.Dir(prefix)
+		}
+	}
+	if prefix == "." || prefix == "/" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+func schemaRegistryObject(ctx context.Context, database, table string) string {
+	return tenant.Prefix(ctx) + SchemaRegistryPrefix + database + "/" + table + ".json"
+}
+
+func driftAlertPrefix(ctx context.Context, database, table string) string {
+	prefix := tenant.Prefix(ctx) + SchemaDriftPrefix
+	if database != "" {
+		prefix += database + "/"
+		if table != "" {
+			prefix += table + "/"
+		}
+	}
+	return prefix
+}
+
+// schemaDriftPolicy returns what the export does on drift: the request's
+// choice, else EXPORT_SCHEMA_DRIFT.
+func (h *ExportHandler) schemaDriftPolicy(request ExportRequest) (string, error) {
+	policy := request.OnSchemaDrift
+	if policy == "" && h.config != nil {
+		policy = h.config.Nessie.SchemaDrift
+	}
+	switch policy {
+	case "":
+		return config.SchemaDriftWarn, nil
+	case config.SchemaDriftWarn, config.SchemaDriftBlock, config.SchemaDriftOff:
+		return policy, nil
+	}
+	return "", httputil.NewError(httputil.CodeBadRequest, fmt.Sprintf("invalid on_schema_drift %q, use warn, block or off", policy), nil)
+}
+
+// checkSchemaDrift compares a load with the table's registered schema and,
+// if it drifted, stores an alert and notifies of it. It returns the alert,
+// or nil without drift or a registered schema.
+func (h *ExportHandler) checkSchemaDrift(ctx context.Context, request ExportRequest, database string, load []SchemaColumn, block bool) *DriftAlert {
+	var registered RegisteredSchema
+	if err := h.browser.readJSON(ctx, schemaRegistryObject(ctx, database, request.TableName), &registered); err != nil {
+		return nil // First load, or none registered yet
+	}
+	drift := compareSchemas(registered.Columns, load)
+	if drift.empty() {
+		return nil
+	}
+
+	files := make([]string, len(request.Files))
+	for i, file := range request.Files {
+		files[i] = file.FileName
+	}
+	now := time.Now()
+	alert := &DriftAlert{
+		Database:       database,
+		Table:          request.TableName,
+		Prefix:         filesPrefix(files),
+		Files:          files,
+		Drift:          drift,
+		Blocked:        block,
+		ExportID:       request.ID,
+		Subject:        auth.Subject(ctx),
+		SchemaVersion:  registered.Version,
+		DetectedAt:     now,
+		RegisteredFrom: registered.ExportID,
+	}
+	log.Printf("Schema drift in export to %s.%s: %s", database, request.TableName, drift)
+
+	key := driftAlertPrefix(ctx, database, request.TableName) + strconv.FormatInt(now.UnixNano(), 10) + ".json"
+	if err := h.browser.storeJSON(ctx, key, alert); err != nil {
+		log.Printf("Warning: Failed to save schema drift alert for %s.%s: %v", database, request.TableName, err)
+	}
+	h.events.Publish(realtime.TopicExports, SchemaDriftEvent, alert)
+	if h.notifier != nil && h.config != nil && h.config.Processing.Webhook.URL != "" {
+		if body, err := json.Marshal(map[string]any{"event": SchemaDriftEvent, "alert": alert}); err == nil {
+			h.notifier.Send(h.config.Processing.Webhook.URL, h.config.Processing.Webhook.Secret, SchemaDriftEvent, body)
+		}
+	}
+	return alert
+}
+
+// registerSchema records load as the table's schema after an export
+// committed it.
+func (h *ExportHandler) registerSchema(ctx context.Context, request ExportRequest, database string, load []SchemaColumn, created bool) {
+	key := schemaRegistryObject(ctx, database, request.TableName)
+	var registered RegisteredSchema
+	if err := h.browser.readJSON(ctx, key, &registered); err != nil || created {
+		registered = RegisteredSchema{}
+	}
+	if registered.Version == 0 || !compareSchemas(registered.Columns, load).empty() {
+		registered.Version++
+	}
+
+	files := make([]string, len(request.Files))
+	for i, file := range request.Files {
+		files[i] = file.FileName
+	}
+	registered.Database = database
+	registered.Table = request.TableName
+	registered.Columns = load
+	registered.Prefix = filesPrefix(files)
+	registered.ExportID = request.ID
+	registered.UpdatedAt = time.Now()
+	if err := h.browser.storeJSON(ctx, key, registered); err != nil {
+		log.Printf("Warning: Failed to register schema of %s.%s: %v", database, request.TableName, err)
+	}
+}
+
+// GetRegisteredSchema answers the schema of a table's last load.
+func (h *DataBrowserHandler) GetRegisteredSchema(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	database, table := vars["database"], vars["table"]
+	var registered RegisteredSchema
+	if err := h.readJSON(r.Context(), schemaRegistryObject(r.Context(), database, table), &registered); err != nil {
+		httputil.WriteCode(w, httputil.CodeNotFound, "No schema registered for the table", err)
+		return
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"schema":  registered,
+	})
+}
+
+// ListDriftAlerts answers the schema drift alerts, newest first, of every
+// table or, with ?database= and ?table=, of one.
+func (h *DataBrowserHandler) ListDriftAlerts(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	database, table := r.URL.Query().Get("database"), r.URL.Query().Get("table")
+	if table != "" && database == "" {
+		httputil.WriteError(w, "database is required with table", http.StatusBadRequest, nil)
+		return
+	}
+	if strings.ContainsAny(database+table, "/\\") {
+		httputil.WriteError(w, "Database and table must not contain path separators", http.StatusBadRequest, nil)
+		return
+	}
+
+	prefix := driftAlertPrefix(ctx, database, table)
+	bucket, err := h.minioClient.Scope(ctx, prefix)
+	if err != nil {
+		httputil.WriteError(w, "Failed to list schema drift alerts", http.StatusInternalServerError, err)
+		return
+	}
+
+	alerts := make([]DriftAlert, 0)
+	client := h.minioClient.GetClient()
+	for object := range client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			httputil.WriteError(w, "Failed to list schema drift alerts", http.StatusInternalServerError, object.Err)
+			return
+		}
+		var alert DriftAlert
+		if err := h.readJSON(ctx, object.Key, &alert); err != nil {
+			log.Printf("Warning: Failed to read schema drift alert %s: %v", object.Key, err)
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].DetectedAt.After(alerts[j].DetectedAt) })
+
+	httputil.WriteJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"alerts":  alerts,
+		"count":   len(alerts),
+	})
+}
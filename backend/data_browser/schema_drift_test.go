@@ -0,0 +1,62 @@
+package data_browser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestInferColumnKinds(t *testing.T) {
+	results := []ProcessingResult{
+		{Success: true, Columns: []string{"id", "price", "active", "note"}, Rows: [][]string{{"1", "2", "true", ""}, {"2", "", "false", "x"}}},
+		{Success: true, Columns: []string{"ID", "Price", "seen"}, Rows: [][]string{{"3", "2.5", "2026-01-02"}}},
+		{Columns: []string{"id"}, Rows: [][]string{{"abc"}}},
+	}
+
+	got := inferColumnKinds(results, []string{"id", "price", "active", "note", "seen", "empty"})
+	want := []SchemaColumn{
+		{Name: "id", Kind: "integer"},
+		{Name: "price", Kind: "number"},
+		{Name: "active", Kind: "boolean"},
+		{Name: "note", Kind: "string"},
+		{Name: "seen", Kind: "date"},
+		{Name: "empty"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("inferColumnKinds = %+v, want %+v", got, want)
+	}
+}
+
+func TestCompareSchemas(t *testing.T) {
+	registered := []SchemaColumn{{Name: "id", Kind: "integer"}, {Name: "price", Kind: "number"}, {Name: "note", Kind: "string"}, {Name: "seen"}}
+	load := []SchemaColumn{{Name: "ID", Kind: "integer"}, {Name: "price", Kind: "string"}, {Name: "seen", Kind: "date"}, {Name: "region", Kind: "string"}}
+
+	drift := compareSchemas(registered, load)
+	if !slices.Equal(drift.Added, []SchemaColumn{{Name: "region", Kind: "string"}}) ||
+		!slices.Equal(drift.Removed, []SchemaColumn{{Name: "note", Kind: "string"}}) ||
+		!slices.Equal(drift.Retyped, []RetypedColumn{{Name: "price", From: "number", To: "string"}}) {
+		t.Errorf("drift = %+v", drift)
+	}
+	if s := drift.String(); s != "+region, -note, price number->string" {
+		t.Errorf("String() = %q", s)
+	}
+	if !compareSchemas(registered, registered).empty() {
+		t.Error("a schema drifted from itself")
+	}
+}
+
+func TestFilesPrefix(t *testing.T) {
+	tests := []struct {
+		files []string
+		want  string
+	}{
+		{[]string{"sales/2026/jan.csv", "sales/2026/feb.csv"}, "sales/2026/"},
+		{[]string{"sales/2026/jan.csv", "sales/2025/dec.csv"}, "sales/"},
+		{[]string{"a.csv", "sales/b.csv"}, ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if got := filesPrefix(test.files); got != test.want {
+			t.Errorf("filesPrefix(%v) = %q, want %q", test.files, got, test.want)
+		}
+	}
+}
@@ -10,7 +10,7 @@ type SchemaMerger struct {
 	files         []FileInfo
 	mergedColumns []string
 	columnTypes   map[string]string
-	resolution    string // "merge", "first_file", "manual"
+	resolution    string // "merge", "first_file", "manual", "preserve_order"
 }
 
 type FileInfo struct {
@@ -45,6 +45,9 @@ const (
 	ResolutionMerge  = "merge"
 	ResolutionFirst  = "first_file"
 	ResolutionManual = "manual"
+	// ResolutionOrdered merges like ResolutionMerge but keeps the order and
+	// casing of the columns as the files give them
+	ResolutionOrdered = "preserve_order"
 )
 
 func NewSchemaMerger(resolution string) *SchemaMerger {
@@ -68,6 +71,8 @@ func (sm *SchemaMerger) MergeSchemas(files []FileInfo) (*MergedSchema, error) {
 		return sm.mergeWithUnion(files)
 	case ResolutionManual:
 		return sm.mergeWithManualResolution(files)
+	case ResolutionOrdered:
+		return sm.mergePreservingOrder(files)
 	default:
 		return sm.mergeWithUnion(files) // Default to merge
 	}
@@ -142,6 +147,63 @@ func (sm *SchemaMerger) mergeWithUnion(files []FileInfo) (*MergedSchema, error)
 	}, nil
 }
 
+// mergePreservingOrder takes the union of the columns of files like
+// mergeWithUnion, but keeps the first file's columns in their order and
+// casing, then appends those only later files have, in the order they are
+// first seen. A column spelled with other casing in a later file is a
+// case_diff conflict resolved to its first spelling.
+func (sm *SchemaMerger) mergePreservingOrder(files []FileInfo) (*MergedSchema, error) {
+	var mergedColumns []string
+	occurrences := make(map[string][]FileColumn)
+
+	for _, file := range files {
+		for _, col := range file.Columns {
+			colKey := strings.ToLower(col)
+			if _, seen := occurrences[colKey]; !seen {
+				mergedColumns = append(mergedColumns, col)
+			}
+			occurrences[colKey] = append(occurrences[colKey], FileColumn{
+				FileName:   file.FileName,
+				ColumnName: col,
+				DataType:   sm.inferType(file, col),
+			})
+		}
+	}
+
+	var columnConflicts []ColumnConflict
+	for _, col := range mergedColumns {
+		fileCols := occurrences[strings.ToLower(col)]
+		for _, fileCol := range fileCols[1:] {
+			if fileCol.ColumnName != col {
+				columnConflicts = append(columnConflicts, ColumnConflict{
+					ColumnName:   col,
+					ConflictType: "case_diff",
+					Files:        fileCols,
+					Resolution:   "use_first_occurrence",
+				})
+				break
+			}
+		}
+	}
+
+	for _, col := range mergedColumns {
+		sm.columnTypes[col] = sm.inferTypeFromFiles(files, col)
+	}
+
+	var totalRows int64
+	for _, file := range files {
+		totalRows += file.RowCount
+	}
+
+	return &MergedSchema{
+		Columns:     mergedColumns,
+		ColumnTypes: sm.columnTypes,
+		SourceFiles: files,
+		TotalRows:   totalRows,
+		Conflicts:   columnConflicts,
+	}, nil
+}
+
 func (sm *SchemaMerger) mergeWithFirstFile(files []FileInfo) (*MergedSchema, error) {
 	if len(files) == 0 {
 		return nil, fmt.Errorf("no files provided")
@@ -0,0 +1,36 @@
+package data_browser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMergePreservingOrder(t *testing.T) {
+	files := []FileInfo{
+		{FileName: "a.csv", Columns: []string{"Order ID", "Customer", "Amount"}, RowCount: 2},
+		{FileName: "b.csv", Columns: []string{"amount", "Region", "Order ID", "Discount"}, RowCount: 3},
+	}
+
+	merged, err := NewSchemaMerger(ResolutionOrdered).MergeSchemas(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Order ID", "Customer", "Amount", "Region", "Discount"}; !slices.Equal(merged.Columns, want) {
+		t.Errorf("columns = %v, want %v", merged.Columns, want)
+	}
+	if merged.TotalRows != 5 || merged.ColumnTypes["Amount"] != "BIGINT" {
+		t.Errorf("merged = %+v", merged)
+	}
+	if len(merged.Conflicts) != 1 || merged.Conflicts[0].ColumnName != "Amount" || merged.Conflicts[0].ConflictType != "case_diff" {
+		t.Errorf("conflicts = %+v", merged.Conflicts)
+	}
+
+	// The default merge still sorts and lowercases
+	union, err := NewSchemaMerger(ResolutionMerge).MergeSchemas(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"amount", "customer", "discount", "order id", "region"}; !slices.Equal(union.Columns, want) {
+		t.Errorf("union columns = %v, want %v", union.Columns, want)
+	}
+}
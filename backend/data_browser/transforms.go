@@ -0,0 +1,366 @@
+package data_browser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/minio/minio-go/v7"
+
+	"bronze-backend/httputil"
+	"bronze-backend/tenant"
+)
+
+// TransformSetPrefix is where transform sets are stored in MinIO, one JSON
+// document per set at transforms/sets/{name}.json, below the tenant's
+// prefix.
+const TransformSetPrefix = "transforms/sets/"
+
+// What a value that does not cast becomes
+const (
+	CastErrorFail = "fail" // The promotion fails
+	CastErrorNull = "null"
+	CastErrorDrop = "drop" // Its row is dropped
+)
+
+// castTypes maps the types columns cast to to their table column types.
+var castTypes = map[string]string{
+	"string":    "VARCHAR(255)",
+	"integer":   "BIGINT",
+	"number":    "DOUBLE",
+	"boolean":   "BOOLEAN",
+	"date":      "DATE",
+	"timestamp": "TIMESTAMP",
+}
+
+// TransformSet is a saved sequence of transforms turning bronze rows into
+// silver ones, run by promote jobs. In order: ColumnRules drop and rename
+// columns, Casts convert columns by their new names, DedupeKeys drop
+// repeated rows, and the rows must pass ValidationSuite.
+type TransformSet struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	ColumnRules
+	// Casts maps columns to string, integer, number, boolean, date or
+	// timestamp
+	Casts       map[string]string `json:"casts,omitempty"`
+	OnCastError string            `json:"on_cast_error,omitempty"` // CastErrorFail (default), CastErrorNull or CastErrorDrop
+	DedupeKeys  []string          `json:"dedupe_keys,omitempty"`   // As in ExportRequest
+	// ValidationSuite names a stored validation suite
+	ValidationSuite string    `json:"validation_suite,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at,omitempty"`
+}
+
+// Validate checks the set before it is stored or run.
+func (s *TransformSet) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("transform set name is required")
+	}
+	if strings.ContainsAny(s.Name, "/\\") {
+		return fmt.Errorf("transform set name must not contain path separators")
+	}
+	for column, castType := range s.Casts {
+		if _, ok := castTypes[castType]; !ok {
+			return fmt.Errorf("column %q has unsupported cast type %q", column, castType)
+		}
+	}
+	switch s.OnCastError {
+	case "", CastErrorFail, CastErrorNull, CastErrorDrop:
+	default:
+		return fmt.Errorf("invalid on_cast_error %q, use fail, null or drop", s.OnCastError)
+	}
+	if strings.ContainsAny(s.ValidationSuite, "/\\") {
+		return fmt.Errorf("validation suite name must not contain path separators")
+	}
+	return nil
+}
+
+// TransformStats counts what a transform set changed.
+type TransformStats struct {
+	RowsRead    int `json:"rows_read"`
+	RowsWritten int `json:"rows_written"`
+	CastErrors  int `json:"cast_errors"`
+	RowsDropped int `json:"rows_dropped"` // For values that did not cast
+	Duplicates  int `json:"duplicates"`
+}
+
+// apply transforms the columns and rows of result in place, short of
+// validation, and returns the table column types of its columns.
+func (s *TransformSet) apply(result *ProcessingResult) (TransformStats, []string, error) {
+	stats := TransformStats{RowsRead: len(result.Rows)}
+
+	results := []ProcessingResult{*result}
+	applyColumnRules(results, s.ColumnRules)
+	*result = results[0]
+
+	types := make([]string, len(result.Columns))
+	casts := make([]string, len(result.Columns))
+	for i := range types {
+		types[i] = castTypes["string"]
+	}
+	for column, castType := range s.Casts {
+		i := columnIndex(result.Columns, column)
+		if i < 0 {
+			return stats, nil, fmt.Errorf("cast column %q is not a column after renames", column)
+		}
+		casts[i] = castType
+		types[i] = castTypes[castType]
+	}
+
+	kept := result.Rows[:0]
+rows:
+	for r, row := range result.Rows {
+		values := make([]string, len(result.Columns))
+		copy(values, row)
+		for i, castType := range casts {
+			if castType == "" || values[i] == "" {
+				continue
+			}
+			value, ok := castValue(values[i], castType)
+			if ok {
+				values[i] = value
+				continue
+			}
+			stats.CastErrors++
+			switch s.OnCastError {
+			case CastErrorNull:
+				values[i] = ""
+			case CastErrorDrop:
+				stats.RowsDropped++
+				continue rows
+			default:
+				return stats, nil, fmt.Errorf("row %d: %q in column %q is not a %s", r+1, values[i], result.Columns[i], castType)
+			}
+		}
+		kept = append(kept, values)
+	}
+	result.Rows = kept
+
+	results = []ProcessingResult{*result}
+	applyDedupe(results, s.DedupeKeys)
+	*result = results[0]
+	if !result.Success {
+		return stats, nil, errors.New(result.Errors[len(result.Errors)-1].ErrorMsg)
+	}
+	stats.Duplicates = result.Duplicates
+	stats.RowsWritten = len(result.Rows)
+	result.RowCount = len(result.Rows)
+	return stats, types, nil
+}
+
+// columnIndex returns the index of the column called name, ignoring case,
+// or -1.
+func columnIndex(columns []string, name string) int {
+	for i, column := range columns {
+		if strings.EqualFold(column, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// timestampLayouts are the layouts timestamps are parsed with, after
+// RFC 3339; dates parse as midnight UTC.
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+}
+
+// castValue converts value to castType, in the canonical text of the type:
+// integers and numbers without exponent or trailing zeros, booleans as
+// true or false, dates as 2006-01-02 and timestamps as RFC 3339 in UTC.
+func castValue(value, castType string) (string, bool) {
+	value = strings.TrimSpace(value)
+	switch castType {
+	case "string":
+		return value, true
+	case "integer":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return strconv.FormatInt(n, 10), true
+		}
+		// Whole numbers written as decimals, as spreadsheets do
+		if f, err := strconv.ParseFloat(value, 64); err == nil && f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+			return strconv.FormatInt(int64(f), 10), true
+		}
+		return "", false
+	case "number":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", false
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), true
+	case "boolean":
+		switch strings.ToLower(value) {
+		case "yes", "y":
+			return "true", true
+		case "no", "n":
+			return "false", true
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", false
+		}
+		return strconv.FormatBool(b), true
+	case "date":
+		if t, ok := parseTimestamp(value); ok {
+			return t.Format("2006-01-02"), true
+		}
+		return "", false
+	case "timestamp":
+		if t, ok := parseTimestamp(value); ok {
+			return t.UTC().Format(time.RFC3339), true
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// parseTimestamp parses value as RFC 3339, a timestamp layout or a date.
+func parseTimestamp(value string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, true
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	for _, layout := range validationDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// transformSetObject returns the object of the set called name. Each tenant
+// keeps its sets below its own prefix.
+func transformSetObject(ctx context.Context, name string) string {
+	return tenant.Prefix(ctx) + TransformSetPrefix + name + ".json"
+}
+
+// LoadTransformSet reads a stored set from MinIO.
+func (h *DataBrowserHandler) LoadTransformSet(ctx context.Context, name string) (TransformSet, error) {
+	var set TransformSet
+
+	reader, err := h.minioClient.DownloadFile(ctx, transformSetObject(ctx, name))
+	if err != nil {
+		return set, fmt.Errorf("failed to open transform set %s: %w", name, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return set, fmt.Errorf("failed to read transform set %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return set, fmt.Errorf("failed to decode transform set %s: %w", name, err)
+	}
+	if set.Name == "" {
+		set.Name = name
+	}
+
+	return set, nil
+}
+
+func (h *DataBrowserHandler) ListTransformSets(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	prefix := tenant.Prefix(ctx) + TransformSetPrefix
+	bucket, err := h.minioClient.Scope(ctx, prefix)
+	if err != nil {
+		httputil.WriteError(w, "Failed to list transform sets", http.StatusInternalServerError, err)
+		return
+	}
+
+	sets := make([]string, 0)
+	client := h.minioClient.GetClient()
+	for object := range client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			httputil.WriteError(w, "Failed to list transform sets", http.StatusInternalServerError, object.Err)
+			return
+		}
+		if strings.HasSuffix(object.Key, ".json") {
+			sets = append(sets, strings.TrimSuffix(path.Base(object.Key), ".json"))
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Transform sets listed successfully",
+		"sets":    sets,
+		"count":   len(sets),
+	})
+}
+
+func (h *DataBrowserHandler) GetTransformSet(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	set, err := h.LoadTransformSet(r.Context(), name)
+	if err != nil {
+		httputil.WriteCode(w, httputil.CodeTransformNotFound, "Transform set not found", err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Transform set retrieved successfully",
+		"set":     set,
+	})
+}
+
+func (h *DataBrowserHandler) SaveTransformSet(w http.ResponseWriter, r *http.Request) {
+	var set TransformSet
+	if err := json.NewDecoder(r.Body).Decode(&set); err != nil {
+		httputil.WriteError(w, "Failed to decode request", http.StatusBadRequest, err)
+		return
+	}
+
+	set.Name = mux.Vars(r)["name"]
+	if err := set.Validate(); err != nil {
+		httputil.WriteError(w, "Invalid transform set", http.StatusBadRequest, err)
+		return
+	}
+	set.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		httputil.WriteError(w, "Failed to encode transform set", http.StatusInternalServerError, err)
+		return
+	}
+
+	if _, err := h.minioClient.UploadFile(r.Context(), transformSetObject(r.Context(), set.Name), bytes.NewReader(data), int64(len(data)), "application/json"); err != nil {
+		httputil.WriteError(w, "Failed to save transform set", http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Transform set saved successfully",
+		"set":     set,
+	})
+}
+
+func (h *DataBrowserHandler) DeleteTransformSet(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := h.minioClient.DeleteFile(r.Context(), transformSetObject(r.Context(), name)); err != nil {
+		httputil.WriteError(w, "Failed to delete transform set", http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Transform set deleted successfully",
+	})
+}
@@ -0,0 +1,101 @@
+package data_browser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCastValue(t *testing.T) {
+	for _, tt := range []struct {
+		value, castType, want string
+		ok                    bool
+	}{
+		{" 42 ", "integer", "42", true},
+		{"42.0", "integer", "42", true},
+		{"42.5", "integer", "", false},
+		{"1.50", "number", "1.5", true},
+		{"1e3", "number", "1000", true},
+		{"NaN", "number", "", false},
+		{"Yes", "boolean", "true", true},
+		{"0", "boolean", "false", true},
+		{"maybe", "boolean", "", false},
+		{"2026-03-01 10:00:00", "date", "2026-03-01", true},
+		{"01/03/2026", "date", "2026-01-03", true},
+		{"2026-03-01T10:00:00+07:00", "timestamp", "2026-03-01T03:00:00Z", true},
+		{"yesterday", "timestamp", "", false},
+	} {
+		got, ok := castValue(tt.value, tt.castType)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("castValue(%q, %q) = %q, %v, want %q, %v", tt.value, tt.castType, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTransformSetApply(t *testing.T) {
+	newResult := func() ProcessingResult {
+		return ProcessingResult{
+			FileName: "orders.csv",
+			Success:  true,
+			Columns:  []string{"id", "amt", "note"},
+			Rows:     [][]string{{"1", "9.50", "a"}, {"2", "x", "b"}, {"1", "9.5", "c"}, {"3", "", "d"}},
+		}
+	}
+	set := TransformSet{
+		Name:        "orders",
+		ColumnRules: ColumnRules{ExcludeColumns: []string{"note"}, RenameColumns: map[string]string{"amt": "amount"}},
+		Casts:       map[string]string{"id": "integer", "amount": "number"},
+		DedupeKeys:  []string{"id", "amount"},
+	}
+
+	// A value that does not cast fails the set by default
+	result := newResult()
+	if _, _, err := set.apply(&result); err == nil {
+		t.Fatal("applied a set with a value that does not cast")
+	}
+
+	set.OnCastError = CastErrorDrop
+	result = newResult()
+	stats, types, err := set.apply(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.Columns, []string{"id", "amount"}) || !slices.Equal(types, []string{"BIGINT", "DOUBLE"}) {
+		t.Errorf("columns = %q, types = %q", result.Columns, types)
+	}
+	// Rows cast before they are compared, so 9.50 and 9.5 are duplicates;
+	// empty values are left alone
+	want := TransformStats{RowsRead: 4, RowsWritten: 2, CastErrors: 1, RowsDropped: 1, Duplicates: 1}
+	if stats != want || len(result.Rows) != 2 || result.Rows[0][1] != "9.5" || result.Rows[1][1] != "" {
+		t.Errorf("stats = %+v, rows = %q", stats, result.Rows)
+	}
+
+	set.OnCastError = CastErrorNull
+	result = newResult()
+	if stats, _, err := set.apply(&result); err != nil || stats.RowsWritten != 3 || result.Rows[1][1] != "" {
+		t.Errorf("null on cast error: %+v, %q, %v", stats, result.Rows, err)
+	}
+
+	// Casts name columns after renames
+	set.Casts = map[string]string{"amt": "number"}
+	result = newResult()
+	if _, _, err := set.apply(&result); err == nil {
+		t.Error("cast a column by its name before renames")
+	}
+}
+
+func TestTransformSetValidate(t *testing.T) {
+	for _, set := range []TransformSet{
+		{},
+		{Name: "a/b"},
+		{Name: "a", Casts: map[string]string{"id": "uuid"}},
+		{Name: "a", OnCastError: "skip"},
+		{Name: "a", ValidationSuite: "../x"},
+	} {
+		if set.Validate() == nil {
+			t.Errorf("Validate(%+v) = nil", set)
+		}
+	}
+	if err := (&TransformSet{Name: "a", Casts: map[string]string{"id": "integer"}, OnCastError: CastErrorNull}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,129 @@
+package data_browser
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"bronze-backend/httputil"
+)
+
+// SourceFileColumn is the first column of a union, naming each row's file.
+const SourceFileColumn = "_source_file"
+
+// MaxUnionFiles caps the files one union browses.
+const MaxUnionFiles = 100
+
+// unionPart is a file of a union, read whole.
+type unionPart struct {
+	file     string
+	response BrowseResponse
+}
+
+// browseUnion reads request.UnionFiles whole, with the request's options,
+// and answers a page of their rows one file after the other, in the
+// columns a multi-file export with request.SchemaResolution would create.
+// Columns match ignoring case; a row has no value for the columns its file
+// lacks, and loses those the merged columns leave out.
+func (h *DataBrowserHandler) browseUnion(ctx context.Context, request BrowseRequest) (BrowseResponse, error) {
+	if request.FileName != "" {
+		return BrowseResponse{}, httputil.NewError(httputil.CodeBadRequest, "give file_name or union_files, not both", nil)
+	}
+	if len(request.UnionFiles) > MaxUnionFiles {
+		return BrowseResponse{}, httputil.NewError(httputil.CodeBadRequest, fmt.Sprintf("union_files takes at most %d files", MaxUnionFiles), nil)
+	}
+	if request.VersionID != "" {
+		return BrowseResponse{}, httputil.NewError(httputil.CodeBadRequest, "version_id is not supported with union_files", nil)
+	}
+
+	parts := make([]unionPart, 0, len(request.UnionFiles))
+	files := make([]FileInfo, 0, len(request.UnionFiles))
+	for _, file := range request.UnionFiles {
+		data, err := h.downloadVersion(ctx, file, "")
+		if err != nil {
+			return BrowseResponse{}, fmt.Errorf("%s: %w", file, err)
+		}
+		read := request
+		read.FileName, read.Offset, read.MaxRows = file, 0, fullReadRows
+		response, err := h.readData(data, read)
+		if err != nil {
+			return BrowseResponse{}, fmt.Errorf("%s: %w", file, err)
+		}
+		parts = append(parts, unionPart{file: file, response: response})
+		files = append(files, FileInfo{
+			FileName: file,
+			Columns:  response.Columns,
+			RowCount: int64(len(response.Rows)),
+			DataType: response.DataType,
+		})
+	}
+
+	merged, err := NewSchemaMerger(request.SchemaResolution).MergeSchemas(files)
+	if err != nil {
+		return BrowseResponse{}, err
+	}
+
+	response := BrowseResponse{
+		Success:     true,
+		Message:     fmt.Sprintf("%d files unioned", len(parts)),
+		DataType:    "union",
+		Columns:     append([]string{SourceFileColumn}, merged.Columns...),
+		Rows:        unionPage(parts, merged.Columns, request.Offset, request.MaxRows),
+		TotalRows:   merged.TotalRows,
+		Offset:      request.Offset,
+		HasHeaders:  request.HasHeaders,
+		SourceFiles: merged.SourceFiles,
+		Conflicts:   merged.Conflicts,
+	}
+	response.RowCount = len(response.Rows)
+	return response, nil
+}
+
+// unionPage returns up to limit rows of parts from offset on, one file
+// after the other, in columns after SourceFileColumn.
+func unionPage(parts []unionPart, columns []string, offset, limit int) [][]string {
+	page := [][]string{}
+	for _, part := range parts {
+		rows := part.response.Rows
+		if offset >= len(rows) {
+			offset -= len(rows)
+			continue
+		}
+		positions := unionPositions(part.response.Columns, columns)
+		for _, row := range rows[offset:] {
+			if len(page) >= limit {
+				return page
+			}
+			unioned := make([]string, len(columns)+1)
+			unioned[0] = part.file
+			for i, position := range positions {
+				if position >= 0 && position < len(row) {
+					unioned[i+1] = row[position]
+				}
+			}
+			page = append(page, unioned)
+		}
+		offset = 0
+	}
+	return page
+}
+
+// unionPositions returns, for each merged column, the position of the file
+// column of that name ignoring case, or -1.
+func unionPositions(fileColumns, mergedColumns []string) []int {
+	byName := make(map[string]int, len(fileColumns))
+	for i, column := range fileColumns {
+		if _, ok := byName[strings.ToLower(column)]; !ok {
+			byName[strings.ToLower(column)] = i
+		}
+	}
+	positions := make([]int, len(mergedColumns))
+	for i, column := range mergedColumns {
+		position, ok := byName[strings.ToLower(column)]
+		if !ok {
+			position = -1
+		}
+		positions[i] = position
+	}
+	return positions
+}
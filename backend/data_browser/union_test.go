@@ -0,0 +1,32 @@
+package data_browser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnionPage(t *testing.T) {
+	parts := []unionPart{
+		{file: "sales/jan.csv", response: BrowseResponse{Columns: []string{"id", "Amount"}, Rows: [][]string{{"1", "10"}, {"2", "20"}}}},
+		{file: "sales/feb.csv", response: BrowseResponse{Columns: []string{"amount", "id", "region"}, Rows: [][]string{{"30", "3", "eu"}}}},
+		{file: "sales/mar.csv", response: BrowseResponse{Columns: []string{"id", "amount"}, Rows: [][]string{{"4", "40"}, {"5", "50"}}}},
+	}
+	columns := []string{"amount", "id", "region"}
+
+	got := unionPage(parts, columns, 1, 3)
+	want := [][]string{
+		{"sales/jan.csv", "20", "2", ""},
+		{"sales/feb.csv", "30", "3", "eu"},
+		{"sales/mar.csv", "40", "4", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unionPage = %q, want %q", got, want)
+	}
+
+	if got := unionPage(parts, columns, 4, 10); len(got) != 1 || got[0][2] != "5" {
+		t.Errorf("last page = %q", got)
+	}
+	if got := unionPage(parts, columns, 5, 10); got == nil || len(got) != 0 {
+		t.Errorf("page past the end = %#v", got)
+	}
+}
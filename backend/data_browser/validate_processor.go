@@ -0,0 +1,115 @@
+package data_browser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"bronze-backend/jobs"
+	"bronze-backend/storage"
+)
+
+// ValidateProcessor runs "validate" jobs: it evaluates a stored validation
+// suite against a file and writes a pass/fail report. A failing suite fails
+// the job, so on_success triggers (e.g. an export) only run for clean data.
+//
+// Job metadata:
+//   - suite:       name of the suite under validation/suites/ (required)
+//   - sheet_name:  sheet (Excel) or table (MDB) to validate
+//   - has_headers: whether the first row holds column names (default true)
+type ValidateProcessor struct {
+	browser     *DataBrowserHandler
+	minioClient *storage.MinIOClient
+}
+
+func NewValidateProcessor(minioClient *storage.MinIOClient) *ValidateProcessor {
+	return &ValidateProcessor{
+		browser:     NewDataBrowserHandler(minioClient),
+		minioClient: minioClient,
+	}
+}
+
+func (vp *ValidateProcessor) ProcessJob(ctx context.Context, job *jobs.Job) jobs.JobResult {
+	startTime := time.Now()
+
+	fail := func(format string, args ...any) jobs.JobResult {
+		return jobs.JobResult{
+			Success:        false,
+			ProcessingTime: time.Since(startTime),
+			Message:        fmt.Sprintf(format, args...),
+		}
+	}
+
+	if vp.minioClient == nil {
+		return fail("MinIO client not available")
+	}
+
+	suiteName, _ := job.Metadata["suite"].(string)
+	if suiteName == "" {
+		return fail("validation suite is required (metadata.suite)")
+	}
+
+	suite, err := vp.browser.LoadValidationSuite(ctx, suiteName)
+	if err != nil {
+		return fail("Failed to load validation suite: %v", err)
+	}
+	if err := suite.Validate(); err != nil {
+		return fail("Invalid validation suite: %v", err)
+	}
+
+	request := BrowseRequest{
+		FileName:   job.ObjectName,
+		MaxRows:    fullReadRows,
+		HasHeaders: true,
+	}
+	if sheet, ok := job.Metadata["sheet_name"].(string); ok {
+		request.SheetName = sheet
+	}
+	if hasHeaders, ok := job.Metadata["has_headers"].(bool); ok {
+		request.HasHeaders = hasHeaders
+	}
+	if treatAsCSV, ok := job.Metadata["treat_as_csv"].(bool); ok {
+		request.TreatAsCSV = treatAsCSV
+	}
+
+	log.Printf("Validating %s against suite %s for job %s", job.ObjectName, suite.Name, job.ID)
+
+	reader, _, err := vp.minioClient.DownloadFileCached(ctx, job.ObjectName, "")
+	if err != nil {
+		return fail("Failed to download file: %v", err)
+	}
+	data, err := jobs.ReadAll(ctx, reader)
+	reader.Close()
+	if err != nil {
+		return fail("Failed to read file: %v", err)
+	}
+
+	job.UpdateProgress(40)
+
+	parsed, err := vp.browser.readData(data, request)
+	if err != nil {
+		return fail("Failed to read source data: %v", err)
+	}
+
+	job.UpdateProgress(70)
+
+	report := RunValidationSuite(suite, parsed.Columns, parsed.Rows)
+	report.FileName = job.ObjectName
+	report.SheetName = parsed.SheetName
+
+	if err := jobs.SaveArtifact(ctx, vp.minioClient, job, "validation_report.json", report); err != nil {
+		log.Printf("Warning: Failed to upload validation report for job %s: %v", job.ID, err)
+	}
+
+	if !report.Passed {
+		return fail("Validation failed: %d of %d rules failed (%d warnings)", report.Errors, len(report.Rules), report.Warnings)
+	}
+
+	return jobs.JobResult{
+		Success:        true,
+		ProcessingTime: time.Since(startTime),
+		Message:        fmt.Sprintf("Validation passed: %d rules, %d warnings", len(report.Rules), report.Warnings),
+		Result:         report,
+	}
+}
@@ -0,0 +1,539 @@
+package data_browser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/minio/minio-go/v7"
+
+	"bronze-backend/httputil"
+	"bronze-backend/tenant"
+)
+
+// ValidationSuitePrefix is where validation suites are stored in MinIO, one
+// JSON document per suite at validation/suites/{name}.json, below the
+// tenant's prefix.
+const ValidationSuitePrefix = "validation/suites/"
+
+// Validation rule types
+const (
+	RuleColumnExists  = "column_exists"
+	RuleNotNull       = "not_null"
+	RuleUnique        = "unique"
+	RuleRegex         = "regex"
+	RuleRange         = "range"
+	RuleAllowedValues = "allowed_values"
+	RuleType          = "type"
+	RuleRowCount      = "row_count"
+	RuleMaxNullRatio  = "max_null_ratio"
+	// RuleReferences checks that the values of a column are in a column of
+	// another table. Only quality jobs load those values; see
+	// loadReferences.
+	RuleReferences = "references"
+)
+
+// maxFailureSamples bounds how many failing rows are reported per rule.
+const maxFailureSamples = 20
+
+type ValidationSuite struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Rules       []ValidationRule `json:"rules"`
+	UpdatedAt   time.Time        `json:"updated_at,omitempty"`
+}
+
+type ValidationRule struct {
+	Name     string   `json:"name,omitempty"`
+	Type     string   `json:"type"`
+	Column   string   `json:"column,omitempty"`
+	Pattern  string   `json:"pattern,omitempty"`   // regex
+	Min      *float64 `json:"min,omitempty"`       // range, row_count
+	Max      *float64 `json:"max,omitempty"`       // range, row_count, max_null_ratio
+	Values   []string `json:"values,omitempty"`    // allowed_values
+	DataType string   `json:"data_type,omitempty"` // type: integer, number, boolean, date
+	Severity string   `json:"severity,omitempty"`  // "error" (default) or "warning"
+	// RefDatabase, RefTable and RefColumn name the column a references
+	// rule checks against; RefDatabase defaults to NESSIE_DEFAULT_DB
+	RefDatabase string `json:"ref_database,omitempty"`
+	RefTable    string `json:"ref_table,omitempty"`
+	RefColumn   string `json:"ref_column,omitempty"`
+
+	refValues map[string]bool // The values of RefColumn, once loaded
+}
+
+type RuleResult struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Column     string `json:"column,omitempty"`
+	Severity   string `json:"severity"`
+	Passed     bool   `json:"passed"`
+	Failures   int    `json:"failures"`
+	SampleRows []int  `json:"sample_rows,omitempty"` // 1-based data row numbers
+	Message    string `json:"message"`
+}
+
+type ValidationReport struct {
+	Suite       string       `json:"suite"`
+	FileName    string       `json:"file_name"`
+	SheetName   string       `json:"sheet_name,omitempty"`
+	RowCount    int          `json:"row_count"`
+	Passed      bool         `json:"passed"`
+	Errors      int          `json:"errors"`
+	Warnings    int          `json:"warnings"`
+	Rules       []RuleResult `json:"rules"`
+	ValidatedAt time.Time    `json:"validated_at"`
+}
+
+// Validate checks the suite's rules before it is stored or run.
+func (s *ValidationSuite) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("suite name is required")
+	}
+	if strings.ContainsAny(s.Name, "/\\") {
+		return fmt.Errorf("suite name must not contain path separators")
+	}
+	for i, rule := range s.Rules {
+		switch rule.Type {
+		case RuleRowCount:
+		case RuleColumnExists, RuleNotNull, RuleUnique, RuleRange, RuleAllowedValues, RuleMaxNullRatio:
+			if rule.Column == "" {
+				return fmt.Errorf("rule %d (%s) requires a column", i+1, rule.Type)
+			}
+		case RuleRegex:
+			if rule.Column == "" || rule.Pattern == "" {
+				return fmt.Errorf("rule %d (regex) requires a column and pattern", i+1)
+			}
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				return fmt.Errorf("rule %d has an invalid pattern: %w", i+1, err)
+			}
+		case RuleReferences:
+			if rule.Column == "" || rule.RefTable == "" || rule.RefColumn == "" {
+				return fmt.Errorf("rule %d (references) requires a column, ref_table and ref_column", i+1)
+			}
+		case RuleType:
+			if rule.Column == "" {
+				return fmt.Errorf("rule %d (type) requires a column", i+1)
+			}
+			switch rule.DataType {
+			case "integer", "number", "boolean", "date":
+			default:
+				return fmt.Errorf("rule %d has unsupported data_type %q", i+1, rule.DataType)
+			}
+		default:
+			return fmt.Errorf("rule %d has unknown type %q", i+1, rule.Type)
+		}
+		if rule.Severity != "" && rule.Severity != "error" && rule.Severity != "warning" {
+			return fmt.Errorf("rule %d has invalid severity %q", i+1, rule.Severity)
+		}
+	}
+	return nil
+}
+
+// RunValidationSuite evaluates every rule of the suite against the data.
+// Column names are matched case-insensitively.
+func RunValidationSuite(suite ValidationSuite, columns []string, rows [][]string) ValidationReport {
+	report := ValidationReport{
+		Suite:       suite.Name,
+		RowCount:    len(rows),
+		Passed:      true,
+		Rules:       make([]RuleResult, 0, len(suite.Rules)),
+		ValidatedAt: time.Now(),
+	}
+
+	columnIndex := make(map[string]int, len(columns))
+	for i, column := range columns {
+		key := strings.ToLower(strings.TrimSpace(column))
+		if _, exists := columnIndex[key]; !exists {
+			columnIndex[key] = i
+		}
+	}
+
+	for _, rule := range suite.Rules {
+		result := evaluateRule(rule, columnIndex, rows)
+		if !result.Passed {
+			if result.Severity == "warning" {
+				report.Warnings++
+			} else {
+				report.Errors++
+				report.Passed = false
+			}
+		}
+		report.Rules = append(report.Rules, result)
+	}
+
+	return report
+}
+
+func evaluateRule(rule ValidationRule, columnIndex map[string]int, rows [][]string) RuleResult {
+	result := RuleResult{
+		Name:     rule.Name,
+		Type:     rule.Type,
+		Column:   rule.Column,
+		Severity: rule.Severity,
+		Passed:   true,
+	}
+	if result.Severity == "" {
+		result.Severity = "error"
+	}
+	if result.Name == "" {
+		result.Name = rule.Type
+		if rule.Column != "" {
+			result.Name += ":" + rule.Column
+		}
+	}
+
+	if rule.Type == RuleRowCount {
+		count := float64(len(rows))
+		if (rule.Min != nil && count < *rule.Min) || (rule.Max != nil && count > *rule.Max) {
+			result.Passed = false
+			result.Failures = 1
+			result.Message = fmt.Sprintf("row count %d outside %s", len(rows), describeBounds(rule.Min, rule.Max))
+		} else {
+			result.Message = fmt.Sprintf("row count %d", len(rows))
+		}
+		return result
+	}
+
+	column, exists := columnIndex[strings.ToLower(strings.TrimSpace(rule.Column))]
+	if !exists {
+		result.Passed = false
+		result.Failures = 1
+		result.Message = fmt.Sprintf("column %q not found", rule.Column)
+		return result
+	}
+	if rule.Type == RuleColumnExists {
+		result.Message = fmt.Sprintf("column %q present", rule.Column)
+		return result
+	}
+
+	value := func(row []string) string {
+		if column < len(row) {
+			return strings.TrimSpace(row[column])
+		}
+		return ""
+	}
+
+	fail := func(rowNumber int) {
+		result.Failures++
+		if len(result.SampleRows) < maxFailureSamples {
+			result.SampleRows = append(result.SampleRows, rowNumber)
+		}
+	}
+
+	switch rule.Type {
+	case RuleNotNull:
+		for i, row := range rows {
+			if value(row) == "" {
+				fail(i + 1)
+			}
+		}
+		result.Message = fmt.Sprintf("%d empty values", result.Failures)
+
+	case RuleMaxNullRatio:
+		nulls := 0
+		for i, row := range rows {
+			if value(row) == "" {
+				nulls++
+				if len(result.SampleRows) < maxFailureSamples {
+					result.SampleRows = append(result.SampleRows, i+1)
+				}
+			}
+		}
+		ratio := 0.0
+		if len(rows) > 0 {
+			ratio = float64(nulls) / float64(len(rows))
+		}
+		limit := 0.0
+		if rule.Max != nil {
+			limit = *rule.Max
+		}
+		if ratio > limit {
+			result.Failures = nulls
+		} else {
+			result.SampleRows = nil
+		}
+		result.Message = fmt.Sprintf("null ratio %.4f (max %.4f)", ratio, limit)
+
+	case RuleUnique:
+		seen := make(map[string]bool, len(rows))
+		for i, row := range rows {
+			v := value(row)
+			if v == "" {
+				continue
+			}
+			if seen[v] {
+				fail(i + 1)
+			}
+			seen[v] = true
+		}
+		result.Message = fmt.Sprintf("%d duplicate values", result.Failures)
+
+	case RuleRegex:
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			result.Passed = false
+			result.Failures = 1
+			result.Message = fmt.Sprintf("invalid pattern: %v", err)
+			return result
+		}
+		for i, row := range rows {
+			if v := value(row); v != "" && !pattern.MatchString(v) {
+				fail(i + 1)
+			}
+		}
+		result.Message = fmt.Sprintf("%d values do not match %s", result.Failures, rule.Pattern)
+
+	case RuleRange:
+		for i, row := range rows {
+			v := value(row)
+			if v == "" {
+				continue
+			}
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil || (rule.Min != nil && n < *rule.Min) || (rule.Max != nil && n > *rule.Max) {
+				fail(i + 1)
+			}
+		}
+		result.Message = fmt.Sprintf("%d values outside %s", result.Failures, describeBounds(rule.Min, rule.Max))
+
+	case RuleAllowedValues:
+		allowed := make(map[string]bool, len(rule.Values))
+		for _, v := range rule.Values {
+			allowed[v] = true
+		}
+		for i, row := range rows {
+			if v := value(row); v != "" && !allowed[v] {
+				fail(i + 1)
+			}
+		}
+		result.Message = fmt.Sprintf("%d values not in the allowed set", result.Failures)
+
+	case RuleReferences:
+		if rule.refValues == nil {
+			result.Passed = false
+			result.Failures = 1
+			result.Message = fmt.Sprintf("values of %s.%s not loaded; references are only checked against tables", rule.RefTable, rule.RefColumn)
+			return result
+		}
+		for i, row := range rows {
+			if v := value(row); v != "" && !rule.refValues[v] {
+				fail(i + 1)
+			}
+		}
+		result.Message = fmt.Sprintf("%d values not in %s.%s", result.Failures, rule.RefTable, rule.RefColumn)
+
+	case RuleType:
+		for i, row := range rows {
+			if v := value(row); v != "" && !matchesDataType(v, rule.DataType) {
+				fail(i + 1)
+			}
+		}
+		result.Message = fmt.Sprintf("%d values are not %s", result.Failures, rule.DataType)
+
+	default:
+		result.Passed = false
+		result.Failures = 1
+		result.Message = fmt.Sprintf("unknown rule type %q", rule.Type)
+		return result
+	}
+
+	result.Passed = result.Failures == 0
+	return result
+}
+
+var validationDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"01/02/2006",
+	"02/01/2006",
+	"2006/01/02",
+}
+
+func matchesDataType(value, dataType string) bool {
+	switch dataType {
+	case "integer":
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	case "number":
+		n, err := strconv.ParseFloat(value, 64)
+		return err == nil && !math.IsNaN(n)
+	case "boolean":
+		_, err := strconv.ParseBool(value)
+		return err == nil
+	case "date":
+		for _, layout := range validationDateLayouts {
+			if _, err := time.Parse(layout, value); err == nil {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+func describeBounds(min, max *float64) string {
+	switch {
+	case min != nil && max != nil:
+		return fmt.Sprintf("[%g, %g]", *min, *max)
+	case min != nil:
+		return fmt.Sprintf(">= %g", *min)
+	case max != nil:
+		return fmt.Sprintf("<= %g", *max)
+	default:
+		return "any"
+	}
+}
+
+// validationSuiteObject returns the object of the suite called name. Each
+// tenant keeps its suites below its own prefix.
+func validationSuiteObject(ctx context.Context, name string) string {
+	return tenant.Prefix(ctx) + ValidationSuitePrefix + name + ".json"
+}
+
+// LoadValidationSuite reads a stored suite from MinIO.
+func (h *DataBrowserHandler) LoadValidationSuite(ctx context.Context, name string) (ValidationSuite, error) {
+	var suite ValidationSuite
+
+	reader, err := h.minioClient.DownloadFile(ctx, validationSuiteObject(ctx, name))
+	if err != nil {
+		return suite, fmt.Errorf("failed to open suite %s: %w", name, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return suite, fmt.Errorf("failed to read suite %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return suite, fmt.Errorf("failed to decode suite %s: %w", name, err)
+	}
+	if suite.Name == "" {
+		suite.Name = name
+	}
+
+	return suite, nil
+}
+
+func (h *DataBrowserHandler) ListValidationSuites(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	prefix := tenant.Prefix(ctx) + ValidationSuitePrefix
+	bucket, err := h.minioClient.Scope(ctx, prefix)
+	if err != nil {
+		httputil.WriteError(w, "Failed to list validation suites", http.StatusInternalServerError, err)
+		return
+	}
+
+	suites := make([]string, 0)
+	client := h.minioClient.GetClient()
+	for object := range client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			httputil.WriteError(w, "Failed to list validation suites", http.StatusInternalServerError, object.Err)
+			return
+		}
+		if strings.HasSuffix(object.Key, ".json") {
+			suites = append(suites, strings.TrimSuffix(path.Base(object.Key), ".json"))
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Validation suites listed successfully",
+		"suites":  suites,
+		"count":   len(suites),
+	})
+}
+
+func (h *DataBrowserHandler) GetValidationSuite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	suite, err := h.LoadValidationSuite(r.Context(), name)
+	if err != nil {
+		httputil.WriteCode(w, httputil.CodeSuiteNotFound, "Validation suite not found", err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Validation suite retrieved successfully",
+		"suite":   suite,
+	})
+}
+
+func (h *DataBrowserHandler) SaveValidationSuite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var suite ValidationSuite
+	if err := json.NewDecoder(r.Body).Decode(&suite); err != nil {
+		httputil.WriteError(w, "Failed to decode request", http.StatusBadRequest, err)
+		return
+	}
+
+	suite.Name = mux.Vars(r)["name"]
+	if err := suite.Validate(); err != nil {
+		httputil.WriteError(w, "Invalid validation suite", http.StatusBadRequest, err)
+		return
+	}
+	suite.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		httputil.WriteError(w, "Failed to encode validation suite", http.StatusInternalServerError, err)
+		return
+	}
+
+	if _, err := h.minioClient.UploadFile(r.Context(), validationSuiteObject(r.Context(), suite.Name), bytes.NewReader(data), int64(len(data)), "application/json"); err != nil {
+		httputil.WriteError(w, "Failed to save validation suite", http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Validation suite saved successfully",
+		"suite":   suite,
+	})
+}
+
+func (h *DataBrowserHandler) DeleteValidationSuite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if err := h.minioClient.DeleteFile(r.Context(), validationSuiteObject(r.Context(), name)); err != nil {
+		httputil.WriteError(w, "Failed to delete validation suite", http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Validation suite deleted successfully",
+	})
+}
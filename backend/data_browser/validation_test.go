@@ -0,0 +1,96 @@
+package data_browser
+
+import "testing"
+
+func TestRunValidationSuite(t *testing.T) {
+	minAge, maxAge := 0.0, 120.0
+	minRows := 5.0
+
+	suite := ValidationSuite{
+		Name: "customers",
+		Rules: []ValidationRule{
+			{Type: RuleNotNull, Column: "id"},
+			{Type: RuleUnique, Column: "ID"},
+			{Type: RuleRegex, Column: "email", Pattern: `^[^@]+@[^@]+$`},
+			{Type: RuleRange, Column: "age", Min: &minAge, Max: &maxAge},
+			{Type: RuleAllowedValues, Column: "status", Values: []string{"active", "inactive"}},
+			{Type: RuleType, Column: "age", DataType: "integer"},
+			{Type: RuleRowCount, Min: &minRows, Severity: "warning"},
+			{Type: RuleColumnExists, Column: "missing"},
+		},
+	}
+	if err := suite.Validate(); err != nil {
+		t.Fatalf("Suite should be valid: %v", err)
+	}
+
+	columns := []string{"id", "email", "age", "status"}
+	rows := [][]string{
+		{"1", "a@example.com", "30", "active"},
+		{"2", "not-an-email", "200", "inactive"},
+		{"2", "c@example.com", "", "deleted"},
+		{"", "d@example.com", "x", "active"},
+	}
+
+	report := RunValidationSuite(suite, columns, rows)
+	if report.Passed {
+		t.Fatal("Expected report to fail")
+	}
+	if report.Warnings != 1 {
+		t.Errorf("Expected 1 warning, got %d", report.Warnings)
+	}
+
+	expected := map[string]int{
+		"not_null:id":           1,
+		"unique:ID":             1,
+		"regex:email":           1,
+		"range:age":             2,
+		"allowed_values:status": 1,
+		"type:age":              1,
+		"row_count":             1,
+		"column_exists:missing": 1,
+	}
+	for _, result := range report.Rules {
+		want, ok := expected[result.Name]
+		if !ok {
+			t.Errorf("Unexpected rule result %s", result.Name)
+			continue
+		}
+		if result.Failures != want {
+			t.Errorf("Rule %s: expected %d failures, got %d (%s)", result.Name, want, result.Failures, result.Message)
+		}
+	}
+}
+
+func TestValidationSuiteValidate(t *testing.T) {
+	invalid := []ValidationSuite{
+		{Name: ""},
+		{Name: "a/b"},
+		{Name: "bad", Rules: []ValidationRule{{Type: "nope"}}},
+		{Name: "bad", Rules: []ValidationRule{{Type: RuleRegex, Column: "x", Pattern: "("}}},
+		{Name: "bad", Rules: []ValidationRule{{Type: RuleType, Column: "x", DataType: "uuid"}}},
+		{Name: "bad", Rules: []ValidationRule{{Type: RuleReferences, Column: "x", RefTable: "t"}}},
+	}
+	for _, suite := range invalid {
+		if err := suite.Validate(); err == nil {
+			t.Errorf("Expected suite %+v to be invalid", suite)
+		}
+	}
+}
+
+func TestReferencesRule(t *testing.T) {
+	rule := ValidationRule{Type: RuleReferences, Column: "customer_id", RefTable: "customers", RefColumn: "id"}
+	columns := []string{"order_id", "customer_id"}
+	rows := [][]string{{"1", "c1"}, {"2", "c9"}, {"3", ""}}
+
+	// Without the referenced values, as in validate jobs, the rule fails
+	report := RunValidationSuite(ValidationSuite{Name: "orders", Rules: []ValidationRule{rule}}, columns, rows)
+	if report.Passed {
+		t.Error("references rule passed without the referenced values")
+	}
+
+	rule.refValues = map[string]bool{"c1": true, "c2": true}
+	report = RunValidationSuite(ValidationSuite{Name: "orders", Rules: []ValidationRule{rule}}, columns, rows)
+	if result := report.Rules[0]; result.Failures != 1 || len(result.SampleRows) != 1 || result.SampleRows[0] != 2 {
+		t.Errorf("references rule = %+v", result)
+	}
+}
@@ -0,0 +1,187 @@
+package data_browser
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"bronze-backend/httputil"
+)
+
+// FileVersion is a version of a file in a bucket with versioning on.
+type FileVersion struct {
+	VersionID    string    `json:"version_id"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	ETag         string    `json:"etag"`
+	IsLatest     bool      `json:"is_latest"`
+}
+
+// ListFileVersions answers the versions of ?file_name=, newest first, to
+// browse with version_id.
+func (h *DataBrowserHandler) ListFileVersions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	fileName := r.URL.Query().Get("file_name")
+	if fileName == "" {
+		httputil.WriteError(w, "file_name is required", http.StatusBadRequest, nil)
+		return
+	}
+
+	enabled, err := h.minioClient.VersioningEnabled(ctx)
+	if err != nil {
+		httputil.WriteError(w, "Failed to get bucket versioning", http.StatusInternalServerError, err)
+		return
+	}
+	objects, err := h.minioClient.ListFileVersions(ctx, fileName)
+	if err != nil {
+		httputil.WriteError(w, "Failed to list file versions", http.StatusInternalServerError, err)
+		return
+	}
+	if len(objects) == 0 {
+		httputil.WriteCode(w, httputil.CodeFileNotFound, "file not found", nil)
+		return
+	}
+
+	versions := make([]FileVersion, 0, len(objects))
+	for _, object := range objects {
+		versions = append(versions, FileVersion{
+			VersionID:    object.VersionID,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+			ETag:         object.ETag,
+			IsLatest:     object.IsLatest,
+		})
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success":            true,
+		"file_name":          fileName,
+		"versioning_enabled": enabled,
+		"versions":           versions,
+		"count":              len(versions),
+	})
+}
+
+// VersionDiff is how the latest version of a file differs from an earlier
+// one. Rows are compared whole, on Columns, so a changed row is one
+// removed and one added; rows of columns one version lacks read as empty.
+type VersionDiff struct {
+	LatestVersionID string     `json:"latest_version_id,omitempty"`
+	Columns         []string   `json:"columns"`
+	ColumnsAdded    []string   `json:"columns_added,omitempty"`
+	ColumnsRemoved  []string   `json:"columns_removed,omitempty"`
+	Added           int        `json:"added"`
+	Removed         int        `json:"removed"`
+	Unchanged       int        `json:"unchanged"`
+	AddedRows       [][]string `json:"added_rows"`
+	RemovedRows     [][]string `json:"removed_rows"`
+	// Truncated is set when there were more rows to list than max_rows
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// diffAgainstLatest diffs the whole of data, the version of the file
+// request browses, against the latest version, listing up to
+// request.MaxRows added and removed rows.
+func (h *DataBrowserHandler) diffAgainstLatest(ctx context.Context, data []byte, request BrowseRequest) (*VersionDiff, error) {
+	full := request
+	full.Offset, full.MaxRows = 0, fullReadRows
+	version, err := h.readData(data, full)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := h.minioClient.GetFileInfo(ctx, request.FileName)
+	if err != nil {
+		return nil, err
+	}
+	latestData, err := h.downloadVersion(ctx, request.FileName, info.VersionID)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := h.readData(latestData, full)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := diffRows(version.Columns, version.Rows, latest.Columns, latest.Rows, request.MaxRows)
+	diff.LatestVersionID = info.VersionID
+	return diff, nil
+}
+
+// diffRows compares the rows of two versions as multisets, on the columns
+// of either, latest first. Up to limit added and removed rows are listed.
+func diffRows(oldColumns []string, oldRows [][]string, newColumns []string, newRows [][]string, limit int) *VersionDiff {
+	diff := &VersionDiff{AddedRows: [][]string{}, RemovedRows: [][]string{}}
+	oldIndex := columnPositions(oldColumns)
+	newIndex := columnPositions(newColumns)
+	diff.Columns = append(diff.Columns, newColumns...)
+	for _, column := range newColumns {
+		if _, ok := oldIndex[column]; !ok {
+			diff.ColumnsAdded = append(diff.ColumnsAdded, column)
+		}
+	}
+	for _, column := range oldColumns {
+		if _, ok := newIndex[column]; !ok {
+			diff.ColumnsRemoved = append(diff.ColumnsRemoved, column)
+			diff.Columns = append(diff.Columns, column)
+		}
+	}
+
+	project := func(index map[string]int, row []string) []string {
+		projected := make([]string, len(diff.Columns))
+		for i, column := range diff.Columns {
+			if j, ok := index[column]; ok && j < len(row) {
+				projected[i] = row[j]
+			}
+		}
+		return projected
+	}
+	key := func(row []string) string { return strings.Join(row, "\x1f") }
+
+	// Rows of the old version not yet matched by a row of the latest
+	remaining := make(map[string]int)
+	for _, row := range oldRows {
+		remaining[key(project(oldIndex, row))]++
+	}
+	for _, row := range newRows {
+		projected := project(newIndex, row)
+		k := key(projected)
+		if remaining[k] > 0 {
+			remaining[k]--
+			diff.Unchanged++
+			continue
+		}
+		diff.Added++
+		if len(diff.AddedRows) < limit {
+			diff.AddedRows = append(diff.AddedRows, projected)
+		}
+	}
+	for _, row := range oldRows {
+		projected := project(oldIndex, row)
+		k := key(projected)
+		if remaining[k] == 0 {
+			continue
+		}
+		remaining[k]--
+		diff.Removed++
+		if len(diff.RemovedRows) < limit {
+			diff.RemovedRows = append(diff.RemovedRows, projected)
+		}
+	}
+	diff.Truncated = diff.Added > len(diff.AddedRows) || diff.Removed > len(diff.RemovedRows)
+	return diff
+}
+
+// columnPositions maps column names to their position; a repeated name
+// maps to its first.
+func columnPositions(columns []string) map[string]int {
+	index := make(map[string]int, len(columns))
+	for i, column := range columns {
+		if _, ok := index[column]; !ok {
+			index[column] = i
+		}
+	}
+	return index
+}
@@ -0,0 +1,35 @@
+package data_browser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffRows(t *testing.T) {
+	oldColumns := []string{"id", "name", "note"}
+	oldRows := [][]string{{"1", "Ann", "x"}, {"2", "Bob", ""}, {"2", "Bob", ""}, {"3", "Cy", "y"}}
+	newColumns := []string{"id", "name", "email"}
+	newRows := [][]string{{"1", "Ann", ""}, {"2", "Bob", ""}, {"4", "Di", "di@example.com"}}
+
+	diff := diffRows(oldColumns, oldRows, newColumns, newRows, 10)
+	want := &VersionDiff{
+		Columns:        []string{"id", "name", "email", "note"},
+		ColumnsAdded:   []string{"email"},
+		ColumnsRemoved: []string{"note"},
+		Added:          2,
+		Removed:        3,
+		Unchanged:      1,
+		AddedRows:      [][]string{{"1", "Ann", "", ""}, {"4", "Di", "di@example.com", ""}},
+		RemovedRows:    [][]string{{"1", "Ann", "", "x"}, {"2", "Bob", "", ""}, {"3", "Cy", "", "y"}},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("diffRows =\n%+v\nwant\n%+v", diff, want)
+	}
+
+	if diff := diffRows(oldColumns, oldRows, newColumns, newRows, 1); !diff.Truncated || len(diff.AddedRows) != 1 || diff.Added != 2 {
+		t.Errorf("limited diff = %+v", diff)
+	}
+	if diff := diffRows(oldColumns, oldRows, oldColumns, oldRows, 10); diff.Added != 0 || diff.Removed != 0 || diff.Unchanged != 4 {
+		t.Errorf("diff of a version with itself = %+v", diff)
+	}
+}
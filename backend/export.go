@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"strings"
+
+	"bronze-backend/data_browser"
+	"bronze-backend/storage"
+)
+
+// export runs one export to Nessie and prints the result as JSON. The export
+// comes from a preset in EXPORT_PRESETS_FILE, from flags, or from a preset
+// with flags overriding its fields.
+func export(args []string) error {
+	fs, opts := newFlagSet("export", "")
+	preset := fs.String("preset", "", "`name` of the export preset to run")
+	table := fs.String("table", "", "table to export to")
+	operation := fs.String("operation", "", "create or append")
+	database := fs.String("database", "", "database of the table, default NESSIE_DEFAULT_DB")
+	formulaMode := fs.String("formula-mode", "", "what Excel formula cells export as: cached, formula or evaluate")
+	normalizeDates := fs.Bool("normalize-dates", false, "export Excel dates as ISO 8601 rather than serial numbers")
+	trim := fs.Bool("trim-whitespace", false, "trim leading and trailing whitespace from values")
+	collapse := fs.Bool("collapse-whitespace", false, "replace runs of whitespace in values with one space")
+	var nullTokens []string
+	fs.Func("null-token", "`value` to export as NULL, e.g. NA; repeatable", func(value string) error {
+		nullTokens = append(nullTokens, value)
+		return nil
+	})
+	normalizeNames := fs.Bool("normalize-column-names", false, "export columns under snake_case names that need no quoting")
+	var excluded []string
+	fs.Func("exclude-column", "source `column` to leave out; repeatable", func(value string) error {
+		excluded = append(excluded, value)
+		return nil
+	})
+	renames := make(map[string]string)
+	fs.Func("rename-column", "`source=target` column rename; repeatable", func(value string) error {
+		source, target, ok := strings.Cut(value, "=")
+		if !ok || source == "" || target == "" {
+			return fmt.Errorf("want source=target, got %q", value)
+		}
+		renames[source] = target
+		return nil
+	})
+	var dedupeKeys []string
+	fs.Func("dedupe-key", "`column` to drop duplicate rows on, or * for whole rows; repeatable", func(value string) error {
+		dedupeKeys = append(dedupeKeys, value)
+		return nil
+	})
+	transactional := fs.Bool("transactional", false, "commit nothing unless every file exports")
+	stopOnError := fs.Bool("stop-on-error", false, "stop at the first file that fails and roll back")
+	var fileNames []string
+	fs.Func("file", "object to export; repeatable, replaces the preset's files", func(value string) error {
+		fileNames = append(fileNames, value)
+		return nil
+	})
+	noHeaders := fs.Bool("no-headers", false, "read the first row of the --file objects as data, not column names")
+	var headers []string
+	fs.Func("header", "column `name` of the --file objects, in order; repeatable", func(value string) error {
+		headers = append(headers, value)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := opts.load()
+	if err != nil {
+		return err
+	}
+
+	var request data_browser.ExportRequest
+	if *preset != "" {
+		request, err = data_browser.LoadExportPreset(cfg.Nessie.PresetsFile, *preset)
+		if err != nil {
+			return err
+		}
+	}
+	if *table != "" {
+		request.TableName = *table
+	}
+	if *operation != "" {
+		request.Operation = *operation
+	}
+	if *database != "" {
+		request.Database = *database
+	}
+	if *formulaMode != "" {
+		request.FormulaMode = *formulaMode
+	}
+	if *normalizeDates {
+		request.NormalizeDates = true
+	}
+	if *trim {
+		request.TrimWhitespace = true
+	}
+	if *collapse {
+		request.CollapseWhitespace = true
+	}
+	if len(nullTokens) > 0 {
+		request.NullTokens = nullTokens
+	}
+	if *normalizeNames {
+		request.NormalizeColumnNames = true
+	}
+	if len(excluded) > 0 {
+		request.ExcludeColumns = excluded
+	}
+	if len(renames) > 0 {
+		if request.RenameColumns == nil {
+			request.RenameColumns = make(map[string]string)
+		}
+		maps.Copy(request.RenameColumns, renames)
+	}
+	if len(dedupeKeys) > 0 {
+		request.DedupeKeys = dedupeKeys
+	}
+	if *transactional {
+		request.Transactional = true
+	}
+	if *stopOnError {
+		request.StopOnError = true
+	}
+	if len(fileNames) > 0 {
+		request.Files = make([]data_browser.FileExportInfo, len(fileNames))
+		for i, name := range fileNames {
+			request.Files[i] = data_browser.FileExportInfo{FileName: name, NoHeaders: *noHeaders, Headers: headers}
+		}
+	}
+
+	storageClient, err := storage.NewMinIOClient(&cfg.MinIO)
+	if err != nil {
+		return fmt.Errorf("failed to create MinIO client: %w", err)
+	}
+	nessieClient, err := storage.NewNessieClient(&cfg.Nessie)
+	if err != nil {
+		return fmt.Errorf("failed to create Nessie client: %w", err)
+	}
+	dataBrowserHandler := data_browser.NewDataBrowserHandler(storageClient)
+	conversionRates, err := cfg.Processing.ParseConversionRates()
+	if err != nil {
+		return err
+	}
+	dataBrowserHandler.SetConversionRates(conversionRates)
+	exportHandler := data_browser.NewExportHandler(storageClient, nessieClient, cfg, dataBrowserHandler)
+
+	log.Printf("Exporting %d files to %s", len(request.Files), request.TableName)
+	response, err := exportHandler.Export(context.Background(), request)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(response); err != nil {
+		return err
+	}
+	if !response.Success {
+		return errors.New(response.Message)
+	}
+	return nil
+}
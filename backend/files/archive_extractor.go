@@ -2,25 +2,43 @@ package files
 
 import (
 	"archive/tar"
-	"archive/zip"
-	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"bronze-backend/jobs"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/nwaples/rardecode/v2"
+	"github.com/yeka/zip"
+)
+
+var (
+	// ErrPasswordRequired is returned when an archive is encrypted and no
+	// password was supplied.
+	ErrPasswordRequired = errors.New("archive is password protected, password required")
+	// ErrPasswordProtectedDisabled is returned for encrypted archives when
+	// PASSWORD_PROTECTED is off.
+	ErrPasswordProtectedDisabled = errors.New("password-protected archives are disabled")
 )
 
 type ArchiveExtractor struct {
 	config DecompressionConfig
+	quota  *jobs.Quota // Budget of the job extracting, or nil
 }
 
+// DecompressionConfig controls extraction. Zero values for the limits mean
+// unlimited.
 type DecompressionConfig struct {
-	MaxExtractSize     string
-	MaxFilesPerArchive int
-	NestedArchiveDepth int
-	PasswordProtected  bool
-	ExtractToSubfolder bool
+	MaxExtractSize      string
+	MaxFilesPerArchive  int
+	MaxCompressionRatio float64
+	NestedArchiveDepth  int
+	PasswordProtected   bool
+	ExtractToSubfolder  bool
 }
 
 func NewArchiveExtractor(config DecompressionConfig) *ArchiveExtractor {
@@ -29,6 +47,14 @@ func NewArchiveExtractor(config DecompressionConfig) *ArchiveExtractor {
 	}
 }
 
+// WithQuota returns an extractor counting the files ExtractArchive writes
+// against the temp disk budget of a job.
+func (d *ArchiveExtractor) WithQuota(quota *jobs.Quota) *ArchiveExtractor {
+	extractor := *d
+	extractor.quota = quota
+	return &extractor
+}
+
 type ArchiveInfo struct {
 	Format      string         `json:"format"`
 	IsArchive   bool           `json:"is_archive"`
@@ -45,6 +71,12 @@ type ExtractionResult struct {
 	FileCount      int         `json:"file_count"`
 	Message        string      `json:"message"`
 	ArchiveInfo    ArchiveInfo `json:"archive_info"`
+	// Lineage maps each file extracted from a nested archive to the chain
+	// of nested archives it came from, outermost first. Files taken
+	// directly from the top-level archive have no entry.
+	Lineage     map[string][]string `json:"lineage,omitempty"`
+	NestedCount int                 `json:"nested_count,omitempty"`
+	Warnings    []string            `json:"warnings,omitempty"`
 }
 
 func (d *ArchiveExtractor) DetectArchive(filePath string) (ArchiveInfo, error) {
@@ -82,9 +114,28 @@ func (d *ArchiveExtractor) DetectArchive(filePath string) (ArchiveInfo, error) {
 	info.Metadata["extension"] = ext
 	info.Metadata["base_name"] = baseName
 
+	if format == "zip" {
+		info.HasPassword = zipHasPassword(filePath)
+	}
+
 	return info, nil
 }
 
+func zipHasPassword(filePath string) bool {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return false
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if file.IsEncrypted() {
+			return true
+		}
+	}
+	return false
+}
+
 func (d *ArchiveExtractor) ExtractArchive(filePath, outputDir string, password string) (ExtractionResult, error) {
 	result := ExtractionResult{}
 
@@ -103,6 +154,14 @@ func (d *ArchiveExtractor) ExtractArchive(filePath, outputDir string, password s
 		return result, fmt.Errorf("file is not an archive")
 	}
 
+	budget, err := newExtractionBudget(d.config, info.TotalSize)
+	if err != nil {
+		result.Success = false
+		result.Message = err.Error()
+		return result, err
+	}
+	budget.quota = d.quota
+
 	extractDir := outputDir
 	if d.config.ExtractToSubfolder {
 		baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
@@ -115,21 +174,132 @@ func (d *ArchiveExtractor) ExtractArchive(filePath, outputDir string, password s
 		return result, err
 	}
 
-	extractedFiles, err := d.extractFiles(filePath, extractDir, password)
+	extractedFiles, err := d.extractFiles(filePath, extractDir, password, budget)
 	if err != nil {
 		result.Success = false
 		result.Message = fmt.Sprintf("Failed to extract archive: %v", err)
 		return result, err
 	}
 
+	if d.config.NestedArchiveDepth > 0 {
+		result.Lineage = make(map[string][]string)
+		nested, err := d.extractNested(extractedFiles, nil, 1, password, budget, &result)
+		if err != nil {
+			result.Success = false
+			result.Message = fmt.Sprintf("Failed to extract nested archive: %v", err)
+			return result, err
+		}
+		extractedFiles = append(extractedFiles, nested...)
+	}
+
 	result.Success = true
 	result.ExtractedFiles = extractedFiles
 	result.FileCount = len(extractedFiles)
 	result.Message = fmt.Sprintf("Successfully extracted %d files", len(extractedFiles))
+	if result.NestedCount > 0 {
+		result.Message += fmt.Sprintf(" (%d nested archives)", result.NestedCount)
+	}
 
 	return result, nil
 }
 
+// extractNested extracts archives found among files, recursing until the
+// configured nesting depth is reached. A nested archive that cannot be
+// extracted is kept as a plain file and reported as a warning, unless it
+// tripped a safety limit, which fails the extraction.
+func (d *ArchiveExtractor) extractNested(files []string, chain []string, depth int, password string, budget *extractionBudget, result *ExtractionResult) ([]string, error) {
+	if depth > d.config.NestedArchiveDepth {
+		return nil, nil
+	}
+
+	var extracted []string
+	for _, filePath := range files {
+		if !d.isExtractable(filePath) {
+			continue
+		}
+
+		nestedDir := nestedExtractDir(filePath)
+		if err := os.MkdirAll(nestedDir, 0755); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: %v", filepath.Base(filePath), err))
+			continue
+		}
+
+		inner, err := d.extractFiles(filePath, nestedDir, password, budget)
+		if isLimitError(err) {
+			return extracted, err
+		}
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("failed to extract nested archive %s: %v", filepath.Base(filePath), err))
+			continue
+		}
+		result.NestedCount++
+
+		innerChain := append(append([]string(nil), chain...), filePath)
+		for _, innerFile := range inner {
+			result.Lineage[innerFile] = innerChain
+		}
+
+		extracted = append(extracted, inner...)
+		deeper, err := d.extractNested(inner, innerChain, depth+1, password, budget, result)
+		extracted = append(extracted, deeper...)
+		if err != nil {
+			return extracted, err
+		}
+	}
+
+	return extracted, nil
+}
+
+// nestedExtractDir picks the directory a nested archive is extracted into:
+// its path without the archive extension, e.g. data/inner.tar.gz becomes
+// data/inner, unless something already exists there.
+func nestedExtractDir(filePath string) string {
+	name := trimArchiveExt(filepath.Base(filePath))
+
+	dir := filepath.Join(filepath.Dir(filePath), name)
+	if _, err := os.Stat(dir); err == nil || name == "" {
+		dir = filePath + "_extracted"
+	}
+	return dir
+}
+
+// trimArchiveExt strips the archive extension, including compound ones like
+// .tar.gz, from a file name.
+func trimArchiveExt(name string) string {
+	lower := strings.ToLower(name)
+	for _, suffix := range compoundArchiveSuffixes {
+		if strings.HasSuffix(lower, suffix) {
+			return name[:len(name)-len(suffix)]
+		}
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// isExtractable reports whether extractFiles can handle the file.
+func (d *ArchiveExtractor) isExtractable(filePath string) bool {
+	return extractableFormats[d.formatOf(filePath)]
+}
+
+// extractableFormats are the formats extractFiles implements.
+var extractableFormats = map[string]bool{
+	"zip":     true,
+	"tar":     true,
+	"gzip":    true,
+	"tar.gz":  true,
+	"bzip2":   true,
+	"tar.bz2": true,
+	"xz":      true,
+	"tar.xz":  true,
+	"zstd":    true,
+	"tar.zst": true,
+	"7z":      true,
+	"rar":     true,
+}
+
+// compoundArchiveSuffixes take precedence over the plain extension, so
+// data.tar.gz is a tar.gz rather than a gzip file.
+var compoundArchiveSuffixes = []string{".tar.gz", ".tar.bz2", ".tar.xz", ".tar.zst"}
+
 func (d *ArchiveExtractor) getArchiveFormat(ext, baseName string) (string, bool) {
 	archiveFormats := map[string]string{
 		".zip":     "zip",
@@ -143,43 +313,51 @@ func (d *ArchiveExtractor) getArchiveFormat(ext, baseName string) (string, bool)
 		".xz":      "xz",
 		".txz":     "tar.xz",
 		".tar.xz":  "tar.xz",
+		".zst":     "zstd",
+		".tzst":    "tar.zst",
+		".tar.zst": "tar.zst",
+		".7z":      "7z",
+		".rar":     "rar",
 	}
 
-	if format, exists := archiveFormats[ext]; exists {
-		return format, true
+	for _, suffix := range compoundArchiveSuffixes {
+		if strings.HasSuffix(baseName, suffix) {
+			return archiveFormats[suffix], true
+		}
 	}
 
-	for ext := range archiveFormats {
-		if strings.HasSuffix(baseName, ext) {
-			return archiveFormats[ext], true
-		}
+	if format, exists := archiveFormats[ext]; exists {
+		return format, true
 	}
 
 	return "", false
 }
 
-func (d *ArchiveExtractor) extractFiles(filePath, outputDir, password string) ([]string, error) {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	baseName := strings.ToLower(filepath.Base(filePath))
+func (d *ArchiveExtractor) formatOf(filePath string) string {
+	format, _ := d.getArchiveFormat(strings.ToLower(filepath.Ext(filePath)), strings.ToLower(filepath.Base(filePath)))
+	return format
+}
 
-	var extractedFiles []string
-	var err error
-
-	switch {
-	case ext == ".zip":
-		extractedFiles, err = d.extractZip(filePath, outputDir, password)
-	case ext == ".tar":
-		extractedFiles, err = d.extractTar(filePath, outputDir)
-	case ext == ".gz" || strings.HasSuffix(baseName, ".tar.gz"):
-		extractedFiles, err = d.extractTarGz(filePath, outputDir)
+func (d *ArchiveExtractor) extractFiles(filePath, outputDir, password string, budget *extractionBudget) ([]string, error) {
+	format := d.formatOf(filePath)
+
+	switch format {
+	case "zip":
+		return d.extractZip(filePath, outputDir, password, budget)
+	case "tar":
+		return d.extractTar(filePath, outputDir, budget)
+	case "gzip", "tar.gz", "bzip2", "tar.bz2", "xz", "tar.xz", "zstd", "tar.zst":
+		return d.extractCompressed(filePath, outputDir, format, budget)
+	case "7z":
+		return d.extract7z(filePath, outputDir, password, budget)
+	case "rar":
+		return d.extractRar(filePath, outputDir, password, budget)
 	default:
-		return nil, fmt.Errorf("unsupported archive format: %s", ext)
+		return nil, fmt.Errorf("unsupported archive format: %s", filepath.Ext(filePath))
 	}
-
-	return extractedFiles, err
 }
 
-func (d *ArchiveExtractor) extractZip(filePath, outputDir, password string) ([]string, error) {
+func (d *ArchiveExtractor) extractZip(filePath, outputDir, password string, budget *extractionBudget) ([]string, error) {
 	var extractedFiles []string
 
 	reader, err := zip.OpenReader(filePath)
@@ -193,28 +371,25 @@ func (d *ArchiveExtractor) extractZip(filePath, outputDir, password string) ([]s
 			continue
 		}
 
-		outputPath := filepath.Join(outputDir, file.Name)
-
-		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-			return nil, err
-		}
-
-		outputFile, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, file.Mode())
-		if err != nil {
-			return nil, err
+		if file.IsEncrypted() {
+			if err := d.checkPassword(password); err != nil {
+				return nil, err
+			}
+			file.SetPassword(password)
 		}
 
 		fileReader, err := file.Open()
 		if err != nil {
-			outputFile.Close()
 			return nil, err
 		}
 
-		_, err = io.Copy(outputFile, fileReader)
+		outputPath, err := budget.extract(outputDir, file.Name, file.Mode(), fileReader)
 		fileReader.Close()
-		outputFile.Close()
 
 		if err != nil {
+			if file.IsEncrypted() && !isLimitError(err) {
+				return nil, fmt.Errorf("failed to decrypt %s, wrong password?: %w", file.Name, err)
+			}
 			return nil, err
 		}
 
@@ -224,16 +399,20 @@ func (d *ArchiveExtractor) extractZip(filePath, outputDir, password string) ([]s
 	return extractedFiles, nil
 }
 
-func (d *ArchiveExtractor) extractTar(filePath, outputDir string) ([]string, error) {
-	var extractedFiles []string
-
+func (d *ArchiveExtractor) extractTar(filePath, outputDir string, budget *extractionBudget) ([]string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
-	reader := tar.NewReader(file)
+	return d.extractTarStream(tar.NewReader(file), outputDir, budget)
+}
+
+// extractTarStream writes the regular files of a tar stream. Links and
+// special files are skipped so they cannot point outside outputDir.
+func (d *ArchiveExtractor) extractTarStream(reader *tar.Reader, outputDir string, budget *extractionBudget) ([]string, error) {
+	var extractedFiles []string
 
 	for {
 		header, err := reader.Next()
@@ -244,27 +423,68 @@ func (d *ArchiveExtractor) extractTar(filePath, outputDir string) ([]string, err
 			return nil, err
 		}
 
-		if header.Typeflag == tar.TypeDir {
+		if !header.FileInfo().Mode().IsRegular() {
 			continue
 		}
 
-		outputPath := filepath.Join(outputDir, header.Name)
-
-		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		outputPath, err := budget.extract(outputDir, header.Name, os.FileMode(header.Mode), reader)
+		if err != nil {
 			return nil, err
 		}
 
-		outputFile, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
+		extractedFiles = append(extractedFiles, outputPath)
+	}
+
+	return extractedFiles, nil
+}
+
+// checkPassword rejects encrypted entries when no password is available or
+// password-protected archives are disabled.
+func (d *ArchiveExtractor) checkPassword(password string) error {
+	if !d.config.PasswordProtected {
+		return ErrPasswordProtectedDisabled
+	}
+	if password == "" {
+		return ErrPasswordRequired
+	}
+	return nil
+}
+
+func (d *ArchiveExtractor) extract7z(filePath, outputDir, password string, budget *extractionBudget) ([]string, error) {
+	var extractedFiles []string
+
+	if password != "" && !d.config.PasswordProtected {
+		return nil, ErrPasswordProtectedDisabled
+	}
+
+	reader, err := sevenzip.OpenReaderWithPassword(filePath, password)
+	if err != nil {
+		return nil, d.wrap7zError(fmt.Errorf("failed to open 7z archive: %w", err))
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		fileReader, err := file.Open()
 		if err != nil {
 			return nil, err
 		}
 
-		_, err = io.Copy(outputFile, reader)
-		outputFile.Close()
+		outputPath, err := budget.extract(outputDir, file.Name, file.Mode(), fileReader)
+		fileReader.Close()
 
-		if err != nil {
+		if isLimitError(err) {
 			return nil, err
 		}
+		if err != nil {
+			if password != "" {
+				return nil, fmt.Errorf("failed to decrypt %s, wrong password?: %w", file.Name, err)
+			}
+			return nil, d.wrap7zError(err)
+		}
 
 		extractedFiles = append(extractedFiles, outputPath)
 	}
@@ -272,22 +492,23 @@ func (d *ArchiveExtractor) extractTar(filePath, outputDir string) ([]string, err
 	return extractedFiles, nil
 }
 
-func (d *ArchiveExtractor) extractTarGz(filePath, outputDir string) ([]string, error) {
+func (d *ArchiveExtractor) extractRar(filePath, outputDir, password string, budget *extractionBudget) ([]string, error) {
 	var extractedFiles []string
 
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
+	if password != "" && !d.config.PasswordProtected {
+		return nil, ErrPasswordProtectedDisabled
 	}
-	defer file.Close()
 
-	gzReader, err := gzip.NewReader(file)
-	if err != nil {
-		return nil, err
+	var options []rardecode.Option
+	if password != "" {
+		options = append(options, rardecode.Password(password))
 	}
-	defer gzReader.Close()
 
-	reader := tar.NewReader(gzReader)
+	reader, err := rardecode.OpenReader(filePath, options...)
+	if err != nil {
+		return nil, d.wrapRarError(err)
+	}
+	defer reader.Close()
 
 	for {
 		header, err := reader.Next()
@@ -295,28 +516,18 @@ func (d *ArchiveExtractor) extractTarGz(filePath, outputDir string) ([]string, e
 			break
 		}
 		if err != nil {
-			return nil, err
+			return nil, d.wrapRarError(err)
 		}
 
-		if header.Typeflag == tar.TypeDir {
+		if header.IsDir {
 			continue
 		}
 
-		outputPath := filepath.Join(outputDir, header.Name)
-
-		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-			return nil, err
-		}
-
-		outputFile, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
-		if err != nil {
-			return nil, err
-		}
-
-		_, err = io.Copy(outputFile, reader)
-		outputFile.Close()
-
+		outputPath, err := budget.extract(outputDir, header.Name, header.Mode(), reader)
 		if err != nil {
+			if header.Encrypted && !isLimitError(err) {
+				return nil, fmt.Errorf("failed to decrypt %s, wrong password?: %w", header.Name, err)
+			}
 			return nil, err
 		}
 
@@ -326,8 +537,55 @@ func (d *ArchiveExtractor) extractTarGz(filePath, outputDir string) ([]string, e
 	return extractedFiles, nil
 }
 
+// wrap7zError maps the 7z reader's missing password error, which is not
+// exported, onto ErrPasswordRequired.
+func (d *ArchiveExtractor) wrap7zError(err error) error {
+	if !strings.Contains(err.Error(), "no password set") {
+		return err
+	}
+	if !d.config.PasswordProtected {
+		return fmt.Errorf("%w: %v", ErrPasswordProtectedDisabled, err)
+	}
+	return fmt.Errorf("%w: %v", ErrPasswordRequired, err)
+}
+
+func (d *ArchiveExtractor) wrapRarError(err error) error {
+	if !errors.Is(err, rardecode.ErrArchiveEncrypted) && !errors.Is(err, rardecode.ErrArchivedFileEncrypted) {
+		return err
+	}
+	if !d.config.PasswordProtected {
+		return fmt.Errorf("%w: %v", ErrPasswordProtectedDisabled, err)
+	}
+	return fmt.Errorf("%w: %v", ErrPasswordRequired, err)
+}
+
+// writeExtractedFile copies an archive entry to outputPath, creating parent
+// directories as needed.
+func writeExtractedFile(outputPath string, mode os.FileMode, reader io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return err
+	}
+
+	perm := mode.Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+
+	outputFile, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(outputFile, reader)
+	if closeErr := outputFile.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
 func (d *ArchiveExtractor) GetSupportedFormats() []string {
 	return []string{
-		"zip", "tar", "tar.gz",
+		"zip", "tar", "tar.gz", "tar.bz2", "tar.xz", "tar.zst",
+		"gzip", "bzip2", "xz", "zstd", "7z", "rar",
 	}
 }
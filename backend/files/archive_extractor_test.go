@@ -0,0 +1,352 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	yekazip "github.com/yeka/zip"
+)
+
+func buildZip(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, content := range files {
+		entry, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+		if _, err := entry.Write(content); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractArchiveNested(t *testing.T) {
+	innermost := buildZip(t, map[string][]byte{"deep.txt": []byte("deep")})
+	inner := buildZip(t, map[string][]byte{"inner.txt": []byte("inner"), "level2.zip": innermost})
+	outer := buildZip(t, map[string][]byte{"top.txt": []byte("top"), "nested/level1.zip": inner})
+
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "outer.zip")
+	if err := os.WriteFile(archivePath, outer, 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+
+	tests := []struct {
+		depth     int
+		wantFiles int
+		wantDeep  bool
+	}{
+		{depth: 0, wantFiles: 2},
+		{depth: 1, wantFiles: 4},
+		{depth: 2, wantFiles: 5, wantDeep: true},
+	}
+
+	for _, tt := range tests {
+		extractor := NewArchiveExtractor(DecompressionConfig{NestedArchiveDepth: tt.depth})
+		outputDir := filepath.Join(dir, "out", string(rune('0'+tt.depth)))
+
+		result, err := extractor.ExtractArchive(archivePath, outputDir, "")
+		if err != nil {
+			t.Fatalf("depth %d: extraction failed: %v", tt.depth, err)
+		}
+		if result.FileCount != tt.wantFiles {
+			t.Errorf("depth %d: expected %d files, got %d: %v", tt.depth, tt.wantFiles, result.FileCount, result.ExtractedFiles)
+		}
+
+		deepPath := filepath.Join(outputDir, "nested", "level1", "level2", "deep.txt")
+		_, statErr := os.Stat(deepPath)
+		if tt.wantDeep != (statErr == nil) {
+			t.Errorf("depth %d: deep.txt present = %v, want %v", tt.depth, statErr == nil, tt.wantDeep)
+		}
+		if tt.wantDeep {
+			chain := result.Lineage[deepPath]
+			if len(chain) != 2 || filepath.Base(chain[0]) != "level1.zip" || filepath.Base(chain[1]) != "level2.zip" {
+				t.Errorf("depth %d: unexpected lineage for deep.txt: %v", tt.depth, chain)
+			}
+		}
+	}
+}
+
+func TestExtractArchivePasswordProtectedZip(t *testing.T) {
+	var buf bytes.Buffer
+	writer := yekazip.NewWriter(&buf)
+	entry, err := writer.Encrypt("secret.txt", "hunter2", yekazip.AES256Encryption)
+	if err != nil {
+		t.Fatalf("Failed to add encrypted entry: %v", err)
+	}
+	if _, err := entry.Write([]byte("classified")); err != nil {
+		t.Fatalf("Failed to write encrypted entry: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "secret.zip")
+	if err := os.WriteFile(archivePath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+
+	extractor := NewArchiveExtractor(DecompressionConfig{PasswordProtected: true})
+
+	info, err := extractor.DetectArchive(archivePath)
+	if err != nil {
+		t.Fatalf("DetectArchive failed: %v", err)
+	}
+	if !info.HasPassword {
+		t.Error("Expected archive to be detected as password protected")
+	}
+
+	if _, err := extractor.ExtractArchive(archivePath, filepath.Join(dir, "none"), ""); !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("Expected ErrPasswordRequired without password, got %v", err)
+	}
+	if _, err := extractor.ExtractArchive(archivePath, filepath.Join(dir, "wrong"), "wrong"); err == nil {
+		t.Error("Expected extraction with the wrong password to fail")
+	}
+
+	outputDir := filepath.Join(dir, "ok")
+	if _, err := extractor.ExtractArchive(archivePath, outputDir, "hunter2"); err != nil {
+		t.Fatalf("Extraction with password failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(outputDir, "secret.txt"))
+	if err != nil || string(content) != "classified" {
+		t.Errorf("Unexpected extracted content %q (%v)", content, err)
+	}
+
+	disabled := NewArchiveExtractor(DecompressionConfig{PasswordProtected: false})
+	if _, err := disabled.ExtractArchive(archivePath, filepath.Join(dir, "disabled"), "hunter2"); !errors.Is(err, ErrPasswordProtectedDisabled) {
+		t.Errorf("Expected ErrPasswordProtectedDisabled, got %v", err)
+	}
+}
+
+func TestExtractArchiveRejectsPathTraversal(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "evil.zip")
+	archive := buildZip(t, map[string][]byte{"../../escape.txt": []byte("gotcha")})
+	if err := os.WriteFile(archivePath, archive, 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+
+	outputDir := filepath.Join(dir, "out", "nested")
+	extractor := NewArchiveExtractor(DecompressionConfig{})
+	if _, err := extractor.ExtractArchive(archivePath, outputDir, ""); !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("Expected ErrUnsafePath, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.txt")); err == nil {
+		t.Error("Entry was written outside the extraction directory")
+	}
+}
+
+func TestExtractArchiveLimits(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "bomb.zip")
+	archive := buildZip(t, map[string][]byte{
+		"a.txt": bytes.Repeat([]byte("a"), 64*1024),
+		"b.txt": bytes.Repeat([]byte("b"), 64*1024),
+	})
+	if err := os.WriteFile(archivePath, archive, 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		config DecompressionConfig
+		want   error
+	}{
+		{name: "unlimited", config: DecompressionConfig{}},
+		{name: "size", config: DecompressionConfig{MaxExtractSize: "100KB"}, want: ErrExtractSizeLimit},
+		{name: "files", config: DecompressionConfig{MaxFilesPerArchive: 1}, want: ErrFileCountLimit},
+		{name: "ratio", config: DecompressionConfig{MaxCompressionRatio: 10}, want: ErrCompressionRatio},
+	}
+
+	for _, tt := range tests {
+		extractor := NewArchiveExtractor(tt.config)
+		_, err := extractor.ExtractArchive(archivePath, filepath.Join(dir, tt.name), "")
+		if tt.want == nil && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestExtractStream(t *testing.T) {
+	content := map[string][]byte{
+		"top.txt":         []byte("top"),
+		"dir/nested.txt":  bytes.Repeat([]byte("n"), 3*streamBlockSize/2),
+		"dir/another.txt": []byte("another"),
+	}
+
+	var tarBuf bytes.Buffer
+	gzWriter := gzip.NewWriter(&tarBuf)
+	tarWriter := tar.NewWriter(gzWriter)
+	for name, data := range content {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if _, err := tarWriter.Write(data); err != nil {
+			t.Fatalf("Failed to write tar entry: %v", err)
+		}
+	}
+	tarWriter.Close()
+	gzWriter.Close()
+
+	archives := map[string][]byte{
+		"data.zip":    buildZip(t, content),
+		"data.tar.gz": tarBuf.Bytes(),
+	}
+
+	for name, archive := range archives {
+		extractor := NewArchiveExtractor(DecompressionConfig{})
+		written := make(map[string][]byte)
+
+		entries, err := extractor.ExtractStream(name, bytes.NewReader(archive), int64(len(archive)), "", func(entry string, reader io.Reader, size int64) error {
+			data, err := io.ReadAll(reader)
+			if err != nil {
+				return err
+			}
+			if int64(len(data)) != size {
+				t.Errorf("%s: %s declared %d bytes, read %d", name, entry, size, len(data))
+			}
+			written[entry] = data
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: streaming extraction failed: %v", name, err)
+		}
+		if len(entries) != len(content) {
+			t.Errorf("%s: expected %d entries, got %d", name, len(content), len(entries))
+		}
+		for entry, data := range content {
+			if !bytes.Equal(written[entry], data) {
+				t.Errorf("%s: content mismatch for %s", name, entry)
+			}
+		}
+	}
+
+	evil := buildZip(t, map[string][]byte{"../evil.txt": []byte("x")})
+	extractor := NewArchiveExtractor(DecompressionConfig{})
+	_, err := extractor.ExtractStream("evil.zip", bytes.NewReader(evil), int64(len(evil)), "", func(string, io.Reader, int64) error {
+		t.Error("Unsafe entry was handed to the writer")
+		return nil
+	})
+	if !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("Expected ErrUnsafePath, got %v", err)
+	}
+}
+
+func TestExtractArchiveCompressedFormats(t *testing.T) {
+	payload := bytes.Repeat([]byte("bronze,data\n"), 100)
+
+	var tarBuf bytes.Buffer
+	tarWriter := tar.NewWriter(&tarBuf)
+	tarWriter.WriteHeader(&tar.Header{Name: "inside/table.csv", Mode: 0644, Size: int64(len(payload)), Typeflag: tar.TypeReg})
+	tarWriter.Write(payload)
+	tarWriter.Close()
+
+	compress := map[string]func(io.Writer) io.WriteCloser{
+		"gz": func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"xz": func(w io.Writer) io.WriteCloser {
+			writer, err := xz.NewWriter(w)
+			if err != nil {
+				t.Fatalf("Failed to create xz writer: %v", err)
+			}
+			return writer
+		},
+		"zst": func(w io.Writer) io.WriteCloser {
+			writer, err := zstd.NewWriter(w)
+			if err != nil {
+				t.Fatalf("Failed to create zstd writer: %v", err)
+			}
+			return writer
+		},
+	}
+
+	dir := t.TempDir()
+	for ext, newWriter := range compress {
+		cases := map[string]struct {
+			data []byte
+			want string
+		}{
+			"report.csv." + ext: {data: payload, want: "report.csv"},
+			"bundle.tar." + ext: {data: tarBuf.Bytes(), want: filepath.Join("inside", "table.csv")},
+			// A tarball without .tar in its name is still recognised
+			"bundle." + ext: {data: tarBuf.Bytes(), want: filepath.Join("inside", "table.csv")},
+		}
+
+		for name, tc := range cases {
+			var buf bytes.Buffer
+			writer := newWriter(&buf)
+			writer.Write(tc.data)
+			writer.Close()
+
+			archivePath := filepath.Join(dir, name)
+			if err := os.WriteFile(archivePath, buf.Bytes(), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+
+			outputDir := filepath.Join(dir, "out-"+name)
+			result, err := NewArchiveExtractor(DecompressionConfig{}).ExtractArchive(archivePath, outputDir, "")
+			if err != nil {
+				t.Errorf("%s: extraction failed: %v", name, err)
+				continue
+			}
+			if result.FileCount != 1 {
+				t.Errorf("%s: expected 1 file, got %v", name, result.ExtractedFiles)
+			}
+			content, err := os.ReadFile(filepath.Join(outputDir, tc.want))
+			if err != nil || !bytes.Equal(content, payload) {
+				t.Errorf("%s: unexpected content at %s (%v)", name, tc.want, err)
+			}
+		}
+	}
+}
+
+func TestListEntries(t *testing.T) {
+	content := map[string][]byte{
+		"a.txt":     bytes.Repeat([]byte("a"), 1000),
+		"dir/b.txt": []byte("b"),
+	}
+	archive := buildZip(t, content)
+
+	preview, err := NewArchiveExtractor(DecompressionConfig{}).ListEntries("data.zip", bytes.NewReader(archive), int64(len(archive)), "", 0)
+	if err != nil {
+		t.Fatalf("ListEntries failed: %v", err)
+	}
+	if preview.Format != "zip" || preview.FileCount != 2 || preview.TotalSize != 1001 {
+		t.Errorf("Unexpected preview: %+v", preview)
+	}
+	for _, entry := range preview.Entries {
+		if entry.Size != int64(len(content[entry.Name])) {
+			t.Errorf("Entry %s: expected size %d, got %d", entry.Name, len(content[entry.Name]), entry.Size)
+		}
+		if entry.Name == "a.txt" && entry.CompressedSize >= entry.Size {
+			t.Errorf("Expected a.txt to be compressed, got %d bytes", entry.CompressedSize)
+		}
+	}
+
+	truncated, err := NewArchiveExtractor(DecompressionConfig{}).ListEntries("data.zip", bytes.NewReader(archive), int64(len(archive)), "", 1)
+	if err != nil {
+		t.Fatalf("ListEntries failed: %v", err)
+	}
+	if !truncated.Truncated || len(truncated.Entries) != 1 || truncated.FileCount != 2 {
+		t.Errorf("Expected a truncated listing with full totals, got %+v", truncated)
+	}
+}
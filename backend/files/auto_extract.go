@@ -0,0 +1,90 @@
+package files
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"bronze-backend/config"
+	"bronze-backend/jobs"
+)
+
+// Job metadata read by extract jobs.
+const (
+	// MetadataExtractPrefix and MetadataCleanup are set only by AUTO_EXTRACT
+	// rules; see jobs.MetadataExtractPrefix
+	MetadataExtractPrefix = jobs.MetadataExtractPrefix
+	MetadataCleanup       = jobs.MetadataCleanup
+	// MetadataAutoExtract records the AUTO_EXTRACT prefix a job was created
+	// for
+	MetadataAutoExtract = "auto_extract"
+)
+
+// AutoExtractJob returns an extract job for the object key if it is an
+// archive under the prefix of an AUTO_EXTRACT rule, or nil. The longest
+// matching prefix wins.
+func (fp *FileProcessor) AutoExtractJob(bucket, key, etag string) *jobs.Job {
+	fp.mu.RLock()
+	rules, decompressor := fp.autoExtract, fp.decompressor
+	fp.mu.RUnlock()
+
+	if decompressor.formatOf(key) == "" {
+		return nil
+	}
+
+	var match *config.AutoExtractRule
+	for i, rule := range rules {
+		if strings.HasPrefix(key, rule.Prefix) && (match == nil || len(rule.Prefix) > len(match.Prefix)) {
+			match = &rules[i]
+		}
+	}
+	if match == nil {
+		return nil
+	}
+
+	job := jobs.NewJob("extract", key, bucket, key, jobs.PriorityMedium)
+	job.ETag = etag
+	job.Metadata[MetadataAutoExtract] = match.Prefix
+	if match.Destination != "" {
+		job.Metadata[MetadataExtractPrefix] = match.Destination
+	}
+	if match.Cleanup == config.CleanupDelete {
+		job.Metadata[MetadataCleanup] = config.CleanupDelete
+	}
+	return job
+}
+
+// EnqueueAutoExtract enqueues an auto-extract job unless a job for the same
+// content exists, returning the job queued or found, or nil on failure. An
+// upload also seen by the watcher is therefore extracted once.
+func EnqueueAutoExtract(queue jobs.Queue, job *jobs.Job) *jobs.Job {
+	queued, duplicate, err := jobs.EnqueueUnique(queue, job, false)
+	switch {
+	case err != nil:
+		log.Printf("Failed to enqueue auto-extract job for %s: %v", job.ObjectName, err)
+		return nil
+	case duplicate:
+		return queued
+	}
+	log.Printf("Created auto-extract job %s for %s", queued.ID, job.ObjectName)
+	return queued
+}
+
+// cleanupArchive deletes the archive of a successful extract job whose
+// cleanup metadata asks for it.
+func (fp *FileProcessor) cleanupArchive(ctx context.Context, job *jobs.Job, result *jobs.JobResult) {
+	if cleanup, _ := job.Metadata[MetadataCleanup].(string); cleanup != config.CleanupDelete || fp.minioClient == nil {
+		return
+	}
+	// Only archives that were extracted have an extract prefix
+	if _, extracted := result.FileInfo["extract_prefix"]; !extracted {
+		return
+	}
+
+	if err := fp.minioClient.DeleteFile(ctx, job.ObjectName); err != nil {
+		log.Printf("Warning: Failed to delete archive %s after extracting it: %v", job.ObjectName, err)
+		return
+	}
+	result.FileInfo["archive_deleted"] = true
+	log.Printf("Deleted archive %s after extracting it for job %s", job.ObjectName, job.ID)
+}
@@ -0,0 +1,50 @@
+package files
+
+import (
+	"context"
+	"testing"
+
+	"bronze-backend/config"
+	"bronze-backend/jobs"
+)
+
+func TestAutoExtractJob(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Processing.Decompression.AutoExtract = "incoming/=extracted:delete,incoming/raw/"
+	fp := NewFileProcessor(cfg, nil)
+
+	if job := fp.AutoExtractJob("lake", "incoming/report.csv", "e1"); job != nil {
+		t.Errorf("a CSV file got an extract job")
+	}
+	if job := fp.AutoExtractJob("lake", "other/data.zip", "e1"); job != nil {
+		t.Errorf("an archive outside the prefixes got an extract job")
+	}
+
+	job := fp.AutoExtractJob("lake", "incoming/data.tar.gz", "e1")
+	if job == nil || job.Type != "extract" || job.Bucket != "lake" || job.ETag != "e1" {
+		t.Fatalf("job = %+v", job)
+	}
+	if job.Metadata[MetadataExtractPrefix] != "extracted" || job.Metadata[MetadataCleanup] != config.CleanupDelete {
+		t.Errorf("metadata = %v", job.Metadata)
+	}
+	if prefix := fp.extractPrefix(context.Background(), job); prefix != "extracted/data.tar.gz/" {
+		t.Errorf("extract prefix = %q", prefix)
+	}
+
+	// The longest prefix wins
+	job = fp.AutoExtractJob("lake", "incoming/raw/data.zip", "e2")
+	if job == nil || job.Metadata[MetadataAutoExtract] != "incoming/raw/" || job.Metadata[MetadataCleanup] != nil {
+		t.Fatalf("job = %+v", job)
+	}
+	if prefix := fp.extractPrefix(context.Background(), job); prefix != "incoming/raw/data.zip/extracted/" {
+		t.Errorf("extract prefix = %q", prefix)
+	}
+
+	// The same upload seen by the watcher is not extracted twice
+	queue := jobs.NewJobQueue(1, 10)
+	first := EnqueueAutoExtract(queue, job)
+	again := EnqueueAutoExtract(queue, fp.AutoExtractJob("lake", "incoming/raw/data.zip", "e2"))
+	if first == nil || again == nil || again.ID != first.ID || len(queue.ListJobs()) != 1 {
+		t.Errorf("enqueued %d jobs, want 1", len(queue.ListJobs()))
+	}
+}
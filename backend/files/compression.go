@@ -0,0 +1,90 @@
+package files
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// decompressReader wraps r in the decompressor for a gzip, bzip2, xz or
+// zstd stream, or for the compression layer of the matching tar format.
+func decompressReader(format string, r io.Reader) (io.ReadCloser, error) {
+	switch strings.TrimPrefix(format, "tar.") {
+	case "gzip", "gz":
+		return gzip.NewReader(r)
+	case "bzip2", "bz2":
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	case "xz":
+		reader, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(reader), nil
+	case "zstd", "zst":
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression format: %s", format)
+	}
+}
+
+// isTarStream reports whether the decompressed stream holds a tar archive,
+// by looking for the ustar magic in the first header block. This lets
+// data.gz that is really a tarball extract as one.
+func isTarStream(reader *bufio.Reader) bool {
+	header, err := reader.Peek(512)
+	if err != nil {
+		return false
+	}
+	return bytes.HasPrefix(header[257:], []byte("ustar"))
+}
+
+// extractCompressed extracts a compressed tarball, or decompresses a single
+// compressed file to its name without the compression extension.
+func (d *ArchiveExtractor) extractCompressed(filePath, outputDir, format string, budget *extractionBudget) ([]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decompressed, err := decompressReader(format, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s stream: %w", format, err)
+	}
+	defer decompressed.Close()
+
+	reader := bufio.NewReaderSize(decompressed, 64*1024)
+	if isTarStream(reader) {
+		return d.extractTarStream(tar.NewReader(reader), outputDir, budget)
+	}
+
+	outputPath, err := budget.extract(outputDir, decompressedName(filePath), 0644, reader)
+	if err != nil {
+		return nil, err
+	}
+	return []string{outputPath}, nil
+}
+
+// decompressedName is the name of a single compressed file once
+// decompressed: report.csv.gz becomes report.csv.
+func decompressedName(filePath string) string {
+	name := trimArchiveExt(filepath.Base(filePath))
+	if name == "" {
+		name = "data"
+	}
+	return name
+}
@@ -0,0 +1,120 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bronze-backend/config"
+	"bronze-backend/jobs"
+)
+
+var (
+	ErrUnsafePath       = errors.New("archive entry escapes the extraction directory")
+	ErrExtractSizeLimit = errors.New("archive exceeds the maximum extract size")
+	ErrFileCountLimit   = errors.New("archive exceeds the maximum number of files")
+	ErrCompressionRatio = errors.New("archive exceeds the maximum compression ratio")
+)
+
+// extractionBudget tracks one extraction, nested archives included, against
+// the configured limits. A zero limit is unlimited. Entry paths are always
+// confined to the extraction directory, whatever the limits.
+type extractionBudget struct {
+	maxBytes    int64
+	maxFiles    int
+	maxRatio    float64
+	archiveSize int64
+	// quota is the temp disk budget of the job, for extractions to disk
+	quota *jobs.Quota
+
+	bytes int64
+	files int
+}
+
+func newExtractionBudget(limits DecompressionConfig, archiveSize int64) (*extractionBudget, error) {
+	maxBytes, err := config.ParseByteSize(limits.MaxExtractSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid max extract size: %w", err)
+	}
+
+	return &extractionBudget{
+		maxBytes:    maxBytes,
+		maxFiles:    limits.MaxFilesPerArchive,
+		maxRatio:    limits.MaxCompressionRatio,
+		archiveSize: archiveSize,
+	}, nil
+}
+
+// safeJoin joins an archive entry name onto outputDir, rejecting absolute
+// names and names that climb out of it (zip-slip).
+func safeJoin(outputDir, name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+
+	outputPath := filepath.Join(outputDir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(outputDir, outputPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	return outputPath, nil
+}
+
+// extract writes one archive entry below outputDir and returns its path.
+func (b *extractionBudget) extract(outputDir, name string, mode os.FileMode, reader io.Reader) (string, error) {
+	outputPath, err := safeJoin(outputDir, name)
+	if err != nil {
+		return "", err
+	}
+
+	b.files++
+	if b.maxFiles > 0 && b.files > b.maxFiles {
+		return "", fmt.Errorf("%w (%d)", ErrFileCountLimit, b.maxFiles)
+	}
+
+	if err := writeExtractedFile(outputPath, mode, &budgetReader{reader: reader, budget: b}); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	return outputPath, nil
+}
+
+func (b *extractionBudget) consume(n int) error {
+	b.bytes += int64(n)
+	if b.maxBytes > 0 && b.bytes > b.maxBytes {
+		return fmt.Errorf("%w (%d bytes)", ErrExtractSizeLimit, b.maxBytes)
+	}
+	if b.maxRatio > 0 && b.archiveSize > 0 && float64(b.bytes)/float64(b.archiveSize) > b.maxRatio {
+		return fmt.Errorf("%w (%.0f:1)", ErrCompressionRatio, b.maxRatio)
+	}
+	return b.quota.UseTempDisk(int64(n))
+}
+
+// budgetReader stops the copy as soon as a limit is crossed, so a zip bomb
+// never gets written out in full.
+type budgetReader struct {
+	reader io.Reader
+	budget *extractionBudget
+}
+
+func (r *budgetReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if limitErr := r.budget.consume(n); limitErr != nil {
+			return n, limitErr
+		}
+	}
+	return n, err
+}
+
+// isLimitError reports whether err came from a safety check. Such errors fail
+// the whole extraction rather than being downgraded to a warning.
+func isLimitError(err error) bool {
+	return errors.Is(err, ErrUnsafePath) || errors.Is(err, ErrExtractSizeLimit) ||
+		errors.Is(err, ErrFileCountLimit) || errors.Is(err, ErrCompressionRatio) ||
+		errors.Is(err, jobs.ErrQuotaExceeded)
+}
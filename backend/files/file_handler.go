@@ -11,10 +11,15 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"bronze-backend/auth"
+	"bronze-backend/httputil"
 	"bronze-backend/jobs"
+	"bronze-backend/metering"
 	"bronze-backend/storage"
+	"bronze-backend/tenant"
 
 	"github.com/gorilla/mux"
 	"github.com/minio/minio-go/v7"
@@ -25,26 +30,49 @@ type FileHandler struct {
 	processor   interface {
 		ProcessJob(ctx context.Context, job *jobs.Job) jobs.JobResult
 	}
-	jobQueue *jobs.JobQueue
+	jobQueue jobs.Queue
+	usage    *metering.Tracker
+
+	uploadMemory atomic.Int64 // Bytes of an upload held in memory before spilling to disk
 }
 
+// defaultUploadMemory is the upload memory of a FileHandler until
+// SetUploadMemory is called.
+const defaultUploadMemory = 32 << 20
+
 func NewFileHandler(minioClient *storage.MinIOClient, fileProcessor interface {
 	ProcessJob(ctx context.Context, job *jobs.Job) jobs.JobResult
 }) *FileHandler {
-	return &FileHandler{
+	h := &FileHandler{
 		minioClient: minioClient,
 		processor:   fileProcessor,
 	}
+	h.uploadMemory.Store(defaultUploadMemory)
+	return h
 }
 
 func NewFileHandlerWithQueue(minioClient *storage.MinIOClient, fileProcessor interface {
 	ProcessJob(ctx context.Context, job *jobs.Job) jobs.JobResult
-}, jobQueue *jobs.JobQueue) *FileHandler {
-	return &FileHandler{
+}, jobQueue jobs.Queue) *FileHandler {
+	h := &FileHandler{
 		minioClient: minioClient,
 		processor:   fileProcessor,
 		jobQueue:    jobQueue,
 	}
+	h.uploadMemory.Store(defaultUploadMemory)
+	return h
+}
+
+// SetUploadMemory sets how many bytes of an upload are held in memory; the
+// rest is buffered in temporary files until it is stored.
+func (h *FileHandler) SetUploadMemory(n int64) {
+	h.uploadMemory.Store(n)
+}
+
+// SetUsage accounts uploads to their caller and enforces the upload and job
+// time quotas.
+func (h *FileHandler) SetUsage(tracker *metering.Tracker) {
+	h.usage = tracker
 }
 
 // Multi-folder request for browsing multiple directories at once
@@ -116,22 +144,22 @@ type BatchListResponse struct {
 
 func (h *FileHandler) BatchListFiles(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Check bucket status first
 	log.Printf("BatchListFiles handler: checking bucket status")
-	bucketOk, bucketMsg := h.checkBucketStatus()
+	bucketOk, bucketMsg := h.checkBucketStatus(r.Context())
 	log.Printf("BatchListFiles handler: bucketOk=%v, bucketMsg=%s", bucketOk, bucketMsg)
 	if !bucketOk {
-		h.writeError(w, bucketMsg, http.StatusServiceUnavailable, fmt.Errorf("bucket not accessible"))
+		httputil.WriteCode(w, httputil.CodeBucketUnavailable, bucketMsg, fmt.Errorf("bucket not accessible"))
 		return
 	}
 
 	var req BatchListRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeError(w, "Invalid JSON", http.StatusBadRequest, err)
+		httputil.WriteError(w, "Invalid JSON", http.StatusBadRequest, err)
 		return
 	}
 
@@ -240,11 +268,14 @@ type UploadResponse struct {
 	ObjectName string `json:"object_name"`
 	Size       int64  `json:"size"`
 	ETag       string `json:"etag"`
+	// ExtractJobID is the extract job created for an archive uploaded
+	// under an AUTO_EXTRACT prefix
+	ExtractJobID string `json:"extract_job_id,omitempty"`
 }
 
 func (h *FileHandler) MultiFolderBrowse(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -255,7 +286,7 @@ func (h *FileHandler) MultiFolderBrowse(w http.ResponseWriter, r *http.Request)
 // SSE streaming for folder browsing
 func (h *FileHandler) streamFolderBrowse(w http.ResponseWriter, r *http.Request) {
 	// Check bucket status first
-	bucketOk, bucketMsg := h.checkBucketStatus()
+	bucketOk, bucketMsg := h.checkBucketStatus(r.Context())
 	if !bucketOk {
 		h.writeSSEError(w, bucketMsg, http.StatusServiceUnavailable, fmt.Errorf("bucket not accessible"))
 		return
@@ -284,7 +315,7 @@ func (h *FileHandler) streamFolderBrowse(w http.ResponseWriter, r *http.Request)
 	// Create a flusher for real-time updates
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		httputil.Error(w, "Streaming unsupported", http.StatusInternalServerError)
 		return
 	}
 
@@ -365,12 +396,7 @@ func (h *FileHandler) writeSSEEvent(w http.ResponseWriter, event string, data st
 }
 
 func (h *FileHandler) writeSSEError(w http.ResponseWriter, message string, code int, err error) {
-	errorData := map[string]interface{}{
-		"error":   message,
-		"code":    code,
-		"details": err.Error(),
-	}
-	errorJSON, _ := json.Marshal(errorData)
+	errorJSON, _ := json.Marshal(browseError(message, code, err))
 	fmt.Fprintf(w, "event: error\n")
 	fmt.Fprintf(w, "data: %s\n\n", string(errorJSON))
 }
@@ -515,19 +541,23 @@ func (h *FileHandler) processFolder(ctx context.Context, folderReq FolderRequest
 
 func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	err := r.ParseMultipartForm(32 << 20) // 32MB max memory
+	if !h.usage.Allow(w, r, metering.MetricUpload) {
+		return
+	}
+
+	err := r.ParseMultipartForm(h.uploadMemory.Load())
 	if err != nil {
-		h.writeError(w, "Failed to parse multipart form", http.StatusBadRequest, err)
+		httputil.WriteError(w, "Failed to parse multipart form", http.StatusBadRequest, err)
 		return
 	}
 
 	file, header, err := r.FormFile("file")
 	if err != nil {
-		h.writeError(w, "Failed to get file from form", http.StatusBadRequest, err)
+		httputil.WriteError(w, "Failed to get file from form", http.StatusBadRequest, err)
 		return
 	}
 	defer file.Close()
@@ -544,14 +574,14 @@ func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 
 	objectName = filepath.Clean(objectName)
 	if strings.HasPrefix(objectName, "/") || strings.Contains(objectName, "..") {
-		h.writeError(w, "Invalid object name", http.StatusBadRequest, nil)
+		httputil.WriteCode(w, httputil.CodeInvalidObjectName, "Invalid object name", nil)
 		return
 	}
 
 	// Check bucket status first
-	bucketOk, bucketMsg := h.checkBucketStatus()
+	bucketOk, bucketMsg := h.checkBucketStatus(r.Context())
 	if !bucketOk {
-		h.writeError(w, bucketMsg, http.StatusServiceUnavailable, fmt.Errorf("bucket not accessible"))
+		httputil.WriteCode(w, httputil.CodeBucketUnavailable, bucketMsg, fmt.Errorf("bucket not accessible"))
 		return
 	}
 
@@ -560,9 +590,10 @@ func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 
 	uploadInfo, err := h.minioClient.UploadFile(ctx, objectName, file, header.Size, contentType)
 	if err != nil {
-		h.writeError(w, "Failed to upload file", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to upload file", http.StatusInternalServerError, err)
 		return
 	}
+	h.usage.Add(auth.Subject(r.Context()), metering.MetricUpload, uploadInfo.Size)
 
 	response := UploadResponse{
 		Success:    true,
@@ -572,12 +603,23 @@ func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 		ETag:       uploadInfo.ETag,
 	}
 
+	if fp, ok := h.processor.(*FileProcessor); ok && h.jobQueue != nil {
+		if job := fp.AutoExtractJob(h.minioClient.Bucket(r.Context()), objectName, uploadInfo.ETag); job != nil {
+			job.SetTraceContext(r.Context())
+			job.Subject = auth.Subject(r.Context())
+			job.Tenant = tenant.Name(r.Context())
+			if queued := EnqueueAutoExtract(h.jobQueue, job); queued != nil {
+				response.ExtractJobID = queued.ID
+			}
+		}
+	}
+
 	h.writeJSON(w, http.StatusCreated, response)
 }
 
 func (h *FileHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -585,19 +627,19 @@ func (h *FileHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 	objectName := vars["filename"]
 
 	if objectName == "" {
-		h.writeError(w, "Filename is required", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Filename is required", http.StatusBadRequest, nil)
 		return
 	}
 
 	objectName = filepath.Clean(objectName)
 	if strings.HasPrefix(objectName, "/") || strings.Contains(objectName, "..") {
-		h.writeError(w, "Invalid object name", http.StatusBadRequest, nil)
+		httputil.WriteCode(w, httputil.CodeInvalidObjectName, "Invalid object name", nil)
 		return
 	}
 
 	// Check if MinIO is available
 	if h.minioClient == nil {
-		h.writeError(w, "MinIO storage is not available", http.StatusServiceUnavailable, fmt.Errorf("MinIO client not initialized"))
+		httputil.WriteCode(w, httputil.CodeStorageUnavailable, "MinIO storage is not available", fmt.Errorf("MinIO client not initialized"))
 		return
 	}
 
@@ -606,24 +648,24 @@ func (h *FileHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 
 	exists, err := h.minioClient.FileExists(ctx, objectName)
 	if err != nil {
-		h.writeError(w, "Failed to check file existence", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to check file existence", http.StatusInternalServerError, err)
 		return
 	}
 
 	if !exists {
-		h.writeError(w, "File not found", http.StatusNotFound, nil)
+		httputil.WriteCode(w, httputil.CodeFileNotFound, "File not found", nil)
 		return
 	}
 
 	fileInfo, err := h.minioClient.GetFileInfo(ctx, objectName)
 	if err != nil {
-		h.writeError(w, "Failed to get file info", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to get file info", http.StatusInternalServerError, err)
 		return
 	}
 
 	reader, err := h.minioClient.DownloadFile(ctx, objectName)
 	if err != nil {
-		h.writeError(w, "Failed to download file", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to download file", http.StatusInternalServerError, err)
 		return
 	}
 	defer reader.Close()
@@ -642,16 +684,16 @@ func (h *FileHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 
 func (h *FileHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Check bucket status first
 	log.Printf("ListFiles handler: checking bucket status")
-	bucketOk, bucketMsg := h.checkBucketStatus()
+	bucketOk, bucketMsg := h.checkBucketStatus(r.Context())
 	log.Printf("ListFiles handler: bucketOk=%v, bucketMsg=%s", bucketOk, bucketMsg)
 	if !bucketOk {
-		h.writeError(w, bucketMsg, http.StatusServiceUnavailable, fmt.Errorf("bucket not accessible"))
+		httputil.WriteCode(w, httputil.CodeBucketUnavailable, bucketMsg, fmt.Errorf("bucket not accessible"))
 		return
 	}
 
@@ -671,7 +713,7 @@ func (h *FileHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
 
 	files, err := h.minioClient.ListFiles(ctx, prefix, limit)
 	if err != nil {
-		h.writeError(w, "Failed to list files", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to list files", http.StatusInternalServerError, err)
 		return
 	}
 
@@ -698,7 +740,7 @@ func (h *FileHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
 
 func (h *FileHandler) GetFileInfo(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -706,13 +748,13 @@ func (h *FileHandler) GetFileInfo(w http.ResponseWriter, r *http.Request) {
 	objectName := vars["filename"]
 
 	if objectName == "" {
-		h.writeError(w, "Filename is required", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Filename is required", http.StatusBadRequest, nil)
 		return
 	}
 
 	objectName = filepath.Clean(objectName)
 	if strings.HasPrefix(objectName, "/") || strings.Contains(objectName, "..") {
-		h.writeError(w, "Invalid object name", http.StatusBadRequest, nil)
+		httputil.WriteCode(w, httputil.CodeInvalidObjectName, "Invalid object name", nil)
 		return
 	}
 
@@ -720,8 +762,12 @@ func (h *FileHandler) GetFileInfo(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	fileInfo, err := h.minioClient.GetFileInfo(ctx, objectName)
+	if storage.IsNotFound(err) {
+		httputil.WriteCode(w, httputil.CodeFileNotFound, "File not found", err)
+		return
+	}
 	if err != nil {
-		h.writeError(w, "Failed to get file info", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to get file info", http.StatusInternalServerError, err)
 		return
 	}
 
@@ -742,7 +788,7 @@ func (h *FileHandler) GetFileInfo(w http.ResponseWriter, r *http.Request) {
 
 func (h *FileHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -750,13 +796,13 @@ func (h *FileHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 	objectName := vars["filename"]
 
 	if objectName == "" {
-		h.writeError(w, "Filename is required", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Filename is required", http.StatusBadRequest, nil)
 		return
 	}
 
 	objectName = filepath.Clean(objectName)
 	if strings.HasPrefix(objectName, "/") || strings.Contains(objectName, "..") {
-		h.writeError(w, "Invalid object name", http.StatusBadRequest, nil)
+		httputil.WriteCode(w, httputil.CodeInvalidObjectName, "Invalid object name", nil)
 		return
 	}
 
@@ -765,7 +811,7 @@ func (h *FileHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 
 	err := h.minioClient.DeleteFile(ctx, objectName)
 	if err != nil {
-		h.writeError(w, "Failed to delete file", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to delete file", http.StatusInternalServerError, err)
 		return
 	}
 
@@ -780,26 +826,26 @@ func (h *FileHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 
 func (h *FileHandler) DeleteFilesByPrefix(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Check bucket status first
-	bucketOk, bucketMsg := h.checkBucketStatus()
+	bucketOk, bucketMsg := h.checkBucketStatus(r.Context())
 	if !bucketOk {
-		h.writeError(w, bucketMsg, http.StatusServiceUnavailable, fmt.Errorf("bucket not accessible"))
+		httputil.WriteCode(w, httputil.CodeBucketUnavailable, bucketMsg, fmt.Errorf("bucket not accessible"))
 		return
 	}
 
 	prefix := r.URL.Query().Get("prefix")
 	if prefix == "" {
-		h.writeError(w, "Prefix parameter is required", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Prefix parameter is required", http.StatusBadRequest, nil)
 		return
 	}
 
 	prefix = filepath.Clean(prefix)
 	if strings.HasPrefix(prefix, "/") || strings.Contains(prefix, "..") {
-		h.writeError(w, "Invalid prefix", http.StatusBadRequest, nil)
+		httputil.WriteCode(w, httputil.CodeInvalidObjectName, "Invalid prefix", nil)
 		return
 	}
 
@@ -809,7 +855,7 @@ func (h *FileHandler) DeleteFilesByPrefix(w http.ResponseWriter, r *http.Request
 	// First, list all files with the prefix
 	files, err := h.minioClient.ListFiles(ctx, prefix, 0)
 	if err != nil {
-		h.writeError(w, "Failed to list files for deletion", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to list files for deletion", http.StatusInternalServerError, err)
 		return
 	}
 
@@ -833,7 +879,7 @@ func (h *FileHandler) DeleteFilesByPrefix(w http.ResponseWriter, r *http.Request
 	// Delete all files
 	err = h.minioClient.DeleteFiles(ctx, objectNames)
 	if err != nil {
-		h.writeError(w, "Failed to delete files", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to delete files", http.StatusInternalServerError, err)
 		return
 	}
 
@@ -849,7 +895,7 @@ func (h *FileHandler) DeleteFilesByPrefix(w http.ResponseWriter, r *http.Request
 
 func (h *FileHandler) GetPresignedURL(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -857,19 +903,19 @@ func (h *FileHandler) GetPresignedURL(w http.ResponseWriter, r *http.Request) {
 	objectName := vars["filename"]
 
 	if objectName == "" {
-		h.writeError(w, "Filename is required", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Filename is required", http.StatusBadRequest, nil)
 		return
 	}
 
 	objectName = filepath.Clean(objectName)
 	if strings.HasPrefix(objectName, "/") || strings.Contains(objectName, "..") {
-		h.writeError(w, "Invalid object name", http.StatusBadRequest, nil)
+		httputil.WriteCode(w, httputil.CodeInvalidObjectName, "Invalid object name", nil)
 		return
 	}
 
 	// Check if MinIO is available
 	if h.minioClient == nil {
-		h.writeError(w, "MinIO storage is not available", http.StatusServiceUnavailable, fmt.Errorf("MinIO client not initialized"))
+		httputil.WriteCode(w, httputil.CodeStorageUnavailable, "MinIO storage is not available", fmt.Errorf("MinIO client not initialized"))
 		return
 	}
 
@@ -886,7 +932,7 @@ func (h *FileHandler) GetPresignedURL(w http.ResponseWriter, r *http.Request) {
 
 	presignedURL, err := h.minioClient.GetPresignedURL(ctx, objectName, expiry)
 	if err != nil {
-		h.writeError(w, "Failed to generate presigned URL", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to generate presigned URL", http.StatusInternalServerError, err)
 		return
 	}
 
@@ -916,18 +962,18 @@ type CopyFileResponse struct {
 
 func (h *FileHandler) CopyFile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var request CopyFileRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		h.writeError(w, "Failed to decode request body", http.StatusBadRequest, err)
+		httputil.WriteError(w, "Failed to decode request body", http.StatusBadRequest, err)
 		return
 	}
 
 	if request.SourceObjectName == "" || request.DestObjectName == "" {
-		h.writeError(w, "Source and destination object names are required", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Source and destination object names are required", http.StatusBadRequest, nil)
 		return
 	}
 
@@ -937,14 +983,14 @@ func (h *FileHandler) CopyFile(w http.ResponseWriter, r *http.Request) {
 
 	if strings.HasPrefix(sourceObjectName, "/") || strings.Contains(sourceObjectName, "..") ||
 		strings.HasPrefix(destObjectName, "/") || strings.Contains(destObjectName, "..") {
-		h.writeError(w, "Invalid object name", http.StatusBadRequest, nil)
+		httputil.WriteCode(w, httputil.CodeInvalidObjectName, "Invalid object name", nil)
 		return
 	}
 
 	// Check bucket status first
-	bucketOk, bucketMsg := h.checkBucketStatus()
+	bucketOk, bucketMsg := h.checkBucketStatus(r.Context())
 	if !bucketOk {
-		h.writeError(w, bucketMsg, http.StatusServiceUnavailable, fmt.Errorf("bucket not accessible"))
+		httputil.WriteCode(w, httputil.CodeBucketUnavailable, bucketMsg, fmt.Errorf("bucket not accessible"))
 		return
 	}
 
@@ -954,19 +1000,19 @@ func (h *FileHandler) CopyFile(w http.ResponseWriter, r *http.Request) {
 	// Check if source file exists
 	exists, err := h.minioClient.FileExists(ctx, sourceObjectName)
 	if err != nil {
-		h.writeError(w, "Failed to check source file existence", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to check source file existence", http.StatusInternalServerError, err)
 		return
 	}
 
 	if !exists {
-		h.writeError(w, "Source file does not exist", http.StatusNotFound, nil)
+		httputil.WriteCode(w, httputil.CodeFileNotFound, "Source file does not exist", nil)
 		return
 	}
 
 	// Copy the file
 	copyInfo, err := h.minioClient.CopyFile(ctx, sourceObjectName, destObjectName)
 	if err != nil {
-		h.writeError(w, "Failed to copy file", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to copy file", http.StatusInternalServerError, err)
 		return
 	}
 
@@ -983,13 +1029,13 @@ func (h *FileHandler) CopyFile(w http.ResponseWriter, r *http.Request) {
 
 func (h *FileHandler) ListBuckets(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Check if MinIO is available
 	if h.minioClient == nil {
-		h.writeError(w, "MinIO storage is not available", http.StatusServiceUnavailable, fmt.Errorf("MinIO client not initialized"))
+		httputil.WriteCode(w, httputil.CodeStorageUnavailable, "MinIO storage is not available", fmt.Errorf("MinIO client not initialized"))
 		return
 	}
 
@@ -998,7 +1044,7 @@ func (h *FileHandler) ListBuckets(w http.ResponseWriter, r *http.Request) {
 
 	buckets, err := h.minioClient.GetClient().ListBuckets(ctx)
 	if err != nil {
-		h.writeError(w, "Failed to list buckets", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to list buckets", http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1022,7 +1068,7 @@ func (h *FileHandler) ListBuckets(w http.ResponseWriter, r *http.Request) {
 
 func (h *FileHandler) SetBucket(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -1031,23 +1077,23 @@ func (h *FileHandler) SetBucket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		h.writeError(w, "Failed to decode request body", http.StatusBadRequest, err)
+		httputil.WriteError(w, "Failed to decode request body", http.StatusBadRequest, err)
 		return
 	}
 
 	if request.BucketName == "" {
-		h.writeError(w, "Bucket name is required", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Bucket name is required", http.StatusBadRequest, nil)
 		return
 	}
 
 	// Check if MinIO is available
 	if h.minioClient == nil {
-		h.writeError(w, "MinIO storage is not available", http.StatusServiceUnavailable, fmt.Errorf("MinIO client not initialized"))
+		httputil.WriteCode(w, httputil.CodeStorageUnavailable, "MinIO storage is not available", fmt.Errorf("MinIO client not initialized"))
 		return
 	}
 
 	if err := h.minioClient.SetBucket(request.BucketName); err != nil {
-		h.writeError(w, "Failed to set bucket", http.StatusBadRequest, err)
+		httputil.WriteError(w, "Failed to set bucket", http.StatusBadRequest, err)
 		return
 	}
 
@@ -1062,17 +1108,17 @@ func (h *FileHandler) SetBucket(w http.ResponseWriter, r *http.Request) {
 
 func (h *FileHandler) GetCurrentBucket(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Check if MinIO is available
 	if h.minioClient == nil {
-		h.writeError(w, "MinIO storage is not available", http.StatusServiceUnavailable, fmt.Errorf("MinIO client not initialized"))
+		httputil.WriteCode(w, httputil.CodeStorageUnavailable, "MinIO storage is not available", fmt.Errorf("MinIO client not initialized"))
 		return
 	}
 
-	currentBucket := h.minioClient.GetBucketName()
+	currentBucket := h.minioClient.Bucket(r.Context())
 
 	response := map[string]any{
 		"success":     true,
@@ -1085,25 +1131,17 @@ func (h *FileHandler) GetCurrentBucket(w http.ResponseWriter, r *http.Request) {
 
 func (h *FileHandler) GetBucketStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Check if MinIO is available
 	if h.minioClient == nil {
-		response := map[string]any{
-			"success": false,
-			"message": "MinIO storage is not available",
-			"bucket":  "",
-			"exists":  false,
-			"error":   "MinIO client not initialized",
-		}
-		h.writeJSON(w, http.StatusServiceUnavailable, response)
+		httputil.WriteCode(w, httputil.CodeStorageUnavailable, "MinIO storage is not available", fmt.Errorf("MinIO client not initialized"))
 		return
 	}
 
-	currentBucket := h.minioClient.GetBucketName()
-	bucketExists, bucketError := h.minioClient.GetBucketStatus()
+	currentBucket, bucketExists, bucketError := h.minioClient.BucketStatus(r.Context())
 
 	response := map[string]any{
 		"success": true,
@@ -1116,17 +1154,17 @@ func (h *FileHandler) GetBucketStatus(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, http.StatusOK, response)
 }
 
-func (h *FileHandler) checkBucketStatus() (bool, string) {
+func (h *FileHandler) checkBucketStatus(ctx context.Context) (bool, string) {
 	log.Printf("checkBucketStatus: starting")
 	if h.minioClient == nil {
 		log.Printf("checkBucketStatus: minioClient is nil")
 		return false, "MinIO client not initialized"
 	}
 
-	bucketExists, bucketError := h.minioClient.GetBucketStatus()
-	log.Printf("checkBucketStatus: bucketExists=%v, bucketError=%s", bucketExists, bucketError)
+	bucket, bucketExists, bucketError := h.minioClient.BucketStatus(ctx)
+	log.Printf("checkBucketStatus: bucket=%s, bucketExists=%v, bucketError=%s", bucket, bucketExists, bucketError)
 	if !bucketExists {
-		errorMsg := fmt.Sprintf("Bucket '%s' is not accessible", h.minioClient.GetBucketName())
+		errorMsg := fmt.Sprintf("Bucket '%s' is not accessible", bucket)
 		if bucketError != "" {
 			errorMsg = fmt.Sprintf("%s: %s", errorMsg, bucketError)
 		}
@@ -1141,21 +1179,33 @@ func (h *FileHandler) checkBucketStatus() (bool, string) {
 // ExtractArchive extracts an archive file and returns information about the extraction
 func (h *FileHandler) ExtractArchive(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		h.writeError(w, "Method not allowed", http.StatusMethodNotAllowed, nil)
+		httputil.WriteError(w, "Method not allowed", http.StatusMethodNotAllowed, nil)
 		return
 	}
 
 	var request struct {
 		FileName string `json:"file_name"`
+		Password string `json:"password,omitempty"`
+		Force    bool   `json:"force,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		h.writeError(w, "Invalid JSON request", http.StatusBadRequest, err)
+		httputil.WriteError(w, "Invalid JSON request", http.StatusBadRequest, err)
 		return
 	}
 
 	if request.FileName == "" {
-		h.writeError(w, "file_name is required", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "file_name is required", http.StatusBadRequest, nil)
+		return
+	}
+
+	if !h.usage.Allow(w, r, metering.MetricJobTime) {
+		return
+	}
+
+	objectInfo, err := h.minioClient.GetFileInfo(r.Context(), request.FileName)
+	if err != nil {
+		httputil.WriteCode(w, httputil.CodeFileNotFound, "File not found", err)
 		return
 	}
 
@@ -1163,7 +1213,7 @@ func (h *FileHandler) ExtractArchive(w http.ResponseWriter, r *http.Request) {
 	jobRequest := map[string]any{
 		"type":        "extract",
 		"file_path":   request.FileName,
-		"bucket":      h.minioClient.GetBucketName(),
+		"bucket":      h.minioClient.Bucket(r.Context()),
 		"object_name": request.FileName,
 		"priority":    "medium",
 	}
@@ -1183,19 +1233,25 @@ func (h *FileHandler) ExtractArchive(w http.ResponseWriter, r *http.Request) {
 	job := &jobs.Job{
 		ID:         fmt.Sprintf("extract_%d", time.Now().UnixNano()),
 		Type:       "extract",
-		Bucket:     h.minioClient.GetBucketName(),
+		Bucket:     h.minioClient.Bucket(r.Context()),
 		ObjectName: request.FileName,
+		ETag:       objectInfo.ETag,
 		Priority:   jobs.PriorityMedium,
 		Status:     jobs.JobStatusPending,
 		CreatedAt:  time.Now(),
 		Metadata:   make(map[string]any),
+		Password:   request.Password,
 	}
+	job.SetTraceContext(r.Context())
+	job.Subject = auth.Subject(r.Context())
+	job.Tenant = tenant.Name(r.Context())
 
 	// Enqueue job for async processing
+	duplicate := false
 	if h.jobQueue != nil {
-		err := h.jobQueue.Enqueue(job)
+		job, duplicate, err = jobs.EnqueueUnique(h.jobQueue, job, request.Force)
 		if err != nil {
-			h.writeError(w, "Failed to enqueue extraction job", http.StatusInternalServerError, err)
+			httputil.WriteError(w, "Failed to enqueue extraction job", http.StatusInternalServerError, err)
 			return
 		}
 	} else {
@@ -1244,24 +1300,78 @@ func (h *FileHandler) ExtractArchive(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
+	if duplicate {
+		response["message"] = "Extraction job already exists for this file"
+		response["duplicate"] = true
+	}
+
 	h.writeJSON(w, http.StatusOK, response)
 }
 
-func (h *FileHandler) writeJSON(w http.ResponseWriter, statusCode int, data any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(data)
-}
+// GetArchiveInfo lists the entries of an archive in MinIO without extracting
+// it, so users can decide what to extract
+func (h *FileHandler) GetArchiveInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httputil.WriteError(w, "Method not allowed", http.StatusMethodNotAllowed, nil)
+		return
+	}
 
-func (h *FileHandler) writeError(w http.ResponseWriter, message string, statusCode int, err error) {
-	response := map[string]any{
-		"success": false,
-		"message": message,
+	var request struct {
+		FileName   string `json:"file_name"`
+		Password   string `json:"password,omitempty"`
+		MaxEntries int    `json:"max_entries,omitempty"`
 	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		httputil.WriteError(w, "Invalid JSON request", http.StatusBadRequest, err)
+		return
+	}
+
+	if request.FileName == "" {
+		httputil.WriteError(w, "file_name is required", http.StatusBadRequest, nil)
+		return
+	}
+
+	extractor := NewArchiveExtractor(DecompressionConfig{})
+	if !extractor.isExtractable(request.FileName) {
+		httputil.WriteCode(w, httputil.CodeUnsupportedArchive, "Unsupported archive format", fmt.Errorf("%s is not a supported archive", filepath.Base(request.FileName)))
+		return
+	}
+
+	bucket, err := h.minioClient.Scope(r.Context(), request.FileName)
 	if err != nil {
-		response["error"] = err.Error()
-		log.Printf("Error: %v", err)
+		httputil.WriteError(w, "Failed to open archive", http.StatusInternalServerError, err)
+		return
 	}
+	object, err := h.minioClient.GetClient().GetObject(r.Context(), bucket, request.FileName, minio.GetObjectOptions{})
+	if err != nil {
+		httputil.WriteError(w, "Failed to open archive", http.StatusInternalServerError, err)
+		return
+	}
+	defer object.Close()
+
+	objectInfo, err := object.Stat()
+	if err != nil {
+		httputil.WriteCode(w, httputil.CodeFileNotFound, "File not found", err)
+		return
+	}
+
+	preview, err := extractor.ListEntries(request.FileName, object, objectInfo.Size, request.Password, request.MaxEntries)
+	if err != nil {
+		httputil.WriteCode(w, httputil.CodeInvalidArchive, "Failed to read archive", err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"success":   true,
+		"message":   "Archive info retrieved successfully",
+		"file_name": request.FileName,
+		"archive":   preview,
+	})
+}
 
-	h.writeJSON(w, statusCode, response)
+func (h *FileHandler) writeJSON(w http.ResponseWriter, statusCode int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
 }
@@ -1,4 +1,5 @@
-// True streaming SSE implementation for file browsing
+// True streaming implementation for file browsing, over SSE and as the
+// browse stream of /api/ws
 package files
 
 import (
@@ -14,8 +15,13 @@ import (
 	"time"
 
 	"github.com/minio/minio-go/v7"
+
+	"bronze-backend/httputil"
 )
 
+// browseTimeout bounds a streaming browse.
+const browseTimeout = 300 * time.Second
+
 func (h *FileHandler) streamFolderBrowseRealtime(w http.ResponseWriter, r *http.Request) {
 	// SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
@@ -25,15 +31,20 @@ func (h *FileHandler) streamFolderBrowseRealtime(w http.ResponseWriter, r *http.
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		httputil.Error(w, "Streaming unsupported", http.StatusInternalServerError)
 		return
 	}
 
-	// Mutex for thread-safe flushing
-	var flushMutex sync.Mutex
-	safeFlush := func() {
-		flushMutex.Lock()
-		defer flushMutex.Unlock()
+	// Folders are listed concurrently; one event is written at a time
+	var writeMutex sync.Mutex
+	send := func(event string, data any) {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return
+		}
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		h.writeSSEEvent(w, event, string(payload))
 		flusher.Flush()
 	}
 
@@ -43,30 +54,16 @@ func (h *FileHandler) streamFolderBrowseRealtime(w http.ResponseWriter, r *http.
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 300*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), browseTimeout)
 	defer cancel()
 
-	// Track goroutines for proper cleanup
-	var wg sync.WaitGroup
-	done := make(chan struct{})
-
 	// Send connected event
-	h.writeSSEEvent(w, "connected", `{"status":"connected"}`)
-	safeFlush()
+	send("connected", map[string]string{"status": "connected"})
 
-	// Process each folder with true streaming
-	for _, folderReq := range req.Folders {
-		wg.Add(1)
-		go func(folderReq FolderRequest) {
-			defer wg.Done()
-			h.streamFolderContents(ctx, w, folderReq, safeFlush)
-		}(folderReq)
-	}
-
-	// Wait for all goroutines to finish in a separate goroutine
+	done := make(chan struct{})
 	go func() {
-		wg.Wait()
-		close(done)
+		defer close(done)
+		h.browseFolders(ctx, req.Folders, send)
 	}()
 
 	// Keep connection alive
@@ -76,27 +73,68 @@ func (h *FileHandler) streamFolderBrowseRealtime(w http.ResponseWriter, r *http.
 	for {
 		select {
 		case <-keepalive.C:
-			h.writeSSEEvent(w, "keepalive", `{"status":"alive"}`)
-			safeFlush()
+			send("keepalive", map[string]string{"status": "alive"})
 		case <-done:
 			// All folders processed, send completion and return
-			h.writeSSEEvent(w, "complete", `{"status":"all_folders_completed"}`)
-			safeFlush()
+			send("complete", map[string]string{"status": "all_folders_completed"})
 			return
 		case <-ctx.Done():
-			h.writeSSEEvent(w, "closed", `{"status":"connection_closed"}`)
+			<-done
+			send("closed", map[string]string{"status": "connection_closed"})
 			return
 		}
 	}
 }
 
+// StreamBrowse lists the folders of a MultiFolderRequest in params like
+// POST /api/files/browse, sending its events except the connection ones
+// (connected, keepalive, complete and closed). It is the browse stream of
+// /api/ws.
+func (h *FileHandler) StreamBrowse(ctx context.Context, params json.RawMessage, send func(event string, data any)) error {
+	if h.minioClient == nil {
+		return fmt.Errorf("MinIO client not initialized")
+	}
+
+	var req MultiFolderRequest
+	if err := json.Unmarshal(params, &req); err != nil {
+		return fmt.Errorf("invalid browse request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, browseTimeout)
+	defer cancel()
+	h.browseFolders(ctx, req.Folders, send)
+	return nil
+}
+
+// browseFolders streams every folder concurrently and returns when all are
+// done.
+func (h *FileHandler) browseFolders(ctx context.Context, folders []FolderRequest, send func(event string, data any)) {
+	var wg sync.WaitGroup
+	for _, folderReq := range folders {
+		wg.Add(1)
+		go func(folderReq FolderRequest) {
+			defer wg.Done()
+			h.streamFolderContents(ctx, folderReq, send)
+		}(folderReq)
+	}
+	wg.Wait()
+}
+
+// browseError is the data of a browse error event.
+func browseError(message string, code int, err error) map[string]any {
+	return map[string]any{
+		"error":   message,
+		"code":    code,
+		"details": err.Error(),
+	}
+}
+
 // Stream folder contents in real-time as they're discovered
-func (h *FileHandler) streamFolderContents(ctx context.Context, w http.ResponseWriter, folderReq FolderRequest, safeFlush func()) {
+func (h *FileHandler) streamFolderContents(ctx context.Context, folderReq FolderRequest, send func(event string, data any)) {
 	// Add panic recovery to prevent crashes
 	defer func() {
 		if r := recover(); r != nil {
-			h.writeSSEError(w, "Panic in folder processing", http.StatusInternalServerError, fmt.Errorf("%v", r))
-			safeFlush()
+			send("error", browseError("Panic in folder processing", http.StatusInternalServerError, fmt.Errorf("%v", r)))
 		}
 	}()
 
@@ -151,14 +189,12 @@ func (h *FileHandler) streamFolderContents(ctx context.Context, w http.ResponseW
 		"status": "processing",
 		"items":  items,
 	}
-	folderStartJSON, _ := json.Marshal(folderStartData)
-	h.writeSSEEvent(w, "folder_start", string(folderStartJSON))
-	safeFlush()
+	send("folder_start", folderStartData)
 
 	// Use MinIO's ListFiles method for streaming with smaller limit for responsiveness
 	objects, err := h.minioClient.ListFiles(ctx, path, 500) // Reduced from 1000
 	if err != nil {
-		h.writeSSEError(w, fmt.Sprintf("Error listing %s", path), http.StatusInternalServerError, err)
+		send("error", browseError(fmt.Sprintf("Error listing %s", path), http.StatusInternalServerError, err))
 		return
 	}
 
@@ -208,11 +244,7 @@ func (h *FileHandler) streamFolderContents(ctx context.Context, w http.ResponseW
 			eventData["contentType"] = h.getContentType(obj.Key)
 		}
 
-		jsonData, _ := json.Marshal(eventData)
-		h.writeSSEEvent(w, "item", string(jsonData))
-
-		// Flush immediately for each item
-		safeFlush()
+		send("item", eventData)
 
 		// Check for context cancellation
 		select {
@@ -232,10 +264,7 @@ func (h *FileHandler) streamFolderContents(ctx context.Context, w http.ResponseW
 		"totalItems": fileCount + dirCount,
 	}
 
-	jsonData, _ := json.Marshal(completionData)
-	h.writeSSEEvent(w, "folder_complete", string(jsonData))
-
-	safeFlush()
+	send("folder_complete", completionData)
 }
 
 // Count total items (files + subdirectories) in a folder
@@ -245,8 +274,13 @@ func (h *FileHandler) countItemsInFolder(ctx context.Context, folderPath string)
 	// Decode URL-encoded folder path
 	decodedPath, _ := url.PathUnescape(folderPath)
 
+	bucket, err := h.minioClient.Scope(ctx, decodedPath)
+	if err != nil {
+		return 0
+	}
+
 	// Use MinIO client to list items in this folder (non-recursive)
-	objectsCh := h.minioClient.GetClient().ListObjects(ctx, h.minioClient.GetBucketName(), minio.ListObjectsOptions{
+	objectsCh := h.minioClient.GetClient().ListObjects(ctx, bucket, minio.ListObjectsOptions{
 		Prefix:    decodedPath,
 		Recursive: false, // Only direct children
 	})
@@ -3,65 +3,117 @@ package files
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"bronze-backend/config"
 	"bronze-backend/jobs"
+	"bronze-backend/quarantine"
+	"bronze-backend/storage"
+	"bronze-backend/tempdir"
+	"bronze-backend/tenant"
+
+	"github.com/minio/minio-go/v7"
 )
 
 type FileProcessor struct {
-	decompressor *ArchiveExtractor
-	config       *config.Config
+	config      *config.Config
+	minioClient *storage.MinIOClient
+
+	// Extraction settings, replaced by SetDecompression while jobs run
+	mu            sync.RWMutex
+	decompression config.DecompressionConfig
+	decompressor  *ArchiveExtractor
+	autoExtract   []config.AutoExtractRule
+
+	quarantine *quarantine.Store // Nil unless quarantine is enabled
 }
 
-func NewFileProcessor(cfg *config.Config) *FileProcessor {
-	decompressorConfig := DecompressionConfig{
-		MaxExtractSize:     cfg.Processing.Decompression.MaxExtractSize,
-		MaxFilesPerArchive: cfg.Processing.Decompression.MaxFilesPerArchive,
-		NestedArchiveDepth: cfg.Processing.Decompression.NestedArchiveDepth,
-		PasswordProtected:  cfg.Processing.Decompression.PasswordProtected,
-		ExtractToSubfolder: cfg.Processing.Decompression.ExtractToSubfolder,
+// NewFileProcessor creates a processor for file jobs. Source objects are read
+// from and job artifacts written to MinIO through minioClient.
+func NewFileProcessor(cfg *config.Config, minioClient *storage.MinIOClient) *FileProcessor {
+	fp := &FileProcessor{
+		config:      cfg,
+		minioClient: minioClient,
 	}
+	fp.SetDecompression(cfg.Processing.Decompression)
+	return fp
+}
 
-	return &FileProcessor{
-		decompressor: NewArchiveExtractor(decompressorConfig),
-		config:       cfg,
+// SetDecompression replaces the archive extraction limits and settings.
+// Jobs already extracting finish with the previous ones.
+func (fp *FileProcessor) SetDecompression(cfg config.DecompressionConfig) {
+	decompressor := NewArchiveExtractor(DecompressionConfig{
+		MaxExtractSize:      cfg.MaxExtractSize,
+		MaxFilesPerArchive:  cfg.MaxFilesPerArchive,
+		MaxCompressionRatio: cfg.MaxCompressionRatio,
+		NestedArchiveDepth:  cfg.NestedArchiveDepth,
+		PasswordProtected:   cfg.PasswordProtected,
+		ExtractToSubfolder:  cfg.ExtractToSubfolder,
+	})
+	autoExtract, err := cfg.ParseAutoExtract()
+	if err != nil {
+		log.Printf("Warning: Ignoring invalid auto-extract rules: %v", err)
 	}
+
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	fp.decompression = cfg
+	fp.decompressor = decompressor
+	fp.autoExtract = autoExtract
 }
 
-type JobProcessor interface {
-	ProcessJob(ctx context.Context, job jobs.Job) jobs.JobResult
+// SetQuarantine sets aside archives that keep failing to extract.
+func (fp *FileProcessor) SetQuarantine(q *quarantine.Store) {
+	fp.quarantine = q
+}
+
+// extraction returns the current extraction settings and the extractor
+// built from them.
+func (fp *FileProcessor) extraction() (config.DecompressionConfig, *ArchiveExtractor) {
+	fp.mu.RLock()
+	defer fp.mu.RUnlock()
+	return fp.decompression, fp.decompressor
 }
 
 func (fp *FileProcessor) ProcessJob(ctx context.Context, job *jobs.Job) jobs.JobResult {
+	result := fp.processJob(ctx, job)
+	if result.Success {
+		fp.quarantine.Succeed(ctx, job.ObjectName)
+		fp.cleanupArchive(ctx, job, &result)
+	}
+	return result
+}
+
+func (fp *FileProcessor) processJob(ctx context.Context, job *jobs.Job) jobs.JobResult {
 	startTime := time.Now()
 
 	log.Printf("Processing job %s: %s/%s", job.ID, job.Bucket, job.ObjectName)
 
 	job.UpdateProgress(10)
 
+	if fp.shouldStream(ctx, job) {
+		return fp.processStreaming(ctx, job, startTime)
+	}
+
 	tempFilePath, err := fp.downloadFileFromMinIO(ctx, job)
 	if err != nil {
-		return jobs.JobResult{
-			Success:        false,
-			ProcessingTime: time.Since(startTime),
-			Message:        fmt.Sprintf("Failed to download file: %v", err),
-		}
+		return fp.failJob(ctx, job, startTime, "download", fmt.Errorf("Failed to download file: %w", err))
 	}
 	defer os.Remove(tempFilePath)
 
 	job.UpdateProgress(30)
 
-	archiveInfo, err := fp.decompressor.DetectArchive(tempFilePath)
+	_, decompressor := fp.extraction()
+	archiveInfo, err := decompressor.DetectArchive(tempFilePath)
 	if err != nil {
-		return jobs.JobResult{
-			Success:        false,
-			ProcessingTime: time.Since(startTime),
-			Message:        fmt.Sprintf("Failed to detect archive: %v", err),
-		}
+		return fp.failJob(ctx, job, startTime, "detect", fmt.Errorf("Failed to detect archive: %w", err))
 	}
 
 	job.UpdateProgress(50)
@@ -79,55 +131,340 @@ func (fp *FileProcessor) ProcessJob(ctx context.Context, job *jobs.Job) jobs.Job
 	if archiveInfo.IsArchive {
 		job.UpdateProgress(60)
 
-		extractDir := filepath.Join(fp.config.Processing.TempDir, job.ID)
-		extractionResult, err := fp.decompressor.ExtractArchive(tempFilePath, extractDir, "")
+		// The archive takes at least its own size again once extracted
+		if err := tempdir.From(ctx).Reserve(archiveInfo.TotalSize); err != nil {
+			return fp.failJob(ctx, job, startTime, "disk_space", err)
+		}
+
+		extractDir := filepath.Join(fp.tempDir(ctx), job.ID)
+		extractionResult, err := decompressor.WithQuota(jobs.QuotaFrom(ctx)).ExtractArchive(tempFilePath, extractDir, job.Password)
 		if err != nil {
-			return jobs.JobResult{
-				Success:        false,
-				ProcessingTime: time.Since(startTime),
-				Message:        fmt.Sprintf("Failed to extract archive: %v", err),
-			}
+			return fp.failJob(ctx, job, startTime, "extract", fmt.Errorf("Failed to extract archive: %w", err))
 		}
 
-		result.ExtractedFiles = extractionResult.ExtractedFiles
-		result.FileInfo["extracted_files"] = extractionResult.ExtractedFiles
-		result.FileInfo["extraction_result"] = extractionResult
+		defer os.RemoveAll(extractDir)
 
-		job.UpdateProgress(80)
+		job.UpdateProgress(70)
 
 		if err := fp.processExtractedFiles(ctx, job, extractionResult.ExtractedFiles); err != nil {
 			log.Printf("Warning: Failed to process extracted files: %v", err)
 		}
 
-		defer os.RemoveAll(extractDir)
+		objectKeys, err := fp.uploadExtractedFiles(ctx, job, extractDir, extractionResult.ExtractedFiles)
+		if err != nil {
+			return fp.failJob(ctx, job, startTime, "upload", fmt.Errorf("Failed to upload extracted files: %w", err))
+		}
+
+		result.ExtractedFiles = make([]string, 0, len(objectKeys))
+		for _, filePath := range extractionResult.ExtractedFiles {
+			if key, ok := objectKeys[filePath]; ok {
+				result.ExtractedFiles = append(result.ExtractedFiles, key)
+			}
+		}
+		result.FileInfo["extracted_files"] = result.ExtractedFiles
+		result.FileInfo["extraction_result"] = extractionResult
+		result.FileInfo["extract_prefix"] = fp.extractPrefix(ctx, job)
+
+		if lineage := extractionLineage(job, extractionResult, objectKeys); len(lineage) > 0 {
+			job.Metadata["lineage"] = lineage
+			result.FileInfo["lineage"] = lineage
+			result.FileInfo["nested_archives"] = extractionResult.NestedCount
+		}
+
+		job.UpdateProgress(85)
+
+		if err := fp.uploadManifest(ctx, job, extractDir, extractionResult, objectKeys); err != nil {
+			log.Printf("Warning: Failed to upload extraction manifest: %v", err)
+		}
 	}
 
 	job.UpdateProgress(90)
 
-	if err := fp.uploadProcessedResults(ctx, job, result); err != nil {
+	result.Message = fmt.Sprintf("Successfully processed file %s", job.ObjectName)
+
+	if err := fp.uploadProcessedResults(ctx, job, &result); err != nil {
 		log.Printf("Warning: Failed to upload processed results: %v", err)
 	}
 
 	job.UpdateProgress(100)
 
-	result.Message = fmt.Sprintf("Successfully processed file %s", job.ObjectName)
 	log.Printf("Completed job %s in %v", job.ID, time.Since(startTime))
 
 	return result
 }
 
+// streamPartSize is the multipart chunk used when streaming an entry of
+// unknown size.
+const streamPartSize = 64 << 20
+
+// shouldStream decides whether an archive is extracted straight from MinIO
+// to MinIO. The job's "streaming" metadata wins; otherwise archives at or
+// above STREAM_EXTRACT_THRESHOLD are streamed.
+func (fp *FileProcessor) shouldStream(ctx context.Context, job *jobs.Job) bool {
+	settings, decompressor := fp.extraction()
+	if fp.minioClient == nil || !decompressor.CanStream(job.ObjectName) {
+		return false
+	}
+
+	if streaming, ok := job.Metadata["streaming"].(bool); ok {
+		return streaming
+	}
+
+	threshold, err := config.ParseByteSize(settings.StreamThreshold)
+	if err != nil {
+		log.Printf("Warning: Ignoring invalid stream threshold: %v", err)
+		return false
+	}
+	if threshold == 0 {
+		return false
+	}
+
+	info, err := fp.minioClient.GetClient().StatObject(ctx, fp.jobBucket(job), job.ObjectName, minio.StatObjectOptions{})
+	if err != nil {
+		return false
+	}
+	return info.Size >= threshold
+}
+
+// processStreaming extracts a zip or tar archive entry by entry from its
+// object into objects under the extract prefix. Nothing is written to local
+// disk and nested archives are uploaded as they are.
+func (fp *FileProcessor) processStreaming(ctx context.Context, job *jobs.Job, startTime time.Time) jobs.JobResult {
+	client := fp.minioClient.GetClient()
+	bucket := fp.jobBucket(job)
+
+	object, err := client.GetObject(ctx, bucket, job.ObjectName, minio.GetObjectOptions{})
+	if err != nil {
+		return fp.failJob(ctx, job, startTime, "download", fmt.Errorf("Failed to open object: %w", err))
+	}
+	defer object.Close()
+
+	info, err := object.Stat()
+	if err != nil {
+		return fp.failJob(ctx, job, startTime, "download", fmt.Errorf("Failed to stat object: %w", err))
+	}
+
+	_, decompressor := fp.extraction()
+	format := decompressor.formatOf(job.ObjectName)
+	prefix := fp.extractPrefix(ctx, job)
+
+	log.Printf("Streaming extraction of %s/%s (%d bytes) to %s for job %s", bucket, job.ObjectName, info.Size, prefix, job.ID)
+
+	job.UpdateProgress(30)
+
+	var manifest []ManifestEntry
+	var written int64
+	_, err = decompressor.ExtractStream(job.ObjectName, object, info.Size, job.Password, func(name string, reader io.Reader, size int64) error {
+		objectName := prefix + name
+		options := minio.PutObjectOptions{ContentType: "application/octet-stream"}
+		if size < 0 {
+			// Unknown sizes make minio-go size parts for a 5TiB object;
+			// fixed parts keep the upload buffer bounded
+			options.PartSize = streamPartSize
+		}
+		upload, err := client.PutObject(ctx, bucket, objectName, reader, size, options)
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %w", objectName, err)
+		}
+
+		manifest = append(manifest, ManifestEntry{Path: name, Object: objectName, Size: upload.Size})
+		written += upload.Size
+		if info.Size > 0 {
+			job.UpdateProgress(30 + 55*min(float64(written)/float64(info.Size), 1))
+		}
+		return nil
+	})
+	if err != nil {
+		return fp.failJob(ctx, job, startTime, "extract", fmt.Errorf("Failed to extract archive: %w", err))
+	}
+
+	extractedFiles := make([]string, 0, len(manifest))
+	for _, entry := range manifest {
+		extractedFiles = append(extractedFiles, entry.Object)
+	}
+
+	result := jobs.JobResult{
+		Success:        true,
+		ExtractedFiles: extractedFiles,
+		FileInfo: map[string]any{
+			"file_size":       info.Size,
+			"format":          format,
+			"streaming":       true,
+			"extracted_files": extractedFiles,
+			"extract_prefix":  prefix,
+		},
+		Message: fmt.Sprintf("Successfully processed file %s", job.ObjectName),
+	}
+
+	job.UpdateProgress(90)
+
+	if err := fp.saveManifest(ctx, job, format, manifest); err != nil {
+		log.Printf("Warning: Failed to upload extraction manifest: %v", err)
+	}
+
+	result.ProcessingTime = time.Since(startTime)
+	if err := fp.uploadProcessedResults(ctx, job, &result); err != nil {
+		log.Printf("Warning: Failed to upload processed results: %v", err)
+	}
+
+	job.UpdateProgress(100)
+
+	log.Printf("Completed streaming job %s in %v (%d files)", job.ID, time.Since(startTime), len(extractedFiles))
+
+	return result
+}
+
+// downloadFileFromMinIO streams the job's object into the temp dir and
+// returns the local path. The caller removes the file when done.
 func (fp *FileProcessor) downloadFileFromMinIO(ctx context.Context, job *jobs.Job) (string, error) {
-	tempFilePath := filepath.Join(fp.config.Processing.TempDir, job.ID+"_"+job.ObjectName)
+	if fp.minioClient == nil {
+		return "", fmt.Errorf("MinIO client not available")
+	}
+
+	tempDir := fp.tempDir(ctx)
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+
+	object, size, err := fp.minioClient.DownloadFileCached(ctx, job.ObjectName, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to open object: %w", err)
+	}
+	defer object.Close()
+
+	if err := tempdir.From(ctx).Reserve(size); err != nil {
+		return "", err
+	}
+
+	// Object names may contain prefixes; only the base name goes into the
+	// temp file so nested keys don't need matching directories
+	tempFilePath := filepath.Join(tempDir, job.ID+"_"+filepath.Base(job.ObjectName))
 
 	file, err := os.Create(tempFilePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to create temp file: %w", err)
 	}
-	defer file.Close()
+
+	written, err := io.Copy(jobs.QuotaFrom(ctx).TempWriter(file), object)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempFilePath)
+		return "", fmt.Errorf("failed to download object: %w", err)
+	}
+
+	log.Printf("Downloaded %s/%s (%d bytes) for job %s", fp.jobBucket(job), job.ObjectName, written, job.ID)
 
 	return tempFilePath, nil
 }
 
+// tempDir returns the directory of the job run in ctx, or TEMP_DIR for a
+// job run outside the worker pool.
+func (fp *FileProcessor) tempDir(ctx context.Context) string {
+	if dir := tempdir.From(ctx); dir != nil {
+		return dir.Path
+	}
+	return fp.config.Processing.TempDir
+}
+
+// jobBucket returns the bucket the job's object lives in, falling back to the
+// client's current bucket.
+func (fp *FileProcessor) jobBucket(job *jobs.Job) string {
+	if job.Bucket != "" {
+		return job.Bucket
+	}
+	return fp.minioClient.GetBucketName()
+}
+
+// extractPrefix returns the object prefix extracted files are uploaded to:
+// {archive}/extracted/ by default, or {prefix}/{archive name}/ below the
+// tenant's prefix, where prefix is the job's extract_prefix metadata or
+// EXTRACT_PREFIX.
+func (fp *FileProcessor) extractPrefix(ctx context.Context, job *jobs.Job) string {
+	settings, _ := fp.extraction()
+	prefix := strings.Trim(settings.ExtractPrefix, "/")
+	if destination, _ := job.Metadata[MetadataExtractPrefix].(string); destination != "" && !strings.Contains(destination, "..") {
+		prefix = strings.Trim(destination, "/")
+	}
+	if prefix != "" {
+		return tenant.Prefix(ctx) + path.Join(prefix, path.Base(job.ObjectName)) + "/"
+	}
+	return strings.TrimSuffix(job.ObjectName, "/") + "/extracted/"
+}
+
+// uploadExtractedFiles uploads every extracted file below the job's extract
+// prefix, preserving its path relative to extractDir. It returns the object
+// key for each local path.
+func (fp *FileProcessor) uploadExtractedFiles(ctx context.Context, job *jobs.Job, extractDir string, extractedFiles []string) (map[string]string, error) {
+	if fp.minioClient == nil {
+		return nil, fmt.Errorf("MinIO client not available")
+	}
+
+	bucket := fp.jobBucket(job)
+	prefix := fp.extractPrefix(ctx, job)
+	objectKeys := make(map[string]string, len(extractedFiles))
+
+	for _, filePath := range extractedFiles {
+		if err := ctx.Err(); err != nil {
+			return objectKeys, err
+		}
+
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return objectKeys, err
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		rel, err := filepath.Rel(extractDir, filePath)
+		if err != nil {
+			return objectKeys, fmt.Errorf("failed to resolve path of %s: %w", filePath, err)
+		}
+		objectName := prefix + filepath.ToSlash(rel)
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return objectKeys, err
+		}
+		_, err = fp.minioClient.GetClient().PutObject(ctx, bucket, objectName, file, info.Size(), minio.PutObjectOptions{
+			ContentType: "application/octet-stream",
+		})
+		file.Close()
+		if err != nil {
+			return objectKeys, fmt.Errorf("failed to upload %s: %w", objectName, err)
+		}
+
+		objectKeys[filePath] = objectName
+	}
+
+	log.Printf("Uploaded %d extracted files to %s/%s for job %s", len(objectKeys), bucket, prefix, job.ID)
+
+	return objectKeys, nil
+}
+
+// extractionLineage maps the object key of every file that came out of a
+// nested archive to its source chain: the job's archive first, followed by the
+// object keys of the nested archives it was found in.
+func extractionLineage(job *jobs.Job, extraction ExtractionResult, objectKeys map[string]string) map[string][]string {
+	lineage := make(map[string][]string, len(extraction.Lineage))
+	for filePath, chain := range extraction.Lineage {
+		key, ok := objectKeys[filePath]
+		if !ok {
+			continue
+		}
+		sources := []string{job.ObjectName}
+		for _, archive := range chain {
+			if archiveKey, ok := objectKeys[archive]; ok {
+				sources = append(sources, archiveKey)
+			}
+		}
+		lineage[key] = sources
+	}
+	return lineage
+}
+
 func (fp *FileProcessor) processExtractedFiles(ctx context.Context, job *jobs.Job, extractedFiles []string) error {
 	for _, filePath := range extractedFiles {
 		select {
@@ -167,34 +504,147 @@ func (fp *FileProcessor) processSingleFile(ctx context.Context, job *jobs.Job, f
 	return nil
 }
 
-func (fp *FileProcessor) uploadProcessedResults(ctx context.Context, job *jobs.Job, result jobs.JobResult) error {
-	resultsPath := filepath.Join(fp.config.Processing.TempDir, job.ID+"_results.json")
+// uploadProcessedResults stores the full result as the job's result
+// artifact, then cuts the extracted files the job itself carries to
+// jobs.InlineFileLimit so that job listings stay small.
+func (fp *FileProcessor) uploadProcessedResults(ctx context.Context, job *jobs.Job, result *jobs.JobResult) error {
+	if fp.minioClient == nil {
+		return nil
+	}
 
-	file, err := os.Create(resultsPath)
-	if err != nil {
+	if err := jobs.SaveArtifact(ctx, fp.minioClient, job, jobs.ArtifactResult, result); err != nil {
 		return err
 	}
-	defer file.Close()
 
+	if result.Truncate(jobs.InlineFileLimit) {
+		// Both repeat every extracted file
+		delete(result.FileInfo, "extraction_result")
+		delete(result.FileInfo, "lineage")
+	}
 	return nil
 }
 
+// ExtractionManifest lists what an archive job extracted, relative to the
+// extraction root.
+type ExtractionManifest struct {
+	JobID      string          `json:"job_id"`
+	Bucket     string          `json:"bucket"`
+	ObjectName string          `json:"object_name"`
+	ETag       string          `json:"etag,omitempty"`
+	Format     string          `json:"format"`
+	FileCount  int             `json:"file_count"`
+	TotalSize  int64           `json:"total_size"`
+	Files      []ManifestEntry `json:"files"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type ManifestEntry struct {
+	Path   string `json:"path"`
+	Object string `json:"object,omitempty"`
+	Size   int64  `json:"size"`
+}
+
+func (fp *FileProcessor) uploadManifest(ctx context.Context, job *jobs.Job, extractDir string, extraction ExtractionResult, objectKeys map[string]string) error {
+	entries := make([]ManifestEntry, 0, len(extraction.ExtractedFiles))
+	for _, filePath := range extraction.ExtractedFiles {
+		entry := ManifestEntry{Path: filePath, Object: objectKeys[filePath]}
+		if rel, err := filepath.Rel(extractDir, filePath); err == nil {
+			entry.Path = filepath.ToSlash(rel)
+		}
+		if info, err := os.Stat(filePath); err == nil {
+			entry.Size = info.Size()
+		}
+		entries = append(entries, entry)
+	}
+
+	return fp.saveManifest(ctx, job, extraction.ArchiveInfo.Format, entries)
+}
+
+func (fp *FileProcessor) saveManifest(ctx context.Context, job *jobs.Job, format string, entries []ManifestEntry) error {
+	if fp.minioClient == nil {
+		return nil
+	}
+
+	manifest := ExtractionManifest{
+		JobID:      job.ID,
+		Bucket:     job.Bucket,
+		ObjectName: job.ObjectName,
+		ETag:       job.ETag,
+		Format:     format,
+		FileCount:  len(entries),
+		Files:      entries,
+		CreatedAt:  time.Now(),
+	}
+	for _, entry := range entries {
+		manifest.TotalSize += entry.Size
+	}
+
+	return jobs.SaveArtifact(ctx, fp.minioClient, job, jobs.ArtifactManifest, manifest)
+}
+
+// failJob uploads an error report for the failed stage and builds the failed
+// job result. Archives failing to be read count towards their quarantine.
+func (fp *FileProcessor) failJob(ctx context.Context, job *jobs.Job, startTime time.Time, stage string, err error) jobs.JobResult {
+	var quarantined *quarantine.Report
+	if stage == "detect" || stage == "extract" {
+		var qErr error
+		quarantined, qErr = fp.quarantine.Fail(ctx, job.ObjectName, quarantine.Failure{
+			Source: quarantine.SourceExtraction,
+			Stage:  stage,
+			JobID:  job.ID,
+			Err:    err,
+		})
+		if qErr != nil {
+			log.Printf("Warning: Failed to quarantine %s: %v", job.ObjectName, qErr)
+		}
+	}
+
+	if fp.minioClient != nil {
+		report := map[string]any{
+			"job_id":      job.ID,
+			"type":        job.Type,
+			"bucket":      job.Bucket,
+			"object_name": job.ObjectName,
+			"stage":       stage,
+			"error":       err.Error(),
+			"failed_at":   time.Now(),
+		}
+		if quarantined != nil {
+			report["quarantined_as"] = quarantined.QuarantinedAs
+		}
+		if uploadErr := jobs.SaveArtifact(ctx, fp.minioClient, job, jobs.ArtifactError, report); uploadErr != nil {
+			log.Printf("Warning: Failed to upload error report for job %s: %v", job.ID, uploadErr)
+		}
+	}
+
+	return jobs.JobResult{
+		Success:        false,
+		ProcessingTime: time.Since(startTime),
+		Message:        err.Error(),
+	}
+}
+
 func (fp *FileProcessor) GetSupportedFormats() []string {
-	return fp.decompressor.GetSupportedFormats()
+	_, decompressor := fp.extraction()
+	return decompressor.GetSupportedFormats()
 }
 
 func (fp *FileProcessor) GetProcessingStats() map[string]any {
+	decompression, _ := fp.extraction()
 	return map[string]any{
 		"supported_formats": fp.GetSupportedFormats(),
 		"temp_dir":          fp.config.Processing.TempDir,
 		"max_workers":       fp.config.Processing.MaxWorkers,
 		"decompression": map[string]any{
-			"enabled":               fp.config.Processing.Decompression.Enabled,
-			"max_extract_size":      fp.config.Processing.Decompression.MaxExtractSize,
-			"max_files_per_archive": fp.config.Processing.Decompression.MaxFilesPerArchive,
-			"nested_archive_depth":  fp.config.Processing.Decompression.NestedArchiveDepth,
-			"password_protected":    fp.config.Processing.Decompression.PasswordProtected,
-			"extract_to_subfolder":  fp.config.Processing.Decompression.ExtractToSubfolder,
+			"enabled":               decompression.Enabled,
+			"max_extract_size":      decompression.MaxExtractSize,
+			"max_files_per_archive": decompression.MaxFilesPerArchive,
+			"max_compression_ratio": decompression.MaxCompressionRatio,
+			"nested_archive_depth":  decompression.NestedArchiveDepth,
+			"password_protected":    decompression.PasswordProtected,
+			"extract_to_subfolder":  decompression.ExtractToSubfolder,
+			"extract_prefix":        decompression.ExtractPrefix,
+			"stream_threshold":      decompression.StreamThreshold,
 		},
 	}
 }
@@ -0,0 +1,271 @@
+package files
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/minio/minio-go/v7"
+
+	"bronze-backend/httputil"
+	"bronze-backend/tenant"
+)
+
+// Limits of a grep; a request can lower them but not raise them
+const (
+	DefaultGrepMatches = 1000
+	MaxGrepMatches     = 10000
+	MaxGrepBytes       = 1 << 30 // Read from the objects, decompressed
+	DefaultGrepTimeout = 60 * time.Second
+	MaxGrepTimeout     = 300 * time.Second
+)
+
+// Lines longer than maxGrepLine end the scan of their object, and matched
+// lines are cut to maxGrepText bytes.
+const (
+	maxGrepLine = 1 << 20
+	maxGrepText = 1000
+)
+
+// grepExtensions are the text-like objects a grep reads by default, also
+// gzipped (e.g. dump.csv.gz).
+var grepExtensions = []string{".txt", ".csv", ".tsv", ".psv", ".json", ".jsonl", ".ndjson", ".log", ".xml", ".sql", ".md", ".yaml", ".yml"}
+
+// GrepRequest searches the lines of the objects under a prefix for a
+// regular expression (RE2 syntax).
+type GrepRequest struct {
+	Prefix     string `json:"prefix"`
+	Pattern    string `json:"pattern"`
+	IgnoreCase bool   `json:"ignore_case,omitempty"`
+	// Extensions replaces the extensions of the objects read, e.g. [".dat"]
+	Extensions []string `json:"extensions,omitempty"`
+	MaxMatches int      `json:"max_matches,omitempty"`
+	MaxBytes   int64    `json:"max_bytes,omitempty"`
+	// TimeoutSeconds bounds the search, default 60
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// GrepMatch is a line matching a grep.
+type GrepMatch struct {
+	Key  string `json:"key"`
+	Line int    `json:"line"` // From 1
+	Text string `json:"text"`
+	// Cut is set when Text is the start of a longer line
+	Cut bool `json:"cut,omitempty"`
+}
+
+// GrepSkip is an object a grep could not read to its end.
+type GrepSkip struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+}
+
+// Reasons a grep stopped before reading every object
+const (
+	GrepStoppedMatches = "max_matches"
+	GrepStoppedBytes   = "max_bytes"
+	GrepStoppedTimeout = "timeout"
+)
+
+// GrepResponse lists the matching lines, in the order of the objects'
+// keys and of their lines.
+type GrepResponse struct {
+	Success      bool        `json:"success"`
+	Matches      []GrepMatch `json:"matches"`
+	Count        int         `json:"count"`
+	FilesScanned int         `json:"files_scanned"`
+	BytesScanned int64       `json:"bytes_scanned"`
+	Skipped      []GrepSkip  `json:"skipped,omitempty"`
+	// StoppedBy names the limit that ended the search early, if any
+	StoppedBy string `json:"stopped_by,omitempty"`
+}
+
+// errGrepBytes ends a grep that read its byte budget.
+var errGrepBytes = errors.New("grep byte budget spent")
+
+// grepBudget reads r, counting the bytes read against the budget of a
+// grep.
+type grepBudget struct {
+	r      io.Reader
+	budget *int64
+}
+
+func (b grepBudget) Read(p []byte) (int, error) {
+	if *b.budget <= 0 {
+		return 0, errGrepBytes
+	}
+	if int64(len(p)) > *b.budget {
+		p = p[:*b.budget]
+	}
+	n, err := b.r.Read(p)
+	*b.budget -= int64(n)
+	return n, err
+}
+
+// GrepFiles answers the lines of the text-like objects under a prefix that
+// match a pattern, within the match, byte and time limits of the request.
+func (h *FileHandler) GrepFiles(w http.ResponseWriter, r *http.Request) {
+	var req GrepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httputil.WriteError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+	if req.Pattern == "" {
+		httputil.WriteError(w, "pattern is required", http.StatusBadRequest, nil)
+		return
+	}
+	pattern := req.Pattern
+	if req.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		httputil.WriteError(w, "Invalid pattern: "+err.Error(), http.StatusBadRequest, err)
+		return
+	}
+
+	maxMatches := DefaultGrepMatches
+	if req.MaxMatches > 0 {
+		maxMatches = min(req.MaxMatches, MaxGrepMatches)
+	}
+	budget := int64(MaxGrepBytes)
+	if req.MaxBytes > 0 {
+		budget = min(req.MaxBytes, MaxGrepBytes)
+	}
+	timeout := DefaultGrepTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = min(time.Duration(req.TimeoutSeconds)*time.Second, MaxGrepTimeout)
+	}
+	extensions := grepExtensions
+	if len(req.Extensions) > 0 {
+		extensions = req.Extensions
+	}
+
+	prefix := strings.TrimPrefix(req.Prefix, "/")
+	if prefix == "" {
+		prefix = tenant.Prefix(r.Context())
+	}
+	bucket, err := h.minioClient.Scope(r.Context(), prefix)
+	if err != nil {
+		httputil.WriteError(w, "Failed to search files", http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	limit := budget
+	response := GrepResponse{Success: true, Matches: []GrepMatch{}}
+	client := h.minioClient.GetClient()
+objects:
+	for object := range client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			response.StoppedBy = GrepStoppedTimeout
+			break objects
+		case object.Err != nil:
+			httputil.WriteError(w, "Failed to list files", http.StatusInternalServerError, object.Err)
+			return
+		case !grepable(object.Key, extensions):
+			continue
+		}
+
+		err := h.grepObject(ctx, object.Key, re, &budget, func(match GrepMatch) bool {
+			response.Matches = append(response.Matches, match)
+			return len(response.Matches) < maxMatches
+		})
+		response.FilesScanned++
+		switch {
+		case err == nil:
+		case errors.Is(err, errGrepBytes):
+			response.StoppedBy = GrepStoppedBytes
+			break objects
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			response.StoppedBy = GrepStoppedTimeout
+			break objects
+		case ctx.Err() != nil:
+			return // The client went away
+		default:
+			response.Skipped = append(response.Skipped, GrepSkip{Key: object.Key, Reason: err.Error()})
+		}
+		if len(response.Matches) >= maxMatches {
+			response.StoppedBy = GrepStoppedMatches
+			break objects
+		}
+	}
+
+	response.Count = len(response.Matches)
+	response.BytesScanned = limit - budget
+	httputil.WriteJSON(w, http.StatusOK, response)
+}
+
+// grepable reports whether key has one of extensions, possibly gzipped.
+func grepable(key string, extensions []string) bool {
+	name := strings.TrimSuffix(strings.ToLower(key), ".gz")
+	ext := path.Ext(name)
+	for _, allowed := range extensions {
+		if strings.EqualFold(ext, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// grepObject sends the lines of key matching re to found, until it
+// returns false or the object ends.
+func (h *FileHandler) grepObject(ctx context.Context, key string, re *regexp.Regexp, budget *int64, found func(GrepMatch) bool) error {
+	object, err := h.minioClient.DownloadFile(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	var reader io.Reader = object
+	if strings.HasSuffix(strings.ToLower(key), ".gz") {
+		gz, err := decompressReader("gzip", object)
+		if err != nil {
+			return fmt.Errorf("failed to decompress: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	return grepLines(key, grepBudget{r: reader, budget: budget}, re, found)
+}
+
+// grepLines sends the lines of r matching re to found, as lines of key,
+// until it returns false or r ends.
+func grepLines(key string, r io.Reader, re *regexp.Regexp, found func(GrepMatch) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxGrepLine)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Bytes()
+		if !re.Match(text) {
+			continue
+		}
+		match := GrepMatch{Key: key, Line: line, Text: string(text)}
+		if len(text) > maxGrepText {
+			cut := maxGrepText
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			match.Text, match.Cut = string(text[:cut]), true
+		}
+		if !found(match) {
+			return nil
+		}
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return fmt.Errorf("a line is longer than %d bytes", maxGrepLine)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,62 @@
+package files
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGrepable(t *testing.T) {
+	for key, want := range map[string]bool{
+		"dumps/orders.csv":     true,
+		"dumps/orders.CSV.GZ":  true,
+		"logs/app.log":         true,
+		"dumps/orders.xlsx":    false,
+		"archives/dump.tar.gz": false,
+		"README":               false,
+	} {
+		if got := grepable(key, grepExtensions); got != want {
+			t.Errorf("grepable(%q) = %v, want %v", key, got, want)
+		}
+	}
+	if !grepable("export/orders.dat", []string{".DAT"}) {
+		t.Error("requested extension not grepable")
+	}
+}
+
+func TestGrepLines(t *testing.T) {
+	input := "id,name\n42,Ann\n7,Bob\n142,Cy\n" + "42," + strings.Repeat("é", 600) + "\n"
+	var matches []GrepMatch
+	err := grepLines("orders.csv", strings.NewReader(input), regexp.MustCompile(`^42,`), func(match GrepMatch) bool {
+		matches = append(matches, match)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || matches[0].Line != 2 || matches[0].Text != "42,Ann" || matches[1].Line != 5 {
+		t.Fatalf("matches = %+v", matches)
+	}
+	if long := matches[1]; !long.Cut || len(long.Text) > maxGrepText || !strings.HasPrefix(long.Text, "42,é") || strings.ContainsRune(long.Text, '�') {
+		t.Errorf("long line = %q (cut %v)", long.Text, long.Cut)
+	}
+
+	// found returning false stops the scan
+	var first []GrepMatch
+	grepLines("orders.csv", strings.NewReader(input), regexp.MustCompile(`\d`), func(match GrepMatch) bool {
+		first = append(first, match)
+		return false
+	})
+	if len(first) != 1 {
+		t.Errorf("matches after stopping = %d, want 1", len(first))
+	}
+}
+
+func TestGrepBudget(t *testing.T) {
+	budget := int64(10)
+	err := grepLines("big.log", grepBudget{r: strings.NewReader(strings.Repeat("line\n", 10)), budget: &budget}, regexp.MustCompile("x"), func(GrepMatch) bool { return true })
+	if !errors.Is(err, errGrepBytes) || budget != 0 {
+		t.Errorf("err = %v, budget left %d", err, budget)
+	}
+}
@@ -0,0 +1,243 @@
+package files
+
+import (
+	"archive/tar"
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/yeka/zip"
+)
+
+// streamBlockSize is how much of the source a streaming zip extraction
+// buffers per read. Zip entries are read at random offsets, and every miss
+// costs one ranged GET, so blocks are large but memory stays bounded.
+const streamBlockSize = 8 << 20
+
+// StreamSource is a remote archive that can be read sequentially (tar) or
+// at random offsets (zip). *minio.Object implements it.
+type StreamSource interface {
+	io.Reader
+	io.ReaderAt
+}
+
+// EntryWriter receives each regular file of a streamed archive. The reader
+// is only valid until the call returns. size is -1 for a single compressed
+// file, whose decompressed size is not known up front.
+type EntryWriter func(name string, reader io.Reader, size int64) error
+
+// StreamedEntry describes one entry written by ExtractStream.
+type StreamedEntry struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// CanStream reports whether the archive can be extracted by ExtractStream.
+// 7z and RAR need local files, and nested archives are not expanded when
+// streaming.
+func (d *ArchiveExtractor) CanStream(name string) bool {
+	format := d.formatOf(name)
+	return extractableFormats[format] && format != "7z" && format != "rar"
+}
+
+// ExtractStream extracts a zip or tar archive without touching local disk,
+// handing each entry to write. Limits and path checks match ExtractArchive.
+func (d *ArchiveExtractor) ExtractStream(name string, source StreamSource, size int64, password string, write EntryWriter) ([]StreamedEntry, error) {
+	if !d.CanStream(name) {
+		return nil, fmt.Errorf("streaming extraction is not supported for %s", filepath.Base(name))
+	}
+
+	budget, err := newExtractionBudget(d.config, size)
+	if err != nil {
+		return nil, err
+	}
+
+	format := d.formatOf(name)
+	switch format {
+	case "zip":
+		return d.streamZip(newBlockReaderAt(source, size, streamBlockSize), size, password, budget, write)
+	case "tar":
+		return d.streamTar(tar.NewReader(source), budget, write)
+	default:
+		decompressed, err := decompressReader(format, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s stream: %w", format, err)
+		}
+		defer decompressed.Close()
+
+		reader := bufio.NewReaderSize(decompressed, 64*1024)
+		if isTarStream(reader) {
+			return d.streamTar(tar.NewReader(reader), budget, write)
+		}
+
+		entry, err := budget.stream(decompressedName(name), reader, -1, write)
+		if err != nil {
+			return nil, err
+		}
+		return []StreamedEntry{entry}, nil
+	}
+}
+
+func (d *ArchiveExtractor) streamZip(source io.ReaderAt, size int64, password string, budget *extractionBudget, write EntryWriter) ([]StreamedEntry, error) {
+	reader, err := zip.NewReader(source, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []StreamedEntry
+	for _, file := range reader.File {
+		if strings.HasSuffix(file.Name, "/") {
+			continue
+		}
+
+		if file.IsEncrypted() {
+			if err := d.checkPassword(password); err != nil {
+				return entries, err
+			}
+			file.SetPassword(password)
+		}
+
+		fileReader, err := file.Open()
+		if err != nil {
+			return entries, err
+		}
+
+		entry, err := budget.stream(file.Name, fileReader, int64(file.UncompressedSize64), write)
+		fileReader.Close()
+		if err != nil {
+			if file.IsEncrypted() && !isLimitError(err) {
+				return entries, fmt.Errorf("failed to decrypt %s, wrong password?: %w", file.Name, err)
+			}
+			return entries, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (d *ArchiveExtractor) streamTar(reader *tar.Reader, budget *extractionBudget, write EntryWriter) ([]StreamedEntry, error) {
+	var entries []StreamedEntry
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return entries, err
+		}
+
+		if !header.FileInfo().Mode().IsRegular() {
+			continue
+		}
+
+		entry, err := budget.stream(header.Name, reader, header.Size, write)
+		if err != nil {
+			return entries, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// stream hands one entry to write under the same checks as extract.
+func (b *extractionBudget) stream(name string, reader io.Reader, size int64, write EntryWriter) (StreamedEntry, error) {
+	// Resolve against a virtual directory so the same zip-slip rules apply;
+	// it must not be the filesystem root, where ".." is silently dropped
+	root := filepath.Join(string(filepath.Separator), "stream")
+	outputPath, err := safeJoin(root, name)
+	if err != nil {
+		return StreamedEntry{}, err
+	}
+	relative, err := filepath.Rel(root, outputPath)
+	if err != nil {
+		return StreamedEntry{}, fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	relative = filepath.ToSlash(relative)
+
+	b.files++
+	if b.maxFiles > 0 && b.files > b.maxFiles {
+		return StreamedEntry{}, fmt.Errorf("%w (%d)", ErrFileCountLimit, b.maxFiles)
+	}
+
+	if err := write(relative, &budgetReader{reader: reader, budget: b}, size); err != nil {
+		return StreamedEntry{}, err
+	}
+	return StreamedEntry{Name: relative, Size: size}, nil
+}
+
+// blockReaderAt serves ReadAt calls from one cached block of the source,
+// turning the small scattered reads of archive/zip into few large ones.
+type blockReaderAt struct {
+	source    io.ReaderAt
+	size      int64
+	blockSize int64
+	block     []byte
+	offset    int64
+}
+
+func newBlockReaderAt(source io.ReaderAt, size, blockSize int64) *blockReaderAt {
+	return &blockReaderAt{
+		source:    source,
+		size:      size,
+		blockSize: blockSize,
+		offset:    -1,
+	}
+}
+
+func (r *blockReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	// Large reads gain nothing from the cache
+	if int64(len(p)) >= r.blockSize {
+		return r.source.ReadAt(p, off)
+	}
+
+	read := 0
+	for read < len(p) && off < r.size {
+		if r.offset < 0 || off < r.offset || off >= r.offset+int64(len(r.block)) {
+			if err := r.fill(off); err != nil {
+				return read, err
+			}
+		}
+		n := copy(p[read:], r.block[off-r.offset:])
+		read += n
+		off += int64(n)
+	}
+
+	if read < len(p) {
+		return read, io.EOF
+	}
+	return read, nil
+}
+
+func (r *blockReaderAt) fill(off int64) error {
+	length := r.blockSize
+	if off+length > r.size {
+		length = r.size - off
+	}
+	if int64(cap(r.block)) < length {
+		r.block = make([]byte, length)
+	}
+	r.block = r.block[:length]
+
+	n, err := r.source.ReadAt(r.block, off)
+	if n == 0 {
+		r.offset = -1
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	r.block = r.block[:n]
+	r.offset = off
+	return nil
+}
@@ -0,0 +1,315 @@
+package files
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"bronze-backend/jobs"
+	"bronze-backend/storage"
+	"bronze-backend/tenant"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// VerifyProcessor runs "verify" jobs: it hashes every object under the job's
+// prefix and compares the result with a manifest file or with checksums
+// stored on the objects themselves.
+//
+// Job metadata:
+//   - algorithm: md5 (default), sha1 or sha256
+//   - manifest:  optional object holding expected checksums, either JSON
+//     ({"path": "hash"} or [{"path": ..., "hash": ...}]) or sha256sum-style
+//     "<hash>  <path>" lines; paths are relative to the prefix
+type VerifyProcessor struct {
+	minioClient *storage.MinIOClient
+}
+
+func NewVerifyProcessor(minioClient *storage.MinIOClient) *VerifyProcessor {
+	return &VerifyProcessor{
+		minioClient: minioClient,
+	}
+}
+
+type VerifyMismatch struct {
+	Object   string `json:"object"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Source   string `json:"source"` // "manifest", "metadata" or "etag"
+}
+
+type VerifyReport struct {
+	Bucket     string            `json:"bucket"`
+	Prefix     string            `json:"prefix"`
+	Algorithm  string            `json:"algorithm"`
+	Manifest   string            `json:"manifest,omitempty"`
+	Checked    int               `json:"checked"`
+	Matched    int               `json:"matched"`
+	Mismatched []VerifyMismatch  `json:"mismatched"`
+	Missing    []string          `json:"missing"`    // In the manifest but not in the bucket
+	Unverified []string          `json:"unverified"` // No expected checksum available
+	Checksums  map[string]string `json:"checksums"`
+}
+
+func newVerifyHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, use md5, sha1 or sha256", algorithm)
+	}
+}
+
+func (vp *VerifyProcessor) ProcessJob(ctx context.Context, job *jobs.Job) jobs.JobResult {
+	startTime := time.Now()
+
+	fail := func(format string, args ...any) jobs.JobResult {
+		return jobs.JobResult{
+			Success:        false,
+			ProcessingTime: time.Since(startTime),
+			Message:        fmt.Sprintf(format, args...),
+		}
+	}
+
+	if vp.minioClient == nil {
+		return fail("MinIO client not available")
+	}
+
+	algorithm, _ := job.Metadata["algorithm"].(string)
+	algorithm = strings.ToLower(algorithm)
+	if algorithm == "" {
+		algorithm = "md5"
+	}
+	if _, err := newVerifyHash(algorithm); err != nil {
+		return fail("%v", err)
+	}
+
+	bucket := job.Bucket
+	if bucket == "" {
+		bucket = vp.minioClient.GetBucketName()
+	}
+	prefix := job.ObjectName
+
+	log.Printf("Verifying %s checksums under %s/%s for job %s", algorithm, bucket, prefix, job.ID)
+
+	report := VerifyReport{
+		Bucket:     bucket,
+		Prefix:     prefix,
+		Algorithm:  algorithm,
+		Mismatched: []VerifyMismatch{},
+		Missing:    []string{},
+		Unverified: []string{},
+		Checksums:  make(map[string]string),
+	}
+
+	expected := map[string]string{}
+	if manifest, _ := job.Metadata["manifest"].(string); manifest != "" {
+		report.Manifest = manifest
+		if err := tenant.Check(ctx, bucket, manifest); err != nil {
+			return fail("%v", err)
+		}
+		var err error
+		expected, err = vp.loadManifest(ctx, bucket, manifest)
+		if err != nil {
+			return fail("Failed to load manifest: %v", err)
+		}
+	}
+
+	client := vp.minioClient.GetClient()
+	objects := make([]minio.ObjectInfo, 0)
+	for object := range client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return fail("Failed to list objects: %v", object.Err)
+		}
+		if object.Key == report.Manifest || strings.HasSuffix(object.Key, "/") {
+			continue
+		}
+		objects = append(objects, object)
+	}
+
+	seen := make(map[string]bool, len(objects))
+	for i, object := range objects {
+		select {
+		case <-ctx.Done():
+			return fail("Verification cancelled: %v", ctx.Err())
+		default:
+		}
+
+		relative := strings.TrimPrefix(strings.TrimPrefix(object.Key, prefix), "/")
+		seen[relative] = true
+
+		actual, err := vp.hashObject(ctx, bucket, object.Key, algorithm)
+		if err != nil {
+			return fail("Failed to hash %s: %v", object.Key, err)
+		}
+		report.Checked++
+		report.Checksums[object.Key] = actual
+
+		want, source := expected[relative], "manifest"
+		if want == "" {
+			want, source = vp.storedChecksum(ctx, bucket, object, algorithm)
+		}
+
+		switch {
+		case want == "":
+			report.Unverified = append(report.Unverified, object.Key)
+		case strings.EqualFold(want, actual):
+			report.Matched++
+		default:
+			report.Mismatched = append(report.Mismatched, VerifyMismatch{
+				Object:   object.Key,
+				Expected: strings.ToLower(want),
+				Actual:   actual,
+				Source:   source,
+			})
+		}
+
+		job.UpdateProgress(float64(i+1) / float64(len(objects)) * 95)
+	}
+
+	for relative := range expected {
+		if !seen[relative] {
+			report.Missing = append(report.Missing, path.Join(prefix, relative))
+		}
+	}
+	sort.Strings(report.Missing)
+
+	if err := jobs.SaveArtifact(ctx, vp.minioClient, job, "verify_report.json", report); err != nil {
+		log.Printf("Warning: Failed to upload verify report for job %s: %v", job.ID, err)
+	}
+
+	if len(report.Mismatched) > 0 || len(report.Missing) > 0 {
+		return fail("Verification failed: %d mismatched, %d missing of %d checked", len(report.Mismatched), len(report.Missing), report.Checked)
+	}
+
+	return jobs.JobResult{
+		Success:        true,
+		ProcessingTime: time.Since(startTime),
+		Message:        fmt.Sprintf("Verified %d objects (%d matched, %d without a stored checksum)", report.Checked, report.Matched, len(report.Unverified)),
+		Result:         report,
+	}
+}
+
+func (vp *VerifyProcessor) hashObject(ctx context.Context, bucket, objectName, algorithm string) (string, error) {
+	object, err := vp.minioClient.GetClient().GetObject(ctx, bucket, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer object.Close()
+
+	h, err := newVerifyHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(h, object); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// storedChecksum looks for an expected checksum in the object's user metadata
+// (e.g. X-Amz-Meta-Sha256 or X-Amz-Meta-Checksum-Sha256), falling back to the
+// ETag for md5 because single-part uploads use the content MD5 as their ETag.
+func (vp *VerifyProcessor) storedChecksum(ctx context.Context, bucket string, object minio.ObjectInfo, algorithm string) (string, string) {
+	info, err := vp.minioClient.GetClient().StatObject(ctx, bucket, object.Key, minio.StatObjectOptions{})
+	if err == nil {
+		for key, value := range info.UserMetadata {
+			name := strings.ToLower(key)
+			if name == algorithm || name == "checksum-"+algorithm {
+				return value, "metadata"
+			}
+		}
+	}
+
+	etag := strings.Trim(object.ETag, `"`)
+	if algorithm == "md5" && etag != "" && !strings.Contains(etag, "-") {
+		return etag, "etag"
+	}
+
+	return "", ""
+}
+
+func (vp *VerifyProcessor) loadManifest(ctx context.Context, bucket, manifest string) (map[string]string, error) {
+	object, err := vp.minioClient.GetClient().GetObject(ctx, bucket, manifest, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseChecksumManifest(data)
+}
+
+func parseChecksumManifest(data []byte) (map[string]string, error) {
+	checksums := make(map[string]string)
+
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "{") {
+		var entries map[string]string
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("invalid JSON manifest: %w", err)
+		}
+		for name, sum := range entries {
+			checksums[cleanManifestPath(name)] = sum
+		}
+		return checksums, nil
+	}
+
+	if strings.HasPrefix(trimmed, "[") {
+		var entries []struct {
+			Path string `json:"path"`
+			Hash string `json:"hash"`
+		}
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("invalid JSON manifest: %w", err)
+		}
+		for _, entry := range entries {
+			checksums[cleanManifestPath(entry.Path)] = entry.Hash
+		}
+		return checksums, nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(trimmed))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid manifest line: %q", line)
+		}
+		// sha256sum marks binary mode with a leading '*' on the path
+		name := strings.TrimPrefix(strings.Join(fields[1:], " "), "*")
+		checksums[cleanManifestPath(name)] = fields[0]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return checksums, nil
+}
+
+func cleanManifestPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
@@ -0,0 +1,171 @@
+// Package graphapi serves a read-only GraphQL view of files, jobs, exports
+// and watcher events, linked to each other so a page can follow a job to
+// its source file and on to the tables that file was exported to in one
+// request.
+package graphapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"bronze-backend/data_browser"
+	"bronze-backend/httputil"
+	"bronze-backend/jobs"
+	"bronze-backend/monitoring"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/minio/minio-go/v7"
+)
+
+// maxDepth bounds how deeply a query may nest fields, since objects link
+// back to each other (a file's jobs have source files with jobs...).
+const maxDepth = 10
+
+// FileStore reads objects from the bucket; *storage.MinIOClient is one.
+type FileStore interface {
+	GetFileInfo(ctx context.Context, objectName string) (minio.ObjectInfo, error)
+	ListFiles(ctx context.Context, prefix string, limit int) ([]minio.ObjectInfo, error)
+}
+
+// EventHistory is the watcher's stored events; *monitoring.FileWatcher is
+// one.
+type EventHistory interface {
+	GetEventHistory(limit int) ([]*monitoring.FileEvent, error)
+}
+
+// ExportHistory lists the recent exports; *data_browser.ExportHandler is
+// one.
+type ExportHistory interface {
+	RecentExports() []data_browser.ExportRecord
+}
+
+// Sources are where queries read from. Files and Events are nil while MinIO
+// or the watcher is unavailable; fields that need them then fail with an
+// error while the rest of the query still resolves.
+type Sources struct {
+	Files   FileStore
+	Jobs    jobs.Queue
+	Exports ExportHistory
+	Events  EventHistory
+}
+
+// Request is a GraphQL request body.
+type Request struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	OperationName string         `json:"operationName,omitempty"`
+}
+
+// Handler serves GraphQL queries.
+type Handler struct {
+	schema  graphql.Schema
+	sources Sources
+}
+
+func NewHandler(sources Sources) (*Handler, error) {
+	schema, err := newSchema()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build GraphQL schema: %w", err)
+	}
+	return &Handler{schema: schema, sources: sources}, nil
+}
+
+// Serve answers a query sent as a JSON body to POST, or as the query,
+// variables and operationName parameters of GET. Errors in the query itself
+// are reported in the result's errors with status 200, as GraphQL clients
+// expect.
+func (h *Handler) Serve(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				httputil.WriteError(w, "Invalid variables", http.StatusBadRequest, err)
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httputil.WriteError(w, "Invalid GraphQL request", http.StatusBadRequest, err)
+		return
+	}
+	if req.Query == "" {
+		httputil.Error(w, "Query is required", http.StatusBadRequest)
+		return
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, h.Execute(r.Context(), req))
+}
+
+// Execute runs a query.
+func (h *Handler) Execute(ctx context.Context, req Request) *graphql.Result {
+	if err := checkDepth(req.Query); err != nil {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)}}
+	}
+	return graphql.Do(graphql.Params{
+		Schema:         h.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        withLoader(ctx, h.sources),
+	})
+}
+
+// checkDepth rejects queries nesting fields more than maxDepth deep. Syntax
+// errors are left for execution to report.
+func checkDepth(query string) error {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(query)})})
+	if err != nil {
+		return nil
+	}
+
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, definition := range doc.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	var depth func(set *ast.SelectionSet, visiting map[string]bool) int
+	depth = func(set *ast.SelectionSet, visiting map[string]bool) int {
+		if set == nil {
+			return 0
+		}
+		deepest := 0
+		for _, selection := range set.Selections {
+			var d int
+			switch selection := selection.(type) {
+			case *ast.Field:
+				d = 1 + depth(selection.SelectionSet, visiting)
+			case *ast.InlineFragment:
+				d = depth(selection.SelectionSet, visiting)
+			case *ast.FragmentSpread:
+				// Fragment cycles are reported by validation
+				name := selection.Name.Value
+				if fragment, ok := fragments[name]; ok && !visiting[name] {
+					visiting[name] = true
+					d = depth(fragment.SelectionSet, visiting)
+					delete(visiting, name)
+				}
+			}
+			deepest = max(deepest, d)
+		}
+		return deepest
+	}
+
+	for _, definition := range doc.Definitions {
+		if operation, ok := definition.(*ast.OperationDefinition); ok {
+			if d := depth(operation.SelectionSet, map[string]bool{}); d > maxDepth {
+				return fmt.Errorf("query is %d levels deep, the limit is %d", d, maxDepth)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package graphapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bronze-backend/data_browser"
+	"bronze-backend/jobs"
+	"bronze-backend/monitoring"
+
+	"github.com/minio/minio-go/v7"
+)
+
+type fakeFiles map[string]minio.ObjectInfo
+
+func (f fakeFiles) GetFileInfo(_ context.Context, name string) (minio.ObjectInfo, error) {
+	if info, ok := f[name]; ok {
+		return info, nil
+	}
+	return minio.ObjectInfo{}, minio.ErrorResponse{Code: "NoSuchKey"}
+}
+
+func (f fakeFiles) ListFiles(_ context.Context, prefix string, limit int) ([]minio.ObjectInfo, error) {
+	var list []minio.ObjectInfo
+	for key, info := range f {
+		if strings.HasPrefix(key, prefix) && len(list) < limit {
+			list = append(list, info)
+		}
+	}
+	return list, nil
+}
+
+type fakeExports []data_browser.ExportRecord
+
+func (f fakeExports) RecentExports() []data_browser.ExportRecord { return f }
+
+type fakeEvents []*monitoring.FileEvent
+
+func (f fakeEvents) GetEventHistory(int) ([]*monitoring.FileEvent, error) { return f, nil }
+
+func newTestHandler(t *testing.T) (*Handler, *jobs.Job) {
+	t.Helper()
+	queue := jobs.NewJobQueue(1, 10)
+	job := jobs.NewJob("extract", "", "bronze", "uploads/a.csv", jobs.PriorityMedium)
+	job.Artifacts = map[string]string{"report": "reports/a.json"}
+	if err := queue.Enqueue(job); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := NewHandler(Sources{
+		Files: fakeFiles{
+			"uploads/a.csv":  {Key: "uploads/a.csv", Size: 42},
+			"reports/a.json": {Key: "reports/a.json", Size: 7},
+		},
+		Jobs: queue,
+		Exports: fakeExports{
+			{ID: "e2", TableName: "sales", Database: "db", Files: []string{"uploads/a.csv", "uploads/gone.csv"}, Success: true, StartedAt: time.Now()},
+			{ID: "e1", TableName: "other", Database: "db", Files: []string{"uploads/b.csv"}, Success: true, StartedAt: time.Now()},
+		},
+		Events: fakeEvents{
+			{ID: "ev1", Key: "uploads/a.csv", EventType: "created", EventTime: time.Now()},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, job
+}
+
+func execute(t *testing.T, h *Handler, query string, variables map[string]any) map[string]any {
+	t.Helper()
+	result := h.Execute(context.Background(), Request{Query: query, Variables: variables})
+	if result.HasErrors() {
+		t.Fatalf("query failed: %v", result.Errors)
+	}
+	data, _ := json.Marshal(result.Data)
+	var decoded map[string]any
+	json.Unmarshal(data, &decoded)
+	return decoded
+}
+
+func TestLineageQuery(t *testing.T) {
+	h, job := newTestHandler(t)
+
+	data := execute(t, h, `query($id: ID!) {
+		job(id: $id) {
+			type
+			artifacts { name file { size } }
+			sourceFile {
+				size
+				events { eventType }
+				exports { id table { name sourceFiles { key } } }
+			}
+		}
+	}`, map[string]any{"id": job.ID})
+
+	got, _ := json.Marshal(data["job"])
+	want := `{"artifacts":[{"file":{"size":7},"name":"report"}],"sourceFile":{"events":[{"eventType":"created"}],"exports":[{"id":"e2","table":{"name":"sales","sourceFiles":[{"key":"uploads/a.csv"}]}}],"size":42},"type":"extract"}`
+	if string(got) != want {
+		t.Errorf("job = %s\nwant %s", got, want)
+	}
+}
+
+func TestQueryFiltersAndMissingSources(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	data := execute(t, h, `{ exports(table: "other") { id } file(key: "nope") { key } }`, nil)
+	if got, _ := json.Marshal(data); string(got) != `{"exports":[{"id":"e1"}],"file":null}` {
+		t.Errorf("data = %s", got)
+	}
+
+	// A missing source fails its fields only
+	h, err := NewHandler(Sources{Jobs: jobs.NewJobQueue(1, 10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := h.Execute(context.Background(), Request{Query: `{ jobs { id } files { key } }`})
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "MinIO is not available") {
+		t.Errorf("errors = %v, want MinIO unavailable", result.Errors)
+	}
+	if jobs := result.Data.(map[string]any)["jobs"]; jobs == nil {
+		t.Errorf("jobs = nil, want an empty list")
+	}
+}
+
+func TestDepthLimit(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	deep := `{ files { jobs { sourceFile { jobs { sourceFile { jobs { sourceFile { jobs { sourceFile { jobs { id } } } } } } } } } } }`
+	result := h.Execute(context.Background(), Request{Query: deep})
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "limit is 10") {
+		t.Errorf("errors = %v, want the depth limit", result.Errors)
+	}
+
+	// Fragments count towards the depth, and cycles do not loop
+	cyclic := `{ files { ...F } } fragment F on File { jobs { sourceFile { ...F } } }`
+	if result := h.Execute(context.Background(), Request{Query: cyclic}); !result.HasErrors() {
+		t.Errorf("cyclic fragments were not rejected")
+	}
+}
+
+func TestServe(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+		body   string
+	}{
+		{"post", httptest.NewRequest("POST", "/api/graphql", strings.NewReader(`{"query":"{ table(name: \"sales\") { exports { id } } }"}`)), http.StatusOK, `"id":"e2"`},
+		{"get", httptest.NewRequest("GET", `/api/graphql?query={file(key:"uploads/a.csv"){size}}`, nil), http.StatusOK, `"size":42`},
+		{"query errors", httptest.NewRequest("GET", "/api/graphql?query={nope}", nil), http.StatusOK, `"errors"`},
+		{"no query", httptest.NewRequest("POST", "/api/graphql", strings.NewReader(`{}`)), http.StatusBadRequest, "Query is required"},
+		{"bad variables", httptest.NewRequest("GET", "/api/graphql?query={files{key}}&variables=x", nil), http.StatusBadRequest, "Invalid variables"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.Serve(w, tt.req)
+			if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.body) {
+				t.Errorf("got %d %s, want %d containing %s", w.Code, w.Body.String(), tt.status, tt.body)
+			}
+		})
+	}
+}
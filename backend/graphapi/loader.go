@@ -0,0 +1,222 @@
+package graphapi
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"bronze-backend/data_browser"
+	"bronze-backend/jobs"
+	"bronze-backend/monitoring"
+	"bronze-backend/tenant"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// maxEvents is how many stored watcher events a query looks through.
+const maxEvents = 1000
+
+// loader reads the sources for one query, fetching each list once however
+// many fields of the query need it. A tenant's query only sees the tenant's
+// own jobs, exports and events.
+type loader struct {
+	src    Sources
+	tenant *tenant.Tenant // Nil for deployment-wide queries
+
+	mu        sync.Mutex
+	fileCache map[string]*minio.ObjectInfo // Nil for objects that do not exist
+
+	jobsOnce sync.Once
+	jobList  []*jobs.Job // Newest first
+
+	exportsOnce sync.Once
+	exportList  []data_browser.ExportRecord
+
+	eventsOnce sync.Once
+	eventList  []*monitoring.FileEvent
+	eventsErr  error
+}
+
+type loaderKey struct{}
+
+func withLoader(ctx context.Context, src Sources) context.Context {
+	l := &loader{src: src, fileCache: make(map[string]*minio.ObjectInfo)}
+	l.tenant, _ = tenant.FromContext(ctx)
+	return context.WithValue(ctx, loaderKey{}, l)
+}
+
+func loaderFrom(ctx context.Context) *loader {
+	return ctx.Value(loaderKey{}).(*loader)
+}
+
+// file returns the object's info, or nil if it does not exist.
+func (l *loader) file(ctx context.Context, key string) (*minio.ObjectInfo, error) {
+	if l.src.Files == nil {
+		return nil, errUnavailable("MinIO")
+	}
+
+	l.mu.Lock()
+	info, ok := l.fileCache[key]
+	l.mu.Unlock()
+	if ok {
+		return info, nil
+	}
+
+	stat, err := l.src.Files.GetFileInfo(ctx, key)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+			return nil, err
+		}
+	} else {
+		info = &stat
+	}
+
+	l.mu.Lock()
+	l.fileCache[key] = info
+	l.mu.Unlock()
+	return info, nil
+}
+
+// files returns the info of the objects of keys that exist.
+func (l *loader) files(ctx context.Context, keys []string) ([]*minio.ObjectInfo, error) {
+	infos := make([]*minio.ObjectInfo, 0, len(keys))
+	for _, key := range keys {
+		info, err := l.file(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if info != nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+// list returns the objects below prefix, caching them for file lookups.
+func (l *loader) list(ctx context.Context, prefix string, limit int) ([]*minio.ObjectInfo, error) {
+	if l.src.Files == nil {
+		return nil, errUnavailable("MinIO")
+	}
+	if limit <= 0 {
+		return []*minio.ObjectInfo{}, nil
+	}
+
+	objects, err := l.src.Files.ListFiles(ctx, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]*minio.ObjectInfo, len(objects))
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range objects {
+		infos[i] = &objects[i]
+		l.fileCache[objects[i].Key] = infos[i]
+	}
+	return infos, nil
+}
+
+// job returns the job with id, if the query may see it.
+func (l *loader) job(id string) (*jobs.Job, bool) {
+	job, ok := l.src.Jobs.GetJob(id)
+	if !ok || (l.tenant != nil && job.Tenant != l.tenant.Name) {
+		return nil, false
+	}
+	return job, true
+}
+
+func (l *loader) allJobs() []*jobs.Job {
+	l.jobsOnce.Do(func() {
+		for _, job := range l.src.Jobs.ListJobs() {
+			if l.tenant == nil || job.Tenant == l.tenant.Name {
+				l.jobList = append(l.jobList, job)
+			}
+		}
+		sort.SliceStable(l.jobList, func(i, j int) bool {
+			return l.jobList[i].CreatedAt.After(l.jobList[j].CreatedAt)
+		})
+	})
+	return l.jobList
+}
+
+// jobsFor returns the jobs run on the object, newest first.
+func (l *loader) jobsFor(key string) []*jobs.Job {
+	matched := []*jobs.Job{}
+	for _, job := range l.allJobs() {
+		if job.ObjectName == key {
+			matched = append(matched, job)
+		}
+	}
+	return matched
+}
+
+// extractedFrom returns the archives the object was extracted from,
+// outermost first, as recorded in the lineage of the extraction job.
+func (l *loader) extractedFrom(key string) []string {
+	for _, job := range l.allJobs() {
+		var chain any
+		switch lineage := job.Metadata["lineage"].(type) {
+		case map[string][]string:
+			chain = lineage[key]
+		case map[string]any: // Decoded from the Redis queue or the state file
+			chain = lineage[key]
+		}
+
+		switch chain := chain.(type) {
+		case []string:
+			return chain
+		case []any:
+			keys := make([]string, 0, len(chain))
+			for _, source := range chain {
+				if s, ok := source.(string); ok {
+					keys = append(keys, s)
+				}
+			}
+			return keys
+		}
+	}
+	return nil
+}
+
+// exports returns the recent exports matching match, newest first.
+func (l *loader) exports(match func(data_browser.ExportRecord) bool) []data_browser.ExportRecord {
+	l.exportsOnce.Do(func() {
+		if l.src.Exports == nil {
+			return
+		}
+		for _, record := range l.src.Exports.RecentExports() {
+			if l.tenant == nil || record.Tenant == l.tenant.Name {
+				l.exportList = append(l.exportList, record)
+			}
+		}
+	})
+	matched := []data_browser.ExportRecord{}
+	for _, record := range l.exportList {
+		if match(record) {
+			matched = append(matched, record)
+		}
+	}
+	return matched
+}
+
+// events returns the stored watcher events matching match, newest first.
+func (l *loader) events(match func(*monitoring.FileEvent) bool) ([]*monitoring.FileEvent, error) {
+	if l.src.Events == nil {
+		return nil, errUnavailable("File watcher")
+	}
+	l.eventsOnce.Do(func() {
+		l.eventList, l.eventsErr = l.src.Events.GetEventHistory(maxEvents)
+	})
+	if l.eventsErr != nil {
+		return nil, l.eventsErr
+	}
+	matched := []*monitoring.FileEvent{}
+	for _, event := range l.eventList {
+		if l.tenant != nil && !tenant.Allows(l.tenant, event.Bucket, event.Key) {
+			continue
+		}
+		if match(event) {
+			matched = append(matched, event)
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,434 @@
+package graphapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"bronze-backend/data_browser"
+	"bronze-backend/jobs"
+	"bronze-backend/monitoring"
+
+	"github.com/graphql-go/graphql"
+	"github.com/minio/minio-go/v7"
+)
+
+// defaultLimit caps list fields that are not given a limit.
+const defaultLimit = 100
+
+func limitArg(defaultValue int) *graphql.ArgumentConfig {
+	return &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultValue, Description: "Maximum number of results"}
+}
+
+func stringArg(p graphql.ResolveParams, name string) string {
+	value, _ := p.Args[name].(string)
+	return value
+}
+
+func intArg(p graphql.ResolveParams, name string) int {
+	value, _ := p.Args[name].(int)
+	return value
+}
+
+// truncate returns at most limit items; a limit of 0 or less returns none.
+func truncate[T any](items []T, limit int) []T {
+	if limit <= 0 {
+		return []T{}
+	}
+	if len(items) > limit {
+		return items[:limit]
+	}
+	return items
+}
+
+// newSchema builds the schema. Objects refer to each other, so their fields
+// are thunks resolved once every type exists. Fields reading MinIO or the
+// watcher are nullable, so when either is unavailable only they become null.
+func newSchema() (graphql.Schema, error) {
+	var fileType, jobType, exportType, tableType, eventType *graphql.Object
+
+	artifactType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Artifact",
+		Description: "A file a job produced",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"name":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"objectKey": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"file": &graphql.Field{
+					Type: fileType,
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return loaderFrom(p.Context).file(p.Context, p.Source.(artifact).ObjectKey)
+					},
+				},
+			}
+		}),
+	})
+
+	fileType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "File",
+		Description: "An object in the bucket",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"key":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"size":         &graphql.Field{Type: graphql.Float, Description: "Size in bytes"},
+				"etag":         &graphql.Field{Type: graphql.String},
+				"lastModified": &graphql.Field{Type: graphql.DateTime},
+				"contentType":  &graphql.Field{Type: graphql.String},
+				"jobs": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(jobType))),
+					Description: "Jobs run on this file, newest first",
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return loaderFrom(p.Context).jobsFor(p.Source.(*minio.ObjectInfo).Key), nil
+					},
+				},
+				"exports": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(exportType))),
+					Description: "Exports that read this file, newest first",
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return loaderFrom(p.Context).exports(func(e data_browser.ExportRecord) bool {
+							return exportedFile(e, p.Source.(*minio.ObjectInfo).Key)
+						}), nil
+					},
+				},
+				"events": &graphql.Field{
+					Type:        graphql.NewList(graphql.NewNonNull(eventType)),
+					Description: "Watcher events for this file, newest first",
+					Args:        graphql.FieldConfigArgument{"limit": limitArg(20)},
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						key := p.Source.(*minio.ObjectInfo).Key
+						events, err := loaderFrom(p.Context).events(func(e *monitoring.FileEvent) bool { return e.Key == key })
+						return truncate(events, intArg(p, "limit")), err
+					},
+				},
+				"extractedFrom": &graphql.Field{
+					Type:        graphql.NewList(graphql.NewNonNull(fileType)),
+					Description: "The archives this file was extracted from, outermost first; empty unless it came out of a nested archive",
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						l := loaderFrom(p.Context)
+						return l.files(p.Context, l.extractedFrom(p.Source.(*minio.ObjectInfo).Key))
+					},
+				},
+			}
+		}),
+	})
+
+	jobType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Job",
+		Description: "A processing job",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"type":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"status":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"priority":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"bucket":      &graphql.Field{Type: graphql.String},
+				"objectName":  &graphql.Field{Type: graphql.String},
+				"progress":    &graphql.Field{Type: graphql.Float},
+				"error":       &graphql.Field{Type: graphql.String},
+				"subject":     &graphql.Field{Type: graphql.String},
+				"chainId":     &graphql.Field{Type: graphql.String},
+				"createdAt":   &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+				"startedAt":   &graphql.Field{Type: graphql.DateTime},
+				"completedAt": &graphql.Field{Type: graphql.DateTime},
+				"durationMs": &graphql.Field{
+					Type: graphql.Float,
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return p.Source.(*jobs.Job).GetDuration().Milliseconds(), nil
+					},
+				},
+				"sourceFile": &graphql.Field{
+					Type:        fileType,
+					Description: "The file the job ran on; null if it no longer exists",
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						job := p.Source.(*jobs.Job)
+						if job.ObjectName == "" {
+							return nil, nil
+						}
+						return loaderFrom(p.Context).file(p.Context, job.ObjectName)
+					},
+				},
+				"dependencies": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(jobType))),
+					Description: "The jobs this job waited for",
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						l := loaderFrom(p.Context)
+						dependencies := []*jobs.Job{}
+						for _, id := range p.Source.(*jobs.Job).DependsOn {
+							if job, ok := l.job(id); ok {
+								dependencies = append(dependencies, job)
+							}
+						}
+						return dependencies, nil
+					},
+				},
+				"artifacts": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(artifactType))),
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return artifacts(p.Source.(*jobs.Job)), nil
+					},
+				},
+			}
+		}),
+	})
+
+	exportType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Export",
+		Description: "An export of files to a Nessie table",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":           &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"tableName":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"database":     &graphql.Field{Type: graphql.String},
+				"operation":    &graphql.Field{Type: graphql.String},
+				"success":      &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+				"message":      &graphql.Field{Type: graphql.String},
+				"rowsExported": &graphql.Field{Type: graphql.Float},
+				"rowsFailed":   &graphql.Field{Type: graphql.Float},
+				"subject":      &graphql.Field{Type: graphql.String},
+				"startedAt":    &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+				"durationMs": &graphql.Field{
+					Type: graphql.Float,
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return p.Source.(data_browser.ExportRecord).Duration.Milliseconds(), nil
+					},
+				},
+				"fileKeys": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+					Description: "Object keys of the exported files, including ones since deleted",
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return p.Source.(data_browser.ExportRecord).Files, nil
+					},
+				},
+				"files": &graphql.Field{
+					Type:        graphql.NewList(graphql.NewNonNull(fileType)),
+					Description: "The exported files that still exist",
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return loaderFrom(p.Context).files(p.Context, p.Source.(data_browser.ExportRecord).Files)
+					},
+				},
+				"table": &graphql.Field{
+					Type: graphql.NewNonNull(tableType),
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						record := p.Source.(data_browser.ExportRecord)
+						return table{Name: record.TableName, Database: record.Database}, nil
+					},
+				},
+			}
+		}),
+	})
+
+	tableType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Table",
+		Description: "A Nessie table and the exports that wrote to it",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"name":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"database": &graphql.Field{Type: graphql.String},
+				"exports": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(exportType))),
+					Description: "Exports to this table, newest first",
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return loaderFrom(p.Context).exports(p.Source.(table).matches), nil
+					},
+				},
+				"sourceFiles": &graphql.Field{
+					Type:        graphql.NewList(graphql.NewNonNull(fileType)),
+					Description: "The files successfully exported to this table that still exist",
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						l := loaderFrom(p.Context)
+						seen := make(map[string]bool)
+						var keys []string
+						for _, record := range l.exports(p.Source.(table).matches) {
+							if !record.Success {
+								continue
+							}
+							for _, key := range record.Files {
+								if !seen[key] {
+									seen[key] = true
+									keys = append(keys, key)
+								}
+							}
+						}
+						return l.files(p.Context, keys)
+					},
+				},
+			}
+		}),
+	})
+
+	eventType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "FileEvent",
+		Description: "A change the file watcher saw",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"bucket":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"key":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"size":        &graphql.Field{Type: graphql.Float},
+				"etag":        &graphql.Field{Type: graphql.String},
+				"eventType":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"eventTime":   &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+				"rule":        &graphql.Field{Type: graphql.String},
+				"processed":   &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+				"processedAt": &graphql.Field{Type: graphql.DateTime},
+				"file": &graphql.Field{
+					Type:        fileType,
+					Description: "The file as it is now; null if it no longer exists",
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return loaderFrom(p.Context).file(p.Context, p.Source.(*monitoring.FileEvent).Key)
+					},
+				},
+			}
+		}),
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"file": &graphql.Field{
+				Type:        fileType,
+				Description: "A file by key; null if it does not exist",
+				Args:        graphql.FieldConfigArgument{"key": {Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loaderFrom(p.Context).file(p.Context, stringArg(p, "key"))
+				},
+			},
+			"files": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(fileType)),
+				Description: "Files below a prefix, recursively",
+				Args: graphql.FieldConfigArgument{
+					"prefix": {Type: graphql.String},
+					"limit":  limitArg(defaultLimit),
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loaderFrom(p.Context).list(p.Context, stringArg(p, "prefix"), intArg(p, "limit"))
+				},
+			},
+			"job": &graphql.Field{
+				Type: jobType,
+				Args: graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					if job, ok := loaderFrom(p.Context).job(stringArg(p, "id")); ok {
+						return job, nil
+					}
+					return nil, nil
+				},
+			},
+			"jobs": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(jobType))),
+				Description: "Jobs, newest first, filtered like GET /api/jobs",
+				Args: graphql.FieldConfigArgument{
+					"type":   {Type: graphql.String},
+					"status": {Type: graphql.String},
+					"prefix": {Type: graphql.String, Description: "Object name prefix"},
+					"limit":  limitArg(defaultLimit),
+					"offset": {Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					filter := jobs.JobFilter{
+						Type:   stringArg(p, "type"),
+						Status: jobs.JobStatus(stringArg(p, "status")),
+						Prefix: stringArg(p, "prefix"),
+						Limit:  intArg(p, "limit"),
+						Offset: intArg(p, "offset"),
+					}
+					if filter.Limit <= 0 {
+						return []*jobs.Job{}, nil
+					}
+					page, _ := filter.Apply(loaderFrom(p.Context).allJobs())
+					return page, nil
+				},
+			},
+			"exports": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(exportType))),
+				Description: "Recent exports, newest first. Only the exports since the server started are known",
+				Args: graphql.FieldConfigArgument{
+					"table":    {Type: graphql.String},
+					"database": {Type: graphql.String},
+					"file":     {Type: graphql.String, Description: "Only exports that read this object key"},
+					"limit":    limitArg(defaultLimit),
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					tableName, database, file := stringArg(p, "table"), stringArg(p, "database"), stringArg(p, "file")
+					records := loaderFrom(p.Context).exports(func(e data_browser.ExportRecord) bool {
+						return (tableName == "" || e.TableName == tableName) &&
+							(database == "" || e.Database == database) &&
+							(file == "" || exportedFile(e, file))
+					})
+					return truncate(records, intArg(p, "limit")), nil
+				},
+			},
+			"table": &graphql.Field{
+				Type:        graphql.NewNonNull(tableType),
+				Description: "A table's export lineage",
+				Args: graphql.FieldConfigArgument{
+					"name":     {Type: graphql.NewNonNull(graphql.String)},
+					"database": {Type: graphql.String, Description: "Any database if empty"},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return table{Name: stringArg(p, "name"), Database: stringArg(p, "database")}, nil
+				},
+			},
+			"watcherEvents": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(eventType)),
+				Description: "Stored watcher events, newest first",
+				Args: graphql.FieldConfigArgument{
+					"prefix":    {Type: graphql.String, Description: "Object key prefix"},
+					"rule":      {Type: graphql.String},
+					"eventType": {Type: graphql.String, Description: "created, modified or removed"},
+					"limit":     limitArg(defaultLimit),
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					prefix, rule, kind := stringArg(p, "prefix"), stringArg(p, "rule"), stringArg(p, "eventType")
+					events, err := loaderFrom(p.Context).events(func(e *monitoring.FileEvent) bool {
+						return strings.HasPrefix(e.Key, prefix) &&
+							(rule == "" || e.Rule == rule) &&
+							(kind == "" || string(e.EventType) == kind)
+					})
+					return truncate(events, intArg(p, "limit")), err
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+// artifact is a named file a job produced.
+type artifact struct {
+	Name      string
+	ObjectKey string
+}
+
+func artifacts(job *jobs.Job) []artifact {
+	list := make([]artifact, 0, len(job.Artifacts))
+	for name, key := range job.Artifacts {
+		list = append(list, artifact{Name: name, ObjectKey: key})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// table names a table; an empty Database matches the table in any database.
+type table struct {
+	Name     string
+	Database string
+}
+
+func (t table) matches(e data_browser.ExportRecord) bool {
+	return e.TableName == t.Name && (t.Database == "" || e.Database == t.Database)
+}
+
+func exportedFile(e data_browser.ExportRecord, key string) bool {
+	for _, file := range e.Files {
+		if file == key {
+			return true
+		}
+	}
+	return false
+}
+
+// errUnavailable is returned by fields whose data source is not running.
+func errUnavailable(source string) error {
+	return fmt.Errorf("%s is not available", source)
+}
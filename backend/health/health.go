@@ -0,0 +1,123 @@
+// Package health serves the liveness and readiness probes. Liveness only
+// says the process is serving requests; readiness checks each dependency and
+// reports its status, so an orchestrator stops routing traffic to an
+// instance that cannot do its work.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"bronze-backend/httputil"
+)
+
+// checkTimeout bounds each dependency check, so one hung dependency cannot
+// stall the probe.
+const checkTimeout = 5 * time.Second
+
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded" // An optional dependency is down
+	StatusDown     = "down"
+)
+
+// Result is the outcome of one dependency check.
+type Result struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	Critical   bool   `json:"critical"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Report is the readiness probe's response body.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type check struct {
+	name     string
+	critical bool
+	run      func(context.Context) error
+}
+
+// Checker runs the readiness checks.
+type Checker struct {
+	checks []check
+}
+
+// NewChecker returns a Checker with no checks.
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Add registers a dependency check. The instance is not ready while a
+// critical check fails; a failing optional one only degrades it.
+func (c *Checker) Add(name string, critical bool, run func(context.Context) error) {
+	c.checks = append(c.checks, check{name: name, critical: critical, run: run})
+}
+
+// Check runs every check concurrently.
+func (c *Checker) Check(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, chk := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := chk.result(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[chk.name] = result
+			switch {
+			case result.Status == StatusOK:
+			case chk.critical:
+				report.Status = StatusDown
+			case report.Status == StatusOK:
+				report.Status = StatusDegraded
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func (chk check) result(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := chk.run(ctx)
+	result := Result{
+		Status:     StatusOK,
+		Critical:   chk.critical,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Live answers the liveness probe. It checks nothing: a process that can
+// answer is alive, and restarting it would not fix a dependency.
+func (c *Checker) Live(w http.ResponseWriter, r *http.Request) {
+	httputil.WriteJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+}
+
+// Ready answers the readiness probe with every check's result, and 503 when
+// a critical check fails.
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+	status := http.StatusOK
+	if report.Status == StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+	httputil.WriteJSON(w, status, report)
+}
@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func ready(t *testing.T, c *Checker) (int, Report) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	c.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, report
+}
+
+func TestReady(t *testing.T) {
+	healthy := func(context.Context) error { return nil }
+	broken := func(context.Context) error { return errors.New("connection refused") }
+
+	c := NewChecker()
+	c.Add("minio", true, healthy)
+	c.Add("nessie", false, broken)
+
+	code, report := ready(t, c)
+	if code != http.StatusOK || report.Status != StatusDegraded {
+		t.Errorf("optional check down: %d %s, want 200 degraded", code, report.Status)
+	}
+	if nessie := report.Checks["nessie"]; nessie.Status != StatusDown || nessie.Error != "connection refused" || nessie.Critical {
+		t.Errorf("nessie = %+v", nessie)
+	}
+
+	c.Add("queue", true, broken)
+	code, report = ready(t, c)
+	if code != http.StatusServiceUnavailable || report.Status != StatusDown {
+		t.Errorf("critical check down: %d %s, want 503 down", code, report.Status)
+	}
+	if report.Checks["minio"].Status != StatusOK {
+		t.Errorf("minio = %+v", report.Checks["minio"])
+	}
+}
+
+func TestCheckTimesOut(t *testing.T) {
+	c := NewChecker()
+	c.Add("hung", true, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if report := c.Check(ctx); report.Checks["hung"].Status != StatusDown {
+		t.Errorf("hung = %+v", report.Checks["hung"])
+	}
+}
@@ -0,0 +1,167 @@
+package httputil
+
+import "net/http"
+
+// Code is the machine-readable kind of an API error. Codes are stable:
+// clients may switch on them, so existing ones are never renamed.
+type Code string
+
+// Codes for any endpoint, one per status. An error without a more specific
+// code gets the one for its status.
+const (
+	CodeBadRequest       Code = "BAD_REQUEST"
+	CodeUnauthorized     Code = "UNAUTHORIZED"
+	CodeForbidden        Code = "FORBIDDEN"
+	CodeNotFound         Code = "NOT_FOUND"
+	CodeMethodNotAllowed Code = "METHOD_NOT_ALLOWED"
+	CodeConflict         Code = "CONFLICT"
+	CodeRequestTooLarge  Code = "REQUEST_TOO_LARGE"
+	CodeUnprocessable    Code = "UNPROCESSABLE"
+	CodeRateLimited      Code = "RATE_LIMITED"
+	CodeInternal         Code = "INTERNAL"
+	CodeUpstream         Code = "UPSTREAM_ERROR"
+	CodeUnavailable      Code = "UNAVAILABLE"
+)
+
+// Codes for specific failures.
+const (
+	CodeInvalidObjectName  Code = "INVALID_OBJECT_NAME"
+	CodeStorageUnavailable Code = "STORAGE_UNAVAILABLE" // MinIO is not connected
+	CodeBucketUnavailable  Code = "BUCKET_UNAVAILABLE"
+	CodeFileNotFound       Code = "FILE_NOT_FOUND"
+	CodeUnsupportedArchive Code = "UNSUPPORTED_ARCHIVE"
+	CodeInvalidArchive     Code = "INVALID_ARCHIVE"
+	CodeUnsupportedFile    Code = "UNSUPPORTED_FILE_TYPE"
+	CodeInvalidDatabase    Code = "INVALID_DATABASE"            // Not an Access database
+	CodeUnsupportedDB      Code = "DATABASE_FORMAT_UNSUPPORTED" // No driver here reads the database's format
+	CodeJobNotFound        Code = "JOB_NOT_FOUND"
+	CodeJobNotPending      Code = "JOB_NOT_PENDING"
+	CodeWorkersUnavailable Code = "WORKERS_UNAVAILABLE" // No worker pool on this instance
+	CodeNessieUnavailable  Code = "NESSIE_UNAVAILABLE"
+	CodeNessieError        Code = "NESSIE_ERROR"
+	CodeSchemaMismatch     Code = "SCHEMA_MISMATCH"
+	CodeSchemaDrift        Code = "SCHEMA_DRIFT"
+	CodeExportFailed       Code = "EXPORT_FAILED"
+	CodeSuiteNotFound      Code = "VALIDATION_SUITE_NOT_FOUND"
+	CodeTransformNotFound  Code = "TRANSFORM_SET_NOT_FOUND"
+	CodeQualityNotFound    Code = "QUALITY_SUITE_NOT_FOUND"
+	CodeQualityFailed      Code = "QUALITY_CHECK_FAILED" // A blocking quality suite fails
+	CodeTenantForbidden    Code = "TENANT_FORBIDDEN"
+	CodeQuotaExceeded      Code = "QUOTA_EXCEEDED"
+	CodeIdempotencyReused  Code = "IDEMPOTENCY_KEY_REUSED"  // The key was sent with another request
+	CodeIdempotencyPending Code = "IDEMPOTENCY_IN_PROGRESS" // The first request with the key is still running
+)
+
+var codeStatus = map[Code]int{
+	CodeBadRequest:       http.StatusBadRequest,
+	CodeUnauthorized:     http.StatusUnauthorized,
+	CodeForbidden:        http.StatusForbidden,
+	CodeNotFound:         http.StatusNotFound,
+	CodeMethodNotAllowed: http.StatusMethodNotAllowed,
+	CodeConflict:         http.StatusConflict,
+	CodeRequestTooLarge:  http.StatusRequestEntityTooLarge,
+	CodeUnprocessable:    http.StatusUnprocessableEntity,
+	CodeRateLimited:      http.StatusTooManyRequests,
+	CodeInternal:         http.StatusInternalServerError,
+	CodeUpstream:         http.StatusBadGateway,
+	CodeUnavailable:      http.StatusServiceUnavailable,
+
+	CodeInvalidObjectName:  http.StatusBadRequest,
+	CodeStorageUnavailable: http.StatusServiceUnavailable,
+	CodeBucketUnavailable:  http.StatusServiceUnavailable,
+	CodeFileNotFound:       http.StatusNotFound,
+	CodeUnsupportedArchive: http.StatusBadRequest,
+	CodeInvalidArchive:     http.StatusUnprocessableEntity,
+	CodeUnsupportedFile:    http.StatusBadRequest,
+	CodeInvalidDatabase:    http.StatusUnprocessableEntity,
+	CodeUnsupportedDB:      http.StatusNotImplemented,
+	CodeJobNotFound:        http.StatusNotFound,
+	CodeJobNotPending:      http.StatusConflict,
+	CodeWorkersUnavailable: http.StatusServiceUnavailable,
+	CodeNessieUnavailable:  http.StatusServiceUnavailable,
+	CodeNessieError:        http.StatusBadGateway,
+	CodeSchemaMismatch:     http.StatusConflict,
+	CodeSchemaDrift:        http.StatusConflict,
+	CodeExportFailed:       http.StatusUnprocessableEntity,
+	CodeSuiteNotFound:      http.StatusNotFound,
+	CodeTransformNotFound:  http.StatusNotFound,
+	CodeQualityNotFound:    http.StatusNotFound,
+	CodeQualityFailed:      http.StatusConflict,
+	CodeTenantForbidden:    http.StatusForbidden,
+	CodeQuotaExceeded:      http.StatusForbidden,
+	CodeIdempotencyReused:  http.StatusUnprocessableEntity,
+	CodeIdempotencyPending: http.StatusConflict,
+}
+
+// Status returns the HTTP status errors with code are answered with.
+func (c Code) Status() int {
+	if status, ok := codeStatus[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// CodeFor returns the generic code for an error status.
+func CodeFor(status int) Code {
+	switch {
+	case status == http.StatusBadRequest:
+		return CodeBadRequest
+	case status == http.StatusUnauthorized:
+		return CodeUnauthorized
+	case status == http.StatusForbidden:
+		return CodeForbidden
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case status == http.StatusConflict:
+		return CodeConflict
+	case status == http.StatusRequestEntityTooLarge:
+		return CodeRequestTooLarge
+	case status == http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case status == http.StatusTooManyRequests:
+		return CodeRateLimited
+	case status == http.StatusBadGateway || status == http.StatusGatewayTimeout:
+		return CodeUpstream
+	case status == http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case status >= http.StatusInternalServerError:
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// APIError is an error carrying the code it is reported with, so layers
+// below the handlers can decide how their failures reach the client.
+type APIError struct {
+	Code    Code
+	Message string
+	Err     error // The underlying cause, if any
+}
+
+// NewError returns an *APIError.
+func NewError(code Code, message string, err error) *APIError {
+	return &APIError{Code: code, Message: message, Err: err}
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns the status of e's code.
+func (e *APIError) StatusCode() int {
+	return e.Code.Status()
+}
+
+// ErrorCode returns e's code.
+func (e *APIError) ErrorCode() Code {
+	return e.Code
+}
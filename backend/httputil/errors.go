@@ -0,0 +1,97 @@
+// Package httputil holds the response helpers and middleware shared by all
+// API handlers, so every endpoint logs and reports errors the same way.
+package httputil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// ErrorResponse is the body of every API error. Message says what failed;
+// Error carries the underlying cause, or repeats Message when there is none.
+// Code says what kind of failure it was, for clients to act on.
+type ErrorResponse struct {
+	Success bool   `json:"success"`
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	Error   string `json:"error"`
+}
+
+// WriteJSON writes data as a JSON response.
+func WriteJSON(w http.ResponseWriter, statusCode int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+// WriteError writes a JSON error response with the code for statusCode.
+// Server errors are logged with their cause. A body cut off by
+// http.MaxBytesReader is reported as 413 whatever statusCode the handler
+// chose, and an error with a StatusCode or ErrorCode method, such as an
+// *APIError or a tenant access error, with its own status and code.
+func WriteError(w http.ResponseWriter, message string, statusCode int, err error) {
+	var coded interface{ ErrorCode() Code }
+	var withStatus interface{ StatusCode() int }
+	code := CodeFor(statusCode)
+	if errors.As(err, &withStatus) {
+		statusCode = withStatus.StatusCode()
+		code = CodeFor(statusCode)
+	}
+	if errors.As(err, &coded) {
+		code = coded.ErrorCode()
+	}
+	write(w, message, statusCode, code, err)
+}
+
+// WriteCode writes a JSON error response with code and its status. A body
+// cut off by http.MaxBytesReader is still reported as 413.
+func WriteCode(w http.ResponseWriter, code Code, message string, err error) {
+	write(w, message, code.Status(), code, err)
+}
+
+func write(w http.ResponseWriter, message string, statusCode int, code Code, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		message = TooLargeMessage(tooLarge.Limit)
+		statusCode = http.StatusRequestEntityTooLarge
+		code = CodeRequestTooLarge
+	}
+
+	response := ErrorResponse{
+		Success: false,
+		Code:    code,
+		Message: message,
+		Error:   message,
+	}
+	if err != nil {
+		response.Error = err.Error()
+		if statusCode >= http.StatusInternalServerError {
+			log.Printf("Error: %s: %v", message, err)
+		}
+	}
+
+	WriteJSON(w, statusCode, response)
+}
+
+// TooLargeMessage is the message of a 413 for a body over limit bytes.
+func TooLargeMessage(limit int64) string {
+	return fmt.Sprintf("Request body too large: the limit is %d bytes", limit)
+}
+
+// Error is a drop-in replacement for http.Error that answers in JSON.
+func Error(w http.ResponseWriter, message string, statusCode int) {
+	WriteError(w, message, statusCode, nil)
+}
+
+// NotFound answers requests that match no route.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Error(w, "Not found: "+r.URL.Path, http.StatusNotFound)
+}
+
+// MethodNotAllowed answers requests whose path exists under another method.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Error(w, "Method not allowed: "+r.Method, http.StatusMethodNotAllowed)
+}
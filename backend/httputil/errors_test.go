@@ -0,0 +1,62 @@
+package httputil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type forbiddenError struct{}
+
+func (forbiddenError) Error() string   { return "forbidden" }
+func (forbiddenError) StatusCode() int { return http.StatusForbidden }
+
+func TestWriteErrorCodes(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		write  func(w http.ResponseWriter)
+		status int
+		code   Code
+	}{
+		{"status", func(w http.ResponseWriter) { Error(w, "Job ID is required", http.StatusBadRequest) }, http.StatusBadRequest, CodeBadRequest},
+		{"server error", func(w http.ResponseWriter) {
+			WriteError(w, "Failed", http.StatusInternalServerError, errors.New("boom"))
+		}, http.StatusInternalServerError, CodeInternal},
+		{"code", func(w http.ResponseWriter) { WriteCode(w, CodeFileNotFound, "File not found", nil) }, http.StatusNotFound, CodeFileNotFound},
+		{"APIError", func(w http.ResponseWriter) {
+			err := fmt.Errorf("browse: %w", NewError(CodeUnsupportedFile, "unsupported file type: .txt", nil))
+			WriteError(w, err.Error(), http.StatusInternalServerError, err)
+		}, http.StatusBadRequest, CodeUnsupportedFile},
+		{"StatusCode", func(w http.ResponseWriter) { WriteError(w, "Denied", http.StatusInternalServerError, forbiddenError{}) }, http.StatusForbidden, CodeForbidden},
+		{"too large", func(w http.ResponseWriter) {
+			WriteCode(w, CodeInvalidArchive, "Failed to read archive", &http.MaxBytesError{Limit: 10})
+		}, http.StatusRequestEntityTooLarge, CodeRequestTooLarge},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.write(rec)
+
+			var body ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.status || body.Code != tt.code || body.Success {
+				t.Errorf("got %d %s, want %d %s", rec.Code, body.Code, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestCodeStatus(t *testing.T) {
+	for code, status := range codeStatus {
+		if status < http.StatusBadRequest {
+			t.Errorf("%s answers %d, want an error status", code, status)
+		}
+	}
+	if Code("SOMETHING_NEW").Status() != http.StatusInternalServerError {
+		t.Error("an unknown code does not answer 500")
+	}
+}
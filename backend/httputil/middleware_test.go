@@ -0,0 +1,101 @@
+package httputil
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogRecordsStatusAndSize(t *testing.T) {
+	var buf bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(previous)
+
+	handler := AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Error(w, "Bucket is required", http.StatusBadRequest)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/jobs?x=1", nil))
+
+	line := buf.String()
+	if !strings.Contains(line, "POST /api/jobs?x=1 400") || !strings.Contains(line, "B\n") {
+		t.Errorf("unexpected access log line: %q", line)
+	}
+
+	var body ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Success || body.Message != "Bucket is required" || body.Error != "Bucket is required" {
+		t.Errorf("unexpected error body: %+v", body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
+
+func TestRecoverAnswersWithJSON(t *testing.T) {
+	previous := log.Writer()
+	log.SetOutput(&bytes.Buffer{})
+	defer log.SetOutput(previous)
+
+	handler := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/files", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"message":"Internal server error"`) {
+		t.Errorf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestAccessLogHijack(t *testing.T) {
+	var buf bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(previous)
+
+	handler := AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			t.Error("recorder does not implement http.Hijacker")
+			return
+		}
+		conn, rw, err := hijacker.Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 204 No Content\r\n\r\n")
+		rw.Flush()
+	}))
+	logged := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(logged)
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	<-logged
+
+	if line := buf.String(); !strings.Contains(line, "GET /api/ws 101") {
+		t.Errorf("unexpected access log line: %q", line)
+	}
+}
@@ -0,0 +1,319 @@
+// Package idempotency replays the response of a mutating request retried
+// with the same Idempotency-Key header, so a client that lost the response
+// to an upload, job creation or export can retry it without the work being
+// done twice.
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"log"
+	"maps"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bronze-backend/auth"
+	"bronze-backend/config"
+	"bronze-backend/httputil"
+	"bronze-backend/storage"
+	"bronze-backend/tenant"
+)
+
+// Header carries the client's key for a request.
+const Header = "Idempotency-Key"
+
+// ReplayedHeader marks a response replayed from an earlier request.
+const ReplayedHeader = "Idempotent-Replayed"
+
+// maxKeyLength is the longest key accepted.
+const maxKeyLength = 255
+
+// maxResponseBytes is the largest response body stored. The requests this
+// wraps answer with small JSON bodies; a larger response is not replayed.
+const maxResponseBytes = 64 << 10
+
+// sweepInterval is how often expired responses are dropped.
+const sweepInterval = time.Minute
+
+// entry is the state of a key. Entries are replaced rather than changed, so
+// they can be read without the lock.
+type entry struct {
+	fingerprint [sha256.Size]byte
+	done        bool // False while the first request runs
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// Store holds the responses of requests sent with a key, for replaying.
+// Responses are kept in memory, so a retry is only recognised by the
+// instance that answered the first request. A nil Store replays nothing.
+type Store struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+// New returns a Store for cfg, or nil when idempotency keys are disabled.
+func New(cfg config.IdempotencyConfig) *Store {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Store{
+		now:     time.Now,
+		ttl:     cfg.TTL,
+		entries: make(map[string]*entry),
+	}
+}
+
+// SetConfig changes how long responses are replayed, from the next one
+// stored. Enabling or disabling the store needs a restart.
+func (s *Store) SetConfig(cfg config.IdempotencyConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ttl = cfg.TTL
+}
+
+// Wrap makes next idempotent for requests with a key. The first request
+// with a key runs and its response is stored; a retry with the same key
+// and the same request gets that response again, marked with
+// ReplayedHeader. Server errors and 429s are not stored, so those requests
+// can be retried for real. Keys are scoped to the caller and tenant.
+func (s *Store) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	if s == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			httputil.Error(w, Header+" is longer than "+strconv.Itoa(maxKeyLength)+" characters", http.StatusBadRequest)
+			return
+		}
+		scope := auth.Subject(r.Context()) + "\x00" + tenant.Name(r.Context()) + "\x00" + key
+
+		existing, claimed := s.claim(scope)
+		if !claimed {
+			s.replay(w, r, existing)
+			return
+		}
+
+		stored := false
+		defer func() {
+			// Also frees the key when next panics
+			if !stored {
+				s.release(scope)
+			}
+		}()
+
+		fp := newFingerprint(r)
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(r.Body, fp), r.Body}
+		recorder := &responseRecorder{ResponseWriter: w}
+
+		next(recorder, r)
+
+		// The fingerprint covers the whole body, read or not
+		if _, err := io.Copy(io.Discard, r.Body); err != nil || !recorder.storable() {
+			return
+		}
+		s.store(scope, fp.sum(), recorder)
+		stored = true
+	}
+}
+
+// claim returns the entry for scope, or reserves scope for a first request
+// and returns true.
+func (s *Store) claim(scope string) (*entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		for k, e := range s.entries {
+			if e.done && now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	if e, ok := s.entries[scope]; ok && (!e.done || now.Before(e.expires)) {
+		return e, false
+	}
+	s.entries[scope] = &entry{}
+	return nil, true
+}
+
+func (s *Store) release(scope string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, scope)
+}
+
+func (s *Store) store(scope string, sum [sha256.Size]byte, recorder *responseRecorder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[scope] = &entry{
+		fingerprint: sum,
+		done:        true,
+		status:      recorder.Status(),
+		header:      recorder.header,
+		body:        recorder.body.Bytes(),
+		expires:     s.now().Add(s.ttl),
+	}
+}
+
+// replay answers a retry with the stored response, after checking that it
+// repeats the first request.
+func (s *Store) replay(w http.ResponseWriter, r *http.Request, e *entry) {
+	if !e.done {
+		httputil.WriteCode(w, httputil.CodeIdempotencyPending, "A request with this "+Header+" is still running", nil)
+		return
+	}
+
+	fp := newFingerprint(r)
+	if _, err := io.Copy(fp, r.Body); err != nil {
+		httputil.WriteError(w, "Failed to read request body", http.StatusBadRequest, err)
+		return
+	}
+	if fp.sum() != e.fingerprint {
+		httputil.WriteCode(w, httputil.CodeIdempotencyReused, Header+" was already used for a different request", nil)
+		return
+	}
+
+	log.Printf("Replaying %s %s for %s", r.Method, r.URL.Path, Header)
+	maps.Copy(w.Header(), e.header)
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(e.status)
+	w.Write(e.body)
+}
+
+// fingerprint hashes a request: its method, path, query and bucket, and
+// its body. The boundary of a multipart body is left out, as a client
+// retrying an upload may pick a new one.
+type fingerprint struct {
+	hash     hash.Hash
+	stripper *boundaryStripper // nil unless the body is multipart
+}
+
+func newFingerprint(r *http.Request) *fingerprint {
+	f := &fingerprint{hash: sha256.New()}
+	for _, part := range []string{r.Method, r.URL.Path, r.URL.RawQuery, storage.SessionBucket(r.Context())} {
+		f.hash.Write([]byte(part))
+		f.hash.Write([]byte{0})
+	}
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		f.stripper = &boundaryStripper{w: f.hash, boundary: []byte(params["boundary"])}
+	}
+	return f
+}
+
+func (f *fingerprint) Write(p []byte) (int, error) {
+	if f.stripper != nil {
+		return f.stripper.Write(p)
+	}
+	return f.hash.Write(p)
+}
+
+func (f *fingerprint) sum() (sum [sha256.Size]byte) {
+	if f.stripper != nil {
+		f.stripper.flush()
+	}
+	copy(sum[:], f.hash.Sum(nil))
+	return sum
+}
+
+// boundaryStripper writes what is written to it to w, leaving out every
+// occurrence of boundary.
+type boundaryStripper struct {
+	w        io.Writer
+	boundary []byte
+	pending  []byte // Tail that may be the start of a boundary
+}
+
+func (b *boundaryStripper) Write(p []byte) (int, error) {
+	b.pending = append(b.pending, p...)
+	for {
+		i := bytes.Index(b.pending, b.boundary)
+		if i < 0 {
+			break
+		}
+		b.w.Write(b.pending[:i])
+		b.pending = b.pending[i+len(b.boundary):]
+	}
+	if keep := len(b.boundary) - 1; len(b.pending) > keep {
+		b.w.Write(b.pending[:len(b.pending)-keep])
+		b.pending = append(b.pending[:0], b.pending[len(b.pending)-keep:]...)
+	}
+	return len(p), nil
+}
+
+func (b *boundaryStripper) flush() {
+	b.w.Write(b.pending)
+	b.pending = nil
+}
+
+// responseRecorder keeps a copy of the response for storing.
+type responseRecorder struct {
+	http.ResponseWriter
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	tooLarge bool
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if r.status == 0 {
+		r.status = statusCode
+		r.header = r.ResponseWriter.Header().Clone()
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.body.Len()+len(data) > maxResponseBytes {
+		r.tooLarge = true
+	} else {
+		r.body.Write(data)
+	}
+	return r.ResponseWriter.Write(data)
+}
+
+// Status returns the response status, 200 if the handler set none.
+func (r *responseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// storable reports whether the response may be replayed.
+func (r *responseRecorder) storable() bool {
+	status := r.Status()
+	return !r.tooLarge && status < http.StatusInternalServerError && status != http.StatusTooManyRequests
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
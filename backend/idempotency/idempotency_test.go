@@ -0,0 +1,189 @@
+package idempotency
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bronze-backend/auth"
+	"bronze-backend/config"
+	"bronze-backend/httputil"
+)
+
+func newTestStore() (*Store, *time.Time) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := New(config.IdempotencyConfig{Enabled: true, TTL: time.Hour})
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+// createJob counts its calls and answers 201 with the body it read.
+func createJob(calls *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		httputil.WriteJSON(w, http.StatusCreated, map[string]string{"job": string(body)})
+	}
+}
+
+func serve(h http.HandlerFunc, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec
+}
+
+func TestReplay(t *testing.T) {
+	s, now := newTestStore()
+	var calls atomic.Int32
+	h := s.Wrap(createJob(&calls))
+
+	first := serve(h, "k1", `{"type":"extract"}`)
+	retry := serve(h, "k1", `{"type":"extract"}`)
+	if calls.Load() != 1 {
+		t.Fatalf("handler ran %d times, want once", calls.Load())
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() || retry.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("retry = %d %q, want the first response replayed", retry.Code, retry.Body.String())
+	}
+	if first.Header().Get(ReplayedHeader) != "" {
+		t.Error("the first response is marked as replayed")
+	}
+
+	if rec := serve(h, "k1", `{"type":"convert"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("same key, other body: status %d, want 422", rec.Code)
+	}
+	serve(h, "", `{"type":"extract"}`)
+	serve(h, "k2", `{"type":"extract"}`)
+	if calls.Load() != 3 {
+		t.Errorf("requests without a key or with a new one ran %d times in all, want 3", calls.Load())
+	}
+
+	*now = now.Add(2 * time.Hour)
+	serve(h, "k1", `{"type":"extract"}`)
+	if calls.Load() != 4 {
+		t.Error("an expired response was replayed")
+	}
+}
+
+func TestKeysAreScopedToTheCaller(t *testing.T) {
+	s, _ := newTestStore()
+	var calls atomic.Int32
+	h := s.Wrap(createJob(&calls))
+
+	for _, subject := range []string{"alice", "bob"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader("{}"))
+		req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: subject, Role: auth.RoleEditor}))
+		req.Header.Set(Header, "k1")
+		h(httptest.NewRecorder(), req)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("handler ran %d times, want once per caller", calls.Load())
+	}
+}
+
+func TestFailuresAreNotStored(t *testing.T) {
+	s, _ := newTestStore()
+	var calls atomic.Int32
+	h := s.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			httputil.Error(w, "Queue unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	serve(h, "k1", "{}")
+	if rec := serve(h, "k1", "{}"); rec.Code != http.StatusCreated || calls.Load() != 2 {
+		t.Errorf("retry after a 503 = %d after %d calls, want it run again", rec.Code, calls.Load())
+	}
+}
+
+func TestInProgress(t *testing.T) {
+	s, _ := newTestStore()
+	started, release := make(chan struct{}), make(chan struct{})
+	h := s.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	done := make(chan struct{})
+	go func() {
+		serve(h, "k1", "{}")
+		close(done)
+	}()
+	<-started
+	if rec := serve(h, "k1", "{}"); rec.Code != http.StatusConflict {
+		t.Errorf("retry while running: status %d, want 409", rec.Code)
+	}
+	close(release)
+	<-done
+}
+
+func TestMultipartBoundaryIsIgnored(t *testing.T) {
+	s, _ := newTestStore()
+	var calls atomic.Int32
+	h := s.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	upload := func(content string) int {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body) // Picks a new boundary each time
+		form.WriteField("object_name", "a.csv")
+		part, _ := form.CreateFormFile("file", "a.csv")
+		part.Write([]byte(content))
+		form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/files/upload", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set(Header, "upload-1")
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec.Code
+	}
+
+	upload("a,b\n1,2\n")
+	if status := upload("a,b\n1,2\n"); status != http.StatusCreated || calls.Load() != 1 {
+		t.Errorf("retried upload = %d after %d calls, want a replay", status, calls.Load())
+	}
+	if status := upload("a,b\n3,4\n"); status != http.StatusUnprocessableEntity {
+		t.Errorf("other file under the same key: status %d, want 422", status)
+	}
+}
+
+func TestBoundaryStripper(t *testing.T) {
+	var out bytes.Buffer
+	b := &boundaryStripper{w: &out, boundary: []byte("XYZ")}
+	for _, chunk := range []string{"aX", "YZb", "cXY", "Zd", "X"} {
+		b.Write([]byte(chunk))
+	}
+	b.flush()
+	if out.String() != "abcdX" {
+		t.Errorf("stripped = %q, want %q", out.String(), "abcdX")
+	}
+}
+
+func TestNilStore(t *testing.T) {
+	var s *Store
+	var calls atomic.Int32
+	h := s.Wrap(createJob(&calls))
+	serve(h, "k1", "{}")
+	serve(h, "k1", "{}")
+	if calls.Load() != 2 {
+		t.Errorf("a nil Store replayed a response")
+	}
+}
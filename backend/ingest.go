@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+
+	"bronze-backend/jobs"
+	"bronze-backend/storage"
+)
+
+// ingestFile is a local file and the object it is uploaded to.
+type ingestFile struct {
+	path   string
+	object string
+}
+
+// ingestFiles lists the regular files under paths. A file is uploaded by its
+// base name and a directory's files by their path inside it, both below
+// prefix.
+func ingestFiles(paths []string, prefix string) ([]ingestFile, error) {
+	var files []ingestFile
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, ingestFile{path: root, object: path.Join(prefix, filepath.Base(root))})
+			continue
+		}
+
+		err = filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || !entry.Type().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(root, name)
+			if err != nil {
+				return err
+			}
+			files = append(files, ingestFile{path: name, object: path.Join(prefix, filepath.ToSlash(rel))})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// ingest uploads local files to MinIO, optionally queueing a job for each.
+func ingest(args []string) error {
+	flags, opts := newFlagSet("ingest", " <path>...")
+	prefix := flags.String("prefix", "", "object key `prefix` to upload below")
+	jobType := flags.String("job", "", "queue a job of this `type` for every uploaded file; needs QUEUE_BACKEND=redis")
+	priority := flags.String("priority", "normal", "priority of the queued jobs")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("no paths to ingest")
+	}
+
+	cfg, err := opts.load()
+	if err != nil {
+		return err
+	}
+
+	files, err := ingestFiles(flags.Args(), *prefix)
+	if err != nil {
+		return err
+	}
+
+	// Only a shared queue reaches a worker; jobs in this process's memory
+	// queue would be lost on exit
+	var queue jobs.Queue
+	if *jobType != "" {
+		if !cfg.Processing.Queue.IsDistributed() {
+			return fmt.Errorf("-job needs a shared queue, set QUEUE_BACKEND=redis")
+		}
+		queue, err = jobs.NewQueue(cfg.Processing)
+		if err != nil {
+			return fmt.Errorf("failed to create job queue: %w", err)
+		}
+		defer queue.Stop()
+	}
+
+	storageClient, err := storage.NewMinIOClient(&cfg.MinIO)
+	if err != nil {
+		return fmt.Errorf("failed to create MinIO client: %w", err)
+	}
+
+	ctx := context.Background()
+	for _, file := range files {
+		if err := uploadFile(ctx, storageClient, file); err != nil {
+			return err
+		}
+		log.Printf("Uploaded %s to %s", file.path, file.object)
+
+		if queue != nil {
+			job := jobs.NewJob(*jobType, file.object, cfg.MinIO.Bucket, file.object, jobs.ParsePriority(*priority))
+			if err := queue.Enqueue(job); err != nil {
+				return fmt.Errorf("failed to queue %s job for %s: %w", *jobType, file.object, err)
+			}
+			log.Printf("Queued %s job %s", *jobType, job.ID)
+		}
+	}
+	log.Printf("Ingested %d files", len(files))
+	return nil
+}
+
+func uploadFile(ctx context.Context, storageClient *storage.MinIOClient, file ingestFile) error {
+	f, err := os.Open(file.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(file.path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if _, err := storageClient.UploadFile(ctx, file.object, f, info.Size(), contentType); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", file.path, err)
+	}
+	return nil
+}
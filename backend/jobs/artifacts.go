@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"bronze-backend/storage"
+	"bronze-backend/tenant"
+)
+
+// Standard artifact names written under a job's prefix.
+const (
+	ArtifactResult   = "result.json"
+	ArtifactManifest = "manifest.json"
+	ArtifactError    = "error.json"
+)
+
+// ArtifactPrefix is the MinIO prefix holding every artifact of a job.
+func ArtifactPrefix(jobID string) string {
+	return path.Join("jobs", jobID) + "/"
+}
+
+// AddArtifact links an object stored in MinIO to the job record.
+func (j *Job) AddArtifact(name, objectName string) {
+	if j.Artifacts == nil {
+		j.Artifacts = make(map[string]string)
+	}
+	j.Artifacts[name] = objectName
+}
+
+// SaveArtifact writes v as JSON to jobs/{id}/{name}, below the tenant's
+// prefix, and links it from the job.
+func SaveArtifact(ctx context.Context, minioClient *storage.MinIOClient, job *Job, name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode artifact %s: %w", name, err)
+	}
+
+	objectName := tenant.Prefix(ctx) + ArtifactPrefix(job.ID) + name
+	if _, err := minioClient.UploadFile(ctx, objectName, bytes.NewReader(data), int64(len(data)), "application/json"); err != nil {
+		return fmt.Errorf("failed to upload artifact %s: %w", name, err)
+	}
+
+	job.AddArtifact(name, objectName)
+	return nil
+}
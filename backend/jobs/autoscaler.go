@@ -0,0 +1,161 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"bronze-backend/config"
+)
+
+// Autoscaler periodically resizes a WorkerPool between configured bounds
+// based on how many jobs are pending and how long jobs typically take.
+type Autoscaler struct {
+	pool       *WorkerPool
+	queue      Queue
+	minWorkers int
+	maxWorkers int
+	interval   time.Duration
+	targetWait time.Duration
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+
+	mu           sync.RWMutex
+	lastDecision AutoscalerStats
+}
+
+type AutoscalerStats struct {
+	Enabled        bool      `json:"enabled"`
+	MinWorkers     int       `json:"min_workers"`
+	MaxWorkers     int       `json:"max_workers"`
+	DesiredWorkers int       `json:"desired_workers"`
+	PendingJobs    int       `json:"pending_jobs"`
+	AvgJobDuration string    `json:"avg_job_duration"`
+	LastEvaluated  time.Time `json:"last_evaluated,omitempty"`
+}
+
+func NewAutoscaler(pool *WorkerPool, queue Queue, cfg config.AutoscaleConfig) *Autoscaler {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	minWorkers := cfg.MinWorkers
+	if minWorkers < 1 {
+		minWorkers = 1
+	}
+	maxWorkers := cfg.MaxWorkers
+	if maxWorkers < minWorkers {
+		maxWorkers = minWorkers
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	targetWait := cfg.TargetWait
+	if targetWait <= 0 {
+		targetWait = 30 * time.Second
+	}
+
+	return &Autoscaler{
+		pool:       pool,
+		queue:      queue,
+		minWorkers: minWorkers,
+		maxWorkers: maxWorkers,
+		interval:   interval,
+		targetWait: targetWait,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+func (a *Autoscaler) Start() {
+	a.wg.Add(1)
+	go a.loop()
+	log.Printf("Worker autoscaler started (min: %d, max: %d, interval: %v)", a.minWorkers, a.maxWorkers, a.interval)
+}
+
+func (a *Autoscaler) Stop() {
+	a.cancel()
+	a.wg.Wait()
+	log.Println("Worker autoscaler stopped")
+}
+
+func (a *Autoscaler) loop() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	a.evaluate()
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			a.evaluate()
+		}
+	}
+}
+
+func (a *Autoscaler) evaluate() {
+	pending := a.queue.Size()
+	active := len(a.pool.GetActiveJobs())
+	avg := a.pool.AverageJobDuration()
+	current := a.pool.GetWorkerCount()
+
+	desired := a.desiredWorkers(pending, active, avg)
+
+	// Grow immediately, but shrink one worker per tick to avoid thrashing
+	// when the queue briefly drains between bursts.
+	target := desired
+	if desired < current {
+		target = current - 1
+	}
+	if target != current {
+		log.Printf("Autoscaler: resizing worker pool %d -> %d (pending: %d, active: %d, avg duration: %v)",
+			current, target, pending, active, avg)
+		a.pool.UpdateWorkerCount(target)
+	}
+
+	a.mu.Lock()
+	a.lastDecision = AutoscalerStats{
+		Enabled:        true,
+		MinWorkers:     a.minWorkers,
+		MaxWorkers:     a.maxWorkers,
+		DesiredWorkers: desired,
+		PendingJobs:    pending,
+		AvgJobDuration: avg.String(),
+		LastEvaluated:  time.Now(),
+	}
+	a.mu.Unlock()
+}
+
+// desiredWorkers keeps every running job on a worker and adds enough workers
+// to drain the pending backlog within the target wait time.
+func (a *Autoscaler) desiredWorkers(pending, active int, avg time.Duration) int {
+	backlog := pending
+	if avg > 0 {
+		backlog = int(math.Ceil(float64(pending) * float64(avg) / float64(a.targetWait)))
+	}
+
+	desired := active + backlog
+	if desired < a.minWorkers {
+		desired = a.minWorkers
+	}
+	if desired > a.maxWorkers {
+		desired = a.maxWorkers
+	}
+	return desired
+}
+
+func (a *Autoscaler) GetStats() AutoscalerStats {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	stats := a.lastDecision
+	stats.Enabled = true
+	stats.MinWorkers = a.minWorkers
+	stats.MaxWorkers = a.maxWorkers
+	return stats
+}
@@ -0,0 +1,243 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/minio/minio-go/v7"
+
+	"bronze-backend/httputil"
+	"bronze-backend/storage"
+	"bronze-backend/tenant"
+)
+
+// DeadLetterPrefix is the MinIO prefix, below the tenant's, holding an entry
+// for each job in the dead-letter queue.
+const DeadLetterPrefix = "dead-letter/"
+
+// MetadataDeadLetter, set to true, sends a job to the dead-letter queue when
+// it fails. Jobs created from watcher events set it, as nobody waits on them
+// to see them fail.
+const MetadataDeadLetter = "dead_letter"
+
+// DeadLetter is a failed job waiting to be retried or discarded.
+type DeadLetter struct {
+	Job      *Job      `json:"job"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// deadLetterStore is the part of the MinIO client the dead-letter queue uses.
+type deadLetterStore interface {
+	UploadFile(ctx context.Context, objectName string, reader io.Reader, size int64, contentType string) (minio.UploadInfo, error)
+	DownloadFile(ctx context.Context, objectName string) (io.ReadCloser, error)
+	ListFiles(ctx context.Context, prefix string, limit int) ([]minio.ObjectInfo, error)
+	DeleteFile(ctx context.Context, objectName string) error
+}
+
+// DeadLetterQueue keeps failed jobs in the bucket they ran in, so they can
+// be looked at and retried once what made them fail is fixed.
+type DeadLetterQueue struct {
+	client deadLetterStore
+}
+
+// NewDeadLetterQueue returns the dead-letter queue stored with client, or nil
+// without storage. A nil queue drops the jobs added to it.
+func NewDeadLetterQueue(client *storage.MinIOClient) *DeadLetterQueue {
+	if client == nil {
+		return nil
+	}
+	return &DeadLetterQueue{client: client}
+}
+
+func deadLetterKey(ctx context.Context, id string) string {
+	return tenant.Prefix(ctx) + DeadLetterPrefix + id + ".json"
+}
+
+// Add puts a failed job in the dead-letter queue of ctx's bucket and tenant.
+func (q *DeadLetterQueue) Add(ctx context.Context, job *Job) error {
+	if q == nil {
+		return nil
+	}
+
+	entry := DeadLetter{Job: job, Error: job.Error, FailedAt: time.Now().UTC()}
+	if job.CompletedAt != nil {
+		entry.FailedAt = job.CompletedAt.UTC()
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter %s: %w", job.ID, err)
+	}
+	if _, err := q.client.UploadFile(ctx, deadLetterKey(ctx, job.ID), bytes.NewReader(data), int64(len(data)), "application/json"); err != nil {
+		return fmt.Errorf("failed to save dead letter %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// List returns the dead-lettered jobs of ctx's bucket and tenant, oldest
+// first.
+func (q *DeadLetterQueue) List(ctx context.Context) ([]DeadLetter, error) {
+	objects, err := q.client.ListFiles(ctx, tenant.Prefix(ctx)+DeadLetterPrefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+
+	entries := make([]DeadLetter, 0, len(objects))
+	for _, object := range objects {
+		id, ok := strings.CutSuffix(path.Base(object.Key), ".json")
+		if !ok || strings.HasSuffix(object.Key, "/") {
+			continue
+		}
+		entry, err := q.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	slices.SortStableFunc(entries, func(a, b DeadLetter) int {
+		return a.FailedAt.Compare(b.FailedAt)
+	})
+	return entries, nil
+}
+
+// Get returns the dead letter of the job id.
+func (q *DeadLetterQueue) Get(ctx context.Context, id string) (DeadLetter, error) {
+	var entry DeadLetter
+	key := deadLetterKey(ctx, id)
+	reader, err := q.client.DownloadFile(ctx, key)
+	if err != nil {
+		return entry, fmt.Errorf("failed to read dead letter %s: %w", id, err)
+	}
+	defer reader.Close()
+	if err := json.NewDecoder(reader).Decode(&entry); err != nil {
+		return entry, fmt.Errorf("failed to read dead letter %s: %w", id, err)
+	}
+	if entry.Job == nil {
+		return entry, fmt.Errorf("dead letter %s has no job", id)
+	}
+	return entry, nil
+}
+
+// Remove takes the job id out of the dead-letter queue.
+func (q *DeadLetterQueue) Remove(ctx context.Context, id string) error {
+	return q.client.DeleteFile(ctx, deadLetterKey(ctx, id))
+}
+
+// DeadLetterListResponse is the body of GET /api/jobs/dead-letter.
+type DeadLetterListResponse struct {
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Entries []DeadLetter `json:"entries"`
+	Total   int          `json:"total"`
+}
+
+// SetDeadLetters serves the dead-letter queue.
+func (h *JobHandler) SetDeadLetters(queue *DeadLetterQueue) {
+	h.deadLetters = queue
+}
+
+// ListDeadLetters returns the failed jobs waiting in the dead-letter queue of
+// the caller's bucket and tenant.
+func (h *JobHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if h.deadLetters == nil {
+		httputil.Error(w, "Dead-letter queue is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	entries, err := h.deadLetters.List(r.Context())
+	if err != nil {
+		httputil.WriteCode(w, httputil.CodeUpstream, "Failed to list dead letters", err)
+		return
+	}
+	entries = slices.DeleteFunc(entries, func(entry DeadLetter) bool { return !visible(r, entry.Job) })
+
+	h.writeJSON(w, http.StatusOK, DeadLetterListResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d jobs in the dead-letter queue", len(entries)),
+		Entries: entries,
+		Total:   len(entries),
+	})
+}
+
+// deadLetter returns the visible dead letter named in the request, writing
+// the error response when there is none.
+func (h *JobHandler) deadLetter(w http.ResponseWriter, r *http.Request) (DeadLetter, bool) {
+	if h.deadLetters == nil {
+		httputil.Error(w, "Dead-letter queue is not available", http.StatusServiceUnavailable)
+		return DeadLetter{}, false
+	}
+
+	entry, err := h.deadLetters.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		var response minio.ErrorResponse
+		if errors.As(err, &response) && response.Code == "NoSuchKey" {
+			httputil.WriteCode(w, httputil.CodeJobNotFound, "Job is not in the dead-letter queue", nil)
+		} else {
+			httputil.WriteCode(w, httputil.CodeUpstream, "Failed to read dead letter", err)
+		}
+		return DeadLetter{}, false
+	}
+	if !visible(r, entry.Job) {
+		httputil.WriteCode(w, httputil.CodeJobNotFound, "Job is not in the dead-letter queue", nil)
+		return DeadLetter{}, false
+	}
+	return entry, true
+}
+
+// RetryDeadLetter enqueues a new job like the dead-lettered one and takes
+// that one out of the queue.
+func (h *JobHandler) RetryDeadLetter(w http.ResponseWriter, r *http.Request) {
+	entry, ok := h.deadLetter(w, r)
+	if !ok {
+		return
+	}
+
+	failed := entry.Job
+	job := newRetry(failed)
+	// A job retried by hand gets its retries again
+	delete(job.Metadata, MetadataAttempt)
+
+	if err := h.jobQueue.Enqueue(job); err != nil {
+		httputil.WriteError(w, "Failed to enqueue job", http.StatusServiceUnavailable, err)
+		return
+	}
+	if err := h.deadLetters.Remove(r.Context(), failed.ID); err != nil {
+		log.Printf("Warning: Failed to remove retried dead letter %s: %v", failed.ID, err)
+	}
+
+	h.writeJSON(w, http.StatusCreated, JobResponse{
+		Success: true,
+		Message: fmt.Sprintf("Job %s retried as %s", failed.ID, job.ID),
+		Job:     job,
+	})
+}
+
+// DiscardDeadLetter takes a job out of the dead-letter queue without
+// retrying it.
+func (h *JobHandler) DiscardDeadLetter(w http.ResponseWriter, r *http.Request) {
+	entry, ok := h.deadLetter(w, r)
+	if !ok {
+		return
+	}
+	if err := h.deadLetters.Remove(r.Context(), entry.Job.ID); err != nil {
+		httputil.WriteCode(w, httputil.CodeUpstream, "Failed to discard dead letter", err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, JobResponse{
+		Success: true,
+		Message: fmt.Sprintf("Job %s discarded from the dead-letter queue", entry.Job.ID),
+		Job:     entry.Job,
+	})
+}
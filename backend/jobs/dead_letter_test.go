@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/minio/minio-go/v7"
+)
+
+// memoryObjects keeps objects in a map.
+type memoryObjects map[string][]byte
+
+func (m memoryObjects) UploadFile(_ context.Context, key string, reader io.Reader, _ int64, _ string) (minio.UploadInfo, error) {
+	body, err := io.ReadAll(reader)
+	m[key] = body
+	return minio.UploadInfo{Key: key}, err
+}
+
+func (m memoryObjects) DownloadFile(_ context.Context, key string) (io.ReadCloser, error) {
+	body, ok := m[key]
+	if !ok {
+		return nil, minio.ErrorResponse{Code: "NoSuchKey"}
+	}
+	return io.NopCloser(bytes.NewReader(body)), nil
+}
+
+func (m memoryObjects) ListFiles(_ context.Context, prefix string, _ int) ([]minio.ObjectInfo, error) {
+	var objects []minio.ObjectInfo
+	for key := range m {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, minio.ObjectInfo{Key: key})
+		}
+	}
+	return objects, nil
+}
+
+func (m memoryObjects) DeleteFile(_ context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+func TestDeadLetterQueue(t *testing.T) {
+	objects := memoryObjects{}
+	deadLetters := &DeadLetterQueue{client: objects}
+	queue := NewJobQueue(1, 10)
+	pool := NewWorkerPool(1, queue, nil)
+	pool.RegisterProcessor("boom", panicProcessor{})
+	pool.SetDeadLetters(deadLetters)
+	pool.workerStarted(0)
+
+	// Only jobs that ask for it are dead-lettered
+	failed := NewJob("boom", "in/a.csv", "lake", "in/a.csv", PriorityHigh)
+	failed.Metadata[MetadataDeadLetter] = true
+	failed.Metadata["preset"] = "sales"
+	ignored := NewJob("boom", "in/b.csv", "lake", "in/b.csv", PriorityMedium)
+	for _, job := range []*Job{failed, ignored} {
+		queue.Enqueue(job)
+		pool.processJob(0, queue.Dequeue())
+	}
+	if _, ok := objects[DeadLetterPrefix+failed.ID+".json"]; !ok || len(objects) != 1 {
+		t.Fatalf("dead letters = %v, want only job %s", objects, failed.ID)
+	}
+
+	h := NewJobHandler(queue, nil)
+	h.SetDeadLetters(deadLetters)
+	serve := func(handler http.HandlerFunc, method, id string) (int, *bytes.Buffer) {
+		req := httptest.NewRequest(method, "/api/jobs/dead-letter/"+id, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code, rec.Body
+	}
+
+	_, body := serve(h.ListDeadLetters, http.MethodGet, "")
+	var list DeadLetterListResponse
+	json.NewDecoder(body).Decode(&list)
+	if list.Total != 1 || list.Entries[0].Job.ID != failed.ID || !strings.Contains(list.Entries[0].Error, "processor exploded") {
+		t.Fatalf("list = %+v", list)
+	}
+
+	code, body := serve(h.RetryDeadLetter, http.MethodPost, failed.ID)
+	var retried JobResponse
+	json.NewDecoder(body).Decode(&retried)
+	if code != http.StatusCreated || retried.Job.ID == failed.ID || retried.Job.Priority != PriorityHigh || retried.Job.Metadata["preset"] != "sales" || retried.Job.Metadata["retry_of"] != failed.ID {
+		t.Fatalf("retry: status %d, %+v", code, retried.Job)
+	}
+	if job, ok := queue.GetJob(retried.Job.ID); !ok || job.Status != JobStatusPending {
+		t.Errorf("retried job not queued")
+	}
+	if len(objects) != 0 {
+		t.Errorf("retried job still dead-lettered: %v", objects)
+	}
+
+	if code, _ := serve(h.DiscardDeadLetter, http.MethodDelete, failed.ID); code != http.StatusNotFound {
+		t.Errorf("discarding a retried job: status %d, want 404", code)
+	}
+}
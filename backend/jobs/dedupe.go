@@ -0,0 +1,48 @@
+package jobs
+
+import "fmt"
+
+// DedupeKey identifies a job by what it would do, so resubmitting the same
+// unchanged object can be recognised. It is empty when the object's ETag is
+// unknown, since there is then no way to tell the content hasn't changed.
+func (j *Job) DedupeKey() string {
+	if j.ETag == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s|%s|%s|%s", j.Type, j.Bucket, j.ObjectName, j.ETag)
+}
+
+// blocksDuplicates reports whether a job with status keeps equivalent jobs
+// from being enqueued: one that failed or was cancelled does not.
+func blocksDuplicates(status JobStatus) bool {
+	switch status {
+	case JobStatusPending, JobStatusProcessing, JobStatusCompleted:
+		return true
+	}
+	return false
+}
+
+// EnqueueUnique enqueues job unless an equivalent job already exists, in
+// which case the existing job is returned with duplicate set. Setting force
+// always enqueues. Each queue backend keeps an index of the jobs by dedupe
+// key, so the check costs the same however many jobs there are.
+func EnqueueUnique(q Queue, job *Job, force bool) (*Job, bool, error) {
+	if force || job.DedupeKey() == "" {
+		if force && job.ETag != "" {
+			job.Metadata["forced"] = true
+		}
+		if err := q.Enqueue(job); err != nil {
+			return nil, false, err
+		}
+		return job, false, nil
+	}
+
+	existing, err := q.EnqueueUnique(job)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, true, nil
+	}
+	return job, false, nil
+}
@@ -0,0 +1,169 @@
+package jobs
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultJobPageSize is the page GET /api/jobs returns without a limit, and
+// MaxJobPageSize the largest it returns with one.
+const (
+	DefaultJobPageSize = 100
+	MaxJobPageSize     = 1000
+)
+
+// JobFilter selects, orders and pages jobs for listing.
+type JobFilter struct {
+	Type          string
+	Status        JobStatus
+	Prefix        string // Object name prefix
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	SortBy        string // "created_at" (default) or "duration"
+	Order         string // "desc" (default) or "asc"
+	Limit         int    // 0 returns every match
+	Offset        int
+}
+
+// ParseJobFilter reads a JobFilter from GET /api/jobs query parameters. It
+// always sets a limit: DefaultJobPageSize when none is given, and at most
+// MaxJobPageSize.
+func ParseJobFilter(query url.Values) (JobFilter, error) {
+	filter := JobFilter{
+		Type:   query.Get("type"),
+		Status: JobStatus(query.Get("status")),
+		Prefix: query.Get("prefix"),
+		SortBy: query.Get("sort"),
+		Order:  strings.ToLower(query.Get("order")),
+	}
+
+	switch filter.SortBy {
+	case "":
+		filter.SortBy = "created_at"
+	case "created_at", "duration":
+	default:
+		return filter, fmt.Errorf("invalid sort %q, use created_at or duration", filter.SortBy)
+	}
+
+	switch filter.Order {
+	case "":
+		filter.Order = "desc"
+	case "asc", "desc":
+	default:
+		return filter, fmt.Errorf("invalid order %q, use asc or desc", filter.Order)
+	}
+
+	var err error
+	if filter.CreatedAfter, err = parseFilterTime(query.Get("created_after")); err != nil {
+		return filter, fmt.Errorf("invalid created_after: %w", err)
+	}
+	if filter.CreatedBefore, err = parseFilterTime(query.Get("created_before")); err != nil {
+		return filter, fmt.Errorf("invalid created_before: %w", err)
+	}
+
+	if filter.Limit, err = parseFilterInt(query.Get("limit")); err != nil {
+		return filter, fmt.Errorf("invalid limit: %w", err)
+	}
+	if filter.Offset, err = parseFilterInt(query.Get("offset")); err != nil {
+		return filter, fmt.Errorf("invalid offset: %w", err)
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultJobPageSize
+	}
+	filter.Limit = min(filter.Limit, MaxJobPageSize)
+
+	return filter, nil
+}
+
+func parseFilterTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+func parseFilterInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return n, nil
+}
+
+// Matches reports whether job passes every filter that is set.
+func (f JobFilter) Matches(job *Job) bool {
+	if f.Type != "" && job.Type != f.Type {
+		return false
+	}
+	if f.Status != "" && job.Status != f.Status {
+		return false
+	}
+	if f.Prefix != "" && !strings.HasPrefix(job.ObjectName, f.Prefix) {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && job.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !job.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// Apply filters and sorts jobs, returning the requested page along with the
+// total number of matches.
+func (f JobFilter) Apply(jobs []*Job) ([]*Job, int) {
+	matched := make([]*Job, 0, len(jobs))
+	for _, job := range jobs {
+		if f.Matches(job) {
+			matched = append(matched, job)
+		}
+	}
+
+	less := func(a, b *Job) bool {
+		if a.CreatedAt.Equal(b.CreatedAt) {
+			return a.ID < b.ID
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	if f.SortBy == "duration" {
+		less = func(a, b *Job) bool {
+			da, db := a.GetDuration(), b.GetDuration()
+			if da == db {
+				return a.ID < b.ID
+			}
+			return da < db
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		if f.Order == "asc" {
+			return less(matched[i], matched[j])
+		}
+		return less(matched[j], matched[i])
+	})
+
+	total := len(matched)
+	if f.Offset >= total {
+		return []*Job{}, total
+	}
+	matched = matched[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(matched) {
+		matched = matched[:f.Limit]
+	}
+
+	return matched, total
+}
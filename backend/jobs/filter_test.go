@@ -0,0 +1,133 @@
+package jobs
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newFilterTestJobs() []*Job {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	jobs := make([]*Job, 0, 5)
+	for i, spec := range []struct {
+		jobType string
+		object  string
+		status  JobStatus
+	}{
+		{"extract", "uploads/a.zip", JobStatusCompleted},
+		{"extract", "uploads/b.zip", JobStatusFailed},
+		{"export", "reports/c.csv", JobStatusCompleted},
+		{"extract", "archive/d.zip", JobStatusPending},
+		{"export", "uploads/e.csv", JobStatusPending},
+	} {
+		job := NewJob(spec.jobType, spec.object, "files", spec.object, PriorityMedium)
+		job.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		job.Status = spec.status
+		if spec.status == JobStatusCompleted || spec.status == JobStatusFailed {
+			started := job.CreatedAt
+			completed := started.Add(time.Duration(5-i) * time.Minute)
+			job.StartedAt = &started
+			job.CompletedAt = &completed
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+func TestJobFilterDefaultsToNewestFirst(t *testing.T) {
+	jobs := newFilterTestJobs()
+
+	filter, err := ParseJobFilter(url.Values{})
+	if err != nil {
+		t.Fatalf("ParseJobFilter failed: %v", err)
+	}
+
+	page, total := filter.Apply(jobs)
+	if total != len(jobs) || len(page) != len(jobs) {
+		t.Fatalf("Expected all %d jobs, got %d of %d", len(jobs), len(page), total)
+	}
+	if page[0] != jobs[4] || page[4] != jobs[0] {
+		t.Errorf("Expected jobs sorted by created_at descending")
+	}
+}
+
+func TestJobFilterMatchesFields(t *testing.T) {
+	jobs := newFilterTestJobs()
+
+	query := url.Values{}
+	query.Set("type", "extract")
+	query.Set("prefix", "uploads/")
+	query.Set("created_after", "2024-01-01T00:30:00Z")
+
+	filter, err := ParseJobFilter(query)
+	if err != nil {
+		t.Fatalf("ParseJobFilter failed: %v", err)
+	}
+
+	page, total := filter.Apply(jobs)
+	if total != 1 || page[0] != jobs[1] {
+		t.Fatalf("Expected only uploads/b.zip, got %d jobs", total)
+	}
+}
+
+func TestJobFilterSortByDurationAndPaginate(t *testing.T) {
+	jobs := newFilterTestJobs()
+
+	query := url.Values{}
+	query.Set("sort", "duration")
+	query.Set("order", "asc")
+	query.Set("status", string(JobStatusCompleted))
+	query.Set("limit", "1")
+	query.Set("offset", "1")
+
+	filter, err := ParseJobFilter(query)
+	if err != nil {
+		t.Fatalf("ParseJobFilter failed: %v", err)
+	}
+
+	page, total := filter.Apply(jobs)
+	if total != 2 {
+		t.Fatalf("Expected 2 completed jobs, got %d", total)
+	}
+	// jobs[2] ran 3 minutes and jobs[0] ran 5, so the second page is jobs[0]
+	if len(page) != 1 || page[0] != jobs[0] {
+		t.Errorf("Expected second page to hold the longest completed job")
+	}
+
+	filter.Offset = 10
+	if page, _ := filter.Apply(jobs); len(page) != 0 {
+		t.Errorf("Expected empty page past the end, got %d jobs", len(page))
+	}
+}
+
+func TestParseJobFilterLimitsPages(t *testing.T) {
+	for value, want := range map[string]int{
+		"":      DefaultJobPageSize,
+		"0":     DefaultJobPageSize,
+		"20":    20,
+		"50000": MaxJobPageSize,
+	} {
+		filter, err := ParseJobFilter(url.Values{"limit": {value}})
+		if err != nil {
+			t.Fatalf("limit %q: %v", value, err)
+		}
+		if filter.Limit != want {
+			t.Errorf("limit %q parsed as %d, want %d", value, filter.Limit, want)
+		}
+	}
+}
+
+func TestParseJobFilterRejectsInvalidValues(t *testing.T) {
+	for name, query := range map[string]url.Values{
+		"sort":   {"sort": {"name"}},
+		"order":  {"order": {"sideways"}},
+		"limit":  {"limit": {"-1"}},
+		"offset": {"offset": {"abc"}},
+		"date":   {"created_before": {"yesterday"}},
+	} {
+		if _, err := ParseJobFilter(query); err == nil {
+			t.Errorf("Expected error for invalid %s", name)
+		}
+	}
+}
@@ -2,10 +2,14 @@ package jobs
 
 import (
 	"container/heap"
+	"context"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+
+	"bronze-backend/tenant"
 )
 
 type JobStatus string
@@ -59,23 +63,38 @@ type JobTrigger struct {
 }
 
 type Job struct {
-	ID          string         `json:"id"`
-	Type        string         `json:"type"`
-	Priority    JobPriority    `json:"priority"`
-	Status      JobStatus      `json:"status"`
-	FilePath    string         `json:"file_path"`
-	Bucket      string         `json:"bucket"`
-	ObjectName  string         `json:"object_name"`
-	CreatedAt   time.Time      `json:"created_at"`
-	StartedAt   *time.Time     `json:"started_at,omitempty"`
-	CompletedAt *time.Time     `json:"completed_at,omitempty"`
-	Error       string         `json:"error,omitempty"`
-	Result      any            `json:"result,omitempty"`
-	Progress    float64        `json:"progress"`
-	Metadata    map[string]any `json:"metadata"`
-	DependsOn   []string       `json:"depends_on,omitempty"`
-	Triggers    []JobTrigger   `json:"triggers,omitempty"`
-	ChainID     string         `json:"chain_id,omitempty"`
+	ID          string            `json:"id"`
+	Type        string            `json:"type"`
+	Priority    JobPriority       `json:"priority"`
+	Status      JobStatus         `json:"status"`
+	FilePath    string            `json:"file_path"`
+	Bucket      string            `json:"bucket"`
+	ObjectName  string            `json:"object_name"`
+	ETag        string            `json:"etag,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	StartedAt   *time.Time        `json:"started_at,omitempty"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	Result      any               `json:"result,omitempty"`
+	Progress    float64           `json:"progress"`
+	Metadata    map[string]any    `json:"metadata"`
+	DependsOn   []string          `json:"depends_on,omitempty"`
+	Triggers    []JobTrigger      `json:"triggers,omitempty"`
+	ChainID     string            `json:"chain_id,omitempty"`
+	Interrupted bool              `json:"interrupted,omitempty"`
+	Artifacts   map[string]string `json:"artifacts,omitempty"` // Artifact name -> MinIO object
+	CallbackURL string            `json:"callback_url,omitempty"`
+	// TraceContext links the job's spans to the request that created it
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+	// Subject is the authenticated caller who created the job; empty for
+	// jobs created by the service itself or with auth disabled
+	Subject string `json:"subject,omitempty"`
+	// Tenant confines the job to a tenant's bucket, prefix and Nessie
+	// namespace; empty for deployment-wide jobs
+	Tenant string `json:"tenant,omitempty"`
+	// Password opens protected archives. It is never serialized, so it
+	// does not appear in API responses, the state file or job listings.
+	Password string `json:"-"`
 }
 
 type JobResult struct {
@@ -85,6 +104,10 @@ type JobResult struct {
 	ProcessingTime time.Duration  `json:"processing_time"`
 	Message        string         `json:"message"`
 	Result         any            `json:"result,omitempty"`
+	// FileCount is the number of extracted files when ExtractedFiles was
+	// truncated, the full list being in the job's result artifact
+	FileCount int  `json:"file_count,omitempty"`
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 func NewJob(jobType, filePath, bucket, objectName string, priority JobPriority) *Job {
@@ -129,6 +152,19 @@ func (j *Job) Cancel() {
 	j.CompletedAt = &now
 }
 
+// Interrupt resets a job that was cut off mid-run (e.g. by a shutdown) back
+// to pending so it can be picked up again from the start.
+func (j *Job) Interrupt() {
+	j.Status = JobStatusPending
+	j.StartedAt = nil
+	j.CompletedAt = nil
+	j.Error = ""
+	j.Result = nil
+	j.Progress = 0
+	j.Interrupted = true
+	j.Metadata["interrupted_at"] = time.Now()
+}
+
 func (j *Job) UpdateProgress(progress float64) {
 	if progress < 0 {
 		progress = 0
@@ -139,6 +175,12 @@ func (j *Job) UpdateProgress(progress float64) {
 	j.Progress = progress
 }
 
+// CheckTenant returns a *tenant.AccessError if the job's object is outside
+// the zone of the tenant ctx is confined to.
+func (j *Job) CheckTenant(ctx context.Context) error {
+	return tenant.Check(ctx, j.Bucket, j.ObjectName)
+}
+
 func (j *Job) GetDuration() time.Duration {
 	if j.StartedAt == nil {
 		return 0
@@ -176,13 +218,33 @@ func ParsePriority(s string) JobPriority {
 	}
 }
 
+// JobQueue is the in-memory queue. Pending jobs wait on a single heap,
+// ordered by priority and then age; capacity bounds how many may wait
+// there, and Ready wakes workers when one is pushed. Every job, pending,
+// running or finished, is kept in a registry, jobsMap, so it can be looked
+// up through its whole life; the oldest finished jobs are dropped past the
+// history limit.
 type JobQueue struct {
 	jobs     *PriorityQueue
 	workers  int
-	jobChan  chan *Job
-	stopChan chan struct{}
+	capacity int // Most pending jobs, 0 for no limit
 	mu       sync.RWMutex
 	jobsMap  map[string]*Job
+	// finished lists the IDs of finished jobs in jobsMap, oldest first
+	finished     []string
+	isFinished   map[string]bool
+	historyLimit int
+	// ready is closed, and replaced, whenever a job is pushed
+	ready chan struct{}
+	// boosts holds the priority of each boosted chain and when it expires
+	boosts map[string]chainBoost
+	// dedupe holds the ID of the latest job enqueued with each DedupeKey
+	dedupe map[string]string
+}
+
+type chainBoost struct {
+	priority JobPriority
+	expires  time.Time
 }
 
 type PriorityQueue []*Job
@@ -214,6 +276,8 @@ func (pq *PriorityQueue) Pop() interface{} {
 	return item
 }
 
+// NewJobQueue creates a queue holding at most queueSize pending jobs, or any
+// number for a queueSize of 0.
 func NewJobQueue(maxWorkers, queueSize int) *JobQueue {
 	pq := make(PriorityQueue, 0)
 	heap.Init(&pq)
@@ -221,32 +285,148 @@ func NewJobQueue(maxWorkers, queueSize int) *JobQueue {
 	return &JobQueue{
 		jobs:     &pq,
 		workers:  maxWorkers,
-		jobChan:  make(chan *Job, queueSize),
-		stopChan: make(chan struct{}),
+		capacity: max(queueSize, 0),
 		jobsMap:  make(map[string]*Job),
+		ready:    make(chan struct{}),
+		boosts:   make(map[string]chainBoost),
+		dedupe:   make(map[string]string),
+
+		isFinished:   make(map[string]bool),
+		historyLimit: DefaultJobHistory,
 	}
 }
 
-func (jq *JobQueue) Enqueue(job *Job) error {
+// DefaultJobHistory is how many finished jobs a JobQueue keeps by default.
+const DefaultJobHistory = 1000
+
+// SetHistoryLimit sets how many finished jobs are kept for lookup.
+func (jq *JobQueue) SetHistoryLimit(limit int) {
 	jq.mu.Lock()
 	defer jq.mu.Unlock()
 
-	if _, exists := jq.jobsMap[job.ID]; exists {
-		return ErrJobAlreadyExists
+	jq.historyLimit = limit
+	jq.pruneHistory()
+}
+
+// finish records that a job in the registry finished, dropping the oldest
+// finished jobs past the history limit. The caller holds jq.mu.
+func (jq *JobQueue) finish(job *Job) {
+	if jq.isFinished[job.ID] {
+		return
 	}
+	jq.isFinished[job.ID] = true
+	jq.finished = append(jq.finished, job.ID)
+	jq.pruneHistory()
+}
+
+// pruneHistory drops the oldest finished jobs past the history limit. The
+// caller holds jq.mu.
+func (jq *JobQueue) pruneHistory() {
+	for len(jq.finished) > jq.historyLimit {
+		id := jq.finished[0]
+		jq.finished = jq.finished[1:]
+		delete(jq.isFinished, id)
+		if job, ok := jq.jobsMap[id]; ok && jq.dedupe[job.DedupeKey()] == id {
+			delete(jq.dedupe, job.DedupeKey())
+		}
+		delete(jq.jobsMap, id)
+	}
+}
+
+// pendingIndex returns the position of a job on the heap, or -1. The caller
+// holds jq.mu.
+func (jq *JobQueue) pendingIndex(id string) int {
+	for i, job := range *jq.jobs {
+		if job.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// full reports whether no more jobs may be enqueued. The caller holds jq.mu.
+func (jq *JobQueue) full() bool {
+	return jq.capacity > 0 && jq.jobs.Len() >= jq.capacity
+}
 
+// push adds a job to the heap and wakes the workers waiting on Ready. The
+// caller holds jq.mu.
+func (jq *JobQueue) push(job *Job) {
 	heap.Push(jq.jobs, job)
 	jq.jobsMap[job.ID] = job
+	close(jq.ready)
+	jq.ready = make(chan struct{})
+}
 
-	select {
-	case jq.jobChan <- job:
-	default:
+// Enqueue adds a job, or returns ErrQueueFull, leaving the queue unchanged,
+// when capacity jobs are already pending.
+func (jq *JobQueue) Enqueue(job *Job) error {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+
+	return jq.enqueue(job)
+}
+
+// EnqueueUnique enqueues job unless the job last enqueued with its dedupe
+// key is still pending, processing or completed.
+func (jq *JobQueue) EnqueueUnique(job *Job) (*Job, error) {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+
+	if key := job.DedupeKey(); key != "" {
+		if existing, ok := jq.jobsMap[jq.dedupe[key]]; ok && existing.ID != job.ID && blocksDuplicates(existing.Status) {
+			return existing, nil
+		}
+	}
+	return nil, jq.enqueue(job)
+}
+
+// enqueue adds a job. The caller holds jq.mu.
+func (jq *JobQueue) enqueue(job *Job) error {
+	if _, exists := jq.jobsMap[job.ID]; exists {
+		return ErrJobAlreadyExists
+	}
+	if jq.full() {
 		return ErrQueueFull
 	}
 
+	if boost, ok := jq.boosts[job.ChainID]; ok && job.ChainID != "" && time.Now().Before(boost.expires) && job.Priority < boost.priority {
+		job.Priority = boost.priority
+	}
+
+	jq.push(job)
+	if key := job.DedupeKey(); key != "" {
+		jq.dedupe[key] = job.ID
+	}
 	return nil
 }
 
+// Requeue puts a job back on the pending heap even when the queue is full,
+// used for jobs interrupted by a shutdown, which must not be lost.
+func (jq *JobQueue) Requeue(job *Job) {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+
+	if jq.pendingIndex(job.ID) >= 0 {
+		return
+	}
+	if jq.isFinished[job.ID] {
+		delete(jq.isFinished, job.ID)
+		jq.finished = slices.DeleteFunc(jq.finished, func(id string) bool { return id == job.ID })
+	}
+
+	jq.push(job)
+}
+
+// Ready returns a channel closed once a job is pushed after the call, for
+// workers to wait on when Dequeue finds nothing.
+func (jq *JobQueue) Ready() <-chan struct{} {
+	jq.mu.RLock()
+	defer jq.mu.RUnlock()
+
+	return jq.ready
+}
+
 func (jq *JobQueue) Dequeue() *Job {
 	jq.mu.Lock()
 	defer jq.mu.Unlock()
@@ -255,10 +435,8 @@ func (jq *JobQueue) Dequeue() *Job {
 		return nil
 	}
 
-	job := heap.Pop(jq.jobs).(*Job)
-	delete(jq.jobsMap, job.ID)
-
-	return job
+	// The job stays in the registry while it runs
+	return heap.Pop(jq.jobs).(*Job)
 }
 
 func (jq *JobQueue) GetJob(id string) (*Job, bool) {
@@ -279,6 +457,9 @@ func (jq *JobQueue) UpdateJobStatus(id string, status JobStatus) bool {
 	}
 
 	job.Status = status
+	if isTerminalStatus(status) {
+		jq.finish(job)
+	}
 	return true
 }
 
@@ -321,6 +502,16 @@ func (jq *JobQueue) ListJobsByStatus(status JobStatus) []*Job {
 	return jobs
 }
 
+// PendingJobs returns a snapshot of the jobs still waiting on the heap.
+func (jq *JobQueue) PendingJobs() []*Job {
+	jq.mu.RLock()
+	defer jq.mu.RUnlock()
+
+	jobs := make([]*Job, len(*jq.jobs))
+	copy(jobs, *jq.jobs)
+	return jobs
+}
+
 func (jq *JobQueue) Size() int {
 	jq.mu.RLock()
 	defer jq.mu.RUnlock()
@@ -337,14 +528,48 @@ func (jq *JobQueue) CancelJob(id string) bool {
 		return false
 	}
 
-	if job.Status == JobStatusProcessing {
+	// Only a job still waiting on the heap can be cancelled; one a worker
+	// has taken, or that finished, cannot
+	i := jq.pendingIndex(id)
+	if i < 0 {
 		return false
 	}
 
+	// A cancelled job no longer waits, nor takes up capacity
+	heap.Remove(jq.jobs, i)
 	job.Cancel()
+	jq.finish(job)
 	return true
 }
 
+func (jq *JobQueue) BoostChain(chainID string, priority JobPriority) []string {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+
+	now := time.Now()
+	for id, boost := range jq.boosts {
+		if now.After(boost.expires) {
+			delete(jq.boosts, id)
+		}
+	}
+	if boost, ok := jq.boosts[chainID]; !ok || boost.priority <= priority {
+		jq.boosts[chainID] = chainBoost{priority: priority, expires: now.Add(chainBoostTTL)}
+	}
+
+	boosted := []string{}
+	for _, job := range *jq.jobs {
+		if inChain(job, chainID) && job.Status == JobStatusPending && job.Priority < priority {
+			job.Priority = priority
+			boosted = append(boosted, job.ID)
+		}
+	}
+	if len(boosted) > 0 {
+		heap.Init(jq.jobs)
+	}
+
+	return boosted
+}
+
 func (jq *JobQueue) GetStats() QueueStats {
 	jq.mu.RLock()
 	defer jq.mu.RUnlock()
@@ -376,11 +601,23 @@ func (jq *JobQueue) GetStats() QueueStats {
 	return stats
 }
 
+// Ping fails when the queue is full.
+func (jq *JobQueue) Ping(ctx context.Context) error {
+	jq.mu.RLock()
+	defer jq.mu.RUnlock()
+
+	if jq.full() {
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// Start and Stop do nothing: the queue has no goroutines, and jobs still
+// pending at Stop are saved with SaveState.
 func (jq *JobQueue) Start() {
 }
 
 func (jq *JobQueue) Stop() {
-	close(jq.stopChan)
 }
 
 type QueueStats struct {
@@ -2,39 +2,138 @@ package jobs
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"runtime"
+	"time"
 
 	"github.com/gorilla/mux"
+
+	"bronze-backend/auth"
+	"bronze-backend/httputil"
+	"bronze-backend/metering"
+	"bronze-backend/storage"
+	"bronze-backend/tempdir"
+	"bronze-backend/tenant"
 )
 
 type JobHandler struct {
-	jobQueue   *JobQueue
+	jobQueue   Queue
 	workerPool *WorkerPool
+	autoscaler *Autoscaler
+	usage      *metering.Tracker
+	storage    *storage.MinIOClient
+	// deadLetters is nil without storage
+	deadLetters *DeadLetterQueue
+	// notifier checks callback URLs; without one they are refused
+	notifier *WebhookNotifier
 }
 
-func NewJobHandler(jobQueue *JobQueue, workerPool *WorkerPool) *JobHandler {
+// NewJobHandler returns a handler for the jobs in jobQueue. workerPool is nil
+// on API-only instances, which leave processing to worker instances; the
+// worker endpoints then answer 503.
+func NewJobHandler(jobQueue Queue, workerPool *WorkerPool) *JobHandler {
 	return &JobHandler{
 		jobQueue:   jobQueue,
 		workerPool: workerPool,
 	}
 }
 
+// SetAutoscaler hands worker pool sizing over to the autoscaler; manual
+// worker count updates are rejected while one is set.
+func (h *JobHandler) SetAutoscaler(autoscaler *Autoscaler) {
+	h.autoscaler = autoscaler
+}
+
+// SetUsage refuses new jobs from callers over their job time quota.
+func (h *JobHandler) SetUsage(tracker *metering.Tracker) {
+	h.usage = tracker
+}
+
+// SetStorage reads the result artifacts of jobs whose results were
+// truncated.
+func (h *JobHandler) SetStorage(minioClient *storage.MinIOClient) {
+	h.storage = minioClient
+}
+
+// SetNotifier accepts the callback URLs notifier can deliver to.
+func (h *JobHandler) SetNotifier(notifier *WebhookNotifier) {
+	h.notifier = notifier
+}
+
+// requireWorkerPool answers 503 and returns false on instances without a
+// worker pool.
+func (h *JobHandler) requireWorkerPool(w http.ResponseWriter) bool {
+	if h.workerPool == nil {
+		httputil.WriteCode(w, httputil.CodeWorkersUnavailable, "Worker pool is not running on this instance", nil)
+		return false
+	}
+	return true
+}
+
+// visible reports whether the caller may see job: a tenant sees only its
+// own jobs.
+func visible(r *http.Request, job *Job) bool {
+	t, ok := tenant.FromContext(r.Context())
+	return !ok || job.Tenant == t.Name
+}
+
+// visibleJobs returns the jobs the caller may see.
+func visibleJobs(r *http.Request, jobs []*Job) []*Job {
+	if _, ok := tenant.FromContext(r.Context()); !ok {
+		return jobs
+	}
+	own := make([]*Job, 0, len(jobs))
+	for _, job := range jobs {
+		if visible(r, job) {
+			own = append(own, job)
+		}
+	}
+	return own
+}
+
+// Metadata of extract jobs only AUTO_EXTRACT rules set. CreateJob drops it
+// from the metadata and trigger parameters callers send, as it writes
+// outside the job's object and deletes the archive, which editors may not
+// do themselves.
+const (
+	// MetadataExtractPrefix extracts below {prefix}/{archive name}/ instead
+	// of the usual location
+	MetadataExtractPrefix = "extract_prefix"
+	// MetadataCleanup set to "delete" deletes the archive once it is
+	// extracted
+	MetadataCleanup = "cleanup"
+)
+
+// dropServerMetadata removes the metadata callers may not set.
+func dropServerMetadata(metadata map[string]any) {
+	delete(metadata, MetadataExtractPrefix)
+	delete(metadata, MetadataCleanup)
+}
+
 type CreateJobRequest struct {
-	Type       string       `json:"type"`
-	FilePath   string       `json:"file_path"`
-	Bucket     string       `json:"bucket"`
-	ObjectName string       `json:"object_name"`
-	Priority   string       `json:"priority"`
-	DependsOn  []string     `json:"depends_on,omitempty"`
-	Triggers   []JobTrigger `json:"triggers,omitempty"`
-	ChainID    string       `json:"chain_id,omitempty"`
+	Type        string         `json:"type"`
+	FilePath    string         `json:"file_path"`
+	Bucket      string         `json:"bucket"`
+	ObjectName  string         `json:"object_name"`
+	ETag        string         `json:"etag,omitempty"`
+	Priority    string         `json:"priority"`
+	DependsOn   []string       `json:"depends_on,omitempty"`
+	Triggers    []JobTrigger   `json:"triggers,omitempty"`
+	ChainID     string         `json:"chain_id,omitempty"`
+	Force       bool           `json:"force,omitempty"` // Re-run even if an identical job exists
+	Metadata    map[string]any `json:"metadata,omitempty"`
+	Password    string         `json:"password,omitempty"`     // For password-protected archives
+	CallbackURL string         `json:"callback_url,omitempty"` // Signed POST when the job finishes
 }
 
 type JobResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	Job     *Job   `json:"job,omitempty"`
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+	Job       *Job   `json:"job,omitempty"`
+	Duplicate bool   `json:"duplicate,omitempty"`
 }
 
 type JobsListResponse struct {
@@ -42,71 +141,172 @@ type JobsListResponse struct {
 	Message string `json:"message"`
 	Jobs    []*Job `json:"jobs"`
 	Count   int    `json:"count"`
+	Total   int    `json:"total"`
+	Limit   int    `json:"limit,omitempty"`
+	Offset  int    `json:"offset,omitempty"`
 }
 
 type JobStatsResponse struct {
-	Success bool            `json:"success"`
-	Message string          `json:"message"`
-	Queue   QueueStats      `json:"queue"`
-	Workers WorkerPoolStats `json:"workers"`
+	Success    bool             `json:"success"`
+	Message    string           `json:"message"`
+	Queue      QueueStats       `json:"queue"`
+	Workers    WorkerPoolStats  `json:"workers"`
+	Autoscaler *AutoscalerStats `json:"autoscaler,omitempty"`
+}
+
+type JobMetricsResponse struct {
+	Success bool           `json:"success"`
+	Message string         `json:"message"`
+	Metrics JobMetrics     `json:"metrics"`
+	Queue   QueueStats     `json:"queue"`
+	TempDir *tempdir.Stats `json:"temp_dir,omitempty"`
+	// ObjectCache is nil when OBJECT_CACHE_SIZE is unset
+	ObjectCache *storage.ObjectCacheStats `json:"object_cache,omitempty"`
 }
 
 type UpdatePriorityRequest struct {
 	Priority string `json:"priority"`
 }
 
+// ChainPriorityResponse lists the jobs of a chain whose priority was raised.
+type ChainPriorityResponse struct {
+	Success  bool   `json:"success"`
+	Message  string `json:"message"`
+	ChainID  string `json:"chain_id"`
+	Priority string `json:"priority"`
+	// Boosted lists the pending jobs raised; jobs already at or above the
+	// priority, or running, are left alone
+	Boosted []string `json:"boosted"`
+}
+
 type UpdateWorkersRequest struct {
 	Count int `json:"count"`
 }
 
+// CancelBatchRequest selects the pending jobs to cancel at once. The
+// filters are those of GET /api/jobs; one is required unless All is set.
+type CancelBatchRequest struct {
+	// Status is pending, the default, as only pending jobs are cancelled
+	Status        JobStatus `json:"status,omitempty"`
+	Type          string    `json:"type,omitempty"`
+	Prefix        string    `json:"prefix,omitempty"`         // Object name prefix
+	CreatedAfter  string    `json:"created_after,omitempty"`  // RFC 3339 or a date
+	CreatedBefore string    `json:"created_before,omitempty"` // RFC 3339 or a date
+	// All cancels every pending job the caller can see
+	All bool `json:"all,omitempty"`
+	// DryRun lists the jobs that would be cancelled, cancelling none
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+type CancelBatchResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Matched int    `json:"matched"`
+	// Cancelled lists the jobs cancelled, or that would be on a dry run
+	Cancelled []string `json:"cancelled"`
+	// Skipped lists the matching jobs that started before they could be
+	// cancelled
+	Skipped []string `json:"skipped,omitempty"`
+	DryRun  bool     `json:"dry_run,omitempty"`
+}
+
 func (h *JobHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req CreateJobRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeError(w, "Invalid request body", http.StatusBadRequest, err)
+		httputil.WriteError(w, "Invalid request body", http.StatusBadRequest, err)
 		return
 	}
 
 	if req.Type == "" {
-		h.writeError(w, "Job type is required", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Job type is required", http.StatusBadRequest, nil)
 		return
 	}
 
 	if req.FilePath == "" {
-		h.writeError(w, "File path is required", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "File path is required", http.StatusBadRequest, nil)
 		return
 	}
 
 	if req.Bucket == "" {
-		h.writeError(w, "Bucket is required", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Bucket is required", http.StatusBadRequest, nil)
 		return
 	}
 
 	if req.ObjectName == "" {
-		h.writeError(w, "Object name is required", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Object name is required", http.StatusBadRequest, nil)
 		return
 	}
 
 	priority := ParsePriority(req.Priority)
 	if priority == PriorityMedium && req.Priority != "" && req.Priority != "medium" {
-		h.writeError(w, "Invalid priority. Use: high, medium, low", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Invalid priority. Use: high, medium, low", http.StatusBadRequest, nil)
+		return
+	}
+
+	if req.CallbackURL != "" {
+		if err := h.notifier.ValidateCallback(r.Context(), req.CallbackURL); err != nil {
+			httputil.WriteError(w, "Invalid callback URL", http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if !h.usage.Allow(w, r, metering.MetricJobTime) {
 		return
 	}
 
 	job := NewJob(req.Type, req.FilePath, req.Bucket, req.ObjectName, priority)
+	job.ETag = req.ETag
+	job.CallbackURL = req.CallbackURL
+	job.SetTraceContext(r.Context())
+	job.Subject = auth.Subject(r.Context())
+	job.Tenant = tenant.Name(r.Context())
+	job.Password = req.Password
+	dropServerMetadata(req.Metadata)
+	for key, value := range req.Metadata {
+		job.Metadata[key] = value
+	}
+	for _, trigger := range req.Triggers {
+		dropServerMetadata(trigger.Parameters)
+	}
+
+	if err := job.CheckTenant(r.Context()); err != nil {
+		httputil.WriteError(w, "Object is outside the tenant's bucket", http.StatusForbidden, err)
+		return
+	}
+	// The worker runs the job in its bucket, so it takes the same rule as
+	// the X-Bucket header
+	if _, confined := tenant.FromContext(r.Context()); !confined && !h.storage.MayUseBucket(r.Context(), job.Bucket) {
+		httputil.Error(w, "Bucket is not allowed: "+job.Bucket, http.StatusForbidden)
+		return
+	}
 
 	// Set job chaining fields
 	job.DependsOn = req.DependsOn
 	job.Triggers = req.Triggers
 	job.ChainID = req.ChainID
 
-	err := h.jobQueue.Enqueue(job)
+	job, duplicate, err := EnqueueUnique(h.jobQueue, job, req.Force)
+	if errors.Is(err, ErrQueueFull) {
+		httputil.WriteError(w, "Job queue is full", http.StatusServiceUnavailable, err)
+		return
+	}
 	if err != nil {
-		h.writeError(w, "Failed to enqueue job", http.StatusInternalServerError, err)
+		httputil.WriteError(w, "Failed to enqueue job", http.StatusInternalServerError, err)
+		return
+	}
+
+	if duplicate {
+		h.writeJSON(w, http.StatusOK, JobResponse{
+			Success:   true,
+			Message:   "Identical job already exists",
+			Job:       job,
+			Duplicate: true,
+		})
 		return
 	}
 
@@ -121,25 +321,34 @@ func (h *JobHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 
 func (h *JobHandler) GetJobs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	status := r.URL.Query().Get("status")
+	filter, err := ParseJobFilter(r.URL.Query())
+	if err != nil {
+		httputil.WriteError(w, "Invalid query parameters", http.StatusBadRequest, err)
+		return
+	}
 
 	var jobs []*Job
-	if status != "" {
-		jobStatus := JobStatus(status)
-		jobs = h.jobQueue.ListJobsByStatus(jobStatus)
+	if filter.Status != "" {
+		jobs = h.jobQueue.ListJobsByStatus(filter.Status)
 	} else {
 		jobs = h.jobQueue.ListJobs()
 	}
+	jobs = visibleJobs(r, jobs)
+
+	jobs, total := filter.Apply(jobs)
 
 	response := JobsListResponse{
 		Success: true,
 		Message: "Jobs retrieved successfully",
 		Jobs:    jobs,
 		Count:   len(jobs),
+		Total:   total,
+		Limit:   filter.Limit,
+		Offset:  filter.Offset,
 	}
 
 	h.writeJSON(w, http.StatusOK, response)
@@ -147,7 +356,7 @@ func (h *JobHandler) GetJobs(w http.ResponseWriter, r *http.Request) {
 
 func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -155,13 +364,13 @@ func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
 	jobID := vars["id"]
 
 	if jobID == "" {
-		h.writeError(w, "Job ID is required", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Job ID is required", http.StatusBadRequest, nil)
 		return
 	}
 
 	job, exists := h.jobQueue.GetJob(jobID)
-	if !exists {
-		h.writeError(w, "Job not found", http.StatusNotFound, nil)
+	if !exists || !visible(r, job) {
+		httputil.WriteCode(w, httputil.CodeJobNotFound, "Job not found", nil)
 		return
 	}
 
@@ -176,7 +385,7 @@ func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
 
 func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -184,13 +393,18 @@ func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
 	jobID := vars["id"]
 
 	if jobID == "" {
-		h.writeError(w, "Job ID is required", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Job ID is required", http.StatusBadRequest, nil)
+		return
+	}
+
+	if job, exists := h.jobQueue.GetJob(jobID); exists && !visible(r, job) {
+		httputil.WriteCode(w, httputil.CodeJobNotFound, "Job not found or cannot be cancelled", nil)
 		return
 	}
 
 	success := h.jobQueue.CancelJob(jobID)
 	if !success {
-		h.writeError(w, "Job not found or cannot be cancelled", http.StatusNotFound, nil)
+		httputil.WriteCode(w, httputil.CodeJobNotFound, "Job not found or cannot be cancelled", nil)
 		return
 	}
 
@@ -203,9 +417,65 @@ func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, http.StatusOK, response)
 }
 
+// CancelBatch cancels every pending job matching the filters of the
+// request, such as the jobs of an unwanted bulk ingestion.
+func (h *JobHandler) CancelBatch(w http.ResponseWriter, r *http.Request) {
+	var req CancelBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httputil.WriteError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+	if req.Status != "" && req.Status != JobStatusPending {
+		httputil.WriteError(w, "Only pending jobs can be cancelled", http.StatusBadRequest, nil)
+		return
+	}
+	if !req.All && req.Type == "" && req.Prefix == "" && req.CreatedAfter == "" && req.CreatedBefore == "" {
+		httputil.WriteError(w, "Give type, prefix, created_after or created_before, or all to cancel every pending job", http.StatusBadRequest, nil)
+		return
+	}
+
+	filter := JobFilter{Type: req.Type, Status: JobStatusPending, Prefix: req.Prefix}
+	var err error
+	if filter.CreatedAfter, err = parseFilterTime(req.CreatedAfter); err != nil {
+		httputil.WriteError(w, "Invalid created_after", http.StatusBadRequest, err)
+		return
+	}
+	if filter.CreatedBefore, err = parseFilterTime(req.CreatedBefore); err != nil {
+		httputil.WriteError(w, "Invalid created_before", http.StatusBadRequest, err)
+		return
+	}
+
+	filter.Order = "asc"
+	matched, _ := filter.Apply(visibleJobs(r, h.jobQueue.ListJobsByStatus(JobStatusPending)))
+	response := CancelBatchResponse{
+		Success:   true,
+		Matched:   len(matched),
+		Cancelled: []string{},
+		DryRun:    req.DryRun,
+	}
+	for _, job := range matched {
+		switch {
+		case req.DryRun:
+			response.Cancelled = append(response.Cancelled, job.ID)
+		case h.jobQueue.CancelJob(job.ID):
+			response.Cancelled = append(response.Cancelled, job.ID)
+		default:
+			response.Skipped = append(response.Skipped, job.ID)
+		}
+	}
+
+	if req.DryRun {
+		response.Message = fmt.Sprintf("%d jobs would be cancelled", len(response.Cancelled))
+	} else {
+		response.Message = fmt.Sprintf("%d jobs cancelled", len(response.Cancelled))
+		log.Printf("Cancelled %d pending jobs in a batch (type %q, prefix %q)", len(response.Cancelled), req.Type, req.Prefix)
+	}
+	h.writeJSON(w, http.StatusOK, response)
+}
+
 func (h *JobHandler) UpdateJobPriority(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -213,30 +483,30 @@ func (h *JobHandler) UpdateJobPriority(w http.ResponseWriter, r *http.Request) {
 	jobID := vars["id"]
 
 	if jobID == "" {
-		h.writeError(w, "Job ID is required", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Job ID is required", http.StatusBadRequest, nil)
 		return
 	}
 
 	var req UpdatePriorityRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeError(w, "Invalid request body", http.StatusBadRequest, err)
+		httputil.WriteError(w, "Invalid request body", http.StatusBadRequest, err)
 		return
 	}
 
 	priority := ParsePriority(req.Priority)
 	if priority == PriorityMedium && req.Priority != "" && req.Priority != "medium" {
-		h.writeError(w, "Invalid priority. Use: high, medium, low", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Invalid priority. Use: high, medium, low", http.StatusBadRequest, nil)
 		return
 	}
 
 	job, exists := h.jobQueue.GetJob(jobID)
-	if !exists {
-		h.writeError(w, "Job not found", http.StatusNotFound, nil)
+	if !exists || !visible(r, job) {
+		httputil.WriteCode(w, httputil.CodeJobNotFound, "Job not found", nil)
 		return
 	}
 
 	if job.Status != JobStatusPending {
-		h.writeError(w, "Cannot update priority of job that is not pending", http.StatusBadRequest, nil)
+		httputil.WriteCode(w, httputil.CodeJobNotPending, "Cannot update priority of job that is not pending", nil)
 		return
 	}
 
@@ -252,20 +522,92 @@ func (h *JobHandler) UpdateJobPriority(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, http.StatusOK, response)
 }
 
+// BoostChain raises every pending job of a chain to a priority, so the
+// remaining steps of an urgent pipeline jump the queue together. Jobs the
+// chain's steps trigger later are raised as they are enqueued.
+func (h *JobHandler) BoostChain(w http.ResponseWriter, r *http.Request) {
+	chainID := mux.Vars(r)["id"]
+	if chainID == "" {
+		httputil.WriteError(w, "Chain ID is required", http.StatusBadRequest, nil)
+		return
+	}
+
+	var req UpdatePriorityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httputil.WriteError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+
+	priority := ParsePriority(req.Priority)
+	if priority == PriorityMedium && req.Priority != "" && req.Priority != "medium" {
+		httputil.WriteError(w, "Invalid priority. Use: high, medium, low", http.StatusBadRequest, nil)
+		return
+	}
+
+	found := false
+	for _, job := range visibleJobs(r, h.jobQueue.ListJobs()) {
+		if inChain(job, chainID) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		httputil.WriteCode(w, httputil.CodeJobNotFound, "Chain not found", nil)
+		return
+	}
+
+	boosted := h.jobQueue.BoostChain(chainID, priority)
+	log.Printf("Boosted %d jobs of chain %s to %s priority", len(boosted), chainID, priority)
+
+	h.writeJSON(w, http.StatusOK, ChainPriorityResponse{
+		Success:  true,
+		Message:  fmt.Sprintf("%d jobs boosted", len(boosted)),
+		ChainID:  chainID,
+		Priority: priority.String(),
+		Boosted:  boosted,
+	})
+}
+
 func (h *JobHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	queueStats := h.jobQueue.GetStats()
-	workerStats := h.workerPool.GetStats()
-
 	response := JobStatsResponse{
 		Success: true,
 		Message: "Stats retrieved successfully",
-		Queue:   queueStats,
-		Workers: workerStats,
+		Queue:   h.jobQueue.GetStats(),
+	}
+	if h.workerPool != nil {
+		response.Workers = h.workerPool.GetStats()
+	}
+
+	if h.autoscaler != nil {
+		autoscalerStats := h.autoscaler.GetStats()
+		response.Autoscaler = &autoscalerStats
+	}
+
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+func (h *JobHandler) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.requireWorkerPool(w) {
+		return
+	}
+
+	response := JobMetricsResponse{
+		Success:     true,
+		Message:     "Metrics retrieved successfully",
+		Metrics:     h.workerPool.GetMetrics(),
+		Queue:       h.jobQueue.GetStats(),
+		TempDir:     h.workerPool.TempDirStats(),
+		ObjectCache: h.storage.ObjectCacheStats(),
 	}
 
 	h.writeJSON(w, http.StatusOK, response)
@@ -273,18 +615,27 @@ func (h *JobHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 
 func (h *JobHandler) UpdateWorkerCount(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.requireWorkerPool(w) {
+		return
+	}
+
+	if h.autoscaler != nil {
+		httputil.WriteError(w, "Worker count is managed by the autoscaler", http.StatusConflict, nil)
 		return
 	}
 
 	var req UpdateWorkersRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeError(w, "Invalid request body", http.StatusBadRequest, err)
+		httputil.WriteError(w, "Invalid request body", http.StatusBadRequest, err)
 		return
 	}
 
 	if req.Count <= 0 || req.Count > 100 {
-		h.writeError(w, "Worker count must be between 1 and 100", http.StatusBadRequest, nil)
+		httputil.WriteError(w, "Worker count must be between 1 and 100", http.StatusBadRequest, nil)
 		return
 	}
 
@@ -301,17 +652,66 @@ func (h *JobHandler) UpdateWorkerCount(w http.ResponseWriter, r *http.Request) {
 
 func (h *JobHandler) GetActiveJobs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.requireWorkerPool(w) {
 		return
 	}
 
-	activeJobs := h.workerPool.GetActiveJobs()
+	activeJobs := visibleJobs(r, h.workerPool.GetActiveJobs())
 
 	response := JobsListResponse{
 		Success: true,
 		Message: "Active jobs retrieved successfully",
 		Jobs:    activeJobs,
 		Count:   len(activeJobs),
+		Total:   len(activeJobs),
+	}
+
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// GetWorkerDetails lists each worker's current job, counters and idle time.
+// Workers busy on one job for longer than ?stuck_after (default 30m) are
+// reported as stuck.
+func (h *JobHandler) GetWorkerDetails(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.requireWorkerPool(w) {
+		return
+	}
+
+	stuckAfter := DefaultStuckThreshold
+	if value := r.URL.Query().Get("stuck_after"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			httputil.WriteError(w, "Invalid stuck_after duration", http.StatusBadRequest, err)
+			return
+		}
+		stuckAfter = parsed
+	}
+
+	workers := h.workerPool.GetWorkerDetails(stuckAfter)
+	stuck := 0
+	for _, worker := range workers {
+		if worker.Stuck {
+			stuck++
+		}
+	}
+
+	response := map[string]any{
+		"success":     true,
+		"message":     "Worker details retrieved successfully",
+		"workers":     workers,
+		"count":       len(workers),
+		"stuck_count": stuck,
+		"stuck_after": stuckAfter.String(),
+		"healthy":     stuck == 0,
 	}
 
 	h.writeJSON(w, http.StatusOK, response)
@@ -319,7 +719,7 @@ func (h *JobHandler) GetActiveJobs(w http.ResponseWriter, r *http.Request) {
 
 func (h *JobHandler) CalculateMaxWorkers(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httputil.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -343,15 +743,3 @@ func (h *JobHandler) writeJSON(w http.ResponseWriter, statusCode int, data any)
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(data)
 }
-
-func (h *JobHandler) writeError(w http.ResponseWriter, message string, statusCode int, err error) {
-	response := map[string]any{
-		"success": false,
-		"message": message,
-	}
-	if err != nil {
-		response["error"] = err.Error()
-	}
-
-	h.writeJSON(w, statusCode, response)
-}
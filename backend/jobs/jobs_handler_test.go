@@ -0,0 +1,314 @@
+package jobs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"bronze-backend/auth"
+	"bronze-backend/config"
+	"bronze-backend/storage"
+	"bronze-backend/tenant"
+)
+
+// API-only instances have no worker pool; the worker endpoints answer 503
+// while job endpoints keep working.
+func TestWorkerEndpointsWithoutPool(t *testing.T) {
+	h := NewJobHandler(NewJobQueue(1, 10), nil)
+
+	endpoints := map[string]struct {
+		method  string
+		handler http.HandlerFunc
+	}{
+		"metrics":        {http.MethodGet, h.GetMetrics},
+		"workers":        {http.MethodPut, h.UpdateWorkerCount},
+		"workers/active": {http.MethodGet, h.GetActiveJobs},
+		"workers/detail": {http.MethodGet, h.GetWorkerDetails},
+	}
+	for name, endpoint := range endpoints {
+		rec := httptest.NewRecorder()
+		endpoint.handler(rec, httptest.NewRequest(endpoint.method, "/api/jobs/"+name, strings.NewReader(`{"count": 2}`)))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: status %d, want 503", name, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.GetStats(rec, httptest.NewRequest(http.MethodGet, "/api/jobs/stats", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("stats: status %d, want 200", rec.Code)
+	}
+}
+
+// A tenant can only create jobs on objects in its zone, and sees only its
+// own jobs.
+func TestJobsConfinedToTenant(t *testing.T) {
+	queue := NewJobQueue(1, 10)
+	h := NewJobHandler(queue, nil)
+	other := NewJob("extract", "b/x.zip", "lake", "b/x.zip", PriorityMedium)
+	other.Tenant = "b"
+	queue.Enqueue(other)
+
+	a := &tenant.Tenant{Name: "a", Bucket: "lake", Prefix: "a/"}
+	request := func(method, target, body string) *http.Request {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		return req.WithContext(tenant.WithTenant(req.Context(), a))
+	}
+
+	rec := httptest.NewRecorder()
+	h.CreateJob(rec, request(http.MethodPost, "/api/jobs", `{"type":"extract","file_path":"b/y.zip","bucket":"lake","object_name":"b/y.zip"}`))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("job outside the zone: status %d, want 403", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.CreateJob(rec, request(http.MethodPost, "/api/jobs", `{"type":"extract","file_path":"a/y.zip","bucket":"lake","object_name":"a/y.zip"}`))
+	var created JobResponse
+	json.NewDecoder(rec.Body).Decode(&created)
+	if rec.Code != http.StatusCreated || created.Job.Tenant != "a" {
+		t.Fatalf("job inside the zone: status %d, tenant %q", rec.Code, created.Job.Tenant)
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetJobs(rec, request(http.MethodGet, "/api/jobs", ""))
+	var list JobsListResponse
+	json.NewDecoder(rec.Body).Decode(&list)
+	if list.Total != 1 || list.Jobs[0].ID != created.Job.ID {
+		t.Errorf("listed %d jobs, want only the tenant's own", list.Total)
+	}
+}
+
+// Without a limit, GET /api/jobs returns the newest page rather than the
+// whole history.
+func TestGetJobsDefaultPage(t *testing.T) {
+	queue := NewJobQueue(1, DefaultJobPageSize+10)
+	h := NewJobHandler(queue, nil)
+	for range DefaultJobPageSize + 5 {
+		queue.Enqueue(NewJob("extract", "a.zip", "lake", "a.zip", PriorityMedium))
+	}
+
+	rec := httptest.NewRecorder()
+	h.GetJobs(rec, httptest.NewRequest(http.MethodGet, "/api/jobs", nil))
+	var list JobsListResponse
+	json.NewDecoder(rec.Body).Decode(&list)
+	if list.Count != DefaultJobPageSize || list.Limit != DefaultJobPageSize || list.Total != DefaultJobPageSize+5 {
+		t.Errorf("count %d, limit %d, total %d; want a page of %d of %d jobs",
+			list.Count, list.Limit, list.Total, DefaultJobPageSize, DefaultJobPageSize+5)
+	}
+}
+
+// A job runs in the bucket it names, so callers other than admins may only
+// name the current bucket or an allowed one, as with the X-Bucket header.
+func TestCreateJobChecksBucket(t *testing.T) {
+	minioClient, err := storage.NewMinIOClient(&config.MinIOConfig{Endpoint: "127.0.0.1:1", Bucket: "lake", AllowedBuckets: "shared"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewJobHandler(NewJobQueue(1, 10), nil)
+	h.SetStorage(minioClient)
+
+	tests := []struct {
+		bucket string
+		role   auth.Role
+		want   int
+	}{
+		{"lake", auth.RoleEditor, http.StatusCreated},
+		{"shared", auth.RoleEditor, http.StatusCreated},
+		{"private", auth.RoleEditor, http.StatusForbidden},
+		{"private", auth.RoleAdmin, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(`{"type":"extract","file_path":"a.zip","bucket":"`+tt.bucket+`","object_name":"a.zip","force":true}`))
+		req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "alice", Role: tt.role}))
+		rec := httptest.NewRecorder()
+		h.CreateJob(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("bucket %s as %v: status %d, want %d", tt.bucket, tt.role, rec.Code, tt.want)
+		}
+	}
+}
+
+// Only auto-extract rules may have an extract job delete its archive or
+// write elsewhere; callers' metadata and triggers cannot ask for it.
+func TestCreateJobDropsServerMetadata(t *testing.T) {
+	h := NewJobHandler(NewJobQueue(1, 10), nil)
+
+	rec := httptest.NewRecorder()
+	h.CreateJob(rec, httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(`{
+		"type": "extract", "file_path": "a.zip", "bucket": "lake", "object_name": "a.zip",
+		"metadata": {"cleanup": "delete", "extract_prefix": "elsewhere", "note": "kept"},
+		"triggers": [{"type": "extract", "condition": "on_success", "parameters": {"cleanup": "delete", "note": "kept"}}]
+	}`)))
+	var created JobResponse
+	json.NewDecoder(rec.Body).Decode(&created)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d", rec.Code)
+	}
+
+	job := created.Job
+	if _, ok := job.Metadata[MetadataCleanup]; ok {
+		t.Errorf("metadata kept %s", MetadataCleanup)
+	}
+	if _, ok := job.Metadata[MetadataExtractPrefix]; ok {
+		t.Errorf("metadata kept %s", MetadataExtractPrefix)
+	}
+	if job.Metadata["note"] != "kept" {
+		t.Errorf("metadata = %v, want note kept", job.Metadata)
+	}
+	if params := job.Triggers[0].Parameters; params[MetadataCleanup] != nil || params["note"] != "kept" {
+		t.Errorf("trigger parameters = %v, want only note", params)
+	}
+}
+
+func TestCancelBatch(t *testing.T) {
+	queue := NewJobQueue(1, 10)
+	h := NewJobHandler(queue, nil)
+	enqueue := func(jobType, object string) *Job {
+		job := NewJob(jobType, object, "lake", object, PriorityMedium)
+		queue.Enqueue(job)
+		return job
+	}
+	first := enqueue("extract", "in/a.zip")
+	second := enqueue("extract", "in/b.zip")
+	otherType := enqueue("convert", "in/c.xlsx")
+	otherPrefix := enqueue("extract", "archive/d.zip")
+
+	cancel := func(body string) (int, CancelBatchResponse) {
+		rec := httptest.NewRecorder()
+		h.CancelBatch(rec, httptest.NewRequest(http.MethodPost, "/api/jobs/cancel-batch", strings.NewReader(body)))
+		var response CancelBatchResponse
+		json.NewDecoder(rec.Body).Decode(&response)
+		return rec.Code, response
+	}
+
+	for _, body := range []string{`{}`, `{"status":"processing","type":"extract"}`, `{"prefix":"in/","created_after":"yesterday"}`} {
+		if code, _ := cancel(body); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, code)
+		}
+	}
+
+	code, response := cancel(`{"type":"extract","prefix":"in/","dry_run":true}`)
+	if code != http.StatusOK || response.Matched != 2 || len(response.Cancelled) != 2 {
+		t.Fatalf("dry run: status %d, %+v", code, response)
+	}
+	if job, _ := queue.GetJob(first.ID); job.Status != JobStatusPending {
+		t.Errorf("dry run cancelled %s", first.ID)
+	}
+
+	code, response = cancel(`{"type":"extract","prefix":"in/"}`)
+	if code != http.StatusOK || response.Cancelled[0] != first.ID || response.Cancelled[1] != second.ID {
+		t.Fatalf("status %d, %+v", code, response)
+	}
+	for _, job := range []*Job{first, second} {
+		if job, _ := queue.GetJob(job.ID); job.Status != JobStatusCancelled {
+			t.Errorf("%s is %s, want cancelled", job.ID, job.Status)
+		}
+	}
+	for _, job := range []*Job{otherType, otherPrefix} {
+		if job, _ := queue.GetJob(job.ID); job.Status != JobStatusPending {
+			t.Errorf("%s is %s, want pending", job.ID, job.Status)
+		}
+	}
+
+	if _, response = cancel(`{"all":true}`); response.Matched != 2 {
+		t.Errorf("all matched %d jobs, want the 2 still pending", response.Matched)
+	}
+}
+
+func TestBoostChain(t *testing.T) {
+	queue := NewJobQueue(1, 10)
+	h := NewJobHandler(queue, nil)
+	enqueue := func(chainID string, priority JobPriority) *Job {
+		job := NewJob("export", "in/a.csv", "lake", "in/a.csv", priority)
+		job.ChainID = chainID
+		queue.Enqueue(job)
+		return job
+	}
+	unrelated := enqueue("", PriorityMedium)
+	step := enqueue("pipeline", PriorityLow)
+	high := enqueue("pipeline", PriorityHigh)
+
+	boost := func(chainID, body string) (int, ChainPriorityResponse) {
+		req := httptest.NewRequest(http.MethodPut, "/api/jobs/chains/"+chainID+"/priority", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"id": chainID})
+		rec := httptest.NewRecorder()
+		h.BoostChain(rec, req)
+		var response ChainPriorityResponse
+		json.NewDecoder(rec.Body).Decode(&response)
+		return rec.Code, response
+	}
+
+	if code, _ := boost("missing", `{"priority":"high"}`); code != http.StatusNotFound {
+		t.Errorf("unknown chain: status %d, want 404", code)
+	}
+	if code, _ := boost("pipeline", `{"priority":"urgent"}`); code != http.StatusBadRequest {
+		t.Errorf("invalid priority: status %d, want 400", code)
+	}
+
+	code, response := boost("pipeline", `{"priority":"high"}`)
+	if code != http.StatusOK || len(response.Boosted) != 1 || response.Boosted[0] != step.ID {
+		t.Fatalf("status %d, %+v", code, response)
+	}
+	if next := queue.Dequeue(); next.ID != high.ID && next.ID != step.ID {
+		t.Errorf("dequeued %s before the chain", next.ID)
+	}
+	if next := queue.Dequeue(); next.ID != high.ID && next.ID != step.ID {
+		t.Errorf("dequeued %s before the chain", next.ID)
+	}
+
+	// Later steps of the chain are raised as they are enqueued
+	if later := enqueue("pipeline", PriorityLow); later.Priority != PriorityHigh {
+		t.Errorf("later step has %s priority, want high", later.Priority)
+	}
+	if unrelated.Priority != PriorityMedium {
+		t.Errorf("job outside the chain was boosted")
+	}
+}
+
+func TestGetJobResult(t *testing.T) {
+	queue := NewJobQueue(1, 10)
+	h := NewJobHandler(queue, nil)
+	job := NewJob("extract", "in/a.zip", "lake", "in/a.zip", PriorityMedium)
+	queue.Enqueue(job)
+
+	get := func(query string) (int, JobResultResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/api/jobs/"+job.ID+"/result"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": job.ID})
+		rec := httptest.NewRecorder()
+		h.GetJobResult(rec, req)
+		var response JobResultResponse
+		json.NewDecoder(rec.Body).Decode(&response)
+		return rec.Code, response
+	}
+
+	if code, _ := get(""); code != http.StatusConflict {
+		t.Errorf("pending job: status %d, want 409", code)
+	}
+
+	files := []string{"in/a/1.csv", "in/a/2.csv", "in/a/3.csv"}
+	job.Complete(JobResult{Success: true, ExtractedFiles: files, FileInfo: map[string]any{"extracted_files": files, "format": "zip"}})
+	code, response := get("?offset=1&limit=1")
+	if code != http.StatusOK || response.Total != 3 || len(response.Files) != 1 || response.Files[0] != "in/a/2.csv" {
+		t.Fatalf("status %d, %+v", code, response)
+	}
+	if response.Result.ExtractedFiles != nil || response.Result.FileInfo["extracted_files"] != nil || response.Result.FileInfo["format"] != "zip" {
+		t.Errorf("result = %+v, want it without its files", response.Result)
+	}
+	if _, response = get("?offset=5"); len(response.Files) != 0 {
+		t.Errorf("offset past the end returned %q", response.Files)
+	}
+
+	// Without storage the files beyond a truncated result cannot be read
+	result := JobResult{Success: true, ExtractedFiles: files, FileInfo: map[string]any{"extracted_files": files}}
+	if !result.Truncate(2) || result.FileCount != 3 || len(result.ExtractedFiles) != 2 || len(result.FileInfo["extracted_files"].([]string)) != 2 {
+		t.Fatalf("truncated result = %+v", result)
+	}
+	job.Complete(result)
+	if code, _ := get(""); code != http.StatusBadGateway {
+		t.Errorf("truncated result without storage: status %d, want 502", code)
+	}
+}
@@ -0,0 +1,163 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestJobQueueCapacity(t *testing.T) {
+	queue := NewJobQueue(1, 2)
+	newJob := func(priority JobPriority) *Job {
+		return NewJob("extract", "a.zip", "files", "a.zip", priority)
+	}
+
+	low, high := newJob(PriorityLow), newJob(PriorityHigh)
+	ready := queue.Ready()
+	for _, job := range []*Job{low, high} {
+		if err := queue.Enqueue(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-ready:
+	default:
+		t.Error("Ready was not closed by a push")
+	}
+
+	rejected := newJob(PriorityMedium)
+	if err := queue.Enqueue(rejected); err != ErrQueueFull {
+		t.Fatalf("enqueue past capacity: %v, want ErrQueueFull", err)
+	}
+	if _, exists := queue.GetJob(rejected.ID); exists || queue.Size() != 2 {
+		t.Errorf("a rejected job was queued")
+	}
+	if err := queue.Ping(context.Background()); err != ErrQueueFull {
+		t.Errorf("Ping on a full queue: %v", err)
+	}
+
+	// Cancelling a pending job frees its place
+	if !queue.CancelJob(low.ID) || queue.Size() != 1 {
+		t.Fatalf("size after cancel = %d, want 1", queue.Size())
+	}
+	if err := queue.Enqueue(rejected); err != nil {
+		t.Fatalf("enqueue after cancel: %v", err)
+	}
+
+	// Interrupted jobs are requeued even when the queue is full
+	interrupted := newJob(PriorityLow)
+	queue.Requeue(interrupted)
+	if queue.Size() != 3 {
+		t.Errorf("size after requeue = %d, want 3", queue.Size())
+	}
+
+	var order []*Job
+	for job := queue.Dequeue(); job != nil; job = queue.Dequeue() {
+		order = append(order, job)
+	}
+	if len(order) != 3 || order[0] != high || order[1] != rejected || order[2] != interrupted {
+		t.Errorf("dequeued %d jobs, want high, medium and the requeued low job, without the cancelled one", len(order))
+	}
+	if err := queue.Ping(context.Background()); err != nil {
+		t.Errorf("Ping on an empty queue: %v", err)
+	}
+}
+
+func TestJobQueueKeepsHistory(t *testing.T) {
+	queue := NewJobQueue(1, 10)
+	queue.SetHistoryLimit(1)
+	first := NewJob("extract", "a.zip", "files", "a.zip", PriorityMedium)
+	second := NewJob("extract", "b.zip", "files", "b.zip", PriorityMedium)
+	queue.Enqueue(first)
+	queue.Enqueue(second)
+
+	job := queue.Dequeue()
+	if got, ok := queue.GetJob(job.ID); !ok || got != job {
+		t.Fatal("a dequeued job is no longer in the registry")
+	}
+	if !queue.UpdateJobStatus(job.ID, JobStatusProcessing) || queue.GetStats().Processing != 1 {
+		t.Error("status of a running job was not updated")
+	}
+	if queue.CancelJob(job.ID) {
+		t.Error("a running job was cancelled")
+	}
+
+	// An interrupted job goes back on the heap
+	job.Interrupt()
+	queue.Requeue(job)
+	if queue.Size() != 2 {
+		t.Fatalf("size after requeue = %d, want 2", queue.Size())
+	}
+
+	for _, id := range []string{first.ID, second.ID} {
+		queue.Dequeue()
+		queue.UpdateJobStatus(id, JobStatusCompleted)
+	}
+	if _, ok := queue.GetJob(first.ID); ok {
+		t.Error("the oldest finished job was kept past the history limit")
+	}
+	if got, ok := queue.GetJob(second.ID); !ok || got.Status != JobStatusCompleted {
+		t.Error("the latest finished job was dropped")
+	}
+	if len(queue.ListJobs()) != 1 {
+		t.Errorf("listed %d jobs, want 1", len(queue.ListJobs()))
+	}
+}
+
+func TestEnqueueUnique(t *testing.T) {
+	queue := NewJobQueue(1, 0)
+	queue.SetHistoryLimit(1)
+	newJob := func() *Job {
+		job := NewJob("extract", "a.zip", "files", "a.zip", PriorityMedium)
+		job.ETag = "etag-1"
+		return job
+	}
+
+	// Concurrent submissions of one object enqueue one job
+	var wg sync.WaitGroup
+	var enqueued atomic.Int32
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, duplicate, err := EnqueueUnique(queue, newJob(), false); err == nil && !duplicate {
+				enqueued.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := enqueued.Load(); n != 1 {
+		t.Fatalf("%d jobs enqueued, want 1", n)
+	}
+	first := queue.Dequeue()
+
+	forced, duplicate, err := EnqueueUnique(queue, newJob(), true)
+	if err != nil || duplicate || forced.Metadata["forced"] != true {
+		t.Errorf("forced: duplicate %v, err %v, metadata %v", duplicate, err, forced.Metadata)
+	}
+
+	// A failed job does not block a resubmission
+	queue.Dequeue()
+	forced.Status = JobStatusFailed
+	queue.UpdateJobStatus(forced.ID, JobStatusFailed)
+	retried, duplicate, err := EnqueueUnique(queue, newJob(), false)
+	if err != nil || duplicate {
+		t.Fatalf("after failure: duplicate %v, err %v", duplicate, err)
+	}
+	if existing, duplicate, _ := EnqueueUnique(queue, newJob(), false); !duplicate || existing.ID != retried.ID {
+		t.Errorf("resubmission returned %s (duplicate %v), want %s", existing.ID, duplicate, retried.ID)
+	}
+
+	// Nor does one dropped from the history
+	queue.Dequeue()
+	retried.Status = JobStatusCompleted
+	queue.UpdateJobStatus(retried.ID, JobStatusCompleted)
+	queue.UpdateJobStatus(first.ID, JobStatusCompleted)
+	if _, duplicate, _ := EnqueueUnique(queue, newJob(), false); duplicate {
+		t.Error("job dropped from the history still blocks resubmissions")
+	}
+	if len(queue.dedupe) != 1 {
+		t.Errorf("dedupe index holds %d keys, want 1", len(queue.dedupe))
+	}
+}
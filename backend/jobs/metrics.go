@@ -0,0 +1,191 @@
+package jobs
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MetricsWindows are the rolling windows reported by the metrics endpoint.
+var MetricsWindows = []struct {
+	Name     string
+	Duration time.Duration
+}{
+	{"5m", 5 * time.Minute},
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+}
+
+type jobSample struct {
+	jobType    string
+	finishedAt time.Time
+	duration   time.Duration
+	wait       time.Duration
+	success    bool
+}
+
+// MetricsRecorder keeps samples of finished jobs for the longest metrics
+// window and aggregates them on demand.
+type MetricsRecorder struct {
+	mu        sync.Mutex
+	samples   []jobSample
+	retention time.Duration
+}
+
+type TypeMetrics struct {
+	Completed     int     `json:"completed"`
+	Failed        int     `json:"failed"`
+	ThroughputMin float64 `json:"throughput_per_minute"`
+	FailureRate   float64 `json:"failure_rate"`
+	DurationP50Ms float64 `json:"duration_p50_ms"`
+	DurationP95Ms float64 `json:"duration_p95_ms"`
+	WaitP50Ms     float64 `json:"queue_wait_p50_ms"`
+	WaitP95Ms     float64 `json:"queue_wait_p95_ms"`
+}
+
+type WindowMetrics struct {
+	Overall TypeMetrics            `json:"overall"`
+	ByType  map[string]TypeMetrics `json:"by_type"`
+}
+
+type JobMetrics struct {
+	GeneratedAt time.Time                `json:"generated_at"`
+	Windows     map[string]WindowMetrics `json:"windows"`
+}
+
+func NewMetricsRecorder() *MetricsRecorder {
+	retention := time.Duration(0)
+	for _, window := range MetricsWindows {
+		if window.Duration > retention {
+			retention = window.Duration
+		}
+	}
+
+	return &MetricsRecorder{retention: retention}
+}
+
+// Record adds a sample for a job that has just completed or failed.
+func (m *MetricsRecorder) Record(job *Job) {
+	if job.StartedAt == nil {
+		return
+	}
+
+	sample := jobSample{
+		jobType:    job.Type,
+		finishedAt: time.Now(),
+		duration:   job.GetDuration(),
+		wait:       job.StartedAt.Sub(job.CreatedAt),
+		success:    job.Status == JobStatusCompleted,
+	}
+	if job.CompletedAt != nil {
+		sample.finishedAt = *job.CompletedAt
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.samples = append(m.samples, sample)
+	m.prune(sample.finishedAt)
+}
+
+// prune drops samples older than the longest window. Samples are appended in
+// completion order, so the expired ones are at the front.
+func (m *MetricsRecorder) prune(now time.Time) {
+	cutoff := now.Add(-m.retention)
+	i := 0
+	for i < len(m.samples) && m.samples[i].finishedAt.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		m.samples = append(m.samples[:0], m.samples[i:]...)
+	}
+}
+
+func (m *MetricsRecorder) Snapshot() JobMetrics {
+	now := time.Now()
+
+	m.mu.Lock()
+	m.prune(now)
+	samples := make([]jobSample, len(m.samples))
+	copy(samples, m.samples)
+	m.mu.Unlock()
+
+	metrics := JobMetrics{
+		GeneratedAt: now,
+		Windows:     make(map[string]WindowMetrics, len(MetricsWindows)),
+	}
+
+	for _, window := range MetricsWindows {
+		cutoff := now.Add(-window.Duration)
+
+		var all []jobSample
+		byType := make(map[string][]jobSample)
+		for _, sample := range samples {
+			if sample.finishedAt.Before(cutoff) {
+				continue
+			}
+			all = append(all, sample)
+			byType[sample.jobType] = append(byType[sample.jobType], sample)
+		}
+
+		windowMetrics := WindowMetrics{
+			Overall: aggregateSamples(all, window.Duration),
+			ByType:  make(map[string]TypeMetrics, len(byType)),
+		}
+		for jobType, typeSamples := range byType {
+			windowMetrics.ByType[jobType] = aggregateSamples(typeSamples, window.Duration)
+		}
+
+		metrics.Windows[window.Name] = windowMetrics
+	}
+
+	return metrics
+}
+
+func aggregateSamples(samples []jobSample, window time.Duration) TypeMetrics {
+	var metrics TypeMetrics
+	if len(samples) == 0 {
+		return metrics
+	}
+
+	durations := make([]time.Duration, 0, len(samples))
+	waits := make([]time.Duration, 0, len(samples))
+	for _, sample := range samples {
+		if sample.success {
+			metrics.Completed++
+		} else {
+			metrics.Failed++
+		}
+		durations = append(durations, sample.duration)
+		waits = append(waits, sample.wait)
+	}
+
+	total := metrics.Completed + metrics.Failed
+	metrics.ThroughputMin = float64(total) / window.Minutes()
+	metrics.FailureRate = float64(metrics.Failed) / float64(total)
+	metrics.DurationP50Ms = percentileMs(durations, 0.50)
+	metrics.DurationP95Ms = percentileMs(durations, 0.95)
+	metrics.WaitP50Ms = percentileMs(waits, 0.50)
+	metrics.WaitP95Ms = percentileMs(waits, 0.95)
+
+	return metrics
+}
+
+// percentileMs returns the nearest-rank percentile of values in milliseconds.
+func percentileMs(values []time.Duration, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	rank := int(p*float64(len(values))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(values) {
+		rank = len(values) - 1
+	}
+
+	return float64(values[rank]) / float64(time.Millisecond)
+}
@@ -0,0 +1,77 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"bronze-backend/config"
+)
+
+// Queue is the job queue used by the worker pool and HTTP handlers. JobQueue
+// keeps everything in process memory; RedisQueue shares one queue between
+// several Bronze instances.
+type Queue interface {
+	Enqueue(job *Job) error
+	// EnqueueUnique enqueues job unless a pending, processing or completed
+	// job with the same DedupeKey exists, returning that job instead. The
+	// check and the enqueue are one atomic step, also across instances
+	// sharing the queue
+	EnqueueUnique(job *Job) (*Job, error)
+	Requeue(job *Job)
+	Dequeue() *Job
+	GetJob(id string) (*Job, bool)
+	UpdateJobStatus(id string, status JobStatus) bool
+	UpdateJobProgress(id string, progress float64) bool
+	ListJobs() []*Job
+	ListJobsByStatus(status JobStatus) []*Job
+	PendingJobs() []*Job
+	Size() int
+	CancelJob(id string) bool
+	// BoostChain raises the pending jobs of a chain, and those enqueued in
+	// it for the next chainBoostTTL, to at least priority, returning the
+	// IDs of the jobs raised
+	BoostChain(chainID string, priority JobPriority) []string
+	GetStats() QueueStats
+	// Ping reports whether the queue can take new jobs
+	Ping(ctx context.Context) error
+	Start()
+	Stop()
+}
+
+// chainBoostTTL is how long a chain boost applies to jobs enqueued after it,
+// such as the later steps of a pipeline.
+const chainBoostTTL = 24 * time.Hour
+
+// dedupeTTL is how long a shared queue remembers the job holding a dedupe
+// key, after which the same submission is enqueued again.
+const dedupeTTL = 7 * 24 * time.Hour
+
+// inChain reports whether job belongs to the chain chainID, which is the ID
+// of its first job.
+func inChain(job *Job, chainID string) bool {
+	return job.ChainID == chainID || job.ID == chainID
+}
+
+// NewQueue creates the queue backend selected in the processing config.
+func NewQueue(cfg config.ProcessingConfig) (Queue, error) {
+	switch cfg.Queue.Backend {
+	case "", config.QueueBackendMemory:
+		queue := NewJobQueue(cfg.MaxWorkers, cfg.QueueSize)
+		if cfg.JobHistory > 0 {
+			queue.SetHistoryLimit(cfg.JobHistory)
+		}
+		return queue, nil
+	case config.QueueBackendRedis:
+		queue, err := NewRedisQueue(cfg.Queue, cfg.QueueSize)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.JobHistory > 0 {
+			queue.SetHistoryLimit(cfg.JobHistory)
+		}
+		return queue, nil
+	default:
+		return nil, fmt.Errorf("unknown queue backend: %s", cfg.Queue.Backend)
+	}
+}
@@ -0,0 +1,167 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"bronze-backend/config"
+)
+
+// ErrQuotaExceeded is wrapped by the error of a job that went over its temp
+// disk or memory budget.
+var ErrQuotaExceeded = errors.New("job quota exceeded")
+
+// Job metadata lowering the budgets of one job, as byte sizes such as 200MB.
+// A job cannot raise its budgets over the configured ones.
+const (
+	MetadataTempDiskLimit = "temp_disk_limit"
+	MetadataMemoryLimit   = "memory_limit"
+)
+
+// Quota is the temp disk and memory budget of one job run: the bytes it may
+// write to the temp directory, and read into memory to parse. A zero limit
+// is unlimited. A nil Quota allows everything.
+type Quota struct {
+	tempDiskLimit int64
+	memoryLimit   int64
+
+	tempDisk atomic.Int64
+	memory   atomic.Int64
+}
+
+func NewQuota(tempDiskLimit, memoryLimit int64) *Quota {
+	return &Quota{tempDiskLimit: tempDiskLimit, memoryLimit: memoryLimit}
+}
+
+type quotaKey struct{}
+
+// WithQuota returns ctx carrying the budget of the job run in it.
+func WithQuota(ctx context.Context, quota *Quota) context.Context {
+	return context.WithValue(ctx, quotaKey{}, quota)
+}
+
+// QuotaFrom returns the budget of the job run in ctx, or nil.
+func QuotaFrom(ctx context.Context) *Quota {
+	quota, _ := ctx.Value(quotaKey{}).(*Quota)
+	return quota
+}
+
+func use(used *atomic.Int64, limit, n int64, resource string) error {
+	total := used.Add(n)
+	if limit > 0 && total > limit {
+		return fmt.Errorf("%w: over its %s budget of %d bytes", ErrQuotaExceeded, resource, limit)
+	}
+	return nil
+}
+
+// UseTempDisk counts n bytes written to the temp directory.
+func (q *Quota) UseTempDisk(n int64) error {
+	if q == nil {
+		return nil
+	}
+	return use(&q.tempDisk, q.tempDiskLimit, n, "temp disk")
+}
+
+// UseMemory counts n bytes read into memory.
+func (q *Quota) UseMemory(n int64) error {
+	if q == nil {
+		return nil
+	}
+	return use(&q.memory, q.memoryLimit, n, "memory")
+}
+
+// TempDiskUsed and MemoryUsed return the bytes counted so far.
+func (q *Quota) TempDiskUsed() int64 {
+	if q == nil {
+		return 0
+	}
+	return q.tempDisk.Load()
+}
+
+func (q *Quota) MemoryUsed() int64 {
+	if q == nil {
+		return 0
+	}
+	return q.memory.Load()
+}
+
+// TempWriter counts what is written through it against the temp disk
+// budget, failing the write that goes over.
+func (q *Quota) TempWriter(w io.Writer) io.Writer {
+	if q == nil {
+		return w
+	}
+	return &quotaWriter{writer: w, quota: q}
+}
+
+type quotaWriter struct {
+	writer io.Writer
+	quota  *Quota
+}
+
+func (w *quotaWriter) Write(p []byte) (int, error) {
+	if err := w.quota.UseTempDisk(int64(len(p))); err != nil {
+		return 0, err
+	}
+	return w.writer.Write(p)
+}
+
+// ReadAll reads r into memory like io.ReadAll, counting the bytes against
+// the memory budget of the job run in ctx and stopping once it is over.
+func ReadAll(ctx context.Context, r io.Reader) ([]byte, error) {
+	quota := QuotaFrom(ctx)
+	if quota == nil {
+		return io.ReadAll(r)
+	}
+	return io.ReadAll(&quotaReader{reader: r, quota: quota})
+}
+
+type quotaReader struct {
+	reader io.Reader
+	quota  *Quota
+}
+
+func (r *quotaReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if quotaErr := r.quota.UseMemory(int64(n)); quotaErr != nil {
+			return n, quotaErr
+		}
+	}
+	return n, err
+}
+
+// jobQuota returns the budget of a job run: the configured limits, lowered
+// by the job's metadata.
+func jobQuota(job *Job, tempDiskLimit, memoryLimit int64) (*Quota, error) {
+	lower := func(limit int64, key string) (int64, error) {
+		value, _ := job.Metadata[key].(string)
+		if value == "" {
+			return limit, nil
+		}
+		size, err := config.ParseByteSize(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		if size > 0 && (limit == 0 || size < limit) {
+			return size, nil
+		}
+		return limit, nil
+	}
+
+	tempDisk, err := lower(tempDiskLimit, MetadataTempDiskLimit)
+	if err != nil {
+		return nil, err
+	}
+	memory, err := lower(memoryLimit, MetadataMemoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	if tempDisk == 0 && memory == 0 {
+		return nil, nil
+	}
+	return NewQuota(tempDisk, memory), nil
+}
@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestQuota(t *testing.T) {
+	quota := NewQuota(10, 8)
+
+	var buf bytes.Buffer
+	writer := quota.TempWriter(&buf)
+	if _, err := writer.Write([]byte("123456")); err != nil {
+		t.Fatalf("write within budget: %v", err)
+	}
+	if _, err := writer.Write([]byte("123456")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("write over budget: err = %v, want ErrQuotaExceeded", err)
+	}
+	if buf.Len() != 6 {
+		t.Errorf("written = %d bytes, want the write over budget dropped", buf.Len())
+	}
+
+	ctx := WithQuota(context.Background(), quota)
+	if _, err := ReadAll(ctx, strings.NewReader("1234")); err != nil {
+		t.Fatalf("read within budget: %v", err)
+	}
+	if _, err := ReadAll(ctx, strings.NewReader("12345")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("read over budget: err = %v, want ErrQuotaExceeded", err)
+	}
+
+	// Without a quota nothing is counted
+	if data, err := ReadAll(context.Background(), strings.NewReader("123456789")); err != nil || len(data) != 9 {
+		t.Errorf("ReadAll without quota = %q, %v", data, err)
+	}
+	var none *Quota
+	if err := none.UseTempDisk(1 << 40); err != nil {
+		t.Errorf("nil quota: %v", err)
+	}
+}
+
+func TestJobQuota(t *testing.T) {
+	job := NewJob("process", "in/a.zip", "lake", "in/a.zip", PriorityMedium)
+	if quota, err := jobQuota(job, 0, 0); quota != nil || err != nil {
+		t.Errorf("no limits: quota = %+v, err = %v", quota, err)
+	}
+
+	// Metadata lowers the configured limits but never raises them
+	job.Metadata[MetadataTempDiskLimit] = "1KB"
+	job.Metadata[MetadataMemoryLimit] = "1GB"
+	quota, err := jobQuota(job, 1<<20, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quota.tempDiskLimit != 1024 || quota.memoryLimit != 1<<20 {
+		t.Errorf("limits = %d, %d; want 1024, %d", quota.tempDiskLimit, quota.memoryLimit, 1<<20)
+	}
+
+	job.Metadata[MetadataMemoryLimit] = "lots"
+	if _, err := jobQuota(job, 0, 0); err == nil {
+		t.Error("invalid memory_limit accepted")
+	}
+}
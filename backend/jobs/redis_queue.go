@@ -0,0 +1,785 @@
+package jobs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"bronze-backend/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// queuePriorities lists the per-priority streams in the order they are read.
+var queuePriorities = []JobPriority{PriorityHigh, PriorityMedium, PriorityLow}
+
+// RedisQueue is a Queue backed by Redis streams so several Bronze instances
+// can share one queue. Each priority has its own stream read through a
+// consumer group; a message is only acknowledged once its job reaches a
+// terminal status, and messages left unacknowledged for longer than the
+// visibility timeout are claimed by another consumer. Job documents are kept
+// in a hash keyed by job ID; the oldest finished ones are dropped past the
+// history limit, as in JobQueue.
+type RedisQueue struct {
+	client            *redis.Client
+	prefix            string
+	group             string
+	consumer          string
+	visibilityTimeout time.Duration
+	queueSize         int
+	historyLimit      int
+	ctx               context.Context
+	cancel            context.CancelFunc
+	wg                sync.WaitGroup
+
+	mu       sync.RWMutex
+	inflight map[string]*redisDelivery
+}
+
+// redisDelivery tracks a job this instance is currently processing.
+type redisDelivery struct {
+	job       *Job
+	stream    string
+	messageID string
+}
+
+func NewRedisQueue(cfg config.QueueConfig, queueSize int) (*RedisQueue, error) {
+	opts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	pingCtx, pingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer pingCancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = "bronze"
+	}
+
+	consumer := cfg.Consumer
+	if consumer == "" {
+		hostname, _ := os.Hostname()
+		consumer = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	visibilityTimeout := cfg.VisibilityTimeout
+	if visibilityTimeout <= 0 {
+		visibilityTimeout = 5 * time.Minute
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	q := &RedisQueue{
+		client:            client,
+		prefix:            prefix,
+		group:             prefix + "-workers",
+		consumer:          consumer,
+		visibilityTimeout: visibilityTimeout,
+		queueSize:         queueSize,
+		historyLimit:      DefaultJobHistory,
+		ctx:               ctx,
+		cancel:            cancel,
+		inflight:          make(map[string]*redisDelivery),
+	}
+
+	for _, priority := range queuePriorities {
+		err := client.XGroupCreateMkStream(ctx, q.streamKey(priority), q.group, "0").Err()
+		if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+			cancel()
+			client.Close()
+			return nil, fmt.Errorf("failed to create consumer group: %w", err)
+		}
+	}
+
+	return q, nil
+}
+
+// SetHistoryLimit sets how many finished jobs are kept for lookup, across
+// every instance sharing the queue. Older ones are dropped as jobs finish.
+func (q *RedisQueue) SetHistoryLimit(limit int) {
+	q.historyLimit = limit
+}
+
+func (q *RedisQueue) jobsKey() string {
+	return q.prefix + ":jobs"
+}
+
+// secretsKey holds job passwords apart from the job JSON, which never
+// carries them.
+func (q *RedisQueue) secretsKey() string {
+	return q.prefix + ":secrets"
+}
+
+// finishedKey orders the IDs of finished jobs by when they finished.
+func (q *RedisQueue) finishedKey() string {
+	return q.prefix + ":finished"
+}
+
+// boostKey holds the priority a chain was boosted to, expiring after
+// chainBoostTTL.
+func (q *RedisQueue) boostKey(chainID string) string {
+	return q.prefix + ":boost:" + chainID
+}
+
+// dedupeKey holds the ID of the latest job enqueued with a dedupe key,
+// expiring after dedupeTTL.
+func (q *RedisQueue) dedupeKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return q.prefix + ":dedupe:" + hex.EncodeToString(sum[:])
+}
+
+func (q *RedisQueue) streamKey(priority JobPriority) string {
+	switch priority {
+	case PriorityHigh, PriorityMedium, PriorityLow:
+	default:
+		priority = PriorityMedium
+	}
+	return fmt.Sprintf("%s:stream:%s", q.prefix, priority.String())
+}
+
+// storeJobScript stores a new job document and points its dedupe key at it,
+// unless, when asked for a unique job, the key already points at a job that
+// is pending, processing or completed; it then returns that job's ID. It
+// returns "" when the job ID is taken. Running as one script makes the check
+// and the store atomic for every instance sharing the queue. A key pointing
+// at a job that is gone, such as one whose enqueue failed after the store,
+// no longer counts.
+//
+// KEYS: dedupe key, jobs hash. ARGV: job ID, job document, dedupe TTL in
+// milliseconds, "1" for a unique job.
+var storeJobScript = redis.NewScript(`
+if ARGV[4] == "1" then
+	local id = redis.call("GET", KEYS[1])
+	if id and id ~= ARGV[1] then
+		local doc = redis.call("HGET", KEYS[2], id)
+		if doc then
+			local status = cjson.decode(doc)["status"]
+			if status == "pending" or status == "processing" or status == "completed" then
+				return id
+			end
+		end
+	end
+end
+if redis.call("HSETNX", KEYS[2], ARGV[1], ARGV[2]) == 0 then
+	return ""
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[3])
+return ARGV[1]
+`)
+
+// saveJobScript stores a job document and, for a finished job, drops its
+// secret and records it as finished, dropping the oldest finished jobs past
+// the history limit. With the document the job was read with, it stores
+// nothing and returns 0 if the job changed since, so a change of status
+// made by another instance is never overwritten.
+//
+// KEYS: jobs hash, secrets hash, finished sorted set. ARGV: job ID, job
+// document, "1" for a finished job, the time in milliseconds, history
+// limit, and optionally the document read. Scores only grow, so jobs
+// finished within the same millisecond are still pruned in order.
+var saveJobScript = redis.NewScript(`
+if ARGV[6] and redis.call("HGET", KEYS[1], ARGV[1]) ~= ARGV[6] then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+if ARGV[3] ~= "1" then
+	redis.call("ZREM", KEYS[3], ARGV[1])
+	return 1
+end
+redis.call("HDEL", KEYS[2], ARGV[1])
+local score = tonumber(ARGV[4])
+local last = redis.call("ZRANGE", KEYS[3], -1, -1, "WITHSCORES")
+if last[2] and tonumber(last[2]) >= score then
+	score = tonumber(last[2]) + 1
+end
+redis.call("ZADD", KEYS[3], "NX", score, ARGV[1])
+local excess = redis.call("ZCARD", KEYS[3]) - tonumber(ARGV[5])
+if excess > 0 then
+	local oldest = redis.call("ZPOPMIN", KEYS[3], excess)
+	for i = 1, #oldest, 2 do
+		redis.call("HDEL", KEYS[1], oldest[i])
+		redis.call("HDEL", KEYS[2], oldest[i])
+	end
+end
+return 1
+`)
+
+// maxUpdateAttempts bounds how often update rereads a job that keeps
+// changing under it.
+const maxUpdateAttempts = 10
+
+func (q *RedisQueue) Enqueue(job *Job) error {
+	_, err := q.enqueue(job, false)
+	return err
+}
+
+// EnqueueUnique enqueues job unless the job last enqueued with its dedupe
+// key, by any instance, is still pending, processing or completed.
+func (q *RedisQueue) EnqueueUnique(job *Job) (*Job, error) {
+	return q.enqueue(job, true)
+}
+
+// enqueue stores and publishes job, returning instead the job holding its
+// dedupe key when unique is set and there is one.
+func (q *RedisQueue) enqueue(job *Job, unique bool) (*Job, error) {
+	if q.queueSize > 0 && q.Size() >= q.queueSize {
+		return nil, ErrQueueFull
+	}
+
+	if job.ChainID != "" {
+		if boost, err := q.client.Get(q.ctx, q.boostKey(job.ChainID)).Int(); err == nil && job.Priority < JobPriority(boost) {
+			job.Priority = JobPriority(boost)
+		}
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job: %w", err)
+	}
+
+	if key := job.DedupeKey(); key != "" {
+		onlyUnique := "0"
+		if unique {
+			onlyUnique = "1"
+		}
+		holder, err := storeJobScript.Run(q.ctx, q.client, []string{q.dedupeKey(key), q.jobsKey()},
+			job.ID, data, dedupeTTL.Milliseconds(), onlyUnique).Text()
+		if err != nil {
+			return nil, fmt.Errorf("failed to store job: %w", err)
+		}
+		if holder == "" {
+			return nil, ErrJobAlreadyExists
+		}
+		if holder != job.ID {
+			if existing, ok := q.GetJob(holder); ok {
+				return existing, nil
+			}
+			return nil, fmt.Errorf("failed to load job %s holding the dedupe key of job %s", holder, job.ID)
+		}
+	} else {
+		created, err := q.client.HSetNX(q.ctx, q.jobsKey(), job.ID, data).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to store job: %w", err)
+		}
+		if !created {
+			return nil, ErrJobAlreadyExists
+		}
+	}
+
+	if job.Password != "" {
+		if err := q.client.HSet(q.ctx, q.secretsKey(), job.ID, job.Password).Err(); err != nil {
+			q.client.HDel(q.ctx, q.jobsKey(), job.ID)
+			return nil, fmt.Errorf("failed to store job secret: %w", err)
+		}
+	}
+
+	if err := q.publish(job); err != nil {
+		q.client.HDel(q.ctx, q.jobsKey(), job.ID)
+		q.client.HDel(q.ctx, q.secretsKey(), job.ID)
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (q *RedisQueue) publish(job *Job) error {
+	err := q.client.XAdd(q.ctx, &redis.XAddArgs{
+		Stream: q.streamKey(job.Priority),
+		Values: map[string]any{"job_id": job.ID},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to publish job: %w", err)
+	}
+	return nil
+}
+
+// Requeue stores the job and publishes it again, acknowledging the delivery
+// it was being processed under, if any.
+func (q *RedisQueue) Requeue(job *Job) {
+	q.mu.Lock()
+	delivery := q.inflight[job.ID]
+	delete(q.inflight, job.ID)
+	q.mu.Unlock()
+
+	if err := q.save(job); err != nil {
+		log.Printf("Failed to requeue job %s: %v", job.ID, err)
+		return
+	}
+
+	if delivery != nil {
+		q.ack(delivery)
+	}
+
+	if err := q.publish(job); err != nil {
+		log.Printf("Failed to requeue job %s: %v", job.ID, err)
+	}
+}
+
+func (q *RedisQueue) Dequeue() *Job {
+	for _, priority := range queuePriorities {
+		stream := q.streamKey(priority)
+
+		messages, _, err := q.client.XAutoClaim(q.ctx, &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    q.group,
+			Consumer: q.consumer,
+			MinIdle:  q.visibilityTimeout,
+			Start:    "0-0",
+			Count:    1,
+		}).Result()
+		if err != nil && err != redis.Nil {
+			if q.ctx.Err() == nil {
+				log.Printf("Failed to claim expired jobs from %s: %v", stream, err)
+			}
+			continue
+		}
+
+		if len(messages) == 0 {
+			streams, err := q.client.XReadGroup(q.ctx, &redis.XReadGroupArgs{
+				Group:    q.group,
+				Consumer: q.consumer,
+				Streams:  []string{stream, ">"},
+				Count:    1,
+				Block:    -1,
+			}).Result()
+			if err != nil {
+				if err != redis.Nil && q.ctx.Err() == nil {
+					log.Printf("Failed to read jobs from %s: %v", stream, err)
+				}
+				continue
+			}
+			for _, s := range streams {
+				messages = append(messages, s.Messages...)
+			}
+		}
+
+		for _, message := range messages {
+			if job := q.deliver(stream, message); job != nil {
+				return job
+			}
+		}
+	}
+
+	return nil
+}
+
+// deliver resolves a stream message to its job and records it as in flight.
+// Messages for jobs that no longer need processing are acknowledged and
+// dropped.
+func (q *RedisQueue) deliver(stream string, message redis.XMessage) *Job {
+	delivery := &redisDelivery{stream: stream, messageID: message.ID}
+
+	// Marking the job processing claims it, so it can no longer be
+	// cancelled
+	jobID, _ := message.Values["job_id"].(string)
+	job, claimed, err := q.update(jobID, func(job *Job) bool {
+		// The job finished, or its priority was raised and it was published
+		// again on the stream of its new priority
+		if isTerminalStatus(job.Status) || stream != q.streamKey(job.Priority) {
+			return false
+		}
+		if job.Status == JobStatusProcessing {
+			// The consumer that held this job stopped acknowledging it
+			// within the visibility timeout, so run it again from the start.
+			job.Interrupt()
+			log.Printf("Reclaimed job %s after visibility timeout", job.ID)
+		}
+		job.Start()
+		return true
+	})
+	if err != nil {
+		// Left unacknowledged, the message is claimed again later
+		log.Printf("Failed to claim job %s: %v", jobID, err)
+		return nil
+	}
+	if !claimed {
+		q.ack(delivery)
+		return nil
+	}
+
+	delivery.job = job
+
+	q.mu.Lock()
+	q.inflight[job.ID] = delivery
+	q.mu.Unlock()
+
+	return job
+}
+
+func (q *RedisQueue) ack(delivery *redisDelivery) {
+	pipe := q.client.TxPipeline()
+	pipe.XAck(q.ctx, delivery.stream, q.group, delivery.messageID)
+	pipe.XDel(q.ctx, delivery.stream, delivery.messageID)
+	if _, err := pipe.Exec(q.ctx); err != nil {
+		log.Printf("Failed to acknowledge message %s: %v", delivery.messageID, err)
+	}
+}
+
+func (q *RedisQueue) load(id string) (*Job, bool) {
+	data, err := q.client.HGet(q.ctx, q.jobsKey(), id).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("Failed to load job %s: %v", id, err)
+		}
+		return nil, false
+	}
+
+	job, err := q.decode(id, data)
+	if err != nil {
+		log.Printf("Failed to decode job %s: %v", id, err)
+		return nil, false
+	}
+	return job, true
+}
+
+// decode decodes the stored document of job id, adding its secret.
+func (q *RedisQueue) decode(id string, data []byte) (*Job, error) {
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+	if job.Metadata == nil {
+		job.Metadata = make(map[string]any)
+	}
+	if !isTerminalStatus(job.Status) {
+		if password, err := q.client.HGet(q.ctx, q.secretsKey(), id).Result(); err == nil {
+			job.Password = password
+		}
+	}
+	return &job, nil
+}
+
+func (q *RedisQueue) save(job *Job) error {
+	_, err := q.store(job, nil)
+	return err
+}
+
+// store stores job, unless read is set and the stored document is no longer
+// read; it reports whether it stored the job.
+func (q *RedisQueue) store(job *Job, read []byte) (bool, error) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode job: %w", err)
+	}
+	finished := "0"
+	if isTerminalStatus(job.Status) {
+		finished = "1"
+	}
+	args := []any{job.ID, data, finished, time.Now().UnixMilli(), q.historyLimit}
+	if read != nil {
+		args = append(args, read)
+	}
+	stored, err := saveJobScript.Run(q.ctx, q.client, []string{q.jobsKey(), q.secretsKey(), q.finishedKey()}, args...).Bool()
+	if err != nil {
+		return false, fmt.Errorf("failed to store job: %w", err)
+	}
+	return stored, nil
+}
+
+// update applies change to the stored copy of job id and stores it, as one
+// step against writes from other instances: if the job changed meanwhile,
+// change is applied to the new copy instead. change returns false to leave
+// the job as it is. update returns the job and whether it was changed, or
+// nil if there is no such job.
+func (q *RedisQueue) update(id string, change func(job *Job) bool) (*Job, bool, error) {
+	for range maxUpdateAttempts {
+		data, err := q.client.HGet(q.ctx, q.jobsKey(), id).Bytes()
+		if err == redis.Nil {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to load job: %w", err)
+		}
+		job, err := q.decode(id, data)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to decode job: %w", err)
+		}
+		if !change(job) {
+			return job, false, nil
+		}
+		if stored, err := q.store(job, data); err != nil || stored {
+			return job, stored, err
+		}
+	}
+	return nil, false, fmt.Errorf("job %s kept changing", id)
+}
+
+// lookup returns the in-flight copy of a job if this instance holds it,
+// otherwise the stored copy.
+func (q *RedisQueue) lookup(id string) (*Job, *redisDelivery, bool) {
+	q.mu.RLock()
+	delivery := q.inflight[id]
+	q.mu.RUnlock()
+
+	if delivery != nil {
+		return delivery.job, delivery, true
+	}
+
+	job, exists := q.load(id)
+	return job, nil, exists
+}
+
+func (q *RedisQueue) GetJob(id string) (*Job, bool) {
+	job, _, exists := q.lookup(id)
+	return job, exists
+}
+
+func (q *RedisQueue) UpdateJobStatus(id string, status JobStatus) bool {
+	job, delivery, exists := q.lookup(id)
+	if !exists {
+		return false
+	}
+
+	job.Status = status
+	if err := q.save(job); err != nil {
+		log.Printf("Failed to update job %s: %v", id, err)
+		return false
+	}
+
+	if delivery != nil && isTerminalStatus(status) {
+		q.mu.Lock()
+		delete(q.inflight, id)
+		q.mu.Unlock()
+		q.ack(delivery)
+	}
+
+	return true
+}
+
+func (q *RedisQueue) UpdateJobProgress(id string, progress float64) bool {
+	job, _, exists := q.lookup(id)
+	if !exists {
+		return false
+	}
+
+	job.UpdateProgress(progress)
+	if err := q.save(job); err != nil {
+		log.Printf("Failed to update job %s: %v", id, err)
+		return false
+	}
+
+	return true
+}
+
+func (q *RedisQueue) ListJobs() []*Job {
+	values, err := q.client.HVals(q.ctx, q.jobsKey()).Result()
+	if err != nil {
+		log.Printf("Failed to list jobs: %v", err)
+		return []*Job{}
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	jobs := make([]*Job, 0, len(values))
+	for _, value := range values {
+		var job Job
+		if err := json.Unmarshal([]byte(value), &job); err != nil {
+			continue
+		}
+		if delivery, ok := q.inflight[job.ID]; ok {
+			jobs = append(jobs, delivery.job)
+			continue
+		}
+		jobs = append(jobs, &job)
+	}
+
+	return jobs
+}
+
+func (q *RedisQueue) ListJobsByStatus(status JobStatus) []*Job {
+	jobs := make([]*Job, 0)
+	for _, job := range q.ListJobs() {
+		if job.Status == status {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+func (q *RedisQueue) PendingJobs() []*Job {
+	return q.ListJobsByStatus(JobStatusPending)
+}
+
+// Ping checks that Redis answers.
+func (q *RedisQueue) Ping(ctx context.Context) error {
+	return q.client.Ping(ctx).Err()
+}
+
+// Size returns the number of messages not yet delivered to any consumer.
+func (q *RedisQueue) Size() int {
+	size := 0
+	for _, priority := range queuePriorities {
+		stream := q.streamKey(priority)
+
+		length, err := q.client.XLen(q.ctx, stream).Result()
+		if err != nil {
+			continue
+		}
+
+		var delivered int64
+		if pending, err := q.client.XPending(q.ctx, stream, q.group).Result(); err == nil {
+			delivered = pending.Count
+		}
+
+		if length > delivered {
+			size += int(length - delivered)
+		}
+	}
+	return size
+}
+
+// CancelJob cancels a job still pending. A job a worker of any instance
+// has claimed, or that finished, cannot be cancelled.
+func (q *RedisQueue) CancelJob(id string) bool {
+	_, cancelled, err := q.update(id, func(job *Job) bool {
+		if job.Status != JobStatusPending {
+			return false
+		}
+		job.Cancel()
+		return true
+	})
+	if err != nil {
+		log.Printf("Failed to cancel job %s: %v", id, err)
+	}
+	return cancelled
+}
+
+func (q *RedisQueue) BoostChain(chainID string, priority JobPriority) []string {
+	key := q.boostKey(chainID)
+	if boost, err := q.client.Get(q.ctx, key).Int(); err != nil || JobPriority(boost) <= priority {
+		if err := q.client.Set(q.ctx, key, int(priority), chainBoostTTL).Err(); err != nil {
+			log.Printf("Failed to store boost of chain %s: %v", chainID, err)
+		}
+	}
+
+	boosted := []string{}
+	for _, job := range q.PendingJobs() {
+		if !inChain(job, chainID) || job.Priority >= priority {
+			continue
+		}
+
+		q.mu.RLock()
+		_, inflight := q.inflight[job.ID]
+		q.mu.RUnlock()
+		if inflight {
+			continue
+		}
+
+		// The message on the old stream is dropped when it is delivered.
+		// A job claimed meanwhile is left alone
+		raised, ok, err := q.update(job.ID, func(stored *Job) bool {
+			if stored.Status != JobStatusPending || stored.Priority >= priority {
+				return false
+			}
+			stored.Priority = priority
+			return true
+		})
+		if err != nil {
+			log.Printf("Failed to boost job %s: %v", job.ID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := q.publish(raised); err != nil {
+			log.Printf("Failed to boost job %s: %v", job.ID, err)
+			continue
+		}
+		boosted = append(boosted, job.ID)
+	}
+
+	return boosted
+}
+
+func (q *RedisQueue) GetStats() QueueStats {
+	jobs := q.ListJobs()
+
+	stats := QueueStats{Total: len(jobs)}
+	for _, job := range jobs {
+		switch job.Status {
+		case JobStatusPending:
+			stats.Pending++
+		case JobStatusProcessing:
+			stats.Processing++
+		case JobStatusCompleted:
+			stats.Completed++
+		case JobStatusFailed:
+			stats.Failed++
+		case JobStatusCancelled:
+			stats.Cancelled++
+		}
+	}
+
+	return stats
+}
+
+func (q *RedisQueue) Start() {
+	q.wg.Add(1)
+	go q.heartbeat()
+	log.Printf("Redis job queue started (consumer: %s, visibility timeout: %v)", q.consumer, q.visibilityTimeout)
+}
+
+func (q *RedisQueue) Stop() {
+	q.cancel()
+	q.wg.Wait()
+	q.client.Close()
+}
+
+// heartbeat re-claims messages for jobs still being processed here so long
+// running jobs are not handed to another consumer.
+func (q *RedisQueue) heartbeat() {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.visibilityTimeout / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-ticker.C:
+			q.mu.RLock()
+			byStream := make(map[string][]string)
+			for _, delivery := range q.inflight {
+				byStream[delivery.stream] = append(byStream[delivery.stream], delivery.messageID)
+			}
+			q.mu.RUnlock()
+
+			for stream, ids := range byStream {
+				err := q.client.XClaimJustID(q.ctx, &redis.XClaimArgs{
+					Stream:   stream,
+					Group:    q.group,
+					Consumer: q.consumer,
+					Messages: ids,
+				}).Err()
+				if err != nil && q.ctx.Err() == nil {
+					log.Printf("Failed to extend visibility for %d jobs on %s: %v", len(ids), stream, err)
+				}
+			}
+		}
+	}
+}
+
+func isTerminalStatus(status JobStatus) bool {
+	switch status {
+	case JobStatusCompleted, JobStatusFailed, JobStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
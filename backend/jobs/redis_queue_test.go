@@ -0,0 +1,199 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+
+	"bronze-backend/config"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedisQueue(t *testing.T) (*RedisQueue, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	queue, err := NewRedisQueue(config.QueueConfig{RedisURL: "redis://" + server.Addr(), Consumer: "test"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(queue.Stop)
+	return queue, server
+}
+
+func TestRedisQueueLifecycle(t *testing.T) {
+	queue, _ := newTestRedisQueue(t)
+
+	low := NewJob("extract", "a.zip", "lake", "a.zip", PriorityLow)
+	high := NewJob("extract", "b.zip", "lake", "b.zip", PriorityHigh)
+	high.Password = "secret"
+	for _, job := range []*Job{low, high} {
+		if err := queue.Enqueue(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := queue.Enqueue(low); err != ErrJobAlreadyExists {
+		t.Errorf("enqueue twice: %v, want ErrJobAlreadyExists", err)
+	}
+	if queue.Size() != 2 {
+		t.Errorf("Size() = %d, want 2", queue.Size())
+	}
+
+	// Claiming a job marks it processing for every instance
+	claimed := queue.Dequeue()
+	if claimed == nil || claimed.ID != high.ID || claimed.Password != "secret" {
+		t.Fatalf("claimed %+v, want the high priority job with its password", claimed)
+	}
+	if stored, _ := queue.load(high.ID); stored.Status != JobStatusProcessing {
+		t.Errorf("claimed job stored as %s, want processing", stored.Status)
+	}
+	if queue.CancelJob(high.ID) {
+		t.Error("cancelled a claimed job")
+	}
+
+	// Acknowledged once finished, the job is not delivered again
+	claimed.Complete(JobResult{Success: true})
+	if !queue.UpdateJobStatus(high.ID, JobStatusCompleted) {
+		t.Fatal("UpdateJobStatus failed")
+	}
+	if len(queue.inflight) != 0 {
+		t.Errorf("%d jobs in flight after completion", len(queue.inflight))
+	}
+	if job, _ := queue.GetJob(high.ID); job.Status != JobStatusCompleted || job.Password != "" {
+		t.Errorf("completed job = %+v, want completed without its password", job)
+	}
+	if queue.CancelJob(high.ID) {
+		t.Error("cancelled a completed job")
+	}
+	if job, _ := queue.GetJob(high.ID); job.Status != JobStatusCompleted {
+		t.Errorf("status after cancel %s, want completed kept", job.Status)
+	}
+
+	// A pending job can be cancelled, and is then not delivered
+	if !queue.CancelJob(low.ID) {
+		t.Fatal("could not cancel a pending job")
+	}
+	if job, _ := queue.GetJob(low.ID); job.Status != JobStatusCancelled {
+		t.Errorf("status %s, want cancelled", job.Status)
+	}
+	if job := queue.Dequeue(); job != nil {
+		t.Errorf("delivered %s after cancellation", job.ID)
+	}
+	if queue.Size() != 0 {
+		t.Errorf("Size() = %d, want 0", queue.Size())
+	}
+}
+
+// Claiming checks and changes a job in one step: a job cancelled by another
+// instance after a worker read it is not claimed.
+func TestRedisQueueClaimAfterCancel(t *testing.T) {
+	queue, _ := newTestRedisQueue(t)
+	job := NewJob("extract", "a.zip", "lake", "a.zip", PriorityMedium)
+	if err := queue.Enqueue(job); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	_, claimed, err := queue.update(job.ID, func(stored *Job) bool {
+		if calls++; calls == 1 && !queue.CancelJob(job.ID) {
+			t.Fatal("could not cancel the pending job")
+		}
+		if stored.Status != JobStatusPending {
+			return false
+		}
+		stored.Start()
+		return true
+	})
+	if err != nil || claimed || calls != 2 {
+		t.Fatalf("claimed %v after %d reads, %v; want the cancellation seen on the second", claimed, calls, err)
+	}
+	if stored, _ := queue.load(job.ID); stored.Status != JobStatusCancelled {
+		t.Errorf("status %s, want cancelled", stored.Status)
+	}
+	if claimed := queue.Dequeue(); claimed != nil {
+		t.Errorf("claimed %s (%s), want nothing", claimed.ID, claimed.Status)
+	}
+}
+
+func TestRedisQueueKeepsHistory(t *testing.T) {
+	queue, server := newTestRedisQueue(t)
+	queue.SetHistoryLimit(2)
+
+	var ids []string
+	for range 4 {
+		job := NewJob("extract", "a.zip", "lake", "a.zip", PriorityMedium)
+		if err := queue.Enqueue(job); err != nil {
+			t.Fatal(err)
+		}
+		claimed := queue.Dequeue()
+		claimed.Fail(errors.New("broken"))
+		queue.UpdateJobStatus(claimed.ID, JobStatusFailed)
+		ids = append(ids, claimed.ID)
+	}
+	pending := NewJob("extract", "b.zip", "lake", "b.zip", PriorityMedium)
+	queue.Enqueue(pending)
+
+	for i, id := range ids {
+		if _, exists := queue.GetJob(id); exists != (i >= 2) {
+			t.Errorf("job %d kept %v, want only the 2 latest finished", i, exists)
+		}
+	}
+	if _, exists := queue.GetJob(pending.ID); !exists {
+		t.Error("pruned a pending job")
+	}
+	if n := len(queue.ListJobs()); n != 3 {
+		t.Errorf("%d jobs listed, want 3", n)
+	}
+	if members, _ := server.ZMembers(queue.finishedKey()); len(members) != 2 {
+		t.Errorf("finished set = %v, want 2 IDs", members)
+	}
+}
+
+func TestRedisQueueEnqueueUnique(t *testing.T) {
+	queue, _ := newTestRedisQueue(t)
+	newJob := func() *Job {
+		job := NewJob("extract", "a.zip", "lake", "a.zip", PriorityMedium)
+		job.ETag = "abc"
+		return job
+	}
+
+	first := newJob()
+	if existing, err := queue.EnqueueUnique(first); err != nil || existing != nil {
+		t.Fatalf("first: %v, %v", existing, err)
+	}
+	existing, err := queue.EnqueueUnique(newJob())
+	if err != nil || existing == nil || existing.ID != first.ID {
+		t.Fatalf("duplicate: %v, %v; want %s", existing, err, first.ID)
+	}
+
+	// Once the job is cancelled, the same content is enqueued again
+	queue.CancelJob(first.ID)
+	if existing, err := queue.EnqueueUnique(newJob()); err != nil || existing != nil {
+		t.Errorf("after cancellation: %v, %v; want a new job", existing, err)
+	}
+}
+
+func TestRedisQueueBoostChain(t *testing.T) {
+	queue, _ := newTestRedisQueue(t)
+	first := NewJob("extract", "a.zip", "lake", "a.zip", PriorityLow)
+	second := NewJob("convert", "a.xlsx", "lake", "a.xlsx", PriorityLow)
+	second.ChainID = first.ID
+	for _, job := range []*Job{first, second} {
+		if err := queue.Enqueue(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	boosted := queue.BoostChain(first.ID, PriorityHigh)
+	if len(boosted) != 2 {
+		t.Fatalf("boosted %v, want both jobs", boosted)
+	}
+	// Delivered from the high priority stream, the low one's messages dropped
+	for range 2 {
+		if job := queue.Dequeue(); job == nil || job.Priority != PriorityHigh {
+			t.Fatalf("delivered %+v, want a boosted job", job)
+		}
+	}
+	if job := queue.Dequeue(); job != nil {
+		t.Errorf("delivered %s twice", job.ID)
+	}
+}
@@ -0,0 +1,148 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"bronze-backend/httputil"
+	"bronze-backend/storage"
+)
+
+// InlineFileLimit is the most extracted files a job's result keeps once its
+// full result is stored as an artifact; GET /api/jobs/{id}/result pages
+// through the rest.
+const InlineFileLimit = 100
+
+// Page sizes of GET /api/jobs/{id}/result.
+const (
+	DefaultResultPageSize = 1000
+	MaxResultPageSize     = 10000
+)
+
+// Truncate cuts the extracted files of r to the first limit, recording how
+// many there were in FileCount. It reports whether any were cut.
+func (r *JobResult) Truncate(limit int) bool {
+	if len(r.ExtractedFiles) <= limit {
+		return false
+	}
+
+	r.FileCount = len(r.ExtractedFiles)
+	r.ExtractedFiles = append([]string(nil), r.ExtractedFiles[:limit]...)
+	r.Truncated = true
+	if _, ok := r.FileInfo["extracted_files"]; ok {
+		r.FileInfo["extracted_files"] = r.ExtractedFiles
+	}
+	return true
+}
+
+// JobResultResponse is a page of the files a job extracted, along with the
+// rest of its result.
+type JobResultResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	JobID   string `json:"job_id"`
+	// Result is the job's result without its extracted files
+	Result JobResult `json:"result"`
+	Files  []string  `json:"files"`
+	Total  int       `json:"total"`
+	Offset int       `json:"offset"`
+	Limit  int       `json:"limit"`
+}
+
+// GetJobResult returns a job's full result, reading it from the job's result
+// artifact when it was truncated, with its extracted files paged by the
+// offset and limit query parameters.
+func (h *JobHandler) GetJobResult(w http.ResponseWriter, r *http.Request) {
+	job, exists := h.jobQueue.GetJob(mux.Vars(r)["id"])
+	if !exists || !visible(r, job) {
+		httputil.WriteCode(w, httputil.CodeJobNotFound, "Job not found", nil)
+		return
+	}
+
+	query := r.URL.Query()
+	offset, err := parseFilterInt(query.Get("offset"))
+	if err != nil {
+		httputil.WriteError(w, "Invalid offset", http.StatusBadRequest, err)
+		return
+	}
+	limit, err := parseFilterInt(query.Get("limit"))
+	if err != nil {
+		httputil.WriteError(w, "Invalid limit", http.StatusBadRequest, err)
+		return
+	}
+	if limit == 0 {
+		limit = DefaultResultPageSize
+	}
+	limit = min(limit, MaxResultPageSize)
+
+	if job.Result == nil {
+		httputil.WriteCode(w, httputil.CodeConflict, fmt.Sprintf("Job is %s and has no result yet", job.Status), nil)
+		return
+	}
+
+	result, err := inlineResult(job)
+	if err != nil {
+		httputil.WriteError(w, "Job has no readable result", http.StatusInternalServerError, err)
+		return
+	}
+	files := result.ExtractedFiles
+	if result.Truncated {
+		if files, err = h.resultFiles(r.Context(), job); err != nil {
+			httputil.WriteCode(w, httputil.CodeUpstream, "Failed to read job result", err)
+			return
+		}
+	}
+
+	result.ExtractedFiles = nil
+	delete(result.FileInfo, "extracted_files")
+	response := JobResultResponse{
+		Success: true,
+		Message: "Job result retrieved successfully",
+		JobID:   job.ID,
+		Result:  result,
+		Files:   files[min(offset, len(files)):min(offset+limit, len(files))],
+		Total:   len(files),
+		Offset:  offset,
+		Limit:   limit,
+	}
+
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// inlineResult returns the result job carries. Jobs loaded from Redis carry
+// it as a decoded JSON object.
+func inlineResult(job *Job) (JobResult, error) {
+	var result JobResult
+	data, err := json.Marshal(job.Result)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+// resultFiles reads every file job extracted from its result artifact.
+func (h *JobHandler) resultFiles(ctx context.Context, job *Job) ([]string, error) {
+	objectName, ok := job.Artifacts[ArtifactResult]
+	if !ok || h.storage == nil {
+		return nil, fmt.Errorf("the full result of job %s is not available", job.ID)
+	}
+	if job.Bucket != "" {
+		ctx = storage.WithBucket(ctx, job.Bucket)
+	}
+	reader, err := h.storage.DownloadFile(ctx, objectName)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var full JobResult
+	if err := json.NewDecoder(reader).Decode(&full); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", objectName, err)
+	}
+	return full.ExtractedFiles, nil
+}
@@ -0,0 +1,200 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"bronze-backend/storage"
+)
+
+// Job metadata carrying a job's retry policy, set on the jobs of auto-job
+// rules.
+const (
+	MetadataMaxRetries   = "max_retries"
+	MetadataRetryBackoff = "retry_backoff"
+	MetadataQuarantine   = "quarantine_object"
+	// MetadataAttempt counts the retries that led to a job, 0 for the first
+	MetadataAttempt = "attempt"
+)
+
+// QuarantineTag is the object tag marking an object whose job failed every
+// retry, with the ID of the last job as value. Auto-job rules create no
+// jobs for a tagged object; uploading it again clears the tag.
+const QuarantineTag = "bronze-quarantined"
+
+// DefaultRetryBackoff is the wait before the first retry when a policy
+// names none. Each further retry waits twice as long, up to MaxRetryBackoff.
+const (
+	DefaultRetryBackoff = time.Minute
+	MaxRetryBackoff     = time.Hour
+)
+
+// RetryPolicy says how a failed job is retried.
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+	// Quarantine tags the object with QuarantineTag once retries run out
+	Quarantine bool
+}
+
+// Apply records the policy in the job's metadata.
+func (p RetryPolicy) Apply(job *Job) {
+	if p.MaxRetries > 0 {
+		job.Metadata[MetadataMaxRetries] = p.MaxRetries
+		if p.Backoff > 0 {
+			job.Metadata[MetadataRetryBackoff] = p.Backoff.String()
+		}
+	}
+	if p.Quarantine {
+		job.Metadata[MetadataQuarantine] = true
+	}
+}
+
+// Delay returns how long to wait before the attempt-th retry, counting
+// from 1.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.Backoff
+	if delay <= 0 {
+		delay = DefaultRetryBackoff
+	}
+	for i := 1; i < attempt && delay < MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, MaxRetryBackoff)
+}
+
+// jobRetryPolicy reads the retry policy of a job and its attempt.
+func jobRetryPolicy(job *Job) (RetryPolicy, int) {
+	policy := RetryPolicy{MaxRetries: metadataInt(job, MetadataMaxRetries)}
+	if backoff, ok := job.Metadata[MetadataRetryBackoff].(string); ok {
+		policy.Backoff, _ = time.ParseDuration(backoff)
+	}
+	policy.Quarantine, _ = job.Metadata[MetadataQuarantine].(bool)
+	return policy, metadataInt(job, MetadataAttempt)
+}
+
+// metadataInt reads a number from job metadata, which jobs loaded from
+// Redis carry as float64.
+func metadataInt(job *Job, key string) int {
+	switch value := job.Metadata[key].(type) {
+	case int:
+		return value
+	case float64:
+		return int(value)
+	}
+	return 0
+}
+
+// newRetry returns a new pending job like failed.
+func newRetry(failed *Job) *Job {
+	job := NewJob(failed.Type, failed.FilePath, failed.Bucket, failed.ObjectName, failed.Priority)
+	for key, value := range failed.Metadata {
+		job.Metadata[key] = value
+	}
+	job.Metadata["retry_of"] = failed.ID
+	job.ETag = failed.ETag
+	job.Tenant = failed.Tenant
+	job.Subject = failed.Subject
+	job.ChainID = failed.ChainID
+	job.TraceContext = failed.TraceContext
+	return job
+}
+
+// objectTagger tags the objects of jobs that failed every retry.
+type objectTagger interface {
+	TagFile(ctx context.Context, objectName string, tags map[string]string) error
+}
+
+// SetStorage lets the pool tag the objects of jobs that are quarantined.
+func (wp *WorkerPool) SetStorage(client *storage.MinIOClient) {
+	if client == nil {
+		return
+	}
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	wp.tagger = client
+}
+
+// pendingRetry is a retry waiting out its backoff.
+type pendingRetry struct {
+	job   *Job
+	timer *time.Timer
+}
+
+// retry schedules a failed job's next attempt, reporting false once the job
+// has no retries left.
+func (wp *WorkerPool) retry(job *Job) bool {
+	policy, attempt := jobRetryPolicy(job)
+	if attempt >= policy.MaxRetries {
+		return false
+	}
+
+	next := newRetry(job)
+	next.Metadata[MetadataAttempt] = attempt + 1
+	delay := policy.Delay(attempt + 1)
+
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	wp.retries[next.ID] = pendingRetry{job: next, timer: time.AfterFunc(delay, func() {
+		wp.mu.Lock()
+		_, waiting := wp.retries[next.ID]
+		delete(wp.retries, next.ID)
+		wp.mu.Unlock()
+		if waiting {
+			wp.enqueueRetry(next)
+		}
+	})}
+	log.Printf("Job %s failed, retry %d of %d in %s", job.ID, attempt+1, policy.MaxRetries, delay)
+	return true
+}
+
+func (wp *WorkerPool) enqueueRetry(job *Job) {
+	if err := wp.jobQueue.Enqueue(job); err != nil {
+		log.Printf("Failed to enqueue job %s retrying %v: %v", job.ID, job.Metadata["retry_of"], err)
+	}
+}
+
+// flushRetries enqueues the retries still waiting out their backoff, so a
+// shutdown does not lose them.
+func (wp *WorkerPool) flushRetries() {
+	wp.mu.Lock()
+	var pending []*Job
+	for id, retry := range wp.retries {
+		// A retry whose timer already fired is enqueued by it
+		if retry.timer.Stop() {
+			pending = append(pending, retry.job)
+			delete(wp.retries, id)
+		}
+	}
+	wp.mu.Unlock()
+
+	for _, job := range pending {
+		wp.enqueueRetry(job)
+	}
+}
+
+// quarantineObject tags the object of a job that failed every retry, if its
+// policy asks for it.
+func (wp *WorkerPool) quarantineObject(ctx context.Context, job *Job) {
+	if policy, _ := jobRetryPolicy(job); !policy.Quarantine || job.ObjectName == "" {
+		return
+	}
+	wp.mu.RLock()
+	tagger := wp.tagger
+	wp.mu.RUnlock()
+	if tagger == nil {
+		return
+	}
+
+	ctx, err := wp.confine(ctx, job)
+	if err == nil {
+		err = tagger.TagFile(ctx, job.ObjectName, map[string]string{QuarantineTag: job.ID})
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to quarantine %s after job %s: %v", job.ObjectName, job.ID, err)
+		return
+	}
+	log.Printf("Quarantined %s: job %s failed every retry", job.ObjectName, job.ID)
+}
@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type recordingTagger map[string]map[string]string
+
+func (r recordingTagger) TagFile(_ context.Context, objectName string, tags map[string]string) error {
+	r[objectName] = tags
+	return nil
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{Backoff: 10 * time.Minute}
+	for attempt, want := range map[int]time.Duration{1: 10 * time.Minute, 2: 20 * time.Minute, 3: 40 * time.Minute, 4: time.Hour, 9: time.Hour} {
+		if got := policy.Delay(attempt); got != want {
+			t.Errorf("Delay(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+	if got := (RetryPolicy{}).Delay(1); got != DefaultRetryBackoff {
+		t.Errorf("Delay without backoff = %s, want %s", got, DefaultRetryBackoff)
+	}
+}
+
+func TestRetryThenQuarantine(t *testing.T) {
+	queue := NewJobQueue(1, 10)
+	pool := NewWorkerPool(1, queue, nil)
+	pool.RegisterProcessor("boom", panicProcessor{})
+	tagger := recordingTagger{}
+	pool.tagger = tagger
+	pool.workerStarted(0)
+
+	job := NewJob("boom", "in/a.csv", "lake", "in/a.csv", PriorityMedium)
+	RetryPolicy{MaxRetries: 1, Backoff: time.Hour, Quarantine: true}.Apply(job)
+	queue.Enqueue(job)
+	pool.processJob(0, queue.Dequeue())
+
+	if queue.Size() != 0 || len(pool.retries) != 1 || len(tagger) != 0 {
+		t.Fatalf("after the first failure: queued %d, waiting %d, tagged %v", queue.Size(), len(pool.retries), tagger)
+	}
+
+	// Retries still waiting are enqueued on shutdown
+	pool.flushRetries()
+	retry := queue.Dequeue()
+	if retry == nil || retry.Metadata["retry_of"] != job.ID || metadataInt(retry, MetadataAttempt) != 1 {
+		t.Fatalf("retry = %+v", retry)
+	}
+
+	pool.processJob(0, retry)
+	if len(pool.retries) != 0 || queue.Size() != 0 {
+		t.Errorf("job retried past max_retries")
+	}
+	if tags := tagger["in/a.csv"]; tags[QuarantineTag] != retry.ID {
+		t.Errorf("tags = %v, want %s=%s", tags, QuarantineTag, retry.ID)
+	}
+}
@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SaveState writes every pending job (including ones requeued after being
+// interrupted) to path so they survive a restart.
+func SaveState(path string, jq Queue) (int, error) {
+	pending := jq.PendingJobs()
+	if len(pending) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return 0, fmt.Errorf("failed to remove stale job state: %w", err)
+		}
+		return 0, nil
+	}
+
+	data, err := json.MarshalIndent(pending, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode job state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create job state directory: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return 0, fmt.Errorf("failed to write job state: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, fmt.Errorf("failed to replace job state: %w", err)
+	}
+
+	return len(pending), nil
+}
+
+// RestoreState requeues jobs saved by SaveState and removes the state file.
+func RestoreState(path string, jq Queue) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read job state: %w", err)
+	}
+
+	var saved []*Job
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return 0, fmt.Errorf("failed to decode job state: %w", err)
+	}
+
+	for _, job := range saved {
+		if job.Metadata == nil {
+			job.Metadata = make(map[string]any)
+		}
+		if job.Status == JobStatusProcessing {
+			job.Interrupt()
+		}
+		jq.Requeue(job)
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return len(saved), fmt.Errorf("failed to remove job state: %w", err)
+	}
+
+	return len(saved), nil
+}
@@ -0,0 +1,47 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"bronze-backend/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SetTraceContext records the trace of ctx on the job, so the job's spans
+// continue the trace of the request that created it.
+func (j *Job) SetTraceContext(ctx context.Context) {
+	j.TraceContext = tracing.Inject(ctx)
+}
+
+// startJobSpan starts the span covering one run of the job, as a child of
+// the trace stored on the job. The time the job spent queued is recorded as
+// a "job.queued" span before it.
+func startJobSpan(ctx context.Context, job *Job) (context.Context, trace.Span) {
+	ctx = tracing.Extract(ctx, job.TraceContext)
+	attrs := []attribute.KeyValue{
+		attribute.String("job.id", job.ID),
+		attribute.String("job.type", job.Type),
+		attribute.String("job.bucket", job.Bucket),
+		attribute.String("job.object", job.ObjectName),
+	}
+
+	if job.StartedAt != nil {
+		_, queued := tracing.Tracer().Start(ctx, "job.queued",
+			trace.WithTimestamp(job.CreatedAt), trace.WithAttributes(attrs...))
+		queued.End(trace.WithTimestamp(*job.StartedAt))
+	}
+
+	return tracing.Start(ctx, "job."+job.Type, attrs...)
+}
+
+// endJobSpan ends the job's span with the outcome of the run.
+func endJobSpan(span trace.Span, job *Job, result JobResult) {
+	span.SetAttributes(attribute.String("job.status", string(job.Status)))
+	if !result.Success {
+		tracing.RecordError(span, errors.New(result.Message))
+	}
+	span.End()
+}
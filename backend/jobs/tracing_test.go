@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bronze-backend/config"
+	"bronze-backend/tracing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestJobSpansContinueRequestTrace(t *testing.T) {
+	if _, err := tracing.Setup(context.Background(), config.TracingConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	ctx, request := tracing.Start(context.Background(), "POST /api/jobs")
+	job := NewJob("extract", "/tmp/a.zip", "files", "a.zip", PriorityMedium)
+	job.SetTraceContext(ctx)
+	request.End()
+
+	time.Sleep(time.Millisecond)
+	job.Start()
+	_, span := startJobSpan(context.Background(), job)
+	job.Complete(JobResult{Success: true})
+	endJobSpan(span, job, JobResult{Success: true})
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("recorded %d spans, want 3", len(spans))
+	}
+	traceID := spans[0].SpanContext().TraceID()
+	for _, s := range spans[1:] {
+		if s.SpanContext().TraceID() != traceID {
+			t.Errorf("span %s is in trace %s, want %s", s.Name(), s.SpanContext().TraceID(), traceID)
+		}
+		if s.Parent().SpanID() != spans[0].SpanContext().SpanID() {
+			t.Errorf("span %s is not a child of the request span", s.Name())
+		}
+	}
+	if spans[1].Name() != "job.queued" || spans[2].Name() != "job.extract" {
+		t.Errorf("unexpected spans: %s, %s", spans[1].Name(), spans[2].Name())
+	}
+}
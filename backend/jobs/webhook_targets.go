@@ -0,0 +1,126 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrCallbackTarget is wrapped by the error of a callback URL whose host is
+// a loopback, private or link-local address not in WEBHOOK_ALLOWED_HOSTS.
+var ErrCallbackTarget = errors.New("callback host is not allowed")
+
+// targetPolicy decides which addresses callbacks that users supply, per job
+// or per watch rule, may reach, so they cannot make the server call into
+// its own network or a cloud metadata endpoint. The operator's WEBHOOK_URL
+// is not subject to it.
+type targetPolicy struct {
+	hosts    map[string]bool // Allowed by name, whatever they resolve to
+	prefixes []netip.Prefix  // Allowed internal ranges
+	resolver *net.Resolver
+}
+
+// newTargetPolicy parses allowed, a comma-separated list of host names, IP
+// addresses and CIDR ranges.
+func newTargetPolicy(allowed string) *targetPolicy {
+	p := &targetPolicy{hosts: make(map[string]bool), resolver: net.DefaultResolver}
+	for _, entry := range strings.Split(allowed, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			p.prefixes = append(p.prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			p.prefixes = append(p.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		} else {
+			p.hosts[strings.ToLower(entry)] = true
+		}
+	}
+	return p
+}
+
+// allowedAddr reports whether callbacks may connect to addr.
+func (p *targetPolicy) allowedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return !(addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsUnspecified() || addr.IsMulticast() || addr.IsInterfaceLocalMulticast())
+}
+
+// resolve returns the addresses of host callbacks may connect to, failing
+// if any address it resolves to is not allowed.
+func (p *targetPolicy) resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !p.allowedAddr(addr) {
+			return nil, fmt.Errorf("%w: %s", ErrCallbackTarget, host)
+		}
+		return []netip.Addr{addr}, nil
+	}
+
+	addrs, err := p.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve callback host %s: %w", host, err)
+	}
+	if p.hosts[strings.ToLower(host)] {
+		return addrs, nil
+	}
+	for _, addr := range addrs {
+		if !p.allowedAddr(addr) {
+			return nil, fmt.Errorf("%w: %s resolves to %s", ErrCallbackTarget, host, addr)
+		}
+	}
+	return addrs, nil
+}
+
+// check resolves the host of callbackURL and checks callbacks may reach it.
+func (p *targetPolicy) check(ctx context.Context, callbackURL string) error {
+	parsed, err := url.Parse(callbackURL)
+	if err != nil {
+		return err
+	}
+	_, err = p.resolve(ctx, parsed.Hostname())
+	return err
+}
+
+// client returns an HTTP client that connects only to the addresses the
+// policy allows. It checks the addresses it dials rather than the URL, so a
+// host resolving to another address by the time of delivery is refused too,
+// as is a redirect to a disallowed host. It ignores proxy settings, which
+// would hide the address.
+func (p *targetPolicy) client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := p.resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("no address for callback host %s", host)
+		}
+		for _, addr := range addrs {
+			var conn net.Conn
+			if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port)); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
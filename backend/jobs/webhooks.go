@@ -0,0 +1,254 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"bronze-backend/config"
+
+	"github.com/google/uuid"
+)
+
+// Webhook headers. The signature is "sha256=" followed by the hex HMAC-SHA256
+// of the raw request body, keyed with WEBHOOK_SECRET.
+const (
+	WebhookSignatureHeader = "X-Bronze-Signature"
+	WebhookEventHeader     = "X-Bronze-Event"
+	WebhookDeliveryHeader  = "X-Bronze-Delivery"
+)
+
+// WebhookPayload is POSTed to callback URLs when a job finishes.
+type WebhookPayload struct {
+	Event       string            `json:"event"` // "job.completed" or "job.failed"
+	JobID       string            `json:"job_id"`
+	Type        string            `json:"type"`
+	Status      JobStatus         `json:"status"`
+	Bucket      string            `json:"bucket"`
+	ObjectName  string            `json:"object_name"`
+	ChainID     string            `json:"chain_id,omitempty"`
+	StartedAt   *time.Time        `json:"started_at,omitempty"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+	DurationMs  int64             `json:"duration_ms"`
+	Error       string            `json:"error,omitempty"`
+	Result      any               `json:"result,omitempty"`
+	Artifacts   map[string]string `json:"artifacts,omitempty"`
+}
+
+// ErrUnsignedCallback is returned for a callback URL while WEBHOOK_SECRET,
+// which signs callbacks, is not set.
+var ErrUnsignedCallback = errors.New("callback URLs need WEBHOOK_SECRET to be set")
+
+// WebhookNotifier delivers job completion webhooks in the background,
+// retrying failed deliveries with exponential backoff. Webhooks to WEBHOOK_URL
+// go out as configured; those to URLs users supply are held to
+// WEBHOOK_ALLOWED_HOSTS.
+type WebhookNotifier struct {
+	url            string
+	secret         string
+	maxRetries     int
+	client         *http.Client
+	targets        *targetPolicy
+	callbackClient *http.Client // For the URLs users supply
+	backoff        time.Duration
+	ctx            context.Context
+	cancel         context.CancelFunc
+	wg             sync.WaitGroup
+}
+
+func NewWebhookNotifier(cfg config.WebhookConfig) *WebhookNotifier {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	maxRetries := cfg.MaxRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	targets := newTargetPolicy(cfg.AllowedHosts)
+	return &WebhookNotifier{
+		url:            cfg.URL,
+		secret:         cfg.Secret,
+		maxRetries:     maxRetries,
+		client:         &http.Client{Timeout: timeout},
+		targets:        targets,
+		callbackClient: targets.client(timeout),
+		backoff:        time.Second,
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// ValidateCallback checks a per-job callback URL: callbacks must be signed,
+// so WEBHOOK_SECRET must be set, and the URL's host must not resolve to a
+// loopback, private or link-local address outside WEBHOOK_ALLOWED_HOSTS. A
+// nil notifier takes no callbacks.
+func (n *WebhookNotifier) ValidateCallback(ctx context.Context, callbackURL string) error {
+	if err := ValidateCallbackURL(callbackURL); err != nil {
+		return err
+	}
+	if n == nil || n.secret == "" {
+		return ErrUnsignedCallback
+	}
+	return n.targets.check(ctx, callbackURL)
+}
+
+// ValidateCallbackURL checks that a callback URL is an absolute http(s)
+// URL. Where it may lead is checked when it is called.
+func ValidateCallbackURL(callbackURL string) error {
+	parsed, err := url.Parse(callbackURL)
+	if err != nil {
+		return err
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("callback URL must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// Notify sends the job's completion webhook to its callback URL and to the
+// global webhook, if either is set. It does not block.
+func (n *WebhookNotifier) Notify(job *Job) {
+	targets := make([]string, 0, 2)
+	if job.CallbackURL != "" && n.secret == "" {
+		log.Printf("Not calling back %s for job %s: WEBHOOK_SECRET is not set", job.CallbackURL, job.ID)
+	} else if job.CallbackURL != "" {
+		targets = append(targets, job.CallbackURL)
+	}
+	if n.url != "" && n.url != job.CallbackURL {
+		targets = append(targets, n.url)
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(NewWebhookPayload(job))
+	if err != nil {
+		log.Printf("Failed to encode webhook for job %s: %v", job.ID, err)
+		return
+	}
+
+	event := webhookEvent(job.Status)
+	for _, target := range targets {
+		n.wg.Add(1)
+		go func(target string) {
+			defer n.wg.Done()
+			if err := n.deliver(target, n.secret, event, body); err != nil {
+				log.Printf("Webhook for job %s to %s failed: %v", job.ID, target, err)
+			}
+		}(target)
+	}
+}
+
+// Send delivers an arbitrary webhook body to target in the background, with
+// the same retries as job webhooks. The body is signed with secret, if set.
+func (n *WebhookNotifier) Send(target, secret, event string, body []byte) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		if err := n.deliver(target, secret, event, body); err != nil {
+			log.Printf("Webhook %s to %s failed: %v", event, target, err)
+		}
+	}()
+}
+
+// Stop abandons pending retries and waits for in-flight deliveries.
+func (n *WebhookNotifier) Stop() {
+	n.cancel()
+	n.wg.Wait()
+}
+
+func NewWebhookPayload(job *Job) WebhookPayload {
+	return WebhookPayload{
+		Event:       webhookEvent(job.Status),
+		JobID:       job.ID,
+		Type:        job.Type,
+		Status:      job.Status,
+		Bucket:      job.Bucket,
+		ObjectName:  job.ObjectName,
+		ChainID:     job.ChainID,
+		StartedAt:   job.StartedAt,
+		CompletedAt: job.CompletedAt,
+		DurationMs:  job.GetDuration().Milliseconds(),
+		Error:       job.Error,
+		Result:      job.Result,
+		Artifacts:   job.Artifacts,
+	}
+}
+
+// SignWebhook returns the signature header value for body.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookEvent(status JobStatus) string {
+	return "job." + string(status)
+}
+
+func (n *WebhookNotifier) deliver(target, secret, event string, body []byte) error {
+	// Every attempt carries the same delivery ID so receivers can dedupe
+	deliveryID := uuid.New().String()
+	backoff := n.backoff
+
+	var err error
+	for attempt := 0; attempt <= n.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-n.ctx.Done():
+				return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		if err = n.post(target, secret, event, deliveryID, body); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", n.maxRetries+1, err)
+}
+
+func (n *WebhookNotifier) post(target, secret, event, deliveryID string, body []byte) error {
+	client := n.callbackClient
+	if target == n.url {
+		client = n.client
+	}
+
+	// Not bound to n.ctx: Stop lets a delivery already on the wire finish
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, event)
+	req.Header.Set(WebhookDeliveryHeader, deliveryID)
+	if secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bronze-backend/config"
+)
+
+func TestWebhookNotifierSignsAndRetries(t *testing.T) {
+	var attempts atomic.Int32
+	received := make(chan WebhookPayload, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got := r.Header.Get(WebhookSignatureHeader); got != SignWebhook("secret", body) {
+			t.Errorf("signature = %q, want %q", got, SignWebhook("secret", body))
+		}
+
+		// Fail the first attempt to exercise the retry
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		var payload WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		received <- payload
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(config.WebhookConfig{Secret: "secret", MaxRetries: 2, AllowedHosts: "127.0.0.1"})
+	notifier.backoff = time.Millisecond
+
+	job := NewJob("extract", "/tmp/a.zip", "files", "a.zip", PriorityMedium)
+	job.CallbackURL = server.URL
+	job.Start()
+	job.Fail(errors.New("boom"))
+	notifier.Notify(job)
+
+	select {
+	case payload := <-received:
+		if payload.JobID != job.ID || payload.Event != "job.failed" || payload.Error != "boom" {
+			t.Errorf("unexpected payload: %+v", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	notifier.Stop()
+
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("attempts = %d, want 2", n)
+	}
+}
+
+func TestValidateCallbackURL(t *testing.T) {
+	for _, value := range []string{"https://example.com/hook", "http://localhost:8080/cb"} {
+		if err := ValidateCallbackURL(value); err != nil {
+			t.Errorf("ValidateCallbackURL(%q) = %v", value, err)
+		}
+	}
+	for _, value := range []string{"example.com/hook", "ftp://example.com", "/relative"} {
+		if err := ValidateCallbackURL(value); err == nil {
+			t.Errorf("ValidateCallbackURL(%q) accepted", value)
+		}
+	}
+}
+
+func TestValidateCallback(t *testing.T) {
+	ctx := context.Background()
+	notifier := NewWebhookNotifier(config.WebhookConfig{Secret: "secret", AllowedHosts: "10.1.0.0/16, 192.168.5.5"})
+
+	for _, value := range []string{"https://93.184.215.14/hook", "http://10.1.2.3:8080/cb", "http://192.168.5.5/cb"} {
+		if err := notifier.ValidateCallback(ctx, value); err != nil {
+			t.Errorf("ValidateCallback(%q) = %v", value, err)
+		}
+	}
+	for _, value := range []string{
+		"http://127.0.0.1:8060/api/jobs",
+		"http://[::1]/",
+		"http://10.2.0.1/",
+		"http://192.168.5.6/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://0.0.0.0:9000/",
+		"http://[::ffff:127.0.0.1]/",
+	} {
+		if err := notifier.ValidateCallback(ctx, value); !errors.Is(err, ErrCallbackTarget) {
+			t.Errorf("ValidateCallback(%q) = %v, want ErrCallbackTarget", value, err)
+		}
+	}
+
+	unsigned := NewWebhookNotifier(config.WebhookConfig{})
+	if err := unsigned.ValidateCallback(ctx, "https://93.184.215.14/hook"); !errors.Is(err, ErrUnsignedCallback) {
+		t.Errorf("without WEBHOOK_SECRET: err = %v, want ErrUnsignedCallback", err)
+	}
+	var none *WebhookNotifier
+	if err := none.ValidateCallback(ctx, "https://93.184.215.14/hook"); !errors.Is(err, ErrUnsignedCallback) {
+		t.Errorf("without notifier: err = %v, want ErrUnsignedCallback", err)
+	}
+}
+
+func TestCallbackDeliveryHeldToAllowedHosts(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	// WEBHOOK_URL is the operator's, and may be internal
+	notifier := NewWebhookNotifier(config.WebhookConfig{URL: server.URL, Secret: "secret"})
+	defer notifier.Stop()
+	if err := notifier.deliver(server.URL, "secret", "job.completed", []byte("{}")); err != nil {
+		t.Errorf("delivery to WEBHOOK_URL: %v", err)
+	}
+
+	// The same address supplied by a user is refused when dialled
+	callback := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	if err := notifier.deliver(callback, "secret", "job.completed", []byte("{}")); !errors.Is(err, ErrCallbackTarget) {
+		t.Errorf("delivery to loopback callback: err = %v, want ErrCallbackTarget", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("server called %d times, want once", n)
+	}
+}
+
+func TestNotifySkipsUnsignedCallbacks(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(config.WebhookConfig{AllowedHosts: "127.0.0.1"})
+	job := NewJob("extract", "/tmp/a.zip", "files", "a.zip", PriorityMedium)
+	job.CallbackURL = server.URL
+	job.Start()
+	job.Complete(JobResult{})
+	notifier.Notify(job)
+	notifier.Stop()
+
+	if n := calls.Load(); n != 0 {
+		t.Errorf("unsigned callback sent %d times", n)
+	}
+}
@@ -4,52 +4,246 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"runtime/debug"
+	"sort"
 	"sync"
 	"time"
+
+	"bronze-backend/metering"
+	"bronze-backend/realtime"
+	"bronze-backend/storage"
+	"bronze-backend/tempdir"
+	"bronze-backend/tenant"
 )
 
+// Processor runs jobs of the types it is registered for.
+type Processor interface {
+	ProcessJob(ctx context.Context, job *Job) JobResult
+}
+
 type WorkerPool struct {
 	workers    int
-	jobQueue   *JobQueue
+	jobQueue   Queue
 	processor  interface{}
+	processors map[string]Processor
 	ctx        context.Context
 	cancel     context.CancelFunc
 	wg         sync.WaitGroup
 	activeJobs map[string]*Job
 	mu         sync.RWMutex
+
+	// Durations of the most recently finished jobs, used for autoscaling
+	recentDurations []time.Duration
+	metrics         *MetricsRecorder
+	notifier        *WebhookNotifier
+	events          *realtime.Hub
+	tenants         *tenant.Registry
+	usage           *metering.Tracker
+	deadLetters     *DeadLetterQueue
+	tagger          objectTagger
+	// Per-job budgets in bytes, 0 for none
+	tempDiskLimit int64
+	memoryLimit   int64
+	tempDir       *tempdir.Manager
+	workerStates  map[int]*workerState
+
+	// stops holds the stop channel of each worker counted in workers,
+	// closed to have the worker exit once it finishes its current job
+	stops        map[int]chan struct{}
+	nextWorkerID int
+
+	// Retries of failed jobs waiting out their backoff, by the new job's ID
+	retries map[string]pendingRetry
 }
 
-func NewWorkerPool(workers int, jobQueue *JobQueue, processor interface{}) *WorkerPool {
+// durationSampleSize bounds how many recent job durations are averaged.
+const durationSampleSize = 50
+
+func NewWorkerPool(workers int, jobQueue Queue, processor interface{}) *WorkerPool {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &WorkerPool{
-		workers:    workers,
-		jobQueue:   jobQueue,
-		processor:  processor,
-		ctx:        ctx,
-		cancel:     cancel,
-		activeJobs: make(map[string]*Job),
+		workers:      workers,
+		jobQueue:     jobQueue,
+		processor:    processor,
+		processors:   make(map[string]Processor),
+		ctx:          ctx,
+		cancel:       cancel,
+		activeJobs:   make(map[string]*Job),
+		metrics:      NewMetricsRecorder(),
+		workerStates: make(map[int]*workerState),
+		stops:        make(map[int]chan struct{}),
+		retries:      make(map[string]pendingRetry),
+	}
+}
+
+// RegisterProcessor routes jobs of jobType to processor instead of the
+// pool's default processor.
+func (wp *WorkerPool) RegisterProcessor(jobType string, processor Processor) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	wp.processors[jobType] = processor
+}
+
+// SetNotifier sends a completion webhook for every job the pool finishes.
+func (wp *WorkerPool) SetNotifier(notifier *WebhookNotifier) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	wp.notifier = notifier
+}
+
+// SetEventHub publishes the jobs the pool starts and finishes to the jobs
+// topic of hub, with the webhook payload as data: job.started, then
+// job.completed, job.failed or job.interrupted.
+func (wp *WorkerPool) SetEventHub(hub *realtime.Hub) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	wp.events = hub
+}
+
+// SetTenants confines each tenant's jobs to its zone while they run.
+func (wp *WorkerPool) SetTenants(tenants *tenant.Registry) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	wp.tenants = tenants
+}
+
+// SetUsage accounts the processing time of every job the pool finishes to
+// the caller who created it.
+func (wp *WorkerPool) SetUsage(tracker *metering.Tracker) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	wp.usage = tracker
+}
+
+// SetDeadLetters puts the failed jobs that set MetadataDeadLetter in queue.
+func (wp *WorkerPool) SetDeadLetters(queue *DeadLetterQueue) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	wp.deadLetters = queue
+}
+
+// SetResourceLimits gives every job a budget of tempDisk bytes written to
+// the temp directory and memory bytes read into memory; 0 is unlimited.
+// Jobs going over fail with ErrQuotaExceeded.
+func (wp *WorkerPool) SetResourceLimits(tempDisk, memory int64) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	wp.tempDiskLimit, wp.memoryLimit = tempDisk, memory
+}
+
+func (wp *WorkerPool) quota(job *Job) (*Quota, error) {
+	wp.mu.RLock()
+	tempDisk, memory := wp.tempDiskLimit, wp.memoryLimit
+	wp.mu.RUnlock()
+
+	return jobQuota(job, tempDisk, memory)
+}
+
+// SetTempDir gives every job run its own directory from manager.
+func (wp *WorkerPool) SetTempDir(manager *tempdir.Manager) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	wp.tempDir = manager
+}
+
+// TempDirStats reports the temp disk use of job runs, or nil without a
+// temp directory manager.
+func (wp *WorkerPool) TempDirStats() *tempdir.Stats {
+	wp.mu.RLock()
+	manager := wp.tempDir
+	wp.mu.RUnlock()
+
+	if manager == nil {
+		return nil
+	}
+	stats := manager.Stats()
+	return &stats
+}
+
+func (wp *WorkerPool) acquireTempDir(job *Job) (*tempdir.Dir, error) {
+	wp.mu.RLock()
+	manager := wp.tempDir
+	wp.mu.RUnlock()
+
+	return manager.Acquire(job.ID)
+}
+
+// confine returns ctx working in the job's bucket and confined to its
+// tenant, or an error if the tenant is unknown or the job's object is
+// outside its zone.
+func (wp *WorkerPool) confine(ctx context.Context, job *Job) (context.Context, error) {
+	if job.Bucket != "" {
+		ctx = storage.WithBucket(ctx, job.Bucket)
+	}
+	if job.Tenant == "" {
+		return ctx, nil
+	}
+
+	wp.mu.RLock()
+	tenants := wp.tenants
+	wp.mu.RUnlock()
+
+	t, ok := tenants.Get(job.Tenant)
+	if !ok {
+		return ctx, fmt.Errorf("unknown tenant %s", job.Tenant)
+	}
+	ctx = tenant.WithTenant(ctx, t)
+	return ctx, job.CheckTenant(ctx)
+}
+
+// publish sends the job's current state to the event hub, if one is set.
+func (wp *WorkerPool) publish(eventType string, job *Job) {
+	wp.mu.RLock()
+	events := wp.events
+	wp.mu.RUnlock()
+	if events != nil {
+		events.Publish(realtime.TopicJobs, eventType, NewWebhookPayload(job))
 	}
 }
 
 func (wp *WorkerPool) Start() {
+	wp.mu.Lock()
 	for i := 0; i < wp.workers; i++ {
-		wp.wg.Add(1)
-		go wp.worker(i)
+		wp.spawnWorker()
 	}
+	wp.mu.Unlock()
 	log.Printf("Started %d workers", wp.workers)
 }
 
+// spawnWorker starts a worker under a new ID. The caller holds wp.mu.
+func (wp *WorkerPool) spawnWorker() {
+	id := wp.nextWorkerID
+	wp.nextWorkerID++
+
+	stop := make(chan struct{})
+	wp.stops[id] = stop
+	wp.wg.Add(1)
+	go wp.worker(id, stop)
+}
+
 func (wp *WorkerPool) Stop() {
 	log.Println("Stopping worker pool...")
 	wp.cancel()
 	wp.wg.Wait()
+	wp.flushRetries()
 	log.Println("Worker pool stopped")
 }
 
-func (wp *WorkerPool) worker(id int) {
+func (wp *WorkerPool) worker(id int, stop <-chan struct{}) {
 	defer wp.wg.Done()
 
+	wp.workerStarted(id)
+	defer wp.workerStopped(id)
+
 	log.Printf("Worker %d started", id)
 
 	for {
@@ -57,10 +251,23 @@ func (wp *WorkerPool) worker(id int) {
 		case <-wp.ctx.Done():
 			log.Printf("Worker %d stopping", id)
 			return
+		case <-stop:
+			log.Printf("Worker %d stopped by scale-down", id)
+			return
 		default:
+			// Taken before Dequeue, so a job pushed in between wakes the worker
+			var ready <-chan struct{}
+			if waiter, ok := wp.jobQueue.(interface{ Ready() <-chan struct{} }); ok {
+				ready = waiter.Ready()
+			}
 			job := wp.jobQueue.Dequeue()
 			if job == nil {
-				time.Sleep(100 * time.Millisecond)
+				select {
+				case <-wp.ctx.Done():
+				case <-stop:
+				case <-ready:
+				case <-time.After(100 * time.Millisecond):
+				}
 				continue
 			}
 
@@ -73,31 +280,44 @@ func (wp *WorkerPool) processJob(workerID int, job *Job) {
 	wp.mu.Lock()
 	wp.activeJobs[job.ID] = job
 	wp.mu.Unlock()
+	wp.workerBusy(workerID, job)
 
 	defer func() {
 		wp.mu.Lock()
 		delete(wp.activeJobs, job.ID)
 		wp.mu.Unlock()
+		wp.workerIdle(workerID, job)
 	}()
 
 	log.Printf("Worker %d processing job %s (%s)", workerID, job.ID, job.Type)
 
 	job.Start()
 	wp.jobQueue.UpdateJobStatus(job.ID, JobStatusProcessing)
+	wp.publish("job.started", job)
 
+	ctx, span := startJobSpan(wp.ctx, job)
 	var result JobResult
+	var panicked bool
+	if ctx, err := wp.confine(ctx, job); err != nil {
+		result = JobResult{Success: false, Message: err.Error()}
+	} else if quota, err := wp.quota(job); err != nil {
+		result = JobResult{Success: false, Message: err.Error()}
+	} else if dir, err := wp.acquireTempDir(job); err != nil {
+		result = JobResult{Success: false, Message: err.Error()}
+	} else {
+		result, panicked = wp.runProcessor(tempdir.WithDir(WithQuota(ctx, quota), dir), job)
+		dir.Release()
+	}
 
-	// Route job to appropriate processor based on type
-	switch job.Type {
-	default:
-		if processor, ok := wp.processor.(interface{ ProcessJob(context.Context, *Job) JobResult }); ok {
-			result = processor.ProcessJob(wp.ctx, job)
-		} else {
-			result = JobResult{
-				Success: false,
-				Message: "Invalid job processor",
-			}
-		}
+	if !result.Success && !panicked && wp.ctx.Err() != nil {
+		// The pool is shutting down and cancelled this job's context; put it
+		// back on the queue instead of recording a failure.
+		job.Interrupt()
+		wp.jobQueue.Requeue(job)
+		log.Printf("Worker %d interrupted job %s, requeued for restart", workerID, job.ID)
+		endJobSpan(span, job, result)
+		wp.publish("job.interrupted", job)
+		return
 	}
 
 	if result.Success {
@@ -110,10 +330,120 @@ func (wp *WorkerPool) processJob(workerID int, job *Job) {
 		wp.jobQueue.UpdateJobStatus(job.ID, JobStatusFailed)
 		log.Printf("Worker %d failed job %s: %s", workerID, job.ID, result.Message)
 		wp.executeTriggers(job, TriggerOnFailure)
+		if !wp.retry(job) {
+			wp.deadLetter(ctx, job)
+			wp.quarantineObject(ctx, job)
+		}
+	}
+	endJobSpan(span, job, result)
+
+	wp.recordDuration(job.GetDuration())
+	wp.metrics.Record(job)
+	wp.publish(webhookEvent(job.Status), job)
+
+	wp.mu.RLock()
+	notifier := wp.notifier
+	tracker := wp.usage
+	wp.mu.RUnlock()
+	tracker.Add(job.Subject, metering.MetricJobTime, job.GetDuration().Milliseconds())
+	if notifier != nil {
+		notifier.Notify(job)
+	}
+}
+
+// deadLetter puts a failed job in the dead-letter queue of its bucket and
+// tenant if it asks for it.
+func (wp *WorkerPool) deadLetter(ctx context.Context, job *Job) {
+	if send, _ := job.Metadata[MetadataDeadLetter].(bool); !send {
+		return
+	}
+	wp.mu.RLock()
+	queue := wp.deadLetters
+	wp.mu.RUnlock()
+	if queue == nil {
+		return
+	}
+
+	ctx, err := wp.confine(ctx, job)
+	if err == nil {
+		err = queue.Add(ctx, job)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to dead-letter job %s: %v", job.ID, err)
+		return
+	}
+	log.Printf("Job %s moved to the dead-letter queue", job.ID)
+}
+
+// runProcessor runs the job on its processor. A panic is recovered and
+// turned into a failed result carrying the stack trace, so one bad job cannot
+// take its worker down with it.
+func (wp *WorkerPool) runProcessor(ctx context.Context, job *Job) (result JobResult, panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			log.Printf("Job %s (%s) panicked: %v\n%s", job.ID, job.Type, r, stack)
+			result = JobResult{
+				Success: false,
+				Message: fmt.Sprintf("panic: %v\n%s", r, stack),
+			}
+			panicked = true
+		}
+	}()
+
+	wp.mu.RLock()
+	registered, hasRegistered := wp.processors[job.Type]
+	wp.mu.RUnlock()
+
+	// Route job to appropriate processor based on type
+	switch {
+	case hasRegistered:
+		return registered.ProcessJob(ctx, job), false
+	default:
+		if processor, ok := wp.processor.(interface {
+			ProcessJob(context.Context, *Job) JobResult
+		}); ok {
+			return processor.ProcessJob(ctx, job), false
+		}
+		return JobResult{
+			Success: false,
+			Message: "Invalid job processor",
+		}, false
+	}
+}
+
+func (wp *WorkerPool) recordDuration(d time.Duration) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	wp.recentDurations = append(wp.recentDurations, d)
+	if len(wp.recentDurations) > durationSampleSize {
+		wp.recentDurations = wp.recentDurations[len(wp.recentDurations)-durationSampleSize:]
 	}
 }
 
+// AverageJobDuration returns the mean duration of recently finished jobs, or
+// zero if none have finished yet.
+func (wp *WorkerPool) AverageJobDuration() time.Duration {
+	wp.mu.RLock()
+	defer wp.mu.RUnlock()
+
+	if len(wp.recentDurations) == 0 {
+		return 0
+	}
 
+	var total time.Duration
+	for _, d := range wp.recentDurations {
+		total += d
+	}
+	return total / time.Duration(len(wp.recentDurations))
+}
+
+// GetMetrics returns throughput, duration and wait-time metrics for jobs
+// finished by this pool.
+func (wp *WorkerPool) GetMetrics() JobMetrics {
+	return wp.metrics.Snapshot()
+}
 
 func (wp *WorkerPool) executeTriggers(parentJob *Job, condition TriggerCondition) {
 	for _, trigger := range parentJob.Triggers {
@@ -149,6 +479,9 @@ func (wp *WorkerPool) createTriggeredJob(parentJob *Job, trigger JobTrigger) *Jo
 	// Set chaining relationships
 	nextJob.DependsOn = []string{parentJob.ID}
 	nextJob.ChainID = parentJob.ChainID
+	nextJob.TraceContext = parentJob.TraceContext // Same trace as the parent
+	nextJob.Subject = parentJob.Subject
+	nextJob.Tenant = parentJob.Tenant
 	if nextJob.ChainID == "" {
 		nextJob.ChainID = parentJob.ID // Use parent ID as chain ID if not set
 	}
@@ -179,6 +512,9 @@ func (wp *WorkerPool) GetActiveJobs() []*Job {
 }
 
 func (wp *WorkerPool) GetWorkerCount() int {
+	wp.mu.RLock()
+	defer wp.mu.RUnlock()
+
 	return wp.workers
 }
 
@@ -187,6 +523,9 @@ func (wp *WorkerPool) UpdateWorkerCount(newCount int) {
 		return
 	}
 
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
 	currentCount := wp.workers
 	if newCount == currentCount {
 		return
@@ -196,13 +535,36 @@ func (wp *WorkerPool) UpdateWorkerCount(newCount int) {
 
 	if newCount > currentCount {
 		for i := currentCount; i < newCount; i++ {
-			wp.wg.Add(1)
-			go wp.worker(i)
+			wp.spawnWorker()
 		}
 		log.Printf("Added %d workers (total: %d)", newCount-currentCount, newCount)
 	} else {
-		log.Printf("Worker count reduced to %d (excess workers will stop naturally)", newCount)
+		for _, id := range wp.stopCandidates(currentCount - newCount) {
+			close(wp.stops[id])
+			delete(wp.stops, id)
+		}
+		log.Printf("Stopping %d workers (total: %d); busy ones finish their current job first", currentCount-newCount, newCount)
+	}
+}
+
+// stopCandidates picks n workers to stop, idle ones first and then the most
+// recently started. The caller holds wp.mu.
+func (wp *WorkerPool) stopCandidates(n int) []int {
+	ids := make([]int, 0, len(wp.stops))
+	for id := range wp.stops {
+		ids = append(ids, id)
 	}
+	busy := func(id int) bool {
+		state, ok := wp.workerStates[id]
+		return ok && state.currentJob != nil
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if busy(ids[i]) != busy(ids[j]) {
+			return !busy(ids[i])
+		}
+		return ids[i] > ids[j]
+	})
+	return ids[:min(n, len(ids))]
 }
 
 func (wp *WorkerPool) GetStats() WorkerPoolStats {
@@ -0,0 +1,146 @@
+package jobs
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type panicProcessor struct{}
+
+func (panicProcessor) ProcessJob(ctx context.Context, job *Job) JobResult {
+	panic("processor exploded")
+}
+
+func TestProcessJobRecoversPanic(t *testing.T) {
+	queue := NewJobQueue(1, 10)
+	pool := NewWorkerPool(1, queue, nil)
+	pool.RegisterProcessor("boom", panicProcessor{})
+	pool.workerStarted(0)
+
+	job := NewJob("boom", "/tmp/a", "files", "a", PriorityMedium)
+	if err := queue.Enqueue(job); err != nil {
+		t.Fatal(err)
+	}
+	pool.processJob(0, queue.Dequeue())
+
+	if job.Status != JobStatusFailed {
+		t.Fatalf("status = %s, want failed", job.Status)
+	}
+	if !strings.Contains(job.Error, "processor exploded") || !strings.Contains(job.Error, "goroutine") {
+		t.Errorf("error does not carry the panic and stack trace: %q", job.Error)
+	}
+
+	details := pool.GetWorkerDetails(DefaultStuckThreshold)
+	if len(details) != 1 || details[0].JobsFailed != 1 || details[0].Status != "idle" {
+		t.Errorf("unexpected worker details: %+v", details)
+	}
+}
+
+type blockingProcessor struct{ release chan struct{} }
+
+func (p blockingProcessor) ProcessJob(ctx context.Context, job *Job) JobResult {
+	<-p.release
+	return JobResult{Success: true}
+}
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !done(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Scaling down stops idle workers at once and lets busy ones finish their
+// job first.
+func TestScaleDownStopsWorkers(t *testing.T) {
+	queue := NewJobQueue(3, 10)
+	pool := NewWorkerPool(3, queue, nil)
+	processor := blockingProcessor{release: make(chan struct{})}
+	pool.RegisterProcessor("block", processor)
+	pool.Start()
+	defer pool.Stop()
+
+	job := NewJob("block", "/tmp/a", "files", "a", PriorityMedium)
+	queue.Enqueue(job)
+	waitFor(t, "the job to start", func() bool { return len(pool.GetActiveJobs()) == 1 })
+
+	pool.UpdateWorkerCount(1)
+	waitFor(t, "idle workers to stop", func() bool { return len(pool.GetWorkerDetails(0)) == 1 })
+	if details := pool.GetWorkerDetails(0); details[0].CurrentJobID != job.ID || details[0].Stopping {
+		t.Errorf("the busy worker was stopped: %+v", details[0])
+	}
+
+	pool.UpdateWorkerCount(2)
+	waitFor(t, "a worker to be added", func() bool { return len(pool.GetWorkerDetails(0)) == 2 })
+	pool.UpdateWorkerCount(1)
+	waitFor(t, "the idle worker to stop", func() bool { return len(pool.GetWorkerDetails(0)) == 1 })
+
+	close(processor.release)
+	waitFor(t, "the job to finish", func() bool { return len(pool.GetActiveJobs()) == 0 })
+	if pool.GetWorkerCount() != 1 {
+		t.Errorf("worker count = %d, want 1", pool.GetWorkerCount())
+	}
+}
+
+// A worker is reported busy with its job while it runs, stuck once past the
+// threshold, and idle with the job counted when it is done.
+func TestWorkerDetailsWhileJobRuns(t *testing.T) {
+	queue := NewJobQueue(2, 10)
+	pool := NewWorkerPool(2, queue, nil)
+	processor := blockingProcessor{release: make(chan struct{})}
+	pool.RegisterProcessor("block", processor)
+	pool.Start()
+	defer pool.Stop()
+
+	waitFor(t, "the workers to start", func() bool { return len(pool.GetWorkerDetails(0)) == 2 })
+	job := NewJob("block", "/tmp/a", "files", "a", PriorityMedium)
+	queue.Enqueue(job)
+	workerBusy := func() bool {
+		for _, detail := range pool.GetWorkerDetails(0) {
+			if detail.CurrentJobID != "" {
+				return true
+			}
+		}
+		return false
+	}
+	waitFor(t, "the job to start", workerBusy)
+
+	var busy, idle []WorkerDetail
+	for _, detail := range pool.GetWorkerDetails(DefaultStuckThreshold) {
+		if detail.Status == "busy" {
+			busy = append(busy, detail)
+		} else {
+			idle = append(idle, detail)
+		}
+	}
+	if len(busy) != 1 || busy[0].CurrentJobID != job.ID || busy[0].CurrentType != "block" || busy[0].BusyFor == "" {
+		t.Fatalf("busy workers = %+v, want one on %s", busy, job.ID)
+	}
+	if len(idle) != 1 || idle[0].Status != "idle" || idle[0].CurrentJobID != "" || idle[0].IdleFor == "" {
+		t.Errorf("idle workers = %+v, want the other one idle", idle)
+	}
+
+	time.Sleep(time.Millisecond)
+	for _, detail := range pool.GetWorkerDetails(time.Nanosecond) {
+		if stuck := detail.ID == busy[0].ID; detail.Stuck != stuck || (detail.Status == "stuck") != stuck {
+			t.Errorf("worker %d: status %s, stuck %v", detail.ID, detail.Status, detail.Stuck)
+		}
+	}
+
+	close(processor.release)
+	waitFor(t, "the job to finish", func() bool { return !workerBusy() })
+	for _, detail := range pool.GetWorkerDetails(DefaultStuckThreshold) {
+		processed := 0
+		if detail.ID == busy[0].ID {
+			processed = 1
+		}
+		if detail.Status != "idle" || detail.CurrentJobID != "" || detail.JobsProcessed != processed || detail.JobsFailed != 0 {
+			t.Errorf("worker %d after the job: %+v", detail.ID, detail)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package jobs
+
+import (
+	"sort"
+	"time"
+)
+
+// DefaultStuckThreshold is how long a worker may spend on one job before it
+// is reported as stuck.
+const DefaultStuckThreshold = 30 * time.Minute
+
+// workerState is the bookkeeping for one worker goroutine, guarded by the
+// pool's mutex.
+type workerState struct {
+	id            int
+	startedAt     time.Time
+	currentJob    *Job
+	jobStartedAt  time.Time
+	idleSince     time.Time
+	jobsProcessed int
+	jobsFailed    int
+	lastError     string
+	lastErrorAt   *time.Time
+}
+
+type WorkerDetail struct {
+	ID            int        `json:"id"`
+	Status        string     `json:"status"` // "idle", "busy" or "stuck"
+	CurrentJobID  string     `json:"current_job_id,omitempty"`
+	CurrentType   string     `json:"current_job_type,omitempty"`
+	BusyFor       string     `json:"busy_for,omitempty"`
+	IdleFor       string     `json:"idle_for,omitempty"`
+	JobsProcessed int        `json:"jobs_processed"`
+	JobsFailed    int        `json:"jobs_failed"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+	StartedAt     time.Time  `json:"started_at"`
+	Stuck         bool       `json:"stuck"`
+	// Stopping workers were removed by a scale-down and exit once their
+	// current job finishes
+	Stopping bool `json:"stopping,omitempty"`
+}
+
+func (wp *WorkerPool) workerStarted(id int) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	now := time.Now()
+	wp.workerStates[id] = &workerState{id: id, startedAt: now, idleSince: now}
+}
+
+func (wp *WorkerPool) workerStopped(id int) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	delete(wp.workerStates, id)
+}
+
+func (wp *WorkerPool) workerBusy(id int, job *Job) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	if state, ok := wp.workerStates[id]; ok {
+		state.currentJob = job
+		state.jobStartedAt = time.Now()
+	}
+}
+
+// workerIdle records the outcome of the job the worker just finished. An
+// interrupted job is not counted.
+func (wp *WorkerPool) workerIdle(id int, job *Job) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	state, ok := wp.workerStates[id]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	state.currentJob = nil
+	state.idleSince = now
+
+	switch job.Status {
+	case JobStatusCompleted:
+		state.jobsProcessed++
+	case JobStatusFailed:
+		state.jobsProcessed++
+		state.jobsFailed++
+		state.lastError = job.Error
+		state.lastErrorAt = &now
+	}
+}
+
+// GetWorkerDetails describes every running worker, flagging those that have
+// been on their current job for longer than stuckAfter.
+func (wp *WorkerPool) GetWorkerDetails(stuckAfter time.Duration) []WorkerDetail {
+	wp.mu.RLock()
+	defer wp.mu.RUnlock()
+
+	now := time.Now()
+	details := make([]WorkerDetail, 0, len(wp.workerStates))
+	for _, state := range wp.workerStates {
+		detail := WorkerDetail{
+			ID:            state.id,
+			Status:        "idle",
+			JobsProcessed: state.jobsProcessed,
+			JobsFailed:    state.jobsFailed,
+			LastError:     state.lastError,
+			LastErrorAt:   state.lastErrorAt,
+			StartedAt:     state.startedAt,
+		}
+		if _, counted := wp.stops[state.id]; !counted {
+			detail.Stopping = true
+		}
+
+		if state.currentJob != nil {
+			busyFor := now.Sub(state.jobStartedAt)
+			detail.Status = "busy"
+			detail.CurrentJobID = state.currentJob.ID
+			detail.CurrentType = state.currentJob.Type
+			detail.BusyFor = busyFor.Round(time.Second).String()
+			if stuckAfter > 0 && busyFor > stuckAfter {
+				detail.Status = "stuck"
+				detail.Stuck = true
+			}
+		} else {
+			detail.IdleFor = now.Sub(state.idleSince).Round(time.Second).String()
+		}
+
+		details = append(details, detail)
+	}
+
+	sort.Slice(details, func(i, j int) bool { return details[i].ID < details[j].ID })
+	return details
+}
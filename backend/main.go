@@ -2,122 +2,418 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"bronze-backend/audit"
+	"bronze-backend/auth"
+	"bronze-backend/bodylimit"
+	"bronze-backend/buildinfo"
 	"bronze-backend/config"
 	"bronze-backend/data_browser"
 	"bronze-backend/files"
+	"bronze-backend/graphapi"
+	"bronze-backend/health"
+	"bronze-backend/idempotency"
 	"bronze-backend/jobs"
+	"bronze-backend/metering"
 	"bronze-backend/monitoring"
+	"bronze-backend/quarantine"
+	"bronze-backend/ratelimit"
+	"bronze-backend/realtime"
 	"bronze-backend/routes"
 	"bronze-backend/storage"
-
-	"github.com/joho/godotenv"
+	"bronze-backend/tenant"
+	"bronze-backend/tracing"
 )
 
 func main() {
-	log.Println("Starting Bronze Backend...")
-
-	// Load .env file if it exists
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables or defaults")
-	} else {
-		if wd, err := os.Getwd(); err == nil {
-			log.Printf("Loaded .env file from: %s/.env", wd)
-		} else {
-			log.Println("Loaded .env file successfully")
+	if err := runCommand(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
 		}
+		log.Fatal(err)
 	}
+}
+
+// serve runs the API server along with the worker pool and file watcher, or
+// the part of them RUN_MODE selects.
+func serve(args []string) error {
+	fs, opts := newFlagSet("serve", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	log.Printf("Starting Bronze Backend %s...", buildinfo.Get())
 
-	cfg, err := config.Load()
+	cfg, err := opts.load()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		return err
 	}
 
 	log.Printf("Configuration loaded successfully")
-	log.Printf("Server: %s", cfg.GetServerAddr())
-	log.Printf("MinIO: %s (bucket: %s)", cfg.MinIO.Endpoint, cfg.MinIO.Bucket)
-	log.Printf("Workers: %d", cfg.Processing.MaxWorkers)
 
-	storageClient, err := storage.NewMinIOClient(&cfg.MinIO)
+	if cfg.Server.Mode == config.RunModeWorker {
+		return runWorker(cfg, opts.envFile)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
 	if err != nil {
-		log.Printf("Warning: Failed to create MinIO client: %v", err)
-		log.Println("MinIO features will be disabled until connection is restored")
-		storageClient = nil
+		log.Printf("Warning: Failed to set up tracing: %v", err)
+		shutdownTracing = func(context.Context) error { return nil }
+	}
+	log.Printf("Server: %s", cfg.GetServerAddr())
+	log.Printf("MinIO: %s (bucket: %s)", cfg.MinIO.Endpoint, cfg.MinIO.Bucket)
+	if cfg.Server.Mode == config.RunModeAPI {
+		log.Println("Workers: none, jobs are processed by worker instances")
 	} else {
-		log.Println("MinIO client created successfully")
+		log.Printf("Workers: %d", cfg.Processing.MaxWorkers)
 	}
 
+	storageClient := newStorageClient(cfg)
+
+	// Core services start without Nessie; exports report 503 until it is
+	// reachable
 	nessieClient, err := storage.NewNessieClient(&cfg.Nessie)
 	if err != nil {
 		log.Printf("Warning: Failed to create Nessie client: %v", err)
-		log.Println("Nessie export features will be disabled")
+		log.Println("Nessie export features will be disabled until Nessie is reachable")
 		nessieClient = nil
 	} else {
 		log.Println("Nessie client created successfully")
+	}
 
-		fileProcessor := files.NewFileProcessor(cfg)
-		log.Println("File processor created successfully")
+	processing, err := startWorkers(cfg, storageClient)
+	if err != nil {
+		return err
+	}
+	jobQueue, workerPool, autoscaler, fileProcessor := processing.queue, processing.pool, processing.autoscaler, processing.fileProcessor
 
-		jobQueue := jobs.NewJobQueue(cfg.Processing.MaxWorkers, cfg.Processing.QueueSize)
-		log.Println("Job queue created successfully")
+	autoJobs, err := monitoring.NewAutoJobEngine(jobQueue, cfg.Watcher.AutoJobsFile)
+	if err != nil {
+		return fmt.Errorf("failed to load auto-job rules: %w", err)
+	}
+	if storageClient != nil {
+		autoJobs.SetObjectTags(storageClient)
+	}
 
-		workerPool := jobs.NewWorkerPool(cfg.Processing.MaxWorkers, jobQueue, fileProcessor)
-		workerPool.Start()
-		log.Printf("Worker pool started with %d workers", cfg.Processing.MaxWorkers)
+	// Job, watcher and export events reach /api/ws clients through the hub
+	events := realtime.NewHub()
+	if workerPool != nil {
+		workerPool.SetEventHub(events)
+	}
 
-		// Create file watcher (disabled for now to avoid startup issues)
-		var fileWatcher *monitoring.FileWatcher
+	var fileWatcher *monitoring.FileWatcher
+	if cfg.Watcher.Enabled {
+		onEvent := func(event *monitoring.FileEvent) {
+			autoJobs.HandleEvent(event)
+			if event.EventType != monitoring.EventCreated {
+				return
+			}
+			if job := fileProcessor.AutoExtractJob(event.Bucket, event.Key, event.ETag); job != nil {
+				job.Metadata["event_id"] = event.ID
+				files.EnqueueAutoExtract(jobQueue, job)
+			}
+		}
+		fileWatcher = startFileWatcher(cfg, onEvent, processing.notifier, events)
+	} else {
 		log.Println("File watcher disabled")
+	}
 
-		fileHandler := files.NewFileHandlerWithQueue(storageClient, fileProcessor, jobQueue)
-		jobHandler := jobs.NewJobHandler(jobQueue, workerPool)
-		watcherHandler := monitoring.NewWatcherHandler(fileWatcher)
-		dataBrowserHandler := data_browser.NewDataBrowserHandler(storageClient)
-		exportHandler := data_browser.NewExportHandler(storageClient, nessieClient, cfg, dataBrowserHandler)
-
-		router := routes.NewRouter(fileHandler, jobHandler, watcherHandler, dataBrowserHandler, exportHandler)
-		server := &http.Server{
-			Addr:         cfg.GetServerAddr(),
-			Handler:      router.GetRouter(),
-			ReadTimeout:  30 * time.Second,
-			WriteTimeout: 30 * time.Second,
-			IdleTimeout:  120 * time.Second,
-		}
+	fileHandler := files.NewFileHandlerWithQueue(storageClient, fileProcessor, jobQueue)
+	fileHandler.SetUsage(processing.usage)
+	jobHandler := jobs.NewJobHandler(jobQueue, workerPool)
+	jobHandler.SetUsage(processing.usage)
+	jobHandler.SetStorage(storageClient)
+	jobHandler.SetNotifier(processing.notifier)
+	jobHandler.SetDeadLetters(jobs.NewDeadLetterQueue(storageClient))
+	if autoscaler != nil {
+		jobHandler.SetAutoscaler(autoscaler)
+	}
+	watcherHandler := monitoring.NewWatcherHandler(fileWatcher)
+	watcherHandler.SetAutoJobs(autoJobs)
+	dataBrowserHandler := data_browser.NewDataBrowserHandler(storageClient)
+	conversionRates, err := cfg.Processing.ParseConversionRates()
+	if err != nil {
+		return err
+	}
+	dataBrowserHandler.SetConversionRates(conversionRates)
+	exportHandler := data_browser.NewExportHandler(storageClient, nessieClient, cfg, dataBrowserHandler)
+	exportHandler.SetEventHub(events)
+	exportHandler.SetUsage(processing.usage)
+	exportHandler.SetQuarantine(processing.quarantine)
+	exportHandler.SetJobQueue(jobQueue)
+	exportHandler.SetNotifier(processing.notifier)
+	realtimeHandler := realtime.NewHandler(events)
+	realtimeHandler.HandleStream(realtime.StreamBrowse, fileHandler.StreamBrowse)
+	realtimeHandler.AllowOrigins(cfg.Server.AllowedOriginList())
 
-		go func() {
-			log.Printf("Starting HTTP server on %s", cfg.GetServerAddr())
-			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				log.Fatalf("Failed to start server: %v", err)
-			}
-		}()
+	// Interfaces holding nil pointers are not nil, so only set what exists
+	graphSources := graphapi.Sources{Jobs: jobQueue, Exports: exportHandler}
+	if storageClient != nil {
+		graphSources.Files = storageClient
+	}
+	if fileWatcher != nil {
+		graphSources.Events = fileWatcher
+	}
+	graphqlHandler, err := graphapi.NewHandler(graphSources)
+	if err != nil {
+		return err
+	}
+
+	// Exports answer 503 until a background retry reaches Nessie
+	reconnectCtx, stopReconnect := context.WithCancel(context.Background())
+	defer stopReconnect()
+	if nessieClient == nil && cfg.Nessie.Endpoint != "" {
+		storage.ConnectNessie(reconnectCtx, &cfg.Nessie, exportHandler.SetNessieClient)
+	}
+
+	// Refuse to start rather than serve the API unprotected
+	authenticator, err := auth.New(context.Background(), cfg.Auth)
+	if err != nil {
+		return fmt.Errorf("failed to set up authentication: %w", err)
+	}
+	if authenticator == nil {
+		log.Println("Authentication disabled")
+	}
+
+	auditLog, err := audit.Open(cfg.Audit)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if auditLog == nil {
+		log.Println("Audit log disabled")
+	}
 
-		quit := make(chan os.Signal, 1)
-		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-		<-quit
+	limiter := ratelimit.New(cfg.RateLimit)
+
+	bodyLimiter, err := bodylimit.New(cfg.BodyLimit)
+	if err != nil {
+		return fmt.Errorf("failed to set up body size limits: %w", err)
+	}
+	fileHandler.SetUploadMemory(uploadMemory(cfg))
 
-		log.Println("Shutting down server...")
+	idempotencyStore := idempotency.New(cfg.Idempotency)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+	router := routes.NewRouter(fileHandler, jobHandler, watcherHandler, dataBrowserHandler, exportHandler, authenticator, auditLog, limiter)
+	router.SetBodyLimiter(bodyLimiter)
+	router.SetIdempotency(idempotencyStore)
+	router.EnableDebug(cfg.Debug)
+	router.EnableProbes(newHealthChecker(storageClient, exportHandler, jobQueue))
+	router.EnableRealtime(realtimeHandler)
+	router.EnableGraphQL(graphqlHandler)
+	router.SetTenants(processing.tenants)
+	router.SetBuckets(storageClient)
+	router.EnableUsage(metering.NewHandler(processing.usage))
+	router.EnableQuarantine(quarantine.NewHandler(processing.quarantine))
 
-		if err := server.Shutdown(ctx); err != nil {
-			log.Printf("Server forced to shutdown: %v", err)
+	configManager := newConfigManager(cfg, opts.envFile, workerPool, autoscaler, fileWatcher, fileProcessor, processing.tenants, processing.usage, processing.quarantine, limiter)
+	configManager.OnChange(func(c *config.Config) {
+		if err := bodyLimiter.SetConfig(c.BodyLimit); err != nil {
+			log.Printf("Warning: Failed to apply body size limits: %v", err)
 		}
+		fileHandler.SetUploadMemory(uploadMemory(c))
+	}, "MAX_UPLOAD_SIZE", "MAX_JSON_BODY_SIZE", "UPLOAD_MEMORY_SIZE", "BODY_LIMIT_ENDPOINTS")
+	if idempotencyStore != nil {
+		configManager.OnChange(func(c *config.Config) {
+			idempotencyStore.SetConfig(c.Idempotency)
+		}, "IDEMPOTENCY_TTL")
+	}
+	router.SetConfigManager(configManager)
+
+	server, err := startServer(cfg, router.GetRouter())
+	if err != nil {
+		return err
+	}
+	stopReload := reloadOnSIGHUP(configManager)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down server...")
+	stopReload()
+	stopReconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	server.shutdown(ctx)
+
+	if err := auditLog.Close(); err != nil {
+		log.Printf("Warning: Failed to close audit log: %v", err)
+	}
+
+	processing.stop(cfg)
+
+	if fileWatcher != nil {
+		fileWatcher.Stop()
+		log.Println("File watcher stopped")
+	}
+
+	// After the watcher, which forwards its events through the notifier
+	processing.notifier.Stop()
+
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
+
+	log.Println("Server exited")
+	return nil
+}
+
+// newConfigManager returns a configuration manager that applies the settings
+// the running services can change without a restart.
+func newConfigManager(cfg *config.Config, envFile string, workerPool *jobs.WorkerPool, autoscaler *jobs.Autoscaler,
+	fileWatcher *monitoring.FileWatcher, fileProcessor *files.FileProcessor, tenants *tenant.Registry, tracker *metering.Tracker, quarantineStore *quarantine.Store, limiter *ratelimit.Limiter) *config.Manager {
+	m := config.NewManager(cfg, envFile)
+
+	// The autoscaler owns the worker count when it runs
+	if workerPool != nil && autoscaler == nil {
+		m.OnChange(func(c *config.Config) {
+			workerPool.UpdateWorkerCount(c.Processing.MaxWorkers)
+		}, "MAX_WORKERS")
+	}
+	if fileWatcher != nil {
+		m.OnChange(func(c *config.Config) {
+			fileWatcher.SetPollInterval(c.Processing.WatchInterval)
+		}, "WATCH_INTERVAL")
+	}
+	m.OnChange(func(c *config.Config) {
+		fileProcessor.SetDecompression(c.Processing.Decompression)
+	}, "MAX_EXTRACT_SIZE", "MAX_FILES_PER_ARCHIVE", "MAX_COMPRESSION_RATIO", "NESTED_ARCHIVE_DEPTH",
+		"PASSWORD_PROTECTED", "EXTRACT_TO_SUBFOLDER", "EXTRACT_PREFIX", "STREAM_EXTRACT_THRESHOLD")
+	if tenants != nil {
+		m.OnChange(func(c *config.Config) {
+			if err := tenants.SetConfig(c.Tenancy); err != nil {
+				log.Printf("Warning: Failed to apply tenants: %v", err)
+			}
+		}, "TENANTS", "TENANT_HEADER")
+	}
+	if tracker != nil {
+		m.OnChange(func(c *config.Config) {
+			if err := tracker.SetConfig(c.Usage); err != nil {
+				log.Printf("Warning: Failed to apply usage quotas: %v", err)
+			}
+		}, "USAGE_QUOTAS")
+	}
+	if quarantineStore != nil {
+		m.OnChange(func(c *config.Config) {
+			quarantineStore.SetConfig(c.Quarantine)
+		}, "QUARANTINE_PREFIX", "QUARANTINE_THRESHOLD", "QUARANTINE_MOVE")
+	}
+	if limiter != nil {
+		m.OnChange(func(c *config.Config) {
+			limiter.SetConfig(c.RateLimit)
+		}, "RATE_LIMIT_RPS", "RATE_LIMIT_BURST", "RATE_LIMIT_EXPENSIVE_RPS", "RATE_LIMIT_EXPENSIVE_BURST", "RATE_LIMIT_TRUST_PROXY")
+	}
+	return m
+}
+
+// uploadMemory returns UPLOAD_MEMORY_SIZE in bytes. Load has validated it.
+func uploadMemory(cfg *config.Config) int64 {
+	n, _ := config.ParseByteSize(cfg.BodyLimit.UploadMemory)
+	return n
+}
 
-		workerPool.Stop()
-		log.Println("Worker pool stopped")
+// newHealthChecker returns the readiness checks. Nessie is optional: without
+// it only exports are unavailable. Workers, which have no export handler,
+// do not check it.
+func newHealthChecker(storageClient *storage.MinIOClient, exportHandler *data_browser.ExportHandler, jobQueue jobs.Queue) *health.Checker {
+	checker := health.NewChecker()
+	checker.Add("minio", true, func(ctx context.Context) error {
+		if storageClient == nil {
+			return errors.New("not connected")
+		}
+		return storageClient.Ping(ctx)
+	})
+	checker.Add("bucket", true, func(ctx context.Context) error {
+		if storageClient == nil {
+			return errors.New("not connected")
+		}
+		return storageClient.CheckBucket(ctx)
+	})
+	if exportHandler != nil {
+		checker.Add("nessie", false, func(ctx context.Context) error {
+			nessieClient := exportHandler.NessieClient()
+			if nessieClient == nil {
+				return errors.New("not connected")
+			}
+			if breaker := nessieClient.Breaker(); breaker.State == storage.BreakerOpen {
+				return fmt.Errorf("circuit breaker open after %d consecutive failures, probing from %s", breaker.Failures, breaker.RetryAt.Format(time.RFC3339))
+			}
+			return nessieClient.Ping(ctx)
+		})
+	}
+	checker.Add("queue", true, jobQueue.Ping)
+	return checker
+}
 
-		if fileWatcher != nil {
-			fileWatcher.Stop()
-			log.Println("File watcher stopped")
+// startFileWatcher starts the file watcher, handing every event to onEvent,
+// forwarding events to rule webhooks through notifier and publishing them to
+// events. It returns nil if the watcher cannot run so the server still comes
+// up without it.
+func startFileWatcher(cfg *config.Config, onEvent func(*monitoring.FileEvent), notifier *jobs.WebhookNotifier, events *realtime.Hub) *monitoring.FileWatcher {
+	var rules []monitoring.WatchRule
+	if cfg.Watcher.RulesFile != "" {
+		loaded, err := monitoring.LoadWatchRules(cfg.Watcher.RulesFile)
+		if err != nil {
+			log.Printf("Warning: Failed to load watch rules: %v", err)
+			return nil
 		}
+		rules = loaded
+	}
+
+	eventStorage, err := monitoring.NewEventStorage(cfg.Watcher)
+	if err != nil {
+		log.Printf("Warning: Failed to open watcher event storage: %v", err)
+		return nil
+	}
+
+	fileWatcher, err := monitoring.NewFileWatcher(monitoring.Config{
+		Endpoint:        cfg.MinIO.Endpoint,
+		AccessKeyID:     cfg.MinIO.AccessKey,
+		SecretAccessKey: cfg.MinIO.SecretKey,
+		UseSSL:          cfg.MinIO.UseSSL(),
+		Region:          cfg.MinIO.Region,
+		Rules:           rules,
+		RulesFile:       cfg.Watcher.RulesFile,
+		BucketName:      cfg.Watcher.Bucket,
+		Prefix:          cfg.Watcher.Prefix,
+		PollInterval:    cfg.Processing.WatchInterval,
+		Retention:       cfg.Watcher.Retention,
+		Debounce:        cfg.Watcher.Debounce,
+		Mode:            cfg.Watcher.Mode,
+	}, eventStorage)
+	if err != nil {
+		log.Printf("Warning: Failed to create file watcher: %v", err)
+		closeEventStorage(eventStorage)
+		return nil
+	}
+	fileWatcher.SetEventHandler(onEvent)
+	fileWatcher.SetWebhookNotifier(notifier)
+	fileWatcher.SetEventHub(events)
+
+	if err := fileWatcher.Start(); err != nil {
+		log.Printf("Warning: Failed to start file watcher: %v", err)
+		closeEventStorage(eventStorage)
+		return nil
+	}
+	log.Printf("File watcher event storage: %s", cfg.Watcher.Storage)
+	return fileWatcher
+}
 
-		log.Println("Server exited")
+func closeEventStorage(eventStorage monitoring.EventStorage) {
+	if closer, ok := eventStorage.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("Warning: Failed to close watcher event storage: %v", err)
+		}
 	}
 }
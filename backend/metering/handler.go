@@ -0,0 +1,80 @@
+package metering
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"bronze-backend/auth"
+	"bronze-backend/config"
+	"bronze-backend/httputil"
+	"bronze-backend/tenant"
+)
+
+// Handler serves usage totals.
+type Handler struct {
+	tracker *Tracker
+}
+
+func NewHandler(tracker *Tracker) *Handler {
+	return &Handler{tracker: tracker}
+}
+
+// GetUsageResponse is the body of GET /api/usage.
+type GetUsageResponse struct {
+	Success bool          `json:"success"`
+	Period  string        `json:"period"`
+	Usage   []*Usage      `json:"usage"`
+	Quotas  config.Quotas `json:"quotas"`
+}
+
+// GetUsage returns usage for a month, the current one unless period is
+// given. Callers see their own usage; admins, and every caller when auth is
+// disabled, see everyone's unless they name a subject. Usage is kept per
+// subject, not per tenant, so callers confined to a tenant only see their
+// own.
+func (h *Handler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	if h.tracker == nil {
+		httputil.Error(w, "Usage accounting is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = Period(time.Now())
+	} else if _, err := time.Parse(periodLayout, period); err != nil {
+		httputil.WriteError(w, "Invalid query parameters", http.StatusBadRequest, fmt.Errorf("invalid period %q, use YYYY-MM", period))
+		return
+	}
+
+	subject := r.URL.Query().Get("subject")
+	_, confined := tenant.FromContext(r.Context())
+	if principal, ok := auth.FromContext(r.Context()); ok && (principal.Role < auth.RoleAdmin || confined) {
+		if subject != "" && subject != principal.Subject {
+			httputil.Error(w, "Only admins may read another caller's usage", http.StatusForbidden)
+			return
+		}
+		subject = principal.Subject
+	}
+
+	var list []*Usage
+	var err error
+	if subject == "" {
+		list, err = h.tracker.List(period)
+	} else {
+		var u *Usage
+		u, err = h.tracker.Get(subject, period)
+		list = []*Usage{u}
+	}
+	if err != nil {
+		httputil.WriteError(w, "Failed to read usage", http.StatusInternalServerError, err)
+		return
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, GetUsageResponse{
+		Success: true,
+		Period:  period,
+		Usage:   list,
+		Quotas:  h.tracker.Quotas(),
+	})
+}
@@ -0,0 +1,290 @@
+// Package metering accounts for what each caller consumes of a shared
+// deployment: bytes uploaded, rows exported and job processing time. Totals
+// are kept per subject and calendar month in a SQLite database, and can be
+// capped with soft quotas, which warn, and hard quotas, which refuse new
+// work until the month ends.
+package metering
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"bronze-backend/auth"
+	"bronze-backend/config"
+	"bronze-backend/httputil"
+
+	_ "modernc.org/sqlite"
+)
+
+// Metric is one kind of consumption.
+type Metric string
+
+const (
+	MetricUpload  Metric = "upload"   // Bytes uploaded
+	MetricRows    Metric = "rows"     // Rows exported to Nessie
+	MetricJobTime Metric = "job_time" // Job processing time, in milliseconds
+)
+
+// Anonymous is the subject usage is accounted to when auth is disabled.
+const Anonymous = "anonymous"
+
+// WarningHeader carries the soft quota warning on responses that are
+// allowed over it.
+const WarningHeader = "X-Usage-Warning"
+
+// periodLayout formats the calendar month usage is accounted to.
+const periodLayout = "2006-01"
+
+const schema = `
+CREATE TABLE IF NOT EXISTS usage (
+	subject       TEXT NOT NULL,
+	period        TEXT NOT NULL,
+	upload_bytes  INTEGER NOT NULL DEFAULT 0,
+	rows_exported INTEGER NOT NULL DEFAULT 0,
+	job_time_ms   INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (subject, period)
+);
+`
+
+var columns = map[Metric]string{
+	MetricUpload:  "upload_bytes",
+	MetricRows:    "rows_exported",
+	MetricJobTime: "job_time_ms",
+}
+
+// Usage is one subject's consumption in one month.
+type Usage struct {
+	Subject      string `json:"subject"`
+	Period       string `json:"period"` // e.g. "2026-01"
+	UploadBytes  int64  `json:"upload_bytes"`
+	RowsExported int64  `json:"rows_exported"`
+	JobTimeMS    int64  `json:"job_time_ms"`
+}
+
+func (u *Usage) value(metric Metric) int64 {
+	switch metric {
+	case MetricUpload:
+		return u.UploadBytes
+	case MetricRows:
+		return u.RowsExported
+	default:
+		return u.JobTimeMS
+	}
+}
+
+// QuotaError is returned for a subject over its hard quota.
+type QuotaError struct {
+	Subject string
+	Metric  Metric
+	Used    int64
+	Limit   int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s is over the %s quota for this month (%d of %d)", e.Subject, e.Metric, e.Used, e.Limit)
+}
+
+// StatusCode makes httputil.WriteError answer 403.
+func (e *QuotaError) StatusCode() int {
+	return http.StatusForbidden
+}
+
+// ErrorCode makes httputil.WriteError report httputil.CodeQuotaExceeded.
+func (e *QuotaError) ErrorCode() httputil.Code {
+	return httputil.CodeQuotaExceeded
+}
+
+// Tracker accounts for usage. A nil Tracker records nothing and enforces no
+// quotas.
+type Tracker struct {
+	db *sql.DB
+
+	mu     sync.RWMutex
+	quotas config.Quotas
+}
+
+// Open opens (or creates) the usage database, or returns nil when usage
+// accounting is disabled.
+func Open(cfg config.UsageConfig) (*Tracker, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	quotas, err := cfg.ParseQuotas()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.DBPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create usage database directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+cfg.DBPath+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage database: %w", err)
+	}
+	// One writer at a time, as for the audit log
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create usage schema: %w", err)
+	}
+
+	return &Tracker{db: db, quotas: quotas}, nil
+}
+
+// Close closes the database.
+func (t *Tracker) Close() error {
+	if t == nil {
+		return nil
+	}
+	return t.db.Close()
+}
+
+// SetConfig replaces the quotas.
+func (t *Tracker) SetConfig(cfg config.UsageConfig) error {
+	quotas, err := cfg.ParseQuotas()
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.quotas = quotas
+	return nil
+}
+
+// Quotas returns the configured quotas.
+func (t *Tracker) Quotas() config.Quotas {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.quotas
+}
+
+func (t *Tracker) quota(metric Metric) config.Quota {
+	quotas := t.Quotas()
+	switch metric {
+	case MetricUpload:
+		return quotas.Upload
+	case MetricRows:
+		return quotas.Rows
+	default:
+		return quotas.JobTime
+	}
+}
+
+// Period returns the calendar month at, in UTC, that usage is accounted to.
+func Period(at time.Time) string {
+	return at.UTC().Format(periodLayout)
+}
+
+func subjectOrAnonymous(subject string) string {
+	if subject == "" {
+		return Anonymous
+	}
+	return subject
+}
+
+// Add accounts amount of metric to subject for the current month. Failures
+// are logged rather than returned, so accounting never fails the work it
+// measures.
+func (t *Tracker) Add(subject string, metric Metric, amount int64) {
+	if t == nil || amount <= 0 {
+		return
+	}
+	column, ok := columns[metric]
+	if !ok {
+		log.Printf("Usage: unknown metric %s", metric)
+		return
+	}
+	subject = subjectOrAnonymous(subject)
+
+	_, err := t.db.Exec(`INSERT INTO usage (subject, period, `+column+`) VALUES (?, ?, ?)
+		ON CONFLICT (subject, period) DO UPDATE SET `+column+` = `+column+` + excluded.`+column,
+		subject, Period(time.Now()), amount)
+	if err != nil {
+		log.Printf("Usage: failed to account %d %s to %s: %v", amount, metric, subject, err)
+	}
+}
+
+// Get returns subject's usage in period, zero if it has none.
+func (t *Tracker) Get(subject, period string) (*Usage, error) {
+	u := &Usage{Subject: subjectOrAnonymous(subject), Period: period}
+	err := t.db.QueryRow(`SELECT upload_bytes, rows_exported, job_time_ms FROM usage
+		WHERE subject = ? AND period = ?`, u.Subject, period).Scan(&u.UploadBytes, &u.RowsExported, &u.JobTimeMS)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return u, nil
+}
+
+// List returns every subject's usage in period, ordered by subject.
+func (t *Tracker) List(period string) ([]*Usage, error) {
+	rows, err := t.db.Query(`SELECT subject, upload_bytes, rows_exported, job_time_ms FROM usage
+		WHERE period = ? ORDER BY subject`, period)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*Usage{}
+	for rows.Next() {
+		u := &Usage{Period: period}
+		if err := rows.Scan(&u.Subject, &u.UploadBytes, &u.RowsExported, &u.JobTimeMS); err != nil {
+			return nil, err
+		}
+		list = append(list, u)
+	}
+	return list, rows.Err()
+}
+
+// Check compares subject's usage of metric this month with its quota. It
+// returns a *QuotaError at or over the hard quota, and a warning at or over
+// the soft quota.
+func (t *Tracker) Check(subject string, metric Metric) (string, error) {
+	if t == nil {
+		return "", nil
+	}
+	quota := t.quota(metric)
+	if quota.Soft == 0 && quota.Hard == 0 {
+		return "", nil
+	}
+
+	u, err := t.Get(subject, Period(time.Now()))
+	if err != nil {
+		return "", fmt.Errorf("failed to read usage: %w", err)
+	}
+	used := u.value(metric)
+	switch {
+	case quota.Hard > 0 && used >= quota.Hard:
+		return "", &QuotaError{Subject: u.Subject, Metric: metric, Used: used, Limit: quota.Hard}
+	case quota.Soft > 0 && used >= quota.Soft:
+		return fmt.Sprintf("%s is over the soft %s quota for this month (%d of %d)", u.Subject, metric, used, quota.Soft), nil
+	}
+	return "", nil
+}
+
+// Allow checks the caller's quota for metric before a handler starts work.
+// Over the hard quota it answers 403 and returns false; over the soft quota
+// it sets the warning header and returns true. A caller is never refused
+// because the usage database could not be read.
+func (t *Tracker) Allow(w http.ResponseWriter, r *http.Request, metric Metric) bool {
+	warning, err := t.Check(auth.Subject(r.Context()), metric)
+	var quotaErr *QuotaError
+	switch {
+	case errors.As(err, &quotaErr):
+		httputil.WriteError(w, "Usage quota exceeded", http.StatusForbidden, err)
+		return false
+	case err != nil:
+		log.Printf("Usage: %v", err)
+	case warning != "":
+		w.Header().Set(WarningHeader, warning)
+	}
+	return true
+}
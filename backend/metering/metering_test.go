@@ -0,0 +1,160 @@
+package metering
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bronze-backend/auth"
+	"bronze-backend/config"
+)
+
+func openTestTracker(t *testing.T, quotas string) *Tracker {
+	t.Helper()
+	tracker, err := Open(config.UsageConfig{Enabled: true, DBPath: filepath.Join(t.TempDir(), "usage.db"), Quotas: quotas})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	return tracker
+}
+
+func TestOpenDisabled(t *testing.T) {
+	tracker, err := Open(config.UsageConfig{})
+	if tracker != nil || err != nil {
+		t.Fatalf("Open() = %v, %v; want nil when disabled", tracker, err)
+	}
+	tracker.Add("alice", MetricUpload, 10)
+	if warning, err := tracker.Check("alice", MetricUpload); warning != "" || err != nil {
+		t.Errorf("a nil Tracker checks quotas: %q, %v", warning, err)
+	}
+}
+
+func TestAddAndList(t *testing.T) {
+	tracker := openTestTracker(t, "")
+	tracker.Add("alice", MetricUpload, 100)
+	tracker.Add("alice", MetricUpload, 50)
+	tracker.Add("alice", MetricRows, 7)
+	tracker.Add("", MetricJobTime, 1500)
+
+	period := Period(time.Now())
+	u, err := tracker.Get("alice", period)
+	if err != nil || u.UploadBytes != 150 || u.RowsExported != 7 || u.JobTimeMS != 0 {
+		t.Errorf("Get(alice) = %+v, %v", u, err)
+	}
+	if u, err := tracker.Get("bob", period); err != nil || u.UploadBytes != 0 {
+		t.Errorf("Get(bob) = %+v, %v; want zero usage", u, err)
+	}
+
+	list, err := tracker.List(period)
+	if err != nil || len(list) != 2 || list[0].Subject != "alice" || list[1].Subject != Anonymous || list[1].JobTimeMS != 1500 {
+		t.Errorf("List() = %+v, %v", list, err)
+	}
+	if list, err := tracker.List("2000-01"); err != nil || len(list) != 0 {
+		t.Errorf("List(2000-01) = %+v, %v; want none", list, err)
+	}
+}
+
+func TestCheckQuotas(t *testing.T) {
+	tracker := openTestTracker(t, "upload=100B/200B, rows=10")
+
+	if warning, err := tracker.Check("alice", MetricUpload); warning != "" || err != nil {
+		t.Errorf("under quota: %q, %v", warning, err)
+	}
+	tracker.Add("alice", MetricUpload, 150)
+	if warning, err := tracker.Check("alice", MetricUpload); warning == "" || err != nil {
+		t.Errorf("over the soft quota: %q, %v; want a warning", warning, err)
+	}
+	tracker.Add("alice", MetricUpload, 50)
+	var quotaErr *QuotaError
+	if _, err := tracker.Check("alice", MetricUpload); !errors.As(err, &quotaErr) || quotaErr.Limit != 200 {
+		t.Errorf("at the hard quota: %v, want a QuotaError", err)
+	}
+	if _, err := tracker.Check("bob", MetricUpload); err != nil {
+		t.Errorf("another subject: %v", err)
+	}
+	if warning, err := tracker.Check("alice", MetricJobTime); warning != "" || err != nil {
+		t.Errorf("metric without a quota: %q, %v", warning, err)
+	}
+
+	if err := tracker.SetConfig(config.UsageConfig{Quotas: "upload=1KB"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tracker.Check("alice", MetricUpload); err != nil {
+		t.Errorf("after raising the quota: %v", err)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	tracker := openTestTracker(t, "rows=5/10")
+	tracker.Add("alice", MetricRows, 5)
+
+	for _, tt := range []struct {
+		added   int64
+		allowed bool
+		status  int
+		warning bool
+	}{
+		{0, true, http.StatusOK, true},
+		{5, false, http.StatusForbidden, false},
+	} {
+		tracker.Add("alice", MetricRows, tt.added)
+		req := httptest.NewRequest(http.MethodPost, "/api/data/export", nil)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "alice"}))
+		rec := httptest.NewRecorder()
+
+		allowed := tracker.Allow(rec, req, MetricRows)
+		if allowed != tt.allowed || rec.Code != tt.status || (rec.Header().Get(WarningHeader) != "") != tt.warning {
+			t.Errorf("after %d more rows: allowed %v, status %d, warning %q", tt.added, allowed, rec.Code, rec.Header().Get(WarningHeader))
+		}
+	}
+}
+
+func TestGetUsage(t *testing.T) {
+	tracker := openTestTracker(t, "")
+	tracker.Add("alice", MetricUpload, 10)
+	tracker.Add("bob", MetricUpload, 20)
+	handler := NewHandler(tracker)
+
+	for _, tt := range []struct {
+		name      string
+		principal *auth.Principal
+		query     string
+		status    int
+		subjects  int
+	}{
+		{"auth disabled", nil, "", http.StatusOK, 2},
+		{"auth disabled, subject", nil, "?subject=bob", http.StatusOK, 1},
+		{"viewer", &auth.Principal{Subject: "alice", Role: auth.RoleViewer}, "", http.StatusOK, 1},
+		{"viewer, other subject", &auth.Principal{Subject: "alice", Role: auth.RoleEditor}, "?subject=bob", http.StatusForbidden, 0},
+		{"admin", &auth.Principal{Subject: "root", Role: auth.RoleAdmin}, "", http.StatusOK, 2},
+		{"invalid period", nil, "?period=2026-13", http.StatusBadRequest, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/usage"+tt.query, nil)
+			if tt.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), tt.principal))
+			}
+			rec := httptest.NewRecorder()
+			handler.GetUsage(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp GetUsageResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Usage) != tt.subjects {
+				t.Errorf("got usage of %d subjects, want %d", len(resp.Usage), tt.subjects)
+			}
+		})
+	}
+}
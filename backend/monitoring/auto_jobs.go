@@ -0,0 +1,390 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bronze-backend/jobs"
+	"bronze-backend/storage"
+)
+
+// AutoJobRule creates a job for every watcher event that matches it. All set
+// conditions must match.
+type AutoJobRule struct {
+	Name     string `json:"name"`
+	Disabled bool   `json:"disabled,omitempty"`
+
+	// Conditions
+	WatchRule  string      `json:"watch_rule,omitempty"`  // Only events from this watch rule
+	Pattern    string      `json:"pattern,omitempty"`     // Key prefix, or glob such as incoming/zips/** or *.csv
+	Extensions []string    `json:"extensions,omitempty"`  // e.g. [".zip", ".tar.gz"]
+	MinSize    int64       `json:"min_size,omitempty"`    // Bytes
+	EventTypes []EventType `json:"event_types,omitempty"` // created, modified or removed; defaults to created
+
+	// Job to create. Parameters become job metadata, e.g. "suite" for
+	// validate jobs or the export settings of an export preset.
+	JobType    string         `json:"job_type"`
+	Priority   string         `json:"priority,omitempty"`
+	Parameters map[string]any `json:"parameters,omitempty"`
+	// ExportPreset creates export jobs that export the object with this
+	// preset, e.g. appending every new CSV to a table
+	ExportPreset string `json:"export_preset,omitempty"`
+
+	// Failure handling. A failed job is retried up to MaxRetries times, the
+	// first retry after RetryBackoff and each next one after twice as long.
+	// With Quarantine, an object whose job failed every retry is tagged and
+	// the rule creates no more jobs for it until it is uploaded again.
+	MaxRetries   int    `json:"max_retries,omitempty"`
+	RetryBackoff string `json:"retry_backoff,omitempty"` // e.g. 30s, default 1m
+	Quarantine   bool   `json:"quarantine,omitempty"`
+}
+
+// maxRuleRetries bounds the retries of a rule's jobs.
+const maxRuleRetries = 10
+
+// eventTypeNames maps the short event names accepted in rules to event types.
+var eventTypeNames = map[string]EventType{
+	"created":  EventCreated,
+	"modified": EventMetadata,
+	"removed":  EventRemoved,
+}
+
+// Validate checks that the rule can be used and expands short event names.
+func (r *AutoJobRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule name is required")
+	}
+	if r.ExportPreset != "" {
+		if r.JobType == "" {
+			r.JobType = "export"
+		} else if r.JobType != "export" {
+			return fmt.Errorf("export_preset requires job_type export, not %s", r.JobType)
+		}
+	}
+	if r.JobType == "" {
+		return fmt.Errorf("job_type is required")
+	}
+	if r.MinSize < 0 {
+		return fmt.Errorf("min_size must not be negative")
+	}
+	if r.Pattern != "" {
+		if _, err := path.Match(strings.TrimSuffix(r.Pattern, "/**"), ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", r.Pattern, err)
+		}
+	}
+	for i, eventType := range r.EventTypes {
+		if full, ok := eventTypeNames[string(eventType)]; ok {
+			r.EventTypes[i] = full
+		} else if eventType != EventCreated && eventType != EventMetadata && eventType != EventRemoved {
+			return fmt.Errorf("invalid event type %q. Use: created, modified, removed", eventType)
+		}
+	}
+	if r.Priority != "" && r.Priority != "low" && r.Priority != "medium" && r.Priority != "high" {
+		return fmt.Errorf("invalid priority. Use: high, medium, low")
+	}
+	if r.MaxRetries < 0 || r.MaxRetries > maxRuleRetries {
+		return fmt.Errorf("max_retries must be between 0 and %d", maxRuleRetries)
+	}
+	if r.RetryBackoff != "" {
+		if backoff, err := time.ParseDuration(r.RetryBackoff); err != nil || backoff <= 0 {
+			return fmt.Errorf("invalid retry_backoff %q: use a positive duration such as 30s", r.RetryBackoff)
+		}
+	}
+	return nil
+}
+
+// RetryPolicy returns how the rule's failed jobs are retried.
+func (r *AutoJobRule) RetryPolicy() jobs.RetryPolicy {
+	backoff, _ := time.ParseDuration(r.RetryBackoff)
+	return jobs.RetryPolicy{MaxRetries: r.MaxRetries, Backoff: backoff, Quarantine: r.Quarantine}
+}
+
+// Matches reports whether the event satisfies every condition of the rule.
+func (r *AutoJobRule) Matches(event *FileEvent) bool {
+	if r.Disabled {
+		return false
+	}
+
+	eventTypes := r.EventTypes
+	if len(eventTypes) == 0 {
+		eventTypes = []EventType{EventCreated}
+	}
+	typeMatches := false
+	for _, eventType := range eventTypes {
+		if eventType == event.EventType {
+			typeMatches = true
+			break
+		}
+	}
+	if !typeMatches {
+		return false
+	}
+
+	if r.WatchRule != "" && r.WatchRule != event.Rule {
+		return false
+	}
+	if r.Pattern != "" && !matchKeyPattern(r.Pattern, event.Key) {
+		return false
+	}
+	if len(r.Extensions) > 0 && !hasExtension(event.Key, r.Extensions) {
+		return false
+	}
+	if r.MinSize > 0 && event.Size < r.MinSize {
+		return false
+	}
+	return true
+}
+
+// matchKeyPattern matches an object key against a pattern. A pattern without
+// wildcards is a key prefix; a pattern ending in /** matches everything below
+// the (possibly wildcarded) directory; anything else is matched with
+// path.Match, on the key's base name when the pattern has no slash.
+func matchKeyPattern(pattern, key string) bool {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return strings.HasPrefix(key, pattern)
+	}
+
+	if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+		depth := strings.Count(dir, "/") + 1
+		parts := strings.SplitN(key, "/", depth+1)
+		if len(parts) <= depth {
+			return false
+		}
+		matched, _ := path.Match(dir, strings.Join(parts[:depth], "/"))
+		return matched
+	}
+
+	if !strings.Contains(pattern, "/") {
+		key = path.Base(key)
+	}
+	matched, _ := path.Match(pattern, key)
+	return matched
+}
+
+func hasExtension(key string, extensions []string) bool {
+	lower := strings.ToLower(key)
+	for _, ext := range extensions {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ObjectTags reads the tags of objects.
+type ObjectTags interface {
+	GetFileTags(ctx context.Context, objectName string) (map[string]string, error)
+}
+
+// AutoJobEngine enqueues jobs for watcher events according to its rules.
+// Rules are kept in a JSON file so they survive restarts.
+type AutoJobEngine struct {
+	queue jobs.Queue
+	path  string
+	rules map[string]AutoJobRule
+	tags  ObjectTags // Nil leaves quarantined objects unchecked
+	mu    sync.RWMutex
+}
+
+// NewAutoJobEngine loads the rules saved at path, if any. An empty path keeps
+// rules in memory only.
+func NewAutoJobEngine(queue jobs.Queue, rulesPath string) (*AutoJobEngine, error) {
+	engine := &AutoJobEngine{
+		queue: queue,
+		path:  rulesPath,
+		rules: make(map[string]AutoJobRule),
+	}
+
+	if rulesPath == "" {
+		return engine, nil
+	}
+
+	data, err := os.ReadFile(rulesPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return engine, nil
+		}
+		return nil, fmt.Errorf("failed to read auto-job rules: %w", err)
+	}
+
+	var rules []AutoJobRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to decode auto-job rules: %w", err)
+	}
+	for _, rule := range rules {
+		engine.rules[rule.Name] = rule
+	}
+	return engine, nil
+}
+
+// SetObjectTags lets rules with quarantine skip the objects tagged with
+// jobs.QuarantineTag.
+func (e *AutoJobEngine) SetObjectTags(tags ObjectTags) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.tags = tags
+}
+
+// ListRules returns the rules sorted by name.
+func (e *AutoJobEngine) ListRules() []AutoJobRule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.sortedRules()
+}
+
+// GetRule returns a rule by name.
+func (e *AutoJobEngine) GetRule(name string) (AutoJobRule, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	rule, ok := e.rules[name]
+	return rule, ok
+}
+
+// SaveRule creates or replaces a rule.
+func (e *AutoJobEngine) SaveRule(rule AutoJobRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	previous, existed := e.rules[rule.Name]
+	e.rules[rule.Name] = rule
+	if err := e.persist(); err != nil {
+		if existed {
+			e.rules[rule.Name] = previous
+		} else {
+			delete(e.rules, rule.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteRule removes a rule, reporting whether it existed.
+func (e *AutoJobEngine) DeleteRule(name string) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rule, ok := e.rules[name]
+	if !ok {
+		return false, nil
+	}
+	delete(e.rules, name)
+	if err := e.persist(); err != nil {
+		e.rules[name] = rule
+		return false, err
+	}
+	return true, nil
+}
+
+// HandleEvent enqueues a job for each rule the event matches. Jobs for an
+// object whose ETag has already been processed are not enqueued twice. Jobs
+// that fail are retried as the rule says, then go to the dead-letter queue,
+// as nobody waits on them.
+func (e *AutoJobEngine) HandleEvent(event *FileEvent) {
+	e.mu.RLock()
+	rules := e.sortedRules()
+	tags := e.tags
+	e.mu.RUnlock()
+
+	quarantined := sync.OnceValue(func() bool { return e.quarantined(tags, event) })
+	for _, rule := range rules {
+		if !rule.Matches(event) {
+			continue
+		}
+		if rule.Quarantine && quarantined() {
+			log.Printf("Auto-job rule %s skipped %s: the object is quarantined", rule.Name, event.Key)
+			continue
+		}
+
+		job := jobs.NewJob(rule.JobType, event.Key, event.Bucket, event.Key, jobs.ParsePriority(rule.Priority))
+		job.ETag = event.ETag
+		for key, value := range rule.Parameters {
+			job.Metadata[key] = value
+		}
+		if rule.ExportPreset != "" {
+			job.Metadata["preset"] = rule.ExportPreset
+		}
+		job.Metadata["auto_job_rule"] = rule.Name
+		job.Metadata["event_id"] = event.ID
+		job.Metadata[jobs.MetadataDeadLetter] = true
+		rule.RetryPolicy().Apply(job)
+
+		queued, duplicate, err := jobs.EnqueueUnique(e.queue, job, false)
+		switch {
+		case err != nil:
+			log.Printf("Auto-job rule %s failed to enqueue %s job for %s: %v", rule.Name, rule.JobType, event.Key, err)
+		case duplicate:
+			log.Printf("Auto-job rule %s skipped %s: identical job %s exists", rule.Name, event.Key, queued.ID)
+		default:
+			log.Printf("Auto-job rule %s created %s job %s for %s", rule.Name, rule.JobType, queued.ID, event.Key)
+		}
+	}
+}
+
+// quarantined reports whether the event's object is tagged as quarantined.
+func (e *AutoJobEngine) quarantined(tags ObjectTags, event *FileEvent) bool {
+	if tags == nil || event.EventType == EventRemoved {
+		return false
+	}
+	ctx := storage.WithBucket(context.Background(), event.Bucket)
+	objectTags, err := tags.GetFileTags(ctx, event.Key)
+	if err != nil {
+		log.Printf("Warning: Failed to read the tags of %s: %v", event.Key, err)
+		return false
+	}
+	_, ok := objectTags[jobs.QuarantineTag]
+	return ok
+}
+
+func (e *AutoJobEngine) sortedRules() []AutoJobRule {
+	rules := make([]AutoJobRule, 0, len(e.rules))
+	for _, rule := range e.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules
+}
+
+// persist writes the rules to the rules file. Callers hold e.mu.
+func (e *AutoJobEngine) persist() error {
+	if e.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(e.sortedRules(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode auto-job rules: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(e.path), 0755); err != nil {
+		return fmt.Errorf("failed to create auto-job rules directory: %w", err)
+	}
+
+	tmpPath := e.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write auto-job rules: %w", err)
+	}
+	if err := os.Rename(tmpPath, e.path); err != nil {
+		return fmt.Errorf("failed to replace auto-job rules: %w", err)
+	}
+	return nil
+}
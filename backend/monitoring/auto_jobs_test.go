@@ -0,0 +1,142 @@
+package monitoring
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bronze-backend/jobs"
+)
+
+func TestMatchKeyPattern(t *testing.T) {
+	cases := []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"incoming/zips/", "incoming/zips/a.zip", true},
+		{"incoming/zips/", "incoming/other/a.zip", false},
+		{"incoming/zips/**", "incoming/zips/2024/a.zip", true},
+		{"incoming/*/**", "incoming/zips/a.zip", true},
+		{"incoming/zips/**", "incoming/zips", false},
+		{"*.csv", "reports/2024/q1.csv", true},
+		{"reports/*.csv", "reports/2024/q1.csv", false},
+	}
+	for _, c := range cases {
+		if got := matchKeyPattern(c.pattern, c.key); got != c.want {
+			t.Errorf("matchKeyPattern(%q, %q) = %v, want %v", c.pattern, c.key, got, c.want)
+		}
+	}
+}
+
+func TestAutoJobEngineCreatesJobs(t *testing.T) {
+	queue := jobs.NewJobQueue(1, 10)
+	rulesPath := filepath.Join(t.TempDir(), "auto_jobs.json")
+
+	engine, err := NewAutoJobEngine(queue, rulesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = engine.SaveRule(AutoJobRule{
+		Name:       "zips",
+		Pattern:    "incoming/zips/**",
+		Extensions: []string{"zip"},
+		MinSize:    10,
+		EventTypes: []EventType{"created"},
+		JobType:    "extract",
+		Priority:   "high",
+		Parameters: map[string]any{"streaming": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule := WatchRule{Name: DefaultRuleName, Bucket: "files"}
+	event := newFileEvent(rule, "incoming/zips/a.zip", EventCreated, time.Now())
+	event.Size = 100
+	event.ETag = "abc"
+	engine.HandleEvent(event)
+	engine.HandleEvent(event) // Same ETag, deduplicated
+
+	small := newFileEvent(rule, "incoming/zips/b.zip", EventCreated, event.EventTime)
+	small.Size = 1
+	engine.HandleEvent(small)
+
+	queued := queue.ListJobs()
+	if len(queued) != 1 {
+		t.Fatalf("queued %d jobs, want 1", len(queued))
+	}
+	job := queued[0]
+	if job.Type != "extract" || job.Priority != jobs.PriorityHigh || job.Metadata["streaming"] != true || job.Metadata["auto_job_rule"] != "zips" {
+		t.Errorf("unexpected job: %+v", job)
+	}
+
+	// Rules are reloaded from disk
+	reloaded, err := NewAutoJobEngine(queue, rulesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved, ok := reloaded.GetRule("zips"); !ok || saved.EventTypes[0] != EventCreated {
+		t.Errorf("rule not persisted: %+v", saved)
+	}
+}
+
+func TestAutoJobRuleExportPreset(t *testing.T) {
+	queue := jobs.NewJobQueue(1, 10)
+	engine, err := NewAutoJobEngine(queue, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.SaveRule(AutoJobRule{Name: "sales", ExportPreset: "sales_raw", JobType: "extract"}); err == nil {
+		t.Error("export_preset was accepted with job_type extract")
+	}
+	if err := engine.SaveRule(AutoJobRule{Name: "sales", Pattern: "incoming/sales/", Extensions: []string{".csv"}, ExportPreset: "sales_raw"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rule := WatchRule{Name: DefaultRuleName, Bucket: "files"}
+	engine.HandleEvent(newFileEvent(rule, "incoming/sales/2026-10.csv", EventCreated, time.Now()))
+	engine.HandleEvent(newFileEvent(rule, "incoming/sales/notes.txt", EventCreated, time.Now()))
+
+	queued := queue.ListJobs()
+	if len(queued) != 1 {
+		t.Fatalf("queued %d jobs, want 1", len(queued))
+	}
+	job := queued[0]
+	if job.Type != "export" || job.Metadata["preset"] != "sales_raw" || job.Metadata[jobs.MetadataDeadLetter] != true {
+		t.Errorf("unexpected job: %+v", job)
+	}
+}
+
+type fakeTags map[string]map[string]string
+
+func (f fakeTags) GetFileTags(_ context.Context, objectName string) (map[string]string, error) {
+	return f[objectName], nil
+}
+
+func TestAutoJobRuleRetryAndQuarantine(t *testing.T) {
+	queue := jobs.NewJobQueue(1, 10)
+	engine, err := NewAutoJobEngine(queue, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetObjectTags(fakeTags{"in/bad.csv": {jobs.QuarantineTag: "job-1"}})
+	if err := engine.SaveRule(AutoJobRule{Name: "csv", JobType: "validate", RetryBackoff: "soon"}); err == nil {
+		t.Error("invalid retry_backoff was accepted")
+	}
+	if err := engine.SaveRule(AutoJobRule{Name: "csv", Pattern: "in/", JobType: "validate", MaxRetries: 3, RetryBackoff: "30s", Quarantine: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	rule := WatchRule{Name: DefaultRuleName, Bucket: "files"}
+	engine.HandleEvent(newFileEvent(rule, "in/bad.csv", EventCreated, time.Now()))
+	engine.HandleEvent(newFileEvent(rule, "in/good.csv", EventCreated, time.Now()))
+
+	queued := queue.ListJobs()
+	if len(queued) != 1 || queued[0].ObjectName != "in/good.csv" {
+		t.Fatalf("queued %+v, want only in/good.csv", queued)
+	}
+	if job := queued[0]; job.Metadata[jobs.MetadataMaxRetries] != 3 || job.Metadata[jobs.MetadataRetryBackoff] != "30s" || job.Metadata[jobs.MetadataQuarantine] != true {
+		t.Errorf("retry policy not applied: %v", job.Metadata)
+	}
+}
@@ -0,0 +1,180 @@
+package monitoring
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var ErrBackfillRunning = errors.New("backfill already running")
+
+// BackfillStatus is the state of a backfill
+type BackfillStatus string
+
+const (
+	BackfillRunning   BackfillStatus = "running"
+	BackfillCompleted BackfillStatus = "completed"
+	BackfillFailed    BackfillStatus = "failed"
+	BackfillCancelled BackfillStatus = "cancelled"
+)
+
+// BackfillProgress reports how far a backfill has got. The total is not known
+// up front: objects are counted as the bucket is listed.
+type BackfillProgress struct {
+	Rule        string         `json:"rule"`
+	Status      BackfillStatus `json:"status"`
+	Scanned     int            `json:"scanned"` // Objects listed so far
+	Emitted     int            `json:"emitted"` // Created events emitted
+	Skipped     int            `json:"skipped"` // Objects already in the event history, or filtered out
+	Error       string         `json:"error,omitempty"`
+	StartedAt   time.Time      `json:"started_at"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+}
+
+// StartBackfill lists the objects already under a watch rule and emits a
+// created event for each one that has no event yet, so enabling the watcher
+// on a populated bucket still feeds auto-jobs and webhooks. It runs in the
+// background; progress is available from GetBackfill.
+func (fw *FileWatcher) StartBackfill(name string) (BackfillProgress, error) {
+	rule, ok := fw.rule(name)
+	if !ok {
+		return BackfillProgress{}, fmt.Errorf("%w: %s", ErrRuleNotFound, name)
+	}
+
+	fw.backfillMu.Lock()
+	defer fw.backfillMu.Unlock()
+
+	if current, ok := fw.backfills[name]; ok && current.Status == BackfillRunning {
+		return *current, fmt.Errorf("%w for rule %s", ErrBackfillRunning, name)
+	}
+
+	progress := &BackfillProgress{
+		Rule:      name,
+		Status:    BackfillRunning,
+		StartedAt: time.Now(),
+	}
+	fw.backfills[name] = progress
+
+	fw.wg.Add(1)
+	go fw.runBackfill(rule, progress)
+
+	log.Printf("Backfill started for rule %s (%s/%s)", name, rule.Bucket, rule.Prefix)
+	return *progress, nil
+}
+
+// GetBackfill returns the progress of the last backfill of a watch rule.
+func (fw *FileWatcher) GetBackfill(name string) (BackfillProgress, bool) {
+	fw.backfillMu.Lock()
+	defer fw.backfillMu.Unlock()
+
+	progress, ok := fw.backfills[name]
+	if !ok {
+		return BackfillProgress{}, false
+	}
+	return *progress, true
+}
+
+func (fw *FileWatcher) runBackfill(rule WatchRule, progress *BackfillProgress) {
+	defer fw.wg.Done()
+
+	objectsCh := fw.client.ListObjects(fw.ctx, rule.Bucket, minio.ListObjectsOptions{
+		Prefix:       rule.Prefix,
+		Recursive:    true,
+		WithMetadata: true,
+	})
+
+	var err error
+	for object := range objectsCh {
+		if object.Err != nil {
+			err = object.Err
+			break
+		}
+
+		var emitted bool
+		emitted, err = fw.backfillObject(rule, object)
+		if err != nil {
+			break
+		}
+		fw.updateBackfill(progress, func(p *BackfillProgress) {
+			p.Scanned++
+			if emitted {
+				p.Emitted++
+			} else {
+				p.Skipped++
+			}
+		})
+	}
+
+	var final BackfillProgress
+	fw.updateBackfill(progress, func(p *BackfillProgress) {
+		now := time.Now()
+		p.CompletedAt = &now
+		switch {
+		case fw.ctx.Err() != nil:
+			p.Status = BackfillCancelled
+		case err != nil:
+			p.Status = BackfillFailed
+			p.Error = err.Error()
+		default:
+			p.Status = BackfillCompleted
+		}
+		final = *p
+	})
+
+	log.Printf("Backfill for rule %s %s: %d scanned, %d emitted, %d skipped",
+		rule.Name, final.Status, final.Scanned, final.Emitted, final.Skipped)
+}
+
+// backfillObject emits a created event for an existing object, unless an
+// event for this version of it is already stored. It reports whether an
+// event was emitted.
+func (fw *FileWatcher) backfillObject(rule WatchRule, object minio.ObjectInfo) (bool, error) {
+	filter := fw.ruleFilter(rule.Name)
+	if !filter.AllowsKey(object.Key) {
+		return false, nil
+	}
+
+	seen, err := fw.storage.HasEvent(rule.Bucket, object.Key, object.ETag)
+	if err != nil {
+		return false, fmt.Errorf("failed to check event history for %s: %w", object.Key, err)
+	}
+	if seen {
+		return false, nil
+	}
+
+	event := newFileEvent(rule, object.Key, EventCreated, time.Now())
+	event.Size = object.Size
+	event.ETag = object.ETag
+	event.Metadata = make(map[string]string)
+	for k, v := range object.UserMetadata {
+		event.Metadata[k] = v
+	}
+	if object.ContentType != "" {
+		event.Metadata["Content-Type"] = object.ContentType
+	}
+
+	return fw.emit(event), nil
+}
+
+func (fw *FileWatcher) updateBackfill(progress *BackfillProgress, update func(*BackfillProgress)) {
+	fw.backfillMu.Lock()
+	defer fw.backfillMu.Unlock()
+
+	update(progress)
+}
+
+// rule returns the named watch rule.
+func (fw *FileWatcher) rule(name string) (WatchRule, bool) {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+
+	for _, rule := range fw.rules {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return WatchRule{}, false
+}
@@ -0,0 +1,60 @@
+package monitoring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestBackfillObjectSkipsSeenVersions(t *testing.T) {
+	rule := WatchRule{
+		Name:    "landing",
+		Bucket:  "files",
+		Filters: EventFilter{Exclude: []string{"*.tmp"}},
+	}
+	fw := &FileWatcher{
+		storage:     NewMemoryEventStorage(),
+		rules:       []WatchRule{rule},
+		broadcaster: NewEventBroadcaster(),
+	}
+
+	var handled []*FileEvent
+	fw.SetEventHandler(func(event *FileEvent) { handled = append(handled, event) })
+
+	seen := newFileEvent(rule, "data/old.csv", EventCreated, time.Now())
+	seen.ETag = "v1"
+	if err := fw.storage.Store(seen); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		object minio.ObjectInfo
+		want   bool
+	}{
+		{minio.ObjectInfo{Key: "data/old.csv", ETag: "v1", Size: 10}, false},
+		{minio.ObjectInfo{Key: "data/old.csv", ETag: "v2", Size: 12}, true},
+		{minio.ObjectInfo{Key: "data/new.csv", ETag: "v1", Size: 5, ContentType: "text/csv"}, true},
+		{minio.ObjectInfo{Key: "data/upload.tmp", ETag: "v1"}, false},
+	}
+	for _, c := range cases {
+		emitted, err := fw.backfillObject(rule, c.object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if emitted != c.want {
+			t.Errorf("backfillObject(%s %s) = %v, want %v", c.object.Key, c.object.ETag, emitted, c.want)
+		}
+	}
+
+	// A second pass finds every version in the history
+	for _, c := range cases {
+		if emitted, _ := fw.backfillObject(rule, c.object); emitted {
+			t.Errorf("second backfill emitted %s %s again", c.object.Key, c.object.ETag)
+		}
+	}
+
+	if len(handled) != 2 || handled[1].EventType != EventCreated || handled[1].Metadata["Content-Type"] != "text/csv" {
+		t.Fatalf("unexpected events: %+v", handled)
+	}
+}
@@ -0,0 +1,119 @@
+package monitoring
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// eventDebouncer holds events per object until the object has been quiet for
+// the debounce window, then releases one event for the burst. Multipart
+// uploads, copies and overwrites in quick succession thus reach storage and
+// auto-jobs once.
+type eventDebouncer struct {
+	window  time.Duration
+	pending map[string]*pendingEvent
+	mu      sync.Mutex
+}
+
+type pendingEvent struct {
+	event    *FileEvent
+	lastSeen time.Time
+}
+
+func newEventDebouncer(window time.Duration) *eventDebouncer {
+	return &eventDebouncer{
+		window:  window,
+		pending: make(map[string]*pendingEvent),
+	}
+}
+
+// add records an event, merging it with the object's pending event. An
+// object created and then changed within the window stays "created"; one
+// created and removed again produces no event at all.
+func (d *eventDebouncer) add(event *FileEvent, now time.Time) {
+	key := event.Rule + "\x00" + event.Bucket + "\x00" + event.Key
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current, ok := d.pending[key]
+	if !ok {
+		d.pending[key] = &pendingEvent{event: event, lastSeen: now}
+		return
+	}
+
+	switch {
+	case current.event.EventType == EventCreated && event.EventType == EventRemoved:
+		delete(d.pending, key)
+		return
+	case current.event.EventType == EventCreated && event.EventType == EventMetadata:
+		event.EventType = EventCreated
+	}
+	current.event = event
+	current.lastSeen = now
+}
+
+// due removes and returns the events whose object has been quiet for the
+// whole window, oldest first.
+func (d *eventDebouncer) due(now time.Time) []*FileEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var ready []*pendingEvent
+	for key, p := range d.pending {
+		if now.Sub(p.lastSeen) >= d.window {
+			ready = append(ready, p)
+			delete(d.pending, key)
+		}
+	}
+	return sortPending(ready)
+}
+
+// drain removes and returns every pending event, oldest first.
+func (d *eventDebouncer) drain() []*FileEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ready := make([]*pendingEvent, 0, len(d.pending))
+	for key, p := range d.pending {
+		ready = append(ready, p)
+		delete(d.pending, key)
+	}
+	return sortPending(ready)
+}
+
+func sortPending(pending []*pendingEvent) []*FileEvent {
+	sort.Slice(pending, func(i, j int) bool { return pending[i].lastSeen.Before(pending[j].lastSeen) })
+
+	events := make([]*FileEvent, len(pending))
+	for i, p := range pending {
+		events[i] = p.event
+	}
+	return events
+}
+
+// debounceLoop releases debounced events once their object is quiet. Events
+// still pending at shutdown are released by Stop.
+func (fw *FileWatcher) debounceLoop() {
+	defer fw.wg.Done()
+
+	// Check often enough that events wait at most a quarter window extra
+	interval := fw.debouncer.window / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-fw.ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, event := range fw.debouncer.due(now) {
+				fw.deliver(event)
+			}
+		}
+	}
+}
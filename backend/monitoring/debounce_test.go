@@ -0,0 +1,64 @@
+package monitoring
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventDebouncerMergesBursts(t *testing.T) {
+	rule := WatchRule{Name: DefaultRuleName, Bucket: "files"}
+	event := func(key string, eventType EventType, etag string) *FileEvent {
+		e := newFileEvent(rule, key, eventType, time.Now())
+		e.ETag = etag
+		return e
+	}
+
+	d := newEventDebouncer(time.Second)
+	start := time.Now()
+
+	// A multipart upload reported as created, then modified
+	d.add(event("data/big.csv", EventCreated, "part"), start)
+	d.add(event("data/big.csv", EventMetadata, "final"), start.Add(500*time.Millisecond))
+	// A temp object that came and went
+	d.add(event("data/tmp.csv", EventCreated, "a"), start)
+	d.add(event("data/tmp.csv", EventRemoved, ""), start.Add(100*time.Millisecond))
+	d.add(event("data/other.csv", EventRemoved, ""), start.Add(200*time.Millisecond))
+
+	if ready := d.due(start.Add(time.Second)); len(ready) != 0 {
+		t.Fatalf("released %d events before the objects were quiet", len(ready))
+	}
+
+	ready := d.due(start.Add(1200 * time.Millisecond))
+	if len(ready) != 1 || ready[0].Key != "data/other.csv" {
+		t.Fatalf("unexpected events after 1.2s: %+v", ready)
+	}
+
+	ready = d.drain()
+	if len(ready) != 1 || ready[0].Key != "data/big.csv" || ready[0].EventType != EventCreated || ready[0].ETag != "final" {
+		t.Fatalf("unexpected drained events: %+v", ready)
+	}
+}
+
+func TestDeliverDropsDuplicateVersions(t *testing.T) {
+	rule := WatchRule{Name: DefaultRuleName, Bucket: "files"}
+	fw := &FileWatcher{
+		storage:     NewMemoryEventStorage(),
+		rules:       []WatchRule{rule},
+		broadcaster: NewEventBroadcaster(),
+	}
+
+	handled := 0
+	fw.SetEventHandler(func(*FileEvent) { handled++ })
+
+	for _, eventType := range []EventType{EventCreated, EventCreated, EventMetadata} {
+		e := newFileEvent(rule, "data/a.csv", eventType, time.Now())
+		e.ETag = "v1"
+		fw.deliver(e)
+	}
+	fw.deliver(newFileEvent(rule, "data/a.csv", EventRemoved, time.Now()))
+	fw.deliver(newFileEvent(rule, "data/a.csv", EventRemoved, time.Now()))
+
+	if handled != 3 {
+		t.Errorf("handled %d events, want 3 (one created, two removed)", handled)
+	}
+}
@@ -0,0 +1,146 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// EventFilter decides which objects of a watch rule produce events. Name
+// patterns use the same syntax as auto-job rules: a key prefix, or a glob
+// matched on the base name (*.tmp) or the full key (tmp/**). Size and content
+// type filters do not apply to removed objects, whose size and type are gone.
+type EventFilter struct {
+	Include             []string `json:"include,omitempty"` // Only keys matching one of these
+	Exclude             []string `json:"exclude,omitempty"` // Never keys matching one of these
+	MinSize             int64    `json:"min_size,omitempty"`
+	MaxSize             int64    `json:"max_size,omitempty"`      // 0 is unlimited
+	ContentTypes        []string `json:"content_types,omitempty"` // Prefixes, e.g. "text/"
+	ExcludeContentTypes []string `json:"exclude_content_types,omitempty"`
+}
+
+// Validate checks the filter's patterns and sizes.
+func (f *EventFilter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(strings.TrimSuffix(pattern, "/**"), ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if f.MinSize < 0 || f.MaxSize < 0 {
+		return fmt.Errorf("sizes must not be negative")
+	}
+	if f.MaxSize > 0 && f.MinSize > f.MaxSize {
+		return fmt.Errorf("min_size is larger than max_size")
+	}
+	return nil
+}
+
+// AllowsKey applies the name filters only, so polling can skip objects
+// before fetching their metadata.
+func (f *EventFilter) AllowsKey(key string) bool {
+	for _, pattern := range f.Exclude {
+		if matchKeyPattern(pattern, key) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, pattern := range f.Include {
+		if matchKeyPattern(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows applies every filter to the event.
+func (f *EventFilter) Allows(event *FileEvent) bool {
+	if !f.AllowsKey(event.Key) {
+		return false
+	}
+	if event.EventType == EventRemoved {
+		return true
+	}
+
+	if event.Size < f.MinSize || (f.MaxSize > 0 && event.Size > f.MaxSize) {
+		return false
+	}
+
+	contentType := strings.ToLower(event.Metadata["Content-Type"])
+	for _, excluded := range f.ExcludeContentTypes {
+		if strings.HasPrefix(contentType, strings.ToLower(excluded)) {
+			return false
+		}
+	}
+	if len(f.ContentTypes) == 0 {
+		return true
+	}
+	for _, allowed := range f.ContentTypes {
+		if strings.HasPrefix(contentType, strings.ToLower(allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleFilter returns the current filter of the named rule.
+func (fw *FileWatcher) ruleFilter(name string) EventFilter {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+
+	for _, rule := range fw.rules {
+		if rule.Name == name {
+			return rule.Filters
+		}
+	}
+	return EventFilter{}
+}
+
+// SetRuleFilters replaces the filters of a watch rule. The change applies to
+// the next event, without restarting the rule.
+func (fw *FileWatcher) SetRuleFilters(name string, filters EventFilter) error {
+	if err := filters.Validate(); err != nil {
+		return err
+	}
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	for i := range fw.rules {
+		if fw.rules[i].Name == name {
+			fw.rules[i].Filters = filters
+			return fw.saveRules()
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrRuleNotFound, name)
+}
+
+// saveRules writes the rules back to the rules file, if the watcher was
+// configured from one, so API changes survive a restart. Callers hold fw.mu.
+func (fw *FileWatcher) saveRules() error {
+	if fw.rulesFile == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(fw.rules, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode watch rules: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fw.rulesFile), 0755); err != nil {
+		return fmt.Errorf("failed to create watch rules directory: %w", err)
+	}
+
+	tmpPath := fw.rulesFile + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write watch rules: %w", err)
+	}
+	if err := os.Rename(tmpPath, fw.rulesFile); err != nil {
+		return fmt.Errorf("failed to replace watch rules: %w", err)
+	}
+	return nil
+}
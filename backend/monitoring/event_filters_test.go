@@ -0,0 +1,39 @@
+package monitoring
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventFilterAllows(t *testing.T) {
+	filter := EventFilter{
+		Exclude:      []string{"*.tmp", "*.keep"},
+		MaxSize:      1000,
+		ContentTypes: []string{"text/", "application/zip"},
+	}
+	rule := WatchRule{Name: DefaultRuleName, Bucket: "files"}
+
+	event := func(key string, eventType EventType, size int64, contentType string) *FileEvent {
+		e := newFileEvent(rule, key, eventType, time.Now())
+		e.Size = size
+		e.Metadata = map[string]string{"Content-Type": contentType}
+		return e
+	}
+
+	cases := []struct {
+		event *FileEvent
+		want  bool
+	}{
+		{event("data/a.csv", EventCreated, 10, "text/csv"), true},
+		{event("data/upload.tmp", EventCreated, 10, "text/csv"), false},
+		{event("data/.keep", EventCreated, 0, "text/plain"), false},
+		{event("data/huge.zip", EventCreated, 5000, "application/zip"), false},
+		{event("data/a.bin", EventCreated, 10, "application/octet-stream"), false},
+		{event("data/huge.zip", EventRemoved, 0, ""), true},
+	}
+	for _, c := range cases {
+		if got := filter.Allows(c.event); got != c.want {
+			t.Errorf("Allows(%s %s) = %v, want %v", c.event.EventType, c.event.Key, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package monitoring
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"bronze-backend/config"
+)
+
+// retentionInterval is how often old processed events are pruned.
+const retentionInterval = time.Hour
+
+// NewEventStorage creates the event storage backend selected in the watcher
+// config.
+func NewEventStorage(cfg config.WatcherConfig) (EventStorage, error) {
+	switch cfg.Storage {
+	case "", config.WatcherStorageMemory:
+		return NewMemoryEventStorage(), nil
+	case config.WatcherStorageSQLite:
+		return NewSQLiteEventStorage(cfg.DBPath)
+	default:
+		return nil, fmt.Errorf("unknown watcher storage: %s", cfg.Storage)
+	}
+}
+
+// retentionLoop deletes processed events once they are older than the
+// retention period, so the event store does not grow without bound.
+func (fw *FileWatcher) retentionLoop() {
+	defer fw.wg.Done()
+
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		fw.prune()
+
+		select {
+		case <-fw.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (fw *FileWatcher) prune() {
+	deleted, err := fw.storage.Prune(time.Now().Add(-fw.retention))
+	if err != nil {
+		log.Printf("Error pruning file events: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Pruned %d processed file events older than %v", deleted, fw.retention)
+	}
+}
@@ -0,0 +1,77 @@
+package monitoring
+
+import (
+	"sync"
+
+	"bronze-backend/realtime"
+)
+
+// subscriberBuffer is how many events a slow stream client may fall behind
+// before further events to it are dropped.
+const subscriberBuffer = 64
+
+// EventBroadcaster fans file events out to live subscribers, such as SSE
+// clients. Publishing never blocks the watcher: a subscriber whose buffer is
+// full misses events rather than stalling everyone else.
+type EventBroadcaster struct {
+	subscribers map[chan *FileEvent]struct{}
+	mu          sync.RWMutex
+}
+
+func NewEventBroadcaster() *EventBroadcaster {
+	return &EventBroadcaster{
+		subscribers: make(map[chan *FileEvent]struct{}),
+	}
+}
+
+// Subscribe returns a channel of new events and a function that ends the
+// subscription and closes the channel.
+func (b *EventBroadcaster) Subscribe() (<-chan *FileEvent, func()) {
+	ch := make(chan *FileEvent, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish sends the event to every subscriber that has room for it.
+func (b *EventBroadcaster) Publish(event *FileEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// SubscriberCount returns the number of live subscribers.
+func (b *EventBroadcaster) SubscriberCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.subscribers)
+}
+
+// SetEventHub publishes every event to the watcher topic of hub, named like
+// the rule webhooks' events, e.g. file.created. Set it before Start.
+func (fw *FileWatcher) SetEventHub(hub *realtime.Hub) {
+	fw.events = hub
+}
+
+// Subscribe streams events as the watcher emits them.
+func (fw *FileWatcher) Subscribe() (<-chan *FileEvent, func()) {
+	return fw.broadcaster.Subscribe()
+}
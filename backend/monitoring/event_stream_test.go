@@ -0,0 +1,36 @@
+package monitoring
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventBroadcaster(t *testing.T) {
+	b := NewEventBroadcaster()
+	events, unsubscribe := b.Subscribe()
+	if b.SubscriberCount() != 1 {
+		t.Fatalf("SubscriberCount() = %d, want 1", b.SubscriberCount())
+	}
+
+	rule := WatchRule{Name: DefaultRuleName, Bucket: "files"}
+	b.Publish(newFileEvent(rule, "data/a.csv", EventCreated, time.Now()))
+	if got := <-events; got.Key != "data/a.csv" {
+		t.Errorf("received %q, want data/a.csv", got.Key)
+	}
+
+	// A full subscriber must not block the publisher
+	for i := 0; i < subscriberBuffer+10; i++ {
+		b.Publish(newFileEvent(rule, "data/b.csv", EventCreated, time.Now()))
+	}
+	if len(events) != subscriberBuffer {
+		t.Errorf("buffered %d events, want %d", len(events), subscriberBuffer)
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if b.SubscriberCount() != 0 {
+		t.Errorf("SubscriberCount() after unsubscribe = %d, want 0", b.SubscriberCount())
+	}
+	for range events {
+	}
+}
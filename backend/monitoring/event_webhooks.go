@@ -0,0 +1,144 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"bronze-backend/jobs"
+)
+
+// redactedSecret replaces webhook secrets in API responses.
+const redactedSecret = "********"
+
+// EventWebhook forwards the events of a watch rule to an external URL. Each
+// delivery is signed like job webhooks, with this webhook's secret, and
+// retried with the settings of WEBHOOK_TIMEOUT and WEBHOOK_MAX_RETRIES.
+type EventWebhook struct {
+	URL        string      `json:"url"`
+	Secret     string      `json:"secret,omitempty"`
+	EventTypes []EventType `json:"event_types,omitempty"` // created, modified or removed; defaults to created and removed
+}
+
+// EventWebhookPayload is POSTed to event webhooks.
+type EventWebhookPayload struct {
+	Event string `json:"event"` // "file.created", "file.modified" or "file.removed"
+	*FileEvent
+}
+
+// Validate checks the URL and expands short event names.
+func (h *EventWebhook) Validate() error {
+	if err := jobs.ValidateCallbackURL(h.URL); err != nil {
+		return fmt.Errorf("invalid webhook URL %q: %w", h.URL, err)
+	}
+	for i, eventType := range h.EventTypes {
+		if full, ok := eventTypeNames[string(eventType)]; ok {
+			h.EventTypes[i] = full
+		} else if eventType != EventCreated && eventType != EventMetadata && eventType != EventRemoved {
+			return fmt.Errorf("invalid event type %q. Use: created, modified, removed", eventType)
+		}
+	}
+	return nil
+}
+
+// Wants reports whether the webhook receives events of this type.
+func (h *EventWebhook) Wants(eventType EventType) bool {
+	if len(h.EventTypes) == 0 {
+		return eventType == EventCreated || eventType == EventRemoved
+	}
+	for _, wanted := range h.EventTypes {
+		if wanted == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookEventName returns the X-Bronze-Event name of an event type, e.g.
+// "file.created".
+func webhookEventName(eventType EventType) string {
+	for name, full := range eventTypeNames {
+		if full == eventType {
+			return "file." + name
+		}
+	}
+	return "file.unknown"
+}
+
+// SetWebhookNotifier enables the webhooks of watch rules. Without it, rule
+// webhooks are ignored.
+func (fw *FileWatcher) SetWebhookNotifier(notifier *jobs.WebhookNotifier) {
+	fw.notifier = notifier
+}
+
+// forward sends the event to the webhooks of its rule. It does not block.
+func (fw *FileWatcher) forward(event *FileEvent) {
+	if fw.notifier == nil {
+		return
+	}
+
+	webhooks := fw.ruleWebhooks(event.Rule)
+	if len(webhooks) == 0 {
+		return
+	}
+
+	name := webhookEventName(event.EventType)
+	body, err := json.Marshal(EventWebhookPayload{Event: name, FileEvent: event})
+	if err != nil {
+		log.Printf("Failed to encode webhook for event %s: %v", event.ID, err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if webhook.Wants(event.EventType) {
+			fw.notifier.Send(webhook.URL, webhook.Secret, name, body)
+		}
+	}
+}
+
+// ruleWebhooks returns the current webhooks of the named rule.
+func (fw *FileWatcher) ruleWebhooks(name string) []EventWebhook {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+
+	for _, rule := range fw.rules {
+		if rule.Name == name {
+			return rule.Webhooks
+		}
+	}
+	return nil
+}
+
+// withoutSecrets returns a copy of the rule safe to show in API responses.
+func (r WatchRule) withoutSecrets() WatchRule {
+	if len(r.Webhooks) == 0 {
+		return r
+	}
+
+	webhooks := make([]EventWebhook, len(r.Webhooks))
+	copy(webhooks, r.Webhooks)
+	for i := range webhooks {
+		if webhooks[i].Secret != "" {
+			webhooks[i].Secret = redactedSecret
+		}
+	}
+	r.Webhooks = webhooks
+	return r
+}
+
+// keepSecrets restores webhook secrets that came back masked from
+// withoutSecrets, taking them from the previous version of the rule.
+func (r *WatchRule) keepSecrets(previous WatchRule) {
+	for i := range r.Webhooks {
+		if r.Webhooks[i].Secret != redactedSecret {
+			continue
+		}
+		r.Webhooks[i].Secret = ""
+		for _, old := range previous.Webhooks {
+			if old.URL == r.Webhooks[i].URL {
+				r.Webhooks[i].Secret = old.Secret
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bronze-backend/config"
+	"bronze-backend/jobs"
+)
+
+func TestRuleWebhooksForwardEvents(t *testing.T) {
+	received := make(chan EventWebhookPayload, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got := r.Header.Get(jobs.WebhookSignatureHeader); got != jobs.SignWebhook("rule-secret", body) {
+			t.Errorf("signature = %q, want %q", got, jobs.SignWebhook("rule-secret", body))
+		}
+
+		var payload EventWebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		if got := r.Header.Get(jobs.WebhookEventHeader); got != payload.Event {
+			t.Errorf("event header = %q, want %q", got, payload.Event)
+		}
+		received <- payload
+	}))
+	defer server.Close()
+
+	rule := WatchRule{
+		Name:     "landing",
+		Bucket:   "files",
+		Webhooks: []EventWebhook{{URL: server.URL, Secret: "rule-secret"}},
+	}
+	if err := rule.normalize(""); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier := jobs.NewWebhookNotifier(config.WebhookConfig{AllowedHosts: "127.0.0.1"})
+	fw := &FileWatcher{rules: []WatchRule{rule}, notifier: notifier}
+
+	// Modified events are not forwarded by default
+	fw.forward(newFileEvent(rule, "data/a.csv", EventMetadata, time.Now()))
+	fw.forward(newFileEvent(rule, "data/a.csv", EventRemoved, time.Now()))
+
+	select {
+	case payload := <-received:
+		if payload.Event != "file.removed" || payload.FileEvent == nil || payload.Key != "data/a.csv" || payload.Rule != "landing" {
+			t.Errorf("unexpected payload: %+v", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	notifier.Stop()
+
+	if len(received) != 0 {
+		t.Errorf("%d unexpected deliveries", len(received))
+	}
+}
+
+func TestWatchRuleWithoutSecrets(t *testing.T) {
+	rule := WatchRule{Name: "landing", Webhooks: []EventWebhook{{URL: "https://example.com", Secret: "s"}}}
+	if got := rule.withoutSecrets().Webhooks[0].Secret; got != redactedSecret {
+		t.Errorf("secret = %q, want it redacted", got)
+	}
+	if rule.Webhooks[0].Secret != "s" {
+		t.Error("withoutSecrets changed the original rule")
+	}
+
+	invalid := EventWebhook{URL: "example.com/hook"}
+	if err := invalid.Validate(); err == nil {
+		t.Error("Validate accepted a relative URL")
+	}
+}
@@ -3,11 +3,15 @@ package monitoring
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"strings"
 	"sync"
 	"time"
 
+	"bronze-backend/jobs"
+	"bronze-backend/realtime"
+
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
@@ -31,6 +35,8 @@ type FileEvent struct {
 	EventType   EventType         `json:"event_type"`
 	EventTime   time.Time         `json:"event_time"`
 	Metadata    map[string]string `json:"metadata,omitempty"`
+	Rule        string            `json:"rule,omitempty"`   // Watch rule that produced the event
+	Labels      map[string]string `json:"labels,omitempty"` // Labels of that rule
 	Processed   bool              `json:"processed"`
 	ProcessedAt *time.Time        `json:"processed_at,omitempty"`
 }
@@ -41,6 +47,11 @@ type EventStorage interface {
 	GetUnprocessed(limit int) ([]*FileEvent, error)
 	MarkProcessed(eventID string) error
 	GetHistory(limit int) ([]*FileEvent, error)
+	// HasEvent reports whether an event was stored for this version of the
+	// object
+	HasEvent(bucket, key, etag string) (bool, error)
+	// Prune deletes processed events older than before
+	Prune(before time.Time) (int, error)
 }
 
 // MemoryEventStorage implements in-memory event storage
@@ -67,11 +78,9 @@ func (m *MemoryEventStorage) GetUnprocessed(limit int) ([]*FileEvent, error) {
 	defer m.mu.RUnlock()
 
 	var unprocessed []*FileEvent
-	count := 0
 	for _, event := range m.events {
-		if !event.Processed && count < limit {
+		if !event.Processed && (limit <= 0 || len(unprocessed) < limit) {
 			unprocessed = append(unprocessed, event)
-			count++
 		}
 	}
 	return unprocessed, nil
@@ -115,20 +124,64 @@ func (m *MemoryEventStorage) GetHistory(limit int) ([]*FileEvent, error) {
 	return allEvents, nil
 }
 
-// FileWatcher watches for file changes in MinIO buckets
+func (m *MemoryEventStorage) HasEvent(bucket, key, etag string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, event := range m.events {
+		if event.Bucket == bucket && event.Key == key && event.ETag == etag {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryEventStorage) Prune(before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := 0
+	for id, event := range m.events {
+		if event.Processed && event.EventTime.Before(before) {
+			delete(m.events, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// FileWatcher watches for file changes in MinIO buckets, one watch rule per
+// bucket and prefix
 type FileWatcher struct {
-	client     *minio.Client
-	storage    EventStorage
-	bucketName string
-	ctx        context.Context
-	cancel     context.CancelFunc
-	wg         sync.WaitGroup
+	client  *minio.Client
+	storage EventStorage
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	// Watch rules by name, and the goroutines running them
+	rules         []WatchRule
+	runners       map[string]*ruleRunner
+	rulesFile     string
+	defaultBucket string
+	paused        bool
+	mu            sync.RWMutex
+	controlMu     sync.Mutex // Serializes pausing and rule changes
 
 	// Event handlers
-	onEvent func(*FileEvent)
+	onEvent     func(*FileEvent)
+	broadcaster *EventBroadcaster
+	events      *realtime.Hub
+	notifier    *jobs.WebhookNotifier
+	debouncer   *eventDebouncer // nil when debouncing is off
+
+	// Last backfill of each rule
+	backfills  map[string]*BackfillProgress
+	backfillMu sync.Mutex
 
 	// Configuration
 	pollInterval time.Duration
+	retention    time.Duration
 }
 
 // Config holds configuration for the file watcher
@@ -138,8 +191,18 @@ type Config struct {
 	SecretAccessKey string
 	UseSSL          bool
 	Region          string
-	BucketName      string
-	PollInterval    time.Duration
+	// Rules lists the buckets and prefixes to watch. When empty, a single
+	// rule named "default" is built from BucketName, Prefix and Mode.
+	Rules        []WatchRule
+	RulesFile    string // Where rule changes made through the API are saved
+	BucketName   string
+	Prefix       string
+	PollInterval time.Duration // Default for rules without their own
+	Retention    time.Duration // Processed events older than this are pruned, 0 keeps them
+	Debounce     time.Duration // Quiet period before an object's events are released, 0 disables
+	// Mode is "poll" (list the bucket every PollInterval) or "notify"
+	// (subscribe to MinIO bucket notifications)
+	Mode string
 }
 
 // NewFileWatcher creates a new file watcher
@@ -161,19 +224,52 @@ func NewFileWatcher(config Config, storage EventStorage) (*FileWatcher, error) {
 		return nil, fmt.Errorf("failed to create MinIO client: %w", err)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-
 	if config.PollInterval == 0 {
 		config.PollInterval = 30 * time.Second
 	}
 
+	rules := config.Rules
+	if len(rules) == 0 {
+		rules = []WatchRule{{
+			Name:   DefaultRuleName,
+			Bucket: config.BucketName,
+			Prefix: config.Prefix,
+			Mode:   config.Mode,
+		}}
+	}
+
+	names := make(map[string]bool)
+	for i := range rules {
+		if err := rules[i].normalize(config.BucketName); err != nil {
+			return nil, err
+		}
+		if names[rules[i].Name] {
+			return nil, fmt.Errorf("duplicate watch rule: %s", rules[i].Name)
+		}
+		names[rules[i].Name] = true
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var debouncer *eventDebouncer
+	if config.Debounce > 0 {
+		debouncer = newEventDebouncer(config.Debounce)
+	}
+
 	return &FileWatcher{
-		client:       client,
-		storage:      storage,
-		bucketName:   config.BucketName,
-		ctx:          ctx,
-		cancel:       cancel,
-		pollInterval: config.PollInterval,
+		client:        client,
+		storage:       storage,
+		ctx:           ctx,
+		cancel:        cancel,
+		rules:         rules,
+		runners:       make(map[string]*ruleRunner),
+		rulesFile:     config.RulesFile,
+		defaultBucket: config.BucketName,
+		broadcaster:   NewEventBroadcaster(),
+		backfills:     make(map[string]*BackfillProgress),
+		debouncer:     debouncer,
+		pollInterval:  config.PollInterval,
+		retention:     config.Retention,
 	}, nil
 }
 
@@ -182,59 +278,86 @@ func (fw *FileWatcher) SetEventHandler(handler func(*FileEvent)) {
 	fw.onEvent = handler
 }
 
-// Start starts the file watcher
+// Start starts watching every rule. A rule whose bucket cannot be reached is
+// skipped; Start only fails if no rule could be started.
 func (fw *FileWatcher) Start() error {
-	// Check if bucket exists
-	exists, err := fw.client.BucketExists(fw.ctx, fw.bucketName)
-	if err != nil {
-		return fmt.Errorf("failed to check bucket existence: %w", err)
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	var lastErr error
+	for _, rule := range fw.rules {
+		if err := fw.startRule(rule); err != nil {
+			log.Printf("Warning: Not watching rule %s: %v", rule.Name, err)
+			lastErr = err
+		}
 	}
-	if !exists {
-		return fmt.Errorf("bucket %s does not exist", fw.bucketName)
+	if len(fw.runners) == 0 && lastErr != nil {
+		return lastErr
 	}
 
-	fw.wg.Add(1)
-	go fw.watchLoop()
+	if fw.retention > 0 {
+		fw.wg.Add(1)
+		go fw.retentionLoop()
+	}
 
-	log.Printf("File watcher started for bucket: %s", fw.bucketName)
+	if fw.debouncer != nil {
+		fw.wg.Add(1)
+		go fw.debounceLoop()
+	}
+
+	log.Printf("File watcher started with %d of %d rules", len(fw.runners), len(fw.rules))
 	return nil
 }
 
-// Stop stops the file watcher
+// Stop stops the file watcher and closes its event storage
 func (fw *FileWatcher) Stop() {
 	fw.cancel()
 	fw.wg.Wait()
+
+	// Release debounced events before the storage goes away
+	if fw.debouncer != nil {
+		for _, event := range fw.debouncer.drain() {
+			fw.deliver(event)
+		}
+	}
+
+	if closer, ok := fw.storage.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("Error closing event storage: %v", err)
+		}
+	}
 	log.Println("File watcher stopped")
 }
 
-// watchLoop runs the main watching loop
-func (fw *FileWatcher) watchLoop() {
+// watchLoop polls the rule's bucket and prefix for changes
+func (fw *FileWatcher) watchLoop(r *ruleRunner) {
 	defer fw.wg.Done()
+	defer close(r.done)
 
-	ticker := time.NewTicker(fw.pollInterval)
+	ticker := time.NewTicker(r.rule.PollInterval)
 	defer ticker.Stop()
 
 	// Get initial state
 	lastKnownObjects := make(map[string]string)
-	err := fw.updateObjectState(lastKnownObjects)
+	err := fw.updateObjectState(r, lastKnownObjects)
 	if err != nil {
-		log.Printf("Error getting initial object state: %v", err)
+		log.Printf("Error getting initial object state for rule %s: %v", r.rule.Name, err)
 	}
 
 	for {
 		select {
-		case <-fw.ctx.Done():
+		case <-r.ctx.Done():
 			return
 		case <-ticker.C:
 			currentObjects := make(map[string]string)
-			err := fw.updateObjectState(currentObjects)
+			err := fw.updateObjectState(r, currentObjects)
 			if err != nil {
-				log.Printf("Error updating object state: %v", err)
+				log.Printf("Error updating object state for rule %s: %v", r.rule.Name, err)
 				continue
 			}
 
 			// Detect changes
-			fw.detectChanges(lastKnownObjects, currentObjects)
+			fw.detectChanges(r, lastKnownObjects, currentObjects)
 
 			// Update last known state
 			lastKnownObjects = currentObjects
@@ -242,12 +365,13 @@ func (fw *FileWatcher) watchLoop() {
 	}
 }
 
-// updateObjectState gets the current state of all objects in the bucket
-func (fw *FileWatcher) updateObjectState(state map[string]string) error {
-	ctx, cancel := context.WithTimeout(fw.ctx, 30*time.Second)
+// updateObjectState gets the current state of all objects under the rule
+func (fw *FileWatcher) updateObjectState(r *ruleRunner, state map[string]string) error {
+	ctx, cancel := context.WithTimeout(r.ctx, 30*time.Second)
 	defer cancel()
 
-	objectsCh := fw.client.ListObjects(ctx, fw.bucketName, minio.ListObjectsOptions{
+	objectsCh := fw.client.ListObjects(ctx, r.rule.Bucket, minio.ListObjectsOptions{
+		Prefix:    r.rule.Prefix,
 		Recursive: true,
 	})
 
@@ -262,16 +386,16 @@ func (fw *FileWatcher) updateObjectState(state map[string]string) error {
 }
 
 // detectChanges compares two states and creates events for changes
-func (fw *FileWatcher) detectChanges(oldState, newState map[string]string) {
+func (fw *FileWatcher) detectChanges(r *ruleRunner, oldState, newState map[string]string) {
 	// Check for new and modified objects
 	for key, newETag := range newState {
 		oldETag, exists := oldState[key]
 		if !exists {
 			// New object
-			fw.createObjectEvent(key, EventCreated)
+			fw.createObjectEvent(r, key, EventCreated)
 		} else if oldETag != newETag {
 			// Modified object
-			fw.createObjectEvent(key, EventMetadata)
+			fw.createObjectEvent(r, key, EventMetadata)
 		}
 	}
 
@@ -279,31 +403,30 @@ func (fw *FileWatcher) detectChanges(oldState, newState map[string]string) {
 	for key := range oldState {
 		if _, exists := newState[key]; !exists {
 			// Deleted object
-			fw.createObjectEvent(key, EventRemoved)
+			fw.createObjectEvent(r, key, EventRemoved)
 		}
 	}
 }
 
 // createObjectEvent creates and processes a file event
-func (fw *FileWatcher) createObjectEvent(key string, eventType EventType) {
-	ctx, cancel := context.WithTimeout(fw.ctx, 10*time.Second)
+func (fw *FileWatcher) createObjectEvent(r *ruleRunner, key string, eventType EventType) {
+	// Skip filtered-out names before fetching their metadata
+	filter := fw.ruleFilter(r.rule.Name)
+	if !filter.AllowsKey(key) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.ctx, 10*time.Second)
 	defer cancel()
 
 	// Get object info
-	objInfo, err := fw.client.StatObject(ctx, fw.bucketName, key, minio.StatObjectOptions{})
+	objInfo, err := fw.client.StatObject(ctx, r.rule.Bucket, key, minio.StatObjectOptions{})
 	if err != nil && eventType != EventRemoved {
 		log.Printf("Error getting object info for %s: %v", key, err)
 		return
 	}
 
-	event := &FileEvent{
-		ID:        fmt.Sprintf("%s-%d", key, time.Now().UnixNano()),
-		Bucket:    fw.bucketName,
-		Key:       key,
-		EventType: eventType,
-		EventTime: time.Now(),
-		Processed: false,
-	}
+	event := newFileEvent(r.rule, key, eventType, time.Now())
 
 	if eventType != EventRemoved {
 		event.Size = objInfo.Size
@@ -315,21 +438,81 @@ func (fw *FileWatcher) createObjectEvent(key string, eventType EventType) {
 				event.Metadata[k] = v[0]
 			}
 		}
+		if objInfo.ContentType != "" {
+			event.Metadata["Content-Type"] = objInfo.ContentType
+		}
+	}
+
+	fw.emit(event)
+}
+
+// newFileEvent creates an event for key, labeled with the rule that saw it
+func newFileEvent(rule WatchRule, key string, eventType EventType, eventTime time.Time) *FileEvent {
+	event := &FileEvent{
+		ID:        fmt.Sprintf("%s-%d", key, time.Now().UnixNano()),
+		Bucket:    rule.Bucket,
+		Key:       key,
+		EventType: eventType,
+		EventTime: eventTime,
+		Rule:      rule.Name,
+		Processed: false,
+	}
+	if len(rule.Labels) > 0 {
+		event.Labels = make(map[string]string, len(rule.Labels))
+		for k, v := range rule.Labels {
+			event.Labels[k] = v
+		}
+	}
+	return event
+}
+
+// emit passes an event on to deliver, unless the filters of its rule reject
+// it. With debouncing on, the event is held until its object is quiet. It
+// reports whether the event was accepted.
+func (fw *FileWatcher) emit(event *FileEvent) bool {
+	filter := fw.ruleFilter(event.Rule)
+	if !filter.Allows(event) {
+		return false
+	}
+
+	if fw.debouncer != nil {
+		fw.debouncer.add(event, time.Now())
+		return true
+	}
+	return fw.deliver(event)
+}
+
+// deliver stores an event and hands it to subscribers, rule webhooks and the
+// event handler. An event for an object version that already has one, such
+// as a second notification for the same upload, is dropped. It reports
+// whether the event was stored.
+func (fw *FileWatcher) deliver(event *FileEvent) bool {
+	if event.EventType != EventRemoved && event.ETag != "" {
+		seen, err := fw.storage.HasEvent(event.Bucket, event.Key, event.ETag)
+		if err != nil {
+			log.Printf("Error checking event history for %s: %v", event.Key, err)
+		} else if seen {
+			return false
+		}
 	}
 
-	// Store event
-	err = fw.storage.Store(event)
+	err := fw.storage.Store(event)
 	if err != nil {
 		log.Printf("Error storing event: %v", err)
-		return
+		return false
 	}
 
+	fw.broadcaster.Publish(event)
+	fw.events.Publish(realtime.TopicWatcher, webhookEventName(event.EventType), event)
+	fw.forward(event)
+
 	// Call event handler if set
 	if fw.onEvent != nil {
 		fw.onEvent(event)
 	}
 
-	log.Printf("File event created: %s - %s", eventType, key)
+	log.Printf("File event created: %s - %s/%s (rule: %s)", event.EventType, event.Bucket, event.Key, event.Rule)
+	return true
 }
 
 // GetUnprocessedEvents returns unprocessed events
@@ -2,13 +2,21 @@ package monitoring
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bronze-backend/httputil"
 )
 
 // WatcherHandler handles file watcher related requests
 type WatcherHandler struct {
-	watcher *FileWatcher
+	watcher  *FileWatcher
+	autoJobs *AutoJobEngine
 }
 
 // NewWatcherHandler creates a new watcher handler
@@ -18,6 +26,16 @@ func NewWatcherHandler(fileWatcher *FileWatcher) *WatcherHandler {
 	}
 }
 
+// SetAutoJobs enables the auto-job rules API
+func (h *WatcherHandler) SetAutoJobs(engine *AutoJobEngine) {
+	h.autoJobs = engine
+}
+
+// Enabled reports whether the file watcher is running
+func (h *WatcherHandler) Enabled() bool {
+	return h != nil && h.watcher != nil
+}
+
 // GetUnprocessedEvents returns unprocessed file events
 func (h *WatcherHandler) GetUnprocessedEvents(w http.ResponseWriter, r *http.Request) {
 	if h.watcher == nil {
@@ -43,7 +61,7 @@ func (h *WatcherHandler) GetUnprocessedEvents(w http.ResponseWriter, r *http.Req
 
 	events, err := h.watcher.GetUnprocessedEvents(limit)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httputil.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -81,7 +99,7 @@ func (h *WatcherHandler) GetEventHistory(w http.ResponseWriter, r *http.Request)
 
 	events, err := h.watcher.GetEventHistory(limit)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httputil.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -111,18 +129,18 @@ func (h *WatcherHandler) MarkEventProcessed(w http.ResponseWriter, r *http.Reque
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		httputil.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
 	if request.EventID == "" {
-		http.Error(w, "event_id is required", http.StatusBadRequest)
+		httputil.Error(w, "event_id is required", http.StatusBadRequest)
 		return
 	}
 
 	err := h.watcher.MarkEventProcessed(request.EventID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httputil.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -134,3 +152,373 @@ func (h *WatcherHandler) MarkEventProcessed(w http.ResponseWriter, r *http.Reque
 		"message": "Event marked as processed",
 	})
 }
+
+// GetRules returns the watch rules
+func (h *WatcherHandler) GetRules(w http.ResponseWriter, r *http.Request) {
+	if h.watcher == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]any{
+			"error": "File watcher is not available",
+			"rules": []interface{}{},
+			"count": 0,
+		})
+		return
+	}
+
+	rules := h.watcher.GetRules()
+	for i := range rules {
+		rules[i] = rules[i].withoutSecrets()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// streamHeartbeat keeps idle event streams alive through proxies.
+const streamHeartbeat = 15 * time.Second
+
+// StreamEvents pushes file events to the client as Server-Sent Events while
+// the connection stays open. ?rule= limits the stream to one watch rule.
+func (h *WatcherHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	if h.watcher == nil {
+		h.writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error": "File watcher is not available",
+		})
+		return
+	}
+
+	// The server's write timeout would cut the stream off
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		httputil.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rule := r.URL.Query().Get("rule")
+	events, unsubscribe := h.watcher.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if rule != "" && event.Rule != rule {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: file_event\ndata: %s\n\n", event.ID, data)
+		}
+
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// GetStatus reports whether the watcher is paused and which rules are running
+func (h *WatcherHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	if h.watcher == nil {
+		h.writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error": "File watcher is not available",
+		})
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, h.watcher.Status())
+}
+
+// Pause stops watching every rule until Resume
+func (h *WatcherHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	if h.watcher == nil {
+		h.writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error": "File watcher is not available",
+		})
+		return
+	}
+
+	h.watcher.Pause()
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"status":  "success",
+		"message": "File watcher paused",
+		"watcher": h.watcher.Status(),
+	})
+}
+
+// Resume starts watching every rule again
+func (h *WatcherHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	if h.watcher == nil {
+		h.writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error": "File watcher is not available",
+		})
+		return
+	}
+
+	h.watcher.Resume()
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"status":  "success",
+		"message": "File watcher resumed",
+		"watcher": h.watcher.Status(),
+	})
+}
+
+// SaveRule adds or replaces the watch rule named in the path and starts
+// watching it
+func (h *WatcherHandler) SaveRule(w http.ResponseWriter, r *http.Request) {
+	if h.watcher == nil {
+		h.writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error": "File watcher is not available",
+		})
+		return
+	}
+
+	var rule WatchRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		httputil.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	rule.Name = mux.Vars(r)["name"]
+
+	if err := h.watcher.SaveRule(rule); err != nil {
+		if errors.Is(err, ErrRuleInvalid) {
+			httputil.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			httputil.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	saved, _ := h.watcher.rule(rule.Name)
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"status":  "success",
+		"message": "Watch rule saved",
+		"rule":    saved.withoutSecrets(),
+	})
+}
+
+// DeleteRule stops watching the watch rule named in the path and deletes it
+func (h *WatcherHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	if h.watcher == nil {
+		h.writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error": "File watcher is not available",
+		})
+		return
+	}
+
+	if err := h.watcher.RemoveRule(mux.Vars(r)["name"]); err != nil {
+		if errors.Is(err, ErrRuleNotFound) {
+			httputil.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			httputil.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Watch rule deleted",
+	})
+}
+
+// UpdateRuleFilters replaces the event filters of the watch rule named in
+// the path
+func (h *WatcherHandler) UpdateRuleFilters(w http.ResponseWriter, r *http.Request) {
+	if h.watcher == nil {
+		h.writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error": "File watcher is not available",
+		})
+		return
+	}
+
+	var filters EventFilter
+	if err := json.NewDecoder(r.Body).Decode(&filters); err != nil {
+		httputil.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if err := h.watcher.SetRuleFilters(name, filters); err != nil {
+		switch {
+		case errors.Is(err, ErrRuleNotFound):
+			httputil.Error(w, err.Error(), http.StatusNotFound)
+		case filters.Validate() != nil:
+			httputil.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			httputil.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"status":  "success",
+		"message": "Watch rule filters updated",
+		"filters": filters,
+	})
+}
+
+// StartBackfill starts emitting created events for the objects already under
+// the watch rule named in the path
+func (h *WatcherHandler) StartBackfill(w http.ResponseWriter, r *http.Request) {
+	if h.watcher == nil {
+		h.writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error": "File watcher is not available",
+		})
+		return
+	}
+
+	progress, err := h.watcher.StartBackfill(mux.Vars(r)["name"])
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRuleNotFound):
+			httputil.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrBackfillRunning):
+			h.writeJSON(w, http.StatusConflict, map[string]any{
+				"error":    err.Error(),
+				"backfill": progress,
+			})
+		default:
+			httputil.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusAccepted, map[string]any{
+		"status":   "success",
+		"message":  "Backfill started",
+		"backfill": progress,
+	})
+}
+
+// GetBackfill returns the progress of the last backfill of the watch rule
+// named in the path
+func (h *WatcherHandler) GetBackfill(w http.ResponseWriter, r *http.Request) {
+	if h.watcher == nil {
+		h.writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error": "File watcher is not available",
+		})
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	progress, ok := h.watcher.GetBackfill(name)
+	if !ok {
+		httputil.Error(w, "No backfill has run for rule "+name, http.StatusNotFound)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"backfill": progress,
+	})
+}
+
+// ListAutoJobRules returns the rules that turn watcher events into jobs
+func (h *WatcherHandler) ListAutoJobRules(w http.ResponseWriter, r *http.Request) {
+	if h.autoJobs == nil {
+		h.writeJSON(w, http.StatusServiceUnavailable, map[string]any{
+			"error": "Auto-job rules are not available",
+			"rules": []interface{}{},
+			"count": 0,
+		})
+		return
+	}
+
+	rules := h.autoJobs.ListRules()
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// SaveAutoJobRule creates or replaces the auto-job rule named in the path
+func (h *WatcherHandler) SaveAutoJobRule(w http.ResponseWriter, r *http.Request) {
+	if h.autoJobs == nil {
+		h.writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error": "Auto-job rules are not available",
+		})
+		return
+	}
+
+	var rule AutoJobRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		httputil.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	rule.Name = mux.Vars(r)["name"]
+
+	if err := rule.Validate(); err != nil {
+		httputil.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.autoJobs.SaveRule(rule); err != nil {
+		httputil.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"status":  "success",
+		"message": "Auto-job rule saved",
+		"rule":    rule,
+	})
+}
+
+// DeleteAutoJobRule removes the auto-job rule named in the path
+func (h *WatcherHandler) DeleteAutoJobRule(w http.ResponseWriter, r *http.Request) {
+	if h.autoJobs == nil {
+		h.writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error": "Auto-job rules are not available",
+		})
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	deleted, err := h.autoJobs.DeleteRule(name)
+	if err != nil {
+		httputil.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		httputil.Error(w, "Auto-job rule not found", http.StatusNotFound)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Auto-job rule deleted",
+	})
+}
+
+func (h *WatcherHandler) writeJSON(w http.ResponseWriter, statusCode int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,91 @@
+package monitoring
+
+import (
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/notification"
+)
+
+// Watcher modes
+const (
+	ModePoll   = "poll"
+	ModeNotify = "notify"
+)
+
+// notifyRetryDelay is how long the watcher waits before resubscribing after
+// the notification stream drops.
+const notifyRetryDelay = 5 * time.Second
+
+// notifyLoop subscribes to bucket notifications, so events arrive as objects
+// change instead of on the next full listing. The subscription is renewed
+// whenever the stream drops.
+func (fw *FileWatcher) notifyLoop(r *ruleRunner) {
+	defer fw.wg.Done()
+	defer close(r.done)
+
+	events := []string{string(EventCreated), string(EventRemoved)}
+	for {
+		infoCh := fw.client.ListenBucketNotification(r.ctx, r.rule.Bucket, r.rule.Prefix, "", events)
+		for info := range infoCh {
+			if info.Err != nil {
+				log.Printf("Bucket notification error for rule %s: %v", r.rule.Name, info.Err)
+				break
+			}
+			for _, record := range info.Records {
+				if event := notificationEvent(r.rule, record); event != nil {
+					fw.emit(event)
+				}
+			}
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(notifyRetryDelay):
+			log.Printf("Resubscribing to bucket notifications for rule %s", r.rule.Name)
+		}
+	}
+}
+
+// notificationEvent converts a notification record to a file event, or
+// returns nil for event kinds the watcher does not track.
+func notificationEvent(rule WatchRule, record notification.Event) *FileEvent {
+	var eventType EventType
+	switch {
+	case strings.HasPrefix(record.EventName, "s3:ObjectCreated:"):
+		eventType = EventCreated
+	case strings.HasPrefix(record.EventName, "s3:ObjectRemoved:"):
+		eventType = EventRemoved
+	default:
+		return nil
+	}
+
+	// Object keys arrive URL-encoded
+	key, err := url.QueryUnescape(record.S3.Object.Key)
+	if err != nil {
+		key = record.S3.Object.Key
+	}
+
+	eventTime, err := time.Parse(time.RFC3339Nano, record.EventTime)
+	if err != nil {
+		eventTime = time.Now()
+	}
+
+	event := newFileEvent(rule, key, eventType, eventTime)
+	if eventType != EventRemoved {
+		event.Size = record.S3.Object.Size
+		event.ETag = record.S3.Object.ETag
+		event.Metadata = make(map[string]string)
+		for k, v := range record.S3.Object.UserMetadata {
+			event.Metadata[k] = v
+		}
+		if record.S3.Object.ContentType != "" {
+			event.Metadata["Content-Type"] = record.S3.Object.ContentType
+		}
+	}
+
+	return event
+}
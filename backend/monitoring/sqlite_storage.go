@@ -0,0 +1,158 @@
+package monitoring
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS file_events (
+	id           TEXT PRIMARY KEY,
+	bucket       TEXT NOT NULL,
+	object_key   TEXT NOT NULL,
+	etag         TEXT NOT NULL DEFAULT '',
+	event_type   TEXT NOT NULL,
+	event_time   INTEGER NOT NULL,
+	processed    INTEGER NOT NULL DEFAULT 0,
+	processed_at INTEGER,
+	data         TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS file_events_unprocessed ON file_events (processed, event_time);
+CREATE INDEX IF NOT EXISTS file_events_time ON file_events (event_time);
+CREATE INDEX IF NOT EXISTS file_events_object ON file_events (bucket, object_key, etag);
+`
+
+// SQLiteEventStorage keeps file events in a SQLite database, so unprocessed
+// events survive restarts. The full event is stored as JSON; the columns
+// next to it exist for querying.
+type SQLiteEventStorage struct {
+	db *sql.DB
+}
+
+// NewSQLiteEventStorage opens (or creates) the event database at path.
+func NewSQLiteEventStorage(path string) (*SQLiteEventStorage, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create event database directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event database: %w", err)
+	}
+	// SQLite allows one writer at a time; a single connection avoids
+	// SQLITE_BUSY between the watcher and API handlers
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create event schema: %w", err)
+	}
+
+	return &SQLiteEventStorage{db: db}, nil
+}
+
+func (s *SQLiteEventStorage) Store(event *FileEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO file_events
+		(id, bucket, object_key, etag, event_type, event_time, processed, processed_at, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.ID, event.Bucket, event.Key, event.ETag, string(event.EventType),
+		event.EventTime.UnixNano(), event.Processed, unixNanoOrNil(event.ProcessedAt), string(data))
+	return err
+}
+
+func (s *SQLiteEventStorage) GetUnprocessed(limit int) ([]*FileEvent, error) {
+	return s.query(`SELECT data, processed, processed_at FROM file_events
+		WHERE processed = 0 ORDER BY event_time ASC LIMIT ?`, sqlLimit(limit))
+}
+
+func (s *SQLiteEventStorage) MarkProcessed(eventID string) error {
+	_, err := s.db.Exec(`UPDATE file_events SET processed = 1, processed_at = ? WHERE id = ?`,
+		time.Now().UnixNano(), eventID)
+	return err
+}
+
+func (s *SQLiteEventStorage) GetHistory(limit int) ([]*FileEvent, error) {
+	return s.query(`SELECT data, processed, processed_at FROM file_events
+		ORDER BY event_time DESC LIMIT ?`, sqlLimit(limit))
+}
+
+func (s *SQLiteEventStorage) HasEvent(bucket, key, etag string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM file_events
+		WHERE bucket = ? AND object_key = ? AND etag = ?)`, bucket, key, etag).Scan(&exists)
+	return exists, err
+}
+
+func (s *SQLiteEventStorage) Prune(before time.Time) (int, error) {
+	result, err := s.db.Exec(`DELETE FROM file_events WHERE processed = 1 AND event_time < ?`, before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
+
+// Close closes the database.
+func (s *SQLiteEventStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteEventStorage) query(query string, args ...any) ([]*FileEvent, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*FileEvent
+	for rows.Next() {
+		var data string
+		var processed bool
+		var processedAt sql.NullInt64
+		if err := rows.Scan(&data, &processed, &processedAt); err != nil {
+			return nil, err
+		}
+
+		var event FileEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, fmt.Errorf("failed to decode event: %w", err)
+		}
+
+		// Processing state is updated in place, not in the JSON
+		event.Processed = processed
+		event.ProcessedAt = nil
+		if processedAt.Valid {
+			at := time.Unix(0, processedAt.Int64)
+			event.ProcessedAt = &at
+		}
+
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+// sqlLimit maps "no limit" (0) to SQLite's unbounded LIMIT -1.
+func sqlLimit(limit int) int {
+	if limit <= 0 {
+		return -1
+	}
+	return limit
+}
+
+func unixNanoOrNil(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,60 @@
+package monitoring
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteEventStorageSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+
+	storage, err := NewSQLiteEventStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := newFileEvent(WatchRule{Name: DefaultRuleName, Bucket: "files"}, "old.csv", EventCreated, time.Now().Add(-48*time.Hour))
+	fresh := newFileEvent(WatchRule{Name: DefaultRuleName, Bucket: "files"}, "new.csv", EventCreated, time.Now())
+	fresh.Metadata = map[string]string{"Content-Type": "text/csv"}
+	for _, event := range []*FileEvent{old, fresh} {
+		if err := storage.Store(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.MarkProcessed(old.ID); err != nil {
+		t.Fatal(err)
+	}
+	storage.Close()
+
+	storage, err = NewSQLiteEventStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+
+	unprocessed, err := storage.GetUnprocessed(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unprocessed) != 1 || unprocessed[0].ID != fresh.ID || unprocessed[0].Metadata["Content-Type"] != "text/csv" {
+		t.Fatalf("unexpected unprocessed events: %+v", unprocessed)
+	}
+
+	deleted, err := storage.Prune(time.Now().Add(-24 * time.Hour))
+	if err != nil || deleted != 1 {
+		t.Fatalf("Prune = %d, %v; want 1 deleted", deleted, err)
+	}
+
+	history, err := storage.GetHistory(10)
+	if err != nil || len(history) != 1 {
+		t.Fatalf("history = %d events, %v; want 1", len(history), err)
+	}
+
+	if seen, err := storage.HasEvent("files", "new.csv", fresh.ETag); err != nil || !seen {
+		t.Errorf("HasEvent(new.csv) = %v, %v; want true", seen, err)
+	}
+	if seen, err := storage.HasEvent("files", "new.csv", "other"); err != nil || seen {
+		t.Errorf("HasEvent(new.csv, other) = %v, %v; want false", seen, err)
+	}
+}
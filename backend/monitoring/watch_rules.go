@@ -0,0 +1,180 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultRuleName names the rule built from the single-bucket settings.
+const DefaultRuleName = "default"
+
+var ErrRuleNotFound = errors.New("watch rule not found")
+
+// WatchRule watches one bucket and prefix. Events it produces carry its name
+// and labels.
+type WatchRule struct {
+	Name         string            `json:"name"`
+	Bucket       string            `json:"bucket"`
+	Prefix       string            `json:"prefix,omitempty"`
+	Mode         string            `json:"mode,omitempty"`          // "poll" (default) or "notify"
+	PollInterval time.Duration     `json:"poll_interval,omitempty"` // Defaults to the watcher's interval
+	Labels       map[string]string `json:"labels,omitempty"`
+	Filters      EventFilter       `json:"filters"`
+	Webhooks     []EventWebhook    `json:"webhooks,omitempty"`
+}
+
+// watchRuleJSON lets poll_interval be written as a duration string ("30s").
+type watchRuleJSON struct {
+	Name         string            `json:"name"`
+	Bucket       string            `json:"bucket"`
+	Prefix       string            `json:"prefix,omitempty"`
+	Mode         string            `json:"mode,omitempty"`
+	PollInterval string            `json:"poll_interval,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Filters      EventFilter       `json:"filters"`
+	Webhooks     []EventWebhook    `json:"webhooks,omitempty"`
+}
+
+func (r WatchRule) MarshalJSON() ([]byte, error) {
+	out := watchRuleJSON{
+		Name:     r.Name,
+		Bucket:   r.Bucket,
+		Prefix:   r.Prefix,
+		Mode:     r.Mode,
+		Labels:   r.Labels,
+		Filters:  r.Filters,
+		Webhooks: r.Webhooks,
+	}
+	if r.PollInterval > 0 {
+		out.PollInterval = r.PollInterval.String()
+	}
+	return json.Marshal(out)
+}
+
+func (r *WatchRule) UnmarshalJSON(data []byte) error {
+	var in watchRuleJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	*r = WatchRule{
+		Name:     in.Name,
+		Bucket:   in.Bucket,
+		Prefix:   in.Prefix,
+		Mode:     in.Mode,
+		Labels:   in.Labels,
+		Filters:  in.Filters,
+		Webhooks: in.Webhooks,
+	}
+	if in.PollInterval != "" {
+		interval, err := time.ParseDuration(in.PollInterval)
+		if err != nil {
+			return fmt.Errorf("invalid poll_interval for rule %s: %w", in.Name, err)
+		}
+		r.PollInterval = interval
+	}
+	return nil
+}
+
+// normalize checks the rule and fills in defaults.
+func (r *WatchRule) normalize(defaultBucket string) error {
+	if r.Name == "" {
+		return fmt.Errorf("watch rule name is required")
+	}
+	if r.Bucket == "" {
+		r.Bucket = defaultBucket
+	}
+	if r.Bucket == "" {
+		return fmt.Errorf("watch rule %s has no bucket", r.Name)
+	}
+
+	switch r.Mode {
+	case "":
+		r.Mode = ModePoll
+	case ModePoll, ModeNotify:
+	default:
+		return fmt.Errorf("unknown watcher mode for rule %s: %s", r.Name, r.Mode)
+	}
+
+	if r.PollInterval < 0 {
+		return fmt.Errorf("poll interval for rule %s must be positive", r.Name)
+	}
+	if err := r.Filters.Validate(); err != nil {
+		return fmt.Errorf("invalid filters for rule %s: %w", r.Name, err)
+	}
+	for i := range r.Webhooks {
+		if err := r.Webhooks[i].Validate(); err != nil {
+			return fmt.Errorf("invalid webhook for rule %s: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// LoadWatchRules reads a JSON array of watch rules from path.
+func LoadWatchRules(path string) ([]WatchRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watch rules: %w", err)
+	}
+
+	var rules []WatchRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse watch rules: %w", err)
+	}
+	return rules, nil
+}
+
+// ruleRunner is the goroutine watching one rule.
+type ruleRunner struct {
+	rule   WatchRule
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startRule checks the rule's bucket and starts watching it. Callers hold
+// fw.mu.
+func (fw *FileWatcher) startRule(rule WatchRule) error {
+	if rule.PollInterval == 0 {
+		rule.PollInterval = fw.pollInterval
+	}
+
+	exists, err := fw.client.BucketExists(fw.ctx, rule.Bucket)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket existence: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("bucket %s does not exist", rule.Bucket)
+	}
+
+	ctx, cancel := context.WithCancel(fw.ctx)
+	runner := &ruleRunner{
+		rule:   rule,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	fw.runners[rule.Name] = runner
+
+	fw.wg.Add(1)
+	if rule.Mode == ModeNotify {
+		go fw.notifyLoop(runner)
+	} else {
+		go fw.watchLoop(runner)
+	}
+	return nil
+}
+
+// GetRules returns the configured watch rules.
+func (fw *FileWatcher) GetRules() []WatchRule {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+
+	rules := make([]WatchRule, len(fw.rules))
+	copy(rules, fw.rules)
+	return rules
+}
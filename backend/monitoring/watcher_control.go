@@ -0,0 +1,199 @@
+package monitoring
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+var ErrRuleInvalid = errors.New("invalid watch rule")
+
+// WatcherStatus reports whether the watcher is paused and which rules are
+// being watched.
+type WatcherStatus struct {
+	Paused  bool     `json:"paused"`
+	Rules   int      `json:"rules"`
+	Running []string `json:"running"` // Rules whose bucket is being watched
+}
+
+// Status returns the watcher's current state.
+func (fw *FileWatcher) Status() WatcherStatus {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+
+	running := make([]string, 0, len(fw.runners))
+	for name := range fw.runners {
+		running = append(running, name)
+	}
+	sort.Strings(running)
+
+	return WatcherStatus{
+		Paused:  fw.paused,
+		Rules:   len(fw.rules),
+		Running: running,
+	}
+}
+
+// Pause stops watching every rule until Resume. Events for changes made while
+// paused are not reported: poll rules take a fresh listing on resume, so use a
+// backfill to catch up on new objects.
+func (fw *FileWatcher) Pause() {
+	fw.controlMu.Lock()
+	defer fw.controlMu.Unlock()
+
+	fw.mu.Lock()
+	if fw.paused {
+		fw.mu.Unlock()
+		return
+	}
+	fw.paused = true
+	names := make([]string, 0, len(fw.runners))
+	for name := range fw.runners {
+		names = append(names, name)
+	}
+	fw.mu.Unlock()
+
+	for _, name := range names {
+		fw.stopRunner(name)
+	}
+	log.Println("File watcher paused")
+}
+
+// Resume starts watching every rule again after Pause.
+func (fw *FileWatcher) Resume() {
+	fw.controlMu.Lock()
+	defer fw.controlMu.Unlock()
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if !fw.paused {
+		return
+	}
+	fw.paused = false
+	for _, rule := range fw.rules {
+		if err := fw.startRule(rule); err != nil {
+			log.Printf("Warning: Not watching rule %s: %v", rule.Name, err)
+		}
+	}
+	log.Printf("File watcher resumed with %d of %d rules", len(fw.runners), len(fw.rules))
+}
+
+// SaveRule adds a watch rule, or replaces the rule with the same name, and
+// starts watching it without restarting the other rules. Use it to change a
+// rule's poll interval or mode at runtime. A rule whose bucket cannot be
+// reached is rejected and the previous rule, if any, keeps running. Webhook
+// secrets sent back masked keep their saved value.
+func (fw *FileWatcher) SaveRule(rule WatchRule) error {
+	fw.controlMu.Lock()
+	defer fw.controlMu.Unlock()
+
+	previous, existed := fw.rule(rule.Name)
+	if existed {
+		rule.keepSecrets(previous)
+	}
+	if err := rule.normalize(fw.defaultBucket); err != nil {
+		return fmt.Errorf("%w: %v", ErrRuleInvalid, err)
+	}
+	fw.stopRunner(rule.Name)
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if !fw.paused {
+		if err := fw.startRule(rule); err != nil {
+			if existed {
+				if restartErr := fw.startRule(previous); restartErr != nil {
+					log.Printf("Warning: Failed to restart rule %s: %v", previous.Name, restartErr)
+				}
+			}
+			return fmt.Errorf("%w: %v", ErrRuleInvalid, err)
+		}
+	}
+
+	if existed {
+		for i := range fw.rules {
+			if fw.rules[i].Name == rule.Name {
+				fw.rules[i] = rule
+			}
+		}
+	} else {
+		fw.rules = append(fw.rules, rule)
+	}
+
+	log.Printf("Watch rule %s saved (%s/%s, %s)", rule.Name, rule.Bucket, rule.Prefix, rule.Mode)
+	return fw.saveRules()
+}
+
+// RemoveRule stops watching a rule and deletes it.
+func (fw *FileWatcher) RemoveRule(name string) error {
+	fw.controlMu.Lock()
+	defer fw.controlMu.Unlock()
+
+	if _, ok := fw.rule(name); !ok {
+		return fmt.Errorf("%w: %s", ErrRuleNotFound, name)
+	}
+	fw.stopRunner(name)
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	rules := make([]WatchRule, 0, len(fw.rules))
+	for _, rule := range fw.rules {
+		if rule.Name != name {
+			rules = append(rules, rule)
+		}
+	}
+	fw.rules = rules
+
+	log.Printf("Watch rule %s removed", name)
+	return fw.saveRules()
+}
+
+// SetPollInterval changes the default poll interval and restarts the running
+// poll rules that use it. Rules with their own interval keep running.
+func (fw *FileWatcher) SetPollInterval(interval time.Duration) {
+	fw.controlMu.Lock()
+	defer fw.controlMu.Unlock()
+
+	fw.mu.Lock()
+	fw.pollInterval = interval
+	var restart []WatchRule
+	for _, rule := range fw.rules {
+		if _, running := fw.runners[rule.Name]; running && rule.Mode == ModePoll && rule.PollInterval == 0 {
+			restart = append(restart, rule)
+		}
+	}
+	fw.mu.Unlock()
+
+	for _, rule := range restart {
+		fw.stopRunner(rule.Name)
+	}
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	for _, rule := range restart {
+		if err := fw.startRule(rule); err != nil {
+			log.Printf("Warning: Not watching rule %s: %v", rule.Name, err)
+		}
+	}
+	log.Printf("Watch interval set to %s, %d rules restarted", interval, len(restart))
+}
+
+// stopRunner stops the goroutine watching a rule and waits for it to exit.
+// fw.mu must not be held: the runner may need it to finish its current event.
+func (fw *FileWatcher) stopRunner(name string) {
+	fw.mu.Lock()
+	runner, ok := fw.runners[name]
+	delete(fw.runners, name)
+	fw.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	runner.cancel()
+	<-runner.done
+}
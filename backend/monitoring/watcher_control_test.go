@@ -0,0 +1,58 @@
+package monitoring
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherRuleChangesWhilePaused(t *testing.T) {
+	rulesFile := filepath.Join(t.TempDir(), "rules.json")
+	fw := &FileWatcher{
+		runners:       make(map[string]*ruleRunner),
+		rulesFile:     rulesFile,
+		defaultBucket: "files",
+	}
+	fw.Pause()
+
+	rule := WatchRule{
+		Name:     "reports",
+		Prefix:   "daily/",
+		Webhooks: []EventWebhook{{URL: "https://example.com/hook", Secret: "s3cret"}},
+	}
+	if err := fw.SaveRule(rule); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sending back the masked secret keeps the saved one
+	update := fw.GetRules()[0].withoutSecrets()
+	update.PollInterval = 5 * time.Minute
+	if err := fw.SaveRule(update); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadWatchRules(rulesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 1 || loaded[0].Bucket != "files" || loaded[0].PollInterval != 5*time.Minute || loaded[0].Webhooks[0].Secret != "s3cret" {
+		t.Fatalf("unexpected saved rules: %+v", loaded)
+	}
+
+	if err := fw.SaveRule(WatchRule{Name: "bad", Mode: "push"}); !errors.Is(err, ErrRuleInvalid) {
+		t.Errorf("SaveRule(bad mode) = %v, want ErrRuleInvalid", err)
+	}
+
+	if err := fw.RemoveRule("reports"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.RemoveRule("reports"); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("RemoveRule(missing) = %v, want ErrRuleNotFound", err)
+	}
+
+	status := fw.Status()
+	if !status.Paused || status.Rules != 0 || len(status.Running) != 0 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
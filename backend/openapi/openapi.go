@@ -0,0 +1,286 @@
+// Package openapi builds an OpenAPI 3 document from the routes the server
+// actually registers. Request and response schemas are derived from the
+// handlers' Go types, so the published contract changes with the code.
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"bronze-backend/httputil"
+)
+
+// Operation documents one route. Request and Response are values of the
+// JSON body types, e.g. jobs.CreateJobRequest{}; nil leaves the body out.
+type Operation struct {
+	Summary     string
+	Description string
+	Tag         string
+	Query       []Param
+	Headers     []Param
+	Request     any
+	Multipart   []string // Form fields besides "file" of a multipart/form-data request
+	Response    any
+	Status      int    // Success status, 200 if zero
+	ContentType string // Success content type when not JSON, e.g. text/event-stream
+}
+
+// Param is a query or header parameter.
+type Param struct {
+	Name        string
+	Description string
+}
+
+// Route is a registered route and the least role allowed to call it,
+// empty for unauthenticated routes.
+type Route struct {
+	Method string
+	Path   string // mux path template, e.g. /api/files/{filename:.+}
+	Role   string
+}
+
+// Info describes the API in the document header.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Tag groups operations.
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// Document is an OpenAPI 3.0 document.
+type Document struct {
+	OpenAPI    string                               `json:"openapi"`
+	Info       Info                                 `json:"info"`
+	Tags       []Tag                                `json:"tags,omitempty"`
+	Paths      map[string]map[string]*PathOperation `json:"paths"`
+	Components Components                           `json:"components"`
+}
+
+// Components holds the schemas referenced by operations.
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how requests authenticate.
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// PathOperation is an operation as it appears in the document.
+type PathOperation struct {
+	Summary      string                `json:"summary,omitempty"`
+	Description  string                `json:"description,omitempty"`
+	Tags         []string              `json:"tags,omitempty"`
+	OperationID  string                `json:"operationId"`
+	Parameters   []Parameter           `json:"parameters,omitempty"`
+	RequestBody  *RequestBody          `json:"requestBody,omitempty"`
+	Responses    map[string]Response   `json:"responses"`
+	Security     []map[string][]string `json:"security,omitempty"`
+	RequiredRole string                `json:"x-required-role,omitempty"`
+}
+
+// Parameter is a path, query or header parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is an operation's request body.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is one of an operation's responses.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Builder collects routes into a Document.
+type Builder struct {
+	doc     *Document
+	schemas *schemaRegistry
+}
+
+// NewBuilder starts a document. Operations of routes with a role require a
+// bearer token.
+func NewBuilder(info Info, tags []Tag) *Builder {
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Tags:    tags,
+		Paths:   make(map[string]map[string]*PathOperation),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+	return &Builder{doc: doc, schemas: newSchemaRegistry(doc.Components.Schemas)}
+}
+
+// pathParam matches a variable in a mux path template, with its optional
+// pattern.
+var pathParam = regexp.MustCompile(`\{([^}:]+)(?::[^}]*)?\}`)
+
+// Add documents a route.
+func (b *Builder) Add(route Route, op Operation) {
+	path := pathParam.ReplaceAllString(route.Path, "{$1}")
+	method := strings.ToLower(route.Method)
+
+	operation := &PathOperation{
+		Summary:      op.Summary,
+		Description:  op.Description,
+		OperationID:  operationID(route.Method, path),
+		Responses:    make(map[string]Response),
+		RequiredRole: route.Role,
+	}
+	if op.Tag != "" {
+		operation.Tags = []string{op.Tag}
+	}
+	if route.Role != "" {
+		operation.Security = []map[string][]string{{"bearerAuth": {}}}
+	}
+
+	for _, match := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+	for _, param := range op.Query {
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name:        param.Name,
+			In:          "query",
+			Description: param.Description,
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+	for _, param := range op.Headers {
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name:        param.Name,
+			In:          "header",
+			Description: param.Description,
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+
+	switch {
+	case len(op.Multipart) > 0:
+		form := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		for _, field := range op.Multipart {
+			form.Properties[field] = &Schema{Type: "string"}
+		}
+		form.Properties["file"] = &Schema{Type: "string", Format: "binary"}
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"multipart/form-data": {Schema: form}},
+		}
+	case op.Request != nil:
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: b.schemas.of(op.Request)}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := Response{Description: http.StatusText(status)}
+	switch {
+	case op.ContentType != "":
+		success.Content = map[string]MediaType{op.ContentType: {}}
+	case op.Response != nil:
+		success.Content = map[string]MediaType{"application/json": {Schema: b.schemas.of(op.Response)}}
+	}
+	operation.Responses[strconv.Itoa(status)] = success
+	operation.Responses["default"] = Response{
+		Description: "Error",
+		Content:     map[string]MediaType{"application/json": {Schema: b.schemas.of(httputil.ErrorResponse{})}},
+	}
+
+	if b.doc.Paths[path] == nil {
+		b.doc.Paths[path] = make(map[string]*PathOperation)
+	}
+	b.doc.Paths[path][method] = operation
+}
+
+// Document returns the document built so far.
+func (b *Builder) Document() *Document {
+	return b.doc
+}
+
+// Endpoints lists the documented operations by tag, sorted by path, for
+// a quick overview of the API.
+func (d *Document) Endpoints() map[string][]Endpoint {
+	endpoints := make(map[string][]Endpoint)
+	for path, methods := range d.Paths {
+		for method, op := range methods {
+			tag := ""
+			if len(op.Tags) > 0 {
+				tag = op.Tags[0]
+			}
+			endpoints[tag] = append(endpoints[tag], Endpoint{
+				Method:  strings.ToUpper(method),
+				Path:    path,
+				Summary: op.Summary,
+				Role:    op.RequiredRole,
+			})
+		}
+	}
+	for _, list := range endpoints {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Path != list[j].Path {
+				return list[i].Path < list[j].Path
+			}
+			return list[i].Method < list[j].Method
+		})
+	}
+	return endpoints
+}
+
+// Endpoint is one operation in the overview returned by Endpoints.
+type Endpoint struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Summary string `json:"summary"`
+	Role    string `json:"role,omitempty"`
+}
+
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '-' || r == '_'
+	}) {
+		if part == "api" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package openapi
+
+import (
+	"testing"
+	"time"
+)
+
+type item struct {
+	Name    string    `json:"name"`
+	Size    *int64    `json:"size,omitempty"`
+	Created time.Time `json:"created"`
+	Next    *item     `json:"next,omitempty"`
+	secret  string
+	Skipped string `json:"-"`
+}
+
+type page struct {
+	item
+	Items []item `json:"items"`
+}
+
+func TestAddConvertsPathTemplate(t *testing.T) {
+	b := NewBuilder(Info{Title: "test", Version: "1"}, nil)
+	b.Add(Route{Method: "GET", Path: "/api/files/{filename:.+}/info", Role: "viewer"}, Operation{Summary: "Info", Headers: []Param{{Name: "X-Trace"}}})
+
+	op := b.Document().Paths["/api/files/{filename}/info"]["get"]
+	if op == nil {
+		t.Fatalf("paths = %v", b.Document().Paths)
+	}
+	if op.OperationID != "getFilesFilenameInfo" {
+		t.Errorf("operationId = %s", op.OperationID)
+	}
+	if len(op.Parameters) != 2 || op.Parameters[0].Name != "filename" || op.Parameters[0].In != "path" || op.Parameters[1].In != "header" {
+		t.Errorf("parameters = %+v", op.Parameters)
+	}
+	if op.Security == nil || op.RequiredRole != "viewer" {
+		t.Errorf("security = %v, role = %q", op.Security, op.RequiredRole)
+	}
+}
+
+func TestSchemaReflection(t *testing.T) {
+	b := NewBuilder(Info{}, nil)
+	b.Add(Route{Method: "POST", Path: "/pages"}, Operation{Request: page{}, Response: page{}, Status: 201})
+
+	op := b.Document().Paths["/pages"]["post"]
+	if ref := op.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/page" {
+		t.Errorf("request schema ref = %q", ref)
+	}
+	if _, ok := op.Responses["201"]; !ok {
+		t.Errorf("responses = %v", op.Responses)
+	}
+
+	schemas := b.Document().Components.Schemas
+	pageSchema := schemas["page"]
+	for _, name := range []string{"name", "size", "created", "next", "items"} {
+		if pageSchema.Properties[name] == nil {
+			t.Errorf("page is missing %s: %v", name, pageSchema.Properties)
+		}
+	}
+	if len(pageSchema.Properties) != 5 {
+		t.Errorf("page has unexpected properties: %v", pageSchema.Properties)
+	}
+	if size := pageSchema.Properties["size"]; size.Type != "integer" || !size.Nullable {
+		t.Errorf("size = %+v", size)
+	}
+	if created := pageSchema.Properties["created"]; created.Format != "date-time" {
+		t.Errorf("created = %+v", created)
+	}
+	if items := pageSchema.Properties["items"]; items.Items == nil || items.Items.Ref != "#/components/schemas/item" {
+		t.Errorf("items = %+v", items)
+	}
+	if next := schemas["item"].Properties["next"]; next.Ref != "#/components/schemas/item" {
+		t.Errorf("recursive next = %+v", next)
+	}
+	if schemas["ErrorResponse"] == nil {
+		t.Error("error response schema missing")
+	}
+}
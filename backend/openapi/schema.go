@@ -0,0 +1,153 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON schema as used by OpenAPI 3.0.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType           = reflect.TypeOf(time.Time{})
+	durationType       = reflect.TypeOf(time.Duration(0))
+	rawMessageType     = reflect.TypeOf(json.RawMessage(nil))
+	textMarshalerType  = reflect.TypeOf((*interface{ MarshalText() ([]byte, error) })(nil)).Elem()
+	jsonMarshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	anySchema          = &Schema{}
+	schemaNameReplacer = strings.NewReplacer("[", "_", "]", "", "*", "", "/", ".")
+)
+
+// schemaRegistry derives schemas from Go types, adding named structs to the
+// document's components and referring to them there.
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaRegistry(schemas map[string]*Schema) *schemaRegistry {
+	return &schemaRegistry{schemas: schemas, names: make(map[reflect.Type]string)}
+}
+
+// of returns the schema of the value's type.
+func (r *schemaRegistry) of(value any) *Schema {
+	return r.schema(reflect.TypeOf(value))
+}
+
+func (r *schemaRegistry) schema(t reflect.Type) *Schema {
+	if t == nil {
+		return anySchema
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == durationType:
+		return &Schema{Type: "integer", Format: "int64"}
+	case t == rawMessageType:
+		return anySchema
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return anySchema
+	case t.Kind() != reflect.Struct && t.Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: r.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schema(t.Elem())}
+	case reflect.Struct:
+		return r.structSchema(t)
+	default:
+		return anySchema
+	}
+}
+
+// structSchema returns a reference to a named struct's schema, adding it to
+// the components first, or the schema itself for an anonymous struct.
+func (r *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return r.properties(t)
+	}
+	if name, ok := r.names[t]; ok {
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	name := schemaNameReplacer.Replace(t.Name())
+	if _, taken := r.schemas[name]; taken {
+		// Same name in another package, e.g. files and storage
+		name = schemaNameReplacer.Replace(t.String())
+	}
+	r.names[t] = name // Before the properties, so recursive types terminate
+	r.schemas[name] = r.properties(t)
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// properties lists a struct's JSON fields as encoding/json would encode them,
+// flattening embedded structs.
+func (r *schemaRegistry) properties(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for key, value := range r.properties(embedded).Properties {
+					schema.Properties[key] = value
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := r.schema(field.Type)
+		if field.Type.Kind() == reflect.Pointer && property.Ref == "" {
+			nullable := *property
+			nullable.Nullable = true
+			property = &nullable
+		}
+		schema.Properties[name] = property
+	}
+	return schema
+}
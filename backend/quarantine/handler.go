@@ -0,0 +1,44 @@
+package quarantine
+
+import (
+	"net/http"
+
+	"bronze-backend/httputil"
+)
+
+// Handler serves the quarantined files.
+type Handler struct {
+	store *Store
+}
+
+func NewHandler(store *Store) *Handler {
+	return &Handler{store: store}
+}
+
+// ListResponse is the body of GET /api/quarantine.
+type ListResponse struct {
+	Success bool     `json:"success"`
+	Files   []Report `json:"files"`
+	Count   int      `json:"count"`
+}
+
+// List returns the reports of the files quarantined in the caller's bucket
+// and tenant.
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		httputil.Error(w, "Quarantine is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	reports, err := h.store.List(r.Context())
+	if err != nil {
+		httputil.WriteError(w, "Failed to list quarantined files", http.StatusInternalServerError, err)
+		return
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, ListResponse{
+		Success: true,
+		Files:   reports,
+		Count:   len(reports),
+	})
+}
@@ -0,0 +1,232 @@
+// Package quarantine sets aside source files that keep failing extraction
+// or export. Once a file has failed often enough in a row it is copied, or
+// moved, under a quarantine prefix with a report of why, so it stops
+// failing jobs and exports and can be looked at and fixed.
+package quarantine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"bronze-backend/config"
+	"bronze-backend/storage"
+	"bronze-backend/tenant"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// ReportSuffix ends the key of the report stored next to a quarantined file.
+const ReportSuffix = ".quarantine.json"
+
+// Where a failure happened.
+const (
+	SourceExport     = "export"
+	SourceExtraction = "extraction"
+)
+
+// Report says why a file was quarantined.
+type Report struct {
+	Bucket        string    `json:"bucket"`
+	Object        string    `json:"object"`         // The key the file failed under
+	QuarantinedAs string    `json:"quarantined_as"` // The key of the copy
+	Moved         bool      `json:"moved"`          // Whether Object was deleted
+	Source        string    `json:"source"`         // SourceExport or SourceExtraction
+	Stage         string    `json:"stage,omitempty"`
+	JobID         string    `json:"job_id,omitempty"`
+	Error         string    `json:"error"` // The last failure
+	Failures      int       `json:"failures"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// Failure is one failed attempt at processing a file.
+type Failure struct {
+	Source string
+	Stage  string
+	JobID  string
+	Err    error
+}
+
+// objectStore is the part of *storage.MinIOClient a Store uses.
+type objectStore interface {
+	Bucket(ctx context.Context) string
+	UploadFile(ctx context.Context, objectName string, reader io.Reader, size int64, contentType string) (minio.UploadInfo, error)
+	DownloadFile(ctx context.Context, objectName string) (io.ReadCloser, error)
+	CopyFile(ctx context.Context, srcObjectName, destObjectName string) (minio.UploadInfo, error)
+	DeleteFile(ctx context.Context, objectName string) error
+	GetClient() *minio.Client
+}
+
+// Store counts the failures of each file and quarantines those failing
+// Threshold times in a row. Counts are kept in memory, so each instance
+// counts the failures it sees. A nil Store quarantines nothing.
+type Store struct {
+	client objectStore
+	now    func() time.Time
+
+	mu       sync.Mutex
+	cfg      config.QuarantineConfig
+	failures map[string]int // By bucket and key
+}
+
+// New returns a Store for cfg, or nil when quarantine is disabled or there
+// is no storage to quarantine in.
+func New(cfg config.QuarantineConfig, client *storage.MinIOClient) *Store {
+	if !cfg.Enabled || client == nil {
+		return nil
+	}
+	return newStore(cfg, client)
+}
+
+func newStore(cfg config.QuarantineConfig, client objectStore) *Store {
+	return &Store{
+		client:   client,
+		now:      time.Now,
+		cfg:      cfg,
+		failures: make(map[string]int),
+	}
+}
+
+// SetConfig changes the prefix, threshold and whether files are moved, from
+// the next failure. Enabling or disabling quarantine needs a restart.
+func (s *Store) SetConfig(cfg config.QuarantineConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+}
+
+// Fail records that processing key failed, and quarantines the file when it
+// has now failed Threshold times in a row. It returns the report of a file
+// it quarantined, or nil. Files already under the quarantine prefix are
+// left alone.
+func (s *Store) Fail(ctx context.Context, key string, failure Failure) (*Report, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	bucket := s.client.Bucket(ctx)
+	s.mu.Lock()
+	cfg := s.cfg
+	target, ok := quarantineKey(tenant.Prefix(ctx), cfg.Prefix, key)
+	if !ok {
+		s.mu.Unlock()
+		return nil, nil
+	}
+	id := bucket + "\x00" + key
+	s.failures[id]++
+	failures := s.failures[id]
+	if failures < cfg.Threshold {
+		s.mu.Unlock()
+		return nil, nil
+	}
+	delete(s.failures, id)
+	s.mu.Unlock()
+
+	report := &Report{
+		Bucket:        bucket,
+		Object:        key,
+		QuarantinedAs: target,
+		Moved:         cfg.Move,
+		Source:        failure.Source,
+		Stage:         failure.Stage,
+		JobID:         failure.JobID,
+		Failures:      failures,
+		QuarantinedAt: s.now().UTC(),
+	}
+	if failure.Err != nil {
+		report.Error = failure.Err.Error()
+	}
+
+	if _, err := s.client.CopyFile(ctx, key, target); err != nil {
+		return nil, fmt.Errorf("failed to copy %s to quarantine: %w", key, err)
+	}
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.client.UploadFile(ctx, target+ReportSuffix, bytes.NewReader(body), int64(len(body)), "application/json"); err != nil {
+		return nil, fmt.Errorf("failed to save quarantine report for %s: %w", key, err)
+	}
+	// Only once the copy and its report are safe
+	if cfg.Move {
+		if err := s.client.DeleteFile(ctx, key); err != nil {
+			return nil, fmt.Errorf("failed to delete quarantined %s: %w", key, err)
+		}
+	}
+
+	log.Printf("Quarantined %s/%s as %s after %d failures: %s", bucket, key, target, failures, report.Error)
+	return report, nil
+}
+
+// Succeed forgets the failures of key, which processed.
+func (s *Store) Succeed(ctx context.Context, key string) {
+	if s == nil {
+		return
+	}
+	id := s.client.Bucket(ctx) + "\x00" + key
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, id)
+}
+
+// List returns the reports of the files quarantined in the caller's bucket
+// and tenant, oldest first.
+func (s *Store) List(ctx context.Context) ([]Report, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	prefix := tenant.Prefix(ctx) + s.cfg.Prefix
+	s.mu.Unlock()
+
+	bucket := s.client.Bucket(ctx)
+	reports := make([]Report, 0)
+	for object := range s.client.GetClient().ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list quarantine: %w", object.Err)
+		}
+		if !strings.HasSuffix(object.Key, ReportSuffix) {
+			continue
+		}
+		report, err := s.readReport(ctx, object.Key)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	slices.SortStableFunc(reports, func(a, b Report) int {
+		return a.QuarantinedAt.Compare(b.QuarantinedAt)
+	})
+	return reports, nil
+}
+
+func (s *Store) readReport(ctx context.Context, key string) (Report, error) {
+	var report Report
+	reader, err := s.client.DownloadFile(ctx, key)
+	if err != nil {
+		return report, fmt.Errorf("failed to read quarantine report %s: %w", key, err)
+	}
+	defer reader.Close()
+	if err := json.NewDecoder(reader).Decode(&report); err != nil {
+		return report, fmt.Errorf("failed to read quarantine report %s: %w", key, err)
+	}
+	return report, nil
+}
+
+// quarantineKey returns where key is quarantined: under prefix, itself
+// under the tenant's prefix so the copy stays the tenant's. It reports
+// false for a key already in quarantine.
+func quarantineKey(tenantPrefix, prefix, key string) (string, bool) {
+	rest := strings.TrimPrefix(key, tenantPrefix)
+	if strings.HasPrefix(rest, prefix) {
+		return "", false
+	}
+	return tenantPrefix + prefix + rest, true
+}
@@ -0,0 +1,114 @@
+package quarantine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"bronze-backend/config"
+	"bronze-backend/tenant"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// memoryStore keeps objects in a map.
+type memoryStore struct {
+	objects map[string][]byte
+}
+
+func (m *memoryStore) Bucket(context.Context) string { return "bronze" }
+
+func (m *memoryStore) UploadFile(_ context.Context, key string, reader io.Reader, _ int64, _ string) (minio.UploadInfo, error) {
+	body, err := io.ReadAll(reader)
+	m.objects[key] = body
+	return minio.UploadInfo{Key: key}, err
+}
+
+func (m *memoryStore) DownloadFile(_ context.Context, key string) (io.ReadCloser, error) {
+	return nil, errors.New("not used")
+}
+
+func (m *memoryStore) CopyFile(_ context.Context, src, dst string) (minio.UploadInfo, error) {
+	body, ok := m.objects[src]
+	if !ok {
+		return minio.UploadInfo{}, errors.New("no such key")
+	}
+	m.objects[dst] = body
+	return minio.UploadInfo{Key: dst}, nil
+}
+
+func (m *memoryStore) DeleteFile(_ context.Context, key string) error {
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *memoryStore) GetClient() *minio.Client { return nil }
+
+func TestFailQuarantinesAfterThreshold(t *testing.T) {
+	objects := &memoryStore{objects: map[string][]byte{"uploads/bad.xlsx": []byte("PK")}}
+	s := newStore(config.QuarantineConfig{Enabled: true, Prefix: "quarantine/", Threshold: 3, Move: true}, objects)
+	s.now = func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+	failure := Failure{Source: SourceExtraction, Stage: "extract", JobID: "job-1", Err: errors.New("zip: not a valid zip file")}
+
+	// A success in between starts the count again
+	for _, succeed := range []bool{false, false, true, false, false} {
+		if succeed {
+			s.Succeed(ctx, "uploads/bad.xlsx")
+			continue
+		}
+		if report, err := s.Fail(ctx, "uploads/bad.xlsx", failure); report != nil || err != nil {
+			t.Fatalf("quarantined before the threshold: %+v, %v", report, err)
+		}
+	}
+
+	report, err := s.Fail(ctx, "uploads/bad.xlsx", failure)
+	if err != nil || report == nil {
+		t.Fatalf("third failure in a row: %+v, %v", report, err)
+	}
+	if report.QuarantinedAs != "quarantine/uploads/bad.xlsx" || !report.Moved || report.Failures != 3 || report.Error != "zip: not a valid zip file" {
+		t.Errorf("report = %+v", report)
+	}
+	keys := slices.Sorted(maps.Keys(objects.objects))
+	if want := []string{"quarantine/uploads/bad.xlsx", "quarantine/uploads/bad.xlsx" + ReportSuffix}; !slices.Equal(keys, want) {
+		t.Errorf("objects = %q, want %q", keys, want)
+	}
+	var saved Report
+	if err := json.Unmarshal(objects.objects["quarantine/uploads/bad.xlsx"+ReportSuffix], &saved); err != nil || saved.Stage != "extract" || saved.JobID != "job-1" {
+		t.Errorf("saved report = %+v, %v", saved, err)
+	}
+
+	// Failures of the quarantined copy itself are not counted
+	for range 3 {
+		if report, _ := s.Fail(ctx, "quarantine/uploads/bad.xlsx", failure); report != nil {
+			t.Fatal("quarantined a file already in quarantine")
+		}
+	}
+}
+
+func TestQuarantineKey(t *testing.T) {
+	for _, tt := range []struct {
+		tenantPrefix, key, want string
+	}{
+		{"", "uploads/a.csv", "quarantine/uploads/a.csv"},
+		{"acme/", "acme/uploads/a.csv", "acme/quarantine/uploads/a.csv"},
+		{"acme/", "acme/quarantine/a.csv", ""},
+	} {
+		got, ok := quarantineKey(tt.tenantPrefix, "quarantine/", tt.key)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("quarantineKey(%q, %q) = %q, %v, want %q", tt.tenantPrefix, tt.key, got, ok, tt.want)
+		}
+	}
+
+	// Under a tenant, the store uses its prefix
+	s := newStore(config.QuarantineConfig{Enabled: true, Prefix: "quarantine/", Threshold: 1}, &memoryStore{objects: map[string][]byte{"acme/a.csv": nil}})
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{Name: "acme", Prefix: "acme/"})
+	if report, err := s.Fail(ctx, "acme/a.csv", Failure{Source: SourceExport}); err != nil || report.QuarantinedAs != "acme/quarantine/a.csv" {
+		t.Errorf("tenant quarantine = %+v, %v", report, err)
+	}
+}
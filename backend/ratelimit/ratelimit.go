@@ -0,0 +1,190 @@
+// Package ratelimit throttles API requests per client with token buckets, so
+// one client cannot flood the server or fill the worker pool. Every request
+// spends from the client's general bucket; expensive operations also spend
+// from a smaller second bucket.
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bronze-backend/auth"
+	"bronze-backend/config"
+	"bronze-backend/httputil"
+
+	"golang.org/x/time/rate"
+)
+
+// idleTimeout is how long a client's buckets are kept after its last
+// request. An idle client's buckets have refilled by then anyway.
+const idleTimeout = 10 * time.Minute
+
+// maxClients bounds how many clients have buckets of their own. Past it,
+// new clients share one overflow client's buckets until idle ones are
+// dropped, so a flood of addresses cannot grow the map without bound.
+const maxClients = 100_000
+
+// overflowKey is the key of the clients over maxClients.
+const overflowKey = "overflow"
+
+type client struct {
+	general   *rate.Limiter
+	expensive *rate.Limiter
+	lastSeen  time.Time
+}
+
+// Limiter holds the token buckets of every client seen recently. A nil
+// Limiter allows every request.
+type Limiter struct {
+	now        func() time.Time
+	maxClients int
+
+	mu        sync.Mutex
+	cfg       config.RateLimitConfig
+	clients   map[string]*client
+	lastSweep time.Time
+}
+
+// New returns a Limiter for cfg, or nil when rate limiting is disabled.
+func New(cfg config.RateLimitConfig) *Limiter {
+	if !cfg.Enabled {
+		return nil
+	}
+	log.Printf("Rate limiting enabled: %g req/s (burst %d), expensive %g req/s (burst %d) per client",
+		cfg.RPS, cfg.Burst, cfg.ExpensiveRPS, cfg.ExpensiveBurst)
+	return &Limiter{
+		cfg:        cfg,
+		now:        time.Now,
+		maxClients: maxClients,
+		clients:    make(map[string]*client),
+	}
+}
+
+// SetConfig changes the rates and bursts. Every client starts over with full
+// buckets at the new rates. Enabling or disabling the limiter needs a
+// restart.
+func (l *Limiter) SetConfig(cfg config.RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cfg = cfg
+	l.clients = make(map[string]*client)
+	log.Printf("Rate limits changed: %g req/s (burst %d), expensive %g req/s (burst %d) per client",
+		cfg.RPS, cfg.Burst, cfg.ExpensiveRPS, cfg.ExpensiveBurst)
+}
+
+// Limit spends one token from the client's general bucket per request.
+func (l *Limiter) Limit(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.allow(w, r, func(c *client) *rate.Limiter { return c.general }) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// Expensive wraps the handler of an expensive operation, spending one token
+// from the client's expensive bucket per request.
+func (l *Limiter) Expensive(next http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if l.allow(w, r, func(c *client) *rate.Limiter { return c.expensive }) {
+			next(w, r)
+		}
+	}
+}
+
+// allow takes a token from the bucket picked from the request's client, or
+// answers 429 with the time until one is available.
+func (l *Limiter) allow(w http.ResponseWriter, r *http.Request, bucket func(*client) *rate.Limiter) bool {
+	now := l.now()
+	limiter := bucket(l.client(l.clientKey(r), now))
+
+	reservation := limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		httputil.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return false
+	}
+	delay := reservation.DelayFrom(now)
+	if delay == 0 {
+		return true
+	}
+	reservation.CancelAt(now)
+
+	seconds := int(math.Ceil(delay.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	httputil.Error(w, fmt.Sprintf("Rate limit exceeded, retry in %ds", seconds), http.StatusTooManyRequests)
+	return false
+}
+
+// client returns the buckets for key, creating full ones for a new client
+// and dropping those of clients idle for a while.
+func (l *Limiter) client(key string, now time.Time) *client {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > idleTimeout {
+		for k, c := range l.clients {
+			if now.Sub(c.lastSeen) > idleTimeout {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[key]
+	if !ok && len(l.clients) >= l.maxClients {
+		key = overflowKey
+		c, ok = l.clients[key]
+	}
+	if !ok {
+		c = &client{
+			general:   rate.NewLimiter(rate.Limit(l.cfg.RPS), l.cfg.Burst),
+			expensive: rate.NewLimiter(rate.Limit(l.cfg.ExpensiveRPS), l.cfg.ExpensiveBurst),
+		}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+	return c
+}
+
+// clientKey identifies the caller by the subject auth.Authenticator.Identify
+// verified, so users behind one address are limited separately, and by IP
+// otherwise. An unverified token counts for nothing: a made-up one per
+// request must not get a fresh bucket each time.
+func (l *Limiter) clientKey(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok && p.Subject != "" {
+		return "user:" + p.Subject
+	}
+	return "ip:" + l.clientIP(r)
+}
+
+func (l *Limiter) clientIP(r *http.Request) string {
+	l.mu.Lock()
+	trustProxy := l.cfg.TrustProxy
+	l.mu.Unlock()
+
+	if trustProxy {
+		// The last address is the one the proxy saw; earlier ones are
+		// whatever the client claimed
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if ip := strings.TrimSpace(forwarded[len(forwarded)-1]); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
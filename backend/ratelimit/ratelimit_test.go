@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bronze-backend/auth"
+	"bronze-backend/config"
+)
+
+func newTestLimiter(cfg config.RateLimitConfig) (*Limiter, *time.Time) {
+	cfg.Enabled = true
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(cfg)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+// serve sends a request from remoteAddr, as the user subject identified
+// when it is not empty.
+func serve(h http.Handler, remoteAddr, subject string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/files", nil)
+	req.RemoteAddr = remoteAddr
+	if subject != "" {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: subject}))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+func TestLimitPerClient(t *testing.T) {
+	l, now := newTestLimiter(config.RateLimitConfig{RPS: 0.5, Burst: 2})
+	h := l.Limit(ok)
+
+	for i := 0; i < 2; i++ {
+		if rec := serve(h, "10.0.0.1:1234", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200", i, rec.Code)
+		}
+	}
+
+	rec := serve(h, "10.0.0.1:1234", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("over burst: status %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+
+	// Other clients have their own buckets
+	if rec := serve(h, "10.0.0.2:1234", ""); rec.Code != http.StatusOK {
+		t.Errorf("other IP: status %d, want 200", rec.Code)
+	}
+	if rec := serve(h, "10.0.0.1:1234", "user-a"); rec.Code != http.StatusOK {
+		t.Errorf("user from same IP: status %d, want 200", rec.Code)
+	}
+
+	// A token nobody verified does not get a bucket of its own
+	req := httptest.NewRequest(http.MethodGet, "/api/files", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Authorization", "Bearer made-up")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("unverified token from same IP: status %d, want 429", rec.Code)
+	}
+
+	// A rejected request does not spend a token
+	*now = now.Add(2 * time.Second)
+	if rec := serve(h, "10.0.0.1:5678", ""); rec.Code != http.StatusOK {
+		t.Errorf("after refill: status %d, want 200", rec.Code)
+	}
+}
+
+func TestExpensiveBudgetIsSeparate(t *testing.T) {
+	l, _ := newTestLimiter(config.RateLimitConfig{RPS: 100, Burst: 100, ExpensiveRPS: 0.1, ExpensiveBurst: 1})
+	general := l.Limit(ok)
+	expensive := l.Limit(l.Expensive(ok))
+
+	if rec := serve(expensive, "10.0.0.1:1", ""); rec.Code != http.StatusOK {
+		t.Fatalf("first expensive: status %d, want 200", rec.Code)
+	}
+	rec := serve(expensive, "10.0.0.1:1", "")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "10" {
+		t.Errorf("second expensive: status %d, Retry-After %q; want 429, 10", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := serve(general, "10.0.0.1:1", ""); rec.Code != http.StatusOK {
+		t.Errorf("cheap request: status %d, want 200", rec.Code)
+	}
+}
+
+func TestClientIPFromProxy(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.168.1.1:443"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 5.6.7.8")
+
+	direct, _ := newTestLimiter(config.RateLimitConfig{})
+	if got := direct.clientKey(req); got != "ip:192.168.1.1" {
+		t.Errorf("without trusted proxy: key %q", got)
+	}
+	proxied, _ := newTestLimiter(config.RateLimitConfig{TrustProxy: true})
+	if got := proxied.clientKey(req); got != "ip:5.6.7.8" {
+		t.Errorf("with trusted proxy: key %q", got)
+	}
+}
+
+func TestMaxClients(t *testing.T) {
+	l, _ := newTestLimiter(config.RateLimitConfig{RPS: 0.1, Burst: 1})
+	l.maxClients = 2
+	h := l.Limit(ok)
+
+	serve(h, "10.0.0.1:1", "")
+	serve(h, "10.0.0.2:1", "")
+	// Further clients share the overflow buckets
+	if rec := serve(h, "10.0.0.3:1", ""); rec.Code != http.StatusOK {
+		t.Fatalf("first client over the cap: status %d, want 200", rec.Code)
+	}
+	if rec := serve(h, "10.0.0.4:1", ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("second client over the cap: status %d, want 429", rec.Code)
+	}
+	if len(l.clients) != 3 {
+		t.Errorf("%d clients kept, want 2 and the overflow", len(l.clients))
+	}
+}
+
+func TestNilLimiterAllowsAll(t *testing.T) {
+	var l *Limiter
+	h := l.Limit(l.Expensive(ok))
+	for i := 0; i < 100; i++ {
+		if rec := serve(h, "10.0.0.1:1", ""); rec.Code != http.StatusOK {
+			t.Fatalf("status %d, want 200", rec.Code)
+		}
+	}
+}
+
+func TestSetConfigResetsBuckets(t *testing.T) {
+	l, _ := newTestLimiter(config.RateLimitConfig{RPS: 0.1, Burst: 1})
+	h := l.Limit(ok)
+
+	serve(h, "10.0.0.1:1", "")
+	if rec := serve(h, "10.0.0.1:1", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("over burst: status %d, want 429", rec.Code)
+	}
+
+	l.SetConfig(config.RateLimitConfig{Enabled: true, RPS: 0.1, Burst: 3})
+	for i := 0; i < 3; i++ {
+		if rec := serve(h, "10.0.0.1:1", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d after raising the burst: status %d, want 200", i, rec.Code)
+		}
+	}
+}
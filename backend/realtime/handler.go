@@ -0,0 +1,314 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"bronze-backend/httputil"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = pongWait * 9 / 10
+	maxMessageSize = 64 << 10
+
+	// topicConnection carries the replies to the client's own messages.
+	topicConnection = "connection"
+)
+
+// Stream runs an on-demand stream for one client, calling send for each of
+// its events, until it is done or ctx is cancelled. params are the client's
+// parameters for it.
+type Stream func(ctx context.Context, params json.RawMessage, send func(eventType string, data any)) error
+
+// clientMessage is a message from the client: subscribe or unsubscribe
+// Topics, start the stream of Topic with Params under ID, or cancel the
+// stream with ID.
+type clientMessage struct {
+	Action string          `json:"action"`
+	Topics []string        `json:"topics,omitempty"`
+	Topic  string          `json:"topic,omitempty"`
+	ID     string          `json:"id,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// Handler serves the WebSocket endpoint.
+type Handler struct {
+	hub      *Hub
+	topics   map[string]bool
+	streams  map[string]Stream
+	origins  map[string]bool // Allowed besides the server's own; see AllowOrigins
+	upgrader websocket.Upgrader
+}
+
+// NewHandler returns a handler for the hub's topics. Add on-demand streams
+// with HandleStream before serving.
+func NewHandler(hub *Hub) *Handler {
+	h := &Handler{
+		hub:     hub,
+		topics:  map[string]bool{TopicJobs: true, TopicWatcher: true, TopicExports: true},
+		streams: make(map[string]Stream),
+		origins: make(map[string]bool),
+	}
+	h.upgrader.CheckOrigin = h.checkOrigin
+	return h
+}
+
+// AllowOrigins lets pages from origins, such as "https://ui.example.com",
+// connect besides those served from the server's own host.
+func (h *Handler) AllowOrigins(origins []string) {
+	for _, origin := range origins {
+		h.origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+}
+
+// checkOrigin refuses browsers connecting from pages of other sites, which
+// could otherwise open a socket with the user's credentials and read their
+// events. Clients other than browsers send no Origin and are let through.
+func (h *Handler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+	if strings.EqualFold(parsed.Host, r.Host) {
+		return true
+	}
+	return h.origins[strings.ToLower(parsed.Scheme+"://"+parsed.Host)]
+}
+
+// HandleStream makes stream available to start under topic.
+func (h *Handler) HandleStream(topic string, stream Stream) {
+	h.streams[topic] = stream
+}
+
+// Connect upgrades the request to a WebSocket and serves the client until
+// either side closes it. ?topics= subscribes to comma-separated topics
+// right away.
+func (h *Handler) Connect(w http.ResponseWriter, r *http.Request) {
+	var topics []string
+	for _, topic := range strings.Split(r.URL.Query().Get("topics"), ",") {
+		if topic = strings.TrimSpace(topic); topic == "" {
+			continue
+		}
+		if !h.topics[topic] {
+			httputil.Error(w, "Unknown topic: "+topic, http.StatusBadRequest)
+			return
+		}
+		topics = append(topics, topic)
+	}
+
+	ws, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has answered the request
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	c := &connection{
+		handler: h,
+		ws:      ws,
+		sub:     h.hub.Subscribe(topics...),
+		out:     make(chan Event, subscriberBuffer),
+		ctx:     ctx,
+		cancel:  cancel,
+		streams: make(map[string]context.CancelFunc),
+	}
+	c.run()
+}
+
+// connection is one client's WebSocket.
+type connection struct {
+	handler *Handler
+	ws      *websocket.Conn
+	sub     *Subscription
+	out     chan Event // Replies and stream events
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu      sync.Mutex
+	streams map[string]context.CancelFunc // Running streams by ID
+	wg      sync.WaitGroup
+}
+
+func (c *connection) run() {
+	defer c.sub.Close()
+
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		c.readLoop()
+	}()
+	c.writeLoop()
+
+	// Closing the socket ends the read loop, so no stream starts while the
+	// running ones are waited for
+	c.cancel()
+	c.ws.Close()
+	<-readDone
+	c.wg.Wait()
+}
+
+// readLoop handles the client's messages until it closes the connection or
+// stops answering pings.
+func (c *connection) readLoop() {
+	defer c.cancel()
+
+	c.ws.SetReadLimit(maxMessageSize)
+	c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg clientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.replyError("", fmt.Sprintf("Invalid message: %v", err))
+			continue
+		}
+		c.handle(msg)
+	}
+}
+
+// writeLoop is the connection's only writer. It returns when the connection
+// is done or a write fails.
+func (c *connection) writeLoop() {
+	ping := time.NewTicker(pingPeriod)
+	defer ping.Stop()
+
+	for {
+		var event Event
+		select {
+		case <-c.ctx.Done():
+			c.ws.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+			return
+		case <-ping.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+			continue
+		case e, ok := <-c.sub.Events():
+			if !ok {
+				return
+			}
+			event = e
+		case event = <-c.out:
+		}
+
+		c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := c.ws.WriteJSON(event); err != nil {
+			return
+		}
+	}
+}
+
+func (c *connection) handle(msg clientMessage) {
+	switch msg.Action {
+	case "subscribe", "unsubscribe":
+		for _, topic := range msg.Topics {
+			if !c.handler.topics[topic] {
+				c.replyError(msg.ID, "Unknown topic: "+topic)
+				return
+			}
+		}
+		if msg.Action == "subscribe" {
+			c.sub.Add(msg.Topics...)
+		} else {
+			c.sub.Remove(msg.Topics...)
+		}
+		c.reply(Event{Topic: topicConnection, Type: "subscriptions", ID: msg.ID, Data: map[string][]string{"topics": c.sub.Topics()}})
+	case "start":
+		c.start(msg)
+	case "cancel":
+		c.mu.Lock()
+		cancel, ok := c.streams[msg.ID]
+		c.mu.Unlock()
+		if !ok {
+			c.replyError(msg.ID, "No running stream with ID "+msg.ID)
+			return
+		}
+		cancel()
+	default:
+		c.replyError(msg.ID, fmt.Sprintf("Unknown action %q, want subscribe, unsubscribe, start or cancel", msg.Action))
+	}
+}
+
+// start runs an on-demand stream. Its events carry the client's ID, and it
+// ends with an "end" event, or "error" if it failed. A cancelled stream ends
+// with "end".
+func (c *connection) start(msg clientMessage) {
+	stream, ok := c.handler.streams[msg.Topic]
+	if !ok {
+		c.replyError(msg.ID, "Unknown stream: "+msg.Topic)
+		return
+	}
+	if msg.ID == "" {
+		c.replyError("", "A stream needs an id")
+		return
+	}
+
+	c.mu.Lock()
+	if _, running := c.streams[msg.ID]; running {
+		c.mu.Unlock()
+		c.replyError(msg.ID, "A stream with ID "+msg.ID+" is already running")
+		return
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.streams[msg.ID] = cancel
+	c.mu.Unlock()
+
+	send := func(eventType string, data any) {
+		select {
+		case c.out <- Event{Topic: msg.Topic, Type: eventType, ID: msg.ID, Data: data, Time: time.Now()}:
+		case <-ctx.Done():
+		}
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		err := stream(ctx, msg.Params, send)
+		cancelled := ctx.Err() != nil
+
+		c.mu.Lock()
+		delete(c.streams, msg.ID)
+		c.mu.Unlock()
+		cancel()
+
+		if err != nil && !cancelled {
+			c.reply(Event{Topic: msg.Topic, Type: "error", ID: msg.ID, Data: map[string]string{"message": err.Error()}})
+		} else {
+			c.reply(Event{Topic: msg.Topic, Type: "end", ID: msg.ID})
+		}
+	}()
+}
+
+// reply queues an event for the client unless the connection is done.
+func (c *connection) reply(event Event) {
+	event.Time = time.Now()
+	select {
+	case c.out <- event:
+	case <-c.ctx.Done():
+	}
+}
+
+func (c *connection) replyError(id, message string) {
+	c.reply(Event{Topic: topicConnection, Type: "error", ID: id, Data: map[string]string{"message": message}})
+}
@@ -0,0 +1,150 @@
+// Package realtime multiplexes the server's live events onto one WebSocket
+// connection per client. Broadcast topics such as job updates are published
+// to a Hub; on-demand streams such as a folder browse run for the
+// connection that starts them.
+package realtime
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Topics of the events published to the hub.
+const (
+	TopicJobs    = "jobs"
+	TopicWatcher = "watcher"
+	TopicExports = "exports"
+
+	// StreamBrowse is the on-demand stream listing folders
+	StreamBrowse = "browse"
+)
+
+// subscriberBuffer is how many events a slow client may fall behind before
+// further events to it are dropped.
+const subscriberBuffer = 256
+
+// Event is one message sent to a client.
+type Event struct {
+	Topic string    `json:"topic"`
+	Type  string    `json:"type"`
+	ID    string    `json:"id,omitempty"` // The client's ID of an on-demand stream
+	Data  any       `json:"data,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// Hub fans published events out to the subscribers of their topic.
+// Publishing never blocks: a subscriber whose buffer is full misses events
+// rather than stalling the publisher. A nil Hub drops everything, so
+// publishers need not check whether realtime events are wanted.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Publish sends an event to every subscriber of topic that has room for it.
+func (h *Hub) Publish(topic, eventType string, data any) {
+	if h == nil {
+		return
+	}
+	event := Event{Topic: topic, Type: eventType, Data: data, Time: time.Now()}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for s := range h.subscribers {
+		if !s.wants(topic) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a subscription to topics, which can be changed later.
+func (h *Hub) Subscribe(topics ...string) *Subscription {
+	s := &Subscription{
+		hub:    h,
+		events: make(chan Event, subscriberBuffer),
+		topics: make(map[string]bool),
+	}
+	s.Add(topics...)
+
+	h.mu.Lock()
+	h.subscribers[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// SubscriberCount returns the number of live subscriptions.
+func (h *Hub) SubscriberCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers)
+}
+
+// Subscription receives the events of the topics it is subscribed to.
+type Subscription struct {
+	hub    *Hub
+	events chan Event
+	once   sync.Once
+
+	mu     sync.RWMutex
+	topics map[string]bool
+}
+
+// Events returns the subscription's events. It is closed by Close.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Add subscribes to more topics.
+func (s *Subscription) Add(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, topic := range topics {
+		s.topics[topic] = true
+	}
+}
+
+// Remove unsubscribes from topics.
+func (s *Subscription) Remove(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, topic := range topics {
+		delete(s.topics, topic)
+	}
+}
+
+// Topics returns the subscribed topics, sorted.
+func (s *Subscription) Topics() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	topics := make([]string, 0, len(s.topics))
+	for topic := range s.topics {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+func (s *Subscription) wants(topic string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topics[topic]
+}
+
+// Close ends the subscription and closes its channel.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.mu.Lock()
+		delete(s.hub.subscribers, s)
+		s.hub.mu.Unlock()
+		close(s.events)
+	})
+}
@@ -0,0 +1,207 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestHubTopics(t *testing.T) {
+	var nilHub *Hub
+	nilHub.Publish(TopicJobs, "job.started", nil)
+
+	hub := NewHub()
+	sub := hub.Subscribe(TopicJobs)
+	hub.Publish(TopicWatcher, "file.created", nil)
+	hub.Publish(TopicJobs, "job.started", "a")
+	if got := <-sub.Events(); got.Type != "job.started" || got.Data != "a" {
+		t.Errorf("received %+v, want job.started", got)
+	}
+	if len(sub.Events()) != 0 {
+		t.Errorf("received an event of an unsubscribed topic")
+	}
+
+	sub.Add(TopicWatcher)
+	sub.Remove(TopicJobs)
+	hub.Publish(TopicJobs, "job.started", nil)
+	hub.Publish(TopicWatcher, "file.created", nil)
+	if got := <-sub.Events(); got.Topic != TopicWatcher {
+		t.Errorf("received %+v, want a watcher event", got)
+	}
+
+	// A full subscriber must not block the publisher
+	for i := 0; i < subscriberBuffer+10; i++ {
+		hub.Publish(TopicWatcher, "file.created", nil)
+	}
+	if len(sub.Events()) != subscriberBuffer {
+		t.Errorf("buffered %d events, want %d", len(sub.Events()), subscriberBuffer)
+	}
+
+	sub.Close()
+	sub.Close()
+	if hub.SubscriberCount() != 0 {
+		t.Errorf("SubscriberCount() after Close = %d, want 0", hub.SubscriberCount())
+	}
+}
+
+func dial(t *testing.T, h *Handler, query string) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(h.Connect))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/?"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func read(t *testing.T, conn *websocket.Conn) Event {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var event Event
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+// waitForSubscribers waits until the hub has n subscriptions, as a client's
+// subscription starts after its handshake.
+func waitForSubscribers(t *testing.T, hub *Hub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.SubscriberCount() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("SubscriberCount() = %d, want %d", hub.SubscriberCount(), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConnectSubscriptions(t *testing.T) {
+	hub := NewHub()
+	conn := dial(t, NewHandler(hub), "topics=jobs")
+	waitForSubscribers(t, hub, 1)
+
+	hub.Publish(TopicJobs, "job.completed", map[string]string{"job_id": "1"})
+	if got := read(t, conn); got.Topic != TopicJobs || got.Type != "job.completed" {
+		t.Errorf("received %+v, want job.completed", got)
+	}
+
+	conn.WriteJSON(clientMessage{Action: "subscribe", Topics: []string{TopicExports}})
+	got := read(t, conn)
+	if got.Type != "subscriptions" || !strings.Contains(toJSON(got.Data), `["exports","jobs"]`) {
+		t.Errorf("received %+v, want subscriptions exports and jobs", got)
+	}
+
+	conn.WriteJSON(clientMessage{Action: "subscribe", Topics: []string{"nope"}})
+	if got := read(t, conn); got.Topic != topicConnection || got.Type != "error" {
+		t.Errorf("unknown topic: received %+v, want an error", got)
+	}
+
+	conn.Close()
+	waitForSubscribers(t, hub, 0)
+}
+
+func TestConnectRejectsUnknownTopic(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(NewHandler(NewHub()).Connect))
+	defer server.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/?topics=nope", nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown topic: err %v, want 400", err)
+	}
+}
+
+func TestConnectChecksOrigin(t *testing.T) {
+	h := NewHandler(NewHub())
+	h.AllowOrigins([]string{"https://UI.example.com/"})
+	server := httptest.NewServer(http.HandlerFunc(h.Connect))
+	defer server.Close()
+
+	tests := []struct {
+		origin string
+		want   int
+	}{
+		{"", http.StatusSwitchingProtocols},
+		{server.URL, http.StatusSwitchingProtocols},
+		{"https://ui.example.com", http.StatusSwitchingProtocols},
+		{"http://ui.example.com", http.StatusForbidden},
+		{"https://evil.example.com", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.origin != "" {
+			header.Set("Origin", tt.origin)
+		}
+		conn, resp, _ := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/", header)
+		if conn != nil {
+			conn.Close()
+		}
+		if resp == nil || resp.StatusCode != tt.want {
+			t.Errorf("origin %q: response %v, want %d", tt.origin, resp, tt.want)
+		}
+	}
+}
+
+func TestStreams(t *testing.T) {
+	h := NewHandler(NewHub())
+	h.HandleStream("count", func(ctx context.Context, params json.RawMessage, send func(string, any)) error {
+		var n int
+		if err := json.Unmarshal(params, &n); err != nil {
+			return err
+		}
+		if n < 0 {
+			// Runs until cancelled
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		for i := 0; i < n; i++ {
+			send("item", i)
+		}
+		return nil
+	})
+	h.HandleStream("fail", func(context.Context, json.RawMessage, func(string, any)) error {
+		return errors.New("broken")
+	})
+	conn := dial(t, h, "")
+
+	conn.WriteJSON(clientMessage{Action: "start", Topic: "count", ID: "a", Params: json.RawMessage("2")})
+	for i, want := range []string{"item", "item", "end"} {
+		got := read(t, conn)
+		if got.Topic != "count" || got.ID != "a" || got.Type != want {
+			t.Errorf("event %d = %+v, want %s of stream a", i, got, want)
+		}
+	}
+
+	conn.WriteJSON(clientMessage{Action: "start", Topic: "fail", ID: "b"})
+	if got := read(t, conn); got.Type != "error" || got.ID != "b" || !strings.Contains(toJSON(got.Data), "broken") {
+		t.Errorf("failed stream: received %+v, want its error", got)
+	}
+
+	conn.WriteJSON(clientMessage{Action: "start", Topic: "count", ID: "c", Params: json.RawMessage("-1")})
+	conn.WriteJSON(clientMessage{Action: "cancel", ID: "c"})
+	if got := read(t, conn); got.Type != "end" || got.ID != "c" {
+		t.Errorf("cancelled stream: received %+v, want end", got)
+	}
+
+	conn.WriteJSON(clientMessage{Action: "start", Topic: "nope", ID: "d"})
+	if got := read(t, conn); got.Topic != topicConnection || got.Type != "error" {
+		t.Errorf("unknown stream: received %+v, want an error", got)
+	}
+}
+
+func toJSON(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,141 @@
+package routes
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+
+	"bronze-backend/config"
+	"bronze-backend/httputil"
+)
+
+// startTime is when the process started, for the uptime in runtime stats.
+var startTime = time.Now()
+
+// RuntimeStats is a snapshot of the Go runtime, for spotting goroutine leaks
+// and memory growth without attaching a profiler.
+type RuntimeStats struct {
+	GoVersion  string    `json:"go_version"`
+	Uptime     string    `json:"uptime"`
+	NumCPU     int       `json:"num_cpu"`
+	GOMAXPROCS int       `json:"gomaxprocs"`
+	Goroutines int       `json:"goroutines"`
+	Heap       HeapStats `json:"heap"`
+	GC         GCStats   `json:"gc"`
+}
+
+// HeapStats reports heap usage in bytes.
+type HeapStats struct {
+	Alloc        uint64 `json:"alloc"`          // Live objects
+	Sys          uint64 `json:"sys"`            // Obtained from the OS
+	Idle         uint64 `json:"idle"`           // Spans waiting to be reused or returned
+	Released     uint64 `json:"released"`       // Returned to the OS
+	Objects      uint64 `json:"objects"`        // Live objects, by count
+	TotalAlloc   uint64 `json:"total_alloc"`    // Cumulative bytes allocated
+	NextGCTarget uint64 `json:"next_gc_target"` // Heap size that triggers the next GC
+}
+
+// GCStats reports garbage collector activity.
+type GCStats struct {
+	NumGC         uint32  `json:"num_gc"`
+	LastGC        string  `json:"last_gc,omitempty"`
+	LastPause     string  `json:"last_pause"`
+	PauseTotal    string  `json:"pause_total"`
+	CPUFraction   float64 `json:"cpu_fraction"` // Share of CPU time spent in GC since start
+	ForcedGCCount uint32  `json:"forced_gc_count"`
+}
+
+// EnableDebug registers pprof under /api/debug/pprof/ and runtime statistics
+// at /api/debug/runtime. Nothing is registered unless cfg.Enabled is set.
+// They take cfg.Token rather than OIDC tokens, which profiling tools cannot
+// fetch, and refuse every request without one.
+func (r *Router) EnableDebug(cfg config.DebugConfig) {
+	if !cfg.Enabled {
+		return
+	}
+
+	debugRouter := r.router.PathPrefix("/api/debug").Subrouter()
+	debugRouter.Use(requireDebugToken(cfg.Token))
+	debugRouter.Use(noWriteDeadline)
+
+	debugRouter.HandleFunc("/runtime", r.runtimeStats).Methods("GET")
+
+	// pprof serves profiles by their path below /debug/pprof/
+	pprofHandler := func(h http.HandlerFunc) http.Handler {
+		return http.StripPrefix("/api", h)
+	}
+	debugRouter.Handle("/pprof/cmdline", pprofHandler(pprof.Cmdline))
+	debugRouter.Handle("/pprof/profile", pprofHandler(pprof.Profile))
+	debugRouter.Handle("/pprof/symbol", pprofHandler(pprof.Symbol))
+	debugRouter.Handle("/pprof/trace", pprofHandler(pprof.Trace))
+	debugRouter.PathPrefix("/pprof/").Handler(pprofHandler(pprof.Index))
+}
+
+// requireDebugToken rejects requests without the bearer token, and every
+// request if the token is empty.
+func requireDebugToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			got, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				httputil.Error(w, "Debug token required", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// noWriteDeadline lifts the server's write timeout, which would cut off CPU
+// profiles and traces that run for longer.
+func noWriteDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		err := http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			httputil.WriteError(w, "Failed to extend write deadline", http.StatusInternalServerError, err)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (r *Router) runtimeStats(w http.ResponseWriter, req *http.Request) {
+	httputil.WriteJSON(w, http.StatusOK, readRuntimeStats())
+}
+
+func readRuntimeStats() RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := RuntimeStats{
+		GoVersion:  runtime.Version(),
+		Uptime:     time.Since(startTime).Round(time.Second).String(),
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Goroutines: runtime.NumGoroutine(),
+		Heap: HeapStats{
+			Alloc:        mem.HeapAlloc,
+			Sys:          mem.HeapSys,
+			Idle:         mem.HeapIdle,
+			Released:     mem.HeapReleased,
+			Objects:      mem.HeapObjects,
+			TotalAlloc:   mem.TotalAlloc,
+			NextGCTarget: mem.NextGC,
+		},
+		GC: GCStats{
+			NumGC:         mem.NumGC,
+			LastPause:     time.Duration(mem.PauseNs[(mem.NumGC+255)%256]).String(),
+			PauseTotal:    time.Duration(mem.PauseTotalNs).String(),
+			CPUFraction:   mem.GCCPUFraction,
+			ForcedGCCount: mem.NumForcedGC,
+		},
+	}
+	if mem.LastGC > 0 {
+		stats.GC.LastGC = time.Unix(0, int64(mem.LastGC)).Format(time.RFC3339)
+	}
+	return stats
+}
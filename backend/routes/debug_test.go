@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bronze-backend/config"
+
+	"github.com/gorilla/mux"
+)
+
+func TestDebugEndpointsRequireToken(t *testing.T) {
+	r := &Router{router: mux.NewRouter()}
+	r.EnableDebug(config.DebugConfig{Enabled: true, Token: "secret"})
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		r.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("/api/debug/runtime", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without token: status %d, want 401", rec.Code)
+	}
+	if rec := get("/api/debug/runtime", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status %d, want 401", rec.Code)
+	}
+
+	rec := get("/api/debug/runtime", "secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("runtime: status %d, want 200", rec.Code)
+	}
+	var stats RuntimeStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Goroutines == 0 || stats.Heap.Alloc == 0 {
+		t.Errorf("implausible runtime stats: %+v", stats)
+	}
+
+	if rec := get("/api/debug/pprof/goroutine?debug=1", "secret"); rec.Code != http.StatusOK {
+		t.Errorf("pprof goroutine: status %d, want 200", rec.Code)
+	}
+}
+
+// Enabled without a token, which config.Load refuses, the endpoints stay
+// closed rather than open to anyone.
+func TestDebugEndpointsWithoutToken(t *testing.T) {
+	r := &Router{router: mux.NewRouter()}
+	r.EnableDebug(config.DebugConfig{Enabled: true})
+
+	for _, header := range []string{"", "Bearer ", "Bearer x"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/debug/pprof/cmdline", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		r.router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d, want 401", header, rec.Code)
+		}
+	}
+}
+
+func TestDebugEndpointsDisabledByDefault(t *testing.T) {
+	r := &Router{router: mux.NewRouter()}
+	r.EnableDebug(config.DebugConfig{})
+
+	rec := httptest.NewRecorder()
+	r.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/debug/runtime", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,279 @@
+package routes
+
+import (
+	"net/http"
+
+	"bronze-backend/audit"
+	"bronze-backend/config"
+	"bronze-backend/data_browser"
+	"bronze-backend/files"
+	"bronze-backend/graphapi"
+	"bronze-backend/health"
+	"bronze-backend/httputil"
+	"bronze-backend/idempotency"
+	"bronze-backend/jobs"
+	"bronze-backend/metering"
+	"bronze-backend/monitoring"
+	"bronze-backend/openapi"
+	"bronze-backend/quarantine"
+	"bronze-backend/storage"
+
+	"github.com/gorilla/mux"
+)
+
+var apiDocsInfo = openapi.Info{
+	Title:       "Bronze Backend API",
+	Description: "A Go backend with MinIO integration, file processing, and job management",
+	Version:     "1.0.0",
+}
+
+var apiDocsTags = []openapi.Tag{
+	{Name: "Health", Description: "Health checks and probes"},
+	{Name: "Info", Description: "API information and documentation"},
+	{Name: "Files", Description: "File and bucket operations"},
+	{Name: "Jobs", Description: "Job management and the worker pool"},
+	{Name: "Watcher", Description: "File watching, watch rules and auto-jobs"},
+	{Name: "Data", Description: "Data browsing, validation and exports"},
+	{Name: "Realtime", Description: "The WebSocket event channel"},
+	{Name: "GraphQL", Description: "Linked queries over files, jobs, exports and watcher events"},
+	{Name: "Usage", Description: "Per-caller usage totals and quotas"},
+	{Name: "Admin", Description: "Configuration, audit log and debugging"},
+}
+
+var (
+	limitParam  = openapi.Param{Name: "limit", Description: "Maximum number of results"}
+	prefixParam = openapi.Param{Name: "prefix", Description: "Object key prefix"}
+)
+
+// apiDocs documents every route, keyed by method and mux path template.
+// The OpenAPI document is built from the routes actually registered, and
+// TestAPIDocsMatchRoutes fails when a route has no entry here or an entry
+// has no route.
+var apiDocs = map[string]openapi.Operation{
+	"GET /api":              {Tag: "Health", Summary: "Health check", Response: map[string]string{}},
+	"GET /api/health":       {Tag: "Health", Summary: "Health check", Response: map[string]string{}},
+	"GET /healthz":          {Tag: "Health", Summary: "Liveness probe", Response: map[string]string{}},
+	"GET /readyz":           {Tag: "Health", Summary: "Readiness probe with per-dependency status; 503 while a critical dependency is down", Response: health.Report{}},
+	"GET /api/openapi.json": {Tag: "Info", Summary: "This OpenAPI document", Response: map[string]any{}},
+	"GET /api/version":      {Tag: "Info", Summary: "Build version, available features and request limits", Response: VersionResponse{}},
+
+	"GET /api/ws": {Tag: "Realtime", Summary: "Open a WebSocket carrying job, watcher, export and browse events",
+		Description: `Send {"action":"subscribe","topics":["jobs"]} or "unsubscribe" to change topics, {"action":"start","topic":"browse","id":"b1","params":{"folders":[...]}} to start a browse stream and {"action":"cancel","id":"b1"} to stop it.`,
+		Query:       []openapi.Param{{Name: "topics", Description: "Comma-separated topics to subscribe to on connect: jobs, watcher, exports"}},
+		Status:      http.StatusSwitchingProtocols},
+
+	"GET /api/graphql": {Tag: "GraphQL", Summary: "Run a GraphQL query",
+		Query:    []openapi.Param{{Name: "query", Description: "The GraphQL query"}, {Name: "variables", Description: "Query variables as a JSON object"}, {Name: "operationName", Description: "Operation to run when the query has several"}},
+		Response: map[string]any{}},
+	"POST /api/graphql": {Tag: "GraphQL", Summary: "Run a GraphQL query",
+		Description: "Nested fields follow links between objects, e.g. a job's sourceFile, its exports and their table. Queries may nest at most 10 levels.",
+		Request:     graphapi.Request{}, Response: map[string]any{}},
+
+	"POST /api/files/browse":                    {Tag: "Files", Summary: "Browse several folders at once", Request: files.MultiFolderRequest{}, Response: files.MultiFolderResponse{}},
+	"POST /api/files/upload":                    {Tag: "Files", Summary: "Upload a file", Headers: idempotencyHeaders, Multipart: []string{"object_name"}, Response: files.UploadResponse{}, Status: http.StatusCreated},
+	"GET /api/files/download/{filename:.+}":     {Tag: "Files", Summary: "Download a file", ContentType: "application/octet-stream"},
+	"GET /api/files/info/{filename:.+}":         {Tag: "Files", Summary: "Get file information", Response: files.FileInfoResponse{}},
+	"GET /api/files/presigned/{filename:.+}":    {Tag: "Files", Summary: "Get a presigned download URL", Query: []openapi.Param{{Name: "expiry", Description: "URL lifetime, e.g. 1h"}}, Response: map[string]any{}},
+	"POST /api/files/delete":                    {Tag: "Files", Summary: "Delete a file", Request: map[string]string{}, Response: files.DeleteResponse{}},
+	"POST /api/files/copy":                      {Tag: "Files", Summary: "Copy a file", Request: files.CopyFileRequest{}, Response: files.CopyFileResponse{}},
+	"POST /api/files/extract":                   {Tag: "Files", Summary: "Queue an archive extraction job", Headers: idempotencyHeaders, Request: map[string]any{}, Response: map[string]any{}},
+	"POST /api/files/archive-info":              {Tag: "Files", Summary: "Inspect an archive", Request: map[string]any{}, Response: map[string]any{}},
+	"POST /api/files/grep":                      {Tag: "Files", Summary: "Lines of the text files under a prefix matching a pattern", Request: files.GrepRequest{}, Response: files.GrepResponse{}},
+	"GET /api/quarantine":                       {Tag: "Files", Summary: "List files quarantined for failing to process, with why", Response: quarantine.ListResponse{}},
+	"GET /api/files":                            {Tag: "Files", Summary: "List files", Query: []openapi.Param{prefixParam, limitParam}, Response: files.FileListResponse{}},
+	"POST /api/files":                           {Tag: "Files", Summary: "List files under several prefixes", Request: files.BatchListRequest{}, Response: files.BatchListResponse{}},
+	"DELETE /api/files":                         {Tag: "Files", Summary: "Delete every file under a prefix", Query: []openapi.Param{prefixParam}, Response: files.DeleteResponse{}},
+	"GET /api/files/{filename:.+}":              {Tag: "Files", Summary: "Download a file", ContentType: "application/octet-stream"},
+	"GET /api/files/{filename:.+}/info":         {Tag: "Files", Summary: "Get file information", Response: files.FileInfoResponse{}},
+	"GET /api/files/{filename:.+}/presigned":    {Tag: "Files", Summary: "Get a presigned download URL", Query: []openapi.Param{{Name: "expiry", Description: "URL lifetime, e.g. 1h"}}, Response: map[string]any{}},
+	"DELETE /api/files/{filename:.+}":           {Tag: "Files", Summary: "Delete a file", Response: files.DeleteResponse{}},
+	"GET /api/buckets":                          {Tag: "Files", Summary: "List buckets", Response: files.BucketListResponse{}},
+	"GET /api/buckets/current":                  {Tag: "Files", Summary: "Get the bucket this client works in", Response: map[string]any{}},
+	"GET /api/buckets/status":                   {Tag: "Files", Summary: "Check the bucket this client works in", Response: map[string]any{}},
+	"POST /api/buckets/set":                     {Tag: "Files", Summary: "Switch the default bucket of every client", Request: map[string]string{}, Response: files.SetBucketResponse{}},
+	"POST /api/jobs":                            {Tag: "Jobs", Summary: "Create a job", Headers: idempotencyHeaders, Request: jobs.CreateJobRequest{}, Response: jobs.JobResponse{}, Status: http.StatusCreated},
+	"GET /api/jobs":                             {Tag: "Jobs", Summary: "List jobs", Query: jobListParams, Response: jobs.JobsListResponse{}},
+	"GET /api/jobs/stats":                       {Tag: "Jobs", Summary: "Queue and worker pool statistics", Response: jobs.JobStatsResponse{}},
+	"GET /api/jobs/metrics":                     {Tag: "Jobs", Summary: "Job throughput and latency metrics", Response: jobs.JobMetricsResponse{}},
+	"PUT /api/jobs/workers":                     {Tag: "Jobs", Summary: "Change the worker count", Request: jobs.UpdateWorkersRequest{}, Response: map[string]any{}},
+	"GET /api/jobs/workers/calculate-max":       {Tag: "Jobs", Summary: "Suggest a maximum worker count for this machine", Response: map[string]any{}},
+	"GET /api/jobs/workers/active":              {Tag: "Jobs", Summary: "List running jobs", Response: jobs.JobsListResponse{}},
+	"GET /api/jobs/workers/detail":              {Tag: "Jobs", Summary: "Per-worker state, flagging stuck jobs", Query: []openapi.Param{{Name: "stuck_after", Description: "Duration after which a running job counts as stuck"}}, Response: map[string]any{}},
+	"POST /api/jobs/cancel-batch":               {Tag: "Jobs", Summary: "Cancel the pending jobs matching filters", Request: jobs.CancelBatchRequest{}, Response: jobs.CancelBatchResponse{}},
+	"GET /api/jobs/dead-letter":                 {Tag: "Jobs", Summary: "List the failed jobs in the dead-letter queue", Response: jobs.DeadLetterListResponse{}},
+	"POST /api/jobs/dead-letter/{id}/retry":     {Tag: "Jobs", Summary: "Retry a dead-lettered job", Response: jobs.JobResponse{}},
+	"DELETE /api/jobs/dead-letter/{id}":         {Tag: "Jobs", Summary: "Discard a dead-lettered job", Response: jobs.JobResponse{}},
+	"GET /api/jobs/{id}":                        {Tag: "Jobs", Summary: "Get a job", Response: jobs.JobResponse{}},
+	"GET /api/jobs/{id}/result":                 {Tag: "Jobs", Summary: "Page through the files a job extracted", Query: []openapi.Param{{Name: "offset", Description: "Files to skip"}, {Name: "limit", Description: "Files per page, 1000 by default and at most 10000"}}, Response: jobs.JobResultResponse{}},
+	"DELETE /api/jobs/{id}":                     {Tag: "Jobs", Summary: "Cancel a job", Response: jobs.JobResponse{}},
+	"PUT /api/jobs/{id}/priority":               {Tag: "Jobs", Summary: "Change a job's priority", Request: jobs.UpdatePriorityRequest{}, Response: jobs.JobResponse{}},
+	"PUT /api/jobs/chains/{id}/priority":        {Tag: "Jobs", Summary: "Raise the priority of a job chain", Request: jobs.UpdatePriorityRequest{}, Response: jobs.ChainPriorityResponse{}},
+	"GET /api/watcher/events/unprocessed":       {Tag: "Watcher", Summary: "List unprocessed file events", Query: []openapi.Param{limitParam}, Response: map[string]any{}},
+	"GET /api/watcher/events/history":           {Tag: "Watcher", Summary: "List file event history", Query: []openapi.Param{limitParam}, Response: map[string]any{}},
+	"GET /api/watcher/events/stream":            {Tag: "Watcher", Summary: "Stream file events as server-sent events", Query: []openapi.Param{{Name: "rule", Description: "Only events of this watch rule"}}, ContentType: "text/event-stream"},
+	"POST /api/watcher/events/mark-processed":   {Tag: "Watcher", Summary: "Mark an event processed", Request: map[string]string{}, Response: map[string]any{}},
+	"GET /api/watcher/status":                   {Tag: "Watcher", Summary: "Watcher state", Response: monitoring.WatcherStatus{}},
+	"POST /api/watcher/pause":                   {Tag: "Watcher", Summary: "Pause the watcher", Response: monitoring.WatcherStatus{}},
+	"POST /api/watcher/resume":                  {Tag: "Watcher", Summary: "Resume the watcher", Response: monitoring.WatcherStatus{}},
+	"GET /api/watcher/rules":                    {Tag: "Watcher", Summary: "List watch rules", Response: map[string]any{}},
+	"PUT /api/watcher/rules/{name}":             {Tag: "Watcher", Summary: "Create or replace a watch rule", Request: monitoring.WatchRule{}, Response: map[string]any{}},
+	"DELETE /api/watcher/rules/{name}":          {Tag: "Watcher", Summary: "Delete a watch rule", Response: map[string]any{}},
+	"PUT /api/watcher/rules/{name}/filters":     {Tag: "Watcher", Summary: "Replace a watch rule's event filters", Request: monitoring.EventFilter{}, Response: map[string]any{}},
+	"POST /api/watcher/rules/{name}/backfill":   {Tag: "Watcher", Summary: "Start a backfill of a watch rule's existing objects", Response: map[string]any{}, Status: http.StatusAccepted},
+	"GET /api/watcher/rules/{name}/backfill":    {Tag: "Watcher", Summary: "Progress of a watch rule's last backfill", Response: monitoring.BackfillProgress{}},
+	"GET /api/watcher/auto-jobs":                {Tag: "Watcher", Summary: "List auto-job rules", Response: map[string]any{}},
+	"PUT /api/watcher/auto-jobs/{name}":         {Tag: "Watcher", Summary: "Create or replace an auto-job rule", Request: monitoring.AutoJobRule{}, Response: map[string]any{}},
+	"DELETE /api/watcher/auto-jobs/{name}":      {Tag: "Watcher", Summary: "Delete an auto-job rule", Response: map[string]any{}},
+	"POST /api/data/browse":                     {Tag: "Data", Summary: "Read rows from a data file", Request: data_browser.BrowseRequest{}, Response: data_browser.BrowseResponse{}},
+	"GET /api/data/files":                       {Tag: "Data", Summary: "List browsable data files", Response: data_browser.FileInfoListResponse{}},
+	"GET /api/data/versions":                    {Tag: "Data", Summary: "List the versions of a file, newest first", Query: versionParams, Response: map[string]any{}},
+	"POST /api/data/diff":                       {Tag: "Data", Summary: "Added, removed and changed rows between two files or versions, by key columns", Request: data_browser.DiffRequest{}, Response: map[string]any{}},
+	"POST /api/data/frequencies":                {Tag: "Data", Summary: "Most frequent values of columns of a file", Request: data_browser.FrequencyRequest{}, Response: map[string]any{}},
+	"POST /api/data/geo/preview":                {Tag: "Data", Summary: "Geographic column of a file as simplified GeoJSON features", Request: data_browser.GeoPreviewRequest{}, Response: map[string]any{}},
+	"GET /api/data/validation/suites":           {Tag: "Data", Summary: "List validation suites", Response: map[string]any{}},
+	"GET /api/data/validation/suites/{name}":    {Tag: "Data", Summary: "Get a validation suite", Response: data_browser.ValidationSuite{}},
+	"PUT /api/data/validation/suites/{name}":    {Tag: "Data", Summary: "Create or replace a validation suite", Request: data_browser.ValidationSuite{}, Response: map[string]any{}},
+	"DELETE /api/data/validation/suites/{name}": {Tag: "Data", Summary: "Delete a validation suite", Response: map[string]any{}},
+	"GET /api/data/transforms/sets":             {Tag: "Data", Summary: "List transform sets", Response: map[string]any{}},
+	"GET /api/data/transforms/sets/{name}":      {Tag: "Data", Summary: "Get a transform set", Response: data_browser.TransformSet{}},
+	"PUT /api/data/transforms/sets/{name}":      {Tag: "Data", Summary: "Create or replace a transform set", Request: data_browser.TransformSet{}, Response: map[string]any{}},
+	"DELETE /api/data/transforms/sets/{name}":   {Tag: "Data", Summary: "Delete a transform set", Response: map[string]any{}},
+	"POST /api/data/export-single":              {Tag: "Data", Summary: "Export one file to a Nessie table", Headers: idempotencyHeaders, Request: data_browser.ExportRequest{}, Response: data_browser.ExportResponse{}},
+	"POST /api/data/export-multiple":            {Tag: "Data", Summary: "Export several files to a Nessie table", Headers: idempotencyHeaders, Request: data_browser.ExportRequest{}, Response: data_browser.ExportResponse{}},
+	"POST /api/data/export-job":                 {Tag: "Data", Summary: "Queue an export job", Headers: idempotencyHeaders, Request: data_browser.ExportRequest{}, Response: map[string]any{}},
+	"GET /api/usage":                            {Tag: "Usage", Summary: "Usage this month, or in period, and the quotas", Query: usageParams, Response: metering.GetUsageResponse{}},
+	"GET /api/config":                           {Tag: "Admin", Summary: "Current settings, secrets redacted, and the settings schema", Response: ConfigResponse{}},
+	"PUT /api/config":                           {Tag: "Admin", Summary: "Validate, save and apply settings", Request: map[string]string{}, Response: map[string]any{}},
+	"GET /api/audit":                            {Tag: "Admin", Summary: "List audited changes, newest first", Query: auditParams, Response: audit.ListEntriesResponse{}},
+	"GET /api/debug/runtime":                    {Tag: "Admin", Summary: "Runtime statistics (debug endpoints only)", Response: RuntimeStats{}},
+
+	"GET /api/data/quality/suites":                       {Tag: "Data", Summary: "List the tables with quality suites", Response: map[string]any{}},
+	"GET /api/data/quality/suites/{database}/{table}":    {Tag: "Data", Summary: "Get a table's quality suite", Response: data_browser.QualitySuite{}},
+	"PUT /api/data/quality/suites/{database}/{table}":    {Tag: "Data", Summary: "Create or replace a table's quality suite", Request: data_browser.QualitySuite{}, Response: map[string]any{}},
+	"DELETE /api/data/quality/suites/{database}/{table}": {Tag: "Data", Summary: "Delete a table's quality suite and last result", Response: map[string]any{}},
+	"GET /api/data/quality/results/{database}/{table}":   {Tag: "Data", Summary: "Result of a table's last quality job", Response: data_browser.QualityResult{}},
+	"POST /api/data/branches/{branch}/merge":             {Tag: "Data", Summary: "Merge a Nessie branch unless a blocking quality suite fails", Request: data_browser.MergeRequest{}, Response: data_browser.MergeResponse{}},
+	"GET /api/lineage/tables/{table}/columns":            {Tag: "Data", Summary: "Source columns and transforms behind each column of a table", Query: lineageParams, Response: data_browser.TableLineage{}},
+	"GET /api/data/schema/registry/{database}/{table}":   {Tag: "Data", Summary: "Columns of a table's last load, as drift is checked against", Response: data_browser.RegisteredSchema{}},
+	"GET /api/data/schema/drift":                         {Tag: "Data", Summary: "List schema drift alerts, newest first", Query: driftParams, Response: map[string]any{}},
+	"POST /api/data/columns/suggestions":                 {Tag: "Data", Summary: "Score how source columns match a table's, for confirming column_matching", Request: data_browser.ColumnSuggestionRequest{}, Response: map[string]any{}},
+	"GET /api/catalog/namespaces":                        {Tag: "Data", Summary: "A page of the Nessie namespaces", Query: catalogPageParams, Response: storage.NessieNamespacePage{}},
+	"GET /api/catalog/tables":                            {Tag: "Data", Summary: "A page of the tables of a database", Query: catalogTableParams, Response: storage.NessieTablePage{}},
+}
+
+var lineageParams = []openapi.Param{
+	{Name: "database", Description: "Database of the table, default NESSIE_DEFAULT_DB"},
+	{Name: "column", Description: "Only this column"},
+}
+
+var driftParams = []openapi.Param{
+	{Name: "database", Description: "Only alerts of this database"},
+	{Name: "table", Description: "Only alerts of this table; requires database"},
+}
+
+var versionParams = []openapi.Param{
+	{Name: "file_name", Description: "The file; required"},
+}
+
+var catalogPageParams = []openapi.Param{
+	{Name: "limit", Description: "Entries per page, default 100, at most 1000"},
+	{Name: "page_token", Description: "next_page_token of the page before"},
+	{Name: "refresh", Description: "true to skip the catalog cache"},
+}
+
+var catalogTableParams = append([]openapi.Param{
+	{Name: "database", Description: "Database of the tables, default NESSIE_DEFAULT_DB"},
+}, catalogPageParams...)
+
+var jobListParams = []openapi.Param{
+	{Name: "status", Description: "pending, processing, completed, failed or cancelled"},
+	{Name: "type", Description: "Job type"},
+	{Name: "prefix", Description: "Object name prefix"},
+	{Name: "created_after", Description: "RFC 3339 time"},
+	{Name: "created_before", Description: "RFC 3339 time"},
+	{Name: "sort", Description: "Field to sort by"},
+	{Name: "order", Description: "asc or desc"},
+	limitParam,
+	{Name: "offset", Description: "Results to skip"},
+}
+
+var auditParams = []openapi.Param{
+	{Name: "subject", Description: "Caller's subject"},
+	{Name: "action", Description: "Method and route, e.g. DELETE /api/files/{filename}"},
+	{Name: "path", Description: "Request path prefix"},
+	{Name: "since", Description: "RFC 3339 time"},
+	{Name: "until", Description: "RFC 3339 time"},
+	limitParam,
+	{Name: "offset", Description: "Results to skip"},
+}
+
+var idempotencyHeaders = []openapi.Param{
+	{Name: idempotency.Header, Description: "Unique key; a retry with the same key and request gets the first response again"},
+}
+
+var usageParams = []openapi.Param{
+	{Name: "period", Description: "Month, YYYY-MM; defaults to the current one"},
+	{Name: "subject", Description: "Caller's subject; admins may name anyone, others only themselves"},
+}
+
+// ConfigResponse is the body of GET /api/config.
+type ConfigResponse struct {
+	Success  bool              `json:"success"`
+	Data     map[string]string `json:"data"`
+	Settings []config.Setting  `json:"settings"`
+}
+
+// routeDocKey identifies a route in apiDocs.
+func routeDocKey(method, path string) string {
+	return method + " " + path
+}
+
+// registeredRoutes lists every route with a method and path, along with the
+// least role allowed to call it.
+func (r *Router) registeredRoutes() []openapi.Route {
+	var routes []openapi.Route
+	r.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+
+		role := ""
+		if required, ok := r.roles[router]; ok {
+			role = required.String()
+		}
+		for _, method := range methods {
+			routes = append(routes, openapi.Route{Method: method, Path: path, Role: role})
+		}
+		return nil
+	})
+	return routes
+}
+
+// buildAPIDocs builds the OpenAPI document of the registered routes.
+// Undocumented routes are still listed, without a summary.
+func (r *Router) buildAPIDocs() *openapi.Document {
+	builder := openapi.NewBuilder(apiDocsInfo, apiDocsTags)
+	for _, route := range r.registeredRoutes() {
+		builder.Add(route, apiDocs[routeDocKey(route.Method, route.Path)])
+	}
+	return builder.Document()
+}
+
+// apiDocument returns the OpenAPI document, building it on first use so it
+// includes routes added after NewRouter, such as the probes.
+func (r *Router) apiDocument() *openapi.Document {
+	r.docsOnce.Do(func() {
+		r.docs = r.buildAPIDocs()
+	})
+	return r.docs
+}
+
+func (r *Router) openAPISpec(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	httputil.WriteJSON(w, http.StatusOK, r.apiDocument())
+}
@@ -0,0 +1,57 @@
+package routes
+
+import (
+	"testing"
+
+	"bronze-backend/config"
+	"bronze-backend/graphapi"
+	"bronze-backend/health"
+	"bronze-backend/metering"
+	"bronze-backend/quarantine"
+	"bronze-backend/realtime"
+)
+
+func TestAPIDocsMatchRoutes(t *testing.T) {
+	r := NewRouter(nil, nil, nil, nil, nil, nil, nil, nil)
+	r.EnableDebug(config.DebugConfig{Enabled: true, Token: "secret"})
+	r.EnableProbes(health.NewChecker())
+	r.EnableRealtime(realtime.NewHandler(realtime.NewHub()))
+	graphqlHandler, err := graphapi.NewHandler(graphapi.Sources{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.EnableGraphQL(graphqlHandler)
+	r.EnableUsage(metering.NewHandler(nil))
+	r.EnableQuarantine(quarantine.NewHandler(nil))
+
+	registered := make(map[string]bool)
+	for _, route := range r.registeredRoutes() {
+		key := routeDocKey(route.Method, route.Path)
+		registered[key] = true
+		if _, ok := apiDocs[key]; !ok {
+			t.Errorf("%s is not documented in apiDocs", key)
+		}
+	}
+	for key := range apiDocs {
+		if !registered[key] {
+			t.Errorf("apiDocs documents %s, which is not registered", key)
+		}
+	}
+
+	operationIDs := make(map[string]string)
+	for path, methods := range r.apiDocument().Paths {
+		for method, op := range methods {
+			if other, ok := operationIDs[op.OperationID]; ok {
+				t.Errorf("operationId %s used by %s %s and %s", op.OperationID, method, path, other)
+			}
+			operationIDs[op.OperationID] = method + " " + path
+		}
+	}
+
+	if op := r.apiDocument().Paths["/api/jobs/{id}"]["delete"]; op == nil || op.RequiredRole != "editor" {
+		t.Errorf("DELETE /api/jobs/{id} = %+v, want role editor", op)
+	}
+	if op := r.apiDocument().Paths["/healthz"]["get"]; op == nil || op.RequiredRole != "" || op.Security != nil {
+		t.Errorf("GET /healthz = %+v, want unauthenticated", op)
+	}
+}
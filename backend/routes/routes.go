@@ -1,22 +1,92 @@
 package routes
 
 import (
-	"bufio"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
-	"os"
-	"strings"
+	"sync"
 
+	"bronze-backend/audit"
+	"bronze-backend/auth"
+	"bronze-backend/bodylimit"
+	"bronze-backend/config"
 	"bronze-backend/data_browser"
 	"bronze-backend/files"
+	"bronze-backend/graphapi"
+	"bronze-backend/health"
+	"bronze-backend/httputil"
+	"bronze-backend/idempotency"
 	"bronze-backend/jobs"
+	"bronze-backend/metering"
 	"bronze-backend/monitoring"
+	"bronze-backend/openapi"
+	"bronze-backend/quarantine"
+	"bronze-backend/ratelimit"
+	"bronze-backend/realtime"
+	"bronze-backend/storage"
+	"bronze-backend/tenant"
+	"bronze-backend/tracing"
 	"github.com/gorilla/mux"
 )
 
 type Router struct {
-	router *mux.Router
+	router        *mux.Router
+	authenticator *auth.Authenticator
+	auditLog      *audit.Log
+	limiter       *ratelimit.Limiter
+	bodyLimiter   *bodylimit.Limiter
+	idempotency   *idempotency.Store
+	configManager *config.Manager
+	tenants       *tenant.Registry
+	buckets       *storage.MinIOClient
+
+	roles    map[*mux.Router]auth.Role // Least role allowed on each group's subrouters
+	docsOnce sync.Once
+	docs     *openapi.Document
+}
+
+// routeGroup splits the routes under one path prefix by the least role
+// allowed to call them, so each role's routes share one access check.
+// Editor and admin routes change things and are recorded in the audit log.
+// Every route is rate limited per client and has its request body size
+// limited. Once authenticated, each request is confined to its tenant and
+// works in the bucket its client selected.
+type routeGroup struct {
+	viewer *mux.Router
+	editor *mux.Router
+	admin  *mux.Router
+}
+
+// group returns the route group for prefix. Requests are matched against
+// the viewer routes first, then editor, then admin.
+func (r *Router) group(prefix string) routeGroup {
+	base := r.router.PathPrefix(prefix).Subrouter()
+	base.Use(r.authenticator.Identify, r.limiter.Limit, r.limitBody)
+	g := routeGroup{
+		viewer: base.NewRoute().Subrouter(),
+		editor: base.NewRoute().Subrouter(),
+		admin:  base.NewRoute().Subrouter(),
+	}
+	g.viewer.Use(r.authenticator.Require(auth.RoleViewer), r.resolveTenant, r.selectBucket)
+	g.editor.Use(r.authenticator.Require(auth.RoleEditor), r.resolveTenant, r.selectBucket, r.auditLog.Middleware)
+	g.admin.Use(r.authenticator.Require(auth.RoleAdmin), r.resolveTenant, r.selectBucket, r.auditLog.Middleware)
+
+	if r.roles == nil {
+		r.roles = make(map[*mux.Router]auth.Role)
+	}
+	r.roles[g.viewer] = auth.RoleViewer
+	r.roles[g.editor] = auth.RoleEditor
+	r.roles[g.admin] = auth.RoleAdmin
+	return g
+}
+
+// deploymentWide refuses requests confined to a tenant on every route of
+// the group, whose endpoints act on the whole deployment.
+func (g routeGroup) deploymentWide() routeGroup {
+	for _, sub := range []*mux.Router{g.viewer, g.editor, g.admin} {
+		sub.Use(tenant.Refuse)
+	}
+	return g
 }
 
 func NewRouter(
@@ -25,11 +95,17 @@ func NewRouter(
 	watcherHandler *monitoring.WatcherHandler,
 	dataBrowserHandler *data_browser.DataBrowserHandler,
 	exportHandler *data_browser.ExportHandler,
+	authenticator *auth.Authenticator,
+	auditLog *audit.Log,
+	limiter *ratelimit.Limiter,
 ) *Router {
 	router := mux.NewRouter()
 
 	r := &Router{
-		router: router,
+		router:        router,
+		authenticator: authenticator,
+		auditLog:      auditLog,
+		limiter:       limiter,
 	}
 
 	r.setupRoutes(fileHandler, jobHandler, watcherHandler, dataBrowserHandler, exportHandler)
@@ -37,6 +113,105 @@ func NewRouter(
 	return r
 }
 
+// EnableProbes serves the liveness probe at /healthz and the readiness probe
+// at /readyz. Like /api/health, they are neither authenticated nor rate
+// limited.
+func (r *Router) EnableProbes(checker *health.Checker) {
+	r.router.HandleFunc("/healthz", checker.Live).Methods("GET")
+	r.router.HandleFunc("/readyz", checker.Ready).Methods("GET")
+}
+
+// EnableRealtime serves the WebSocket event channel at /api/ws.
+func (r *Router) EnableRealtime(h *realtime.Handler) {
+	wsRouter := r.group("/api/ws").deploymentWide()
+	wsRouter.viewer.HandleFunc("", h.Connect).Methods("GET")
+}
+
+// EnableGraphQL serves GraphQL queries at /api/graphql.
+func (r *Router) EnableGraphQL(h *graphapi.Handler) {
+	graphqlRouter := r.group("/api/graphql")
+	graphqlRouter.viewer.HandleFunc("", r.limiter.Expensive(h.Serve)).Methods("GET", "POST")
+}
+
+// EnableUsage serves per-caller usage totals at /api/usage.
+func (r *Router) EnableUsage(h *metering.Handler) {
+	usageRouter := r.group("/api/usage")
+	usageRouter.viewer.HandleFunc("", h.GetUsage).Methods("GET")
+}
+
+// EnableQuarantine lists the quarantined source files at /api/quarantine.
+func (r *Router) EnableQuarantine(h *quarantine.Handler) {
+	quarantineRouter := r.group("/api/quarantine")
+	quarantineRouter.viewer.HandleFunc("", h.List).Methods("GET")
+}
+
+// SetBodyLimiter limits the size of request bodies.
+func (r *Router) SetBodyLimiter(l *bodylimit.Limiter) {
+	r.bodyLimiter = l
+}
+
+// limitBody applies the body limiter, which is set after the routes are.
+func (r *Router) limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.bodyLimiter.Middleware(next).ServeHTTP(w, req)
+	})
+}
+
+// SetIdempotency replays retried uploads, job creations and exports sent
+// with an idempotency key from store.
+func (r *Router) SetIdempotency(store *idempotency.Store) {
+	r.idempotency = store
+}
+
+// idempotent applies the idempotency store, which is set after the routes
+// are.
+func (r *Router) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		r.idempotency.Wrap(next)(w, req)
+	}
+}
+
+// SetTenants confines requests to the tenants of the registry.
+func (r *Router) SetTenants(tenants *tenant.Registry) {
+	r.tenants = tenants
+}
+
+// resolveTenant applies the tenant middleware, whose registry is set after
+// the routes are.
+func (r *Router) resolveTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.tenants.Middleware(next).ServeHTTP(w, req)
+	})
+}
+
+// SetBuckets lets clients select the buckets of m they may use per request.
+func (r *Router) SetBuckets(m *storage.MinIOClient) {
+	r.buckets = m
+}
+
+// selectBucket applies the bucket selection of the storage client, which is
+// set after the routes are.
+func (r *Router) selectBucket(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.buckets.SelectBucket(next).ServeHTTP(w, req)
+	})
+}
+
+// allowHeaders returns the request headers browsers may send cross-origin.
+func (r *Router) allowHeaders() string {
+	headers := "Content-Type, Authorization, " + storage.BucketHeader + ", " + idempotency.Header
+	if header := r.tenants.Header(); header != "" {
+		return headers + ", " + header
+	}
+	return headers
+}
+
+// SetConfigManager makes configuration updates take effect without a
+// restart where possible.
+func (r *Router) SetConfigManager(m *config.Manager) {
+	r.configManager = m
+}
+
 func (r *Router) setupRoutes(
 	fileHandler *files.FileHandler,
 	jobHandler *jobs.JobHandler,
@@ -44,12 +219,22 @@ func (r *Router) setupRoutes(
 	dataBrowserHandler *data_browser.DataBrowserHandler,
 	exportHandler *data_browser.ExportHandler,
 ) {
+	// Log every request and turn handler panics into JSON errors
+	r.router.Use(httputil.AccessLog)
+	r.router.Use(httputil.Recover)
+	r.router.NotFoundHandler = httputil.AccessLog(http.HandlerFunc(httputil.NotFound))
+	r.router.MethodNotAllowedHandler = httputil.AccessLog(http.HandlerFunc(httputil.MethodNotAllowed))
+
+	// Trace every request, named after its route
+	r.router.Use(tracing.Middleware)
+
 	// Add CORS middleware
+	allowHeaders := r.allowHeaders
 	r.router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", allowHeaders())
 
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
@@ -65,71 +250,138 @@ func (r *Router) setupRoutes(
 	r.router.HandleFunc("/api", r.healthCheck).Methods("GET")
 
 	// File routes - comprehensive endpoints
-	fileRouter := r.router.PathPrefix("/api/files").Subrouter()
-	
+	fileRouter := r.group("/api/files")
+
 	// New multi-folder endpoint
-	fileRouter.HandleFunc("/browse", fileHandler.MultiFolderBrowse).Methods("POST")
-	
+	fileRouter.viewer.HandleFunc("/browse", r.limiter.Expensive(fileHandler.MultiFolderBrowse)).Methods("POST")
+
 	// Specific operation endpoints
-	fileRouter.HandleFunc("/upload", fileHandler.UploadFile).Methods("POST")
-	fileRouter.HandleFunc("/download/{filename:.+}", fileHandler.DownloadFile).Methods("GET")
-	fileRouter.HandleFunc("/info/{filename:.+}", fileHandler.GetFileInfo).Methods("GET")
-	fileRouter.HandleFunc("/presigned/{filename:.+}", fileHandler.GetPresignedURL).Methods("GET")
-	fileRouter.HandleFunc("/delete", fileHandler.DeleteFile).Methods("POST")
-	fileRouter.HandleFunc("/copy", fileHandler.CopyFile).Methods("POST")
-	fileRouter.HandleFunc("/extract", fileHandler.ExtractArchive).Methods("POST")
-	
+	fileRouter.editor.HandleFunc("/upload", r.idempotent(fileHandler.UploadFile)).Methods("POST")
+	fileRouter.viewer.HandleFunc("/download/{filename:.+}", fileHandler.DownloadFile).Methods("GET")
+	fileRouter.viewer.HandleFunc("/info/{filename:.+}", fileHandler.GetFileInfo).Methods("GET")
+	fileRouter.viewer.HandleFunc("/presigned/{filename:.+}", fileHandler.GetPresignedURL).Methods("GET")
+	fileRouter.admin.HandleFunc("/delete", fileHandler.DeleteFile).Methods("POST")
+	fileRouter.editor.HandleFunc("/copy", fileHandler.CopyFile).Methods("POST")
+	fileRouter.editor.HandleFunc("/extract", r.limiter.Expensive(r.idempotent(fileHandler.ExtractArchive))).Methods("POST")
+	fileRouter.viewer.HandleFunc("/archive-info", r.limiter.Expensive(fileHandler.GetArchiveInfo)).Methods("POST")
+	fileRouter.viewer.HandleFunc("/grep", r.limiter.Expensive(fileHandler.GrepFiles)).Methods("POST")
+
 	// Legacy root-level endpoints for compatibility
-	fileRouter.HandleFunc("", fileHandler.ListFiles).Methods("GET")
-	fileRouter.HandleFunc("", fileHandler.BatchListFiles).Methods("POST")
-	fileRouter.HandleFunc("", fileHandler.DeleteFilesByPrefix).Methods("DELETE")
-	fileRouter.HandleFunc("/{filename:.+}", fileHandler.DownloadFile).Methods("GET")
-	fileRouter.HandleFunc("/{filename:.+}/info", fileHandler.GetFileInfo).Methods("GET")
-	fileRouter.HandleFunc("/{filename:.+}/presigned", fileHandler.GetPresignedURL).Methods("GET")
-	fileRouter.HandleFunc("/{filename:.+}", fileHandler.DeleteFile).Methods("DELETE")
+	fileRouter.viewer.HandleFunc("", fileHandler.ListFiles).Methods("GET")
+	fileRouter.viewer.HandleFunc("", fileHandler.BatchListFiles).Methods("POST")
+	fileRouter.admin.HandleFunc("", fileHandler.DeleteFilesByPrefix).Methods("DELETE")
+	fileRouter.viewer.HandleFunc("/{filename:.+}", fileHandler.DownloadFile).Methods("GET")
+	fileRouter.viewer.HandleFunc("/{filename:.+}/info", fileHandler.GetFileInfo).Methods("GET")
+	fileRouter.viewer.HandleFunc("/{filename:.+}/presigned", fileHandler.GetPresignedURL).Methods("GET")
+	fileRouter.admin.HandleFunc("/{filename:.+}", fileHandler.DeleteFile).Methods("DELETE")
 
 	// Bucket management routes
-	bucketRouter := r.router.PathPrefix("/api/buckets").Subrouter()
-	bucketRouter.HandleFunc("", fileHandler.ListBuckets).Methods("GET")
-	bucketRouter.HandleFunc("/current", fileHandler.GetCurrentBucket).Methods("GET")
-	bucketRouter.HandleFunc("/status", fileHandler.GetBucketStatus).Methods("GET")
-	bucketRouter.HandleFunc("/set", fileHandler.SetBucket).Methods("POST")
+	bucketRouter := r.group("/api/buckets")
+	bucketRouter.viewer.Handle("", tenant.Refuse(http.HandlerFunc(fileHandler.ListBuckets))).Methods("GET")
+	bucketRouter.viewer.HandleFunc("/current", fileHandler.GetCurrentBucket).Methods("GET")
+	bucketRouter.viewer.Handle("/status", tenant.Refuse(http.HandlerFunc(fileHandler.GetBucketStatus))).Methods("GET")
+	bucketRouter.admin.Handle("/set", tenant.Refuse(http.HandlerFunc(fileHandler.SetBucket))).Methods("POST")
 
 	// Job routes
-	jobRouter := r.router.PathPrefix("/api/jobs").Subrouter()
-	jobRouter.HandleFunc("", jobHandler.CreateJob).Methods("POST")
-	jobRouter.HandleFunc("", jobHandler.GetJobs).Methods("GET")
-	jobRouter.HandleFunc("/stats", jobHandler.GetStats).Methods("GET")
-	jobRouter.HandleFunc("/workers", jobHandler.UpdateWorkerCount).Methods("PUT")
-	jobRouter.HandleFunc("/workers/calculate-max", jobHandler.CalculateMaxWorkers).Methods("GET")
-	jobRouter.HandleFunc("/workers/active", jobHandler.GetActiveJobs).Methods("GET")
-	jobRouter.HandleFunc("/{id}", jobHandler.GetJob).Methods("GET")
-	jobRouter.HandleFunc("/{id}", jobHandler.CancelJob).Methods("DELETE")
-	jobRouter.HandleFunc("/{id}/priority", jobHandler.UpdateJobPriority).Methods("PUT")
+	jobRouter := r.group("/api/jobs")
+	jobRouter.editor.HandleFunc("", r.limiter.Expensive(r.idempotent(jobHandler.CreateJob))).Methods("POST")
+	jobRouter.viewer.HandleFunc("", jobHandler.GetJobs).Methods("GET")
+	jobRouter.viewer.HandleFunc("/stats", jobHandler.GetStats).Methods("GET")
+	jobRouter.viewer.HandleFunc("/metrics", jobHandler.GetMetrics).Methods("GET")
+	jobRouter.admin.Handle("/workers", tenant.Refuse(http.HandlerFunc(jobHandler.UpdateWorkerCount))).Methods("PUT")
+	jobRouter.viewer.HandleFunc("/workers/calculate-max", jobHandler.CalculateMaxWorkers).Methods("GET")
+	jobRouter.viewer.HandleFunc("/workers/active", jobHandler.GetActiveJobs).Methods("GET")
+	jobRouter.viewer.Handle("/workers/detail", tenant.Refuse(http.HandlerFunc(jobHandler.GetWorkerDetails))).Methods("GET")
+	jobRouter.editor.HandleFunc("/cancel-batch", jobHandler.CancelBatch).Methods("POST")
+	jobRouter.viewer.HandleFunc("/dead-letter", jobHandler.ListDeadLetters).Methods("GET")
+	jobRouter.editor.HandleFunc("/dead-letter/{id}/retry", jobHandler.RetryDeadLetter).Methods("POST")
+	jobRouter.editor.HandleFunc("/dead-letter/{id}", jobHandler.DiscardDeadLetter).Methods("DELETE")
+	jobRouter.viewer.HandleFunc("/{id}", jobHandler.GetJob).Methods("GET")
+	jobRouter.viewer.HandleFunc("/{id}/result", jobHandler.GetJobResult).Methods("GET")
+	jobRouter.editor.HandleFunc("/{id}", jobHandler.CancelJob).Methods("DELETE")
+	jobRouter.editor.HandleFunc("/{id}/priority", jobHandler.UpdateJobPriority).Methods("PUT")
+	jobRouter.editor.HandleFunc("/chains/{id}/priority", jobHandler.BoostChain).Methods("PUT")
 
 	// Watcher routes
-	watcherRouter := r.router.PathPrefix("/api/watcher").Subrouter()
-	watcherRouter.HandleFunc("/events/unprocessed", watcherHandler.GetUnprocessedEvents).Methods("GET")
-	watcherRouter.HandleFunc("/events/history", watcherHandler.GetEventHistory).Methods("GET")
-	watcherRouter.HandleFunc("/events/mark-processed", watcherHandler.MarkEventProcessed).Methods("POST")
+	watcherRouter := r.group("/api/watcher").deploymentWide()
+	watcherRouter.viewer.HandleFunc("/events/unprocessed", watcherHandler.GetUnprocessedEvents).Methods("GET")
+	watcherRouter.viewer.HandleFunc("/events/history", watcherHandler.GetEventHistory).Methods("GET")
+	watcherRouter.viewer.HandleFunc("/events/stream", watcherHandler.StreamEvents).Methods("GET")
+	watcherRouter.editor.HandleFunc("/events/mark-processed", watcherHandler.MarkEventProcessed).Methods("POST")
+	watcherRouter.viewer.HandleFunc("/status", watcherHandler.GetStatus).Methods("GET")
+	watcherRouter.admin.HandleFunc("/pause", watcherHandler.Pause).Methods("POST")
+	watcherRouter.admin.HandleFunc("/resume", watcherHandler.Resume).Methods("POST")
+	watcherRouter.viewer.HandleFunc("/rules", watcherHandler.GetRules).Methods("GET")
+	watcherRouter.admin.HandleFunc("/rules/{name}", watcherHandler.SaveRule).Methods("PUT")
+	watcherRouter.admin.HandleFunc("/rules/{name}", watcherHandler.DeleteRule).Methods("DELETE")
+	watcherRouter.admin.HandleFunc("/rules/{name}/filters", watcherHandler.UpdateRuleFilters).Methods("PUT")
+	watcherRouter.editor.HandleFunc("/rules/{name}/backfill", r.limiter.Expensive(watcherHandler.StartBackfill)).Methods("POST")
+	watcherRouter.viewer.HandleFunc("/rules/{name}/backfill", watcherHandler.GetBackfill).Methods("GET")
+	watcherRouter.viewer.HandleFunc("/auto-jobs", watcherHandler.ListAutoJobRules).Methods("GET")
+	watcherRouter.admin.HandleFunc("/auto-jobs/{name}", watcherHandler.SaveAutoJobRule).Methods("PUT")
+	watcherRouter.admin.HandleFunc("/auto-jobs/{name}", watcherHandler.DeleteAutoJobRule).Methods("DELETE")
 
 	// Data browser routes
-	dataRouter := r.router.PathPrefix("/api/data").Subrouter()
-	dataRouter.HandleFunc("/browse", dataBrowserHandler.BrowseData).Methods("POST")
-	dataRouter.HandleFunc("/files", dataBrowserHandler.ListDataFiles).Methods("GET")
+	dataRouter := r.group("/api/data")
+	dataRouter.viewer.HandleFunc("/browse", r.limiter.Expensive(dataBrowserHandler.BrowseData)).Methods("POST")
+	dataRouter.viewer.HandleFunc("/files", dataBrowserHandler.ListDataFiles).Methods("GET")
+	dataRouter.viewer.HandleFunc("/versions", dataBrowserHandler.ListFileVersions).Methods("GET")
+	dataRouter.viewer.HandleFunc("/diff", r.limiter.Expensive(dataBrowserHandler.DiffData)).Methods("POST")
+	dataRouter.viewer.HandleFunc("/frequencies", r.limiter.Expensive(dataBrowserHandler.GetValueFrequencies)).Methods("POST")
+	dataRouter.viewer.HandleFunc("/geo/preview", r.limiter.Expensive(dataBrowserHandler.PreviewGeoData)).Methods("POST")
+
+	// Validation suite routes
+	dataRouter.viewer.HandleFunc("/validation/suites", dataBrowserHandler.ListValidationSuites).Methods("GET")
+	dataRouter.viewer.HandleFunc("/validation/suites/{name}", dataBrowserHandler.GetValidationSuite).Methods("GET")
+	dataRouter.editor.HandleFunc("/validation/suites/{name}", dataBrowserHandler.SaveValidationSuite).Methods("PUT")
+	dataRouter.admin.HandleFunc("/validation/suites/{name}", dataBrowserHandler.DeleteValidationSuite).Methods("DELETE")
+
+	// Transform set routes, for promote jobs
+	dataRouter.viewer.HandleFunc("/transforms/sets", dataBrowserHandler.ListTransformSets).Methods("GET")
+	dataRouter.viewer.HandleFunc("/transforms/sets/{name}", dataBrowserHandler.GetTransformSet).Methods("GET")
+	dataRouter.editor.HandleFunc("/transforms/sets/{name}", dataBrowserHandler.SaveTransformSet).Methods("PUT")
+	dataRouter.admin.HandleFunc("/transforms/sets/{name}", dataBrowserHandler.DeleteTransformSet).Methods("DELETE")
+
+	// Quality suite routes; a failing suite can block branch merges
+	dataRouter.viewer.HandleFunc("/quality/suites", dataBrowserHandler.ListQualitySuites).Methods("GET")
+	dataRouter.viewer.HandleFunc("/quality/suites/{database}/{table}", dataBrowserHandler.GetQualitySuite).Methods("GET")
+	dataRouter.editor.HandleFunc("/quality/suites/{database}/{table}", dataBrowserHandler.SaveQualitySuite).Methods("PUT")
+	dataRouter.admin.HandleFunc("/quality/suites/{database}/{table}", dataBrowserHandler.DeleteQualitySuite).Methods("DELETE")
+	dataRouter.viewer.HandleFunc("/quality/results/{database}/{table}", dataBrowserHandler.GetQualityResult).Methods("GET")
+	dataRouter.admin.HandleFunc("/branches/{branch}/merge", exportHandler.MergeBranch).Methods("POST")
+
+	// Schema registry routes; exports alert when a load drifts from it
+	dataRouter.viewer.HandleFunc("/schema/registry/{database}/{table}", dataBrowserHandler.GetRegisteredSchema).Methods("GET")
+	dataRouter.viewer.HandleFunc("/schema/drift", dataBrowserHandler.ListDriftAlerts).Methods("GET")
 
 	// Export routes
-	dataRouter.HandleFunc("/export-single", exportHandler.ExportSingleFile).Methods("POST")
-	dataRouter.HandleFunc("/export-multiple", exportHandler.ExportMultipleFiles).Methods("POST")
-	dataRouter.HandleFunc("/export-job", exportHandler.CreateExportJob).Methods("POST")
+	dataRouter.editor.HandleFunc("/export-single", r.limiter.Expensive(r.idempotent(exportHandler.ExportSingleFile))).Methods("POST")
+	dataRouter.editor.HandleFunc("/export-multiple", r.limiter.Expensive(r.idempotent(exportHandler.ExportMultipleFiles))).Methods("POST")
+	dataRouter.editor.HandleFunc("/export-job", r.limiter.Expensive(r.idempotent(exportHandler.CreateExportJob))).Methods("POST")
+	dataRouter.viewer.HandleFunc("/columns/suggestions", exportHandler.SuggestColumnMappings).Methods("POST")
+
+	// Lineage routes
+	lineageRouter := r.group("/api/lineage")
+	lineageRouter.viewer.HandleFunc("/tables/{table}/columns", exportHandler.GetColumnLineage).Methods("GET")
+
+	// Catalog routes, paginated and cached listings of Nessie
+	catalogRouter := r.group("/api/catalog")
+	catalogRouter.viewer.HandleFunc("/namespaces", exportHandler.ListNamespaces).Methods("GET")
+	catalogRouter.viewer.HandleFunc("/tables", exportHandler.ListTables).Methods("GET")
 
 	// Configuration routes
-	r.router.HandleFunc("/api/config", r.getConfig).Methods("GET")
-	r.router.HandleFunc("/api/config", r.updateConfig).Methods("PUT")
+	configRouter := r.group("/api/config").deploymentWide()
+	configRouter.admin.HandleFunc("", r.getConfig).Methods("GET")
+	configRouter.admin.HandleFunc("", r.updateConfig).Methods("PUT")
+
+	// Audit log routes
+	auditRouter := r.group("/api/audit").deploymentWide()
+	auditRouter.admin.HandleFunc("", audit.NewHandler(r.auditLog).ListEntries).Methods("GET")
 
 	// API documentation routes
 	r.router.HandleFunc("/api", r.apiInfo).Methods("GET")
 	r.router.HandleFunc("/api/openapi.json", r.openAPISpec).Methods("GET")
+	r.router.HandleFunc("/api/version", r.version(exportHandler, watcherHandler)).Methods("GET")
 }
 
 func (r *Router) GetRouter() *mux.Router {
@@ -150,194 +402,18 @@ func (r *Router) healthCheck(w http.ResponseWriter, req *http.Request) {
 
 func (r *Router) apiInfo(w http.ResponseWriter, req *http.Request) {
 	apiInfo := map[string]any{
-		"name":        "Bronze Backend API",
-		"version":     "1.0.0",
-		"description": "A Go backend with MinIO integration, file processing, and job management",
+		"name":        apiDocsInfo.Title,
+		"version":     apiDocsInfo.Version,
+		"description": apiDocsInfo.Description,
 		"openapi":     "/api/openapi.json",
-		"endpoints": map[string]any{
-			"files": map[string]any{
-				"browse": map[string]any{
-					"method": "POST",
-					"path":   "/api/files/browse",
-					"description": "Browse multiple folders with rich metadata and recursive options",
-					"body": map[string]any{
-						"folders": "[]FolderRequest - Array of folder requests with options",
-						"limit":   "int (optional) - Maximum items per folder",
-					},
-				},
-				"upload": map[string]any{
-					"method":      "POST",
-					"path":        "/api/files/upload",
-					"description": "Upload a file to MinIO",
-					"body":        "multipart/form-data with file field",
-				},
-				"download": map[string]any{
-					"method":      "GET",
-					"path":        "/api/files/download/{filename}",
-					"description": "Download a specific file",
-				},
-				"info": map[string]any{
-					"method":      "GET",
-					"path":        "/api/files/info/{filename}",
-					"description": "Get file information",
-				},
-				"delete": map[string]any{
-					"method":      "POST",
-					"path":        "/api/files/delete",
-					"description": "Delete a specific file",
-					"body": map[string]any{
-						"filename": "string - File to delete",
-					},
-				},
-				"presigned": map[string]any{
-					"method":       "GET",
-					"path":         "/api/files/presigned/{filename}",
-					"description":  "Generate presigned URL for file access",
-					"query_params": []string{"expiry"},
-				},
-				"copy": map[string]any{
-					"method":      "POST",
-					"path":        "/api/files/copy",
-					"description": "Copy a file to a new location",
-					"body": map[string]any{
-						"source":      "string - Source file path",
-						"destination": "string - Destination file path",
-					},
-				},
-				"extract": map[string]any{
-					"method":      "POST",
-					"path":        "/api/files/extract",
-					"description": "Extract archive files (ZIP, TAR, TAR.GZ)",
-					"body": map[string]any{
-						"filename":           "string - Archive file to extract",
-						"destination_folder":  "string (optional) - Extract to specific folder",
-						"delete_after":       "bool (optional) - Delete archive after extraction",
-					},
-				},
-			},
-			"buckets": map[string]any{
-				"list": map[string]any{
-					"method":      "GET",
-					"path":        "/api/buckets",
-					"description": "List all available buckets",
-				},
-				"current": map[string]any{
-					"method":      "GET",
-					"path":        "/api/buckets/current",
-					"description": "Get currently active bucket",
-				},
-				"status": map[string]any{
-					"method":      "GET",
-					"path":        "/api/buckets/status",
-					"description": "Get bucket status and availability",
-				},
-				"set": map[string]any{
-					"method":      "POST",
-					"path":        "/api/buckets/set",
-					"description": "Set active bucket",
-					"body": map[string]any{
-						"bucket_name": "string",
-					},
-				},
-			},
-			"jobs": map[string]any{
-				"create": map[string]any{
-					"method":      "POST",
-					"path":        "/api/jobs",
-					"description": "Create a new processing job",
-				},
-				"list": map[string]any{
-					"method":       "GET",
-					"path":         "/api/jobs",
-					"description":  "List all jobs",
-					"query_params": []string{"status"},
-				},
-				"get": map[string]any{
-					"method":      "GET",
-					"path":        "/api/jobs/{id}",
-					"description": "Get specific job details",
-				},
-				"cancel": map[string]any{
-					"method":      "DELETE",
-					"path":        "/api/jobs/{id}",
-					"description": "Cancel a specific job",
-				},
-				"update_priority": map[string]any{
-					"method":      "PUT",
-					"path":        "/api/jobs/{id}/priority",
-					"description": "Update job priority",
-				},
-				"stats": map[string]any{
-					"method":      "GET",
-					"path":        "/api/jobs/stats",
-					"description": "Get job queue and worker statistics",
-				},
-				"update_workers": map[string]any{
-					"method":      "PUT",
-					"path":        "/api/jobs/workers",
-					"description": "Update worker pool size",
-				},
-				"calculate_max_workers": map[string]any{
-					"method":      "GET",
-					"path":        "/api/jobs/workers/calculate-max",
-					"description": "Calculate optimal number of workers based on CPU cores",
-				},
-				"active_jobs": map[string]any{
-					"method":      "GET",
-					"path":        "/api/jobs/workers/active",
-					"description": "Get currently active jobs",
-				},
-			},
-			"data": map[string]any{
-				"browse": map[string]any{
-					"method":      "POST",
-					"path":        "/api/data/browse",
-					"description": "Browse data from Excel (XLSX, XLS, XLSM), CSV, or MDB files in S3",
-					"body": map[string]any{
-						"file_name":           "string (required)",
-						"sheet_name":          "string (optional, for Excel files)",
-						"max_rows":            "int (optional, default 100, max 10000)",
-						"offset":              "int (optional, default 0)",
-						"has_headers":         "bool (optional, default false)",
-						"treat_as_csv":        "bool (optional, default false)",
-						"auto_detect_headers": "bool (optional, default false)",
-						"stream_mode":         "bool (optional, default false)",
-						"chunk_size":          "int (optional, default 1000, streaming only)",
-					},
-				},
-				"files": map[string]any{
-					"method":      "GET",
-					"path":        "/api/data/files",
-					"description": "List all supported data files (Excel XLSX/XLS/XLSM, CSV, MDB)",
-				},
-			},
-			"watcher": map[string]any{
-				"unprocessed_events": map[string]any{
-					"method":       "GET",
-					"path":         "/api/watcher/events/unprocessed",
-					"description":  "Get unprocessed file change events",
-					"query_params": []string{"limit"},
-				},
-				"event_history": map[string]any{
-					"method":       "GET",
-					"path":         "/api/watcher/events/history",
-					"description":  "Get file change event history",
-					"query_params": []string{"limit"},
-				},
-				"mark_processed": map[string]any{
-					"method":      "POST",
-					"path":        "/api/watcher/events/mark-processed",
-					"description": "Mark a file event as processed",
-				},
-			},
-		},
+		"endpoints":   r.apiDocument().Endpoints(),
 		"features": []string{
 			"MinIO object storage integration",
 			"File upload/download/management",
 			"Bucket management and selection",
 			"Priority-based job queue",
 			"Configurable worker pool",
-			"Archive decompression (ZIP, TAR, TAR.GZ)",
+			"Archive decompression (ZIP, TAR, GZIP, BZIP2, XZ, ZSTD, 7Z, RAR)",
 			"File processing pipeline",
 			"Real-time job tracking",
 			"File watching and change tracking",
@@ -357,119 +433,49 @@ func (r *Router) apiInfo(w http.ResponseWriter, req *http.Request) {
 }
 
 func (r *Router) getConfig(w http.ResponseWriter, req *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	// Read current .env file
-	envData := make(map[string]string)
-
-	// Default values
-	envData["SERVER_HOST"] = "localhost"
-	envData["SERVER_PORT"] = "8060"
-	envData["MINIO_ENDPOINT"] = "localhost:9000"
-	envData["MINIO_ACCESS_KEY"] = "minioadmin"
-	envData["MINIO_SECRET_KEY"] = "minioadmin"
-	envData["MINIO_USE_SSL"] = "false"
-	envData["MINIO_BUCKET"] = "files"
-	envData["MINIO_REGION"] = "us-east-1"
-	envData["MAX_WORKERS"] = "3"
-	envData["QUEUE_SIZE"] = "100"
-	envData["WATCH_INTERVAL"] = "5s"
-	envData["TEMP_DIR"] = "/tmp/bronze"
-	envData["DECOMPRESSION_ENABLED"] = "true"
-	envData["MAX_EXTRACT_SIZE"] = "1GB"
-	envData["MAX_FILES_PER_ARCHIVE"] = "1000"
-	envData["NESTED_ARCHIVE_DEPTH"] = "3"
-	envData["PASSWORD_PROTECTED"] = "true"
-	envData["EXTRACT_TO_SUBFOLDER"] = "true"
-
-	// Try to read actual .env file
-	if envFile, err := os.Open(".env"); err == nil {
-		defer envFile.Close()
-		scanner := bufio.NewScanner(envFile)
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			if line != "" && !strings.HasPrefix(line, "#") {
-				if parts := strings.SplitN(line, "=", 2); len(parts) == 2 {
-					key := strings.TrimSpace(parts[0])
-					value := strings.TrimSpace(parts[1])
-					envData[key] = value
-				}
-			}
-		}
+	if r.configManager == nil {
+		httputil.Error(w, "Configuration manager is not available", http.StatusServiceUnavailable)
+		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"data":    envData,
+	httputil.WriteJSON(w, http.StatusOK, ConfigResponse{
+		Success:  true,
+		Data:     r.configManager.Values(),
+		Settings: config.Settings(),
 	})
 }
 
 func (r *Router) updateConfig(w http.ResponseWriter, req *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	var updates map[string]string
-	if err := json.NewDecoder(req.Body).Decode(&updates); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"error":   "Invalid JSON",
-		})
+	if r.configManager == nil {
+		httputil.Error(w, "Configuration manager is not available", http.StatusServiceUnavailable)
 		return
 	}
 
-	// Read existing .env file
-	envFile, err := os.Open(".env")
-	var envLines []string
-	if err == nil {
-		defer envFile.Close()
-		scanner := bufio.NewScanner(envFile)
-		for scanner.Scan() {
-			envLines = append(envLines, scanner.Text())
-		}
+	var updates map[string]string
+	if err := json.NewDecoder(req.Body).Decode(&updates); err != nil {
+		httputil.WriteError(w, "Invalid JSON", http.StatusBadRequest, err)
+		return
 	}
 
-	// Update values in memory
-	for key, value := range updates {
-		// Find and replace existing line or add new one
-		found := false
-		for i, line := range envLines {
-			if strings.HasPrefix(strings.TrimSpace(line), key+"=") {
-				envLines[i] = fmt.Sprintf("%s=%s", key, value)
-				found = true
-				break
-			}
-		}
-		if !found {
-			envLines = append(envLines, fmt.Sprintf("%s=%s", key, value))
-		}
+	result, err := r.configManager.Update(updates)
+	if errors.Is(err, config.ErrInvalidSetting) {
+		httputil.WriteError(w, "Invalid configuration", http.StatusBadRequest, err)
+		return
 	}
-
-	// Write back to .env file
-	if err := os.WriteFile(".env", []byte(strings.Join(envLines, "\n")), 0644); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"error":   fmt.Sprintf("Failed to write .env file: %v", err),
-		})
+	if err != nil {
+		httputil.WriteError(w, "Failed to update configuration", http.StatusInternalServerError, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "Configuration updated successfully",
-		"data":    updates,
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"success":          true,
+		"message":          "Configuration updated successfully",
+		"data":             config.Redact(updates),
+		"applied":          result.Applied,
+		"restart_required": result.RestartRequired,
 	})
 }
-
-func (r *Router) openAPISpec(w http.ResponseWriter, req *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.WriteHeader(http.StatusOK)
-
-	// Read and serve OpenAPI JSON file
-	http.ServeFile(w, req, "openapi.json")
-}
@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// Routes sharing a path across role groups must still dispatch by method.
+func TestRouteGroupDispatchesAcrossRoles(t *testing.T) {
+	r := &Router{router: mux.NewRouter()}
+	r.router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	})
+
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(name + " " + mux.Vars(req)["filename"]))
+		}
+	}
+	group := r.group("/api/files")
+	group.editor.HandleFunc("/upload", handler("upload")).Methods("POST")
+	group.viewer.HandleFunc("/{filename:.+}", handler("download")).Methods("GET")
+	group.admin.HandleFunc("/{filename:.+}", handler("delete")).Methods("DELETE")
+
+	tests := []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{http.MethodGet, "/api/files/a/b.csv", http.StatusOK, "download a/b.csv"},
+		{http.MethodDelete, "/api/files/a/b.csv", http.StatusOK, "delete a/b.csv"},
+		{http.MethodPost, "/api/files/upload", http.StatusOK, "upload "},
+		{http.MethodPut, "/api/files/a/b.csv", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r.router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.status || rec.Body.String() != tt.body {
+			t.Errorf("%s %s: got %d %q, want %d %q", tt.method, tt.path, rec.Code, rec.Body.String(), tt.status, tt.body)
+		}
+	}
+}
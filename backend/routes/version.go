@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"net/http"
+
+	"bronze-backend/buildinfo"
+	"bronze-backend/config"
+	"bronze-backend/data_browser"
+	"bronze-backend/httputil"
+	"bronze-backend/monitoring"
+)
+
+// VersionResponse is the body of GET /api/version. It tells a frontend what
+// this deployment can do, so it can hide what is unavailable rather than
+// find out from errors.
+type VersionResponse struct {
+	Success  bool           `json:"success"`
+	Build    buildinfo.Info `json:"build"`
+	Features Features       `json:"features"`
+	Limits   Limits         `json:"limits"`
+}
+
+// Features reports which optional parts of the API are available.
+type Features struct {
+	MDB           bool `json:"mdb"`           // Access databases can be browsed
+	Nessie        bool `json:"nessie"`        // Exports can reach Nessie
+	Watcher       bool `json:"watcher"`       // The file watcher is running
+	Decompression bool `json:"decompression"` // Archives can be extracted
+	Auth          bool `json:"auth"`          // Requests need credentials
+}
+
+// Limits reports the request limits in force. Sizes are in bytes; 0 is no
+// limit.
+type Limits struct {
+	MaxUploadSize      int64 `json:"max_upload_size"`
+	MaxJSONBodySize    int64 `json:"max_json_body_size"`
+	MaxBrowseRows      int   `json:"max_browse_rows"`
+	MaxExtractSize     int64 `json:"max_extract_size"`
+	MaxFilesPerArchive int   `json:"max_files_per_archive"`
+}
+
+// version answers GET /api/version. Like /api/health it needs no
+// credentials, so a frontend can call it before signing in.
+func (r *Router) version(exportHandler *data_browser.ExportHandler, watcherHandler *monitoring.WatcherHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		response := VersionResponse{
+			Success: true,
+			Build:   buildinfo.Get(),
+			Features: Features{
+				MDB:     data_browser.MDBSupported(),
+				Nessie:  exportHandler != nil && exportHandler.NessieClient() != nil,
+				Watcher: watcherHandler.Enabled(),
+				Auth:    r.authenticator != nil,
+			},
+			Limits: Limits{
+				MaxUploadSize:   r.bodyLimiter.Limit("POST /api/files/upload", true),
+				MaxJSONBodySize: r.bodyLimiter.Limit("", false),
+				MaxBrowseRows:   data_browser.MaxBrowseRows,
+			},
+		}
+		if r.configManager != nil {
+			decompression := r.configManager.Current().Processing.Decompression
+			response.Features.Decompression = decompression.Enabled
+			// The value was validated when the configuration was loaded
+			response.Limits.MaxExtractSize, _ = config.ParseByteSize(decompression.MaxExtractSize)
+			response.Limits.MaxFilesPerArchive = decompression.MaxFilesPerArchive
+		}
+		httputil.WriteJSON(w, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bronze-backend/bodylimit"
+	"bronze-backend/buildinfo"
+	"bronze-backend/config"
+	"bronze-backend/data_browser"
+)
+
+func TestVersion(t *testing.T) {
+	r := NewRouter(nil, nil, nil, nil, nil, nil, nil, nil)
+	limiter, err := bodylimit.New(config.BodyLimitConfig{
+		MaxUploadSize:   "1GB",
+		MaxJSONBodySize: "1MB",
+		Endpoints:       "POST /api/files/upload=2GB",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetBodyLimiter(limiter)
+
+	rec := httptest.NewRecorder()
+	r.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/version", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", rec.Code)
+	}
+	var body VersionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	if body.Build != buildinfo.Get() {
+		t.Errorf("build = %+v, want %+v", body.Build, buildinfo.Get())
+	}
+	if body.Features.Nessie || body.Features.Watcher || body.Features.Auth {
+		t.Errorf("features = %+v, want Nessie, the watcher and auth off", body.Features)
+	}
+	want := Limits{MaxUploadSize: 2 << 30, MaxJSONBodySize: 1 << 20, MaxBrowseRows: data_browser.MaxBrowseRows}
+	if body.Limits != want {
+		t.Errorf("limits = %+v, want %+v", body.Limits, want)
+	}
+}
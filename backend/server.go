@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"bronze-backend/certreload"
+	"bronze-backend/config"
+)
+
+// httpServer is the HTTP(S) listener, and the certificate reloader when it
+// serves HTTPS.
+type httpServer struct {
+	*http.Server
+	certReloader *certreload.Reloader
+}
+
+// startServer serves handler on the configured address, over HTTPS when a
+// certificate is configured.
+func startServer(cfg *config.Config, handler http.Handler) (*httpServer, error) {
+	server := &httpServer{Server: &http.Server{
+		Addr:         cfg.GetServerAddr(),
+		Handler:      handler,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  120 * time.Second,
+	}}
+
+	if cfg.Server.TLSEnabled() {
+		certReloader, err := certreload.New(cfg.Server.TLSCert, cfg.Server.TLSKey, cfg.Server.TLSReloadInterval)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up TLS: %w", err)
+		}
+		server.certReloader = certReloader
+		server.TLSConfig = certReloader.TLSConfig()
+	}
+
+	go func() {
+		var err error
+		if server.certReloader != nil {
+			log.Printf("Starting HTTPS server on %s", server.Addr)
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("Starting HTTP server on %s", server.Addr)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+	return server, nil
+}
+
+// shutdown stops accepting connections and waits for open requests until ctx
+// is done.
+func (s *httpServer) shutdown(ctx context.Context) {
+	if err := s.Shutdown(ctx); err != nil {
+		log.Printf("Server forced to shutdown: %v", err)
+	}
+	if s.certReloader != nil {
+		s.certReloader.Stop()
+	}
+}
+
+// reloadOnSIGHUP reloads .env on SIGHUP, as PUT /api/config does, until the
+// returned function is called.
+func reloadOnSIGHUP(configManager *config.Manager) func() {
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if _, err := configManager.Reload(); err != nil {
+				log.Printf("Warning: Failed to reload configuration: %v", err)
+			}
+		}
+	}()
+	return func() { signal.Stop(reload) }
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,8 +11,11 @@ import (
 	"time"
 
 	"bronze-backend/config"
+	"bronze-backend/tenant"
+	"bronze-backend/tracing"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/tags"
 )
 
 type MinIOClient struct {
@@ -20,6 +24,9 @@ type MinIOClient struct {
 	bucketName   string
 	bucketExists bool
 	bucketError  string
+	cache        *ObjectCache // Nil unless OBJECT_CACHE_SIZE is set
+	ranged       rangedDownload
+	allowed      map[string]bool // MINIO_ALLOWED_BUCKETS
 }
 
 func NewMinIOClient(cfg *config.MinIOConfig) (*MinIOClient, error) {
@@ -31,14 +38,24 @@ func NewMinIOClient(cfg *config.MinIOConfig) (*MinIOClient, error) {
 		endpoint = strings.TrimPrefix(endpoint, "https://")
 	}
 
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MinIO transport: %w", err)
+	}
+
 	client, err := minio.New(endpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
 		Secure: cfg.UseSSL(),
 		Region: cfg.Region,
+		// Spans for every S3 call; trace headers are not sent, they are not
+		// part of the request signature
+		Transport:  tracing.Transport(transport, "minio", false),
+		MaxRetries: maxAttempts(cfg),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create MinIO client: %w", err)
 	}
+	trace(client, cfg.Client.Trace)
 
 	minioClient := &MinIOClient{
 		client:       client,
@@ -46,7 +63,15 @@ func NewMinIOClient(cfg *config.MinIOConfig) (*MinIOClient, error) {
 		bucketName:   cfg.Bucket,
 		bucketExists: false, // Will be checked lazily
 		bucketError:  "Bucket status not yet checked",
+		ranged:       rangedDownload{concurrency: cfg.DownloadConcurrency},
+		allowed:      make(map[string]bool),
+	}
+	for _, bucket := range cfg.AllowedBucketNames() {
+		minioClient.allowed[bucket] = true
 	}
+	// Validated by config.Load
+	minioClient.ranged.threshold, _ = config.ParseByteSize(cfg.DownloadThreshold)
+	minioClient.ranged.partSize, _ = config.ParseByteSize(cfg.DownloadPartSize)
 
 	// Check bucket existence asynchronously to avoid blocking startup
 	go func() {
@@ -98,9 +123,35 @@ func (m *MinIOClient) ensureBucket() error {
 	return nil
 }
 
+// IsNotFound reports whether err is MinIO's answer for a missing object,
+// object version or bucket.
+func IsNotFound(err error) bool {
+	var minioErr minio.ErrorResponse
+	if !errors.As(err, &minioErr) {
+		return false
+	}
+	return minioErr.Code == "NoSuchKey" || minioErr.Code == "NoSuchVersion" || minioErr.Code == "NoSuchBucket"
+}
+
+// Scope returns the bucket key is read from or written to, as chosen by
+// Bucket. A key outside the tenant's prefix is refused with a
+// *tenant.AccessError.
+func (m *MinIOClient) Scope(ctx context.Context, key string) (string, error) {
+	bucket := m.Bucket(ctx)
+	if err := tenant.Check(ctx, bucket, key); err != nil {
+		return "", err
+	}
+	return bucket, nil
+}
+
 func (m *MinIOClient) UploadFile(ctx context.Context, objectName string, reader io.Reader, size int64, contentType string) (minio.UploadInfo, error) {
+	bucket, err := m.Scope(ctx, objectName)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+
 	// Check if bucket is accessible first, refresh status if needed
-	if !m.bucketExists {
+	if bucket == m.bucketName && !m.bucketExists {
 		// Try to check bucket status again in case async check hasn't completed yet
 		exists, err := m.checkBucketExists()
 		if err != nil {
@@ -120,23 +171,82 @@ func (m *MinIOClient) UploadFile(ctx context.Context, objectName string, reader
 		}
 	}
 
-	return m.client.PutObject(ctx, m.bucketName, objectName, reader, size, minio.PutObjectOptions{
+	return m.client.PutObject(ctx, bucket, objectName, reader, size, minio.PutObjectOptions{
 		ContentType: contentType,
 	})
 }
 
 func (m *MinIOClient) DownloadFile(ctx context.Context, objectName string) (io.ReadCloser, error) {
-	return m.client.GetObject(ctx, m.bucketName, objectName, minio.GetObjectOptions{})
+	bucket, err := m.Scope(ctx, objectName)
+	if err != nil {
+		return nil, err
+	}
+	return m.client.GetObject(ctx, bucket, objectName, minio.GetObjectOptions{})
+}
+
+// DownloadFileVersion reads a version of an object, or its latest version
+// when versionID is empty.
+func (m *MinIOClient) DownloadFileVersion(ctx context.Context, objectName, versionID string) (io.ReadCloser, error) {
+	bucket, err := m.Scope(ctx, objectName)
+	if err != nil {
+		return nil, err
+	}
+	return m.client.GetObject(ctx, bucket, objectName, minio.GetObjectOptions{VersionID: versionID})
+}
+
+// ListFileVersions lists the versions of an object, newest first, leaving
+// out delete markers. It is empty for a missing object, and has the one
+// version of an object in a bucket without versioning.
+func (m *MinIOClient) ListFileVersions(ctx context.Context, objectName string) ([]minio.ObjectInfo, error) {
+	bucket, err := m.Scope(ctx, objectName)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []minio.ObjectInfo
+	for object := range m.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: objectName, Recursive: true, WithVersions: true}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		if object.Key == objectName && !object.IsDeleteMarker {
+			versions = append(versions, object)
+		}
+	}
+	return versions, nil
+}
+
+// VersioningEnabled reports whether the bucket of ctx keeps object versions.
+func (m *MinIOClient) VersioningEnabled(ctx context.Context) (bool, error) {
+	versioning, err := m.client.GetBucketVersioning(ctx, m.Bucket(ctx))
+	if err != nil {
+		return false, err
+	}
+	return versioning.Enabled(), nil
 }
 
 func (m *MinIOClient) GetFileInfo(ctx context.Context, objectName string) (minio.ObjectInfo, error) {
-	return m.client.StatObject(ctx, m.bucketName, objectName, minio.StatObjectOptions{})
+	bucket, err := m.Scope(ctx, objectName)
+	if err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	return m.client.StatObject(ctx, bucket, objectName, minio.StatObjectOptions{})
 }
 
+// ListFiles lists the objects and directories directly below prefix, at
+// most limit entries when limit is positive. Directories are the common
+// prefixes of the delimited listing: keys ending in "/" with no size or
+// modification time. A tenant listing the root gets its own prefix.
 func (m *MinIOClient) ListFiles(ctx context.Context, prefix string, limit int) ([]minio.ObjectInfo, error) {
+	if t, ok := tenant.FromContext(ctx); ok && prefix == "" {
+		prefix = t.Prefix
+	}
+	bucket, err := m.Scope(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
 	// Check if bucket is accessible first, refresh status if needed
-	log.Printf("ListFiles: bucketExists=%v, bucketError=%s", m.bucketExists, m.bucketError)
-	if !m.bucketExists {
+	if bucket == m.bucketName && !m.bucketExists {
 		// Try to check bucket status again in case async check hasn't completed yet
 		exists, err := m.checkBucketExists()
 		if err != nil {
@@ -150,111 +260,50 @@ func (m *MinIOClient) ListFiles(ctx context.Context, prefix string, limit int) (
 			m.bucketError = ""
 		}
 
-		log.Printf("ListFiles: after recheck bucketExists=%v, bucketError=%s", m.bucketExists, m.bucketError)
-
 		// If still not accessible, return error
 		if !m.bucketExists {
 			return nil, fmt.Errorf("bucket '%s' is not accessible: %s", m.bucketName, m.bucketError)
 		}
 	}
 
-	var files []minio.ObjectInfo
-	seenDirs := make(map[string]bool)
-
-	objectsCh := m.client.ListObjects(ctx, m.bucketName, minio.ListObjectsOptions{
-		Prefix:    prefix,
-		Recursive: false, // Don't recurse to get directory structure
-	})
+	// Stops the listing when the limit ends it early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	count := 0
-	for object := range objectsCh {
+	opts := minio.ListObjectsOptions{Prefix: prefix} // Delimited by "/"
+	files := []minio.ObjectInfo{}
+	if limit > 0 {
+		opts.MaxKeys = limit
+		files = make([]minio.ObjectInfo, 0, min(limit, 1000))
+	}
+	for object := range m.client.ListObjects(ctx, bucket, opts) {
 		if object.Err != nil {
 			return nil, object.Err
 		}
-
-		// Check if we've reached the limit
-		if limit > 0 && count >= limit {
-			break
-		}
-
-		// Add the object itself
 		files = append(files, object)
-		count++
-
-		// Check if this object represents a directory path
-		// Extract directory prefixes from the object key
-		if prefix != "" {
-			relativePath := strings.TrimPrefix(object.Key, prefix)
-			if strings.HasPrefix(relativePath, "/") {
-				relativePath = relativePath[1:]
-			}
-
-			// Find directory components in the relative path
-			parts := strings.Split(relativePath, "/")
-			for i := 0; i < len(parts)-1; i++ {
-				dirPath := strings.Join(parts[:i+1], "/")
-				if dirPath != "" && !seenDirs[dirPath] {
-					seenDirs[dirPath] = true
-					// Create a directory object
-					dirKey := prefix
-					if dirKey != "" && !strings.HasSuffix(dirKey, "/") {
-						dirKey += "/"
-					}
-					dirKey += dirPath + "/"
-
-					dirObject := minio.ObjectInfo{
-						Key:          dirKey,
-						Size:         0,
-						LastModified: time.Now(),
-						ETag:         "",
-						ContentType:  "application/x-directory",
-					}
-					files = append(files, dirObject)
-				}
-			}
-		} else {
-			// Handle root level directories
-			parts := strings.Split(object.Key, "/")
-			if len(parts) > 1 {
-				dirPath := parts[0]
-				// Only create synthetic directory if this object is not already a directory
-				if dirPath != "" && !seenDirs[dirPath] && !strings.HasSuffix(object.Key, "/") {
-					seenDirs[dirPath] = true
-					dirObject := minio.ObjectInfo{
-						Key:          dirPath + "/",
-						Size:         0,
-						LastModified: time.Now(),
-						ETag:         "",
-						ContentType:  "application/x-directory",
-					}
-					files = append(files, dirObject)
-				}
-			}
-		}
-	}
-
-	// Remove duplicates from the final result
-	uniqueFiles := make([]minio.ObjectInfo, 0, len(files))
-	seenKeys := make(map[string]bool)
-	for _, file := range files {
-		if !seenKeys[file.Key] {
-			seenKeys[file.Key] = true
-			uniqueFiles = append(uniqueFiles, file)
-		} else {
-			log.Printf("Removing duplicate key: %s", file.Key)
+		if limit > 0 && len(files) == limit {
+			break
 		}
 	}
-
-	log.Printf("ListFiles: before deduplication %d files, after deduplication %d files", len(files), len(uniqueFiles))
-
-	return uniqueFiles, nil
+	return files, nil
 }
 
 func (m *MinIOClient) DeleteFile(ctx context.Context, objectName string) error {
-	return m.client.RemoveObject(ctx, m.bucketName, objectName, minio.RemoveObjectOptions{})
+	bucket, err := m.Scope(ctx, objectName)
+	if err != nil {
+		return err
+	}
+	return m.client.RemoveObject(ctx, bucket, objectName, minio.RemoveObjectOptions{})
 }
 
 func (m *MinIOClient) DeleteFiles(ctx context.Context, objectNames []string) error {
+	bucket := m.Bucket(ctx)
+	for _, objectName := range objectNames {
+		if err := tenant.Check(ctx, bucket, objectName); err != nil {
+			return err
+		}
+	}
+
 	objectsCh := make(chan minio.ObjectInfo)
 
 	go func() {
@@ -264,7 +313,7 @@ func (m *MinIOClient) DeleteFiles(ctx context.Context, objectNames []string) err
 		}
 	}()
 
-	errorCh := m.client.RemoveObjects(ctx, m.bucketName, objectsCh, minio.RemoveObjectsOptions{})
+	errorCh := m.client.RemoveObjects(ctx, bucket, objectsCh, minio.RemoveObjectsOptions{})
 
 	for err := range errorCh {
 		if err.Err != nil {
@@ -276,22 +325,64 @@ func (m *MinIOClient) DeleteFiles(ctx context.Context, objectNames []string) err
 }
 
 func (m *MinIOClient) CopyFile(ctx context.Context, srcObjectName, destObjectName string) (minio.UploadInfo, error) {
+	bucket, err := m.Scope(ctx, srcObjectName)
+	if err == nil {
+		err = tenant.Check(ctx, bucket, destObjectName)
+	}
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+
 	srcOpts := minio.CopySrcOptions{
-		Bucket: m.bucketName,
+		Bucket: bucket,
 		Object: srcObjectName,
 	}
 
 	destOpts := minio.CopyDestOptions{
-		Bucket: m.bucketName,
+		Bucket: bucket,
 		Object: destObjectName,
 	}
 
 	return m.client.CopyObject(ctx, destOpts, srcOpts)
 }
 
+// GetFileTags returns the tags of an object.
+func (m *MinIOClient) GetFileTags(ctx context.Context, objectName string) (map[string]string, error) {
+	bucket, err := m.Scope(ctx, objectName)
+	if err != nil {
+		return nil, err
+	}
+	objectTags, err := m.client.GetObjectTagging(ctx, bucket, objectName, minio.GetObjectTaggingOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return objectTags.ToMap(), nil
+}
+
+// TagFile sets tags on an object, keeping its other tags.
+func (m *MinIOClient) TagFile(ctx context.Context, objectName string, add map[string]string) error {
+	existing, err := m.GetFileTags(ctx, objectName)
+	if err != nil {
+		return err
+	}
+	for key, value := range add {
+		existing[key] = value
+	}
+	objectTags, err := tags.MapToObjectTags(existing)
+	if err != nil {
+		return err
+	}
+	bucket := m.Bucket(ctx)
+	return m.client.PutObjectTagging(ctx, bucket, objectName, objectTags, minio.PutObjectTaggingOptions{})
+}
+
 func (m *MinIOClient) GetPresignedURL(ctx context.Context, objectName string, expiry time.Duration) (string, error) {
+	bucket, err := m.Scope(ctx, objectName)
+	if err != nil {
+		return "", err
+	}
 	reqParams := make(url.Values)
-	presignedURL, err := m.client.PresignedGetObject(ctx, m.bucketName, objectName, expiry, reqParams)
+	presignedURL, err := m.client.PresignedGetObject(ctx, bucket, objectName, expiry, reqParams)
 	if err != nil {
 		return "", err
 	}
@@ -299,7 +390,11 @@ func (m *MinIOClient) GetPresignedURL(ctx context.Context, objectName string, ex
 }
 
 func (m *MinIOClient) GetPresignedUploadURL(ctx context.Context, objectName string, expiry time.Duration) (string, map[string]string, error) {
-	presignedURL, err := m.client.PresignedPutObject(ctx, m.bucketName, objectName, expiry)
+	bucket, err := m.Scope(ctx, objectName)
+	if err != nil {
+		return "", nil, err
+	}
+	presignedURL, err := m.client.PresignedPutObject(ctx, bucket, objectName, expiry)
 	if err != nil {
 		return "", nil, err
 	}
@@ -307,7 +402,11 @@ func (m *MinIOClient) GetPresignedUploadURL(ctx context.Context, objectName stri
 }
 
 func (m *MinIOClient) FileExists(ctx context.Context, objectName string) (bool, error) {
-	_, err := m.client.StatObject(ctx, m.bucketName, objectName, minio.StatObjectOptions{})
+	bucket, err := m.Scope(ctx, objectName)
+	if err != nil {
+		return false, err
+	}
+	_, err = m.client.StatObject(ctx, bucket, objectName, minio.StatObjectOptions{})
 	if err != nil {
 		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
 			return false, nil
@@ -317,6 +416,27 @@ func (m *MinIOClient) FileExists(ctx context.Context, objectName string) (bool,
 	return true, nil
 }
 
+// Ping checks that MinIO answers requests.
+func (m *MinIOClient) Ping(ctx context.Context) error {
+	_, err := m.client.BucketExists(ctx, m.bucketName)
+	return err
+}
+
+// CheckBucket checks that the current bucket exists and its objects can be
+// listed.
+func (m *MinIOClient) CheckBucket(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for object := range m.client.ListObjects(ctx, m.bucketName, minio.ListObjectsOptions{MaxKeys: 1}) {
+		if object.Err != nil {
+			return fmt.Errorf("bucket %s: %w", m.bucketName, object.Err)
+		}
+		break
+	}
+	return nil
+}
+
 // Get direct MinIO client for advanced operations
 func (m *MinIOClient) GetClient() *minio.Client {
 	return m.client
@@ -360,6 +480,24 @@ func (m *MinIOClient) GetBucketStatus() (bool, string) {
 	return m.bucketExists, m.bucketError
 }
 
+// BucketStatus reports whether the bucket requests with ctx work in is
+// accessible. Only the current bucket's status is kept; any other bucket is
+// checked on each call.
+func (m *MinIOClient) BucketStatus(ctx context.Context) (string, bool, string) {
+	bucket := m.Bucket(ctx)
+	if bucket == m.bucketName {
+		return bucket, m.bucketExists, m.bucketError
+	}
+	exists, err := m.client.BucketExists(ctx, bucket)
+	switch {
+	case err != nil:
+		return bucket, false, fmt.Sprintf("Cannot access bucket '%s': %v", bucket, err)
+	case !exists:
+		return bucket, false, fmt.Sprintf("Bucket '%s' does not exist", bucket)
+	}
+	return bucket, true, ""
+}
+
 type FileInfoResponse struct {
 	Key          string    `json:"key"`
 	Size         int64     `json:"size"`
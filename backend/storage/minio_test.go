@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListFiles(t *testing.T) {
+	_, client := newFakeS3(t, map[string]string{
+		"raw/":             "",
+		"raw/a.csv":        "a",
+		"raw/b.csv":        "bb",
+		"raw/2024/jan.csv": "jan",
+		"raw/2024/feb.csv": "feb",
+		"raw/2025/mar.csv": "mar",
+		"raw/files/x.json": "{}",
+		"other/c.csv":      "c",
+	})
+	m := &MinIOClient{client: client, bucketName: "lake", bucketExists: true}
+
+	list := func(prefix string, limit int) map[string]int64 {
+		t.Helper()
+		objects, err := m.ListFiles(context.Background(), prefix, limit)
+		if err != nil {
+			t.Fatalf("list %q: %v", prefix, err)
+		}
+		sizes := map[string]int64{}
+		for _, object := range objects {
+			if _, ok := sizes[object.Key]; ok {
+				t.Errorf("list %q: %s listed twice", prefix, object.Key)
+			}
+			sizes[object.Key] = object.Size
+			// Directories have no time of their own to report
+			want := fakeModified
+			if strings.HasSuffix(object.Key, "/") && object.Key != prefix {
+				want = time.Time{}
+			}
+			if !object.LastModified.Equal(want) {
+				t.Errorf("list %q: %s modified %v, want %v", prefix, object.Key, object.LastModified, want)
+			}
+		}
+		return sizes
+	}
+
+	got := list("raw/", 0)
+	want := map[string]int64{"raw/": 0, "raw/a.csv": 1, "raw/b.csv": 2, "raw/2024/": 0, "raw/2025/": 0, "raw/files/": 0}
+	if len(got) != len(want) {
+		t.Errorf("list raw/ = %v, want %v", got, want)
+	}
+	for key, size := range want {
+		if got[key] != size {
+			t.Errorf("list raw/: %s = %d, want %d in %v", key, got[key], size, got)
+		}
+	}
+
+	// A prefix that is not a directory lists no made-up directories below it
+	got = list("raw/fi", 0)
+	if _, ok := got["raw/files/"]; len(got) != 1 || !ok {
+		t.Errorf("list raw/fi = %v, want only raw/files/", got)
+	}
+	if got := list("", 0); len(got) != 2 {
+		t.Errorf("list root = %v, want raw/ and other/", got)
+	}
+	if got := list("raw/", 3); len(got) != 3 {
+		t.Errorf("list raw/ with limit 3 = %v", got)
+	}
+}
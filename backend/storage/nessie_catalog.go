@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bronze-backend/tenant"
+)
+
+// MaxCatalogPageSize caps the entries of a page of namespaces or tables.
+const MaxCatalogPageSize = 1000
+
+// NessiePageOptions select a page of a listing. Limit defaults to 100 and
+// is capped at MaxCatalogPageSize; PageToken is the NextPageToken of the
+// page before. Fresh skips the catalog cache.
+type NessiePageOptions struct {
+	Limit     int
+	PageToken string
+	Fresh     bool
+}
+
+func (o NessiePageOptions) query() string {
+	limit := o.Limit
+	switch {
+	case limit <= 0:
+		limit = 100
+	case limit > MaxCatalogPageSize:
+		limit = MaxCatalogPageSize
+	}
+	query := url.Values{"maxRecords": {strconv.Itoa(limit)}}
+	if o.PageToken != "" {
+		query.Set("pageToken", o.PageToken)
+	}
+	return query.Encode()
+}
+
+// NessieNamespacePage is a page of namespaces. NextPageToken is empty on
+// the last page.
+type NessieNamespacePage struct {
+	Namespaces    []string `json:"namespaces"`
+	NextPageToken string   `json:"next_page_token,omitempty"`
+}
+
+// NessieTableRef names a table.
+type NessieTableRef struct {
+	Name     string `json:"name"`
+	Database string `json:"database"`
+}
+
+// NessieTablePage is a page of the tables of a database. NextPageToken is
+// empty on the last page.
+type NessieTablePage struct {
+	Tables        []NessieTableRef `json:"tables"`
+	NextPageToken string           `json:"next_page_token,omitempty"`
+}
+
+// catalogCache keeps pages of listings for ttl, so browsing a catalog of
+// thousands of tables does not list them from Nessie on every request.
+type catalogCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]catalogEntry
+}
+
+type catalogEntry struct {
+	page    any
+	expires time.Time
+}
+
+func newCatalogCache(ttl time.Duration) *catalogCache {
+	return &catalogCache{ttl: ttl, entries: make(map[string]catalogEntry)}
+}
+
+func (c *catalogCache) get(key string) (any, bool) {
+	if c == nil || c.ttl <= 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.page, true
+}
+
+func (c *catalogCache) put(key string, page any) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = catalogEntry{page: page, expires: time.Now().Add(c.ttl)}
+}
+
+// invalidate drops the pages of the listings whose URL starts with prefix.
+func (c *catalogCache) invalidate(prefix string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// tablesURL is the URL listing the tables of a database.
+func (n *NessieClient) tablesURL(ctx context.Context, database string) string {
+	return fmt.Sprintf("%s/databases/%s/tables", n.baseURL(ctx), url.PathEscape(database))
+}
+
+// namespaceListing and tableListing are pages of Nessie's listings.
+type namespaceListing struct {
+	Namespaces []string `json:"namespaces"`
+	HasMore    bool     `json:"hasMore"`
+	Token      string   `json:"token"`
+}
+
+type tableListing struct {
+	Tables  []NessieTableRef `json:"tables"`
+	HasMore bool             `json:"hasMore"`
+	Token   string           `json:"token"`
+}
+
+// listPage gets a page of a listing, from the cache unless options say
+// otherwise. decoded, if set, completes a page fetched before it is cached.
+func listPage[T any](ctx context.Context, n *NessieClient, listURL string, options NessiePageOptions, what string, decoded func(*T)) (T, error) {
+	pageURL := listURL + "?" + options.query()
+	if !options.Fresh {
+		if cached, ok := n.catalog.get(pageURL); ok {
+			return cached.(T), nil
+		}
+	}
+
+	var page T
+	resp, err := n.send(ctx, http.MethodGet, pageURL, nil, what)
+	if err != nil {
+		return page, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return page, fmt.Errorf("failed to decode %s: %w", what, err)
+	}
+	if decoded != nil {
+		decoded(&page)
+	}
+	n.catalog.put(pageURL, page)
+	return page, nil
+}
+
+// namespacesURL is the URL listing the namespaces.
+func (n *NessieClient) namespacesURL() string {
+	return n.endpoint + "/api/v1/namespaces"
+}
+
+// ListNamespaces returns a page of the namespaces in Nessie. A tenant sees
+// only its namespace and its children.
+func (n *NessieClient) ListNamespaces(ctx context.Context, options NessiePageOptions) (*NessieNamespacePage, error) {
+	listing, err := listPage[namespaceListing](ctx, n, n.namespacesURL(), options, "list namespaces", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &NessieNamespacePage{Namespaces: make([]string, 0, len(listing.Namespaces))}
+	_, confined := tenant.FromContext(ctx)
+	own := n.Namespace(ctx)
+	for _, namespace := range listing.Namespaces {
+		if !confined || namespace == own || strings.HasPrefix(namespace, own+".") {
+			page.Namespaces = append(page.Namespaces, namespace)
+		}
+	}
+	if listing.HasMore {
+		page.NextPageToken = listing.Token
+	}
+	return page, nil
+}
+
+// ListTables returns a page of the tables of a database, in the namespace
+// of ctx.
+func (n *NessieClient) ListTables(ctx context.Context, database string, options NessiePageOptions) (*NessieTablePage, error) {
+	listing, err := listPage(ctx, n, n.tablesURL(ctx, database), options, "list tables", func(listing *tableListing) {
+		for i := range listing.Tables {
+			if listing.Tables[i].Database == "" {
+				listing.Tables[i].Database = database
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &NessieTablePage{Tables: slices.Clone(listing.Tables)}
+	if page.Tables == nil {
+		page.Tables = []NessieTableRef{}
+	}
+	if listing.HasMore {
+		page.NextPageToken = listing.Token
+	}
+	return page, nil
+}
+
+// invalidateTables drops the cached pages listing the tables of a
+// database, and the namespaces, after a table was created or dropped.
+func (n *NessieClient) invalidateTables(ctx context.Context, database string) {
+	n.catalog.invalidate(n.tablesURL(ctx, database) + "?")
+	n.catalog.invalidate(n.namespacesURL() + "?")
+}
+
+// AllTables returns every table of a database, following the pages of
+// the listing.
+func (n *NessieClient) AllTables(ctx context.Context, database string) ([]NessieTableRef, error) {
+	options := NessiePageOptions{Limit: MaxCatalogPageSize}
+	var tables []NessieTableRef
+	for {
+		page, err := n.ListTables(ctx, database, options)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, page.Tables...)
+		if page.NextPageToken == "" {
+			return tables, nil
+		}
+		options.PageToken = page.NextPageToken
+	}
+}
@@ -7,18 +7,28 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"bronze-backend/config"
+	"bronze-backend/tenant"
+	"bronze-backend/tracing"
 )
 
 type NessieClient struct {
 	client    *http.Client
 	config    *config.NessieConfig
-	baseURL   string
+	endpoint  string
 	namespace string
 	authToken string
+	// fixedNamespace, set by WithNamespace, replaces the namespace and the
+	// tenant's
+	fixedNamespace string
+	breaker        *circuitBreaker
+	maxRetries     int
+	retryBackoff   time.Duration
+	catalog        *catalogCache // Pages of namespace and table listings
 }
 
 type NessieConfig struct {
@@ -63,20 +73,38 @@ func NewNessieClient(cfg *config.NessieConfig) (*NessieClient, error) {
 		return nil, fmt.Errorf("Nessie endpoint is required")
 	}
 
+	settings := cfg.Client
+	if settings.Timeout <= 0 {
+		settings.Timeout = 30 * time.Second
+	}
+	if settings.RetryBackoff <= 0 {
+		settings.RetryBackoff = 500 * time.Millisecond
+	}
+
+	// Every request goes to one host, so keep enough of its connections
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if settings.MaxIdleConns > 0 {
+		transport.MaxIdleConns = settings.MaxIdleConns
+		transport.MaxIdleConnsPerHost = settings.MaxIdleConns
+	}
 	client := &http.Client{
-		Timeout: 30 * time.Second,
+		Timeout:   settings.Timeout,
+		Transport: tracing.Transport(transport, "nessie", true),
 	}
 
 	// Remove trailing slash from endpoint
 	endpoint := strings.TrimRight(cfg.Endpoint, "/")
-	baseURL := fmt.Sprintf("%s/api/v1/namespaces/%s", endpoint, cfg.Namespace)
 
 	nessieClient := &NessieClient{
-		client:    client,
-		config:    cfg,
-		baseURL:   baseURL,
-		namespace: cfg.Namespace,
-		authToken: cfg.AuthToken,
+		client:       client,
+		config:       cfg,
+		endpoint:     endpoint,
+		namespace:    cfg.Namespace,
+		authToken:    cfg.AuthToken,
+		breaker:      newCircuitBreaker(settings.BreakerThreshold, settings.BreakerCooldown),
+		maxRetries:   max(settings.MaxRetries, 0),
+		retryBackoff: settings.RetryBackoff,
+		catalog:      newCatalogCache(settings.CatalogCacheTTL),
 	}
 
 	// Test connection
@@ -88,15 +116,87 @@ func NewNessieClient(cfg *config.NessieConfig) (*NessieClient, error) {
 	return nessieClient, nil
 }
 
+// baseURL returns the URL of the namespace tables are kept in.
+func (n *NessieClient) baseURL(ctx context.Context) string {
+	return fmt.Sprintf("%s/api/v1/namespaces/%s", n.endpoint, n.Namespace(ctx))
+}
+
+// Namespace returns the namespace tables are kept in: the one given to
+// WithNamespace, else the tenant's when ctx is confined to one, else the
+// configured namespace.
+func (n *NessieClient) Namespace(ctx context.Context) string {
+	if n.fixedNamespace != "" {
+		return n.fixedNamespace
+	}
+	return tenant.Namespace(ctx, n.namespace)
+}
+
+// WithNamespace returns a client for the tables of namespace, sharing n's
+// connection.
+func (n *NessieClient) WithNamespace(namespace string) *NessieClient {
+	c := *n
+	c.fixedNamespace = namespace
+	return &c
+}
+
+// maxNessieRetryInterval caps the backoff between reconnection attempts.
+const maxNessieRetryInterval = 5 * time.Minute
+
+// ConnectNessie retries NewNessieClient in the background, backing off from
+// cfg.RetryInterval, until it succeeds or ctx is done. The client is handed
+// to connected.
+func ConnectNessie(ctx context.Context, cfg *config.NessieConfig, connected func(*NessieClient)) {
+	interval := cfg.RetryInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+
+			client, err := NewNessieClient(cfg)
+			if err == nil {
+				log.Println("Nessie reconnected, export features enabled")
+				connected(client)
+				return
+			}
+
+			interval = min(interval*2, maxNessieRetryInterval)
+			log.Printf("Warning: Nessie still unreachable, retrying in %v: %v", interval, err)
+		}
+	}()
+}
+
 func (n *NessieClient) testConnection() error {
-	req, err := http.NewRequest("GET", n.baseURL+"/config", nil)
+	if err := n.Ping(context.Background()); err != nil {
+		return err
+	}
+
+	log.Printf("Successfully connected to Nessie")
+	return nil
+}
+
+// Ping checks that Nessie answers requests. It is not retried, but counts
+// toward the circuit breaker, so once the breaker's cooldown is over a
+// successful ping closes it.
+func (n *NessieClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", n.baseURL(ctx)+"/config", nil)
 	if err != nil {
 		return fmt.Errorf("failed to create test request: %w", err)
 	}
 
 	n.addAuthHeader(req)
 
+	if err := n.breaker.allow(); err != nil {
+		return err
+	}
 	resp, err := n.client.Do(req)
+	n.breaker.record(err != nil || resp.StatusCode >= 500)
 	if err != nil {
 		return fmt.Errorf("connection test failed: %w", err)
 	}
@@ -105,13 +205,11 @@ func (n *NessieClient) testConnection() error {
 	if resp.StatusCode >= 400 {
 		return fmt.Errorf("Nessie connection failed with status: %d", resp.StatusCode)
 	}
-
-	log.Printf("Successfully connected to Nessie")
 	return nil
 }
 
 func (n *NessieClient) TableExists(ctx context.Context, database, tableName string) (bool, error) {
-	tableURL := fmt.Sprintf("%s/databases/%s/tables/%s", n.baseURL, database, tableName)
+	tableURL := fmt.Sprintf("%s/databases/%s/tables/%s", n.baseURL(ctx), database, tableName)
 
 	req, err := http.NewRequest("GET", tableURL, nil)
 	if err != nil {
@@ -121,7 +219,7 @@ func (n *NessieClient) TableExists(ctx context.Context, database, tableName stri
 	n.addAuthHeader(req)
 	req = req.WithContext(ctx)
 
-	resp, err := n.client.Do(req)
+	resp, err := n.do(req)
 	if err != nil {
 		return false, fmt.Errorf("failed to check table existence: %w", err)
 	}
@@ -131,7 +229,7 @@ func (n *NessieClient) TableExists(ctx context.Context, database, tableName stri
 }
 
 func (n *NessieClient) GetTableSchema(ctx context.Context, database, tableName string) (*NessieTable, error) {
-	tableURL := fmt.Sprintf("%s/databases/%s/tables/%s", n.baseURL, database, tableName)
+	tableURL := fmt.Sprintf("%s/databases/%s/tables/%s", n.baseURL(ctx), database, tableName)
 
 	req, err := http.NewRequest("GET", tableURL, nil)
 	if err != nil {
@@ -141,7 +239,7 @@ func (n *NessieClient) GetTableSchema(ctx context.Context, database, tableName s
 	n.addAuthHeader(req)
 	req = req.WithContext(ctx)
 
-	resp, err := n.client.Do(req)
+	resp, err := n.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get table schema: %w", err)
 	}
@@ -163,7 +261,7 @@ func (n *NessieClient) GetTableSchema(ctx context.Context, database, tableName s
 }
 
 func (n *NessieClient) CreateTable(ctx context.Context, table *NessieTable) error {
-	createURL := fmt.Sprintf("%s/databases/%s/tables", n.baseURL, table.Database)
+	createURL := fmt.Sprintf("%s/databases/%s/tables", n.baseURL(ctx), table.Database)
 
 	jsonData, err := json.Marshal(table)
 	if err != nil {
@@ -179,7 +277,7 @@ func (n *NessieClient) CreateTable(ctx context.Context, table *NessieTable) erro
 	req.Header.Set("Content-Type", "application/json")
 	req = req.WithContext(ctx)
 
-	resp, err := n.client.Do(req)
+	resp, err := n.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to create table: %w", err)
 	}
@@ -189,12 +287,13 @@ func (n *NessieClient) CreateTable(ctx context.Context, table *NessieTable) erro
 		return fmt.Errorf("failed to create table, status: %d", resp.StatusCode)
 	}
 
+	n.invalidateTables(ctx, table.Database)
 	log.Printf("Successfully created Nessie table: %s.%s", table.Database, table.Name)
 	return nil
 }
 
 func (n *NessieClient) AppendToTable(ctx context.Context, database, tableName string, rows []map[string]interface{}) error {
-	appendURL := fmt.Sprintf("%s/databases/%s/tables/%s/data", n.baseURL, database, tableName)
+	appendURL := fmt.Sprintf("%s/databases/%s/tables/%s/data", n.baseURL(ctx), database, tableName)
 
 	requestData := map[string]interface{}{
 		"rows": rows,
@@ -214,7 +313,7 @@ func (n *NessieClient) AppendToTable(ctx context.Context, database, tableName st
 	req.Header.Set("Content-Type", "application/json")
 	req = req.WithContext(ctx)
 
-	resp, err := n.client.Do(req)
+	resp, err := n.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to append to table: %w", err)
 	}
@@ -228,6 +327,143 @@ func (n *NessieClient) AppendToTable(ctx context.Context, database, tableName st
 	return nil
 }
 
+// NessieTableData is the rows of a table as of one commit.
+type NessieTableData struct {
+	Rows []map[string]interface{} `json:"rows"`
+	Hash string                   `json:"hash"` // The commit read
+}
+
+// ReadTable returns the rows of a table.
+func (n *NessieClient) ReadTable(ctx context.Context, database, tableName string) (*NessieTableData, error) {
+	dataURL := fmt.Sprintf("%s/databases/%s/tables/%s/data", n.baseURL(ctx), database, tableName)
+	resp, err := n.send(ctx, "GET", dataURL, nil, "read table")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var data NessieTableData
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode table data: %w", err)
+	}
+	return &data, nil
+}
+
+// stagedURL is the URL of the uncommitted snapshot stageID of a table.
+func (n *NessieClient) stagedURL(ctx context.Context, database, tableName, stageID string) string {
+	return fmt.Sprintf("%s/databases/%s/tables/%s/staged/%s", n.baseURL(ctx), database, tableName, stageID)
+}
+
+// send sends body, if any, as JSON, failing on an error status. what names
+// the request in errors.
+func (n *NessieClient) send(ctx context.Context, method, url string, body any, what string) (*http.Response, error) {
+	reader := bytes.NewReader(nil)
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", what, err)
+		}
+		reader = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s request: %w", what, err)
+	}
+	n.addAuthHeader(req)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := n.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s: %w", what, err)
+	}
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to %s, status: %d", what, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// StageRows adds a batch of rows to the uncommitted snapshot stageID of a
+// table, starting it with the first batch. Staged rows are not visible
+// until CommitStaged.
+func (n *NessieClient) StageRows(ctx context.Context, database, tableName, stageID string, rows []map[string]interface{}) error {
+	resp, err := n.send(ctx, "POST", n.stagedURL(ctx, database, tableName, stageID), map[string]interface{}{"rows": rows}, "stage rows")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// CommitStaged publishes every batch staged under stageID as one Nessie
+// commit, a single snapshot of the table, and returns the commit's hash.
+func (n *NessieClient) CommitStaged(ctx context.Context, database, tableName, stageID string) (string, error) {
+	resp, err := n.send(ctx, "POST", n.stagedURL(ctx, database, tableName, stageID)+"/commit", nil, "commit staged rows")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var commit struct {
+		Hash string `json:"hash"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&commit); err != nil {
+		return "", fmt.Errorf("failed to decode commit: %w", err)
+	}
+
+	log.Printf("Committed staged rows to Nessie table %s.%s: %s", database, tableName, commit.Hash)
+	return commit.Hash, nil
+}
+
+// DiscardStaged drops the batches staged under stageID, leaving the table
+// as it was.
+func (n *NessieClient) DiscardStaged(ctx context.Context, database, tableName, stageID string) error {
+	resp, err := n.send(ctx, "DELETE", n.stagedURL(ctx, database, tableName, stageID), nil, "discard staged rows")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// DropTable deletes a table.
+func (n *NessieClient) DropTable(ctx context.Context, database, tableName string) error {
+	tableURL := fmt.Sprintf("%s/databases/%s/tables/%s", n.baseURL(ctx), database, tableName)
+	resp, err := n.send(ctx, "DELETE", tableURL, nil, "drop table")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	n.invalidateTables(ctx, database)
+
+	log.Printf("Dropped Nessie table: %s.%s", database, tableName)
+	return nil
+}
+
+// MergeBranch merges the commits of branch from into branch into and
+// returns the hash of into afterwards.
+func (n *NessieClient) MergeBranch(ctx context.Context, from, into string) (string, error) {
+	mergeURL := fmt.Sprintf("%s/api/v1/trees/branch/%s/merge", n.endpoint, url.PathEscape(into))
+	resp, err := n.send(ctx, "POST", mergeURL, map[string]string{"fromRefName": from}, "merge branch")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var merge struct {
+		Hash string `json:"resultantTargetHash"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&merge); err != nil {
+		return "", fmt.Errorf("failed to decode merge: %w", err)
+	}
+
+	log.Printf("Merged Nessie branch %s into %s: %s", from, into, merge.Hash)
+	return merge.Hash, nil
+}
+
 func (n *NessieClient) ValidateSchema(sourceColumns []string, targetTable *NessieTable) []NessieColumnMismatch {
 	var mismatches []NessieColumnMismatch
 
@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bronze-backend/config"
+	"bronze-backend/tenant"
+)
+
+func TestConnectNessieRetriesUntilReachable(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.NessieConfig{Endpoint: server.URL, Namespace: "warehouse", RetryInterval: time.Millisecond}
+	connected := make(chan *NessieClient, 1)
+	ConnectNessie(t.Context(), cfg, func(client *NessieClient) { connected <- client })
+
+	select {
+	case client := <-connected:
+		if client == nil {
+			t.Fatal("connected with a nil client")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("not connected after %d attempts", attempts.Load())
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+}
+
+func TestConnectNessieStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cfg := &config.NessieConfig{Endpoint: "http://127.0.0.1:1", RetryInterval: time.Millisecond}
+	called := make(chan struct{})
+	ConnectNessie(ctx, cfg, func(*NessieClient) { close(called) })
+
+	select {
+	case <-called:
+		t.Fatal("connected after cancellation")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNessieClientRetriesIdempotentRequests(t *testing.T) {
+	var reads, stages atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/config"):
+		case r.Method == http.MethodGet:
+			if reads.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		default:
+			stages.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.NessieConfig{Endpoint: server.URL, Namespace: "warehouse", Client: config.NessieClientConfig{
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+	}}
+	client, err := NewNessieClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err := client.TableExists(t.Context(), "db", "sales")
+	if err != nil || !exists {
+		t.Errorf("TableExists = %v, %v after transient failures", exists, err)
+	}
+	if got := reads.Load(); got != 3 {
+		t.Errorf("reads = %d, want 3", got)
+	}
+
+	// Staging is not idempotent, so it is sent once
+	if err := client.StageRows(t.Context(), "db", "sales", "s1", nil); err == nil {
+		t.Error("StageRows succeeded on 502")
+	}
+	if got := stages.Load(); got != 1 {
+		t.Errorf("stage attempts = %d, want 1", got)
+	}
+}
+
+func TestNessieCircuitBreaker(t *testing.T) {
+	var down atomic.Bool
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if down.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.NessieConfig{Endpoint: server.URL, Namespace: "warehouse", Client: config.NessieClientConfig{
+		BreakerThreshold: 2,
+		BreakerCooldown:  50 * time.Millisecond,
+	}}
+	client, err := NewNessieClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantClient := client.WithNamespace("tenant")
+
+	down.Store(true)
+	for range 2 {
+		if _, err := client.ReadTable(t.Context(), "db", "sales"); err == nil {
+			t.Fatal("ReadTable succeeded while Nessie fails")
+		}
+	}
+	if state := tenantClient.Breaker().State; state != BreakerOpen {
+		t.Fatalf("breaker = %s after 2 failures, want open", state)
+	}
+
+	sent := requests.Load()
+	if _, err := tenantClient.ReadTable(t.Context(), "db", "sales"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("ReadTable while open = %v, want ErrCircuitOpen", err)
+	}
+	if requests.Load() != sent {
+		t.Error("a request reached Nessie while the breaker was open")
+	}
+
+	// After the cooldown a successful probe closes it
+	down.Store(false)
+	time.Sleep(60 * time.Millisecond)
+	if state := client.Breaker().State; state != BreakerHalfOpen {
+		t.Errorf("breaker = %s after the cooldown, want half_open", state)
+	}
+	if err := client.Ping(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if status := client.Breaker(); status.State != BreakerClosed || status.Failures != 0 {
+		t.Errorf("breaker = %+v after a successful probe", status)
+	}
+}
+
+func TestNessieCatalogListing(t *testing.T) {
+	var lists atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost, strings.HasSuffix(r.URL.Path, "/config"):
+		case r.URL.Path == "/api/v1/namespaces":
+			lists.Add(1)
+			w.Write([]byte(`{"namespaces":["acme","acme.raw","globex","acmeish"]}`))
+		case r.URL.Path == "/api/v1/namespaces/warehouse/databases/db/tables" && r.URL.Query().Get("pageToken") == "":
+			lists.Add(1)
+			if r.URL.Query().Get("maxRecords") != "2" {
+				t.Errorf("maxRecords = %s, want 2", r.URL.Query().Get("maxRecords"))
+			}
+			w.Write([]byte(`{"tables":[{"name":"a"},{"name":"b"}],"hasMore":true,"token":"t2"}`))
+		case r.URL.Path == "/api/v1/namespaces/warehouse/databases/db/tables":
+			lists.Add(1)
+			w.Write([]byte(`{"tables":[{"name":"c"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.NessieConfig{Endpoint: server.URL, Namespace: "warehouse", Client: config.NessieClientConfig{CatalogCacheTTL: time.Minute}}
+	client, err := NewNessieClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := client.ListTables(t.Context(), "db", NessiePageOptions{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Tables) != 2 || page.Tables[0].Database != "db" || page.NextPageToken != "t2" {
+		t.Errorf("first page = %+v", page)
+	}
+	next, err := client.ListTables(t.Context(), "db", NessiePageOptions{Limit: 2, PageToken: page.NextPageToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next.Tables) != 1 || next.NextPageToken != "" {
+		t.Errorf("last page = %+v", next)
+	}
+
+	// Pages are cached until a table is created, or refreshed on request
+	if _, err := client.ListTables(t.Context(), "db", NessiePageOptions{Limit: 2}); err != nil || lists.Load() != 2 {
+		t.Errorf("lists = %d after a cached page, err %v", lists.Load(), err)
+	}
+	if _, err := client.ListTables(t.Context(), "db", NessiePageOptions{Limit: 2, Fresh: true}); err != nil || lists.Load() != 3 {
+		t.Errorf("lists = %d after a fresh page, err %v", lists.Load(), err)
+	}
+	if err := client.CreateTable(t.Context(), &NessieTable{Name: "d", Database: "db"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListTables(t.Context(), "db", NessiePageOptions{Limit: 2}); err != nil || lists.Load() != 4 {
+		t.Errorf("lists = %d after creating a table, err %v", lists.Load(), err)
+	}
+
+	// A tenant only sees its namespace and its children
+	ctx := tenant.WithTenant(t.Context(), &tenant.Tenant{Name: "acme", Namespace: "acme"})
+	namespaces, err := client.ListNamespaces(ctx, NessiePageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(namespaces.Namespaces, ",") != "acme,acme.raw" {
+		t.Errorf("tenant namespaces = %v", namespaces.Namespaces)
+	}
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen fails Nessie requests while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("Nessie circuit breaker is open")
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open" // The cooldown is over; the next request probes Nessie
+)
+
+// BreakerStatus is the state of the Nessie circuit breaker.
+type BreakerStatus struct {
+	State    string    `json:"state"`
+	Failures int       `json:"failures"` // Consecutive
+	OpenedAt time.Time `json:"opened_at,omitzero"`
+	RetryAt  time.Time `json:"retry_at,omitzero"` // When an open breaker lets a probe through
+}
+
+// circuitBreaker stops sending requests to Nessie after threshold
+// consecutive failures, until cooldown has passed and a probe succeeds.
+// A nil or zero-threshold breaker lets everything through.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool // A half-open probe is in flight
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+func (b *circuitBreaker) disabled() bool {
+	return b == nil || b.threshold <= 0
+}
+
+// allow returns ErrCircuitOpen unless a request may be sent now.
+func (b *circuitBreaker) allow() error {
+	if b.disabled() {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+	if time.Since(b.openedAt) < b.cooldown || b.probing {
+		return ErrCircuitOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// record counts the outcome of a request let through by allow.
+func (b *circuitBreaker) record(failed bool) {
+	if b.disabled() {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasOpen := b.failures >= b.threshold
+	b.probing = false
+	if !failed {
+		if wasOpen {
+			log.Printf("Nessie circuit breaker closed")
+		}
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		if !wasOpen {
+			log.Printf("Nessie circuit breaker opened after %d consecutive failures", b.failures)
+		}
+		b.openedAt = time.Now()
+	}
+}
+
+// release ends a request let through by allow without counting it.
+func (b *circuitBreaker) release() {
+	if b.disabled() {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *circuitBreaker) status() BreakerStatus {
+	if b.disabled() {
+		return BreakerStatus{State: BreakerClosed}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{State: BreakerClosed, Failures: b.failures}
+	if b.failures >= b.threshold {
+		status.State = BreakerOpen
+		status.OpenedAt = b.openedAt
+		status.RetryAt = b.openedAt.Add(b.cooldown)
+		if !time.Now().Before(status.RetryAt) {
+			status.State = BreakerHalfOpen
+		}
+	}
+	return status
+}
+
+// Breaker returns the state of the circuit breaker, which clients made by
+// WithNamespace share.
+func (n *NessieClient) Breaker() BreakerStatus {
+	return n.breaker.status()
+}
+
+// retryableStatus reports whether a response may succeed if sent again.
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500 && status != http.StatusNotImplemented
+}
+
+// idempotent reports whether a request can be sent again without doing
+// twice what it does. Staging, committing and creating are POSTs, so an
+// attempt that timed out may have taken effect and is not retried.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// do sends req through the circuit breaker. An idempotent request failing
+// with a network error or a retryable status is sent again, up to
+// maxRetries times, backing off from retryBackoff. The last response is
+// returned whatever its status.
+func (n *NessieClient) do(req *http.Request) (*http.Response, error) {
+	retries := 0
+	if idempotent(req.Method) {
+		retries = n.maxRetries
+	}
+	backoff := n.retryBackoff
+
+	for attempt := 0; ; attempt++ {
+		if err := n.breaker.allow(); err != nil {
+			return nil, err
+		}
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := n.client.Do(req)
+		if req.Context().Err() != nil {
+			// Cancelled by the caller, which says nothing of Nessie
+			n.breaker.release()
+			return resp, err
+		}
+		n.breaker.record(err != nil || resp.StatusCode >= 500)
+
+		if (err == nil && !retryableStatus(resp.StatusCode)) || attempt >= retries {
+			return resp, err
+		}
+		if resp != nil {
+			// Drained so the connection is reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
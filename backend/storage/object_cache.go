@@ -0,0 +1,314 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bronze-backend/config"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// ObjectCache keeps local copies of objects, keyed by their ETag and size,
+// so an object browsed, profiled, exported or extracted again is read from
+// disk instead of MinIO. Objects with the same content share one copy. Past
+// its capacity it removes the least recently used copies; readers still
+// holding one keep reading it. The index is rebuilt from the directory on
+// startup, so each instance needs its own directory.
+type ObjectCache struct {
+	dir      string
+	capacity int64
+	minSize  int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // Of *cacheEntry, by key
+	lru     *list.List               // Most recently used first
+	size    int64
+	loading map[string]chan struct{} // Closed once the key's download ends
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+}
+
+// cacheSuffix ends the name of every cached copy; downloads in progress end
+// in .tmp until complete.
+const cacheSuffix = ".obj"
+
+// NewObjectCache returns the cache of cfg, or nil when cfg.Size is empty.
+func NewObjectCache(cfg config.ObjectCacheConfig) (*ObjectCache, error) {
+	capacity, err := config.ParseByteSize(cfg.Size)
+	if err != nil {
+		return nil, fmt.Errorf("OBJECT_CACHE_SIZE: %w", err)
+	}
+	if capacity == 0 {
+		return nil, nil
+	}
+	minSize, err := config.ParseByteSize(cfg.MinSize)
+	if err != nil {
+		return nil, fmt.Errorf("OBJECT_CACHE_MIN_SIZE: %w", err)
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create object cache directory: %w", err)
+	}
+
+	c := &ObjectCache{
+		dir:      cfg.Dir,
+		capacity: capacity,
+		minSize:  minSize,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		loading:  make(map[string]chan struct{}),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load indexes the copies left by a previous process, the most recently
+// used by modification time first, and removes unfinished downloads.
+func (c *ObjectCache) load() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read object cache directory: %w", err)
+	}
+
+	type cachedFile struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	var copies []cachedFile
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			os.Remove(filepath.Join(c.dir, entry.Name()))
+			continue
+		}
+		key, ok := strings.CutSuffix(entry.Name(), cacheSuffix)
+		if !ok {
+			continue
+		}
+		copies = append(copies, cachedFile{key: key, size: info.Size(), modTime: info.ModTime()})
+	}
+	slices.SortFunc(copies, func(a, b cachedFile) int { return b.modTime.Compare(a.modTime) })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, file := range copies {
+		c.entries[file.key] = c.lru.PushBack(&cacheEntry{key: file.key, size: file.size})
+		c.size += file.size
+	}
+	c.evict()
+	return nil
+}
+
+func cacheKey(etag string, size int64) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", strings.Trim(etag, `"`), size)))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *ObjectCache) path(key string) string {
+	return filepath.Join(c.dir, key+cacheSuffix)
+}
+
+// Open returns a reader of a version of an object, or of its latest version
+// when versionID is empty, and its size. Objects smaller than the minimum
+// size or larger than the whole cache are read from MinIO.
+func (c *ObjectCache) Open(ctx context.Context, m *MinIOClient, bucket, objectName, versionID string) (io.ReadCloser, int64, error) {
+	info, err := m.client.StatObject(ctx, bucket, objectName, minio.StatObjectOptions{VersionID: versionID})
+	if err != nil {
+		return nil, 0, err
+	}
+	if info.ETag == "" || info.Size < c.minSize || info.Size > c.capacity {
+		object, err := m.openObject(ctx, bucket, objectName, versionID, info)
+		return object, info.Size, err
+	}
+
+	key := cacheKey(info.ETag, info.Size)
+	filled := false
+	for {
+		c.mu.Lock()
+		if elem, ok := c.entries[key]; ok {
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+			file, err := os.Open(c.path(key))
+			if err == nil {
+				if !filled {
+					c.hits.Add(1)
+				}
+				now := time.Now()
+				os.Chtimes(file.Name(), now, now)
+				return file, info.Size, nil
+			}
+			// Removed from under the cache; download it again
+			c.mu.Lock()
+			c.remove(elem)
+			c.mu.Unlock()
+			continue
+		}
+		if done, ok := c.loading[key]; ok {
+			c.mu.Unlock()
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return nil, 0, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		c.loading[key] = done
+		c.mu.Unlock()
+
+		c.misses.Add(1)
+		err := c.fill(ctx, m, bucket, objectName, versionID, info, key)
+		c.mu.Lock()
+		delete(c.loading, key)
+		c.mu.Unlock()
+		close(done)
+		if err != nil {
+			return nil, 0, err
+		}
+		filled = true
+	}
+}
+
+// fill downloads an object into the cache as key.
+func (c *ObjectCache) fill(ctx context.Context, m *MinIOClient, bucket, objectName, versionID string, info minio.ObjectInfo, key string) error {
+	object, err := m.openObject(ctx, bucket, objectName, versionID, info)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	file, err := os.CreateTemp(c.dir, key+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create object cache file: %w", err)
+	}
+	written, err := io.Copy(file, object)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written != info.Size {
+		err = fmt.Errorf("object %s changed while caching it", objectName)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, size: info.Size})
+	c.size += info.Size
+	c.evict()
+	return nil
+}
+
+// evict removes the least recently used copies until the cache fits its
+// capacity. c.mu must be held.
+func (c *ObjectCache) evict() {
+	for c.size > c.capacity && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// remove drops a copy. c.mu must be held.
+func (c *ObjectCache) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	if c.entries[entry.key] != elem {
+		return
+	}
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+	if err := os.Remove(c.path(entry.key)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: Failed to remove cached object %s: %v", entry.key, err)
+	}
+}
+
+// ObjectCacheStats reports the use of the object cache.
+type ObjectCacheStats struct {
+	Dir           string `json:"dir"`
+	Objects       int    `json:"objects"`
+	SizeBytes     int64  `json:"size_bytes"`
+	CapacityBytes int64  `json:"capacity_bytes"`
+	MinSizeBytes  int64  `json:"min_size_bytes"`
+	Hits          int64  `json:"hits"`
+	Misses        int64  `json:"misses"`
+	Evictions     int64  `json:"evictions"`
+}
+
+func (c *ObjectCache) Stats() ObjectCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ObjectCacheStats{
+		Dir:           c.dir,
+		Objects:       c.lru.Len(),
+		SizeBytes:     c.size,
+		CapacityBytes: c.capacity,
+		MinSizeBytes:  c.minSize,
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Evictions:     c.evictions.Load(),
+	}
+}
+
+// SetObjectCache has DownloadFileCached read through cache.
+func (m *MinIOClient) SetObjectCache(cache *ObjectCache) {
+	m.cache = cache
+}
+
+// ObjectCacheStats reports the use of the object cache, or nil without one.
+func (m *MinIOClient) ObjectCacheStats() *ObjectCacheStats {
+	if m == nil || m.cache == nil {
+		return nil
+	}
+	stats := m.cache.Stats()
+	return &stats
+}
+
+// DownloadFileCached reads a version of an object, or its latest version
+// when versionID is empty, through the object cache, returning its size
+// too. It suits large objects read whole, over and over.
+func (m *MinIOClient) DownloadFileCached(ctx context.Context, objectName, versionID string) (io.ReadCloser, int64, error) {
+	bucket, err := m.Scope(ctx, objectName)
+	if err != nil {
+		return nil, 0, err
+	}
+	if m.cache != nil {
+		return m.cache.Open(ctx, m, bucket, objectName, versionID)
+	}
+
+	info, err := m.client.StatObject(ctx, bucket, objectName, minio.StatObjectOptions{VersionID: versionID})
+	if err != nil {
+		return nil, 0, err
+	}
+	object, err := m.openObject(ctx, bucket, objectName, versionID, info)
+	return object, info.Size, err
+}
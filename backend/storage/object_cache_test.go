@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bronze-backend/config"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// fakeS3 serves objects of one bucket, counting the GETs of each and the
+// ranged ones, and lists them.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]string
+	gets    map[string]int
+	ranges  map[string]int
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("list-type") {
+		f.list(w, r)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/lake/")
+	f.mu.Lock()
+	body, ok := f.objects[key]
+	if r.Method == http.MethodGet && r.Header.Get("Range") != "" {
+		f.ranges[key]++
+	} else if r.Method == http.MethodGet {
+		f.gets[key]++
+	}
+	f.mu.Unlock()
+	if !ok {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `<Error><Code>NoSuchKey</Code><Key>%s</Key></Error>`, key)
+		return
+	}
+
+	sum := md5.Sum([]byte(body))
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	if match := r.Header.Get("If-Match"); match != "" && match != etag && `"`+match+`"` != etag {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+	status := http.StatusOK
+	var start, end int
+	if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err == nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(body)))
+		body = body[start : end+1]
+		status = http.StatusPartialContent
+	}
+	w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		io.WriteString(w, body)
+	}
+}
+
+// fakeModified is the modification time of every listed object.
+var fakeModified = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// list answers a ListObjectsV2 request in one page, rolling the keys below
+// the delimiter up into common prefixes.
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	f.mu.Lock()
+	keys := slices.Sorted(maps.Keys(f.objects))
+	f.mu.Unlock()
+
+	var contents, prefixes strings.Builder
+	seen := map[string]bool{}
+	for _, key := range keys {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			common := prefix + rest[:i+len(delimiter)]
+			if !seen[common] {
+				seen[common] = true
+				fmt.Fprintf(&prefixes, `<CommonPrefixes><Prefix>%s</Prefix></CommonPrefixes>`, common)
+			}
+			continue
+		}
+		fmt.Fprintf(&contents, `<Contents><Key>%s</Key><LastModified>%s</LastModified><Size>%d</Size></Contents>`,
+			key, fakeModified.Format(time.RFC3339), len(f.objects[key]))
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprintf(w, `<ListBucketResult><Name>lake</Name><Prefix>%s</Prefix><Delimiter>%s</Delimiter><IsTruncated>false</IsTruncated>%s%s</ListBucketResult>`,
+		prefix, delimiter, contents.String(), prefixes.String())
+}
+
+func newFakeS3(t *testing.T, objects map[string]string) (*fakeS3, *minio.Client) {
+	t.Helper()
+	s3 := &fakeS3{objects: objects, gets: map[string]int{}, ranges: map[string]int{}}
+	server := httptest.NewServer(s3)
+	t.Cleanup(server.Close)
+
+	client, err := minio.New(strings.TrimPrefix(server.URL, "http://"), &minio.Options{
+		Creds:  credentials.NewStaticV4("key", "secret", ""),
+		Region: "us-east-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s3, client
+}
+
+func TestObjectCache(t *testing.T) {
+	s3, client := newFakeS3(t, map[string]string{"big.csv": strings.Repeat("a", 100), "other.csv": strings.Repeat("b", 100), "tiny.csv": "c"})
+	dir := t.TempDir()
+	cache, err := NewObjectCache(config.ObjectCacheConfig{Dir: dir, Size: "150B", MinSize: "10B"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &MinIOClient{client: client, bucketName: "lake", cache: cache}
+
+	read := func(key string) string {
+		t.Helper()
+		reader, size, err := m.DownloadFileCached(context.Background(), key, "")
+		if err != nil {
+			t.Fatalf("open %s: %v", key, err)
+		}
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		if err != nil || int64(len(data)) != size {
+			t.Fatalf("read %s: %d of %d bytes, %v", key, len(data), size, err)
+		}
+		return string(data)
+	}
+
+	for range 3 {
+		if got := read("big.csv"); got != s3.objects["big.csv"] {
+			t.Fatalf("big.csv = %q", got)
+		}
+		read("tiny.csv")
+	}
+	if s3.gets["big.csv"] != 1 || s3.gets["tiny.csv"] != 3 {
+		t.Errorf("GETs = %v, want big.csv once and tiny.csv, under the minimum size, every time", s3.gets)
+	}
+
+	// The second large object does not fit next to the first
+	read("other.csv")
+	read("big.csv")
+	if s3.gets["big.csv"] != 2 {
+		t.Errorf("big.csv fetched %d times, want it evicted and fetched again", s3.gets["big.csv"])
+	}
+	stats := cache.Stats()
+	if stats.Objects != 1 || stats.SizeBytes != 100 || stats.Hits != 2 || stats.Misses != 3 || stats.Evictions != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// A new cache picks up the copies on disk
+	reopened, err := NewObjectCache(config.ObjectCacheConfig{Dir: dir, Size: "150B", MinSize: "10B"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.cache = reopened
+	read("big.csv")
+	if s3.gets["big.csv"] != 2 {
+		t.Errorf("big.csv fetched again after reopening the cache")
+	}
+
+	if _, _, err := m.DownloadFileCached(context.Background(), "missing.csv", ""); !IsNotFound(err) {
+		t.Errorf("missing object: err = %v, want not found", err)
+	}
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// rangedDownload sets how large objects are downloaded in parallel ranges.
+type rangedDownload struct {
+	threshold   int64 // 0 downloads every object in one request
+	partSize    int64
+	concurrency int
+}
+
+// openObject reads the object info describes, failing if its ETag changed.
+// Objects of at least the download threshold are read as parallel ranges,
+// the others in one request.
+func (m *MinIOClient) openObject(ctx context.Context, bucket, objectName, versionID string, info minio.ObjectInfo) (io.ReadCloser, error) {
+	ranged := m.ranged
+	if info.ETag == "" {
+		return m.client.GetObject(ctx, bucket, objectName, minio.GetObjectOptions{VersionID: versionID})
+	}
+	if ranged.threshold <= 0 || info.Size < ranged.threshold || ranged.partSize <= 0 || ranged.concurrency < 2 {
+		opts := minio.GetObjectOptions{VersionID: versionID}
+		// The content must be the one info describes
+		if err := opts.SetMatchETag(strings.Trim(info.ETag, `"`)); err != nil {
+			return nil, err
+		}
+		return m.client.GetObject(ctx, bucket, objectName, opts)
+	}
+	return newRangedReader(ctx, m.client, bucket, objectName, versionID, info, ranged), nil
+}
+
+type rangedPart struct {
+	data []byte
+	err  error
+}
+
+// rangedReader reads an object in order while fetching the parts ahead of
+// the one being read, up to concurrency parts being fetched or waiting to
+// be read at a time.
+type rangedReader struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	parts  []chan rangedPart
+	slots  chan struct{} // One per part fetched and not yet read
+
+	next    int
+	current []byte
+	err     error
+}
+
+func newRangedReader(ctx context.Context, client *minio.Client, bucket, objectName, versionID string, info minio.ObjectInfo, ranged rangedDownload) *rangedReader {
+	ctx, cancel := context.WithCancel(ctx)
+	count := int((info.Size + ranged.partSize - 1) / ranged.partSize)
+	r := &rangedReader{
+		ctx:    ctx,
+		cancel: cancel,
+		parts:  make([]chan rangedPart, count),
+		slots:  make(chan struct{}, ranged.concurrency),
+	}
+	for i := range r.parts {
+		r.parts[i] = make(chan rangedPart, 1)
+	}
+
+	etag := strings.Trim(info.ETag, `"`)
+	go func() {
+		for i := range r.parts {
+			select {
+			case r.slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			start := int64(i) * ranged.partSize
+			end := min(start+ranged.partSize, info.Size) - 1
+			go func() {
+				data, err := fetchRange(ctx, client, bucket, objectName, versionID, etag, start, end)
+				r.parts[i] <- rangedPart{data: data, err: err}
+			}()
+		}
+	}()
+	return r
+}
+
+// fetchRange reads bytes start to end, inclusive, of the object version
+// with etag.
+func fetchRange(ctx context.Context, client *minio.Client, bucket, objectName, versionID, etag string, start, end int64) ([]byte, error) {
+	opts := minio.GetObjectOptions{VersionID: versionID}
+	if err := opts.SetRange(start, end); err != nil {
+		return nil, err
+	}
+	// Every part must come from the same content
+	if err := opts.SetMatchETag(etag); err != nil {
+		return nil, err
+	}
+	object, err := client.GetObject(ctx, bucket, objectName, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	data := make([]byte, end-start+1)
+	if _, err := io.ReadFull(object, data); err != nil {
+		return nil, fmt.Errorf("failed to read bytes %d-%d of %s: %w", start, end, objectName, err)
+	}
+	return data, nil
+}
+
+func (r *rangedReader) Read(p []byte) (int, error) {
+	for len(r.current) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.next == len(r.parts) {
+			r.err = io.EOF
+			return 0, io.EOF
+		}
+
+		select {
+		case part := <-r.parts[r.next]:
+			if part.err != nil {
+				r.err = part.err
+				r.cancel()
+				return 0, r.err
+			}
+			r.current = part.data
+		case <-r.ctx.Done():
+			r.err = r.ctx.Err()
+			return 0, r.err
+		}
+		r.next++
+		// The part is read from r.current; the next one may be fetched
+		<-r.slots
+	}
+
+	n := copy(p, r.current)
+	r.current = r.current[n:]
+	return n, nil
+}
+
+// Close stops fetching the parts not read yet.
+func (r *rangedReader) Close() error {
+	r.cancel()
+	r.current = nil
+	if r.err == nil {
+		r.err = os.ErrClosed
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+
+	"bronze-backend/auth"
+	"bronze-backend/httputil"
+	"bronze-backend/tenant"
+
+	"github.com/minio/minio-go/v7/pkg/s3utils"
+)
+
+// BucketHeader names the bucket a client is working in, so each client keeps
+// its own active bucket instead of switching the server's current bucket
+// for everyone.
+const BucketHeader = "X-Bucket"
+
+// bucketQuery carries the bucket for clients that cannot set headers, such
+// as browser WebSocket and EventSource connections.
+const bucketQuery = "bucket"
+
+type bucketKey struct{}
+
+// WithBucket returns ctx working in bucket.
+func WithBucket(ctx context.Context, bucket string) context.Context {
+	return context.WithValue(ctx, bucketKey{}, bucket)
+}
+
+// SessionBucket returns the bucket the client of ctx chose, or "" if it
+// uses the current bucket.
+func SessionBucket(ctx context.Context) string {
+	bucket, _ := ctx.Value(bucketKey{}).(string)
+	return bucket
+}
+
+// SelectBucket runs each request in the bucket named by the BucketHeader
+// header or the bucket query parameter, if any. It runs after the auth and
+// tenant middleware. A tenant may only name its own bucket; other clients
+// may name the current bucket or one of MINIO_ALLOWED_BUCKETS, and admins,
+// who can switch the current bucket anyway, any bucket. Other buckets are
+// refused, so the MinIO credentials' reach is not every caller's. Without
+// auth every caller counts as an admin.
+func (m *MinIOClient) SelectBucket(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bucket := r.Header.Get(BucketHeader)
+		if bucket == "" {
+			bucket = r.URL.Query().Get(bucketQuery)
+		}
+		if bucket == "" || m == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := s3utils.CheckValidBucketNameStrict(bucket); err != nil {
+			httputil.WriteError(w, "Invalid bucket", http.StatusBadRequest, err)
+			return
+		}
+		if t, ok := tenant.FromContext(r.Context()); ok {
+			if bucket != t.Bucket {
+				httputil.WriteCode(w, httputil.CodeTenantForbidden, "Bucket is outside the tenant's zone: "+bucket, nil)
+				return
+			}
+		} else if !m.MayUseBucket(r.Context(), bucket) {
+			httputil.Error(w, "Bucket is not allowed: "+bucket, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithBucket(r.Context(), bucket)))
+	})
+}
+
+// MayUseBucket reports whether the caller of ctx, when not confined to a
+// tenant, may work in bucket, by the rule of SelectBucket. Without a client
+// only admins may.
+func (m *MinIOClient) MayUseBucket(ctx context.Context, bucket string) bool {
+	if m != nil && (bucket == m.bucketName || m.allowed[bucket]) {
+		return true
+	}
+	p, ok := auth.FromContext(ctx)
+	return !ok || p.Role >= auth.RoleAdmin
+}
+
+// Bucket returns the bucket requests with ctx work in: the tenant's bucket
+// when ctx is confined to one, else the client's session bucket, else the
+// current bucket.
+func (m *MinIOClient) Bucket(ctx context.Context) string {
+	if bucket := SessionBucket(ctx); bucket != "" {
+		return tenant.Bucket(ctx, bucket)
+	}
+	return tenant.Bucket(ctx, m.bucketName)
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bronze-backend/auth"
+	"bronze-backend/tenant"
+)
+
+func TestSelectBucket(t *testing.T) {
+	m := &MinIOClient{bucketName: "default", allowed: map[string]bool{"shared": true}}
+	handler := m.SelectBucket(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Resolved", m.Bucket(r.Context()))
+	}))
+	teamA := &tenant.Tenant{Name: "a", Bucket: "lake", Prefix: "team-a/"}
+	viewer := &auth.Principal{Subject: "v", Role: auth.RoleViewer}
+	admin := &auth.Principal{Subject: "a", Role: auth.RoleAdmin}
+
+	for _, tt := range []struct {
+		name      string
+		principal *auth.Principal
+		tenant    *tenant.Tenant
+		header    string
+		target    string
+		want      int
+		bucket    string
+	}{
+		{"none", nil, nil, "", "/api/files", http.StatusOK, "default"},
+		{"header", nil, nil, "raw", "/api/files", http.StatusOK, "raw"},
+		{"query", nil, nil, "", "/api/ws?bucket=raw", http.StatusOK, "raw"},
+		{"header over query", nil, nil, "raw", "/api/ws?bucket=other", http.StatusOK, "raw"},
+		{"invalid name", nil, nil, "Not_A_Bucket", "/api/files", http.StatusBadRequest, ""},
+		{"tenant", nil, teamA, "", "/api/files", http.StatusOK, "lake"},
+		{"tenant's bucket", nil, teamA, "lake", "/api/files", http.StatusOK, "lake"},
+		{"other bucket than the tenant's", nil, teamA, "raw", "/api/files", http.StatusForbidden, ""},
+		{"viewer, current bucket", viewer, nil, "default", "/api/files", http.StatusOK, "default"},
+		{"viewer, allowed bucket", viewer, nil, "shared", "/api/files", http.StatusOK, "shared"},
+		{"viewer, other bucket", viewer, nil, "raw", "/api/files", http.StatusForbidden, ""},
+		{"viewer, other bucket by query", viewer, nil, "", "/api/ws?bucket=raw", http.StatusForbidden, ""},
+		{"admin, other bucket", admin, nil, "raw", "/api/files", http.StatusOK, "raw"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), tt.principal))
+			}
+			if tt.tenant != nil {
+				req = req.WithContext(tenant.WithTenant(req.Context(), tt.tenant))
+			}
+			if tt.header != "" {
+				req.Header.Set(BucketHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want || rec.Header().Get("X-Resolved") != tt.bucket {
+				t.Errorf("got %d in bucket %q, want %d in %q", rec.Code, rec.Header().Get("X-Resolved"), tt.want, tt.bucket)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"bronze-backend/config"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// newTransport returns the MinIO SDK's default transport with the
+// connection pool and timeouts of cfg.
+func newTransport(cfg *config.MinIOConfig) (*http.Transport, error) {
+	transport, err := minio.DefaultTransport(cfg.UseSSL())
+	if err != nil {
+		return nil, err
+	}
+
+	client := cfg.Client
+	transport.DialContext = (&net.Dialer{
+		Timeout:   client.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.MaxIdleConns = client.MaxIdleConns
+	transport.MaxIdleConnsPerHost = client.MaxIdleConnsPerHost
+	transport.TLSHandshakeTimeout = client.TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = client.ResponseHeaderTimeout
+	transport.IdleConnTimeout = client.IdleConnTimeout
+	return transport, nil
+}
+
+// maxAttempts returns how many times the SDK tries a request, which it
+// counts including the first attempt.
+func maxAttempts(cfg *config.MinIOConfig) int {
+	return cfg.Client.MaxRetries + 1
+}
+
+// trace logs the SDK's requests and responses as MINIO_TRACE asks.
+func trace(client *minio.Client, mode string) {
+	switch mode {
+	case config.MinIOTraceErrors:
+		client.TraceErrorsOnlyOn(log.Writer())
+	case config.MinIOTraceAll:
+		client.TraceOn(log.Writer())
+	}
+}
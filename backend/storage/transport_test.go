@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"bronze-backend/config"
+)
+
+func TestNewTransport(t *testing.T) {
+	cfg := &config.MinIOConfig{
+		Endpoint: "https://s3.example.com",
+		Client: config.MinIOClientConfig{
+			MaxIdleConns:          64,
+			MaxIdleConnsPerHost:   32,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 20 * time.Second,
+			IdleConnTimeout:       2 * time.Minute,
+			MaxRetries:            0,
+		},
+	}
+	transport, err := newTransport(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.MaxIdleConns != 64 || transport.MaxIdleConnsPerHost != 32 || transport.TLSHandshakeTimeout != 5*time.Second ||
+		transport.ResponseHeaderTimeout != 20*time.Second || transport.IdleConnTimeout != 2*time.Minute {
+		t.Errorf("transport = %+v", transport)
+	}
+	if transport.TLSClientConfig == nil {
+		t.Error("TLS settings of the default transport lost")
+	}
+	// The SDK counts the first attempt too
+	if attempts := maxAttempts(cfg); attempts != 1 {
+		t.Errorf("attempts = %d, want 1 without retries", attempts)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package tempdir
+
+import "errors"
+
+// diskSpace cannot tell the free space of a filesystem on this platform.
+func diskSpace(string) (free, total int64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
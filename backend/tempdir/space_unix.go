@@ -0,0 +1,15 @@
+//go:build linux || darwin
+
+package tempdir
+
+import "syscall"
+
+// diskSpace returns the bytes free to unprivileged users and the size of
+// the filesystem holding dir.
+func diskSpace(dir string) (free, total int64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), int64(stat.Blocks) * int64(stat.Bsize), nil
+}
@@ -0,0 +1,289 @@
+// Package tempdir manages the part of TEMP_DIR jobs write to. Each job run
+// gets its own directory below jobs/, removed when the run ends; downloads
+// and extractions check there is disk space for them before they start; and
+// a cleaner removes the directories that runs of a crashed process left
+// behind.
+package tempdir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bronze-backend/config"
+)
+
+// JobsDir is the directory below TEMP_DIR holding the job directories. The
+// cleaner leaves the rest of TEMP_DIR, such as the databases kept there,
+// alone.
+const JobsDir = "jobs"
+
+// ErrInsufficientSpace is wrapped by the error of a download or extraction
+// the temp filesystem has no room for.
+var ErrInsufficientSpace = errors.New("not enough temp disk space")
+
+// Manager hands out job directories and cleans up orphaned ones. A nil
+// Manager hands out none and checks nothing.
+type Manager struct {
+	root          string
+	minFree       int64
+	orphanAge     time.Duration
+	cleanInterval time.Duration
+	space         func(dir string) (free, total int64, err error)
+	now           func() time.Time
+
+	mu        sync.Mutex
+	active    map[string]bool // Job directories in use, by name
+	lastClean time.Time
+
+	orphansRemoved  atomic.Int64
+	bytesRemoved    atomic.Int64
+	spaceRejections atomic.Int64
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns the Manager of cfg.TempDir, creating its jobs directory.
+func New(cfg config.ProcessingConfig) (*Manager, error) {
+	minFree, err := config.ParseByteSize(cfg.TempMinFree)
+	if err != nil {
+		return nil, fmt.Errorf("TEMP_MIN_FREE: %w", err)
+	}
+	root := filepath.Join(cfg.TempDir, JobsDir)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create job temp directory: %w", err)
+	}
+	return &Manager{
+		root:          root,
+		minFree:       minFree,
+		orphanAge:     cfg.TempOrphanAge,
+		cleanInterval: cfg.TempCleanInterval,
+		space:         diskSpace,
+		now:           time.Now,
+		active:        make(map[string]bool),
+	}, nil
+}
+
+// Dir is the temp directory of one job run.
+type Dir struct {
+	Path    string
+	manager *Manager
+	name    string
+}
+
+// Acquire creates the directory of a job run. The cleaner leaves it alone
+// until Release removes it.
+func (m *Manager) Acquire(jobID string) (*Dir, error) {
+	if m == nil {
+		return nil, nil
+	}
+	name := filepath.Base(jobID)
+	dir := &Dir{Path: filepath.Join(m.root, name), manager: m, name: name}
+
+	m.mu.Lock()
+	m.active[name] = true
+	m.mu.Unlock()
+	if err := os.MkdirAll(dir.Path, 0755); err != nil {
+		dir.Release()
+		return nil, fmt.Errorf("failed to create temp directory for job %s: %w", jobID, err)
+	}
+	return dir, nil
+}
+
+// Release removes the directory and everything the run left in it.
+func (d *Dir) Release() {
+	if d == nil {
+		return
+	}
+	if err := os.RemoveAll(d.Path); err != nil {
+		log.Printf("Warning: Failed to remove temp directory %s: %v", d.Path, err)
+	}
+	d.manager.mu.Lock()
+	delete(d.manager.active, d.name)
+	d.manager.mu.Unlock()
+}
+
+// Reserve checks there is room for need more bytes in the directory. A nil
+// Dir has room for anything.
+func (d *Dir) Reserve(need int64) error {
+	if d == nil {
+		return nil
+	}
+	return d.manager.EnsureSpace(need)
+}
+
+type dirKey struct{}
+
+// WithDir returns ctx carrying the directory of the job run in it.
+func WithDir(ctx context.Context, dir *Dir) context.Context {
+	return context.WithValue(ctx, dirKey{}, dir)
+}
+
+// From returns the directory of the job run in ctx, or nil.
+func From(ctx context.Context) *Dir {
+	dir, _ := ctx.Value(dirKey{}).(*Dir)
+	return dir
+}
+
+// EnsureSpace checks the temp filesystem has need bytes free on top of
+// TEMP_MIN_FREE. Platforms that cannot tell have room for anything.
+func (m *Manager) EnsureSpace(need int64) error {
+	if m == nil {
+		return nil
+	}
+	free, _, err := m.space(m.root)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check temp disk space: %w", err)
+	}
+	if free-need < m.minFree {
+		m.spaceRejections.Add(1)
+		return fmt.Errorf("%w: %d bytes needed, %d free, %d kept free", ErrInsufficientSpace, need, free, m.minFree)
+	}
+	return nil
+}
+
+// Clean removes the job directories no run of this process holds that were
+// last modified over TEMP_ORPHAN_AGE ago, returning how many it removed.
+func (m *Manager) Clean() int {
+	entries, err := os.ReadDir(m.root)
+	if err != nil {
+		log.Printf("Warning: Failed to list %s: %v", m.root, err)
+		return 0
+	}
+
+	cutoff := m.now().Add(-m.orphanAge)
+	removed := 0
+	for _, entry := range entries {
+		if m.removeOrphan(entry, cutoff) {
+			removed++
+		}
+	}
+
+	m.mu.Lock()
+	m.lastClean = m.now()
+	m.mu.Unlock()
+	if removed > 0 {
+		log.Printf("Removed %d orphaned job directories from %s", removed, m.root)
+	}
+	return removed
+}
+
+func (m *Manager) removeOrphan(entry fs.DirEntry, cutoff time.Time) bool {
+	info, err := entry.Info()
+	if err != nil || info.ModTime().After(cutoff) {
+		return false
+	}
+
+	// Held so a run cannot take the directory while it is removed
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active[entry.Name()] {
+		return false
+	}
+	path := filepath.Join(m.root, entry.Name())
+	size := dirSize(path)
+	if err := os.RemoveAll(path); err != nil {
+		log.Printf("Warning: Failed to remove orphaned %s: %v", path, err)
+		return false
+	}
+	m.orphansRemoved.Add(1)
+	m.bytesRemoved.Add(size)
+	return true
+}
+
+// Start cleans up once, for the runs of a previous process, then every
+// TEMP_CLEAN_INTERVAL until Stop.
+func (m *Manager) Start() {
+	m.Clean()
+	if m.cleanInterval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.cleanInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.Clean()
+			}
+		}
+	}()
+	log.Printf("Temp directory cleaner started (interval: %v, orphan age: %v)", m.cleanInterval, m.orphanAge)
+}
+
+func (m *Manager) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+		m.wg.Wait()
+	}
+}
+
+// Stats reports the temp disk use of job runs.
+type Stats struct {
+	Dir             string     `json:"dir"`
+	UsedBytes       int64      `json:"used_bytes"` // By job directories
+	FreeBytes       int64      `json:"free_bytes"` // On the filesystem, 0 when unknown
+	TotalBytes      int64      `json:"total_bytes"`
+	MinFreeBytes    int64      `json:"min_free_bytes"`
+	ActiveJobs      int        `json:"active_jobs"`
+	OrphansRemoved  int64      `json:"orphans_removed"`
+	BytesRemoved    int64      `json:"orphan_bytes_removed"`
+	SpaceRejections int64      `json:"space_rejections"` // Downloads and extractions refused for lack of space
+	LastCleanAt     *time.Time `json:"last_clean_at,omitempty"`
+}
+
+func (m *Manager) Stats() Stats {
+	stats := Stats{
+		Dir:             m.root,
+		UsedBytes:       dirSize(m.root),
+		MinFreeBytes:    m.minFree,
+		OrphansRemoved:  m.orphansRemoved.Load(),
+		BytesRemoved:    m.bytesRemoved.Load(),
+		SpaceRejections: m.spaceRejections.Load(),
+	}
+	stats.FreeBytes, stats.TotalBytes, _ = m.space(m.root)
+
+	m.mu.Lock()
+	stats.ActiveJobs = len(m.active)
+	if !m.lastClean.IsZero() {
+		lastClean := m.lastClean
+		stats.LastCleanAt = &lastClean
+	}
+	m.mu.Unlock()
+	return stats
+}
+
+// dirSize returns the bytes of the regular files below path.
+func dirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,108 @@
+package tempdir
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bronze-backend/config"
+)
+
+func newManager(t *testing.T) *Manager {
+	t.Helper()
+	m, err := New(config.ProcessingConfig{TempDir: t.TempDir(), TempMinFree: "1KB", TempOrphanAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.space = func(string) (int64, int64, error) { return 4096, 8192, nil }
+	return m
+}
+
+func TestJobDirectories(t *testing.T) {
+	m := newManager(t)
+
+	dir, err := m.Acquire("job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir.Path, "data.csv"), make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if From(WithDir(context.Background(), dir)) != dir {
+		t.Error("directory not carried by context")
+	}
+	if stats := m.Stats(); stats.ActiveJobs != 1 || stats.UsedBytes != 100 || stats.FreeBytes != 4096 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	dir.Release()
+	if _, err := os.Stat(dir.Path); !os.IsNotExist(err) {
+		t.Errorf("released directory still there: %v", err)
+	}
+	if stats := m.Stats(); stats.ActiveJobs != 0 {
+		t.Errorf("active jobs = %d after release", stats.ActiveJobs)
+	}
+}
+
+func TestEnsureSpace(t *testing.T) {
+	m := newManager(t)
+	dir, _ := m.Acquire("job-1")
+	defer dir.Release()
+
+	// 4096 free, 1024 kept free
+	if err := dir.Reserve(3072); err != nil {
+		t.Errorf("reserve within free space: %v", err)
+	}
+	if err := dir.Reserve(3073); !errors.Is(err, ErrInsufficientSpace) {
+		t.Errorf("reserve over free space: err = %v, want ErrInsufficientSpace", err)
+	}
+	if stats := m.Stats(); stats.SpaceRejections != 1 {
+		t.Errorf("space rejections = %d, want 1", stats.SpaceRejections)
+	}
+
+	m.space = func(string) (int64, int64, error) { return 0, 0, errors.ErrUnsupported }
+	if err := dir.Reserve(1 << 40); err != nil {
+		t.Errorf("reserve without known space: %v", err)
+	}
+	var none *Dir
+	if err := none.Reserve(1 << 40); err != nil {
+		t.Errorf("reserve without directory: %v", err)
+	}
+}
+
+func TestClean(t *testing.T) {
+	m := newManager(t)
+	old := time.Now().Add(-2 * time.Hour)
+
+	orphan := filepath.Join(m.root, "crashed")
+	os.MkdirAll(orphan, 0755)
+	os.WriteFile(filepath.Join(orphan, "archive.zip"), make([]byte, 50), 0644)
+	os.Chtimes(orphan, old, old)
+
+	recent := filepath.Join(m.root, "recent")
+	os.MkdirAll(recent, 0755)
+
+	running, _ := m.Acquire("running")
+	defer running.Release()
+	os.Chtimes(running.Path, old, old)
+
+	if removed := m.Clean(); removed != 1 {
+		t.Errorf("removed = %d, want 1", removed)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Error("orphaned directory not removed")
+	}
+	for _, path := range []string{recent, running.Path} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s removed: %v", path, err)
+		}
+	}
+
+	stats := m.Stats()
+	if stats.OrphansRemoved != 1 || stats.BytesRemoved != 50 || stats.LastCleanAt == nil {
+		t.Errorf("stats = %+v", stats)
+	}
+}
@@ -0,0 +1,60 @@
+package tenant
+
+import (
+	"net/http"
+
+	"bronze-backend/auth"
+	"bronze-backend/httputil"
+)
+
+// Middleware confines each request to its tenant, named by the token's
+// tenant claim or, for callers without one, the tenant header. It runs after
+// auth.Require. A token's claim cannot be overridden by the header, and
+// authenticated callers without a tenant must be admins, whose requests are
+// deployment-wide; with auth disabled the header alone decides. With a nil
+// Registry every request passes unconfined.
+func (r *Registry) Middleware(next http.Handler) http.Handler {
+	if r == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name := req.Header.Get(r.Header())
+		principal, authenticated := auth.FromContext(req.Context())
+		if authenticated {
+			switch {
+			case principal.Tenant != "" && name != "" && name != principal.Tenant:
+				httputil.WriteCode(w, httputil.CodeTenantForbidden, "Tenant header does not match the token's tenant", nil)
+				return
+			case principal.Tenant != "":
+				name = principal.Tenant
+			case principal.Role < auth.RoleAdmin:
+				// Only admins may pick a tenant, or act across all of them
+				httputil.WriteCode(w, httputil.CodeTenantForbidden, "Token has no tenant", nil)
+				return
+			}
+		}
+
+		if name == "" {
+			next.ServeHTTP(w, req)
+			return
+		}
+		t, ok := r.Get(name)
+		if !ok {
+			httputil.WriteCode(w, httputil.CodeTenantForbidden, "Unknown tenant: "+name, nil)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(WithTenant(req.Context(), t)))
+	})
+}
+
+// Refuse answers 403 to requests confined to a tenant, for endpoints that
+// act on the whole deployment.
+func Refuse(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if t, ok := FromContext(req.Context()); ok {
+			httputil.WriteCode(w, httputil.CodeTenantForbidden, "Not available to tenant "+t.Name, nil)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,166 @@
+// Package tenant confines each team using a shared deployment to its own
+// bronze zone: a bucket, an object prefix within it and a Nessie namespace.
+// A request's tenant is resolved once by Middleware and carried in its
+// context, so the storage, job and export layers can check every bucket and
+// key they touch against it.
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"bronze-backend/config"
+	"bronze-backend/httputil"
+)
+
+// Tenant is one team's bronze zone.
+type Tenant = config.Tenant
+
+type tenantKey struct{}
+
+// WithTenant returns ctx confined to t.
+func WithTenant(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, t)
+}
+
+// FromContext returns the tenant ctx is confined to. Requests without one
+// are deployment-wide.
+func FromContext(ctx context.Context) (*Tenant, bool) {
+	t, ok := ctx.Value(tenantKey{}).(*Tenant)
+	return t, ok
+}
+
+// Name returns the name of the tenant ctx is confined to, or "".
+func Name(ctx context.Context) string {
+	if t, ok := FromContext(ctx); ok {
+		return t.Name
+	}
+	return ""
+}
+
+// AccessError is returned for a bucket or key outside the tenant's zone.
+type AccessError struct {
+	Tenant string
+	Bucket string
+	Key    string
+}
+
+func (e *AccessError) Error() string {
+	return fmt.Sprintf("tenant %s has no access to %s/%s", e.Tenant, e.Bucket, e.Key)
+}
+
+// StatusCode makes httputil.WriteError answer 403.
+func (e *AccessError) StatusCode() int {
+	return http.StatusForbidden
+}
+
+// ErrorCode makes httputil.WriteError report httputil.CodeTenantForbidden.
+func (e *AccessError) ErrorCode() httputil.Code {
+	return httputil.CodeTenantForbidden
+}
+
+// Allows reports whether key in bucket is inside t's zone.
+func Allows(t *Tenant, bucket, key string) bool {
+	return bucket == t.Bucket && strings.HasPrefix(key, t.Prefix)
+}
+
+// Check returns an *AccessError if ctx is confined to a tenant and key in
+// bucket is outside its zone.
+func Check(ctx context.Context, bucket, key string) error {
+	t, ok := FromContext(ctx)
+	if !ok || Allows(t, bucket, key) {
+		return nil
+	}
+	return &AccessError{Tenant: t.Name, Bucket: bucket, Key: key}
+}
+
+// Bucket returns the bucket of the tenant ctx is confined to, or fallback.
+func Bucket(ctx context.Context, fallback string) string {
+	if t, ok := FromContext(ctx); ok {
+		return t.Bucket
+	}
+	return fallback
+}
+
+// Prefix returns the object prefix of the tenant ctx is confined to, or "".
+func Prefix(ctx context.Context) string {
+	if t, ok := FromContext(ctx); ok {
+		return t.Prefix
+	}
+	return ""
+}
+
+// Namespace returns the Nessie namespace of the tenant ctx is confined to,
+// or fallback.
+func Namespace(ctx context.Context, fallback string) string {
+	if t, ok := FromContext(ctx); ok {
+		return t.Namespace
+	}
+	return fallback
+}
+
+// Registry holds the configured tenants. A nil Registry means tenancy is
+// disabled: every request is deployment-wide.
+type Registry struct {
+	mu      sync.RWMutex
+	tenants map[string]*Tenant
+	header  string
+}
+
+// New returns a Registry for cfg, or nil when tenancy is disabled.
+func New(cfg config.TenancyConfig) (*Registry, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	r := &Registry{}
+	if err := r.SetConfig(cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SetConfig replaces the tenants. Requests already running keep the tenant
+// they started with.
+func (r *Registry) SetConfig(cfg config.TenancyConfig) error {
+	parsed, err := cfg.ParseTenants()
+	if err != nil {
+		return err
+	}
+	tenants := make(map[string]*Tenant, len(parsed))
+	for i := range parsed {
+		tenants[parsed[i].Name] = &parsed[i]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tenants = tenants
+	r.header = cfg.Header
+	log.Printf("Tenancy: %d tenants, selected by the %s header or token claim", len(tenants), cfg.Header)
+	return nil
+}
+
+// Get returns the tenant called name.
+func (r *Registry) Get(name string) (*Tenant, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tenants[name]
+	return t, ok
+}
+
+// Header returns the request header that names the tenant, or "" when
+// tenancy is disabled.
+func (r *Registry) Header() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.header
+}
@@ -0,0 +1,118 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bronze-backend/auth"
+	"bronze-backend/config"
+)
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r, err := New(config.TenancyConfig{Enabled: true, Tenants: "a=lake/team-a, b=lake-b:analytics", Header: "X-Tenant"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestNewDisabled(t *testing.T) {
+	r, err := New(config.TenancyConfig{Tenants: "a=lake"})
+	if r != nil || err != nil {
+		t.Fatalf("New() = %v, %v; want nil when disabled", r, err)
+	}
+	if _, ok := r.Get("a"); ok || r.Header() != "" {
+		t.Error("a nil Registry has tenants")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	r := newTestRegistry(t)
+	a, _ := r.Get("a")
+	ctx := WithTenant(context.Background(), a)
+
+	if err := Check(ctx, "lake", "team-a/raw/x.csv"); err != nil {
+		t.Errorf("Check() inside the zone = %v", err)
+	}
+	for _, tt := range []struct{ bucket, key string }{
+		{"lake", "team-b/x.csv"},
+		{"lake", "team-a.csv"},
+		{"lake-b", "team-a/x.csv"},
+	} {
+		var accessErr *AccessError
+		if err := Check(ctx, tt.bucket, tt.key); !errors.As(err, &accessErr) || accessErr.StatusCode() != http.StatusForbidden {
+			t.Errorf("Check(%s, %s) = %v, want an AccessError", tt.bucket, tt.key, err)
+		}
+	}
+	if err := Check(context.Background(), "other", "x.csv"); err != nil {
+		t.Errorf("Check() without a tenant = %v", err)
+	}
+
+	if Bucket(ctx, "default") != "lake" || Prefix(ctx) != "team-a/" || Namespace(ctx, "warehouse") != "a" {
+		t.Errorf("zone of a = %s/%s, namespace %s", Bucket(ctx, "default"), Prefix(ctx), Namespace(ctx, "warehouse"))
+	}
+	if Bucket(context.Background(), "default") != "default" || Namespace(context.Background(), "warehouse") != "warehouse" {
+		t.Error("a request without a tenant does not use the defaults")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	r := newTestRegistry(t)
+	handler := r.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Resolved", Name(req.Context()))
+	}))
+
+	for _, tt := range []struct {
+		name      string
+		principal *auth.Principal
+		header    string
+		want      int
+		tenant    string
+	}{
+		{"auth disabled, no header", nil, "", http.StatusOK, ""},
+		{"auth disabled, header", nil, "b", http.StatusOK, "b"},
+		{"unknown tenant", nil, "c", http.StatusForbidden, ""},
+		{"claim", &auth.Principal{Role: auth.RoleViewer, Tenant: "a"}, "", http.StatusOK, "a"},
+		{"claim and same header", &auth.Principal{Role: auth.RoleViewer, Tenant: "a"}, "a", http.StatusOK, "a"},
+		{"claim and other header", &auth.Principal{Role: auth.RoleAdmin, Tenant: "a"}, "b", http.StatusForbidden, ""},
+		{"no claim", &auth.Principal{Role: auth.RoleEditor}, "", http.StatusForbidden, ""},
+		{"no claim, header", &auth.Principal{Role: auth.RoleEditor}, "a", http.StatusForbidden, ""},
+		{"admin", &auth.Principal{Role: auth.RoleAdmin}, "", http.StatusOK, ""},
+		{"admin picks a tenant", &auth.Principal{Role: auth.RoleAdmin}, "b", http.StatusOK, "b"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/files", nil)
+			if tt.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), tt.principal))
+			}
+			if tt.header != "" {
+				req.Header.Set("X-Tenant", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want || rec.Header().Get("X-Resolved") != tt.tenant {
+				t.Errorf("got %d with tenant %q, want %d with %q", rec.Code, rec.Header().Get("X-Resolved"), tt.want, tt.tenant)
+			}
+		})
+	}
+}
+
+func TestRefuse(t *testing.T) {
+	r := newTestRegistry(t)
+	handler := r.Middleware(Refuse(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})))
+
+	for header, want := range map[string]int{"": http.StatusOK, "a": http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodGet, "/api/watcher/status", nil)
+		req.Header.Set("X-Tenant", header)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("tenant %q: status %d, want %d", header, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"bronze-backend/buildinfo"
+	"bronze-backend/config"
+	"bronze-backend/data_browser"
+	"bronze-backend/files"
+	"bronze-backend/jobs"
+	"bronze-backend/metering"
+	"bronze-backend/quarantine"
+	"bronze-backend/storage"
+	"bronze-backend/tempdir"
+	"bronze-backend/tenant"
+)
+
+// workers is the job queue and the pool processing it, shared by serve and
+// worker.
+type workers struct {
+	queue         jobs.Queue
+	pool          *jobs.WorkerPool // Nil on API-only instances
+	autoscaler    *jobs.Autoscaler // Nil unless autoscaling is enabled
+	notifier      *jobs.WebhookNotifier
+	fileProcessor *files.FileProcessor
+	tenants       *tenant.Registry  // Nil unless tenancy is enabled
+	usage         *metering.Tracker // Nil unless usage accounting is enabled
+	quarantine    *quarantine.Store // Nil unless quarantine is enabled
+	tempDir       *tempdir.Manager  // Nil on API-only instances
+}
+
+// newStorageClient connects to MinIO, returning nil if it cannot so the
+// server still comes up without it, with the object cache if configured.
+func newStorageClient(cfg *config.Config) *storage.MinIOClient {
+	storageClient, err := storage.NewMinIOClient(&cfg.MinIO)
+	if err != nil {
+		log.Printf("Warning: Failed to create MinIO client: %v", err)
+		log.Println("MinIO features will be disabled until connection is restored")
+		return nil
+	}
+	log.Println("MinIO client created successfully")
+
+	// Shared by browsing, exports and jobs
+	cache, err := storage.NewObjectCache(cfg.ObjectCache)
+	if err != nil {
+		log.Printf("Warning: Object cache disabled: %v", err)
+	} else if cache != nil {
+		storageClient.SetObjectCache(cache)
+		log.Printf("Object cache enabled in %s (%s)", cfg.ObjectCache.Dir, cfg.ObjectCache.Size)
+	}
+	return storageClient
+}
+
+// startWorkers opens the job queue, restoring saved jobs for the memory
+// queue, and starts the worker pool unless the instance only serves the API.
+func startWorkers(cfg *config.Config, storageClient *storage.MinIOClient) (*workers, error) {
+	fileProcessor := files.NewFileProcessor(cfg, storageClient)
+	log.Println("File processor created successfully")
+
+	// Shared by extraction jobs and the API's exports
+	quarantineStore := quarantine.New(cfg.Quarantine, storageClient)
+	fileProcessor.SetQuarantine(quarantineStore)
+
+	jobQueue, err := jobs.NewQueue(cfg.Processing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job queue: %w", err)
+	}
+	jobQueue.Start()
+	log.Printf("Job queue created successfully (backend: %s)", cfg.Processing.Queue.Backend)
+
+	if !cfg.Processing.Queue.IsDistributed() {
+		if restored, err := jobs.RestoreState(cfg.Processing.StateFile, jobQueue); err != nil {
+			log.Printf("Warning: Failed to restore job state: %v", err)
+		} else if restored > 0 {
+			log.Printf("Restored %d pending jobs from %s", restored, cfg.Processing.StateFile)
+		}
+	}
+
+	tenants, err := tenant.New(cfg.Tenancy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up tenants: %w", err)
+	}
+
+	// Workers account job time to the same database as the API
+	tracker, err := metering.Open(cfg.Usage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage database: %w", err)
+	}
+
+	webhookNotifier := jobs.NewWebhookNotifier(cfg.Processing.Webhook)
+	if cfg.Server.Mode == config.RunModeAPI {
+		return &workers{queue: jobQueue, notifier: webhookNotifier, fileProcessor: fileProcessor, tenants: tenants, usage: tracker, quarantine: quarantineStore}, nil
+	}
+
+	tempDir, err := tempdir.New(cfg.Processing)
+	if err != nil {
+		return nil, err
+	}
+	tempDir.Start()
+
+	workerPool := jobs.NewWorkerPool(cfg.Processing.MaxWorkers, jobQueue, fileProcessor)
+	workerPool.RegisterProcessor("verify", files.NewVerifyProcessor(storageClient))
+	workerPool.RegisterProcessor("convert", data_browser.NewConvertProcessor(storageClient))
+	workerPool.RegisterProcessor("validate", data_browser.NewValidateProcessor(storageClient))
+	workerPool.RegisterProcessor("promote", data_browser.NewPromoteProcessor(cfg, storageClient))
+	workerPool.RegisterProcessor("quality", data_browser.NewQualityProcessor(cfg, storageClient))
+	workerPool.RegisterProcessor("export", data_browser.NewExportProcessor(cfg, storageClient))
+	workerPool.SetNotifier(webhookNotifier)
+	workerPool.SetTenants(tenants)
+	workerPool.SetUsage(tracker)
+	workerPool.SetDeadLetters(jobs.NewDeadLetterQueue(storageClient))
+	workerPool.SetStorage(storageClient)
+	// Validated by config.Load
+	tempDiskLimit, _ := config.ParseByteSize(cfg.Processing.JobTempDiskLimit)
+	memoryLimit, _ := config.ParseByteSize(cfg.Processing.JobMemoryLimit)
+	workerPool.SetResourceLimits(tempDiskLimit, memoryLimit)
+	workerPool.SetTempDir(tempDir)
+	workerPool.Start()
+	log.Printf("Worker pool started with %d workers", cfg.Processing.MaxWorkers)
+
+	var autoscaler *jobs.Autoscaler
+	if cfg.Processing.Autoscale.Enabled {
+		autoscaler = jobs.NewAutoscaler(workerPool, jobQueue, cfg.Processing.Autoscale)
+		autoscaler.Start()
+	}
+
+	return &workers{
+		queue:         jobQueue,
+		pool:          workerPool,
+		autoscaler:    autoscaler,
+		notifier:      webhookNotifier,
+		fileProcessor: fileProcessor,
+		tenants:       tenants,
+		usage:         tracker,
+		quarantine:    quarantineStore,
+		tempDir:       tempDir,
+	}, nil
+}
+
+// stop stops the pool and the queue, saving the memory queue's pending jobs,
+// and closes the usage database.
+// The notifier is left running for whatever else forwards events through it.
+func (w *workers) stop(cfg *config.Config) {
+	if w.autoscaler != nil {
+		w.autoscaler.Stop()
+	}
+
+	if w.pool != nil {
+		w.pool.Stop()
+		log.Println("Worker pool stopped")
+		w.tempDir.Stop()
+	}
+
+	if !cfg.Processing.Queue.IsDistributed() {
+		if saved, err := jobs.SaveState(cfg.Processing.StateFile, w.queue); err != nil {
+			log.Printf("Warning: Failed to save job state: %v", err)
+		} else if saved > 0 {
+			log.Printf("Saved %d pending jobs to %s", saved, cfg.Processing.StateFile)
+		}
+	}
+
+	w.queue.Stop()
+
+	if err := w.usage.Close(); err != nil {
+		log.Printf("Warning: Failed to close usage database: %v", err)
+	}
+}
+
+// worker runs a worker instance, as RUN_MODE=worker does.
+func worker(args []string) error {
+	fs, opts := newFlagSet("worker", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	opts.overrides["RUN_MODE"] = config.RunModeWorker
+
+	log.Printf("Starting Bronze worker %s...", buildinfo.Get())
+
+	cfg, err := opts.load()
+	if err != nil {
+		return err
+	}
+	return runWorker(cfg, opts.envFile)
+}
+
+// runWorker processes jobs until interrupted, serving only the health probes.
+// Jobs come from the shared queue, so it is meant for QUEUE_BACKEND=redis;
+// with the memory queue it only works off jobs restored from JOB_STATE_FILE.
+func runWorker(cfg *config.Config, envFile string) error {
+	if !cfg.Processing.Queue.IsDistributed() {
+		log.Println("Warning: The memory queue is not shared; this worker only processes jobs restored from JOB_STATE_FILE")
+	}
+
+	storageClient := newStorageClient(cfg)
+	if storageClient == nil {
+		return fmt.Errorf("MinIO is required to process jobs")
+	}
+
+	processing, err := startWorkers(cfg, storageClient)
+	if err != nil {
+		return err
+	}
+
+	checker := newHealthChecker(storageClient, nil, processing.queue)
+	probes := http.NewServeMux()
+	probes.HandleFunc("GET /healthz", checker.Live)
+	probes.HandleFunc("GET /readyz", checker.Ready)
+	server, err := startServer(cfg, probes)
+	if err != nil {
+		processing.stop(cfg)
+		return err
+	}
+
+	configManager := newConfigManager(cfg, envFile, processing.pool, processing.autoscaler, nil, processing.fileProcessor, processing.tenants, processing.usage, processing.quarantine, nil)
+	stopReload := reloadOnSIGHUP(configManager)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down worker...")
+	stopReload()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server.shutdown(ctx)
+
+	processing.stop(cfg)
+	processing.notifier.Stop()
+	log.Println("Worker exited")
+	return nil
+}
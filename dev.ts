@@ -150,7 +150,7 @@ async function killPorts() {
     await new Promise(resolve => setTimeout(resolve, 1000));
     
     // Try to kill any remaining Go processes on port 8060
-    await checkCommand('pkill', ['-f', 'go run main.go']);
+    await checkCommand('pkill', ['-f', 'go run . serve']);
     
     // Try to kill any remaining Vite processes on port 8070
     await checkCommand('pkill', ['-f', 'vite']);
@@ -185,7 +185,7 @@ async function main() {
     backend = runService(
       'Backend',
       'go',
-      ['run', 'main.go'],
+      ['run', '.', 'serve'],
       BACKEND_DIR,
       '32' // Green
     );
@@ -11,6 +11,38 @@ export const api = axios.create({
   }
 })
 
+// The bucket this browser tab works in. The server keeps a default bucket
+// shared by every client; sending BUCKET_HEADER overrides it for this tab only.
+export const BUCKET_HEADER = 'X-Bucket'
+const ACTIVE_BUCKET_KEY = 'bronze.activeBucket'
+
+export function getActiveBucket(): string | null {
+  return sessionStorage.getItem(ACTIVE_BUCKET_KEY)
+}
+
+export function setActiveBucket(bucket: string | null): void {
+  if (bucket) {
+    sessionStorage.setItem(ACTIVE_BUCKET_KEY, bucket)
+  } else {
+    sessionStorage.removeItem(ACTIVE_BUCKET_KEY)
+  }
+}
+
+// bucketHeaders returns the headers selecting the active bucket, for requests
+// sent with fetch instead of api
+export function bucketHeaders(): Record<string, string> {
+  const bucket = getActiveBucket()
+  return bucket ? { [BUCKET_HEADER]: bucket } : {}
+}
+
+api.interceptors.request.use((config) => {
+  const bucket = getActiveBucket()
+  if (bucket) {
+    config.headers.set(BUCKET_HEADER, bucket)
+  }
+  return config
+})
+
 api.interceptors.response.use(
   (response) => response,
   (error) => {
@@ -1,4 +1,4 @@
-import { api } from './client'
+import { api, bucketHeaders } from './client'
 import type { UploadResponse, FileListResponse } from '@/types'
 import { isAbortError } from '@/utils/abortUtils'
 
@@ -51,6 +51,7 @@ export async function browseFolders(folders: any[], onEvent: SSEEventCallback, o
         'Content-Type': 'application/json',
         'Accept': 'text/event-stream',
         'Cache-Control': 'no-cache',
+        ...bucketHeaders(),
       },
       body: JSON.stringify({ folders }),
       signal: abortController?.signal,
@@ -1,13 +1,13 @@
 import { api } from './client'
-import type { CreateJobRequest, JobResponse, JobListResponse, ApiResponse, JobStats } from '@/types'
+import type { CreateJobRequest, JobResponse, JobListResponse, JobListQuery, ApiResponse, JobStats } from '@/types'
 
 export async function createJob(jobData: CreateJobRequest): Promise<JobResponse> {
   const { data } = await api.post('/api/jobs', jobData)
   return data
 }
 
-export async function getJobs(status?: string): Promise<JobListResponse> {
-  const params = status ? { status } : {}
+export async function getJobs(status?: string, query: JobListQuery = {}): Promise<JobListResponse> {
+  const params = status ? { ...query, status } : query
   const { data } = await api.get('/api/jobs', { params })
   return data
 }
@@ -71,6 +71,20 @@ export interface JobListResponse {
   message: string
   jobs: Job[]
   count: number
+  total: number
+  limit?: number
+  offset?: number
+}
+
+export interface JobListQuery {
+  type?: string
+  prefix?: string
+  created_after?: string
+  created_before?: string
+  sort?: 'created_at' | 'duration'
+  order?: 'asc' | 'desc'
+  limit?: number
+  offset?: number
 }
 
 export interface JobResponse {